@@ -28,6 +28,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -42,11 +43,16 @@ type ServerAndConfigs struct {
 	ConfigFiles json.RawMessage
 }
 
-// generate runs t3c-generate and returns the result.
-func generate(cfg config.Cfg) ([]t3cutil.ATSConfigFile, error) {
+// generate runs t3c-generate and returns the result, along with the IDs of the
+// content invalidation jobs the generated config applies.
+func generate(cfg config.Cfg) ([]t3cutil.ATSConfigFile, []uint64, error) {
 	configData, err := request(cfg, "config")
 	if err != nil {
-		return nil, errors.New("requesting: " + err.Error())
+		return nil, nil, errors.New("requesting: " + err.Error())
+	}
+	jobIDs, err := getJobIDs(configData)
+	if err != nil {
+		return nil, nil, errors.New("getting job IDs: " + err.Error())
 	}
 	args := []string{
 		"--log-location-error=" + outToErr(cfg.LogLocationErr),
@@ -71,7 +77,7 @@ func generate(cfg config.Cfg) ([]t3cutil.ATSConfigFile, error) {
 
 	generatedFiles, stdErr, code := t3cutil.DoInput(configData, config.GenerateCmd, args...)
 	if code != 0 {
-		return nil, fmt.Errorf("t3c-generate returned non-zero exit code %v stdout '%v' stderr '%v'", code, string(generatedFiles), string(stdErr))
+		return nil, nil, fmt.Errorf("t3c-generate returned non-zero exit code %v stdout '%v' stderr '%v'", code, string(generatedFiles), string(stdErr))
 	}
 	if len(bytes.TrimSpace(stdErr)) > 0 {
 		log.Warnln(`t3c-generate stderr start` + "\n" + string(stdErr))
@@ -80,15 +86,39 @@ func generate(cfg config.Cfg) ([]t3cutil.ATSConfigFile, error) {
 
 	preprocessedBytes, err := preprocess(cfg, configData, generatedFiles)
 	if err != nil {
-		return nil, errors.New("preprocessing config files: " + err.Error())
+		return nil, nil, errors.New("preprocessing config files: " + err.Error())
 	}
 
 	allFiles := []t3cutil.ATSConfigFile{}
 	if err := json.Unmarshal(preprocessedBytes, &allFiles); err != nil {
-		return nil, errors.New("unmarshalling generated files: " + err.Error())
+		return nil, nil, errors.New("unmarshalling generated files: " + err.Error())
 	}
 
-	return allFiles, nil
+	return allFiles, jobIDs, nil
+}
+
+// getJobIDs returns the IDs of the content invalidation jobs in the
+// 't3c-request --get-data=config' data configData for Delivery Services on the
+// server's CDN, which are the jobs applied by its regex_revalidate.config.
+func getJobIDs(configData []byte) ([]uint64, error) {
+	data := t3cutil.ConfigData{}
+	if err := json.Unmarshal(configData, &data); err != nil {
+		return nil, errors.New("unmarshalling config data: " + err.Error())
+	}
+	dses := map[string]struct{}{}
+	for _, ds := range data.DeliveryServices {
+		if ds.XMLID != nil {
+			dses[*ds.XMLID] = struct{}{}
+		}
+	}
+	ids := []uint64{}
+	for _, job := range data.Jobs {
+		if _, ok := dses[job.DeliveryService]; ok && job.ID > 0 {
+			ids = append(ids, uint64(job.ID))
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
 }
 
 // preprocess takes the to Data from 't3c-request --get-data=config' and the generated files from 't3c-generate', passes them to `t3c-preprocess`, and returns the result.
@@ -194,9 +224,10 @@ func getPackages(cfg config.Cfg) ([]Package, error) {
 	return pkgs, nil
 }
 
-// sendUpdate updates the given cache's queue update and reval status in Traffic Ops.
+// sendUpdate updates the given cache's queue update and reval status in Traffic Ops,
+// and reports the given content invalidation jobs as applied.
 // Note the statuses are the value to be set, not whether to set the value.
-func sendUpdate(cfg config.Cfg, updateStatus bool, revalStatus bool) error {
+func sendUpdate(cfg config.Cfg, updateStatus bool, revalStatus bool, jobIDs []uint64) error {
 	args := []string{
 		"--traffic-ops-timeout-milliseconds=" + strconv.FormatInt(int64(cfg.TOTimeoutMS), 10),
 		"--traffic-ops-user=" + cfg.TOUser,
@@ -209,6 +240,13 @@ func sendUpdate(cfg config.Cfg, updateStatus bool, revalStatus bool) error {
 		"--set-update-status=" + strconv.FormatBool(updateStatus),
 		"--set-reval-status=" + strconv.FormatBool(revalStatus),
 	}
+	if len(jobIDs) > 0 {
+		idStrs := make([]string, 0, len(jobIDs))
+		for _, id := range jobIDs {
+			idStrs = append(idStrs, strconv.FormatUint(id, 10))
+		}
+		args = append(args, "--acknowledge-jobs="+strings.Join(idStrs, ","))
+	}
 	if _, used := os.LookupEnv("TO_USER"); !used {
 		args = append(args, "--traffic-ops-user="+cfg.TOUser)
 	}
@@ -61,6 +61,7 @@ type TrafficOpsReq struct {
 	installedPkgs map[string]struct{} // map of packages which were installed by us.
 	pluginPkgs    map[string]struct{} // map of packages
 	changedFiles  []string            // list of config files which were changed
+	jobIDs        []uint64            // content invalidation jobs applied by the config files
 
 	configFiles          map[string]*ConfigFile
 	TrafficCtlReload     bool   // a traffic_ctl_reload is required
@@ -682,10 +683,11 @@ func (r *TrafficOpsReq) GetConfigFileList() error {
 		}
 	}
 
-	allFiles, err := generate(r.Cfg)
+	allFiles, jobIDs, err := generate(r.Cfg)
 	if err != nil {
 		return errors.New("requesting data generating config files: " + err.Error())
 	}
+	r.jobIDs = jobIDs
 
 	r.configFiles = map[string]*ConfigFile{}
 	for _, file := range allFiles {
@@ -1191,15 +1193,15 @@ func (r *TrafficOpsReq) UpdateTrafficOps(syncdsUpdate *UpdateStatus) (bool, erro
 		fallthrough
 	case t3cutil.ModeSyncDS:
 		if serverStatus.RevalPending {
-			err = sendUpdate(r.Cfg, false, true)
+			err = sendUpdate(r.Cfg, false, true, r.jobIDs)
 		} else {
-			err = sendUpdate(r.Cfg, false, false)
+			err = sendUpdate(r.Cfg, false, false, r.jobIDs)
 		}
 	case t3cutil.ModeRevalidate:
 		if serverStatus.UpdatePending {
-			err = sendUpdate(r.Cfg, true, false)
+			err = sendUpdate(r.Cfg, true, false, r.jobIDs)
 		} else {
-			err = sendUpdate(r.Cfg, false, false)
+			err = sendUpdate(r.Cfg, false, false, r.jobIDs)
 		}
 	}
 	if err != nil {
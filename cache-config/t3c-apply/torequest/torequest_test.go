@@ -141,3 +141,21 @@ func TestGetConfigFile(t *testing.T) {
 		t.Errorf("GetConfigFile('remap.config') failed, expected 'remap.config' got '" + cfg.Name + "'.")
 	}
 }
+
+func TestGetJobIDs(t *testing.T) {
+	configData := []byte(`{
+	"delivery_services": [{"xmlId": "ds1"}, {"xmlId": "ds2"}],
+	"jobs": [
+		{"id": 9, "deliveryService": "ds2"},
+		{"id": 3, "deliveryService": "ds1"},
+		{"id": 5, "deliveryService": "other-cdn-ds"}
+	]
+}`)
+	ids, err := getJobIDs(configData)
+	if err != nil {
+		t.Fatalf("getJobIDs() unexpected error: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != 3 || ids[1] != 9 {
+		t.Errorf("getJobIDs() expected [3 9], actual %v", ids)
+	}
+}
@@ -24,6 +24,8 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/apache/trafficcontrol/cache-config/t3cutil"
@@ -46,6 +48,9 @@ type Cfg struct {
 	GetData          string
 	UpdatePending    bool
 	RevalPending     bool
+	// AcknowledgeJobs are the IDs of the content invalidation jobs to report
+	// to Traffic Ops as applied.
+	AcknowledgeJobs []uint64
 	t3cutil.TCCfg
 }
 
@@ -72,6 +77,7 @@ func InitConfig() (Cfg, error) {
 	var revalPendingPtr bool
 	getopt.FlagLong(&updatePendingPtr, "set-update-status", 'q', "[true | false] sets the servers update status").Mandatory()
 	getopt.FlagLong(&revalPendingPtr, "set-reval-status", 'a', "[true | false] sets the servers revalidate status").Mandatory()
+	acknowledgeJobsPtr := getopt.StringLong("acknowledge-jobs", 'j', "", "Comma-separated IDs of the content invalidation jobs to report to Traffic Ops as applied")
 	toInsecurePtr := getopt.BoolLong("traffic-ops-insecure", 'I', "[true | false] ignore certificate errors from Traffic Ops")
 	toTimeoutMSPtr := getopt.IntLong("traffic-ops-timeout-milliseconds", 't', 30000, "Timeout in milli-seconds for Traffic Ops requests, default is 30000")
 	toURLPtr := getopt.StringLong("traffic-ops-url", 'u', "", "Traffic Ops URL. Must be the full URL, including the scheme. Required. May also be set with     the environment variable TO_URL")
@@ -114,6 +120,11 @@ func InitConfig() (Cfg, error) {
 		return Cfg{}, errors.New("invalid Traffic Ops URL from " + urlSourceStr + " '" + toURL + "': " + err.Error())
 	}
 
+	acknowledgeJobs, err := parseJobIDs(*acknowledgeJobsPtr)
+	if err != nil {
+		return Cfg{}, errors.New("parsing acknowledge-jobs: " + err.Error())
+	}
+
 	var cacheHostName string
 	if len(*cacheHostNamePtr) > 0 {
 		cacheHostName = *cacheHostNamePtr
@@ -132,6 +143,7 @@ func InitConfig() (Cfg, error) {
 		LoginDispersion:  dispersion,
 		UpdatePending:    updatePendingPtr,
 		RevalPending:     revalPendingPtr,
+		AcknowledgeJobs:  acknowledgeJobs,
 		TCCfg: t3cutil.TCCfg{
 			CacheHostName: cacheHostName,
 			GetData:       "update-status",
@@ -150,3 +162,20 @@ func InitConfig() (Cfg, error) {
 
 	return cfg, nil
 }
+
+// parseJobIDs parses a comma-separated list of job IDs.
+func parseJobIDs(s string) ([]uint64, error) {
+	ids := []uint64{}
+	for _, idStr := range strings.Split(s, ",") {
+		idStr = strings.TrimSpace(idStr)
+		if idStr == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid job ID '" + idStr + "'")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -56,6 +56,15 @@ func main() {
 		os.Exit(3)
 	}
 
+	if len(cfg.AcknowledgeJobs) > 0 {
+		// acknowledgements are informational, so failing to make them doesn't fail the update
+		if err := t3cutil.AcknowledgeJobs(*tccfg, cfg.TCCfg.CacheHostName, cfg.AcknowledgeJobs); err != nil {
+			log.Errorf("acknowledging jobs %v (continuing anyway): %s\n", cfg.AcknowledgeJobs, err)
+		} else {
+			log.Infof("acknowledged jobs %v\n", cfg.AcknowledgeJobs)
+		}
+	}
+
 	cur_status, err := t3cutil.GetServerUpdateStatus(*tccfg)
 	if err != nil {
 		log.Errorf("%s, %s\n", err, cfg.TCCfg.CacheHostName)
@@ -221,6 +221,37 @@ func SetUpdateStatus(cfg TCCfg, serverName string, queue bool, revalPending bool
 	return nil
 }
 
+// AcknowledgeJobsPath is the path of the Traffic Ops endpoint to which caches
+// report the content invalidation jobs they have applied. It only exists in
+// API 4.0 and later.
+const AcknowledgeJobsPath = `/api/4.0/jobs/acknowledgements`
+
+// AcknowledgeJobs reports to Traffic Ops that serverName has applied the
+// content invalidation jobs with the given IDs.
+func AcknowledgeJobs(cfg TCCfg, serverName string, jobIDs []uint64) error {
+	if cfg.TOClient.C == nil {
+		return errors.New("Traffic Ops is too old to support job acknowledgements")
+	}
+	body, err := json.Marshal(tc.InvalidationJobAcknowledgement{HostName: &serverName, JobIDs: jobIDs})
+	if err != nil {
+		return errors.New("marshalling job acknowledgement: " + err.Error())
+	}
+	// The client is for an older API version than the endpoint, so this must be a raw request.
+	resp, remoteAddr, err := cfg.TOClient.C.RawRequestWithHdr(http.MethodPost, AcknowledgeJobsPath, body, nil)
+	if err != nil {
+		return errors.New("acknowledging jobs (Traffic Ops '" + torequtil.MaybeIPStr(remoteAddr) + "'): " + err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bodyBts, err := ioutil.ReadAll(resp.Body)
+		if err == nil {
+			return fmt.Errorf("Traffic Ops returned %v %v", resp.StatusCode, string(bodyBts))
+		}
+		return fmt.Errorf("Traffic Ops returned %v (error reading body)", resp.StatusCode)
+	}
+	return nil
+}
+
 // setUpdateStatusLegacy sets the queue and reval status of serverName in Traffic Ops,
 // using the legacy pre-2.0 /update endpoint.
 func setUpdateStatusLegacy(cfg TCCfg, serverName tc.CacheName, queue bool, revalPending bool) error {
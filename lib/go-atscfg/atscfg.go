@@ -645,6 +645,18 @@ func hasRequiredCapabilities(caps map[ServerCapability]struct{}, reqCaps map[Ser
 	return true
 }
 
+// missingRequiredCapabilities returns the sorted names of the required capabilities reqCaps which aren't in caps.
+func missingRequiredCapabilities(caps map[ServerCapability]struct{}, reqCaps map[ServerCapability]struct{}) []string {
+	missing := []string{}
+	for reqCap, _ := range reqCaps {
+		if _, ok := caps[reqCap]; !ok {
+			missing = append(missing, string(reqCap))
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
 // makeErr takes a list of warnings and an error string, and combines them to a single error.
 // Configs typically generate a list of warnings as they go. When an error is encountered, we want to combine the warnings encountered and include them in the returned error message, since they're likely hints as to why the error occurred.
 func makeErr(warnings []string, err string) error {
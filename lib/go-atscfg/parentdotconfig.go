@@ -1053,20 +1053,7 @@ func getTopologyParents(
 			continue
 		}
 
-		if !strings.HasPrefix(sv.Type, tc.EdgeTypePrefix) && !strings.HasPrefix(sv.Type, tc.MidTypePrefix) && sv.Type != tc.OriginTypeName {
-			continue // only consider edges, mids, and origins in the CacheGroup.
-		}
-		if _, dsHasOrigin := dsOrigins[ServerID(*sv.ID)]; sv.Type == tc.OriginTypeName && !dsHasOrigin {
-			continue
-		}
-		if *sv.CDNName != *server.CDNName {
-			continue
-		}
-		if *sv.Status != string(tc.CacheStatusReported) && *sv.Status != string(tc.CacheStatusOnline) {
-			continue
-		}
-
-		if sv.Type != tc.OriginTypeName && !hasRequiredCapabilities(serverCapabilities[*sv.ID], dsRequiredCapabilities[*ds.ID]) {
+		if topologyParentExclusion(&sv.Server, *server.CDNName, *ds.ID, dsOrigins, serverCapabilities, dsRequiredCapabilities) != "" {
 			continue
 		}
 		if *sv.Cachegroup == parentCG {
@@ -1090,6 +1077,42 @@ func getTopologyParents(
 	return parentStrs, secondaryParentStrs, warnings, nil
 }
 
+// topologyParentExclusion returns why the given server may not be a parent,
+// in a Topology, for the Delivery Service with the given ID in the CDN with
+// the given name, or the empty string if it may be. The server's ID, CDNName,
+// and Status must not be nil.
+//
+// Note this doesn't consider the server's Cache Group, nor whether its
+// Profile has the not_a_parent Parameter.
+func topologyParentExclusion(
+	sv *Server,
+	cdnName string,
+	dsID int,
+	dsOrigins map[ServerID]struct{},
+	serverCapabilities map[int]map[ServerCapability]struct{},
+	dsRequiredCapabilities map[int]map[ServerCapability]struct{},
+) string {
+	// only consider edges, mids, and origins in the CacheGroup.
+	if !strings.HasPrefix(sv.Type, tc.EdgeTypePrefix) && !strings.HasPrefix(sv.Type, tc.MidTypePrefix) && sv.Type != tc.OriginTypeName {
+		return "type '" + sv.Type + "' is not an edge, mid, or origin type"
+	}
+	if _, dsHasOrigin := dsOrigins[ServerID(*sv.ID)]; sv.Type == tc.OriginTypeName && !dsHasOrigin {
+		return "origin is not assigned to the Delivery Service"
+	}
+	if *sv.CDNName != cdnName {
+		return "in CDN '" + *sv.CDNName + "', not '" + cdnName + "'"
+	}
+	if *sv.Status != string(tc.CacheStatusReported) && *sv.Status != string(tc.CacheStatusOnline) {
+		return "status '" + *sv.Status + "' is not " + string(tc.CacheStatusReported) + " or " + string(tc.CacheStatusOnline)
+	}
+	if sv.Type != tc.OriginTypeName {
+		if missing := missingRequiredCapabilities(serverCapabilities[*sv.ID], dsRequiredCapabilities[dsID]); len(missing) > 0 {
+			return "missing required capabilities: " + strings.Join(missing, ", ")
+		}
+	}
+	return ""
+}
+
 // getOriginURI returns the URL, any warnings, and any error.
 func getOriginURI(fqdn string) (*url.URL, []string, error) {
 	warnings := []string{}
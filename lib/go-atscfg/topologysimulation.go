@@ -0,0 +1,231 @@
+package atscfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+)
+
+// SimulateTopology returns the path through the given Topology taken by
+// requests for the given Delivery Service which arrive at the given Cache
+// Group, as directed by the parent.config generated by MakeParentDotConfig,
+// along with any warnings, and any error.
+//
+// The servers are those of every Cache Group in the Topology; those in other
+// CDNs, with other Statuses, or without the Delivery Service's required
+// capabilities are reported as excluded, with the reason.
+func SimulateTopology(
+	ds *DeliveryService,
+	cacheGroup tc.CacheGroupName,
+	topology tc.Topology,
+	servers []Server,
+	tcParentConfigParams []tc.Parameter,
+	serverCapabilities map[int]map[ServerCapability]struct{},
+	dsRequiredCapabilities map[int]map[ServerCapability]struct{},
+	cacheGroupArr []tc.CacheGroupNullable,
+	dss []DeliveryServiceServer,
+) (tc.TopologySimulation, []string, error) {
+	warnings := []string{}
+
+	if ds.ID == nil || ds.XMLID == nil || *ds.XMLID == "" {
+		return tc.TopologySimulation{}, warnings, errors.New("Delivery Service missing ID or XMLID")
+	} else if ds.CDNName == nil {
+		return tc.TopologySimulation{}, warnings, errors.New("Delivery Service '" + *ds.XMLID + "' missing CDNName")
+	} else if ds.OrgServerFQDN == nil || *ds.OrgServerFQDN == "" {
+		return tc.TopologySimulation{}, warnings, errors.New("Delivery Service '" + *ds.XMLID + "' has no origin server")
+	}
+
+	cacheGroups, err := makeCGMap(cacheGroupArr)
+	if err != nil {
+		return tc.TopologySimulation{}, warnings, errors.New("making CacheGroup map: " + err.Error())
+	}
+
+	parentConfigParamsWithProfiles, err := tcParamsToParamsWithProfiles(tcParentConfigParams)
+	if err != nil {
+		warnings = append(warnings, "error getting profiles from Traffic Ops Parameters, Parameters will not be considered for simulation! : "+err.Error())
+		parentConfigParamsWithProfiles = []parameterWithProfiles{}
+	}
+	parentConfigParams := parameterWithProfilesToMap(parentConfigParamsWithProfiles)
+
+	dsOrigins, dsOriginWarns := makeDSOrigins(dss, []DeliveryService{*ds}, servers)
+	warnings = append(warnings, dsOriginWarns...)
+	origins := dsOrigins[DeliveryServiceID(*ds.ID)]
+
+	// Parents are listed in the same order as getTopologyParents lists them.
+	rankedServers := []serverWithParams{}
+	for _, sv := range servers {
+		if sv.ID == nil || sv.HostName == nil {
+			warnings = append(warnings, "TO Servers server had nil ID or HostName, skipping")
+			continue
+		} else if sv.Cachegroup == nil || sv.CDNName == nil || sv.Status == nil || *sv.Status == "" || sv.Profile == nil {
+			warnings = append(warnings, "TO Servers server '"+*sv.HostName+"' had missing Cachegroup, CDNName, Status, or Profile, skipping")
+			continue
+		}
+		params, paramWarns := serverParentageParams(&sv, parentConfigParams)
+		warnings = append(warnings, paramWarns...)
+		rankedServers = append(rankedServers, serverWithParams{Server: sv, Params: params})
+	}
+	sort.Sort(serversWithParamsSortByRank(rankedServers))
+
+	sim := tc.TopologySimulation{
+		Topology:        topology.Name,
+		DeliveryService: *ds.XMLID,
+		CacheGroup:      string(cacheGroup),
+		Tiers:           []tc.TopologySimulationTier{},
+	}
+
+	visited := map[tc.CacheGroupName]struct{}{}
+	for cg := cacheGroup; ; {
+		if _, ok := visited[cg]; ok {
+			return sim, warnings, errors.New("topology '" + topology.Name + "' has a cycle at cachegroup '" + string(cg) + "'")
+		}
+		visited[cg] = struct{}{}
+
+		placement, err := getTopologyPlacement(cg, topology, cacheGroups, ds)
+		if err != nil {
+			return sim, warnings, errors.New("getting topology placement: " + err.Error())
+		}
+		if !placement.InTopology {
+			return sim, warnings, errors.New("cachegroup '" + string(cg) + "' is not in topology '" + topology.Name + "'")
+		}
+
+		tier := tc.TopologySimulationTier{
+			CacheGroup: string(cg),
+			Servers:    []tc.TopologySimulationServer{},
+			Excluded:   []tc.TopologySimulationExclusion{},
+		}
+		for _, sv := range rankedServers {
+			if *sv.Cachegroup != string(cg) {
+				continue
+			}
+			if reason := topologyParentExclusion(&sv.Server, *ds.CDNName, *ds.ID, origins, serverCapabilities, dsRequiredCapabilities); reason != "" {
+				tier.Excluded = append(tier.Excluded, simulationExclusion(sv, reason))
+				continue
+			}
+			tier.Servers = append(tier.Servers, simulationServer(sv, ""))
+		}
+
+		// If it's the last tier, then the parent is the origin.
+		// Note this doesn't include MSO, whose final tier cachegroup points to the origin cachegroup.
+		if placement.IsLastTier {
+			orgURI, orgWarns, err := getOriginURI(*ds.OrgServerFQDN)
+			warnings = append(warnings, orgWarns...)
+			if err != nil {
+				return sim, warnings, errors.New("DS '" + *ds.XMLID + "' has malformed origin URI: '" + *ds.OrgServerFQDN + "': " + err.Error())
+			}
+			origin := orgURI.Host
+			tier.Origin = &origin
+			sim.Tiers = append(sim.Tiers, tier)
+			return sim, warnings, nil
+		}
+
+		node := tc.TopologyNode{}
+		for _, n := range topology.Nodes {
+			if n.Cachegroup == string(cg) {
+				node = n
+				break
+			}
+		}
+		if numParents := len(node.Parents); numParents > 2 {
+			warnings = append(warnings, "topology '"+topology.Name+"' cachegroup '"+string(cg)+"' has "+strconv.Itoa(numParents)+" parent nodes, but Apache Traffic Server only supports Primary and Secondary (2) lists of parents. CacheGroup nodes after the first 2 will be ignored!")
+		}
+		parentCG := tc.CacheGroupName(topology.Nodes[node.Parents[0]].Cachegroup)
+		tier.PrimaryParent = simulateParent(parentCG, rankedServers, *ds.CDNName, *ds.ID, origins, serverCapabilities, dsRequiredCapabilities)
+		if len(tier.PrimaryParent.Servers) == 0 {
+			warnings = append(warnings, "cachegroup '"+string(parentCG)+"' has no servers which may be parents of cachegroup '"+string(cg)+"'; parent.config for its servers will have no line for DS '"+*ds.XMLID+"'")
+		}
+		if len(node.Parents) > 1 {
+			if len(topology.Nodes) <= node.Parents[1] {
+				warnings = append(warnings, "topology '"+topology.Name+"' cachegroup '"+string(cg)+"' secondary parent "+strconv.Itoa(node.Parents[1])+" greater than number of topology nodes "+strconv.Itoa(len(topology.Nodes))+". Secondary parent will be ignored!")
+			} else {
+				secondaryCG := tc.CacheGroupName(topology.Nodes[node.Parents[1]].Cachegroup)
+				tier.SecondaryParent = simulateParent(secondaryCG, rankedServers, *ds.CDNName, *ds.ID, origins, serverCapabilities, dsRequiredCapabilities)
+			}
+		}
+		sim.Tiers = append(sim.Tiers, tier)
+
+		// An MSO Delivery Service's last cache tier's parents are its origins.
+		if parent, ok := cacheGroups[parentCG]; ok && parent.Type != nil && *parent.Type == tc.CacheGroupOriginTypeName {
+			return sim, warnings, nil
+		}
+		cg = parentCG
+	}
+}
+
+// simulateParent returns the servers of the given Cache Group which are
+// parents in a topology, and those which aren't, and why.
+func simulateParent(
+	cg tc.CacheGroupName,
+	rankedServers []serverWithParams,
+	cdnName string,
+	dsID int,
+	dsOrigins map[ServerID]struct{},
+	serverCapabilities map[int]map[ServerCapability]struct{},
+	dsRequiredCapabilities map[int]map[ServerCapability]struct{},
+) *tc.TopologySimulationParent {
+	parent := tc.TopologySimulationParent{
+		CacheGroup: string(cg),
+		Servers:    []tc.TopologySimulationServer{},
+		Excluded:   []tc.TopologySimulationExclusion{},
+	}
+	for _, sv := range rankedServers {
+		if *sv.Cachegroup != string(cg) {
+			continue
+		}
+		if reason := topologyParentExclusion(&sv.Server, cdnName, dsID, dsOrigins, serverCapabilities, dsRequiredCapabilities); reason != "" {
+			parent.Excluded = append(parent.Excluded, simulationExclusion(sv, reason))
+			continue
+		}
+		if sv.Params.NotAParent {
+			parent.Excluded = append(parent.Excluded, simulationExclusion(sv, "Profile has the "+ParentConfigCacheParamNotAParent+" Parameter"))
+			continue
+		}
+		parentStr, err := serverParentStr(&sv.Server, sv.Params)
+		if err != nil {
+			parent.Excluded = append(parent.Excluded, simulationExclusion(sv, "getting server parent string, which prevents generating parent.config: "+err.Error()))
+			continue
+		}
+		parent.Servers = append(parent.Servers, simulationServer(sv, parentStr))
+	}
+	return &parent
+}
+
+func simulationServer(sv serverWithParams, parent string) tc.TopologySimulationServer {
+	return tc.TopologySimulationServer{
+		ID:       *sv.ID,
+		HostName: *sv.HostName,
+		Type:     sv.Type,
+		Status:   *sv.Status,
+		Rank:     sv.Params.Rank,
+		Parent:   parent,
+	}
+}
+
+func simulationExclusion(sv serverWithParams, reason string) tc.TopologySimulationExclusion {
+	return tc.TopologySimulationExclusion{
+		ID:       *sv.ID,
+		HostName: *sv.HostName,
+		Reason:   reason,
+	}
+}
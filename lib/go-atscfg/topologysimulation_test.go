@@ -0,0 +1,212 @@
+package atscfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+)
+
+func makeSimulationServer(id int, hostName string, cg string) Server {
+	sv := makeTestParentServer()
+	sv.ID = util.IntPtr(id)
+	sv.HostName = util.StrPtr(hostName)
+	sv.Cachegroup = util.StrPtr(cg)
+	sv.Type = tc.MidTypePrefix
+	return *sv
+}
+
+func makeSimulationCG(name string, cgType string) tc.CacheGroupNullable {
+	cg := tc.CacheGroupNullable{}
+	cg.Name = util.StrPtr(name)
+	cg.Type = util.StrPtr(cgType)
+	return cg
+}
+
+func simulationExclusionReason(excluded []tc.TopologySimulationExclusion, hostName string) string {
+	for _, ex := range excluded {
+		if ex.HostName == hostName {
+			return ex.Reason
+		}
+	}
+	return ""
+}
+
+func TestSimulateTopology(t *testing.T) {
+	ds := makeParentDS()
+	ds.XMLID = util.StrPtr("ds0")
+	ds.CDNName = util.StrPtr("myCDN")
+	ds.OrgServerFQDN = util.StrPtr("http://ds0.example.net")
+	ds.Topology = util.StrPtr("t0")
+
+	edge := makeSimulationServer(1, "myedge", "edgeCG")
+	edge.Type = tc.EdgeTypePrefix
+	mid0 := makeSimulationServer(2, "mymid0", "midCG")
+	mid1 := makeSimulationServer(3, "mymid1", "midCG")
+	mid1.Status = util.StrPtr(string(tc.CacheStatusOffline))
+	mid2 := makeSimulationServer(4, "mymid2", "midCG")
+	mid2.Profile = util.StrPtr("notaparent")
+	mid3 := makeSimulationServer(5, "mymid3", "midCG")
+	mid3.CDNName = util.StrPtr("otherCDN")
+	mid4 := makeSimulationServer(6, "mymid4", "midCG")
+	mid5 := makeSimulationServer(7, "mymid5", "midCG")
+	mid5.Profile = util.StrPtr("rank0")
+	secondary := makeSimulationServer(8, "mysecondary", "secondaryCG")
+	servers := []Server{edge, mid0, mid1, mid2, mid3, mid4, mid5, secondary}
+
+	params := []tc.Parameter{
+		{Name: ParentConfigCacheParamNotAParent, ConfigFile: "parent.config", Value: "true", Profiles: []byte(`["notaparent"]`)},
+		{Name: ParentConfigCacheParamRank, ConfigFile: "parent.config", Value: "0", Profiles: []byte(`["rank0"]`)},
+	}
+
+	serverCapabilities := map[int]map[ServerCapability]struct{}{}
+	for _, sv := range servers {
+		if *sv.ID != 6 {
+			serverCapabilities[*sv.ID] = map[ServerCapability]struct{}{"FOO": {}}
+		}
+	}
+	dsRequiredCapabilities := map[int]map[ServerCapability]struct{}{*ds.ID: {"FOO": {}}}
+
+	topology := tc.Topology{
+		Name: "t0",
+		Nodes: []tc.TopologyNode{
+			{Cachegroup: "edgeCG", Parents: []int{1, 2}},
+			{Cachegroup: "midCG"},
+			{Cachegroup: "secondaryCG"},
+		},
+	}
+	cgs := []tc.CacheGroupNullable{
+		makeSimulationCG("edgeCG", tc.CacheGroupEdgeTypeName),
+		makeSimulationCG("midCG", tc.CacheGroupMidTypeName),
+		makeSimulationCG("secondaryCG", tc.CacheGroupMidTypeName),
+	}
+
+	sim, _, err := SimulateTopology(ds, "edgeCG", topology, servers, params, serverCapabilities, dsRequiredCapabilities, cgs, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sim.Tiers) != 2 {
+		t.Fatalf("expected 2 tiers, got %d: %+v", len(sim.Tiers), sim.Tiers)
+	}
+
+	edgeTier := sim.Tiers[0]
+	if edgeTier.CacheGroup != "edgeCG" || len(edgeTier.Servers) != 1 || edgeTier.Servers[0].HostName != "myedge" {
+		t.Errorf("expected first tier to be edgeCG with server myedge, got: %+v", edgeTier)
+	}
+	if edgeTier.Origin != nil {
+		t.Errorf("expected first tier not to go to origin, got: %s", *edgeTier.Origin)
+	}
+	if edgeTier.PrimaryParent == nil || edgeTier.PrimaryParent.CacheGroup != "midCG" {
+		t.Fatalf("expected primary parent midCG, got: %+v", edgeTier.PrimaryParent)
+	}
+	if edgeTier.SecondaryParent == nil || edgeTier.SecondaryParent.CacheGroup != "secondaryCG" || len(edgeTier.SecondaryParent.Servers) != 1 {
+		t.Errorf("expected secondary parent secondaryCG with one server, got: %+v", edgeTier.SecondaryParent)
+	}
+
+	parents := edgeTier.PrimaryParent.Servers
+	if len(parents) != 2 {
+		t.Fatalf("expected 2 primary parents, got %d: %+v", len(parents), parents)
+	}
+	if parents[0].HostName != "mymid5" || parents[1].HostName != "mymid0" {
+		t.Errorf("expected parents in order of rank, got '%s' then '%s'", parents[0].HostName, parents[1].HostName)
+	}
+	if parents[1].Parent != "mymid0.mydomain.example.net:80|0.999" {
+		t.Errorf("expected parent.config entry 'mymid0.mydomain.example.net:80|0.999', got '%s'", parents[1].Parent)
+	}
+
+	excluded := edgeTier.PrimaryParent.Excluded
+	expectedReasons := map[string]string{
+		"mymid1": "status",
+		"mymid2": ParentConfigCacheParamNotAParent,
+		"mymid3": "otherCDN",
+		"mymid4": "missing required capabilities: FOO",
+	}
+	if len(excluded) != len(expectedReasons) {
+		t.Errorf("expected %d excluded servers, got %d: %+v", len(expectedReasons), len(excluded), excluded)
+	}
+	for hostName, expected := range expectedReasons {
+		if reason := simulationExclusionReason(excluded, hostName); !strings.Contains(reason, expected) {
+			t.Errorf("expected server '%s' to be excluded for a reason containing '%s', got: '%s'", hostName, expected, reason)
+		}
+	}
+
+	midTier := sim.Tiers[1]
+	if midTier.CacheGroup != "midCG" || midTier.PrimaryParent != nil {
+		t.Errorf("expected last tier to be midCG without parents, got: %+v", midTier)
+	}
+	if midTier.Origin == nil || *midTier.Origin != "ds0.example.net:80" {
+		t.Errorf("expected last tier to go to origin 'ds0.example.net:80', got: %+v", midTier)
+	}
+
+	if _, _, err := SimulateTopology(ds, "otherCG", topology, servers, params, serverCapabilities, dsRequiredCapabilities, cgs, nil); err == nil {
+		t.Error("expected an error simulating from a cachegroup that isn't in the topology")
+	}
+}
+
+func TestSimulateTopologyMSO(t *testing.T) {
+	ds := makeParentDS()
+	ds.XMLID = util.StrPtr("ds0")
+	ds.CDNName = util.StrPtr("myCDN")
+	ds.OrgServerFQDN = util.StrPtr("http://ds0.example.net")
+	ds.Topology = util.StrPtr("t0")
+	ds.MultiSiteOrigin = util.BoolPtr(true)
+
+	edge := makeSimulationServer(1, "myedge", "edgeCG")
+	edge.Type = tc.EdgeTypePrefix
+	org0 := makeSimulationServer(2, "myorg0", "orgCG")
+	org0.Type = tc.OriginTypeName
+	org1 := makeSimulationServer(3, "myorg1", "orgCG")
+	org1.Type = tc.OriginTypeName
+	servers := []Server{edge, org0, org1}
+
+	topology := tc.Topology{
+		Name: "t0",
+		Nodes: []tc.TopologyNode{
+			{Cachegroup: "edgeCG", Parents: []int{1}},
+			{Cachegroup: "orgCG"},
+		},
+	}
+	cgs := []tc.CacheGroupNullable{
+		makeSimulationCG("edgeCG", tc.CacheGroupEdgeTypeName),
+		makeSimulationCG("orgCG", tc.CacheGroupOriginTypeName),
+	}
+	dss := []DeliveryServiceServer{{Server: 2, DeliveryService: *ds.ID}}
+
+	sim, _, err := SimulateTopology(ds, "edgeCG", topology, servers, nil, nil, nil, cgs, dss)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sim.Tiers) != 1 {
+		t.Fatalf("expected 1 tier, got %d: %+v", len(sim.Tiers), sim.Tiers)
+	}
+	tier := sim.Tiers[0]
+	if tier.Origin != nil {
+		t.Errorf("expected MSO last cache tier to have origin cachegroup parents, not origin '%s'", *tier.Origin)
+	}
+	if tier.PrimaryParent == nil || len(tier.PrimaryParent.Servers) != 1 || tier.PrimaryParent.Servers[0].HostName != "myorg0" {
+		t.Fatalf("expected origin myorg0 as the only parent, got: %+v", tier.PrimaryParent)
+	}
+	if reason := simulationExclusionReason(tier.PrimaryParent.Excluded, "myorg1"); !strings.Contains(reason, "not assigned") {
+		t.Errorf("expected unassigned origin to be excluded, got reason: '%s'", reason)
+	}
+}
@@ -32,11 +32,13 @@ import (
 // caught at compile-time.
 const (
 	AcceptEncoding     = "Accept-Encoding"     // RFC7231§5.3.4
+	Authorization      = "Authorization"       // RFC7235§4.2
 	CacheControl       = "Cache-Control"       // RFC7234§5.2
 	ContentDisposition = "Content-Disposition" // RFC6266
 	ContentEncoding    = "Content-Encoding"    // RFC7231§3.1.2.2
 	ContentType        = "Content-Type"        // RFC7231§3.1.1.5
 	PermissionsPolicy  = "Permissions-Policy"  // W3C "Permissions Policy"
+	RetryAfter         = "Retry-After"         // RFC7231§7.1.3
 	Server             = "Server"              // RFC7231§7.4.2
 	UserAgent          = "User-Agent"          // RFC7231§5.5.3
 	Vary               = "Vary"                // RFC7231§7.1.4
@@ -47,6 +49,7 @@ const (
 const (
 	ApplicationJSON           = "application/json"         // RFC4627§6
 	ApplicationOctetStream    = "application/octet-stream" // RFC2046§4.5.2
+	ApplicationYAML           = "application/yaml"         // RFC9512§2.1
 	ContentTypeMultiPartMixed = "multipart/mixed"          // RFC1341§7.2
 	ContentTypeTextPlain      = "text/plain"               // RFC2046§4.1
 	ContentTypeURIList        = "text/uri-list"            // RFC2483§5
@@ -0,0 +1,126 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APITokenPrefix is the prefix of every APIToken's secret value, which makes
+// leaked tokens easy to recognize.
+const APITokenPrefix = "tops_"
+
+// APITokenMethods is the set of HTTP request methods to which an APIToken may
+// be restricted.
+var APITokenMethods = map[string]struct{}{
+	http.MethodGet:    {},
+	http.MethodPost:   {},
+	http.MethodPut:    {},
+	http.MethodPatch:  {},
+	http.MethodDelete: {},
+}
+
+// APIToken is a personal access token, which authenticates requests as the
+// user who created it, optionally restricted to some API routes and request
+// methods.
+type APIToken struct {
+	ID   int    `json:"id" db:"id"`
+	Name string `json:"name" db:"name"`
+	// Routes are the IDs of the API routes the token may be used with. If
+	// empty, it may be used with any route.
+	Routes []int `json:"routes"`
+	// Methods are the HTTP request methods the token may be used with. If
+	// empty, it may be used with any method.
+	Methods  []string   `json:"methods"`
+	Expires  time.Time  `json:"expires" db:"expires"`
+	LastUsed *time.Time `json:"lastUsed" db:"last_used"`
+	Created  time.Time  `json:"created" db:"created"`
+}
+
+// APITokenRequest is the request body used to create an APIToken.
+type APITokenRequest struct {
+	Name    *string    `json:"name"`
+	Routes  []int      `json:"routes"`
+	Methods []string   `json:"methods"`
+	Expires *time.Time `json:"expires"`
+}
+
+// Validate implements the
+// github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api.ParseValidator
+// interface. Methods are normalized to upper case.
+func (r *APITokenRequest) Validate(*sql.Tx) error {
+	errs := []string{}
+	if r.Name == nil || strings.TrimSpace(*r.Name) == "" {
+		errs = append(errs, "'name' is required")
+	}
+	if r.Expires == nil {
+		errs = append(errs, "'expires' is required")
+	} else if !r.Expires.After(time.Now()) {
+		errs = append(errs, "'expires' must be in the future")
+	}
+	for _, route := range r.Routes {
+		if route <= 0 {
+			errs = append(errs, "invalid route ID "+strconv.Itoa(route))
+		}
+	}
+	for i, method := range r.Methods {
+		r.Methods[i] = strings.ToUpper(method)
+		if _, ok := APITokenMethods[r.Methods[i]]; !ok {
+			errs = append(errs, "invalid method '"+method+"'")
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// APITokenCreated is an APIToken along with its secret value, which is only
+// ever returned when the token is created.
+type APITokenCreated struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+// APITokensResponse is the type of a response from Traffic Ops to a GET
+// request made to its /user/current/tokens API endpoint.
+type APITokensResponse struct {
+	Response []APIToken `json:"response"`
+	Alerts
+}
+
+// APITokenCreatedResponse is the type of a response from Traffic Ops to a
+// POST request made to its /user/current/tokens API endpoint.
+type APITokenCreatedResponse struct {
+	Response APITokenCreated `json:"response"`
+	Alerts
+}
+
+// APITokenResponse is the type of a response from Traffic Ops to a DELETE
+// request made to its /user/current/tokens/{{ID}} API endpoint.
+type APITokenResponse struct {
+	Response APIToken `json:"response"`
+	Alerts
+}
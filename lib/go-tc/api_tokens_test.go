@@ -0,0 +1,63 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-util"
+)
+
+func TestAPITokenRequestValidate(t *testing.T) {
+	valid := func() APITokenRequest {
+		expires := time.Now().Add(time.Hour)
+		return APITokenRequest{
+			Name:    util.StrPtr("ci"),
+			Routes:  []int{4064315324},
+			Methods: []string{"get", "POST"},
+			Expires: &expires,
+		}
+	}
+
+	req := valid()
+	if err := req.Validate(nil); err != nil {
+		t.Errorf("expected valid token request, got error: %v", err)
+	}
+	if req.Methods[0] != "GET" || req.Methods[1] != "POST" {
+		t.Errorf("expected methods to be normalized to upper case, actual: %v", req.Methods)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	tests := map[string]func(*APITokenRequest){
+		"missing name":    func(r *APITokenRequest) { r.Name = util.StrPtr(" ") },
+		"missing expires": func(r *APITokenRequest) { r.Expires = nil },
+		"past expires":    func(r *APITokenRequest) { r.Expires = &past },
+		"invalid route":   func(r *APITokenRequest) { r.Routes = append(r.Routes, 0) },
+		"invalid method":  func(r *APITokenRequest) { r.Methods = append(r.Methods, "TRACE") },
+	}
+	for name, modify := range tests {
+		req := valid()
+		modify(&req)
+		if err := req.Validate(nil); err == nil {
+			t.Errorf("%s: expected an error, got none", name)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// These are the possible values of the Status of an AsyncStatus.
+const (
+	// AsyncPending is the status of an asynchronous job which has not yet
+	// started - or, for jobs not run by the asynchronous job queue, which
+	// has not yet finished.
+	AsyncPending = "PENDING"
+	// AsyncRunning is the status of a queued asynchronous job which is
+	// currently being run.
+	AsyncRunning = "RUNNING"
+	// AsyncSucceeded is the status of an asynchronous job which finished
+	// successfully.
+	AsyncSucceeded = "SUCCEEDED"
+	// AsyncFailed is the status of an asynchronous job which finished
+	// unsuccessfully.
+	AsyncFailed = "FAILED"
+	// AsyncCancelled is the status of a queued asynchronous job which was
+	// cancelled before it finished.
+	AsyncCancelled = "CANCELLED"
+)
+
+// AsyncStatus is the status of an asynchronous job, as returned by the
+// async_status API endpoints.
+type AsyncStatus struct {
+	Id        int        `json:"id,omitempty" db:"id"`
+	Status    string     `json:"status,omitempty" db:"status"`
+	StartTime time.Time  `json:"start_time,omitempty" db:"start_time"`
+	EndTime   *time.Time `json:"end_time,omitempty" db:"end_time"`
+	Message   *string    `json:"message,omitempty" db:"message"`
+	// Type is the type of a queued job, e.g. "snapshot". It is empty for
+	// jobs that are not run by the asynchronous job queue.
+	Type string `json:"type,omitempty" db:"type"`
+	// UserName is the name of the user who started the job, if known.
+	UserName *string `json:"username,omitempty" db:"username"`
+	// Progress is the percentage of the job that has been completed.
+	Progress int `json:"progress" db:"progress"`
+	// CancelRequested is whether or not a user has asked for the job to be
+	// cancelled.
+	CancelRequested bool `json:"cancel_requested" db:"cancel_requested"`
+	// Result is the job-type-specific result of a successful job, if any.
+	Result json.RawMessage `json:"result,omitempty" db:"result"`
+	// Artifact is the API path from which a file produced by the job - e.g.
+	// an ISO image - may be downloaded, if the job produced one.
+	Artifact *string `json:"artifact,omitempty"`
+}
+
+// IsFinished returns whether or not the job has finished, successfully or
+// otherwise.
+func (s AsyncStatus) IsFinished() bool {
+	return s.Status == AsyncSucceeded || s.Status == AsyncFailed || s.Status == AsyncCancelled
+}
+
+// AsyncStatusResponse is the type of a response from Traffic Ops to a
+// request for the status of a single asynchronous job, or to a request which
+// started one.
+type AsyncStatusResponse struct {
+	Response AsyncStatus `json:"response"`
+	Alerts
+}
+
+// AsyncStatusesResponse is the type of a response from Traffic Ops to a
+// GET request made to its /async_status API endpoint.
+type AsyncStatusesResponse struct {
+	Response []AsyncStatus `json:"response"`
+	Alerts
+}
+
+// AsyncStatusLogEntry is a single message logged by a queued asynchronous job.
+type AsyncStatusLogEntry struct {
+	Time    time.Time `json:"time" db:"time"`
+	Level   string    `json:"level" db:"level"`
+	Message string    `json:"message" db:"message"`
+}
+
+// AsyncStatusLogsResponse is the type of a response from Traffic Ops to a
+// GET request made to its /async_status/{{ID}}/logs API endpoint.
+type AsyncStatusLogsResponse struct {
+	Response []AsyncStatusLogEntry `json:"response"`
+	Alerts
+}
@@ -0,0 +1,105 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"time"
+)
+
+// CDNCapacityForecastResponse is the type of a response from Traffic Ops to a
+// GET request made to its /cdns/{{name}}/capacity/forecast API endpoint.
+type CDNCapacityForecastResponse struct {
+	Response CDNCapacityForecast `json:"response"`
+	Alerts
+}
+
+// CDNCapacityForecast is a capacity planning report for a CDN, projecting
+// when each of its Cache Groups will cross the requested utilization
+// thresholds based on historical bandwidth usage.
+//
+// All bandwidth values are in kilobits per second.
+type CDNCapacityForecast struct {
+	CDN         string    `json:"cdn"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	// HistoryStart and HistoryEnd bound the period of Traffic Stats data from
+	// which the forecast was computed.
+	HistoryStart time.Time `json:"historyStart"`
+	HistoryEnd   time.Time `json:"historyEnd"`
+	// HorizonDays is the number of days into the future beyond which crossing
+	// dates are not projected.
+	HorizonDays int `json:"horizonDays"`
+	// Thresholds are the utilization percentages for which crossing dates
+	// were projected.
+	Thresholds  []float64                    `json:"thresholds"`
+	CacheGroups []CacheGroupCapacityForecast `json:"cacheGroups"`
+}
+
+// CacheGroupCapacityForecast is the capacity forecast of a single Cache Group.
+//
+// All bandwidth values are in kilobits per second.
+type CacheGroupCapacityForecast struct {
+	CacheGroup string `json:"cacheGroup"`
+	// Servers is the number of EDGE and MID tier servers in the Cache Group
+	// that count toward its capacity.
+	Servers int `json:"servers"`
+	// ServersMissingMaxBandwidth is the number of those Servers with no
+	// monitored interface that has a maximum bandwidth set, and which
+	// therefore add nothing to CapacityKbps.
+	ServersMissingMaxBandwidth int `json:"serversMissingMaxBandwidth"`
+	// CapacityKbps is the sum of the maximum bandwidths of the monitored
+	// interfaces of the Cache Group's servers.
+	CapacityKbps float64 `json:"capacityKbps"`
+	// CurrentKbps is the Cache Group's current bandwidth as reported by
+	// Traffic Monitor. It is nil if no Traffic Monitor could be reached.
+	CurrentKbps *float64 `json:"currentKbps"`
+	// Percentile95Kbps is the most recent daily 95th percentile bandwidth
+	// recorded by Traffic Stats. It is nil if there is no history.
+	Percentile95Kbps *float64 `json:"percentile95Kbps"`
+	// TrendKbpsPerDay is the slope of the linear trend fitted to the daily
+	// 95th percentile history. It is nil if there are fewer than two days of
+	// history.
+	TrendKbpsPerDay *float64 `json:"trendKbpsPerDay"`
+	// HeadroomKbps is CapacityKbps less Percentile95Kbps.
+	HeadroomKbps *float64 `json:"headroomKbps"`
+	// UtilizationPercent is Percentile95Kbps as a percentage of CapacityKbps.
+	UtilizationPercent *float64                    `json:"utilizationPercent"`
+	Thresholds         []CapacityThresholdForecast `json:"thresholds"`
+}
+
+// CapacityThresholdForecast is the projection of when a Cache Group will
+// cross a single utilization threshold.
+type CapacityThresholdForecast struct {
+	// Percent is the utilization threshold, as a percentage of capacity.
+	Percent float64 `json:"percent"`
+	// ThresholdKbps is the bandwidth at which the threshold is crossed.
+	ThresholdKbps float64 `json:"thresholdKbps"`
+	// HeadroomKbps is ThresholdKbps less the Cache Group's 95th percentile
+	// bandwidth. It is nil if there is no history.
+	HeadroomKbps *float64 `json:"headroomKbps"`
+	// Exceeded is whether the 95th percentile bandwidth has already crossed
+	// the threshold.
+	Exceeded bool `json:"exceeded"`
+	// ProjectedDate is when the trend is projected to cross the threshold.
+	// It is nil if the threshold has already been exceeded, if the trend is
+	// flat or falling, or if the crossing lies beyond the forecast horizon.
+	ProjectedDate *time.Time `json:"projectedDate"`
+	// DaysRemaining is the number of days until ProjectedDate.
+	DaysRemaining *int `json:"daysRemaining"`
+}
@@ -0,0 +1,366 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/apache/trafficcontrol/lib/go-util"
+)
+
+// CDNExportVersion is the version of the CDN export document format produced
+// by this version of Traffic Ops. Imports of documents with a different
+// major version are rejected.
+const CDNExportVersion = "1.0"
+
+// CDNExport is a declarative document describing the entire configuration of
+// a CDN, as produced by /cdns/{{name}}/export and consumed by /cdns/import.
+//
+// All references between objects are by name rather than ID, so that a
+// document can be moved between Traffic Ops instances. Types, Statuses, and
+// Tenants are referenced by name but are not part of the document, and must
+// already exist wherever it is imported.
+type CDNExport struct {
+	Version            string                     `json:"version"`
+	CDN                CDNExportCDN               `json:"cdn"`
+	Divisions          []CDNExportDivision        `json:"divisions"`
+	Regions            []CDNExportRegion          `json:"regions"`
+	PhysLocations      []CDNExportPhysLocation    `json:"physLocations"`
+	CacheGroups        []CDNExportCacheGroup      `json:"cacheGroups"`
+	Profiles           []CDNExportProfile         `json:"profiles"`
+	ServerCapabilities []string                   `json:"serverCapabilities"`
+	Servers            []CDNExportServer          `json:"servers"`
+	Topologies         []CDNExportTopology        `json:"topologies"`
+	DeliveryServices   []CDNExportDeliveryService `json:"deliveryServices"`
+}
+
+// CDNExportCDN is the CDN itself, in a CDNExport.
+type CDNExportCDN struct {
+	Name          string `json:"name"`
+	DomainName    string `json:"domainName"`
+	DNSSECEnabled bool   `json:"dnssecEnabled"`
+}
+
+// CDNExportDivision is a Division in a CDNExport.
+type CDNExportDivision struct {
+	Name string `json:"name"`
+}
+
+// CDNExportRegion is a Region in a CDNExport.
+type CDNExportRegion struct {
+	Name     string `json:"name"`
+	Division string `json:"division"`
+}
+
+// CDNExportPhysLocation is a Physical Location in a CDNExport.
+type CDNExportPhysLocation struct {
+	Name      string  `json:"name"`
+	ShortName string  `json:"shortName"`
+	Address   string  `json:"address"`
+	City      string  `json:"city"`
+	State     string  `json:"state"`
+	Zip       string  `json:"zip"`
+	POC       *string `json:"poc"`
+	Phone     *string `json:"phone"`
+	Email     *string `json:"email"`
+	Comments  *string `json:"comments"`
+	Region    string  `json:"region"`
+}
+
+// CDNExportCacheGroup is a Cache Group in a CDNExport. Parents and fallbacks
+// are referenced by Cache Group name.
+type CDNExportCacheGroup struct {
+	Name                      string   `json:"name"`
+	ShortName                 string   `json:"shortName"`
+	Type                      string   `json:"type"`
+	Latitude                  *float64 `json:"latitude"`
+	Longitude                 *float64 `json:"longitude"`
+	ParentCacheGroup          *string  `json:"parentCacheGroup"`
+	SecondaryParentCacheGroup *string  `json:"secondaryParentCacheGroup"`
+	FallbackToClosest         bool     `json:"fallbackToClosest"`
+	Fallbacks                 []string `json:"fallbacks"`
+	LocalizationMethods       []string `json:"localizationMethods"`
+}
+
+// CDNExportProfile is a Profile in a CDNExport, with all of its Parameters.
+type CDNExportProfile struct {
+	Name            string               `json:"name"`
+	Description     string               `json:"description"`
+	Type            string               `json:"type"`
+	RoutingDisabled bool                 `json:"routingDisabled"`
+	Parameters      []CDNExportParameter `json:"parameters"`
+}
+
+// CDNExportParameter is a Parameter of a Profile in a CDNExport.
+type CDNExportParameter struct {
+	Name       string `json:"name"`
+	ConfigFile string `json:"configFile"`
+	Value      string `json:"value"`
+	Secure     bool   `json:"secure"`
+}
+
+// CDNExportServer is a server in a CDNExport. Servers are identified by their
+// host and domain names together. Passwords are never exported.
+type CDNExportServer struct {
+	HostName      string                   `json:"hostName"`
+	DomainName    string                   `json:"domainName"`
+	CacheGroup    string                   `json:"cacheGroup"`
+	Profile       string                   `json:"profile"`
+	Type          string                   `json:"type"`
+	PhysLocation  string                   `json:"physLocation"`
+	Status        string                   `json:"status"`
+	OfflineReason *string                  `json:"offlineReason"`
+	Rack          *string                  `json:"rack"`
+	TCPPort       *int                     `json:"tcpPort"`
+	HTTPSPort     *int                     `json:"httpsPort"`
+	ILOIPAddress  *string                  `json:"iloIpAddress"`
+	ILOIPGateway  *string                  `json:"iloIpGateway"`
+	ILOIPNetmask  *string                  `json:"iloIpNetmask"`
+	ILOUsername   *string                  `json:"iloUsername"`
+	MgmtIPAddress *string                  `json:"mgmtIpAddress"`
+	MgmtIPGateway *string                  `json:"mgmtIpGateway"`
+	MgmtIPNetmask *string                  `json:"mgmtIpNetmask"`
+	Interfaces    []ServerInterfaceInfoV40 `json:"interfaces"`
+	Capabilities  []string                 `json:"capabilities"`
+}
+
+// FQDN returns the fully qualified domain name of the server, which is how
+// servers are identified in a CDNExport.
+func (s CDNExportServer) FQDN() string {
+	return s.HostName + "." + s.DomainName
+}
+
+// CDNExportTopology is a Topology in a CDNExport.
+type CDNExportTopology struct {
+	Name        string                  `json:"name"`
+	Description string                  `json:"description"`
+	Nodes       []CDNExportTopologyNode `json:"nodes"`
+}
+
+// CDNExportTopologyNode is a node of a Topology in a CDNExport. Unlike the
+// nodes of a Topology, parents are referenced by Cache Group name rather
+// than by index.
+type CDNExportTopologyNode struct {
+	CacheGroup string   `json:"cacheGroup"`
+	Parents    []string `json:"parents"`
+}
+
+// CDNExportDeliveryService is a Delivery Service in a CDNExport.
+//
+// The Delivery Service's own fields are those of a DeliveryServiceV4, except
+// that IDs and other fields generated by Traffic Ops are omitted, and the CDN
+// is implied by the document. Its Profile, Tenant, and Type are identified by
+// the profileName, tenant, and type fields. Its Profile need not be in the
+// document, since Delivery Services may use Profiles of other CDNs.
+type CDNExportDeliveryService struct {
+	DeliveryServiceV4
+	Regexes              []CDNExportRegex          `json:"regexes"`
+	RequiredCapabilities []string                  `json:"requiredCapabilities"`
+	SteeringTargets      []CDNExportSteeringTarget `json:"steeringTargets"`
+}
+
+// CDNExportRegex is a regular expression of a Delivery Service in a
+// CDNExport.
+type CDNExportRegex struct {
+	Type      string `json:"type"`
+	Pattern   string `json:"pattern"`
+	SetNumber int    `json:"setNumber"`
+}
+
+// CDNExportSteeringTarget is a target of a Steering Delivery Service in a
+// CDNExport. The target Delivery Service must be in the same document.
+type CDNExportSteeringTarget struct {
+	Target string `json:"target"`
+	Type   string `json:"type"`
+	Value  int    `json:"value"`
+}
+
+// CDNImportChange is a single object that an import of a CDNExport creates,
+// updates, or deletes.
+type CDNImportChange struct {
+	// Type is the kind of object, e.g. "server" or "deliveryService".
+	Type string `json:"type"`
+	// Name is the name which identifies the object in the document.
+	Name string `json:"name"`
+}
+
+// CDNImportPlan is the set of changes an import of a CDNExport makes, or
+// would make, to Traffic Ops.
+type CDNImportPlan struct {
+	DryRun  bool              `json:"dryRun"`
+	Creates []CDNImportChange `json:"creates"`
+	Updates []CDNImportChange `json:"updates"`
+	Deletes []CDNImportChange `json:"deletes"`
+}
+
+// CDNImportResponse is the type of a response from Traffic Ops to a POST
+// request made to its /cdns/import API endpoint.
+type CDNImportResponse struct {
+	Response CDNImportPlan `json:"response"`
+	Alerts
+}
+
+// Validate checks that the document is of a supported version, that every
+// object in it has a name that is unique among objects of its kind, and that
+// the references between its Divisions, Regions, Physical Locations, Cache
+// Groups, Profiles, Server Capabilities, Topologies, and Delivery Services
+// can be resolved within the document itself.
+func (e *CDNExport) Validate(*sql.Tx) error {
+	if e.Version == "" {
+		return errors.New("version: required")
+	}
+	if major := strings.SplitN(e.Version, ".", 2)[0]; major != strings.SplitN(CDNExportVersion, ".", 2)[0] {
+		return fmt.Errorf("version: unsupported document version '%s', this Traffic Ops supports version %s", e.Version, CDNExportVersion)
+	}
+
+	errs := []error{}
+	if e.CDN.Name == "" {
+		errs = append(errs, errors.New("cdn.name: required"))
+	}
+	if e.CDN.DomainName == "" {
+		errs = append(errs, errors.New("cdn.domainName: required"))
+	}
+
+	names := func(kind string, ns []string) map[string]struct{} {
+		set := make(map[string]struct{}, len(ns))
+		for _, n := range ns {
+			if n == "" {
+				errs = append(errs, fmt.Errorf("%s: name cannot be blank", kind))
+				continue
+			}
+			if _, ok := set[n]; ok {
+				errs = append(errs, fmt.Errorf("%s: duplicate name '%s'", kind, n))
+			}
+			set[n] = struct{}{}
+		}
+		return set
+	}
+	ref := func(kind, name, field, target string, set map[string]struct{}) {
+		if _, ok := set[target]; !ok {
+			errs = append(errs, fmt.Errorf("%s '%s': %s '%s' is not in the document", kind, name, field, target))
+		}
+	}
+
+	divisions := make([]string, 0, len(e.Divisions))
+	for _, d := range e.Divisions {
+		divisions = append(divisions, d.Name)
+	}
+	divisionSet := names("divisions", divisions)
+
+	regions := make([]string, 0, len(e.Regions))
+	for _, r := range e.Regions {
+		regions = append(regions, r.Name)
+		ref("region", r.Name, "division", r.Division, divisionSet)
+	}
+	regionSet := names("regions", regions)
+
+	physLocations := make([]string, 0, len(e.PhysLocations))
+	for _, p := range e.PhysLocations {
+		physLocations = append(physLocations, p.Name)
+		ref("physLocation", p.Name, "region", p.Region, regionSet)
+	}
+	physLocationSet := names("physLocations", physLocations)
+
+	cacheGroups := make([]string, 0, len(e.CacheGroups))
+	for _, cg := range e.CacheGroups {
+		cacheGroups = append(cacheGroups, cg.Name)
+	}
+	cacheGroupSet := names("cacheGroups", cacheGroups)
+	for _, cg := range e.CacheGroups {
+		if cg.ParentCacheGroup != nil {
+			ref("cacheGroup", cg.Name, "parentCacheGroup", *cg.ParentCacheGroup, cacheGroupSet)
+		}
+		if cg.SecondaryParentCacheGroup != nil {
+			ref("cacheGroup", cg.Name, "secondaryParentCacheGroup", *cg.SecondaryParentCacheGroup, cacheGroupSet)
+		}
+		for _, fallback := range cg.Fallbacks {
+			ref("cacheGroup", cg.Name, "fallback", fallback, cacheGroupSet)
+		}
+		for _, method := range cg.LocalizationMethods {
+			if LocalizationMethodFromString(method) == LocalizationMethodInvalid {
+				errs = append(errs, fmt.Errorf("cacheGroup '%s': invalid localization method '%s'", cg.Name, method))
+			}
+		}
+	}
+
+	profiles := make([]string, 0, len(e.Profiles))
+	for _, p := range e.Profiles {
+		profiles = append(profiles, p.Name)
+	}
+	profileSet := names("profiles", profiles)
+
+	capabilitySet := names("serverCapabilities", e.ServerCapabilities)
+
+	servers := make([]string, 0, len(e.Servers))
+	for _, s := range e.Servers {
+		servers = append(servers, s.FQDN())
+		ref("server", s.FQDN(), "cacheGroup", s.CacheGroup, cacheGroupSet)
+		ref("server", s.FQDN(), "profile", s.Profile, profileSet)
+		ref("server", s.FQDN(), "physLocation", s.PhysLocation, physLocationSet)
+		for _, c := range s.Capabilities {
+			ref("server", s.FQDN(), "capability", c, capabilitySet)
+		}
+		if s.HostName == "" || s.DomainName == "" {
+			errs = append(errs, fmt.Errorf("server '%s': hostName and domainName are required", s.FQDN()))
+		}
+	}
+	names("servers", servers)
+
+	topologies := make([]string, 0, len(e.Topologies))
+	for _, t := range e.Topologies {
+		topologies = append(topologies, t.Name)
+		for _, node := range t.Nodes {
+			ref("topology", t.Name, "node cacheGroup", node.CacheGroup, cacheGroupSet)
+			for _, parent := range node.Parents {
+				ref("topology", t.Name, "node parent", parent, cacheGroupSet)
+			}
+		}
+	}
+	topologySet := names("topologies", topologies)
+
+	dses := make([]string, 0, len(e.DeliveryServices))
+	for _, ds := range e.DeliveryServices {
+		dses = append(dses, coerceString(ds.XMLID))
+	}
+	dsSet := names("deliveryServices", dses)
+	for _, ds := range e.DeliveryServices {
+		xmlID := coerceString(ds.XMLID)
+		if ds.Type == nil || *ds.Type == "" {
+			errs = append(errs, fmt.Errorf("deliveryService '%s': type is required", xmlID))
+		}
+		if ds.Tenant == nil || *ds.Tenant == "" {
+			errs = append(errs, fmt.Errorf("deliveryService '%s': tenant is required", xmlID))
+		}
+		if ds.Topology != nil {
+			ref("deliveryService", xmlID, "topology", *ds.Topology, topologySet)
+		}
+		for _, c := range ds.RequiredCapabilities {
+			ref("deliveryService", xmlID, "requiredCapability", c, capabilitySet)
+		}
+		for _, st := range ds.SteeringTargets {
+			ref("deliveryService", xmlID, "steering target", st.Target, dsSet)
+		}
+	}
+
+	return util.JoinErrs(errs)
+}
@@ -0,0 +1,143 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"strings"
+	"testing"
+)
+
+func validCDNExport() CDNExport {
+	dsType := DSTypeHTTP
+	tenant := "root"
+	xmlID := "ds1"
+	topology := "top"
+	parent := "mid"
+	return CDNExport{
+		Version:       CDNExportVersion,
+		CDN:           CDNExportCDN{Name: "cdn", DomainName: "cdn.test"},
+		Divisions:     []CDNExportDivision{{Name: "div"}},
+		Regions:       []CDNExportRegion{{Name: "reg", Division: "div"}},
+		PhysLocations: []CDNExportPhysLocation{{Name: "pl", Region: "reg"}},
+		CacheGroups: []CDNExportCacheGroup{
+			{Name: "mid", Type: "MID_LOC"},
+			{Name: "edge", Type: "EDGE_LOC", ParentCacheGroup: &parent, LocalizationMethods: []string{"CZ"}},
+		},
+		Profiles:           []CDNExportProfile{{Name: "EDGE", Type: "ATS_PROFILE"}},
+		ServerCapabilities: []string{"ram"},
+		Servers: []CDNExportServer{
+			{HostName: "edge1", DomainName: "test", CacheGroup: "edge", Profile: "EDGE", PhysLocation: "pl", Capabilities: []string{"ram"}},
+		},
+		Topologies: []CDNExportTopology{{Name: "top", Nodes: []CDNExportTopologyNode{{CacheGroup: "edge", Parents: []string{"mid"}}, {CacheGroup: "mid"}}}},
+		DeliveryServices: []CDNExportDeliveryService{{
+			DeliveryServiceV4: DeliveryServiceV4{
+				DeliveryServiceFieldsV30:         DeliveryServiceFieldsV30{Topology: &topology},
+				DeliveryServiceFieldsV13:         DeliveryServiceFieldsV13{Tenant: &tenant},
+				DeliveryServiceNullableFieldsV11: DeliveryServiceNullableFieldsV11{XMLID: &xmlID, Type: &dsType},
+			},
+			RequiredCapabilities: []string{"ram"},
+		}},
+	}
+}
+
+func TestCDNExportValidate(t *testing.T) {
+	doc := validCDNExport()
+	if err := doc.Validate(nil); err != nil {
+		t.Fatalf("expected a valid document, got error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*CDNExport)
+		errs   []string
+	}{
+		{
+			name:   "missing version",
+			modify: func(e *CDNExport) { e.Version = "" },
+			errs:   []string{"version: required"},
+		},
+		{
+			name:   "unsupported major version",
+			modify: func(e *CDNExport) { e.Version = "2.0" },
+			errs:   []string{"unsupported document version '2.0'"},
+		},
+		{
+			name:   "newer minor version",
+			modify: func(e *CDNExport) { e.Version = "1.7" },
+		},
+		{
+			name:   "dangling region division",
+			modify: func(e *CDNExport) { e.Regions[0].Division = "nope" },
+			errs:   []string{"region 'reg': division 'nope' is not in the document"},
+		},
+		{
+			name:   "duplicate cache group",
+			modify: func(e *CDNExport) { e.CacheGroups = append(e.CacheGroups, e.CacheGroups[0]) },
+			errs:   []string{"cacheGroups: duplicate name 'mid'"},
+		},
+		{
+			name:   "invalid localization method",
+			modify: func(e *CDNExport) { e.CacheGroups[1].LocalizationMethods = []string{"bogus"} },
+			errs:   []string{"invalid localization method 'bogus'"},
+		},
+		{
+			name: "server references",
+			modify: func(e *CDNExport) {
+				e.Servers[0].Profile = "MID"
+				e.Servers[0].Capabilities = []string{"disk"}
+			},
+			errs: []string{"server 'edge1.test': profile 'MID'", "server 'edge1.test': capability 'disk'"},
+		},
+		{
+			name:   "topology parent",
+			modify: func(e *CDNExport) { e.Topologies[0].Nodes[0].Parents = []string{"org"} },
+			errs:   []string{"topology 'top': node parent 'org' is not in the document"},
+		},
+		{
+			name: "delivery service references",
+			modify: func(e *CDNExport) {
+				e.DeliveryServices[0].Tenant = nil
+				e.DeliveryServices[0].SteeringTargets = []CDNExportSteeringTarget{{Target: "ds2"}}
+			},
+			errs: []string{"deliveryService 'ds1': tenant is required", "steering target 'ds2' is not in the document"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc := validCDNExport()
+			test.modify(&doc)
+			err := doc.Validate(nil)
+			if len(test.errs) == 0 {
+				if err != nil {
+					t.Errorf("expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected errors containing %v, got none", test.errs)
+			}
+			for _, expected := range test.errs {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected error to contain '%s', got: %v", expected, err)
+				}
+			}
+		})
+	}
+}
@@ -20,16 +20,109 @@ package tc
  */
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
+// CDNLockScope is the kind of object within a CDN to which a lock applies.
+type CDNLockScope string
+
+// These are the valid values for a CDNLockScope.
+const (
+	// CDNLockScopeCDN is the scope of a lock that applies to an entire CDN.
+	CDNLockScopeCDN = CDNLockScope("")
+	// CDNLockScopeDeliveryService is the scope of a lock that applies to a
+	// single Delivery Service, identified by its XMLID.
+	CDNLockScopeDeliveryService = CDNLockScope("deliveryservice")
+	// CDNLockScopeTopology is the scope of a lock that applies to a single
+	// Topology, identified by its name.
+	CDNLockScopeTopology = CDNLockScope("topology")
+	// CDNLockScopeCacheGroup is the scope of a lock that applies to a single
+	// Cache Group, identified by its name.
+	CDNLockScopeCacheGroup = CDNLockScope("cachegroup")
+	// CDNLockScopeProfile is the scope of a lock that applies to a single
+	// Profile, identified by its name.
+	CDNLockScopeProfile = CDNLockScope("profile")
+)
+
+// IsValid returns whether or not the CDNLockScope is one of the known scopes.
+func (s CDNLockScope) IsValid() bool {
+	switch s {
+	case CDNLockScopeCDN, CDNLockScopeDeliveryService, CDNLockScopeTopology, CDNLockScopeCacheGroup, CDNLockScopeProfile:
+		return true
+	}
+	return false
+}
+
+// Description returns a human-readable description of the object(s) locked
+// by a lock with this scope on the named object in the given CDN.
+func (s CDNLockScope) Description(name, cdn string) string {
+	switch s {
+	case CDNLockScopeCDN:
+		return "cdn " + cdn
+	case CDNLockScopeDeliveryService:
+		return fmt.Sprintf("delivery service %s in cdn %s", name, cdn)
+	}
+	return fmt.Sprintf("%s %s in cdn %s", s, name, cdn)
+}
+
 // CDNLock is a struct to store the details of a lock that a user wishes to acquire on a CDN.
+//
+// A lock with an empty Scope applies to the entire CDN; otherwise it applies
+// only to the object of that Scope with the given Name. A lock with an
+// Expires time is ignored - and eventually removed - once that time has
+// passed.
 type CDNLock struct {
-	UserName    string    `json:"userName" db:"username"`
-	CDN         string    `json:"cdn" db:"cdn"`
-	Message     *string   `json:"message" db:"message"`
-	Soft        *bool     `json:"soft" db:"soft"`
-	LastUpdated time.Time `json:"lastUpdated" db:"last_updated"`
+	UserName    string       `json:"userName" db:"username"`
+	CDN         string       `json:"cdn" db:"cdn"`
+	Scope       CDNLockScope `json:"scope,omitempty" db:"scope"`
+	Name        string       `json:"name,omitempty" db:"name"`
+	Message     *string      `json:"message" db:"message"`
+	Soft        *bool        `json:"soft" db:"soft"`
+	Expires     *time.Time   `json:"expires,omitempty" db:"expires"`
+	LastUpdated time.Time    `json:"lastUpdated" db:"last_updated"`
+}
+
+// Validate validates the fields of a CDNLock that may be set by a user who
+// wishes to acquire it.
+func (l CDNLock) Validate() error {
+	errs := []string{}
+	if l.CDN == "" {
+		errs = append(errs, "field 'cdn' must be present")
+	}
+	if l.Soft == nil {
+		errs = append(errs, "field 'soft' must be present")
+	}
+	if !l.Scope.IsValid() {
+		errs = append(errs, fmt.Sprintf("'scope' must be one of '%s', '%s', '%s' or '%s', or omitted to lock the entire CDN", CDNLockScopeDeliveryService, CDNLockScopeTopology, CDNLockScopeCacheGroup, CDNLockScopeProfile))
+	} else if l.Scope == CDNLockScopeCDN && l.Name != "" {
+		errs = append(errs, "'name' cannot be given without a 'scope'")
+	} else if l.Scope != CDNLockScopeCDN && l.Name == "" {
+		errs = append(errs, "'name' is required when 'scope' is given")
+	}
+	if l.Expires != nil && !l.Expires.After(time.Now()) {
+		errs = append(errs, "'expires' must be in the future")
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ConflictMessage returns a message naming the holder of the lock, what is
+// locked and why, suitable for rejecting a conflicting change made by another
+// user.
+func (l CDNLock) ConflictMessage() string {
+	msg := fmt.Sprintf("user %s currently has a lock on %s", l.UserName, l.Scope.Description(l.Name, l.CDN))
+	if l.Expires != nil {
+		msg += " until " + l.Expires.Format(time.RFC3339)
+	}
+	if l.Message != nil && *l.Message != "" {
+		msg += ": " + *l.Message
+	}
+	return msg
 }
 
 // CDNLockCreateResponse is a struct to store the response of a CREATE operation on a lock.
@@ -0,0 +1,106 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-util"
+)
+
+func TestCDNLockValidate(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	testCases := []struct {
+		description string
+		lock        CDNLock
+		valid       bool
+	}{
+		{
+			description: "CDN-wide lock",
+			lock:        CDNLock{CDN: "cdn1", Soft: util.BoolPtr(true)},
+			valid:       true,
+		},
+		{
+			description: "delivery service lock with expiry",
+			lock:        CDNLock{CDN: "cdn1", Soft: util.BoolPtr(false), Scope: CDNLockScopeDeliveryService, Name: "ds1", Expires: &future},
+			valid:       true,
+		},
+		{
+			description: "missing cdn",
+			lock:        CDNLock{Soft: util.BoolPtr(true)},
+		},
+		{
+			description: "missing soft",
+			lock:        CDNLock{CDN: "cdn1"},
+		},
+		{
+			description: "unknown scope",
+			lock:        CDNLock{CDN: "cdn1", Soft: util.BoolPtr(true), Scope: "server", Name: "edge"},
+		},
+		{
+			description: "scope without a name",
+			lock:        CDNLock{CDN: "cdn1", Soft: util.BoolPtr(true), Scope: CDNLockScopeTopology},
+		},
+		{
+			description: "name without a scope",
+			lock:        CDNLock{CDN: "cdn1", Soft: util.BoolPtr(true), Name: "mso-topology"},
+		},
+		{
+			description: "expiry in the past",
+			lock:        CDNLock{CDN: "cdn1", Soft: util.BoolPtr(true), Expires: &past},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.description, func(t *testing.T) {
+			err := testCase.lock.Validate()
+			if testCase.valid && err != nil {
+				t.Errorf("expected no error, got: %v", err)
+			} else if !testCase.valid && err == nil {
+				t.Error("expected an error, got none")
+			}
+		})
+	}
+}
+
+func TestCDNLockConflictMessage(t *testing.T) {
+	expires := time.Date(2021, 6, 5, 12, 0, 0, 0, time.UTC)
+	lock := CDNLock{
+		UserName: "alice",
+		CDN:      "cdn1",
+		Scope:    CDNLockScopeDeliveryService,
+		Name:     "ds1",
+		Message:  util.StrPtr("migrating origins"),
+		Expires:  &expires,
+	}
+	msg := lock.ConflictMessage()
+	for _, expected := range []string{"alice", "delivery service ds1", "cdn1", "2021-06-05T12:00:00Z", "migrating origins"} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("expected conflict message '%s' to contain '%s'", msg, expected)
+		}
+	}
+
+	lock = CDNLock{UserName: "bob", CDN: "cdn2"}
+	if msg := lock.ConflictMessage(); msg != "user bob currently has a lock on cdn cdn2" {
+		t.Errorf("unexpected conflict message for a CDN-wide lock: %s", msg)
+	}
+}
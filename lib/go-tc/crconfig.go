@@ -19,6 +19,10 @@ package tc
  * under the License.
  */
 
+import (
+	"time"
+)
+
 // CRConfig is JSON-serializable as the CRConfig used by Traffic Control.
 type CRConfig struct {
 	// Config is mostly a map of string values, but may contain an 'soa' key which is a map[string]string, and may contain a 'ttls' key with a value map[string]string. It might not contain these values, so they must be checked for, and all values must be checked by the user and an error returned if the type is unexpected. Be aware, neither the language nor the API provides any guarantees about the type!
@@ -223,3 +227,38 @@ type PutSnapshotResponse struct {
 	Response *string `json:"response,omitempty"`
 	Alerts
 }
+
+// SnapshotHistoryEntry is a Snapshot which was current for a CDN at some
+// time, and which may be restored.
+type SnapshotHistoryEntry struct {
+	ID  int    `json:"id" db:"id"`
+	CDN string `json:"cdn" db:"cdn"`
+	// User is the username of the user who took the Snapshot, which is nil
+	// for Snapshots taken before Snapshot history was kept.
+	User    *string   `json:"user" db:"username"`
+	Comment *string   `json:"comment" db:"comment"`
+	Created time.Time `json:"created" db:"created"`
+	// Current is whether this is the CDN's current Snapshot.
+	Current bool `json:"current"`
+}
+
+// SnapshotHistoryResponse is the type of a response from Traffic Ops to a GET
+// request made to its /cdns/{{Name}}/snapshots API endpoint.
+type SnapshotHistoryResponse struct {
+	Response []SnapshotHistoryEntry `json:"response"`
+	Alerts
+}
+
+// SnapshotRestoreRequest is the optional body of a POST request made to
+// Traffic Ops's /cdns/{{Name}}/snapshots/{{ID}}/restore API endpoint.
+type SnapshotRestoreRequest struct {
+	Comment *string `json:"comment"`
+}
+
+// SnapshotRestoreResponse is the type of a response from Traffic Ops to a
+// POST request made to its /cdns/{{Name}}/snapshots/{{ID}}/restore API
+// endpoint. The Response is the new history entry recording the restore.
+type SnapshotRestoreResponse struct {
+	Response SnapshotHistoryEntry `json:"response"`
+	Alerts
+}
@@ -0,0 +1,77 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// CRConfigDiff is the semantic difference between two CRConfigs, typically
+// a CDN's current Snapshot and the CRConfig that would be produced by taking
+// a new one.
+//
+// Stats are not compared, since they always differ between Snapshots.
+type CRConfigDiff struct {
+	Config           CRConfigSectionDiff `json:"config"`
+	ContentRouters   CRConfigSectionDiff `json:"contentRouters"`
+	ContentServers   CRConfigSectionDiff `json:"contentServers"`
+	DeliveryServices CRConfigSectionDiff `json:"deliveryServices"`
+	EdgeLocations    CRConfigSectionDiff `json:"edgeLocations"`
+	Monitors         CRConfigSectionDiff `json:"monitors"`
+	RouterLocations  CRConfigSectionDiff `json:"trafficRouterLocations"`
+	Topologies       CRConfigSectionDiff `json:"topologies"`
+}
+
+// CRConfigSectionDiff is the difference between one section of two
+// CRConfigs, e.g. their Delivery Services. Entries are identified by their
+// key in the section, e.g. a Delivery Service's XMLID.
+type CRConfigSectionDiff struct {
+	Added   []string         `json:"added"`
+	Removed []string         `json:"removed"`
+	Changed []CRConfigChange `json:"changed"`
+}
+
+// Empty returns whether there are no differences in the section.
+func (d CRConfigSectionDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// CRConfigChange is the set of fields that changed in a single CRConfig
+// entry that exists in both CRConfigs.
+type CRConfigChange struct {
+	Name   string                `json:"name"`
+	Fields []CRConfigFieldChange `json:"fields"`
+}
+
+// CRConfigFieldChange is a single changed field of a CRConfig entry.
+type CRConfigFieldChange struct {
+	// Field is the path of the field within the entry, with the names of
+	// nested objects' fields separated by '.', e.g. "soa.minimum". It is
+	// empty if the entry itself is a single value, as are most Config
+	// entries.
+	Field string `json:"field"`
+	// Old is the field's old value, or null if it didn't exist.
+	Old interface{} `json:"old"`
+	// New is the field's new value, or null if it was removed.
+	New interface{} `json:"new"`
+}
+
+// CRConfigDiffResponse is the type of a response from Traffic Ops to a GET
+// request made to its /cdns/{{Name}}/snapshot/diff API endpoint.
+type CRConfigDiffResponse struct {
+	Response CRConfigDiff `json:"response"`
+	Alerts
+}
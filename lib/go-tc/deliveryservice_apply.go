@@ -0,0 +1,220 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/apache/trafficcontrol/lib/go-util"
+)
+
+// These are the actions which an apply of Delivery Services may take on each
+// Delivery Service.
+const (
+	DeliveryServiceApplyCreate = "create"
+	DeliveryServiceApplyUpdate = "update"
+	DeliveryServiceApplyDelete = "delete"
+)
+
+// DeliveryServiceApplyRequest is the desired set of Delivery Services of a
+// CDN, a Tenant, or a Tenant within a CDN - the "scope" of the request - as
+// sent to /deliveryservices/apply.
+//
+// Delivery Services in the scope which are not in the request are deleted.
+// Delivery Services refer to their CDN, Type, Tenant, and Profile by name;
+// the corresponding ID fields are ignored. A Delivery Service which omits its
+// CDN or Tenant is in the CDN or Tenant of the scope.
+type DeliveryServiceApplyRequest struct {
+	CDN              *string                    `json:"cdn"`
+	Tenant           *string                    `json:"tenant"`
+	DeliveryServices []DeliveryServiceApplyItem `json:"deliveryServices"`
+}
+
+// DeliveryServiceApplyItem is a single desired Delivery Service in a
+// DeliveryServiceApplyRequest.
+//
+// Fields which are omitted or null are not managed by the request, and keep
+// their current value when an existing Delivery Service is updated. Likewise,
+// if Regexes or RequiredCapabilities are null they are left as they are, while
+// an empty array removes them all.
+type DeliveryServiceApplyItem struct {
+	DeliveryServiceV4
+	Regexes              []DeliveryServiceRegex `json:"regexes"`
+	RequiredCapabilities []string               `json:"requiredCapabilities"`
+
+	// managed is the set of JSON fields given a non-null value when the
+	// item was decoded, or nil if it wasn't decoded from JSON.
+	managed map[string]struct{}
+}
+
+// UnmarshalJSON implements the encoding/json.Unmarshaler interface, recording
+// which fields were given, so that fields which aren't nullable - like
+// ecsEnabled - are only managed when they're given explicitly.
+func (item *DeliveryServiceApplyItem) UnmarshalJSON(data []byte) error {
+	type plainItem DeliveryServiceApplyItem
+	plain := plainItem{}
+	if err := json.Unmarshal(data, &plain); err != nil {
+		return err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*item = DeliveryServiceApplyItem(plain)
+	item.managed = make(map[string]struct{}, len(fields))
+	for field, value := range fields {
+		if string(value) != "null" {
+			item.managed[field] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// Manages returns whether the given JSON field of the Delivery Service is
+// managed by the request. Every field of an item which wasn't decoded from
+// JSON is managed.
+func (item DeliveryServiceApplyItem) Manages(field string) bool {
+	if item.managed == nil {
+		return true
+	}
+	_, ok := item.managed[field]
+	return ok
+}
+
+// setManaged marks the given JSON field as managed.
+func (item *DeliveryServiceApplyItem) setManaged(field string) {
+	if item.managed != nil {
+		item.managed[field] = struct{}{}
+	}
+}
+
+// DeliveryServiceFieldChange is a change to a single field of a Delivery
+// Service made by an apply. Old is null for created Delivery Services.
+type DeliveryServiceFieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// DeliveryServiceApplyChange is a change to a single Delivery Service made by
+// an apply. Fields is empty for deleted Delivery Services.
+type DeliveryServiceApplyChange struct {
+	XMLID  string                       `json:"xmlId"`
+	Action string                       `json:"action"`
+	Fields []DeliveryServiceFieldChange `json:"fields"`
+}
+
+// DeliveryServiceApplyPlan is the set of changes which an apply of Delivery
+// Services made or, if DryRun is true, would make. Delivery Services which
+// are unchanged are not included.
+type DeliveryServiceApplyPlan struct {
+	DryRun  bool                         `json:"dryRun"`
+	Changes []DeliveryServiceApplyChange `json:"changes"`
+}
+
+// Counts returns the number of Delivery Services created, updated, and
+// deleted by the plan.
+func (p DeliveryServiceApplyPlan) Counts() (int, int, int) {
+	creates, updates, deletes := 0, 0, 0
+	for _, c := range p.Changes {
+		switch c.Action {
+		case DeliveryServiceApplyCreate:
+			creates++
+		case DeliveryServiceApplyUpdate:
+			updates++
+		case DeliveryServiceApplyDelete:
+			deletes++
+		}
+	}
+	return creates, updates, deletes
+}
+
+// DeliveryServiceApplyResponse is the type of a response from Traffic Ops to a
+// POST request made to its /deliveryservices/apply API endpoint.
+type DeliveryServiceApplyResponse struct {
+	Response DeliveryServiceApplyPlan `json:"response"`
+	Alerts
+}
+
+// Validate implements the
+// github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api.ParseValidator
+// interface. It only checks the request itself; the Delivery Services are
+// validated as they are created or updated.
+//
+// Validate also defaults the CDN and Tenant of each Delivery Service to those
+// of the scope.
+func (req *DeliveryServiceApplyRequest) Validate(*sql.Tx) error {
+	if req.CDN == nil && req.Tenant == nil {
+		return errors.New("at least one of cdn and tenant is required")
+	}
+	if req.DeliveryServices == nil {
+		return errors.New("deliveryServices: required; use an empty array to delete every Delivery Service in the scope")
+	}
+
+	errs := []error{}
+	seen := make(map[string]struct{}, len(req.DeliveryServices))
+	for i := range req.DeliveryServices {
+		ds := &req.DeliveryServices[i]
+		if ds.XMLID == nil || *ds.XMLID == "" {
+			errs = append(errs, fmt.Errorf("deliveryServices[%d]: xmlId is required", i))
+			continue
+		}
+		xmlID := *ds.XMLID
+		if _, ok := seen[xmlID]; ok {
+			errs = append(errs, fmt.Errorf("deliveryServices: duplicate xmlId '%s'", xmlID))
+		}
+		seen[xmlID] = struct{}{}
+
+		if ds.CDNName == nil && req.CDN != nil {
+			ds.CDNName = req.CDN
+			ds.setManaged("cdnName")
+		} else if req.CDN != nil && *ds.CDNName != *req.CDN {
+			errs = append(errs, fmt.Errorf("deliveryService '%s': cdnName '%s' is not the CDN of the request", xmlID, *ds.CDNName))
+		}
+		if ds.CDNName == nil {
+			errs = append(errs, fmt.Errorf("deliveryService '%s': cdnName is required", xmlID))
+		}
+		if ds.Tenant == nil && req.Tenant != nil {
+			ds.Tenant = req.Tenant
+			ds.setManaged("tenant")
+		} else if req.Tenant != nil && *ds.Tenant != *req.Tenant {
+			errs = append(errs, fmt.Errorf("deliveryService '%s': tenant '%s' is not the tenant of the request", xmlID, *ds.Tenant))
+		}
+		if ds.Tenant == nil {
+			errs = append(errs, fmt.Errorf("deliveryService '%s': tenant is required", xmlID))
+		}
+
+		for _, re := range ds.Regexes {
+			if DSMatchTypeFromString(re.Type) == DSMatchTypeInvalid {
+				errs = append(errs, fmt.Errorf("deliveryService '%s': invalid regex type '%s'", xmlID, re.Type))
+			}
+			if re.Pattern == "" {
+				errs = append(errs, fmt.Errorf("deliveryService '%s': regex pattern is required", xmlID))
+			} else if _, err := regexp.Compile(re.Pattern); err != nil {
+				errs = append(errs, fmt.Errorf("deliveryService '%s': invalid regex pattern '%s': %v", xmlID, re.Pattern, err))
+			}
+		}
+	}
+	return util.JoinErrs(errs)
+}
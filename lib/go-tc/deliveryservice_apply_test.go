@@ -0,0 +1,88 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDeliveryServiceApplyItemManages(t *testing.T) {
+	item := DeliveryServiceApplyItem{}
+	if !item.Manages("ecsEnabled") {
+		t.Error("expected an item which wasn't decoded from JSON to manage every field")
+	}
+
+	if err := json.Unmarshal([]byte(`{"xmlId": "ds1", "active": true, "ecsEnabled": false, "longDesc": null, "regexes": []}`), &item); err != nil {
+		t.Fatalf("unexpected error decoding item: %v", err)
+	}
+	if item.XMLID == nil || *item.XMLID != "ds1" || item.Regexes == nil || item.RequiredCapabilities != nil {
+		t.Errorf("expected the item to be decoded, actual: %+v", item)
+	}
+	for field, expected := range map[string]bool{"xmlId": true, "active": true, "ecsEnabled": true, "regexes": true, "longDesc": false, "signed": false} {
+		if actual := item.Manages(field); actual != expected {
+			t.Errorf("expected Manages(%s) to be %t, actual %t", field, expected, actual)
+		}
+	}
+}
+
+func TestDeliveryServiceApplyRequestValidate(t *testing.T) {
+	req := DeliveryServiceApplyRequest{}
+	if err := json.Unmarshal([]byte(`{"cdn": "cdn1", "deliveryServices": [{"xmlId": "ds1", "tenant": "root"}]}`), &req); err != nil {
+		t.Fatalf("unexpected error decoding request: %v", err)
+	}
+	if err := req.Validate(nil); err != nil {
+		t.Fatalf("expected a valid request, got error: %v", err)
+	}
+	ds := req.DeliveryServices[0]
+	if ds.CDNName == nil || *ds.CDNName != "cdn1" || !ds.Manages("cdnName") {
+		t.Errorf("expected the CDN of the request to be the managed CDN of the delivery service, actual: %v", ds.CDNName)
+	}
+
+	req = DeliveryServiceApplyRequest{}
+	if err := json.Unmarshal([]byte(`{"tenant": "root", "deliveryServices": [
+		{"xmlId": "ds1", "tenant": "other", "regexes": [{"type": "BOGUS", "pattern": "("}]},
+		{"xmlId": "ds1", "cdnName": "cdn1"},
+		{}
+	]}`), &req); err != nil {
+		t.Fatalf("unexpected error decoding request: %v", err)
+	}
+	err := req.Validate(nil)
+	if err == nil {
+		t.Fatal("expected an error, got none")
+	}
+	for _, expected := range []string{
+		"deliveryService 'ds1': tenant 'other' is not the tenant of the request",
+		"deliveryService 'ds1': cdnName is required",
+		"invalid regex type 'BOGUS'",
+		"invalid regex pattern '('",
+		"duplicate xmlId 'ds1'",
+		"deliveryServices[2]: xmlId is required",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error to contain '%s', actual: %v", expected, err)
+		}
+	}
+
+	if err := (&DeliveryServiceApplyRequest{DeliveryServices: []DeliveryServiceApplyItem{}}).Validate(nil); err == nil {
+		t.Error("expected an error for a request without a CDN or tenant, got none")
+	}
+}
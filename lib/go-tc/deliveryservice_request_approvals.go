@@ -0,0 +1,174 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MaxDSRRequiredApprovals is the greatest number of approvals a Delivery
+// Service Request Approval Policy may require.
+const MaxDSRRequiredApprovals = 10
+
+// DSRApprovalPolicy is the policy governing the approval of Delivery Service
+// Requests for the Delivery Services in a Tenant. It applies as well to the
+// Tenant's descendants, unless they have their own policy.
+type DSRApprovalPolicy struct {
+	TenantID *int    `json:"tenantId" db:"tenant_id"`
+	Tenant   *string `json:"tenant" db:"tenant"`
+	// RequiredApprovals is the number of distinct users who must approve a
+	// Delivery Service Request before it may become "pending" or "complete".
+	RequiredApprovals *int `json:"requiredApprovals" db:"required_approvals"`
+	// ApproverRoles are the names of the Roles whose users may approve
+	// Delivery Service Requests. If empty, users with any Role may.
+	ApproverRoles []string `json:"approverRoles" db:"approver_roles"`
+	// AllowSelfApproval is whether the author of a Delivery Service Request
+	// may approve it.
+	AllowSelfApproval *bool `json:"allowSelfApproval" db:"allow_self_approval"`
+	// NotifyByEmail is whether the author and assignee of a Delivery Service
+	// Request are emailed each time it's approved.
+	NotifyByEmail *bool      `json:"notifyByEmail" db:"notify_by_email"`
+	LastUpdated   *TimeNoMod `json:"lastUpdated" db:"last_updated"`
+}
+
+// DSRApprovalPoliciesResponse is the type of a response from Traffic Ops to
+// a GET request made to its /deliveryservice_request_approval_policies API
+// endpoint.
+type DSRApprovalPoliciesResponse struct {
+	Response []DSRApprovalPolicy `json:"response"`
+	Alerts
+}
+
+// DSRApprovalPolicyResponse is the type of a response from Traffic Ops to a
+// request that creates or updates a Delivery Service Request Approval Policy.
+type DSRApprovalPolicyResponse struct {
+	Response DSRApprovalPolicy `json:"response"`
+	Alerts
+}
+
+// Validate implements the
+// github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api.ParseValidator
+// interface.
+func (p *DSRApprovalPolicy) Validate(tx *sql.Tx) error {
+	errs := []string{}
+	if p.TenantID == nil {
+		errs = append(errs, "'tenantId' is required")
+	}
+	if p.RequiredApprovals == nil {
+		errs = append(errs, "'requiredApprovals' is required")
+	} else if *p.RequiredApprovals < 1 || *p.RequiredApprovals > MaxDSRRequiredApprovals {
+		errs = append(errs, "'requiredApprovals' must be between 1 and "+strconv.Itoa(MaxDSRRequiredApprovals))
+	}
+	for _, role := range p.ApproverRoles {
+		if role == "" {
+			errs = append(errs, "'approverRoles' cannot contain empty names")
+			break
+		}
+	}
+	if tx != nil && len(p.ApproverRoles) > 0 {
+		missing := []string{}
+		if err := tx.QueryRow(`SELECT ARRAY(SELECT n FROM UNNEST($1::text[]) AS n WHERE n NOT IN (SELECT name FROM role))`, pq.Array(p.ApproverRoles)).Scan(pq.Array(&missing)); err != nil {
+			return errors.New("checking approver roles: " + err.Error())
+		}
+		if len(missing) > 0 {
+			errs = append(errs, "'approverRoles' contains nonexistent Roles: "+strings.Join(missing, ", "))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// MayApprove returns whether a user with the given Role name may approve
+// Delivery Service Requests under the policy.
+func (p DSRApprovalPolicy) MayApprove(roleName string) bool {
+	if len(p.ApproverRoles) == 0 {
+		return true
+	}
+	for _, r := range p.ApproverRoles {
+		if r == roleName {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryServiceRequestApproval is the approval of a Delivery Service
+// Request by a single user.
+type DeliveryServiceRequestApproval struct {
+	ID                       int       `json:"id" db:"id"`
+	DeliveryServiceRequestID int       `json:"deliveryServiceRequestId" db:"deliveryservice_request"`
+	Approver                 string    `json:"approver" db:"approver"`
+	Comment                  *string   `json:"comment" db:"comment"`
+	Approved                 time.Time `json:"approved" db:"approved"`
+}
+
+// DeliveryServiceRequestApprovalRequest is the body of a POST request made
+// to the /deliveryservice_requests/{{ID}}/approvals API endpoint.
+type DeliveryServiceRequestApprovalRequest struct {
+	Comment *string `json:"comment"`
+}
+
+// Validate implements the
+// github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api.ParseValidator
+// interface.
+func (r *DeliveryServiceRequestApprovalRequest) Validate(*sql.Tx) error {
+	if r.Comment != nil && len(*r.Comment) > 1024 {
+		return errors.New("'comment' cannot be longer than 1024 characters")
+	}
+	return nil
+}
+
+// DeliveryServiceRequestApprovals is the approval state of a Delivery
+// Service Request.
+type DeliveryServiceRequestApprovals struct {
+	DeliveryServiceRequestID int `json:"deliveryServiceRequestId"`
+	// Policy is the strictest Approval Policy governing the Delivery Service
+	// Request - the one requiring the most approvals - or nil if none does.
+	Policy *DSRApprovalPolicy `json:"policy"`
+	// Policies are all of the Approval Policies governing the Delivery
+	// Service Request. An update which moves a Delivery Service to another
+	// Tenant is governed by the policies of both Tenants, and approvers must
+	// satisfy all of them.
+	Policies []DSRApprovalPolicy `json:"policies"`
+	// RequiredApprovals is the number of approvals the Delivery Service
+	// Request needs to become "pending" or "complete". It's zero if no
+	// policy governs it.
+	RequiredApprovals int                              `json:"requiredApprovals"`
+	Approvals         []DeliveryServiceRequestApproval `json:"approvals"`
+	// Approved is whether the Delivery Service Request has at least
+	// RequiredApprovals approvals.
+	Approved bool `json:"approved"`
+}
+
+// DeliveryServiceRequestApprovalsResponse is the type of a response from
+// Traffic Ops to a request made to its
+// /deliveryservice_requests/{{ID}}/approvals API endpoint.
+type DeliveryServiceRequestApprovalsResponse struct {
+	Response DeliveryServiceRequestApprovals `json:"response"`
+	Alerts
+}
@@ -0,0 +1,76 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/lib/go-util"
+)
+
+func TestDSRApprovalPolicyValidate(t *testing.T) {
+	valid := func() DSRApprovalPolicy {
+		return DSRApprovalPolicy{
+			TenantID:          util.IntPtr(1),
+			RequiredApprovals: util.IntPtr(2),
+			ApproverRoles:     []string{"admin", "operations"},
+		}
+	}
+
+	p := valid()
+	if err := p.Validate(nil); err != nil {
+		t.Errorf("expected valid policy, got error: %v", err)
+	}
+
+	p = valid()
+	p.TenantID = nil
+	if err := p.Validate(nil); err == nil || !strings.Contains(err.Error(), "tenantId") {
+		t.Errorf("expected error about missing tenantId, got: %v", err)
+	}
+
+	for _, n := range []int{0, -1, MaxDSRRequiredApprovals + 1} {
+		p = valid()
+		p.RequiredApprovals = util.IntPtr(n)
+		if err := p.Validate(nil); err == nil || !strings.Contains(err.Error(), "requiredApprovals") {
+			t.Errorf("expected error about %d required approvals, got: %v", n, err)
+		}
+	}
+
+	p = valid()
+	p.ApproverRoles = []string{"admin", ""}
+	if err := p.Validate(nil); err == nil || !strings.Contains(err.Error(), "approverRoles") {
+		t.Errorf("expected error about empty approver role, got: %v", err)
+	}
+}
+
+func TestDSRApprovalPolicyMayApprove(t *testing.T) {
+	p := DSRApprovalPolicy{}
+	if !p.MayApprove("read-only") {
+		t.Error("expected a policy with no approver roles to allow any role to approve")
+	}
+	p.ApproverRoles = []string{"admin", "operations"}
+	if !p.MayApprove("operations") {
+		t.Error("expected 'operations' to be allowed to approve")
+	}
+	if p.MayApprove("portal") {
+		t.Error("expected 'portal' not to be allowed to approve")
+	}
+}
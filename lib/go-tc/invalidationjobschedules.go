@@ -0,0 +1,142 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-util"
+)
+
+// InvalidationJobSchedule is a recurring content invalidation job. Each time
+// its Cron expression comes due, Traffic Ops creates a new content
+// invalidation job for its Delivery Service with its Regex and TTLHours.
+type InvalidationJobSchedule struct {
+	ID              *int    `json:"id" db:"id"`
+	DeliveryService *string `json:"deliveryService" db:"deliveryservice"`
+	Regex           *string `json:"regex" db:"regex"`
+	TTLHours        *uint   `json:"ttlHours" db:"ttl_hours"`
+	// Cron is a five-field cron expression, evaluated in UTC.
+	Cron      *string `json:"cron" db:"cron"`
+	Enabled   *bool   `json:"enabled" db:"enabled"`
+	CreatedBy *string `json:"createdBy" db:"created_by"`
+	// LastRun is the time at which a job was last created from the schedule,
+	// if ever.
+	LastRun *time.Time `json:"lastRun" db:"last_run"`
+	// NextRun is the time at which a job will next be created from the
+	// schedule. It is null if the schedule is disabled.
+	NextRun     *time.Time `json:"nextRun" db:"next_run"`
+	LastUpdated *TimeNoMod `json:"lastUpdated" db:"last_updated"`
+}
+
+// Validate implements the
+// github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api.ParseValidator
+// interface.
+func (s *InvalidationJobSchedule) Validate(tx *sql.Tx) error {
+	errs := []string{}
+	if s.DeliveryService == nil || *s.DeliveryService == "" {
+		errs = append(errs, "'deliveryService' is required")
+	}
+	if s.Regex == nil || *s.Regex == "" {
+		errs = append(errs, "'regex' is required")
+	} else if !strings.HasPrefix(*s.Regex, `\/`) && !strings.HasPrefix(*s.Regex, "/") {
+		errs = append(errs, `'regex' must start with '/' (or '\/')`)
+	} else if _, err := regexp.Compile(*s.Regex); err != nil {
+		errs = append(errs, "'regex' is not a valid Regular Expression: "+err.Error())
+	}
+	if s.TTLHours == nil || *s.TTLHours == 0 {
+		errs = append(errs, "'ttlHours' is required and must be greater than zero")
+	} else if tx != nil {
+		var maxDays uint
+		err := tx.QueryRow(`SELECT value FROM parameter WHERE name='maxRevalDurationDays' AND config_file='regex_revalidate.config'`).Scan(&maxDays)
+		if maxHours := maxDays * 24; err == nil && *s.TTLHours > maxHours { // silently ignore other errors too
+			errs = append(errs, "'ttlHours' cannot exceed "+strconv.FormatUint(uint64(maxHours), 10))
+		}
+	}
+	if s.Cron == nil || *s.Cron == "" {
+		errs = append(errs, "'cron' is required")
+	} else if _, err := util.ParseCronSchedule(*s.Cron); err != nil {
+		errs = append(errs, "'cron' is not a valid cron expression: "+err.Error())
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// InvalidationJobSchedulesResponse is the type of a response from Traffic Ops
+// to a GET request made to its /jobs/schedules API endpoint.
+type InvalidationJobSchedulesResponse struct {
+	Response []InvalidationJobSchedule `json:"response"`
+	Alerts
+}
+
+// InvalidationJobScheduleResponse is the type of a response from Traffic Ops
+// to a POST, PUT, or DELETE request made to its /jobs/schedules API endpoint.
+type InvalidationJobScheduleResponse struct {
+	Response InvalidationJobSchedule `json:"response"`
+	Alerts
+}
+
+// InvalidationJobAcknowledgement is a cache server's report of the content
+// invalidation jobs it has applied.
+type InvalidationJobAcknowledgement struct {
+	HostName *string  `json:"hostName"`
+	JobIDs   []uint64 `json:"jobIds"`
+}
+
+// Validate implements the
+// github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api.ParseValidator
+// interface.
+func (a *InvalidationJobAcknowledgement) Validate(*sql.Tx) error {
+	if a.HostName == nil || *a.HostName == "" {
+		return errors.New("'hostName' is required")
+	}
+	return nil
+}
+
+// InvalidationJobProgress describes how many of the cache servers to which a
+// content invalidation job applies have acknowledged applying it.
+type InvalidationJobProgress struct {
+	JobID           uint64 `json:"jobId"`
+	DeliveryService string `json:"deliveryService"`
+	// Servers is the number of cache servers to which the job applies.
+	Servers int `json:"servers"`
+	// Acknowledged is the number of those servers which have applied it.
+	Acknowledged int `json:"acknowledged"`
+	// Percent is Acknowledged as a percentage of Servers. It is 100 if the
+	// job applies to no servers.
+	Percent float64 `json:"percent"`
+	// Pending lists the host names of the servers which have not yet applied
+	// the job.
+	Pending []string `json:"pending"`
+}
+
+// InvalidationJobProgressResponse is the type of a response from Traffic Ops
+// to a GET request made to its /jobs/{{ID}}/progress API endpoint.
+type InvalidationJobProgressResponse struct {
+	Response InvalidationJobProgress `json:"response"`
+	Alerts
+}
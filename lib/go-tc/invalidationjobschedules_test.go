@@ -0,0 +1,70 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"testing"
+
+	"github.com/apache/trafficcontrol/lib/go-util"
+)
+
+func TestInvalidationJobScheduleValidate(t *testing.T) {
+	valid := func() InvalidationJobSchedule {
+		return InvalidationJobSchedule{
+			DeliveryService: util.StrPtr("demo1"),
+			Regex:           util.StrPtr(`/images/.*\.png`),
+			TTLHours:        util.UIntPtr(24),
+			Cron:            util.StrPtr("0 3 * * *"),
+		}
+	}
+
+	s := valid()
+	if err := s.Validate(nil); err != nil {
+		t.Errorf("expected valid schedule, got error: %v", err)
+	}
+
+	tests := map[string]func(*InvalidationJobSchedule){
+		"missing deliveryService": func(s *InvalidationJobSchedule) { s.DeliveryService = nil },
+		"missing regex":           func(s *InvalidationJobSchedule) { s.Regex = nil },
+		"relative regex":          func(s *InvalidationJobSchedule) { s.Regex = util.StrPtr("images/.*") },
+		"invalid regex":           func(s *InvalidationJobSchedule) { s.Regex = util.StrPtr("/images/(") },
+		"zero ttl":                func(s *InvalidationJobSchedule) { s.TTLHours = util.UIntPtr(0) },
+		"missing cron":            func(s *InvalidationJobSchedule) { s.Cron = util.StrPtr("") },
+		"invalid cron":            func(s *InvalidationJobSchedule) { s.Cron = util.StrPtr("0 25 * * *") },
+	}
+	for name, modify := range tests {
+		s := valid()
+		modify(&s)
+		if err := s.Validate(nil); err == nil {
+			t.Errorf("%s: expected an error, got none", name)
+		}
+	}
+}
+
+func TestInvalidationJobAcknowledgementValidate(t *testing.T) {
+	ack := InvalidationJobAcknowledgement{JobIDs: []uint64{1}}
+	if err := ack.Validate(nil); err == nil {
+		t.Error("expected an error for a missing hostName, got none")
+	}
+	ack.HostName = util.StrPtr("edge")
+	if err := ack.Validate(nil); err != nil {
+		t.Errorf("expected valid acknowledgement, got error: %v", err)
+	}
+}
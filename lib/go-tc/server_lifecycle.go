@@ -0,0 +1,161 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// These are the states through which a ServerMaintenanceWindow passes.
+const (
+	// MaintenanceWindowScheduled is the state of a maintenance window which
+	// has not yet started.
+	MaintenanceWindowScheduled = "scheduled"
+	// MaintenanceWindowActive is the state of a maintenance window which has
+	// started, but not yet ended.
+	MaintenanceWindowActive = "active"
+	// MaintenanceWindowComplete is the state of a maintenance window which has
+	// ended.
+	MaintenanceWindowComplete = "complete"
+)
+
+// ServerStatusTransition is a change of server Status which is allowed. Once
+// any transition from a Status exists, servers with that Status may only be
+// moved to the Statuses to which a transition from it exists.
+type ServerStatusTransition struct {
+	ID          *int       `json:"id" db:"id"`
+	FromStatus  *string    `json:"fromStatus" db:"from_status"`
+	ToStatus    *string    `json:"toStatus" db:"to_status"`
+	LastUpdated *TimeNoMod `json:"lastUpdated" db:"last_updated"`
+}
+
+// Validate implements the
+// github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api.ParseValidator
+// interface.
+func (t *ServerStatusTransition) Validate(*sql.Tx) error {
+	errs := []string{}
+	if t.FromStatus == nil || *t.FromStatus == "" {
+		errs = append(errs, "'fromStatus' is required")
+	}
+	if t.ToStatus == nil || *t.ToStatus == "" {
+		errs = append(errs, "'toStatus' is required")
+	}
+	if len(errs) == 0 && *t.FromStatus == *t.ToStatus {
+		errs = append(errs, "'toStatus' must differ from 'fromStatus'")
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ServerStatusTransitionsResponse is the type of a response from Traffic Ops
+// to a GET request made to its /server_status_transitions API endpoint.
+type ServerStatusTransitionsResponse struct {
+	Response []ServerStatusTransition `json:"response"`
+	Alerts
+}
+
+// ServerStatusTransitionResponse is the type of a response from Traffic Ops
+// to a POST or DELETE request made to its /server_status_transitions API
+// endpoint.
+type ServerStatusTransitionResponse struct {
+	Response ServerStatusTransition `json:"response"`
+	Alerts
+}
+
+// ServerMaintenanceWindow is a period of time during which a server is
+// ADMIN_DOWN for maintenance. Traffic Ops sets the server's Status to
+// ADMIN_DOWN when the window starts, and back to its previous Status when the
+// window ends.
+type ServerMaintenanceWindow struct {
+	ID       *int    `json:"id" db:"id"`
+	ServerID *int    `json:"serverId" db:"server"`
+	HostName *string `json:"hostName" db:"host_name"`
+	// Start is the time at which the server is set ADMIN_DOWN.
+	Start *time.Time `json:"start" db:"start_time"`
+	// End is the time at which the server is set back to its
+	// PreviousStatus.
+	End    *time.Time `json:"end" db:"end_time"`
+	Reason *string    `json:"reason" db:"reason"`
+	// State is one of MaintenanceWindowScheduled, MaintenanceWindowActive
+	// or MaintenanceWindowComplete.
+	State *string `json:"state" db:"state"`
+	// PreviousStatus is the Status the server had when the window started,
+	// to which it is restored when the window ends. It is null if the window
+	// hasn't started, or if the server's Status was not changed when it did.
+	PreviousStatus *string    `json:"previousStatus" db:"previous_status"`
+	CreatedBy      *string    `json:"createdBy" db:"created_by"`
+	LastUpdated    *TimeNoMod `json:"lastUpdated" db:"last_updated"`
+}
+
+// Validate implements the
+// github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api.ParseValidator
+// interface.
+func (m *ServerMaintenanceWindow) Validate(*sql.Tx) error {
+	errs := []string{}
+	if m.ServerID == nil {
+		errs = append(errs, "'serverId' is required")
+	}
+	if m.Start == nil {
+		errs = append(errs, "'start' is required")
+	}
+	if m.End == nil {
+		errs = append(errs, "'end' is required")
+	} else if m.Start != nil && !m.End.After(*m.Start) {
+		errs = append(errs, "'end' must be after 'start'")
+	} else if !m.End.After(time.Now()) {
+		errs = append(errs, "'end' must be in the future")
+	}
+	if m.Reason == nil || *m.Reason == "" {
+		errs = append(errs, "'reason' is required")
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ServerMaintenanceWindowsResponse is the type of a response from Traffic Ops
+// to a GET request made to its /server_maintenance_windows API endpoint.
+type ServerMaintenanceWindowsResponse struct {
+	Response []ServerMaintenanceWindow `json:"response"`
+	Alerts
+}
+
+// ServerMaintenanceWindowResponse is the type of a response from Traffic Ops
+// to a POST or DELETE request made to its /server_maintenance_windows API
+// endpoint.
+type ServerMaintenanceWindowResponse struct {
+	Response ServerMaintenanceWindow `json:"response"`
+	Alerts
+}
+
+// TMMaintenanceWindow is a scheduled or active maintenance window of a cache
+// server, as given to Traffic Monitor in its monitoring configuration.
+type TMMaintenanceWindow struct {
+	HostName string    `json:"hostName"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Reason   string    `json:"reason"`
+}
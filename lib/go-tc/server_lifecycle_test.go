@@ -0,0 +1,78 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-util"
+)
+
+func TestServerMaintenanceWindowValidate(t *testing.T) {
+	valid := func() ServerMaintenanceWindow {
+		start := time.Now().Add(time.Hour)
+		end := start.Add(2 * time.Hour)
+		return ServerMaintenanceWindow{
+			ServerID: util.IntPtr(1),
+			Start:    &start,
+			End:      &end,
+			Reason:   util.StrPtr("disk replacement"),
+		}
+	}
+
+	m := valid()
+	if err := m.Validate(nil); err != nil {
+		t.Errorf("expected valid maintenance window, got error: %v", err)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	tests := map[string]func(*ServerMaintenanceWindow){
+		"missing serverId": func(m *ServerMaintenanceWindow) { m.ServerID = nil },
+		"missing start":    func(m *ServerMaintenanceWindow) { m.Start = nil },
+		"missing end":      func(m *ServerMaintenanceWindow) { m.End = nil },
+		"end before start": func(m *ServerMaintenanceWindow) { m.End = &past },
+		"end equals start": func(m *ServerMaintenanceWindow) { end := *m.Start; m.End = &end },
+		"missing reason":   func(m *ServerMaintenanceWindow) { m.Reason = util.StrPtr("") },
+		"already ended":    func(m *ServerMaintenanceWindow) { start := past.Add(-time.Hour); m.Start = &start; m.End = &past },
+	}
+	for name, modify := range tests {
+		m := valid()
+		modify(&m)
+		if err := m.Validate(nil); err == nil {
+			t.Errorf("%s: expected an error, got none", name)
+		}
+	}
+}
+
+func TestServerStatusTransitionValidate(t *testing.T) {
+	tr := ServerStatusTransition{FromStatus: util.StrPtr("PRE_PROD"), ToStatus: util.StrPtr("REPORTED")}
+	if err := tr.Validate(nil); err != nil {
+		t.Errorf("expected valid transition, got error: %v", err)
+	}
+	tr.ToStatus = util.StrPtr("PRE_PROD")
+	if err := tr.Validate(nil); err == nil {
+		t.Error("expected an error for a transition to the same status, got none")
+	}
+	tr.FromStatus = nil
+	if err := tr.Validate(nil); err == nil {
+		t.Error("expected an error for a transition with no fromStatus, got none")
+	}
+}
@@ -0,0 +1,60 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import "time"
+
+// SSLKeyExpiration describes the certificate of a Delivery Service's current
+// SSL keys, as stored in Traffic Vault.
+type SSLKeyExpiration struct {
+	// DeliveryService is the XMLID of the Delivery Service.
+	DeliveryService string `json:"deliveryservice"`
+	// CDN is the name of the Delivery Service's CDN.
+	CDN string `json:"cdn"`
+	// Version is the version of the Delivery Service's SSL keys.
+	Version int `json:"version"`
+	// Expiration is the time after which the certificate is no longer
+	// valid.
+	Expiration time.Time `json:"expiration"`
+	// DaysUntilExpiration is the number of whole days until Expiration. It
+	// is negative if the certificate has already expired.
+	DaysUntilExpiration int `json:"daysUntilExpiration"`
+	// Issuer is the distinguished name of the certificate's issuer.
+	Issuer string `json:"issuer"`
+	// SANs are the Subject Alternative Names - DNS names and IP addresses -
+	// of the certificate.
+	SANs []string `json:"sans"`
+	// KeyType describes the certificate's public key, e.g. "RSA 2048" or
+	// "ECDSA P-256".
+	KeyType string `json:"keyType"`
+	// AuthType is the source of the certificate, e.g. "Self Signed" or
+	// "Lets Encrypt".
+	AuthType string `json:"authType"`
+	// AutoRenew is whether the certificate is renewed by Traffic Ops'
+	// automatic certificate renewal.
+	AutoRenew bool `json:"autoRenew"`
+}
+
+// SSLKeyExpirationsResponse is the type of a response from Traffic Ops to a
+// GET request made to its /sslkeys/expirations API endpoint.
+type SSLKeyExpirationsResponse struct {
+	Response []SSLKeyExpiration `json:"response"`
+	Alerts
+}
@@ -0,0 +1,98 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// TopologySimulation describes the path through a Topology taken by requests
+// for a Delivery Service which arrive at a Cache Group, as directed by the
+// parent.config files Traffic Ops generates.
+type TopologySimulation struct {
+	Topology        string `json:"topology"`
+	DeliveryService string `json:"deliveryService"`
+	CacheGroup      string `json:"cacheGroup"`
+	// Tiers are the Cache Groups through which requests pass, starting with
+	// CacheGroup and following each tier's primary parent. Each tier's
+	// secondary parent is only used when its primary parent can't be.
+	Tiers []TopologySimulationTier `json:"tiers"`
+}
+
+// TopologySimulationTier is a Cache Group through which requests pass in a
+// TopologySimulation.
+type TopologySimulationTier struct {
+	CacheGroup string `json:"cacheGroup"`
+	// Servers are the servers in the Cache Group which serve the Delivery
+	// Service.
+	Servers []TopologySimulationServer `json:"servers"`
+	// Excluded are the servers in the Cache Group which don't serve the
+	// Delivery Service, and why.
+	Excluded []TopologySimulationExclusion `json:"excluded"`
+	// PrimaryParent is the Cache Group to which the tier's servers forward
+	// requests, or nil if they forward them directly to the Origin.
+	PrimaryParent *TopologySimulationParent `json:"primaryParent"`
+	// SecondaryParent is the Cache Group to which the tier's servers forward
+	// requests if they can't use the PrimaryParent, if any.
+	SecondaryParent *TopologySimulationParent `json:"secondaryParent"`
+	// Origin is the host (and port, if any) of the Delivery Service's
+	// Origin, to which the tier's servers forward requests if they have no
+	// PrimaryParent.
+	Origin *string `json:"origin"`
+}
+
+// TopologySimulationParent is a parent Cache Group of a
+// TopologySimulationTier.
+type TopologySimulationParent struct {
+	CacheGroup string `json:"cacheGroup"`
+	// Servers are the parents in the Cache Group, in the order in which they
+	// appear in parent.config.
+	Servers []TopologySimulationServer `json:"servers"`
+	// Excluded are the servers in the Cache Group which aren't parents, and
+	// why.
+	Excluded []TopologySimulationExclusion `json:"excluded"`
+}
+
+// TopologySimulationServer is a server which serves requests in a
+// TopologySimulation.
+type TopologySimulationServer struct {
+	ID       int    `json:"id"`
+	HostName string `json:"hostName"`
+	Type     string `json:"type"`
+	Status   string `json:"status"`
+	// Rank is the server's parent rank, from the "rank" parent.config
+	// Parameter of its Profile.
+	Rank int `json:"rank"`
+	// Parent is the server's entry in parent.config lists of parents, e.g.
+	// "mid.example.net:80|0.999". It is empty for servers which serve
+	// requests for the tier, rather than as its parents.
+	Parent string `json:"parent,omitempty"`
+}
+
+// TopologySimulationExclusion is a server which doesn't serve requests in a
+// TopologySimulation, and why.
+type TopologySimulationExclusion struct {
+	ID       int    `json:"id"`
+	HostName string `json:"hostName"`
+	Reason   string `json:"reason"`
+}
+
+// TopologySimulationResponse is the type of a response from Traffic Ops to a
+// GET request made to its /topologies/{{name}}/simulate API endpoint.
+type TopologySimulationResponse struct {
+	Response TopologySimulation `json:"response"`
+	Alerts
+}
@@ -79,6 +79,9 @@ type TrafficMonitorConfig struct {
 	// servers (those given in TrafficServers), which are stored here to
 	// avoid potentially lengthy reiteration.
 	Profiles []TMProfile `json:"profiles,omitempty"`
+	// MaintenanceWindows is the set of scheduled and active maintenance
+	// windows of monitored cache servers.
+	MaintenanceWindows []TMMaintenanceWindow `json:"maintenanceWindows,omitempty"`
 }
 
 const healthThresholdAvailableBandwidthInKbps = "availableBandwidthInKbps"
@@ -179,6 +182,9 @@ type TrafficMonitorConfigMap struct {
 	DeliveryService map[string]TMDeliveryService
 	// Profile is a map of Profile Names to TMProfile objects.
 	Profile map[string]TMProfile
+	// MaintenanceWindow is a map of cache server hostnames to their scheduled
+	// and active maintenance windows.
+	MaintenanceWindow map[string][]TMMaintenanceWindow
 }
 
 func (s *Stats) ToLegacy(monitorConfig TrafficMonitorConfigMap) ([]string, LegacyStats) {
@@ -580,6 +586,7 @@ func TrafficMonitorTransformToMap(tmConfig *TrafficMonitorConfig) (*TrafficMonit
 	tm.TrafficMonitor = make(map[string]TrafficMonitor, len(tmConfig.TrafficMonitors))
 	tm.DeliveryService = make(map[string]TMDeliveryService, len(tmConfig.DeliveryServices))
 	tm.Profile = make(map[string]TMProfile, len(tmConfig.Profiles))
+	tm.MaintenanceWindow = make(map[string][]TMMaintenanceWindow)
 
 	for _, trafficServer := range tmConfig.TrafficServers {
 		tm.TrafficServer[trafficServer.HostName] = trafficServer
@@ -607,6 +614,10 @@ func TrafficMonitorTransformToMap(tmConfig *TrafficMonitorConfig) (*TrafficMonit
 		tm.Profile[profile.Name] = profile
 	}
 
+	for _, window := range tmConfig.MaintenanceWindows {
+		tm.MaintenanceWindow[window.HostName] = append(tm.MaintenanceWindow[window.HostName], window)
+	}
+
 	return &tm, tm.Valid()
 }
 
@@ -570,6 +570,10 @@ func TestTrafficMonitorTransformToMap(t *testing.T) {
 			TrafficMonitor{},
 		},
 		DeliveryServices: []TMDeliveryService{{XMLID: "foo"}},
+		MaintenanceWindows: []TMMaintenanceWindow{
+			{HostName: "testHostname", Reason: "first"},
+			{HostName: "testHostname", Reason: "second"},
+		},
 		Profiles: []TMProfile{
 			{
 				Name: "test",
@@ -604,6 +608,10 @@ func TestTrafficMonitorTransformToMap(t *testing.T) {
 		t.Error("Expected delivery service 'foo' to exist in map after conversion, but it didn't")
 	}
 
+	if len(converted.MaintenanceWindow["testHostname"]) != 2 {
+		t.Errorf("Incorrect number of maintenance windows for 'testHostname' after conversion; expected: 2, got: %d", len(converted.MaintenanceWindow["testHostname"]))
+	}
+
 	if _, ok := converted.TrafficServer["testHostname"]; !ok {
 		t.Error("Expected server 'testHostname' to exist in map after conversion, but it didn't")
 	} else if len(converted.TrafficServer["testHostname"].Interfaces) != 1 {
@@ -0,0 +1,55 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// TrafficVaultEncryptionKey is an AES key with which a Traffic Vault backend
+// encrypts secrets, as known to that backend.
+type TrafficVaultEncryptionKey struct {
+	// ID identifies the key; it's stored alongside each secret encrypted
+	// with the key.
+	ID string `json:"id"`
+	// Current is whether the key is the one new secrets are encrypted with.
+	Current bool `json:"current"`
+	// Configured is whether the key is configured in Traffic Ops, and so can
+	// be used to decrypt secrets.
+	Configured bool `json:"configured"`
+	// Retired is whether the key has been retired, after which it's never
+	// used.
+	Retired bool `json:"retired"`
+	// Secrets is the number of stored secrets encrypted with the key.
+	Secrets int `json:"secrets"`
+}
+
+// TrafficVaultEncryptionKeys is the set of encryption keys a Traffic Vault
+// backend knows of.
+type TrafficVaultEncryptionKeys struct {
+	Keys []TrafficVaultEncryptionKey `json:"keys"`
+	// UnidentifiedSecrets is the number of stored secrets which were
+	// encrypted before key IDs were recorded, which may be encrypted with any
+	// configured key. No key may be retired while there are any.
+	UnidentifiedSecrets int `json:"unidentifiedSecrets"`
+}
+
+// TrafficVaultEncryptionKeysResponse represents the JSON HTTP response
+// returned by the /vault/keys route.
+type TrafficVaultEncryptionKeysResponse struct {
+	Response TrafficVaultEncryptionKeys `json:"response"`
+	Alerts
+}
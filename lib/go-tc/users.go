@@ -41,6 +41,12 @@ type UserToken struct {
 	Token string `json:"t"`
 }
 
+// OIDCTokenLoginRequest is a request payload containing an OpenID Connect ID
+// token for authentication.
+type OIDCTokenLoginRequest struct {
+	IDToken string `json:"idToken"`
+}
+
 // UserV13 contains non-nullable TO user information
 type UserV13 struct {
 	Username         string    `json:"username"`
@@ -0,0 +1,199 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// WebhookEvent is the name of a Traffic Ops lifecycle event to which a
+// Webhook may subscribe.
+type WebhookEvent string
+
+// These are the events for which Traffic Ops calls Webhooks.
+const (
+	WebhookEventSnapshot                     = WebhookEvent("snapshot")
+	WebhookEventDeliveryServiceRequestStatus = WebhookEvent("deliveryservice_request.status")
+	WebhookEventCDNLockCreate                = WebhookEvent("cdn_lock.create")
+	WebhookEventCDNLockDelete                = WebhookEvent("cdn_lock.delete")
+	WebhookEventServerStatus                 = WebhookEvent("server.status")
+	WebhookEventInvalidationJobCreate        = WebhookEvent("invalidation_job.create")
+	WebhookEventSSLKeysExpiring              = WebhookEvent("sslkeys.expiring")
+)
+
+// WebhookEvents is the set of all valid WebhookEvents.
+var WebhookEvents = map[WebhookEvent]struct{}{
+	WebhookEventSnapshot:                     {},
+	WebhookEventDeliveryServiceRequestStatus: {},
+	WebhookEventCDNLockCreate:                {},
+	WebhookEventCDNLockDelete:                {},
+	WebhookEventServerStatus:                 {},
+	WebhookEventInvalidationJobCreate:        {},
+	WebhookEventSSLKeysExpiring:              {},
+}
+
+// These are the statuses of a WebhookDelivery.
+const (
+	WebhookDeliveryStatusPending    = "pending"
+	WebhookDeliveryStatusDelivering = "delivering"
+	WebhookDeliveryStatusSucceeded  = "succeeded"
+	WebhookDeliveryStatusFailed     = "failed"
+)
+
+// These are the HTTP headers sent with each Webhook call.
+const (
+	// WebhookEventHeader contains the name of the event being delivered.
+	WebhookEventHeader = "X-Traffic-Ops-Event"
+	// WebhookDeliveryHeader contains the integral, unique identifier of the
+	// delivery, which is the same for every attempt to make it.
+	WebhookDeliveryHeader = "X-Traffic-Ops-Delivery"
+	// WebhookSignatureHeader contains "sha256=" followed by the
+	// hex-encoded HMAC-SHA256 of the request body, keyed with the Webhook's
+	// secret.
+	WebhookSignatureHeader = "X-Traffic-Ops-Signature"
+)
+
+// Webhook is a subscription to Traffic Ops lifecycle events, which are
+// delivered by POSTing a WebhookPayload to its URL.
+type Webhook struct {
+	ID   *int    `json:"id" db:"id"`
+	Name *string `json:"name" db:"name"`
+	URL  *string `json:"url" db:"url"`
+	// Secret is the key used to sign payloads. It is write-only, and is
+	// never returned by Traffic Ops.
+	Secret *string `json:"secret,omitempty" db:"secret"`
+	// Events are the events to which the Webhook subscribes. If empty, it
+	// subscribes to all events.
+	Events      []WebhookEvent `json:"events" db:"events"`
+	Enabled     *bool          `json:"enabled" db:"enabled"`
+	LastUpdated *TimeNoMod     `json:"lastUpdated" db:"last_updated"`
+}
+
+// Validate implements the
+// github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api.ParseValidator
+// interface.
+func (w *Webhook) Validate(*sql.Tx) error {
+	errs := []string{}
+	if w.Name == nil || strings.TrimSpace(*w.Name) == "" {
+		errs = append(errs, "'name' is required")
+	}
+	if w.URL == nil || *w.URL == "" {
+		errs = append(errs, "'url' is required")
+	} else if u, err := url.Parse(*w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, "'url' must be an absolute http or https URL")
+	}
+	if w.Secret == nil || *w.Secret == "" {
+		errs = append(errs, "'secret' is required")
+	}
+	for _, event := range w.Events {
+		if _, ok := WebhookEvents[event]; !ok {
+			errs = append(errs, "unknown event '"+string(event)+"'")
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// WebhooksResponse is the type of a response from Traffic Ops to a GET
+// request made to its /webhooks API endpoint.
+type WebhooksResponse struct {
+	Response []Webhook `json:"response"`
+	Alerts
+}
+
+// WebhookResponse is the type of a response from Traffic Ops to a POST, PUT,
+// or DELETE request made to its /webhooks API endpoint.
+type WebhookResponse struct {
+	Response Webhook `json:"response"`
+	Alerts
+}
+
+// WebhookPayload is the body of a request made to a Webhook's URL.
+type WebhookPayload struct {
+	// Event is the event that occurred.
+	Event WebhookEvent `json:"event"`
+	// Time is when the event occurred.
+	Time time.Time `json:"time"`
+	// User is the username of the user who caused the event.
+	User string `json:"user"`
+	// Data describes the event; its structure depends on the Event.
+	Data interface{} `json:"data"`
+}
+
+// WebhookDelivery is a record of the delivery of an event to a Webhook.
+type WebhookDelivery struct {
+	ID           int             `json:"id" db:"id"`
+	WebhookID    int             `json:"webhookId" db:"webhook"`
+	Event        WebhookEvent    `json:"event" db:"event"`
+	Payload      json.RawMessage `json:"payload" db:"payload"`
+	Status       string          `json:"status" db:"status"`
+	Attempts     int             `json:"attempts" db:"attempts"`
+	ResponseCode *int            `json:"responseCode" db:"response_code"`
+	Error        *string         `json:"error" db:"error"`
+	Created      time.Time       `json:"created" db:"created"`
+	LastUpdated  time.Time       `json:"lastUpdated" db:"last_updated"`
+}
+
+// WebhookDeliveriesResponse is the type of a response from Traffic Ops to a
+// GET request made to its /webhooks/{{ID}}/deliveries API endpoint.
+type WebhookDeliveriesResponse struct {
+	Response []WebhookDelivery `json:"response"`
+	Alerts
+}
+
+// WebhookSnapshotData is the Data of a WebhookPayload for a
+// WebhookEventSnapshot event.
+type WebhookSnapshotData struct {
+	CDN   string `json:"cdn"`
+	CDNID int    `json:"cdnId"`
+}
+
+// WebhookDeliveryServiceRequestStatusData is the Data of a WebhookPayload
+// for a WebhookEventDeliveryServiceRequestStatus event.
+type WebhookDeliveryServiceRequestStatusData struct {
+	ID        int           `json:"id"`
+	XMLID     string        `json:"xmlId"`
+	OldStatus RequestStatus `json:"oldStatus"`
+	NewStatus RequestStatus `json:"newStatus"`
+}
+
+// WebhookServerStatusData is the Data of a WebhookPayload for a
+// WebhookEventServerStatus event.
+type WebhookServerStatusData struct {
+	ID            int     `json:"id"`
+	HostName      string  `json:"hostName"`
+	Status        string  `json:"status"`
+	OfflineReason *string `json:"offlineReason"`
+}
+
+// WebhookSSLKeysExpiringData is the Data of a WebhookPayload for a
+// WebhookEventSSLKeysExpiring event.
+type WebhookSSLKeysExpiringData struct {
+	// Days is the number of days within which the certificates expire.
+	Days        int                `json:"days"`
+	Expirations []SSLKeyExpiration `json:"expirations"`
+}
@@ -0,0 +1,57 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"testing"
+
+	"github.com/apache/trafficcontrol/lib/go-util"
+)
+
+func TestWebhookValidate(t *testing.T) {
+	valid := func() Webhook {
+		return Webhook{
+			Name:   util.StrPtr("hook"),
+			URL:    util.StrPtr("https://example.test/hook"),
+			Secret: util.StrPtr("secret"),
+			Events: []WebhookEvent{WebhookEventSnapshot},
+		}
+	}
+
+	wh := valid()
+	if err := wh.Validate(nil); err != nil {
+		t.Errorf("expected valid webhook, got error: %v", err)
+	}
+
+	tests := map[string]func(*Webhook){
+		"missing name":   func(w *Webhook) { w.Name = nil },
+		"missing secret": func(w *Webhook) { w.Secret = util.StrPtr("") },
+		"relative url":   func(w *Webhook) { w.URL = util.StrPtr("/hook") },
+		"non-http url":   func(w *Webhook) { w.URL = util.StrPtr("ftp://example.test/hook") },
+		"unknown event":  func(w *Webhook) { w.Events = append(w.Events, "bogus") },
+	}
+	for name, modify := range tests {
+		wh := valid()
+		modify(&wh)
+		if err := wh.Validate(nil); err == nil {
+			t.Errorf("%s: expected an error, got none", name)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package util
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	specs := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@fortnightly",
+	}
+	for _, spec := range specs {
+		if _, err := ParseCronSchedule(spec); err == nil {
+			t.Errorf("expected an error parsing '%s', got nil", spec)
+		}
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	start := time.Date(2021, time.June, 4, 10, 17, 30, 0, time.UTC) // a Friday
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2021, time.June, 4, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, time.June, 4, 10, 30, 0, 0, time.UTC)},
+		{"17 * * * *", time.Date(2021, time.June, 4, 11, 17, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2021, time.June, 5, 3, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, time.June, 4, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2021, time.June, 5, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2021, time.June, 6, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 9 * * mon-fri", time.Date(2021, time.June, 7, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, time.June, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,15 * *", time.Date(2021, time.June, 15, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 8-18/4 * * *", time.Date(2021, time.June, 4, 12, 0, 0, 0, time.UTC)},
+		// day of month OR day of week when both are restricted
+		{"0 0 13 * 1", time.Date(2021, time.June, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		sched, err := ParseCronSchedule(test.spec)
+		if err != nil {
+			t.Errorf("parsing '%s': unexpected error: %v", test.spec, err)
+			continue
+		}
+		if actual := sched.Next(start); !actual.Equal(test.expected) {
+			t.Errorf("'%s': expected next time %v, got %v", test.spec, test.expected, actual)
+		}
+	}
+}
+
+func TestCronScheduleMatches(t *testing.T) {
+	sched, err := ParseCronSchedule("*/10 2 * * *")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !sched.Matches(time.Date(2021, time.June, 4, 2, 20, 45, 0, time.UTC)) {
+		t.Error("expected 02:20:45 to match '*/10 2 * * *'")
+	}
+	if sched.Matches(time.Date(2021, time.June, 4, 2, 21, 0, 0, time.UTC)) {
+		t.Error("expected 02:21 not to match '*/10 2 * * *'")
+	}
+}
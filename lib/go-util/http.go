@@ -56,6 +56,14 @@ func (i *Interceptor) Header() http.Header {
 	return i.W.Header()
 }
 
+// Flush implements http.Flusher.
+// It flushes Interceptor's internal ResponseWriter, if that ResponseWriter is itself an http.Flusher, and otherwise does nothing.
+func (i *Interceptor) Flush() {
+	if f, ok := i.W.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // BodyInterceptor fulfills the Writer interface, but records the body and doesn't actually write. This allows performing operations on the entire body written by a handler, for example, compressing or hashing. To actually write, call `RealWrite()`. Note this means `len(b)` and `nil` are always returned by `Write()`, any real write errors will be returned by `RealWrite()`.
 type BodyInterceptor struct {
 	W         http.ResponseWriter
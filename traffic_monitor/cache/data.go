@@ -69,6 +69,39 @@ type AvailableStatus struct {
 	UnavailableStat string
 	// Poller is the name of the poller which set this availability status.
 	Poller string
+	// Probed is whether the cache server has been checked by synthetic probes.
+	Probed bool
+	// ProbeAvailable indicates whether a Cache Server passed its synthetic
+	// probes for various IP protocol versions. It's only meaningful if Probed
+	// is true.
+	ProbeAvailable AvailableTuple
+	// ProbeWhy describes the synthetic probes the cache server failed, if any.
+	ProbeWhy string
+}
+
+// CombinedAvailable returns whether the cache server is available for each IP
+// protocol version, taking the results of both polling and synthetic probes
+// into account.
+func (a AvailableStatus) CombinedAvailable() AvailableTuple {
+	if !a.Probed {
+		return a.Available
+	}
+	return AvailableTuple{
+		IPv4: a.Available.IPv4 && a.ProbeAvailable.IPv4,
+		IPv6: a.Available.IPv6 && a.ProbeAvailable.IPv6,
+	}
+}
+
+// Reason returns the reasons for the cache server's availability, from both
+// polling and synthetic probes.
+func (a AvailableStatus) Reason() string {
+	switch {
+	case a.ProbeWhy == "":
+		return a.Why
+	case a.Why == "":
+		return a.ProbeWhy
+	}
+	return a.Why + "; " + a.ProbeWhy
 }
 
 // CacheAvailableStatuses is the available status of each cache.
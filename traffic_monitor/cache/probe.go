@@ -0,0 +1,73 @@
+package cache
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/apache/trafficcontrol/traffic_monitor/poller"
+	"github.com/apache/trafficcontrol/traffic_monitor/todata"
+)
+
+// ProbeStatPrefix prefixes the names of the miscellaneous stats of synthetic
+// probe results, in the form "probe.<name>.<stat>". Health thresholds on these
+// stats are only evaluated against the results of the "probe" poller.
+const ProbeStatPrefix = "probe."
+
+func init() {
+	registerDecoder(poller.PollerTypeProbe, probeParse, probePrecompute)
+}
+
+// probeParse decodes the results of the "probe" poller. Each probe yields the
+// miscellaneous stats "probe.<name>.available" (1 or 0) and
+// "probe.<name>.latency_ms", and, for probes using TLS,
+// "probe.<name>.cert_expiry_days".
+func probeParse(cacheName string, rdr io.Reader, _ interface{}) (Statistics, map[string]interface{}, error) {
+	stats := Statistics{Probes: map[string]poller.ProbeResult{}}
+	if rdr == nil {
+		return stats, map[string]interface{}{}, nil
+	}
+
+	results := []poller.ProbeResult{}
+	if err := json.NewDecoder(rdr).Decode(&results); err != nil {
+		return stats, nil, err
+	}
+
+	miscStats := make(map[string]interface{}, len(results)*3)
+	for _, result := range results {
+		stats.Probes[result.Name] = result
+		prefix := ProbeStatPrefix + result.Name + "."
+		available := 0.0
+		if result.Available {
+			available = 1
+		}
+		miscStats[prefix+"available"] = available
+		miscStats[prefix+"latency_ms"] = result.LatencyMs
+		if result.CertExpiryDays != nil {
+			miscStats[prefix+"cert_expiry_days"] = *result.CertExpiryDays
+		}
+	}
+	return stats, miscStats, nil
+}
+
+func probePrecompute(cache string, toData todata.TOData, stats Statistics, miscStats map[string]interface{}) PrecomputedData {
+	return PrecomputedData{DeliveryServiceStats: map[string]*DSStat{}}
+}
@@ -0,0 +1,62 @@
+package cache
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProbeParse(t *testing.T) {
+	input := `[
+		{"name": "tcp", "type": "tcp", "available": true, "latency_ms": 1.5},
+		{"name": "https", "type": "tls", "available": false, "latency_ms": 3, "cert_expiry_days": 2.5, "error": "certificate expires in 2.5 days, less than 14.0"}
+	]`
+	stats, miscStats, err := probeParse("cache0", strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatalf("expected no error, actual: %v", err)
+	}
+	if len(stats.Probes) != 2 {
+		t.Fatalf("expected 2 probe results, actual: %d", len(stats.Probes))
+	}
+	if !stats.Probes["tcp"].Available || stats.Probes["https"].Available {
+		t.Errorf("expected probe 'tcp' available and 'https' unavailable, actual: %+v", stats.Probes)
+	}
+
+	expected := map[string]float64{
+		"probe.tcp.available":          1,
+		"probe.tcp.latency_ms":         1.5,
+		"probe.https.available":        0,
+		"probe.https.latency_ms":       3,
+		"probe.https.cert_expiry_days": 2.5,
+	}
+	if len(miscStats) != len(expected) {
+		t.Errorf("expected %d stats, actual: %d (%v)", len(expected), len(miscStats), miscStats)
+	}
+	for name, val := range expected {
+		if actual, ok := miscStats[name].(float64); !ok || actual != val {
+			t.Errorf("stat '%s' expected: %v, actual: %v", name, val, miscStats[name])
+		}
+	}
+
+	if _, _, err := probeParse("cache0", strings.NewReader("not json"), nil); err == nil {
+		t.Error("expected error parsing invalid probe results, actual: nil")
+	}
+}
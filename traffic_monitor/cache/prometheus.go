@@ -0,0 +1,423 @@
+package cache
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_monitor/config"
+	"github.com/apache/trafficcontrol/traffic_monitor/poller"
+	"github.com/apache/trafficcontrol/traffic_monitor/todata"
+)
+
+// prometheusDSStatPrefix prefixes the names of the miscellaneous stats into
+// which the prometheus parser aggregates Delivery Service metrics, in the form
+// "deliveryservice.<XMLID or remap FQDN>.<stat>". Prometheus metric names
+// can't contain dots, so these never collide with the metrics themselves.
+const prometheusDSStatPrefix = "deliveryservice."
+
+func init() {
+	registerDecoder("prometheus", prometheusParse, prometheusPrecompute)
+}
+
+// prometheusLabel is a single label of a Prometheus sample.
+type prometheusLabel struct {
+	Name  string
+	Value string
+}
+
+// prometheusSample is a single sample of the Prometheus text exposition (or
+// OpenMetrics) format, without its timestamp, which is ignored.
+type prometheusSample struct {
+	Name   string
+	Labels []prometheusLabel
+	Value  float64
+}
+
+// label returns the value of the sample's label with the given name.
+func (s prometheusSample) label(name string) (string, bool) {
+	for _, l := range s.Labels {
+		if l.Name == name {
+			return l.Value, true
+		}
+	}
+	return "", false
+}
+
+// key returns the name under which the sample is stored in the
+// miscellaneous stats, e.g. `node_load1` or `http_requests_total{code="200"}`.
+func (s prometheusSample) key() string {
+	if len(s.Labels) == 0 {
+		return s.Name
+	}
+	b := strings.Builder{}
+	b.WriteString(s.Name)
+	b.WriteString("{")
+	for i, l := range s.Labels {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(l.Name)
+		b.WriteString("=")
+		b.WriteString(strconv.Quote(l.Value))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func prometheusParse(cacheName string, data io.Reader, pollCTX interface{}) (Statistics, map[string]interface{}, error) {
+	var stats Statistics
+	if data == nil {
+		log.Warnf("Cannot read stats data for cache '%s' - nil data reader", cacheName)
+		return stats, nil, errors.New("handler got nil reader")
+	}
+
+	mapping := config.DefaultPrometheusStats
+	if ctx, ok := pollCTX.(*poller.HTTPPollCtx); ok {
+		mapping = ctx.PrometheusStats
+	}
+
+	samples, err := prometheusParseSamples(cacheName, data)
+	if err != nil {
+		return stats, nil, fmt.Errorf("parsing Prometheus stats for cache '%s': %v", cacheName, err)
+	}
+
+	miscStats := make(map[string]interface{}, len(samples))
+	stats.Interfaces = make(map[string]Interface)
+	foundLoadavg := false
+	for _, sample := range samples {
+		if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			log.Debugf("cache '%s' Prometheus sample '%s' is not finite, skipping", cacheName, sample.key())
+			continue
+		}
+
+		switch sample.Name {
+		case mapping.LoadavgOneMetric:
+			stats.Loadavg.One = sample.Value
+			foundLoadavg = true
+			continue
+		case mapping.LoadavgFiveMetric:
+			stats.Loadavg.Five = sample.Value
+			continue
+		case mapping.LoadavgFifteenMetric:
+			stats.Loadavg.Fifteen = sample.Value
+			continue
+		case mapping.AvailableMetric:
+			stats.NotAvailable = sample.Value == 0
+		case mapping.InterfaceBytesInMetric, mapping.InterfaceBytesOutMetric, mapping.InterfaceSpeedMetric:
+			prometheusAddInterfaceSample(cacheName, mapping, sample, stats.Interfaces)
+		case mapping.DSInBytesMetric, mapping.DSOutBytesMetric, mapping.DSResponsesMetric:
+			prometheusAddDSSample(mapping, sample, miscStats)
+		}
+		miscStats[sample.key()] = sample.Value
+	}
+
+	if mapping.LoadavgOneMetric == "" || !foundLoadavg {
+		return stats, nil, fmt.Errorf("Error parsing loadavg for cache '%s': data was missing '%s'", cacheName, mapping.LoadavgOneMetric)
+	}
+	if len(stats.Interfaces) < 1 {
+		return stats, nil, fmt.Errorf("cache '%s' had no interfaces", cacheName)
+	}
+
+	return stats, miscStats, nil
+}
+
+// prometheusAddInterfaceSample adds the network interface statistic in the
+// given sample to ifaces.
+func prometheusAddInterfaceSample(cacheName string, mapping config.PrometheusStats, sample prometheusSample, ifaces map[string]Interface) {
+	name, ok := sample.label(mapping.InterfaceLabel)
+	if !ok || name == "" {
+		log.Warnf("cache '%s' interface stat '%s' has no '%s' label", cacheName, sample.key(), mapping.InterfaceLabel)
+		return
+	}
+	iface := ifaces[name]
+	switch sample.Name {
+	case mapping.InterfaceSpeedMetric:
+		speed := sample.Value * mapping.InterfaceSpeedMultiplier
+		if speed > math.MaxInt64 || speed < 0 {
+			log.Warnf("speed of interface '%s' outside of representable integer range: %v", name, speed)
+			return
+		}
+		iface.Speed = int64(speed)
+	default:
+		if sample.Value > math.MaxUint64 || sample.Value < 0 {
+			log.Warnf("bytes for interface '%s' cannot be represented as a uint64 (%v)", name, sample.Value)
+			return
+		}
+		if sample.Name == mapping.InterfaceBytesInMetric {
+			iface.BytesIn = uint64(sample.Value)
+		} else {
+			iface.BytesOut = uint64(sample.Value)
+		}
+	}
+	ifaces[name] = iface
+}
+
+// prometheusAddDSSample adds the Delivery Service statistic in the given
+// sample to the aggregate Delivery Service stats in miscStats. Samples for the
+// same statistic, e.g. responses with codes 200 and 206, are summed.
+func prometheusAddDSSample(mapping config.PrometheusStats, sample prometheusSample, miscStats map[string]interface{}) {
+	ds, ok := sample.label(mapping.DeliveryServiceLabel)
+	if !ok || ds == "" {
+		return
+	}
+	stat := ""
+	switch sample.Name {
+	case mapping.DSInBytesMetric:
+		stat = "in_bytes"
+	case mapping.DSOutBytesMetric:
+		stat = "out_bytes"
+	default:
+		code, _ := sample.label(mapping.StatusCodeLabel)
+		if code == "" || code[0] < '2' || code[0] > '5' {
+			return
+		}
+		stat = "status_" + code[:1] + "xx"
+	}
+	key := prometheusDSStatPrefix + ds + "." + stat
+	total, _ := miscStats[key].(float64)
+	miscStats[key] = total + sample.Value
+}
+
+// prometheusParseSamples parses every sample in the given Prometheus text
+// exposition (or OpenMetrics) format data. Comments, including HELP and TYPE
+// metadata, are ignored. A line which can't be parsed is logged and skipped,
+// rather than failing the whole scrape.
+func prometheusParseSamples(cacheName string, data io.Reader) ([]prometheusSample, error) {
+	samples := []prometheusSample{}
+	scanner := bufio.NewScanner(data)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		sample, err := prometheusParseLine(line)
+		if err != nil {
+			log.Warnf("cache '%s' Prometheus stats line %d cannot be parsed, skipping: %v", cacheName, lineNum, err)
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(samples) < 1 {
+		return nil, errors.New("no samples found")
+	}
+	return samples, nil
+}
+
+// prometheusParseLine parses a single sample line, of the form
+// `name{label="value",...} value [timestamp] [# {label="value",...} value [timestamp]]`.
+// The OpenMetrics exemplar following the '#', if any, is ignored.
+func prometheusParseLine(line string) (prometheusSample, error) {
+	sample := prometheusSample{}
+	nameEnd := strings.IndexAny(line, "{ \t")
+	if nameEnd < 0 {
+		return sample, errors.New("sample has no value")
+	}
+	sample.Name = line[:nameEnd]
+	if sample.Name == "" {
+		return sample, errors.New("sample has no metric name")
+	}
+	rest := line[nameEnd:]
+
+	if rest[0] == '{' {
+		labels, remaining, err := prometheusParseLabels(rest[1:])
+		if err != nil {
+			return sample, fmt.Errorf("metric '%s': %v", sample.Name, err)
+		}
+		sample.Labels = labels
+		rest = remaining
+	}
+
+	fields := strings.Fields(rest)
+	for i, field := range fields {
+		if field == "#" {
+			fields = fields[:i]
+			break
+		}
+	}
+	if len(fields) < 1 || len(fields) > 2 {
+		return sample, fmt.Errorf("metric '%s': expected a value and optional timestamp, got '%s'", sample.Name, strings.TrimSpace(rest))
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, fmt.Errorf("metric '%s': parsing value: %v", sample.Name, err)
+	}
+	sample.Value = value
+	return sample, nil
+}
+
+// prometheusParseLabels parses the labels following the opening brace of a
+// sample, returning them and the rest of the line after the closing brace.
+func prometheusParseLabels(s string) ([]prometheusLabel, string, error) {
+	labels := []prometheusLabel{}
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return nil, "", errors.New("unterminated label set")
+		}
+		if s[0] == '}' {
+			return labels, s[1:], nil
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return nil, "", errors.New("label has no value")
+		}
+		name := strings.TrimSpace(s[:eq])
+		if name == "" {
+			return nil, "", errors.New("label has no name")
+		}
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		if s == "" || s[0] != '"' {
+			return nil, "", fmt.Errorf("value of label '%s' is not quoted", name)
+		}
+
+		value := strings.Builder{}
+		i := 1
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] != '\\' {
+				value.WriteByte(s[i])
+				continue
+			}
+			i++
+			if i >= len(s) {
+				break
+			}
+			switch s[i] {
+			case 'n':
+				value.WriteByte('\n')
+			default: // '\\' and '"', plus anything else escaped needlessly
+				value.WriteByte(s[i])
+			}
+		}
+		if i >= len(s) {
+			return nil, "", fmt.Errorf("unterminated value of label '%s'", name)
+		}
+		labels = append(labels, prometheusLabel{Name: name, Value: value.String()})
+
+		s = strings.TrimLeft(s[i+1:], " \t")
+		if strings.HasPrefix(s, ",") {
+			s = s[1:]
+		} else if !strings.HasPrefix(s, "}") {
+			return nil, "", fmt.Errorf("expected ',' or '}' after label '%s'", name)
+		}
+	}
+}
+
+func prometheusPrecompute(cacheName string, data todata.TOData, stats Statistics, miscStats map[string]interface{}) PrecomputedData {
+	var precomputed PrecomputedData
+	precomputed.DeliveryServiceStats = make(map[string]*DSStat)
+
+	precomputed.OutBytes = 0
+	precomputed.MaxKbps = 0
+	for _, iface := range stats.Interfaces {
+		precomputed.OutBytes += iface.BytesOut
+		if iface.Speed > precomputed.MaxKbps {
+			precomputed.MaxKbps = iface.Speed
+		}
+	}
+	precomputed.MaxKbps *= 1000
+
+	for stat, value := range miscStats {
+		if !strings.HasPrefix(stat, prometheusDSStatPrefix) {
+			continue
+		}
+		trimmedStat := strings.TrimPrefix(stat, prometheusDSStatPrefix)
+		lastDot := strings.LastIndexByte(trimmedStat, '.')
+		if lastDot < 1 {
+			err := errors.New("stat has no deliveryservice and name parts")
+			log.Infof("precomputing cache %s stat %s value %v error %v", cacheName, stat, value, err)
+			precomputed.Errors = append(precomputed.Errors, err)
+			continue
+		}
+		ds, ok := prometheusDeliveryService(data, trimmedStat[:lastDot])
+		if !ok {
+			err := errors.New("No Delivery Service match for stat")
+			log.Infof("precomputing cache %s stat %s value %v error %v", cacheName, stat, value, err)
+			precomputed.Errors = append(precomputed.Errors, err)
+			continue
+		}
+
+		floatVal, ok := value.(float64)
+		if !ok || floatVal > math.MaxUint64 || floatVal < 0 {
+			err := fmt.Errorf("couldn't parse numeric stat: value '%v' out of range for uint64", value)
+			log.Infof("precomputing cache %s stat %s value %v error %v", cacheName, stat, value, err)
+			precomputed.Errors = append(precomputed.Errors, err)
+			continue
+		}
+		parsedStat := uint64(floatVal)
+
+		dsName := string(ds)
+		dsStat, ok := precomputed.DeliveryServiceStats[dsName]
+		if !ok || dsStat == nil {
+			dsStat = new(DSStat)
+		}
+		switch trimmedStat[lastDot+1:] {
+		case "status_2xx":
+			dsStat.Status2xx += parsedStat
+		case "status_3xx":
+			dsStat.Status3xx += parsedStat
+		case "status_4xx":
+			dsStat.Status4xx += parsedStat
+		case "status_5xx":
+			dsStat.Status5xx += parsedStat
+		case "out_bytes":
+			dsStat.OutBytes += parsedStat
+		case "in_bytes":
+			dsStat.InBytes += parsedStat
+		default:
+			err := fmt.Errorf("Unknown stat '%s'", trimmedStat[lastDot+1:])
+			log.Infof("precomputing cache %s stat %s value %v error %v", cacheName, stat, value, err)
+			precomputed.Errors = append(precomputed.Errors, err)
+			continue
+		}
+		precomputed.DeliveryServiceStats[dsName] = dsStat
+	}
+	return precomputed
+}
+
+// prometheusDeliveryService returns the Delivery Service identified by the
+// given value of a Delivery Service label, which is either the XMLID of a
+// Delivery Service or the FQDN of one of its remaps.
+func prometheusDeliveryService(data todata.TOData, name string) (tc.DeliveryServiceName, bool) {
+	if _, ok := data.DeliveryServiceTypes[tc.DeliveryServiceName(name)]; ok {
+		return tc.DeliveryServiceName(name), true
+	}
+	parts := strings.SplitN(name, ".", 3)
+	if len(parts) < 3 {
+		return "", false
+	}
+	ds, ok := data.DeliveryServiceRegexes.DeliveryService(parts[2], parts[1], parts[0])
+	return ds, ok && ds != ""
+}
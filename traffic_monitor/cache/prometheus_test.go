@@ -0,0 +1,226 @@
+package cache
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_monitor/config"
+	"github.com/apache/trafficcontrol/traffic_monitor/poller"
+	"github.com/apache/trafficcontrol/traffic_monitor/todata"
+)
+
+func TestPrometheusParse(t *testing.T) {
+	fd, err := os.Open("prometheus.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	pl := &poller.HTTPPollCtx{HTTPHeader: http.Header{}, PrometheusStats: config.DefaultPrometheusStats}
+	stats, misc, err := prometheusParse("test", fd, pl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Loadavg.One != 0.42 || stats.Loadavg.Five != 0.25 || stats.Loadavg.Fifteen != 0.5 {
+		t.Errorf("Incorrect loadavg, expected 0.42 0.25 0.5, got %v %v %v", stats.Loadavg.One, stats.Loadavg.Five, stats.Loadavg.Fifteen)
+	}
+	if stats.NotAvailable {
+		t.Error("Expected the cache to be available, since no available metric is configured")
+	}
+
+	if len(stats.Interfaces) != 2 {
+		t.Fatalf("Expected exactly two interfaces, got %d", len(stats.Interfaces))
+	}
+	iface, ok := stats.Interfaces["eth0"]
+	if !ok {
+		t.Fatal("Didn't find the expected 'eth0' network interface")
+	}
+	if iface.Speed != 10000 {
+		t.Errorf("Incorrect interface speed, expected 10000, got %d", iface.Speed)
+	}
+	if iface.BytesIn != 4363732 {
+		t.Errorf("Incorrect interface bytes in, expected 4363732, got %d", iface.BytesIn)
+	}
+	if iface.BytesOut != 237634637 {
+		t.Errorf("Incorrect interface bytes out, expected 237634637, got %d", iface.BytesOut)
+	}
+
+	if misc["deliveryservice.demo1.status_2xx"] != float64(100) {
+		t.Errorf("Expected 100 for demo1 status_2xx, got %v", misc["deliveryservice.demo1.status_2xx"])
+	}
+	if misc[`deliveryservice_responses_total{deliveryservice="demo1",code="206"}`] != float64(10) {
+		t.Errorf("Expected the raw sample for demo1 206 responses to be 10, got %v", misc[`deliveryservice_responses_total{deliveryservice="demo1",code="206"}`])
+	}
+	if misc[`process_start_time_seconds{path="C:\\traffic server",note="say \"hi\"\nbye"}`] != float64(1623340800) {
+		t.Errorf("Expected escaped label values to be parsed, got misc stats %v", misc)
+	}
+	for stat := range misc {
+		if strings.HasPrefix(stat, "go_gc_duration_seconds") {
+			t.Errorf("Expected NaN sample '%s' to be skipped", stat)
+		}
+		if strings.HasPrefix(stat, "node_load") {
+			t.Errorf("Expected loadavg sample '%s' to be removed from the miscellaneous stats", stat)
+		}
+	}
+}
+
+func TestPrometheusParseMapping(t *testing.T) {
+	mapping := config.DefaultPrometheusStats
+	mapping.LoadavgOneMetric = "ats_load_one"
+	mapping.InterfaceLabel = "interface"
+	mapping.InterfaceBytesOutMetric = "ats_interface_tx_bytes"
+	mapping.AvailableMetric = "ats_up"
+	data := `
+ats_load_one 1.5
+ats_interface_tx_bytes{interface="bond0"} 12345
+ats_up 0
+`
+	pl := &poller.HTTPPollCtx{HTTPHeader: http.Header{}, PrometheusStats: mapping}
+	stats, _, err := prometheusParse("test", strings.NewReader(data), pl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Loadavg.One != 1.5 {
+		t.Errorf("Incorrect one-minute loadavg, expected 1.5, got %v", stats.Loadavg.One)
+	}
+	if stats.Interfaces["bond0"].BytesOut != 12345 {
+		t.Errorf("Incorrect bond0 bytes out, expected 12345, got %+v", stats.Interfaces)
+	}
+	if !stats.NotAvailable {
+		t.Error("Expected the cache to be unavailable, since its available metric was 0")
+	}
+
+	data = `
+ats_load_one 1.5 # {trace_id="abc"} 1
+ats_interface_tx_bytes{interface="bond0"} 12345 1623340800000 # {trace_id="abc"} 1
+ats_interface_tx_bytes{interface="bond1"} not a number
+`
+	stats, _, err = prometheusParse("test", strings.NewReader(data), pl)
+	if err != nil {
+		t.Fatalf("Expected an unparsable line not to fail the whole scrape, got: %v", err)
+	}
+	if stats.Loadavg.One != 1.5 || stats.Interfaces["bond0"].BytesOut != 12345 {
+		t.Errorf("Incorrect stats parsed around exemplars and an unparsable line: %+v", stats)
+	}
+	if _, ok := stats.Interfaces["bond1"]; ok {
+		t.Error("Expected the unparsable bond1 sample to be skipped")
+	}
+
+	if _, _, err := prometheusParse("test", strings.NewReader("ats_up 1\n"), pl); err == nil {
+		t.Error("Expected an error parsing stats with no loadavg, got nil")
+	}
+	if _, _, err := prometheusParse("test", strings.NewReader("ats_load_one 1.5\n"), pl); err == nil {
+		t.Error("Expected an error parsing stats with no interfaces, got nil")
+	}
+}
+
+func TestPrometheusParseLine(t *testing.T) {
+	invalid := []string{
+		`metric`,
+		`metric{label="value"`,
+		`metric{label=value} 1`,
+		`metric{label="value} 1`,
+		`metric{label="value" other="value"} 1`,
+		`metric{="value"} 1`,
+		`metric notanumber`,
+		`metric 1 2 3`,
+	}
+	for _, line := range invalid {
+		if _, err := prometheusParseLine(line); err == nil {
+			t.Errorf("Expected an error parsing '%s', got nil", line)
+		}
+	}
+
+	exemplars := map[string]float64{
+		`http_requests_total{code="200"} 1027 # {trace_id="KOO5S4vxi0o"} 1 1623340800.000`: 1027,
+		`http_requests_total{code="200"} 1027 1623340800000 # {trace_id="KOO5S4vxi0o"} 1`:  1027,
+		`http_requests_total 12 # {trace_id="a # b"} 0.5`:                                  12,
+	}
+	for line, expected := range exemplars {
+		sample, err := prometheusParseLine(line)
+		if err != nil {
+			t.Errorf("Unexpected error parsing a sample with an exemplar '%s': %v", line, err)
+		} else if sample.Value != expected {
+			t.Errorf("Incorrect value of sample '%s', expected %v, got %v", line, expected, sample.Value)
+		}
+	}
+
+	sample, err := prometheusParseLine(`metric{a="1",b="2",} +Inf`)
+	if err != nil {
+		t.Fatalf("Unexpected error parsing a sample with a trailing comma: %v", err)
+	}
+	if len(sample.Labels) != 2 || sample.key() != `metric{a="1",b="2"}` {
+		t.Errorf("Incorrect sample, expected metric{a=\"1\",b=\"2\"}, got %s", sample.key())
+	}
+}
+
+func TestPrometheusPrecompute(t *testing.T) {
+	fd, err := os.Open("prometheus.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	pl := &poller.HTTPPollCtx{HTTPHeader: http.Header{}, PrometheusStats: config.DefaultPrometheusStats}
+	stats, misc, err := prometheusParse("test", fd, pl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	toData := todata.New()
+	toData.DeliveryServiceTypes["demo1"] = tc.DSTypeCategoryHTTP
+	toData.DeliveryServiceTypes["demo2"] = tc.DSTypeCategoryHTTP
+	toData.DeliveryServiceRegexes.DotStartSlashDotFooSlashDotDotStar["demo2"] = "demo2"
+
+	prc := prometheusPrecompute("test", *toData, stats, misc)
+	if prc.OutBytes != 237634637+1024 {
+		t.Errorf("Incorrect OutBytes, expected %d, got %d", 237634637+1024, prc.OutBytes)
+	}
+	if prc.MaxKbps != 10000000 {
+		t.Errorf("Incorrect MaxKbps, expected 10000000, got %d", prc.MaxKbps)
+	}
+	if len(prc.Errors) != 1 {
+		t.Errorf("Expected exactly one error, for the unknown Delivery Service, got %v", prc.Errors)
+	}
+
+	expected := map[string]DSStat{
+		"demo1": {InBytes: 1000, OutBytes: 50000, Status2xx: 100, Status4xx: 3, Status5xx: 1},
+		"demo2": {InBytes: 2000, OutBytes: 60000, Status3xx: 7},
+	}
+	if len(prc.DeliveryServiceStats) != len(expected) {
+		t.Errorf("Expected stats for %d Delivery Services, got %d", len(expected), len(prc.DeliveryServiceStats))
+	}
+	for ds, exp := range expected {
+		actual, ok := prc.DeliveryServiceStats[ds]
+		if !ok || actual == nil {
+			t.Errorf("Expected stats for Delivery Service '%s', got none", ds)
+			continue
+		}
+		if *actual != exp {
+			t.Errorf("Incorrect stats for Delivery Service '%s', expected %+v, got %+v", ds, exp, *actual)
+		}
+	}
+}
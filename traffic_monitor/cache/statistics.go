@@ -23,6 +23,8 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+
+	"github.com/apache/trafficcontrol/traffic_monitor/poller"
 )
 
 // DSStat is a single Delivery Service statistic, which is associated with
@@ -161,6 +163,9 @@ type Statistics struct {
 	// Sometimes caches can directly report this, but it's not supported by
 	// stats_over_http (afaik), so it always just uses ``false''
 	NotAvailable bool
+	// Probes are the results of synthetic probes of the cache server, by
+	// probe name. It's only populated for results of the "probe" poller.
+	Probes map[string]poller.ProbeResult
 }
 
 // AddInterfaceFromRawLine parses the raw line - presumably read from
@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"regexp"
 	"strings"
 	"time"
 
@@ -97,37 +98,260 @@ func (t *PollingProtocol) UnmarshalJSON(b []byte) error {
 
 // Config is the configuration for the application. It includes myriad data, such as polling intervals and log locations.
 type Config struct {
-	CacheHealthPollingInterval   time.Duration   `json:"-"`
-	CacheStatPollingInterval     time.Duration   `json:"-"`
-	MonitorConfigPollingInterval time.Duration   `json:"-"`
-	HTTPTimeout                  time.Duration   `json:"-"`
-	PeerPollingInterval          time.Duration   `json:"-"`
-	PeerOptimistic               bool            `json:"peer_optimistic"`
-	PeerOptimisticQuorumMin      int             `json:"peer_optimistic_quorum_min"`
-	MaxEvents                    uint64          `json:"max_events"`
-	MaxStatHistory               uint64          `json:"max_stat_history"`
-	MaxHealthHistory             uint64          `json:"max_health_history"`
-	HealthFlushInterval          time.Duration   `json:"-"`
-	StatFlushInterval            time.Duration   `json:"-"`
-	StatBufferInterval           time.Duration   `json:"-"`
-	LogLocationError             string          `json:"log_location_error"`
-	LogLocationWarning           string          `json:"log_location_warning"`
-	LogLocationInfo              string          `json:"log_location_info"`
-	LogLocationDebug             string          `json:"log_location_debug"`
-	LogLocationEvent             string          `json:"log_location_event"`
-	ServeReadTimeout             time.Duration   `json:"-"`
-	ServeWriteTimeout            time.Duration   `json:"-"`
-	HealthToStatRatio            uint64          `json:"health_to_stat_ratio"`
-	StaticFileDir                string          `json:"static_file_dir"`
-	CRConfigHistoryCount         uint64          `json:"crconfig_history_count"`
-	TrafficOpsMinRetryInterval   time.Duration   `json:"-"`
-	TrafficOpsMaxRetryInterval   time.Duration   `json:"-"`
-	CRConfigBackupFile           string          `json:"crconfig_backup_file"`
-	TMConfigBackupFile           string          `json:"tmconfig_backup_file"`
-	TrafficOpsDiskRetryMax       uint64          `json:"-"`
-	CachePollingProtocol         PollingProtocol `json:"cache_polling_protocol"`
-	PeerPollingProtocol          PollingProtocol `json:"peer_polling_protocol"`
-	HTTPPollingFormat            string          `json:"http_polling_format"`
+	CacheHealthPollingInterval   time.Duration    `json:"-"`
+	CacheStatPollingInterval     time.Duration    `json:"-"`
+	MonitorConfigPollingInterval time.Duration    `json:"-"`
+	HTTPTimeout                  time.Duration    `json:"-"`
+	PeerPollingInterval          time.Duration    `json:"-"`
+	PeerOptimistic               bool             `json:"peer_optimistic"`
+	PeerOptimisticQuorumMin      int              `json:"peer_optimistic_quorum_min"`
+	MaxEvents                    uint64           `json:"max_events"`
+	MaxStatHistory               uint64           `json:"max_stat_history"`
+	MaxHealthHistory             uint64           `json:"max_health_history"`
+	HealthFlushInterval          time.Duration    `json:"-"`
+	StatFlushInterval            time.Duration    `json:"-"`
+	StatBufferInterval           time.Duration    `json:"-"`
+	LogLocationError             string           `json:"log_location_error"`
+	LogLocationWarning           string           `json:"log_location_warning"`
+	LogLocationInfo              string           `json:"log_location_info"`
+	LogLocationDebug             string           `json:"log_location_debug"`
+	LogLocationEvent             string           `json:"log_location_event"`
+	ServeReadTimeout             time.Duration    `json:"-"`
+	ServeWriteTimeout            time.Duration    `json:"-"`
+	HealthToStatRatio            uint64           `json:"health_to_stat_ratio"`
+	StaticFileDir                string           `json:"static_file_dir"`
+	CRConfigHistoryCount         uint64           `json:"crconfig_history_count"`
+	TrafficOpsMinRetryInterval   time.Duration    `json:"-"`
+	TrafficOpsMaxRetryInterval   time.Duration    `json:"-"`
+	CRConfigBackupFile           string           `json:"crconfig_backup_file"`
+	TMConfigBackupFile           string           `json:"tmconfig_backup_file"`
+	TrafficOpsDiskRetryMax       uint64           `json:"-"`
+	CachePollingProtocol         PollingProtocol  `json:"cache_polling_protocol"`
+	PeerPollingProtocol          PollingProtocol  `json:"peer_polling_protocol"`
+	HTTPPollingFormat            string           `json:"http_polling_format"`
+	PrometheusStats              PrometheusStats  `json:"prometheus_stats"`
+	SyntheticProbeInterval       time.Duration    `json:"-"`
+	SyntheticProbes              []SyntheticProbe `json:"synthetic_probes"`
+	HistoryFile                  string           `json:"history_file"`
+	HistoryRetention             time.Duration    `json:"-"`
+	HistorySampleInterval        time.Duration    `json:"-"`
+	HistoryStats                 []string         `json:"history_stats"`
+	PeerConsensus                PeerConsensus    `json:"peer_consensus"`
+}
+
+// SyntheticProbeType is the kind of check a SyntheticProbe performs.
+type SyntheticProbeType string
+
+const (
+	// SyntheticProbeTypeTCP checks that a TCP connection can be established.
+	SyntheticProbeTypeTCP = SyntheticProbeType("tcp")
+	// SyntheticProbeTypeTLS checks that a TLS handshake succeeds, and that the
+	// certificate presented by the cache server isn't about to expire.
+	SyntheticProbeTypeTLS = SyntheticProbeType("tls")
+	// SyntheticProbeTypeHTTP checks that an HTTP GET request returns the
+	// expected status code and body.
+	SyntheticProbeTypeHTTP = SyntheticProbeType("http")
+)
+
+// SyntheticProbe is an active check performed against each cache server, in
+// addition to polling its health and stats. A cache server failing any of its
+// probes is marked unavailable.
+type SyntheticProbe struct {
+	// Name uniquely identifies the probe. It is used in the names of the
+	// probe's statistics, e.g. "probe.<name>.latency_ms".
+	Name string             `json:"name"`
+	Type SyntheticProbeType `json:"type"`
+	// Port is the port to connect to on the cache server. If zero, 80 is used
+	// for HTTP probes without TLS, and 443 otherwise.
+	Port int `json:"port"`
+	// TimeoutMs is the time allowed for the probe to complete. If zero, the
+	// http_timeout_ms of the Traffic Monitor is used.
+	TimeoutMs uint64 `json:"timeout_ms"`
+	// DeliveryService is the XMLID of a Delivery Service. If set, the probe is
+	// only performed on cache servers assigned to it.
+	DeliveryService string `json:"delivery_service"`
+	// Host is the Host header of HTTP probes, and the server name of TLS
+	// handshakes.
+	Host string `json:"host"`
+	// TLS makes HTTP probes use HTTPS.
+	TLS  bool   `json:"tls"`
+	Path string `json:"path"`
+	// ExpectedStatus is the status code HTTP probes must return. If zero, 200
+	// is expected.
+	ExpectedStatus int `json:"expected_status"`
+	// BodyRegex, if set, must match the body of the response to HTTP probes.
+	BodyRegex string `json:"body_regex"`
+	// CertExpiryDays is the minimum number of days before the certificate
+	// presented during TLS handshakes expires. If zero, any unexpired
+	// certificate is accepted.
+	CertExpiryDays float64 `json:"cert_expiry_days"`
+}
+
+// Validate returns an error if the probe is missing required fields, or has
+// fields that don't apply to its Type.
+func (p SyntheticProbe) Validate() error {
+	if p.Name == "" {
+		return errors.New("synthetic probe missing name")
+	}
+	switch p.Type {
+	case SyntheticProbeTypeTCP, SyntheticProbeTypeTLS:
+	case SyntheticProbeTypeHTTP:
+		if p.BodyRegex != "" {
+			if _, err := regexp.Compile(p.BodyRegex); err != nil {
+				return errors.New("synthetic probe '" + p.Name + "' has an invalid body_regex: " + err.Error())
+			}
+		}
+	default:
+		return errors.New("synthetic probe '" + p.Name + "' has invalid type '" + string(p.Type) + "'")
+	}
+	if p.Port < 0 || p.Port > 65535 {
+		return errors.New("synthetic probe '" + p.Name + "' has an invalid port")
+	}
+	return nil
+}
+
+// PeerPolicy is how the availability of a cache server as polled by this
+// Traffic Monitor is combined with its availability as polled by its peers.
+type PeerPolicy string
+
+const (
+	// PeerPolicyOptimistic marks a cache server available if this Traffic
+	// Monitor or any reachable peer finds it available.
+	PeerPolicyOptimistic = PeerPolicy("optimistic")
+	// PeerPolicyPessimistic marks a cache server unavailable if this Traffic
+	// Monitor or any reachable peer finds it unavailable.
+	PeerPolicyPessimistic = PeerPolicy("pessimistic")
+	// PeerPolicyMajority marks a cache server available if most of this
+	// Traffic Monitor and its reachable peers find it available.
+	PeerPolicyMajority = PeerPolicy("majority")
+	// PeerPolicyWeightedLocation is PeerPolicyMajority, with each Traffic
+	// Monitor's vote weighted by the weight of its location (Cache Group).
+	PeerPolicyWeightedLocation = PeerPolicy("weighted-location")
+	// PeerPolicyWeightedLatency is PeerPolicyMajority, with each peer's vote
+	// weighted down as the time to poll it grows.
+	PeerPolicyWeightedLatency = PeerPolicy("weighted-latency")
+)
+
+// Valid returns whether the policy is one of the known policies.
+func (p PeerPolicy) Valid() bool {
+	switch p {
+	case PeerPolicyOptimistic, PeerPolicyPessimistic, PeerPolicyMajority, PeerPolicyWeightedLocation, PeerPolicyWeightedLatency:
+		return true
+	}
+	return false
+}
+
+// PeerConsensus configures how the states of cache servers are combined with
+// the states polled by peer Traffic Monitors.
+type PeerConsensus struct {
+	Policy PeerPolicy `json:"policy"`
+	// CacheGroupPolicies overrides the Policy for cache servers in the given
+	// Cache Groups.
+	CacheGroupPolicies map[string]PeerPolicy `json:"cachegroup_policies"`
+	// LocationWeights are the weights of the votes of Traffic Monitors in the
+	// given locations (Cache Groups), for the weighted-location policy.
+	LocationWeights map[string]float64 `json:"location_weights"`
+	// DefaultWeight is the weight of the votes of Traffic Monitors in locations
+	// not in LocationWeights.
+	DefaultWeight float64 `json:"default_weight"`
+	// LatencyReferenceMs is the poll time up to which peers have a full weight
+	// of 1 for the weighted-latency policy. Slower peers have a weight of
+	// LatencyReferenceMs divided by their poll time.
+	LatencyReferenceMs uint64 `json:"latency_reference_ms"`
+}
+
+// DefaultPeerConsensus combines states optimistically, as Traffic Monitor
+// always has.
+var DefaultPeerConsensus = PeerConsensus{
+	Policy:             PeerPolicyOptimistic,
+	DefaultWeight:      1,
+	LatencyReferenceMs: 100,
+}
+
+// CacheGroupPolicy returns the policy for cache servers in the given Cache
+// Group.
+func (c PeerConsensus) CacheGroupPolicy(cacheGroup string) PeerPolicy {
+	if policy, ok := c.CacheGroupPolicies[cacheGroup]; ok {
+		return policy
+	}
+	return c.Policy
+}
+
+// Validate returns an error if any policy is unknown, any weight is negative,
+// or the latency reference is zero.
+func (c PeerConsensus) Validate() error {
+	if !c.Policy.Valid() {
+		return errors.New("peer consensus has invalid policy '" + string(c.Policy) + "'")
+	}
+	for cacheGroup, policy := range c.CacheGroupPolicies {
+		if !policy.Valid() {
+			return errors.New("peer consensus has invalid policy '" + string(policy) + "' for cache group '" + cacheGroup + "'")
+		}
+	}
+	for location, weight := range c.LocationWeights {
+		if weight < 0 {
+			return errors.New("peer consensus has negative weight for location '" + location + "'")
+		}
+	}
+	if c.DefaultWeight < 0 {
+		return errors.New("peer consensus has negative default_weight")
+	}
+	if c.LatencyReferenceMs == 0 {
+		return errors.New("peer consensus has zero latency_reference_ms")
+	}
+	return nil
+}
+
+// PrometheusStats maps the metrics and labels served by caches whose stats
+// are polled in the "prometheus" format onto the statistics Traffic Monitor
+// needs. Metrics with an empty name are ignored.
+type PrometheusStats struct {
+	// LoadavgOneMetric, LoadavgFiveMetric, and LoadavgFifteenMetric are the
+	// metrics holding the cache server's one, five, and fifteen minute
+	// "loadavg". Only LoadavgOneMetric is required.
+	LoadavgOneMetric     string `json:"loadavg_one_metric"`
+	LoadavgFiveMetric    string `json:"loadavg_five_metric"`
+	LoadavgFifteenMetric string `json:"loadavg_fifteen_metric"`
+	// InterfaceLabel is the label holding the network interface name of the
+	// interface metrics.
+	InterfaceLabel          string `json:"interface_label"`
+	InterfaceBytesInMetric  string `json:"interface_bytes_in_metric"`
+	InterfaceBytesOutMetric string `json:"interface_bytes_out_metric"`
+	InterfaceSpeedMetric    string `json:"interface_speed_metric"`
+	// InterfaceSpeedMultiplier converts the value of InterfaceSpeedMetric to
+	// megabits per second, the unit of interface speeds in Traffic Monitor.
+	InterfaceSpeedMultiplier float64 `json:"interface_speed_multiplier"`
+	// DeliveryServiceLabel is the label holding the Delivery Service of the
+	// Delivery Service metrics, as either its XMLID or the FQDN of a remap.
+	DeliveryServiceLabel string `json:"delivery_service_label"`
+	DSInBytesMetric      string `json:"ds_in_bytes_metric"`
+	DSOutBytesMetric     string `json:"ds_out_bytes_metric"`
+	// DSResponsesMetric is the count of responses for a Delivery Service, by
+	// the response code (e.g. "200" or "2xx") in StatusCodeLabel.
+	DSResponsesMetric string `json:"ds_responses_metric"`
+	StatusCodeLabel   string `json:"status_code_label"`
+	// AvailableMetric optionally reports whether the cache server is available;
+	// a value of 0 marks it unavailable.
+	AvailableMetric string `json:"available_metric"`
+}
+
+// DefaultPrometheusStats maps the metrics of the Prometheus node_exporter onto
+// the system statistics, and metrics with generic names onto Delivery Service
+// statistics.
+var DefaultPrometheusStats = PrometheusStats{
+	LoadavgOneMetric:         "node_load1",
+	LoadavgFiveMetric:        "node_load5",
+	LoadavgFifteenMetric:     "node_load15",
+	InterfaceLabel:           "device",
+	InterfaceBytesInMetric:   "node_network_receive_bytes_total",
+	InterfaceBytesOutMetric:  "node_network_transmit_bytes_total",
+	InterfaceSpeedMetric:     "node_network_speed_bytes",
+	InterfaceSpeedMultiplier: 8.0 / 1000000.0,
+	DeliveryServiceLabel:     "deliveryservice",
+	DSInBytesMetric:          "deliveryservice_in_bytes_total",
+	DSOutBytesMetric:         "deliveryservice_out_bytes_total",
+	DSResponsesMetric:        "deliveryservice_responses_total",
+	StatusCodeLabel:          "code",
 }
 
 func (c Config) ErrorLog() log.LogLocation   { return log.LogLocation(c.LogLocationError) }
@@ -169,6 +393,11 @@ var DefaultConfig = Config{
 	CachePollingProtocol:         Both,
 	PeerPollingProtocol:          Both,
 	HTTPPollingFormat:            HTTPPollingFormat,
+	PrometheusStats:              DefaultPrometheusStats,
+	SyntheticProbeInterval:       10 * time.Second,
+	HistoryRetention:             7 * 24 * time.Hour,
+	HistorySampleInterval:        time.Minute,
+	PeerConsensus:                DefaultPeerConsensus,
 }
 
 // MarshalJSON marshals custom millisecond durations. Aliasing inspired by http://choly.ca/post/go-json-marshalling/
@@ -188,6 +417,9 @@ func (c *Config) MarshalJSON() ([]byte, error) {
 		StatBufferIntervalMs           uint64 `json:"stat_buffer_interval_ms"`
 		ServeReadTimeoutMs             uint64 `json:"serve_read_timeout_ms"`
 		ServeWriteTimeoutMs            uint64 `json:"serve_write_timeout_ms"`
+		SyntheticProbeIntervalMs       uint64 `json:"synthetic_probe_interval_ms"`
+		HistoryRetentionMs             uint64 `json:"history_retention_ms"`
+		HistorySampleIntervalMs        uint64 `json:"history_sample_interval_ms"`
 		*Alias
 	}{
 		CacheHealthPollingIntervalMs:   uint64(c.CacheHealthPollingInterval / time.Millisecond),
@@ -200,6 +432,9 @@ func (c *Config) MarshalJSON() ([]byte, error) {
 		HealthFlushIntervalMs:          uint64(c.HealthFlushInterval / time.Millisecond),
 		StatFlushIntervalMs:            uint64(c.StatFlushInterval / time.Millisecond),
 		StatBufferIntervalMs:           uint64(c.StatBufferInterval / time.Millisecond),
+		SyntheticProbeIntervalMs:       uint64(c.SyntheticProbeInterval / time.Millisecond),
+		HistoryRetentionMs:             uint64(c.HistoryRetention / time.Millisecond),
+		HistorySampleIntervalMs:        uint64(c.HistorySampleInterval / time.Millisecond),
 		Alias:                          (*Alias)(c),
 	})
 }
@@ -226,6 +461,9 @@ func (c *Config) UnmarshalJSON(data []byte) error {
 		CRConfigBackupFile             *string `json:"crconfig_backup_file"`
 		TMConfigBackupFile             *string `json:"tmconfig_backup_file"`
 		HTTPPollingFormat              *string `json:"http_polling_format"`
+		SyntheticProbeIntervalMs       *uint64 `json:"synthetic_probe_interval_ms"`
+		HistoryRetentionMs             *uint64 `json:"history_retention_ms"`
+		HistorySampleIntervalMs        *uint64 `json:"history_sample_interval_ms"`
 		*Alias
 	}{
 		Alias: (*Alias)(c),
@@ -289,6 +527,28 @@ func (c *Config) UnmarshalJSON(data []byte) error {
 	if aux.HTTPPollingFormat != nil {
 		c.HTTPPollingFormat = *aux.HTTPPollingFormat
 	}
+	if aux.SyntheticProbeIntervalMs != nil {
+		c.SyntheticProbeInterval = time.Duration(*aux.SyntheticProbeIntervalMs) * time.Millisecond
+	}
+	if aux.HistoryRetentionMs != nil {
+		c.HistoryRetention = time.Duration(*aux.HistoryRetentionMs) * time.Millisecond
+	}
+	if aux.HistorySampleIntervalMs != nil {
+		c.HistorySampleInterval = time.Duration(*aux.HistorySampleIntervalMs) * time.Millisecond
+	}
+	if err := c.PeerConsensus.Validate(); err != nil {
+		return err
+	}
+	names := map[string]struct{}{}
+	for _, probe := range c.SyntheticProbes {
+		if err := probe.Validate(); err != nil {
+			return err
+		}
+		if _, ok := names[probe.Name]; ok {
+			return errors.New("duplicate synthetic probe name '" + probe.Name + "'")
+		}
+		names[probe.Name] = struct{}{}
+	}
 	return nil
 }
 
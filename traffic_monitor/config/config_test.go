@@ -21,6 +21,7 @@ package config
 
 import (
 	"testing"
+	"time"
 )
 
 const exampleTMConfig = `
@@ -71,3 +72,124 @@ func TestLoggingConfig(t *testing.T) {
 		t.Errorf("debug log location - expected: %s, actual: %s\n", c.LogLocationDebug, string(c.DebugLog()))
 	}
 }
+
+func TestPrometheusStatsConfig(t *testing.T) {
+	c, err := LoadBytes([]byte(exampleTMConfig))
+	if err != nil {
+		t.Fatalf("loading config bytes - expected: no error, actual: %v", err)
+	}
+	if c.PrometheusStats != DefaultPrometheusStats {
+		t.Errorf("prometheus stats - expected: defaults %+v, actual: %+v\n", DefaultPrometheusStats, c.PrometheusStats)
+	}
+
+	c, err = LoadBytes([]byte(`{"prometheus_stats": {"loadavg_one_metric": "ats_load_one", "available_metric": "ats_up"}}`))
+	if err != nil {
+		t.Fatalf("loading config bytes - expected: no error, actual: %v", err)
+	}
+	expected := DefaultPrometheusStats
+	expected.LoadavgOneMetric = "ats_load_one"
+	expected.AvailableMetric = "ats_up"
+	if c.PrometheusStats != expected {
+		t.Errorf("prometheus stats - expected: %+v, actual: %+v\n", expected, c.PrometheusStats)
+	}
+}
+
+func TestSyntheticProbesConfig(t *testing.T) {
+	c, err := LoadBytes([]byte(`{
+		"synthetic_probe_interval_ms": 30000,
+		"synthetic_probes": [
+			{"name": "https", "type": "tls", "cert_expiry_days": 14},
+			{"name": "origin", "type": "http", "delivery_service": "demo1", "host": "demo1.example.test", "path": "/health", "body_regex": "^OK"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("loading config bytes - expected: no error, actual: %v", err)
+	}
+	if c.SyntheticProbeInterval != 30*time.Second {
+		t.Errorf("synthetic probe interval - expected: 30s, actual: %v", c.SyntheticProbeInterval)
+	}
+	if len(c.SyntheticProbes) != 2 {
+		t.Fatalf("synthetic probes - expected: 2, actual: %d", len(c.SyntheticProbes))
+	}
+	if c.SyntheticProbes[1].DeliveryService != "demo1" || c.SyntheticProbes[1].Path != "/health" {
+		t.Errorf("synthetic probe - expected: delivery service 'demo1' path '/health', actual: %+v", c.SyntheticProbes[1])
+	}
+
+	invalid := map[string]string{
+		"missing name":    `{"synthetic_probes": [{"type": "tcp"}]}`,
+		"invalid type":    `{"synthetic_probes": [{"name": "a", "type": "udp"}]}`,
+		"invalid regex":   `{"synthetic_probes": [{"name": "a", "type": "http", "body_regex": "("}]}`,
+		"invalid port":    `{"synthetic_probes": [{"name": "a", "type": "tcp", "port": 65536}]}`,
+		"duplicate names": `{"synthetic_probes": [{"name": "a", "type": "tcp"}, {"name": "a", "type": "tls"}]}`,
+	}
+	for name, cfg := range invalid {
+		if _, err := LoadBytes([]byte(cfg)); err == nil {
+			t.Errorf("loading config with %s - expected: error, actual: nil", name)
+		}
+	}
+}
+
+func TestHistoryConfig(t *testing.T) {
+	c, err := LoadBytes([]byte(exampleTMConfig))
+	if err != nil {
+		t.Fatalf("loading config bytes - expected: no error, actual: %v", err)
+	}
+	if c.HistoryFile != "" || c.HistoryRetention != DefaultConfig.HistoryRetention || c.HistorySampleInterval != DefaultConfig.HistorySampleInterval {
+		t.Errorf("history - expected: disabled with default retention and sample interval, actual: file '%s' retention %v sample interval %v", c.HistoryFile, c.HistoryRetention, c.HistorySampleInterval)
+	}
+
+	c, err = LoadBytes([]byte(`{
+		"history_file": "/var/lib/traffic_monitor/history.db",
+		"history_retention_ms": 86400000,
+		"history_sample_interval_ms": 300000,
+		"history_stats": ["proxy.process.http.current_client_connections"]
+	}`))
+	if err != nil {
+		t.Fatalf("loading config bytes - expected: no error, actual: %v", err)
+	}
+	if c.HistoryFile != "/var/lib/traffic_monitor/history.db" {
+		t.Errorf("history file - expected: '/var/lib/traffic_monitor/history.db', actual: '%s'", c.HistoryFile)
+	}
+	if c.HistoryRetention != 24*time.Hour {
+		t.Errorf("history retention - expected: 24h, actual: %v", c.HistoryRetention)
+	}
+	if c.HistorySampleInterval != 5*time.Minute {
+		t.Errorf("history sample interval - expected: 5m, actual: %v", c.HistorySampleInterval)
+	}
+	if len(c.HistoryStats) != 1 || c.HistoryStats[0] != "proxy.process.http.current_client_connections" {
+		t.Errorf("history stats - expected: [proxy.process.http.current_client_connections], actual: %v", c.HistoryStats)
+	}
+}
+
+func TestPeerConsensusConfig(t *testing.T) {
+	c, err := LoadBytes([]byte(exampleTMConfig))
+	if err != nil {
+		t.Fatalf("loading config bytes - expected: no error, actual: %v", err)
+	}
+	if c.PeerConsensus.Policy != PeerPolicyOptimistic {
+		t.Errorf("peer consensus policy - expected: optimistic, actual: %s", c.PeerConsensus.Policy)
+	}
+
+	c, err = LoadBytes([]byte(`{"peer_consensus": {"policy": "majority", "cachegroup_policies": {"cg-edge-east": "weighted-location"}, "location_weights": {"east": 2}}}`))
+	if err != nil {
+		t.Fatalf("loading config bytes - expected: no error, actual: %v", err)
+	}
+	if c.PeerConsensus.CacheGroupPolicy("cg-edge-west") != PeerPolicyMajority || c.PeerConsensus.CacheGroupPolicy("cg-edge-east") != PeerPolicyWeightedLocation {
+		t.Errorf("peer consensus cache group policies - expected: majority, with weighted-location for cg-edge-east, actual: %+v", c.PeerConsensus)
+	}
+	if c.PeerConsensus.DefaultWeight != 1 || c.PeerConsensus.LatencyReferenceMs != 100 {
+		t.Errorf("peer consensus - expected: default weight and latency reference, actual: %+v", c.PeerConsensus)
+	}
+
+	invalid := map[string]string{
+		"invalid policy":             `{"peer_consensus": {"policy": "random"}}`,
+		"invalid cache group policy": `{"peer_consensus": {"cachegroup_policies": {"cg": "random"}}}`,
+		"negative weight":            `{"peer_consensus": {"location_weights": {"east": -1}}}`,
+		"zero latency reference":     `{"peer_consensus": {"policy": "weighted-latency", "latency_reference_ms": 0}}`,
+	}
+	for name, cfg := range invalid {
+		if _, err := LoadBytes([]byte(cfg)); err == nil {
+			t.Errorf("loading config with %s - expected: error, actual: nil", name)
+		}
+	}
+}
@@ -243,6 +243,10 @@ func createCacheStatuses(
 			log.Infof("Error getting cache %v health span: %v\n", cacheName, err)
 		}
 
+		// report availability including the results of synthetic probes
+		cacheStatus.Why = cacheStatus.Reason()
+		cacheStatus.Available = cacheStatus.CombinedAvailable()
+
 		if serverInfo.ServerStatus == tc.CacheStatusOnline.String() {
 			cacheStatus.Why = "ONLINE - available"
 			cacheStatus.Available.IPv4 = serverInfo.IPv4() != ""
@@ -293,16 +297,17 @@ func cacheStatusAndPoller(server string, serverInfo tc.TrafficServer, localCache
 	}
 
 	var statusStr string
-	if status.Why == "" {
+	if why := status.Reason(); why == "" {
 		if status.ProcessedAvailable {
 			statusStr = status.Status + " - available"
 		} else {
 			statusStr = status.Status + " - unavailable"
 		}
 	} else {
-		statusStr = status.Why
+		statusStr = why
 	}
-	return statusStr, status.Poller, status.Available.IPv4, status.Available.IPv6, status.ProcessedAvailable
+	available := status.CombinedAvailable()
+	return statusStr, status.Poller, available.IPv4, available.IPv6, status.ProcessedAvailable
 }
 
 func createCacheConnections(statResultHistory threadsafe.ResultStatHistory) map[string]int64 {
@@ -33,6 +33,7 @@ import (
 	"github.com/apache/trafficcontrol/lib/go-rfc"
 	"github.com/apache/trafficcontrol/traffic_monitor/config"
 	"github.com/apache/trafficcontrol/traffic_monitor/health"
+	"github.com/apache/trafficcontrol/traffic_monitor/history"
 	"github.com/apache/trafficcontrol/traffic_monitor/peer"
 	"github.com/apache/trafficcontrol/traffic_monitor/threadsafe"
 	"github.com/apache/trafficcontrol/traffic_monitor/todata"
@@ -63,6 +64,9 @@ func MakeDispatchMap(
 	lastStats threadsafe.LastStats,
 	unpolledCaches threadsafe.UnpolledCaches,
 	monitorConfig threadsafe.TrafficMonitorConfigMap,
+	historyStore *history.Store,
+	pollCounts threadsafe.PollCounts,
+	explanations peer.ExplanationsThreadsafe,
 ) map[string]http.HandlerFunc {
 
 	// wrap composes all universal wrapper functions. Right now, it's only the UnpolledCheck, but there may be others later. For example, security headers.
@@ -132,6 +136,18 @@ func MakeDispatchMap(
 		"/api/crconfig-history": wrap(WrapErr(errorCount, func() ([]byte, error) {
 			return srvAPICRConfigHist(toSession)
 		}, rfc.ApplicationJSON)),
+		"/api/events": wrap(WrapParams(func(params url.Values, path string) ([]byte, int) {
+			return srvAPIEvents(params, errorCount, path, events, historyStore)
+		}, rfc.ApplicationJSON)),
+		"/api/cache-history": wrap(WrapParams(func(params url.Values, path string) ([]byte, int) {
+			return srvAPICacheHistory(params, errorCount, path, historyStore)
+		}, rfc.ApplicationJSON)),
+		"/metrics": wrap(WrapBytes(func() []byte {
+			return srvMetrics(opsConfig, toSession, toData, localStates, combinedStates, peerStates, healthHistory, statInfoHistory, statMaxKbpses, dsStats, pollCounts, monitorConfig)
+		}, PrometheusContentType)),
+		"/api/peer-consensus": wrap(WrapParams(func(params url.Values, path string) ([]byte, int) {
+			return srvAPIPeerConsensus(errorCount, path, explanations, peerStates)
+		}, rfc.ApplicationJSON)),
 	}
 	return addTrailingSlashEndpoints(dispatchMap)
 }
@@ -0,0 +1,131 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package datareq
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_monitor/health"
+	"github.com/apache/trafficcontrol/traffic_monitor/history"
+	"github.com/apache/trafficcontrol/traffic_monitor/threadsafe"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// APICacheHistory is the stored history of a cache server.
+type APICacheHistory struct {
+	Cache   string                `json:"cache"`
+	Samples []history.CacheSample `json:"samples"`
+}
+
+// parseTimeParam parses a time query parameter, which may be either a Unix
+// timestamp in seconds (like the times of events) or an RFC3339 date.
+func parseTimeParam(params url.Values, name string, defaultTime time.Time) (time.Time, error) {
+	val := params.Get(name)
+	if val == "" {
+		return defaultTime, nil
+	}
+	if unix, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return time.Time{}, errors.New("invalid query parameter " + name + " '" + val + "' - must be a Unix timestamp or RFC3339 date")
+	}
+	return t, nil
+}
+
+// parseTimeRange parses the `since` and `until` query parameters. The range
+// defaults to everything up to now.
+func parseTimeRange(params url.Values) (time.Time, time.Time, error) {
+	since, err := parseTimeParam(params, "since", time.Unix(0, 0))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	// until is exclusive, so the default must include events of this second
+	until, err := parseTimeParam(params, "until", time.Now().Add(time.Second))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if until.Before(since) {
+		return time.Time{}, time.Time{}, errors.New("invalid query parameters - until is before since")
+	}
+	return since, until, nil
+}
+
+// srvAPIEvents serves the events from `since` up to `until`, optionally only
+// those of the cache named by `cache`, newest first. Without a history store,
+// only the events still in memory are available.
+func srvAPIEvents(params url.Values, errorCount threadsafe.Uint, path string, events health.ThreadsafeEvents, historyStore *history.Store) ([]byte, int) {
+	since, until, err := parseTimeRange(params)
+	if err != nil {
+		HandleErr(errorCount, path, err)
+		return []byte(err.Error()), http.StatusBadRequest
+	}
+	cacheName := params.Get("cache")
+
+	filtered := []health.Event{}
+	if historyStore != nil {
+		if filtered, err = historyStore.Events(since, until, cacheName); err != nil {
+			return WrapErrCode(errorCount, path, nil, err)
+		}
+	} else {
+		for _, e := range events.Get() {
+			t := time.Time(e.Time)
+			if t.Before(since) || !t.Before(until) || (cacheName != "" && e.Hostname != cacheName) {
+				continue
+			}
+			filtered = append(filtered, e)
+		}
+	}
+
+	json := jsoniter.ConfigFastest
+	bytes, err := json.Marshal(JSONEvents{Events: filtered})
+	return WrapErrCode(errorCount, path, bytes, err)
+}
+
+// srvAPICacheHistory serves the stored history of the cache named by the path
+// argument, from `since` up to `until`, oldest first.
+func srvAPICacheHistory(params url.Values, errorCount threadsafe.Uint, path string, historyStore *history.Store) ([]byte, int) {
+	if historyStore == nil {
+		return []byte("Persistent history is not enabled; set history_file in the Traffic Monitor configuration."), http.StatusNotFound
+	}
+	cacheName := getPathArgument(path)
+	if cacheName == "" {
+		return []byte("missing cache name - request /api/cache-history/{name}"), http.StatusBadRequest
+	}
+	since, until, err := parseTimeRange(params)
+	if err != nil {
+		HandleErr(errorCount, path, err)
+		return []byte(err.Error()), http.StatusBadRequest
+	}
+
+	samples, err := historyStore.CacheHistory(cacheName, since, until)
+	if err != nil {
+		return WrapErrCode(errorCount, path, nil, err)
+	}
+	json := jsoniter.ConfigFastest
+	bytes, err := json.Marshal(APICacheHistory{Cache: cacheName, Samples: samples})
+	return WrapErrCode(errorCount, path, bytes, err)
+}
@@ -0,0 +1,58 @@
+package datareq
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseTimeRange(t *testing.T) {
+	since, until, err := parseTimeRange(url.Values{"since": {"1600000000"}, "until": {"2020-09-13T13:00:00Z"}})
+	if err != nil {
+		t.Fatalf("parsing time range - expected: no error, actual: %v", err)
+	}
+	if !since.Equal(time.Unix(1600000000, 0)) {
+		t.Errorf("since - expected: %v, actual: %v", time.Unix(1600000000, 0), since)
+	}
+	if !until.Equal(time.Date(2020, 9, 13, 13, 0, 0, 0, time.UTC)) {
+		t.Errorf("until - expected: 2020-09-13T13:00:00Z, actual: %v", until)
+	}
+
+	since, until, err = parseTimeRange(url.Values{})
+	if err != nil {
+		t.Fatalf("parsing empty time range - expected: no error, actual: %v", err)
+	}
+	if since.Unix() != 0 || until.Before(time.Now()) {
+		t.Errorf("default time range - expected: from the epoch to now, actual: %v to %v", since, until)
+	}
+
+	invalid := map[string]url.Values{
+		"invalid since":      {"since": {"yesterday"}},
+		"invalid until":      {"until": {"2020-09-13"}},
+		"until before since": {"since": {"1600000000"}, "until": {"1500000000"}},
+	}
+	for name, params := range invalid {
+		if _, _, err := parseTimeRange(params); err == nil {
+			t.Errorf("parsing time range with %s - expected: error, actual: nil", name)
+		}
+	}
+}
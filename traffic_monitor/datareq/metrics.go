@@ -0,0 +1,348 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package datareq
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_monitor/cache"
+	"github.com/apache/trafficcontrol/traffic_monitor/dsdata"
+	"github.com/apache/trafficcontrol/traffic_monitor/peer"
+	"github.com/apache/trafficcontrol/traffic_monitor/threadsafe"
+	"github.com/apache/trafficcontrol/traffic_monitor/todata"
+	"github.com/apache/trafficcontrol/traffic_monitor/towrap"
+)
+
+// PrometheusContentType is the Content-Type of the Prometheus text exposition
+// format, served by the /metrics endpoint.
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricsData is the state of Traffic Monitor exported as Prometheus metrics.
+type metricsData struct {
+	CDN               string
+	ToData            todata.TOData
+	LocalStates       tc.CRStates
+	CombinedStates    tc.CRStates
+	HealthHistory     cache.ResultHistory
+	StatInfoHistory   cache.ResultInfoHistory
+	MaxKbpses         cache.Kbpses
+	DSStats           dsdata.StatsReadonly
+	PollCounts        map[string]map[tc.CacheName]threadsafe.PollCount
+	PeersAvailable    map[tc.TrafficMonitorName]bool
+	CRConfigStats     *tc.CRConfigStats
+	MonitorConfigTime time.Time
+	Now               time.Time
+}
+
+func srvMetrics(
+	opsConfig threadsafe.OpsConfig,
+	toSession towrap.TrafficOpsSessionThreadsafe,
+	toData todata.TODataThreadsafe,
+	localStates peer.CRStatesThreadsafe,
+	combinedStates peer.CRStatesThreadsafe,
+	peerStates peer.CRStatesPeersThreadsafe,
+	healthHistory threadsafe.ResultHistory,
+	statInfoHistory threadsafe.ResultInfoHistory,
+	statMaxKbpses threadsafe.CacheKbpses,
+	dsStats threadsafe.DSStatsReader,
+	pollCounts threadsafe.PollCounts,
+	monitorConfig threadsafe.TrafficMonitorConfigMap,
+) []byte {
+	cdn := opsConfig.Get().CdnName
+	crConfigStats := (*tc.CRConfigStats)(nil)
+	if toSession.Initialized() && cdn != "" {
+		_, crConfigStats = toSession.LastCRConfigStats(cdn)
+	}
+
+	peersAvailable := map[tc.TrafficMonitorName]bool{}
+	for peerName := range peerStates.GetPeersOnline() {
+		peersAvailable[peerName] = peerStates.GetPeerAvailability(peerName)
+	}
+
+	return createMetrics(metricsData{
+		CDN:               cdn,
+		ToData:            toData.Get(),
+		LocalStates:       localStates.Get(),
+		CombinedStates:    combinedStates.Get(),
+		HealthHistory:     healthHistory.Get(),
+		StatInfoHistory:   statInfoHistory.Get(),
+		MaxKbpses:         statMaxKbpses.Get(),
+		DSStats:           dsStats.Get(),
+		PollCounts:        pollCounts.Get(),
+		PeersAvailable:    peersAvailable,
+		CRConfigStats:     crConfigStats,
+		MonitorConfigTime: monitorConfig.Time(),
+		Now:               time.Now(),
+	})
+}
+
+// metricsWriter writes metrics in the Prometheus text exposition format.
+type metricsWriter struct {
+	buf *bytes.Buffer
+}
+
+// family writes the HELP and TYPE lines of a metric. All samples of the metric
+// must be written immediately after.
+func (w metricsWriter) family(name string, metricType string, help string) {
+	w.buf.WriteString("# HELP " + name + " " + help + "\n")
+	w.buf.WriteString("# TYPE " + name + " " + metricType + "\n")
+}
+
+// sample writes a sample of a metric. Labels are given as alternating names and
+// values.
+func (w metricsWriter) sample(name string, val float64, labels ...string) {
+	w.buf.WriteString(name)
+	if len(labels) > 0 {
+		w.buf.WriteString("{")
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.buf.WriteString(",")
+			}
+			w.buf.WriteString(labels[i] + `="` + escapeLabelValue(labels[i+1]) + `"`)
+		}
+		w.buf.WriteString("}")
+	}
+	w.buf.WriteString(" " + strconv.FormatFloat(val, 'g', -1, 64) + "\n")
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
+
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// createMetrics returns the given Traffic Monitor state as Prometheus metrics.
+// Samples are sorted by their labels, so the output is stable between scrapes.
+func createMetrics(data metricsData) []byte {
+	w := metricsWriter{buf: &bytes.Buffer{}}
+
+	cacheNames := make([]string, 0, len(data.LocalStates.Caches))
+	for cacheName := range data.LocalStates.Caches {
+		cacheNames = append(cacheNames, string(cacheName))
+	}
+	sort.Strings(cacheNames)
+	cacheLabels := func(cacheName string, labels ...string) []string {
+		return append([]string{
+			"cdn", data.CDN,
+			"cache", cacheName,
+			"cachegroup", string(data.ToData.ServerCachegroups[tc.CacheName(cacheName)]),
+			"type", string(data.ToData.ServerTypes[tc.CacheName(cacheName)]),
+		}, labels...)
+	}
+
+	w.family("traffic_monitor_cache_available", "gauge", "Whether the cache is available, as determined by this Traffic Monitor.")
+	for _, cacheName := range cacheNames {
+		w.sample("traffic_monitor_cache_available", boolMetric(data.LocalStates.Caches[tc.CacheName(cacheName)].IsAvailable), cacheLabels(cacheName)...)
+	}
+
+	w.family("traffic_monitor_cache_combined_available", "gauge", "Whether the cache is available, as combined with the states of peer Traffic Monitors and served to Traffic Router.")
+	for _, cacheName := range cacheNames {
+		w.sample("traffic_monitor_cache_combined_available", boolMetric(data.CombinedStates.Caches[tc.CacheName(cacheName)].IsAvailable), cacheLabels(cacheName)...)
+	}
+
+	w.family("traffic_monitor_cache_interface_bandwidth_kbps", "gauge", "The outgoing bandwidth of the monitored interface of the cache as of the last health poll, in kilobits per second.")
+	for _, cacheName := range cacheNames {
+		results := data.HealthHistory[tc.CacheName(cacheName)]
+		if len(results) == 0 {
+			continue
+		}
+		interfaceNames := make([]string, 0, len(results[0].InterfaceVitals))
+		for interfaceName := range results[0].InterfaceVitals {
+			interfaceNames = append(interfaceNames, interfaceName)
+		}
+		sort.Strings(interfaceNames)
+		for _, interfaceName := range interfaceNames {
+			w.sample("traffic_monitor_cache_interface_bandwidth_kbps", float64(results[0].InterfaceVitals[interfaceName].KbpsOut), cacheLabels(cacheName, "interface", interfaceName)...)
+		}
+	}
+
+	w.family("traffic_monitor_cache_bandwidth_capacity_kbps", "gauge", "The bandwidth capacity of the monitored interfaces of the cache, in kilobits per second.")
+	for _, cacheName := range cacheNames {
+		if maxKbps, ok := data.MaxKbpses[cacheName]; ok {
+			w.sample("traffic_monitor_cache_bandwidth_capacity_kbps", float64(maxKbps), cacheLabels(cacheName)...)
+		}
+	}
+
+	w.family("traffic_monitor_cache_poll_duration_seconds", "gauge", "The time the last poll of the cache took to complete, in seconds.")
+	for _, cacheName := range cacheNames {
+		if results := data.HealthHistory[tc.CacheName(cacheName)]; len(results) > 0 {
+			w.sample("traffic_monitor_cache_poll_duration_seconds", results[0].RequestTime.Seconds(), cacheLabels(cacheName, "poller", "health")...)
+		}
+		if results := data.StatInfoHistory[tc.CacheName(cacheName)]; len(results) > 0 {
+			w.sample("traffic_monitor_cache_poll_duration_seconds", results[0].RequestTime.Seconds(), cacheLabels(cacheName, "poller", "stat")...)
+		}
+	}
+
+	pollers := make([]string, 0, len(data.PollCounts))
+	for poller := range data.PollCounts {
+		pollers = append(pollers, poller)
+	}
+	sort.Strings(pollers)
+
+	w.family("traffic_monitor_cache_polls_total", "counter", "The number of times the cache has been polled.")
+	for _, cacheName := range cacheNames {
+		for _, poller := range pollers {
+			if count, ok := data.PollCounts[poller][tc.CacheName(cacheName)]; ok {
+				w.sample("traffic_monitor_cache_polls_total", float64(count.Polls), cacheLabels(cacheName, "poller", poller)...)
+			}
+		}
+	}
+
+	w.family("traffic_monitor_cache_poll_errors_total", "counter", "The number of polls of the cache which failed.")
+	for _, cacheName := range cacheNames {
+		for _, poller := range pollers {
+			if count, ok := data.PollCounts[poller][tc.CacheName(cacheName)]; ok {
+				w.sample("traffic_monitor_cache_poll_errors_total", float64(count.Errors), cacheLabels(cacheName, "poller", poller)...)
+			}
+		}
+	}
+
+	createDSMetrics(w, data)
+
+	peerNames := make([]string, 0, len(data.PeersAvailable))
+	for peerName := range data.PeersAvailable {
+		peerNames = append(peerNames, string(peerName))
+	}
+	sort.Strings(peerNames)
+
+	w.family("traffic_monitor_peer_available", "gauge", "Whether the peer Traffic Monitor is ONLINE and reachable.")
+	for _, peerName := range peerNames {
+		w.sample("traffic_monitor_peer_available", boolMetric(data.PeersAvailable[tc.TrafficMonitorName(peerName)]), "cdn", data.CDN, "peer", peerName)
+	}
+
+	w.family("traffic_monitor_crconfig_age_seconds", "gauge", "The time since the last CRConfig Snapshot fetched from Traffic Ops was taken, in seconds.")
+	if data.CRConfigStats != nil && data.CRConfigStats.DateUnixSeconds != nil {
+		w.sample("traffic_monitor_crconfig_age_seconds", data.Now.Sub(time.Unix(*data.CRConfigStats.DateUnixSeconds, 0)).Seconds(), "cdn", data.CDN)
+	}
+
+	w.family("traffic_monitor_monitoring_config_age_seconds", "gauge", "The time since the monitoring configuration was last fetched from Traffic Ops, in seconds.")
+	if !data.MonitorConfigTime.IsZero() {
+		w.sample("traffic_monitor_monitoring_config_age_seconds", data.Now.Sub(data.MonitorConfigTime).Seconds(), "cdn", data.CDN)
+	}
+
+	return w.buf.Bytes()
+}
+
+// createDSMetrics writes the metrics of each Delivery Service, in total and by
+// the cache group and type of the caches serving it.
+func createDSMetrics(w metricsWriter, data metricsData) {
+	dsNames := make([]string, 0, len(data.ToData.DeliveryServiceTypes))
+	for dsName := range data.ToData.DeliveryServiceTypes {
+		dsNames = append(dsNames, string(dsName))
+	}
+	sort.Strings(dsNames)
+
+	stats := map[string]*dsdata.Stat{}
+	for _, dsName := range dsNames {
+		if data.DSStats == nil {
+			break
+		}
+		if stat, ok := data.DSStats.Get(tc.DeliveryServiceName(dsName)); ok {
+			stats[dsName] = stat.Copy()
+		}
+	}
+
+	w.family("traffic_monitor_ds_available", "gauge", "Whether the Delivery Service has available caches.")
+	for _, dsName := range dsNames {
+		if state, ok := data.CombinedStates.DeliveryService[tc.DeliveryServiceName(dsName)]; ok {
+			w.sample("traffic_monitor_ds_available", boolMetric(state.IsAvailable), "cdn", data.CDN, "delivery_service", dsName)
+		}
+	}
+
+	w.family("traffic_monitor_ds_tps", "gauge", "The transactions per second served by all caches of the Delivery Service.")
+	for _, dsName := range dsNames {
+		if stat, ok := stats[dsName]; ok {
+			w.sample("traffic_monitor_ds_tps", stat.TotalStats.TpsTotal.Value, "cdn", data.CDN, "delivery_service", dsName)
+		}
+	}
+
+	w.family("traffic_monitor_ds_kbps", "gauge", "The bandwidth served by all caches of the Delivery Service, in kilobits per second.")
+	for _, dsName := range dsNames {
+		if stat, ok := stats[dsName]; ok {
+			w.sample("traffic_monitor_ds_kbps", stat.TotalStats.Kbps.Value, "cdn", data.CDN, "delivery_service", dsName)
+		}
+	}
+
+	w.family("traffic_monitor_ds_cachegroup_tps", "gauge", "The transactions per second served by the caches of the Delivery Service in the cache group.")
+	for _, dsName := range dsNames {
+		if stat, ok := stats[dsName]; ok {
+			for _, cacheGroup := range sortedCacheGroups(stat.CacheGroups) {
+				w.sample("traffic_monitor_ds_cachegroup_tps", stat.CacheGroups[tc.CacheGroupName(cacheGroup)].TpsTotal.Value, "cdn", data.CDN, "delivery_service", dsName, "cachegroup", cacheGroup)
+			}
+		}
+	}
+
+	w.family("traffic_monitor_ds_cachegroup_kbps", "gauge", "The bandwidth served by the caches of the Delivery Service in the cache group, in kilobits per second.")
+	for _, dsName := range dsNames {
+		if stat, ok := stats[dsName]; ok {
+			for _, cacheGroup := range sortedCacheGroups(stat.CacheGroups) {
+				w.sample("traffic_monitor_ds_cachegroup_kbps", stat.CacheGroups[tc.CacheGroupName(cacheGroup)].Kbps.Value, "cdn", data.CDN, "delivery_service", dsName, "cachegroup", cacheGroup)
+			}
+		}
+	}
+
+	w.family("traffic_monitor_ds_type_tps", "gauge", "The transactions per second served by the caches of the Delivery Service of the cache type.")
+	for _, dsName := range dsNames {
+		if stat, ok := stats[dsName]; ok {
+			for _, cacheType := range sortedCacheTypes(stat.Types) {
+				w.sample("traffic_monitor_ds_type_tps", stat.Types[tc.CacheType(cacheType)].TpsTotal.Value, "cdn", data.CDN, "delivery_service", dsName, "type", cacheType)
+			}
+		}
+	}
+
+	w.family("traffic_monitor_ds_type_kbps", "gauge", "The bandwidth served by the caches of the Delivery Service of the cache type, in kilobits per second.")
+	for _, dsName := range dsNames {
+		if stat, ok := stats[dsName]; ok {
+			for _, cacheType := range sortedCacheTypes(stat.Types) {
+				w.sample("traffic_monitor_ds_type_kbps", stat.Types[tc.CacheType(cacheType)].Kbps.Value, "cdn", data.CDN, "delivery_service", dsName, "type", cacheType)
+			}
+		}
+	}
+}
+
+func sortedCacheGroups(m map[tc.CacheGroupName]*dsdata.StatCacheStats) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedCacheTypes(m map[tc.CacheType]*dsdata.StatCacheStats) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
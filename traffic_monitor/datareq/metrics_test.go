@@ -0,0 +1,112 @@
+package datareq
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_monitor/cache"
+	"github.com/apache/trafficcontrol/traffic_monitor/dsdata"
+	"github.com/apache/trafficcontrol/traffic_monitor/threadsafe"
+	"github.com/apache/trafficcontrol/traffic_monitor/todata"
+)
+
+func TestCreateMetrics(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	toData := todata.New()
+	toData.ServerCachegroups["edge0"] = "cg0"
+	toData.ServerCachegroups["edge1"] = "cg1"
+	toData.ServerTypes["edge0"] = "EDGE"
+	toData.ServerTypes["edge1"] = "EDGE"
+	toData.DeliveryServiceTypes["demo1"] = tc.DSTypeCategoryHTTP
+
+	states := tc.NewCRStates()
+	states.Caches["edge0"] = tc.IsAvailable{IsAvailable: true, Ipv4Available: true}
+	states.Caches["edge1"] = tc.IsAvailable{IsAvailable: false}
+	states.DeliveryService["demo1"] = tc.CRStatesDeliveryService{IsAvailable: true}
+
+	dsStats := dsdata.NewStats(1)
+	stat := dsdata.NewStat()
+	stat.TotalStats.TpsTotal.Value = 150
+	stat.TotalStats.Kbps.Value = 2000
+	stat.CacheGroups["cg0"] = &dsdata.StatCacheStats{TpsTotal: dsdata.StatFloat{Value: 100}, Kbps: dsdata.StatFloat{Value: 1500}}
+	stat.CacheGroups["cg1"] = &dsdata.StatCacheStats{TpsTotal: dsdata.StatFloat{Value: 50}, Kbps: dsdata.StatFloat{Value: 500}}
+	stat.Types["EDGE"] = &dsdata.StatCacheStats{TpsTotal: dsdata.StatFloat{Value: 150}, Kbps: dsdata.StatFloat{Value: 2000}}
+	dsStats.DeliveryService["demo1"] = stat
+
+	snapshotTime := now.Add(-90 * time.Second).Unix()
+	pollCounts := threadsafe.NewPollCounts()
+	pollCounts.Add("health", []cache.Result{{ID: "edge0"}, {ID: "edge0"}, {ID: "edge1", Error: errors.New("timeout")}})
+
+	metrics := string(createMetrics(metricsData{
+		CDN:            "cdn0",
+		ToData:         *toData,
+		LocalStates:    states,
+		CombinedStates: states,
+		HealthHistory: cache.ResultHistory{
+			"edge0": {{ID: "edge0", RequestTime: 250 * time.Millisecond, InterfaceVitals: map[string]cache.Vitals{"bond0": {KbpsOut: 1234}}}},
+		},
+		StatInfoHistory:   cache.ResultInfoHistory{},
+		MaxKbpses:         cache.Kbpses{"edge0": 10000000},
+		DSStats:           dsStats,
+		PollCounts:        pollCounts.Get(),
+		PeersAvailable:    map[tc.TrafficMonitorName]bool{"tm1": true, `tm"2`: false},
+		CRConfigStats:     &tc.CRConfigStats{DateUnixSeconds: &snapshotTime},
+		MonitorConfigTime: now.Add(-5 * time.Second),
+		Now:               now,
+	}))
+
+	expected := []string{
+		"# TYPE traffic_monitor_cache_available gauge\n" +
+			`traffic_monitor_cache_available{cdn="cdn0",cache="edge0",cachegroup="cg0",type="EDGE"} 1` + "\n" +
+			`traffic_monitor_cache_available{cdn="cdn0",cache="edge1",cachegroup="cg1",type="EDGE"} 0` + "\n",
+		`traffic_monitor_cache_interface_bandwidth_kbps{cdn="cdn0",cache="edge0",cachegroup="cg0",type="EDGE",interface="bond0"} 1234` + "\n",
+		`traffic_monitor_cache_bandwidth_capacity_kbps{cdn="cdn0",cache="edge0",cachegroup="cg0",type="EDGE"} 1e+07` + "\n",
+		`traffic_monitor_cache_poll_duration_seconds{cdn="cdn0",cache="edge0",cachegroup="cg0",type="EDGE",poller="health"} 0.25` + "\n",
+		`traffic_monitor_cache_polls_total{cdn="cdn0",cache="edge0",cachegroup="cg0",type="EDGE",poller="health"} 2` + "\n",
+		`traffic_monitor_cache_poll_errors_total{cdn="cdn0",cache="edge1",cachegroup="cg1",type="EDGE",poller="health"} 1` + "\n",
+		`traffic_monitor_ds_available{cdn="cdn0",delivery_service="demo1"} 1` + "\n",
+		`traffic_monitor_ds_tps{cdn="cdn0",delivery_service="demo1"} 150` + "\n",
+		`traffic_monitor_ds_kbps{cdn="cdn0",delivery_service="demo1"} 2000` + "\n",
+		`traffic_monitor_ds_cachegroup_tps{cdn="cdn0",delivery_service="demo1",cachegroup="cg0"} 100` + "\n" +
+			`traffic_monitor_ds_cachegroup_tps{cdn="cdn0",delivery_service="demo1",cachegroup="cg1"} 50` + "\n",
+		`traffic_monitor_ds_type_kbps{cdn="cdn0",delivery_service="demo1",type="EDGE"} 2000` + "\n",
+		`traffic_monitor_peer_available{cdn="cdn0",peer="tm\"2"} 0` + "\n" +
+			`traffic_monitor_peer_available{cdn="cdn0",peer="tm1"} 1` + "\n",
+		`traffic_monitor_crconfig_age_seconds{cdn="cdn0"} 90` + "\n",
+		`traffic_monitor_monitoring_config_age_seconds{cdn="cdn0"} 5` + "\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(metrics, e) {
+			t.Errorf("expected metrics to contain:\n%s\nactual:\n%s", e, metrics)
+		}
+	}
+
+	if strings.Contains(metrics, `poller="stat"`) {
+		t.Errorf("expected no stat poller metrics without stat polls, actual:\n%s", metrics)
+	}
+	if strings.Count(metrics, "# TYPE traffic_monitor_cache_available ") != 1 {
+		t.Errorf("expected each metric to be declared once, actual:\n%s", metrics)
+	}
+}
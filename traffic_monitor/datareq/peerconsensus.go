@@ -0,0 +1,62 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package datareq
+
+import (
+	"net/http"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_monitor/peer"
+	"github.com/apache/trafficcontrol/traffic_monitor/threadsafe"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// APIPeerConsensus explains how the combined state of each cache was chosen
+// from the votes of this Traffic Monitor and its peers.
+type APIPeerConsensus struct {
+	// PeersAvailable is the number of peers which are ONLINE and reachable.
+	PeersAvailable int `json:"peersAvailable"`
+	// PeerCount is the number of peers which are ONLINE.
+	PeerCount int `json:"peerCount"`
+	// OptimisticQuorum is whether PeersAvailable is at least the
+	// peer_optimistic_quorum_min, without which combined states aren't served.
+	OptimisticQuorum bool                                        `json:"optimisticQuorum"`
+	Caches           map[tc.CacheName]peer.CacheStateExplanation `json:"caches"`
+}
+
+// srvAPIPeerConsensus serves the explanations of the combined states of all
+// caches, or only of the cache named by the path argument.
+func srvAPIPeerConsensus(errorCount threadsafe.Uint, path string, explanations peer.ExplanationsThreadsafe, peerStates peer.CRStatesPeersThreadsafe) ([]byte, int) {
+	resp := APIPeerConsensus{Caches: explanations.Get()}
+	resp.OptimisticQuorum, resp.PeersAvailable, resp.PeerCount, _ = peerStates.HasOptimisticQuorum()
+
+	if cacheName := tc.CacheName(getPathArgument(path)); cacheName != "" {
+		explanation, ok := resp.Caches[cacheName]
+		if !ok {
+			return []byte("cache '" + string(cacheName) + "' not found"), http.StatusNotFound
+		}
+		resp.Caches = map[tc.CacheName]peer.CacheStateExplanation{cacheName: explanation}
+	}
+
+	json := jsoniter.ConfigFastest
+	bytes, err := json.Marshal(resp)
+	return WrapErrCode(errorCount, path, bytes, err)
+}
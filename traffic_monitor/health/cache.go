@@ -21,6 +21,7 @@ package health
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -46,6 +47,10 @@ const AvailableStr = "available"
 // available to serve traffic.
 const UnavailableStr = "unavailable"
 
+// ProbePollerName is the name of the poller which performs synthetic probes of
+// cache servers.
+const ProbePollerName = "probe"
+
 // GetVitals Gets the vitals to decide health on in the right format
 func GetVitals(newResult *cache.Result, prevResult *cache.Result, mc *tc.TrafficMonitorConfigMap) {
 	if newResult.Error != nil {
@@ -265,9 +270,83 @@ func getProcessAvailableTuple(protocol config.PollingProtocol) func(cache.Availa
 	return func(cache.AvailableTuple, tc.TrafficServer) bool { return false }
 }
 
+// EvalProbes returns whether the given result of the "probe" poller shows the
+// cache server to be available, a string describing the failed probes, and the
+// probe stat whose threshold was exceeded, if any. Only thresholds on probe
+// stats ("probe.<name>.<stat>") are evaluated; the resultStats may be nil, and
+// if so, thresholds aren't checked.
+func EvalProbes(result cache.ResultInfo, resultStats *threadsafe.ResultStatValHistory, mc *tc.TrafficMonitorConfigMap) (bool, string, string) {
+	serverInfo, ok := mc.TrafficServer[result.ID]
+	if !ok {
+		log.Errorf("Cache %v missing from from Traffic Ops Monitor Config - treating as OFFLINE\n", result.ID)
+		return false, "ERROR - server missing in Traffic Ops monitor config", ""
+	}
+	if tc.CacheStatusFromString(serverInfo.ServerStatus) == tc.CacheStatusOnline {
+		return true, "", ""
+	}
+	if result.Error != nil {
+		return false, fmt.Sprintf("probe error: %v", result.Error), ""
+	}
+
+	names := make([]string, 0, len(result.Statistics.Probes))
+	for name := range result.Statistics.Probes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	reasons := []string{}
+	for _, name := range names {
+		if probe := result.Statistics.Probes[name]; !probe.Available {
+			reasons = append(reasons, "probe "+name+" failed: "+probe.Error)
+		}
+	}
+	if len(reasons) > 0 {
+		return false, strings.Join(reasons, "; "), ""
+	}
+
+	if resultStats == nil {
+		return true, "", ""
+	}
+	profile, ok := mc.Profile[serverInfo.Profile]
+	if !ok {
+		log.Errorf("Profile '%v' for cache server '%v' missing from monitoring configuration - treating as OFFLINE", serverInfo.Profile, result.ID)
+		return false, "ERROR - server profile missing in Traffic Ops monitor config", ""
+	}
+
+	stats := make([]string, 0, len(profile.Parameters.Thresholds))
+	for stat := range profile.Parameters.Thresholds {
+		if strings.HasPrefix(stat, cache.ProbeStatPrefix) {
+			stats = append(stats, stat)
+		}
+	}
+	sort.Strings(stats)
+
+	for _, stat := range stats {
+		threshold := profile.Parameters.Thresholds[stat]
+		resultStatHistory := resultStats.Load(stat)
+		if len(resultStatHistory) == 0 {
+			continue
+		}
+		resultStatNum, ok := util.ToNumeric(resultStatHistory[0].Val)
+		if !ok {
+			log.Errorf("health.EvalProbes threshold stat %s was not a number: %v", stat, resultStatHistory[0].Val)
+			continue
+		}
+		if !inThreshold(threshold, resultStatNum) {
+			return false, exceedsThresholdMsg(stat, threshold, resultStatNum), stat
+		}
+	}
+	return true, "", ""
+}
+
 // CalcAvailability calculates the availability of each cache in results.
 // statResultHistory may be nil, in which case stats won't be used to calculate
 // availability.
+//
+// Results of the "probe" poller (ProbePollerName) set the availability of
+// caches' synthetic probes, which is combined with the availability from
+// polling their health and stats. Caches which haven't yet been polled are
+// skipped.
 func CalcAvailability(
 	results []cache.Result,
 	pollerName string,
@@ -293,92 +372,44 @@ func CalcAvailability(
 			log.Errorf("Cache %v missing from from Traffic Ops Monitor Config - treating as OFFLINE\n", result.ID)
 		}
 
-		availStatus := cache.AvailableStatus{
-			LastCheckedIPv4:    result.UsingIPv4,
-			ProcessedAvailable: true,
-			Poller:             pollerName,
-			Status:             serverInfo.ServerStatus,
-		}
-
-		lastStatus, ok := localCacheStatuses[result.ID]
-		if ok {
-			if result.UsingIPv4 {
-				availStatus.Available.IPv4 = true
-				availStatus.Available.IPv6 = serverInfo.IPv6() != "" && lastStatus.Available.IPv6
-			} else {
-				availStatus.Available.IPv6 = true
-				availStatus.Available.IPv4 = serverInfo.IPv4() != "" && lastStatus.Available.IPv4
-			}
-		}
+		lastStatus, lastStatusExists := localCacheStatuses[result.ID]
 
-		reasons := []string{}
-		resultInfo := cache.ToInfo(result)
-		for _, inf := range serverInfo.Interfaces {
-			if !inf.Monitor {
+		var availStatus cache.AvailableStatus
+		if pollerName == ProbePollerName {
+			if !lastStatusExists {
 				continue
 			}
-
-			available, why := EvalInterface(resultInfo.InterfaceVitals, inf)
-			if result.UsingIPv4 {
-				availStatus.Available.IPv4 = availStatus.Available.IPv4 && available
-			} else {
-				availStatus.Available.IPv6 = availStatus.Available.IPv6 && available
-			}
-
-			if why != "" {
-				reasons = append(reasons, inf.Name+": "+why)
-			}
-		}
-
-		var aggIsAvailable bool
-		var aggWhyAvailable string
-		var aggUnavailableStat string
-
-		if statResultsVal != nil {
-			aggIsAvailable, aggWhyAvailable, aggUnavailableStat = EvalAggregate(cache.ToInfo(result), &statResultsVal.Stats, &mc)
-		} else {
-			aggIsAvailable, aggWhyAvailable, aggUnavailableStat = EvalAggregate(cache.ToInfo(result), nil, &mc)
-		}
-
-		if result.UsingIPv4 {
-			availStatus.Available.IPv4 = availStatus.Available.IPv4 && aggIsAvailable
+			availStatus = evalProbeAvailability(result, lastStatus, statResultsVal, &mc)
 		} else {
-			availStatus.Available.IPv6 = availStatus.Available.IPv6 && aggIsAvailable
+			availStatus = evalPollAvailability(result, lastStatus, lastStatusExists, serverInfo, statResultsVal, &mc, pollerName)
 		}
 
-		availStatus.ProcessedAvailable = processAvailableTuple(availStatus.Available, serverInfo)
-
-		if aggWhyAvailable != "" {
-			reasons = append([]string{aggWhyAvailable}, reasons...)
-		}
-		availStatus.Why = strings.Join(reasons, "; ")
-		if aggUnavailableStat != "" {
-			availStatus.UnavailableStat = aggUnavailableStat
-		}
+		combinedAvailable := availStatus.CombinedAvailable()
+		availStatus.ProcessedAvailable = processAvailableTuple(combinedAvailable, serverInfo)
 
 		localStates.SetCache(tc.CacheName(result.ID), tc.IsAvailable{
 			IsAvailable:   availStatus.ProcessedAvailable,
-			Ipv4Available: availStatus.Available.IPv4,
-			Ipv6Available: availStatus.Available.IPv6,
+			Ipv4Available: combinedAvailable.IPv4,
+			Ipv6Available: combinedAvailable.IPv6,
 		})
 
 		if available, ok := localStates.GetCache(tc.CacheName(result.ID)); !ok || available.IsAvailable != lastStatus.ProcessedAvailable {
 			protocol := "IPv4"
-			if !availStatus.LastCheckedIPv4 {
+			if !result.UsingIPv4 {
 				protocol = "IPv6"
 			}
 			log.Infof("Changing state for %s was: %t now: %t because %s poller: %v on protocol %v error: %v",
-				result.ID, available.IsAvailable, availStatus.ProcessedAvailable, availStatus.Why, pollerName, protocol, result.Error)
+				result.ID, available.IsAvailable, availStatus.ProcessedAvailable, availStatus.Reason(), pollerName, protocol, result.Error)
 
 			event := Event{
 				Time:          Time(time.Now()),
-				Description:   "Protocol (" + protocol + ") " + availStatus.Why + " (" + pollerName + ") ",
+				Description:   "Protocol (" + protocol + ") " + availStatus.Reason() + " (" + pollerName + ") ",
 				Name:          result.ID,
 				Hostname:      result.ID,
 				Type:          toData.ServerTypes[tc.CacheName(result.ID)].String(),
 				Available:     availStatus.ProcessedAvailable,
-				IPv4Available: availStatus.Available.IPv4,
-				IPv6Available: availStatus.Available.IPv6,
+				IPv4Available: combinedAvailable.IPv4,
+				IPv6Available: combinedAvailable.IPv6,
 			}
 			events.Add(event)
 		}
@@ -389,6 +420,114 @@ func CalcAvailability(
 	localCacheStatusThreadsafe.Set(localCacheStatuses)
 }
 
+// evalPollAvailability returns the availability status of a cache server from
+// the result of polling its health or stats. The availability from its
+// synthetic probes is kept from its last status.
+func evalPollAvailability(
+	result cache.Result,
+	lastStatus cache.AvailableStatus,
+	lastStatusExists bool,
+	serverInfo tc.TrafficServer,
+	statResultsVal *threadsafe.CacheStatHistory,
+	mc *tc.TrafficMonitorConfigMap,
+	pollerName string,
+) cache.AvailableStatus {
+	availStatus := cache.AvailableStatus{
+		LastCheckedIPv4:    result.UsingIPv4,
+		ProcessedAvailable: true,
+		Poller:             pollerName,
+		Status:             serverInfo.ServerStatus,
+		Probed:             lastStatus.Probed,
+		ProbeAvailable:     lastStatus.ProbeAvailable,
+		ProbeWhy:           lastStatus.ProbeWhy,
+	}
+
+	if lastStatusExists {
+		if result.UsingIPv4 {
+			availStatus.Available.IPv4 = true
+			availStatus.Available.IPv6 = serverInfo.IPv6() != "" && lastStatus.Available.IPv6
+		} else {
+			availStatus.Available.IPv6 = true
+			availStatus.Available.IPv4 = serverInfo.IPv4() != "" && lastStatus.Available.IPv4
+		}
+	}
+
+	reasons := []string{}
+	resultInfo := cache.ToInfo(result)
+	for _, inf := range serverInfo.Interfaces {
+		if !inf.Monitor {
+			continue
+		}
+
+		available, why := EvalInterface(resultInfo.InterfaceVitals, inf)
+		if result.UsingIPv4 {
+			availStatus.Available.IPv4 = availStatus.Available.IPv4 && available
+		} else {
+			availStatus.Available.IPv6 = availStatus.Available.IPv6 && available
+		}
+
+		if why != "" {
+			reasons = append(reasons, inf.Name+": "+why)
+		}
+	}
+
+	var aggIsAvailable bool
+	var aggWhyAvailable string
+	var aggUnavailableStat string
+
+	if statResultsVal != nil {
+		aggIsAvailable, aggWhyAvailable, aggUnavailableStat = EvalAggregate(resultInfo, &statResultsVal.Stats, mc)
+	} else {
+		aggIsAvailable, aggWhyAvailable, aggUnavailableStat = EvalAggregate(resultInfo, nil, mc)
+	}
+
+	if result.UsingIPv4 {
+		availStatus.Available.IPv4 = availStatus.Available.IPv4 && aggIsAvailable
+	} else {
+		availStatus.Available.IPv6 = availStatus.Available.IPv6 && aggIsAvailable
+	}
+
+	if aggWhyAvailable != "" {
+		reasons = append([]string{aggWhyAvailable}, reasons...)
+	}
+	availStatus.Why = strings.Join(reasons, "; ")
+	if aggUnavailableStat != "" {
+		availStatus.UnavailableStat = aggUnavailableStat
+	}
+	return availStatus
+}
+
+// evalProbeAvailability returns the last availability status of a cache
+// server, updated with the result of its synthetic probes over the IP protocol
+// version of the result. Until a cache has been probed over both versions, the
+// other version is assumed to pass.
+func evalProbeAvailability(
+	result cache.Result,
+	lastStatus cache.AvailableStatus,
+	statResultsVal *threadsafe.CacheStatHistory,
+	mc *tc.TrafficMonitorConfigMap,
+) cache.AvailableStatus {
+	availStatus := lastStatus
+	if !lastStatus.Probed {
+		availStatus.Probed = true
+		availStatus.ProbeAvailable = cache.AvailableTuple{IPv4: true, IPv6: true}
+	}
+
+	var available bool
+	var why string
+	if statResultsVal != nil {
+		available, why, _ = EvalProbes(cache.ToInfo(result), &statResultsVal.Stats, mc)
+	} else {
+		available, why, _ = EvalProbes(cache.ToInfo(result), nil, mc)
+	}
+	availStatus.ProbeAvailable.SetAvailability(result.UsingIPv4, available)
+	availStatus.ProbeWhy = why
+	if !available {
+		availStatus.Poller = ProbePollerName
+	}
+	return availStatus
+}
+
 func setErr(newResult *cache.Result, err error) {
 	newResult.Error = err
 	newResult.Available = false
@@ -27,6 +27,7 @@ import (
 	"github.com/apache/trafficcontrol/traffic_monitor/cache"
 	"github.com/apache/trafficcontrol/traffic_monitor/config"
 	"github.com/apache/trafficcontrol/traffic_monitor/peer"
+	"github.com/apache/trafficcontrol/traffic_monitor/poller"
 	"github.com/apache/trafficcontrol/traffic_monitor/threadsafe"
 	"github.com/apache/trafficcontrol/traffic_monitor/todata"
 
@@ -565,3 +566,125 @@ func TestEvalInterface(t *testing.T) {
 		t.Errorf("Incorrect reason for interface exceeding threshold to be unavailable; expected: 'maximum bandwidth exceeded', got: '%s'", why)
 	}
 }
+
+func TestCalcAvailabilityProbes(t *testing.T) {
+	resultID := "myCacheName"
+	mc := tc.TrafficMonitorConfigMap{
+		TrafficServer: map[string]tc.TrafficServer{
+			resultID: {
+				ServerStatus: string(tc.CacheStatusReported),
+				Profile:      "myProfileName",
+				Interfaces: []tc.ServerInterfaceInfo{
+					{
+						Name:        "eth0",
+						IPAddresses: []tc.ServerIPAddress{{Address: "192.0.2.1", ServiceAddress: true}},
+					},
+				},
+			},
+		},
+		Profile: map[string]tc.TMProfile{
+			"myProfileName": {
+				Name: "myProfileName",
+				Parameters: tc.TMParameters{
+					Thresholds: map[string]tc.HealthThreshold{
+						"probe.origin.latency_ms": {Val: 100, Comparator: "<"},
+					},
+				},
+			},
+		},
+	}
+	toData := todata.TOData{
+		ServerTypes:            map[tc.CacheName]tc.CacheType{tc.CacheName(resultID): tc.CacheTypeEdge},
+		DeliveryServiceServers: map[tc.DeliveryServiceName][]tc.CacheName{},
+		ServerCachegroups:      map[tc.CacheName]tc.CacheGroupName{},
+	}
+	localCacheStatusThreadsafe := threadsafe.NewCacheAvailableStatus()
+	localStates := peer.NewCRStatesThreadsafe()
+	events := NewThreadsafeEvents(200)
+	localStates.AddCache(tc.CacheName(resultID), tc.IsAvailable{})
+	probeStatHistory := threadsafe.NewResultStatHistory()
+
+	healthResult := cache.Result{ID: resultID, Time: time.Now(), Available: true, UsingIPv4: true}
+	probeResult := func(available bool, latencyMs float64) cache.Result {
+		result := cache.Result{
+			ID:        resultID,
+			Time:      time.Now(),
+			Available: true,
+			UsingIPv4: true,
+			Statistics: cache.Statistics{Probes: map[string]poller.ProbeResult{
+				"origin": {Name: "origin", Type: config.SyntheticProbeTypeHTTP, Available: available, LatencyMs: latencyMs},
+			}},
+			Miscellaneous: map[string]interface{}{"probe.origin.latency_ms": latencyMs},
+		}
+		if !available {
+			result.Statistics.Probes["origin"] = poller.ProbeResult{Name: "origin", Error: "bad HTTP status: expected 200, got 503"}
+		}
+		if err := probeStatHistory.Add(result, 1); err != nil {
+			t.Fatalf("adding probe result to history: %v", err)
+		}
+		return result
+	}
+	calc := func(result cache.Result, pollerName string) cache.AvailableStatus {
+		statHistory := (*threadsafe.ResultStatHistory)(nil)
+		if pollerName == ProbePollerName {
+			statHistory = &probeStatHistory
+		}
+		CalcAvailability([]cache.Result{result}, pollerName, statHistory, mc, toData, localCacheStatusThreadsafe, localStates, events, config.Both)
+		return localCacheStatusThreadsafe.Get()[resultID]
+	}
+
+	calc(probeResult(false, 10), ProbePollerName)
+	if _, ok := localCacheStatusThreadsafe.Get()[resultID]; ok {
+		t.Fatal("expected probe result for a cache which hasn't been polled to be skipped, actual: cache has a status")
+	}
+
+	calc(healthResult, "health")
+	status := calc(healthResult, "health")
+	if !status.ProcessedAvailable || status.Probed {
+		t.Fatalf("expected polled cache to be available and not probed, actual: available %v probed %v", status.ProcessedAvailable, status.Probed)
+	}
+
+	status = calc(probeResult(false, 10), ProbePollerName)
+	if status.ProcessedAvailable {
+		t.Error("expected cache failing a probe to be unavailable, actual: available")
+	}
+	if !strings.Contains(status.ProbeWhy, "probe origin failed: bad HTTP status") {
+		t.Errorf("expected ProbeWhy to describe the failed probe, actual: '%s'", status.ProbeWhy)
+	}
+	if !strings.Contains(status.Reason(), status.Why) {
+		t.Errorf("expected Reason() to include the polling reason '%s', actual: '%s'", status.Why, status.Reason())
+	}
+	if status.Poller != ProbePollerName {
+		t.Errorf("expected Poller '%s', actual: '%s'", ProbePollerName, status.Poller)
+	}
+	if !status.Available.IPv4 {
+		t.Error("expected the polled availability to be kept, actual: IPv4 unavailable")
+	}
+	if avail, _ := localStates.GetCache(tc.CacheName(resultID)); avail.IsAvailable || avail.Ipv4Available {
+		t.Errorf("expected local state of cache failing a probe to be unavailable, actual: %+v", avail)
+	}
+
+	status = calc(healthResult, "health")
+	if status.ProcessedAvailable {
+		t.Error("expected health poll not to override a failed probe, actual: available")
+	}
+
+	status = calc(probeResult(true, 250), ProbePollerName)
+	if status.ProcessedAvailable {
+		t.Error("expected cache exceeding a probe threshold to be unavailable, actual: available")
+	}
+	if !strings.Contains(status.ProbeWhy, "probe.origin.latency_ms too high") {
+		t.Errorf("expected ProbeWhy to describe the exceeded threshold, actual: '%s'", status.ProbeWhy)
+	}
+
+	status = calc(probeResult(true, 10), ProbePollerName)
+	if !status.ProcessedAvailable {
+		t.Errorf("expected cache passing its probes to be available, actual: unavailable because '%s'", status.Reason())
+	}
+	if status.ProbeWhy != "" {
+		t.Errorf("expected no ProbeWhy for passing probes, actual: '%s'", status.ProbeWhy)
+	}
+	if avail, _ := localStates.GetCache(tc.CacheName(resultID)); !avail.IsAvailable {
+		t.Error("expected local state of cache passing its probes to be available, actual: unavailable")
+	}
+}
@@ -57,12 +57,22 @@ type Event struct {
 	IPv6Available bool   `json:"ipv6Available"`
 }
 
+// EventStore persists events, so they survive restarts.
+type EventStore interface {
+	// AddEvent stores the given event.
+	AddEvent(e Event) error
+	// RecentEvents returns up to limit of the latest stored events, newest
+	// first.
+	RecentEvents(limit uint64) ([]Event, error)
+}
+
 // Events provides safe access for multiple goroutines readers and a single writer to a stored Events slice.
 type ThreadsafeEvents struct {
 	events    *[]Event
 	m         *sync.RWMutex
 	nextIndex *uint64
 	max       uint64
+	store     EventStore
 }
 
 func copyEvents(a []Event) []Event {
@@ -77,6 +87,24 @@ func NewThreadsafeEvents(maxEvents uint64) ThreadsafeEvents {
 	return ThreadsafeEvents{m: &sync.RWMutex{}, events: &[]Event{}, nextIndex: &i, max: maxEvents}
 }
 
+// NewPersistentThreadsafeEvents creates a new single-writer-multiple-reader
+// Threadsafe object, which also adds events to the given store. It starts with
+// the latest events in the store, and continues their indexes.
+func NewPersistentThreadsafeEvents(maxEvents uint64, store EventStore) ThreadsafeEvents {
+	o := NewThreadsafeEvents(maxEvents)
+	o.store = store
+	events, err := store.RecentEvents(maxEvents)
+	if err != nil {
+		log.Errorf("loading stored events: %v", err)
+		return o
+	}
+	if len(events) > 0 {
+		*o.events = events
+		*o.nextIndex = events[0].Index + 1
+	}
+	return o
+}
+
 // Get returns the internal slice of Events for reading. This MUST NOT be modified. If modification is necessary, copy the slice.
 func (o *ThreadsafeEvents) Get() []Event {
 	o.m.RLock()
@@ -99,4 +127,9 @@ func (o *ThreadsafeEvents) Add(e Event) {
 	*o.events = events
 	*o.nextIndex++
 	o.m.Unlock()
+	if o.store != nil {
+		if err := o.store.AddEvent(e); err != nil {
+			log.Errorf("storing event for %s: %v", e.Hostname, err)
+		}
+	}
 }
@@ -0,0 +1,359 @@
+// Package history provides an embedded, on-disk store of Traffic Monitor
+// events and downsampled cache history, which survives restarts and can be
+// queried by time range.
+package history
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/traffic_monitor/cache"
+	"github.com/apache/trafficcontrol/traffic_monitor/health"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// eventsBucket holds events, keyed by their time and index.
+	eventsBucket = "events"
+	// cachesBucket holds a bucket of samples for each cache, keyed by time.
+	cachesBucket = "caches"
+)
+
+// maxPruneInterval is the longest time between removing expired history.
+const maxPruneInterval = time.Hour
+
+// CacheSample is a snapshot of the availability and vitals of a cache server,
+// as of a stat poll.
+type CacheSample struct {
+	Time          time.Time `json:"time"`
+	Available     bool      `json:"available"`
+	IPv4Available bool      `json:"ipv4Available"`
+	IPv6Available bool      `json:"ipv6Available"`
+	// Status is the reason for the cache's availability, e.g.
+	// "REPORTED - available".
+	Status                string  `json:"status"`
+	Poller                string  `json:"poller"`
+	LoadAverage           float64 `json:"loadAverage"`
+	BandwidthKbps         int64   `json:"bandwidthKbps"`
+	BandwidthCapacityKbps int64   `json:"bandwidthCapacityKbps"`
+	BytesIn               uint64  `json:"bytesIn"`
+	BytesOut              uint64  `json:"bytesOut"`
+	// Stats holds the values of the configured history stats which the cache
+	// reported.
+	Stats map[string]interface{} `json:"stats,omitempty"`
+	// Error is the error polling the cache, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Store is an on-disk store of events and cache history. Events older than its
+// retention are removed periodically, and at most one sample of each cache is
+// stored per sample interval.
+type Store struct {
+	db             *bolt.DB
+	retention      time.Duration
+	sampleInterval time.Duration
+	stats          []string
+	// lastSamples is the time of the last sample stored for each cache.
+	lastSamples map[string]time.Time
+	m           *sync.Mutex
+	stop        chan struct{}
+}
+
+// Open opens the store in the given file, creating it if it doesn't exist, and
+// starts removing history older than retention in the background.
+// Cache samples include the given stats, if the cache reports them.
+func Open(path string, retention time.Duration, sampleInterval time.Duration, stats []string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, errors.New("opening database '" + path + "': " + err.Error())
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{eventsBucket, cachesBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return errors.New("creating bucket '" + bucket + "': " + err.Error())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.New("creating buckets for database '" + path + "': " + err.Error())
+	}
+
+	s := &Store{
+		db:             db,
+		retention:      retention,
+		sampleInterval: sampleInterval,
+		stats:          stats,
+		lastSamples:    map[string]time.Time{},
+		m:              &sync.Mutex{},
+		stop:           make(chan struct{}),
+	}
+	if retention > 0 {
+		go s.pruneLoop()
+	}
+	return s, nil
+}
+
+// Close stops removing expired history, and closes the store.
+func (s *Store) Close() error {
+	close(s.stop)
+	return s.db.Close()
+}
+
+// timeKey returns a key which sorts by the given time, then sequence number.
+func timeKey(t time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+// keyTime returns the time of a key created by timeKey.
+func keyTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key)))
+}
+
+// AddEvent stores the given event.
+func (s *Store) AddEvent(e health.Event) error {
+	bts, err := json.Marshal(e)
+	if err != nil {
+		return errors.New("encoding event: " + err.Error())
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(eventsBucket)).Put(timeKey(time.Time(e.Time), e.Index), bts)
+	})
+}
+
+// RecentEvents returns up to limit of the latest stored events, newest first.
+func (s *Store) RecentEvents(limit uint64) ([]health.Event, error) {
+	events := []health.Event{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(eventsBucket)).Cursor()
+		for k, v := c.Last(); k != nil && uint64(len(events)) < limit; k, v = c.Prev() {
+			e := health.Event{}
+			if err := json.Unmarshal(v, &e); err != nil {
+				return errors.New("decoding event: " + err.Error())
+			}
+			events = append(events, e)
+		}
+		return nil
+	})
+	return events, err
+}
+
+// Events returns the stored events from since up to (but not including) until,
+// newest first. If cacheName isn't empty, only events for that cache are
+// returned.
+func (s *Store) Events(since time.Time, until time.Time, cacheName string) ([]health.Event, error) {
+	events := []health.Event{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(eventsBucket)).Cursor()
+		k, v := c.Seek(timeKey(until, 0))
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		for ; k != nil && !keyTime(k).Before(since); k, v = c.Prev() {
+			e := health.Event{}
+			if err := json.Unmarshal(v, &e); err != nil {
+				return errors.New("decoding event: " + err.Error())
+			}
+			if cacheName != "" && e.Hostname != cacheName {
+				continue
+			}
+			events = append(events, e)
+		}
+		return nil
+	})
+	return events, err
+}
+
+// AddCacheSamples stores a sample of each of the caches of the given stat
+// results, with its given availability status, unless one was stored for the
+// cache within the sample interval.
+func (s *Store) AddCacheSamples(results []cache.Result, statuses cache.AvailableStatuses) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	samples := map[string]CacheSample{}
+	for _, result := range results {
+		if last, ok := s.lastSamples[result.ID]; ok && result.Time.Sub(last) < s.sampleInterval {
+			continue
+		}
+		samples[result.ID] = s.newCacheSample(result, statuses[result.ID])
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		caches := tx.Bucket([]byte(cachesBucket))
+		for cacheName, sample := range samples {
+			bucket, err := caches.CreateBucketIfNotExists([]byte(cacheName))
+			if err != nil {
+				return errors.New("creating bucket for cache '" + cacheName + "': " + err.Error())
+			}
+			bts, err := json.Marshal(sample)
+			if err != nil {
+				return errors.New("encoding sample of cache '" + cacheName + "': " + err.Error())
+			}
+			if err := bucket.Put(timeKey(sample.Time, 0), bts); err != nil {
+				return errors.New("storing sample of cache '" + cacheName + "': " + err.Error())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for cacheName, sample := range samples {
+		s.lastSamples[cacheName] = sample.Time
+	}
+	return nil
+}
+
+func (s *Store) newCacheSample(result cache.Result, status cache.AvailableStatus) CacheSample {
+	available := status.CombinedAvailable()
+	sample := CacheSample{
+		Time:                  result.Time,
+		Available:             status.ProcessedAvailable,
+		IPv4Available:         available.IPv4,
+		IPv6Available:         available.IPv6,
+		Status:                status.Reason(),
+		Poller:                status.Poller,
+		LoadAverage:           result.Vitals.LoadAvg,
+		BandwidthKbps:         result.Vitals.KbpsOut,
+		BandwidthCapacityKbps: result.Vitals.MaxKbpsOut,
+		BytesIn:               result.Vitals.BytesIn,
+		BytesOut:              result.Vitals.BytesOut,
+	}
+	if result.Error != nil {
+		sample.Error = result.Error.Error()
+	}
+	for _, stat := range s.stats {
+		if val, ok := result.Miscellaneous[stat]; ok {
+			if sample.Stats == nil {
+				sample.Stats = map[string]interface{}{}
+			}
+			sample.Stats[stat] = val
+		}
+	}
+	return sample
+}
+
+// CacheHistory returns the stored samples of the given cache from since up to
+// (but not including) until, oldest first.
+func (s *Store) CacheHistory(cacheName string, since time.Time, until time.Time) ([]CacheSample, error) {
+	samples := []CacheSample{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(cachesBucket)).Bucket([]byte(cacheName))
+		if bucket == nil {
+			return nil
+		}
+		c := bucket.Cursor()
+		for k, v := c.Seek(timeKey(since, 0)); k != nil && keyTime(k).Before(until); k, v = c.Next() {
+			sample := CacheSample{}
+			if err := json.Unmarshal(v, &sample); err != nil {
+				return errors.New("decoding sample: " + err.Error())
+			}
+			samples = append(samples, sample)
+		}
+		return nil
+	})
+	return samples, err
+}
+
+// Prune removes events and samples older than the retention, as of now.
+func (s *Store) Prune(now time.Time) error {
+	cutoff := now.Add(-s.retention)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := pruneBucket(tx.Bucket([]byte(eventsBucket)), cutoff); err != nil {
+			return errors.New("pruning events: " + err.Error())
+		}
+		caches := tx.Bucket([]byte(cachesBucket))
+		emptyCaches := [][]byte{}
+		err := caches.ForEach(func(cacheName []byte, _ []byte) error {
+			bucket := caches.Bucket(cacheName)
+			if err := pruneBucket(bucket, cutoff); err != nil {
+				return errors.New("pruning cache '" + string(cacheName) + "': " + err.Error())
+			}
+			if k, _ := bucket.Cursor().First(); k == nil {
+				emptyCaches = append(emptyCaches, append([]byte(nil), cacheName...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// caches removed from the CDN no longer have history
+		for _, cacheName := range emptyCaches {
+			if err := caches.DeleteBucket(cacheName); err != nil {
+				return errors.New("deleting bucket of cache '" + string(cacheName) + "': " + err.Error())
+			}
+		}
+		return nil
+	})
+}
+
+// pruneBucket deletes the entries of the given bucket, whose keys are created
+// by timeKey, which are older than cutoff.
+func pruneBucket(bucket *bolt.Bucket, cutoff time.Time) error {
+	expired := [][]byte{}
+	c := bucket.Cursor()
+	for k, _ := c.First(); k != nil && keyTime(k).Before(cutoff); k, _ = c.Next() {
+		expired = append(expired, append([]byte(nil), k...))
+	}
+	// deleting while iterating with a cursor skips entries
+	for _, k := range expired {
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) pruneLoop() {
+	interval := s.retention / 10
+	if interval > maxPruneInterval {
+		interval = maxPruneInterval
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		if err := s.Prune(time.Now()); err != nil {
+			log.Errorf("pruning history: %v", err)
+		}
+		select {
+		case <-tick.C:
+		case <-s.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package history
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_monitor/cache"
+	"github.com/apache/trafficcontrol/traffic_monitor/health"
+)
+
+func openTestStore(t *testing.T, sampleInterval time.Duration) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "tm-history")
+	if err != nil {
+		t.Fatalf("creating temporary directory: %v", err)
+	}
+	// a retention of 0 doesn't prune in the background, so tests control pruning
+	s, err := Open(filepath.Join(dir, "history.db"), 0, sampleInterval, []string{"proxy.process.http.current_client_connections"})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("opening store: %v", err)
+	}
+	return s, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	s, cleanup := openTestStore(t, time.Minute)
+	defer cleanup()
+
+	start := time.Unix(1600000000, 0)
+	for i, hostname := range []string{"cache0", "cache1", "cache0", "cache1"} {
+		e := health.Event{
+			Time:        health.Time(start.Add(time.Duration(i) * time.Hour)),
+			Index:       uint64(i),
+			Description: "REPORTED - unavailable",
+			Name:        hostname,
+			Hostname:    hostname,
+		}
+		if err := s.AddEvent(e); err != nil {
+			t.Fatalf("adding event %d: %v", i, err)
+		}
+	}
+
+	events, err := s.Events(start, start.Add(3*time.Hour), "")
+	if err != nil {
+		t.Fatalf("getting events: %v", err)
+	}
+	if len(events) != 3 || events[0].Index != 2 || events[2].Index != 0 {
+		t.Errorf("expected events 2, 1, 0, actual: %+v", events)
+	}
+
+	events, err = s.Events(start.Add(time.Minute), start.Add(4*time.Hour), "cache0")
+	if err != nil {
+		t.Fatalf("getting events: %v", err)
+	}
+	if len(events) != 1 || events[0].Index != 2 {
+		t.Errorf("expected event 2 of cache0, actual: %+v", events)
+	}
+
+	events, err = s.RecentEvents(2)
+	if err != nil {
+		t.Fatalf("getting recent events: %v", err)
+	}
+	if len(events) != 2 || events[0].Index != 3 || events[1].Index != 2 {
+		t.Errorf("expected recent events 3, 2, actual: %+v", events)
+	}
+
+	s.retention = 90 * time.Minute
+	if err := s.Prune(start.Add(3 * time.Hour)); err != nil {
+		t.Fatalf("pruning: %v", err)
+	}
+	events, err = s.Events(start, start.Add(4*time.Hour), "")
+	if err != nil {
+		t.Fatalf("getting events: %v", err)
+	}
+	if len(events) != 2 || events[1].Index != 2 {
+		t.Errorf("expected events older than the retention to be pruned, actual: %+v", events)
+	}
+}
+
+func TestCacheSamples(t *testing.T) {
+	s, cleanup := openTestStore(t, time.Minute)
+	defer cleanup()
+
+	start := time.Unix(1600000000, 0)
+	statuses := cache.AvailableStatuses{
+		"cache0": {
+			Available:          cache.AvailableTuple{IPv4: true},
+			ProcessedAvailable: false,
+			Why:                "REPORTED - available",
+			Poller:             "probe",
+			Probed:             true,
+			ProbeWhy:           "probe origin failed: bad HTTP status: expected 200, got 503",
+		},
+	}
+	for i := 0; i < 6; i++ {
+		result := cache.Result{
+			ID:            "cache0",
+			Time:          start.Add(time.Duration(i) * 30 * time.Second),
+			Vitals:        cache.Vitals{LoadAvg: 0.5, KbpsOut: int64(i)},
+			Miscellaneous: map[string]interface{}{"proxy.process.http.current_client_connections": float64(i), "other": 1},
+		}
+		if i == 5 {
+			result.Error = errors.New("timeout")
+		}
+		if err := s.AddCacheSamples([]cache.Result{result}, statuses); err != nil {
+			t.Fatalf("adding sample %d: %v", i, err)
+		}
+	}
+
+	samples, err := s.CacheHistory("cache0", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("getting cache history: %v", err)
+	}
+	if len(samples) != 3 {
+		t.Fatalf("expected one sample per minute (3), actual: %d", len(samples))
+	}
+	if samples[0].BandwidthKbps != 0 || samples[1].BandwidthKbps != 2 || samples[2].BandwidthKbps != 4 {
+		t.Errorf("expected samples of polls 0, 2, 4 oldest first, actual: %+v", samples)
+	}
+	if samples[0].Available || samples[0].IPv4Available || samples[0].Status != "REPORTED - available; probe origin failed: bad HTTP status: expected 200, got 503" {
+		t.Errorf("expected sample to have the combined availability and reason, actual: %+v", samples[0])
+	}
+	if len(samples[1].Stats) != 1 || samples[1].Stats["proxy.process.http.current_client_connections"] != float64(2) {
+		t.Errorf("expected sample to have only the configured stats, actual: %+v", samples[1].Stats)
+	}
+
+	samples, err = s.CacheHistory("cache0", start.Add(time.Minute), start.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("getting cache history: %v", err)
+	}
+	if len(samples) != 1 || !samples[0].Time.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected the sample at 1 minute, actual: %+v", samples)
+	}
+
+	if samples, err = s.CacheHistory("cache1", start, start.Add(time.Hour)); err != nil || len(samples) != 0 {
+		t.Errorf("expected no history of an unknown cache, actual: %+v, error %v", samples, err)
+	}
+
+	s.retention = time.Minute
+	if err := s.Prune(start.Add(10 * time.Minute)); err != nil {
+		t.Fatalf("pruning: %v", err)
+	}
+	if samples, err = s.CacheHistory("cache0", start, start.Add(time.Hour)); err != nil || len(samples) != 0 {
+		t.Errorf("expected expired samples to be pruned, actual: %+v, error %v", samples, err)
+	}
+}
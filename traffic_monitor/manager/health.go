@@ -48,6 +48,7 @@ func StartHealthResultManager(
 	cfg config.Config,
 	events health.ThreadsafeEvents,
 	localCacheStatus threadsafe.CacheAvailableStatus,
+	pollCounts threadsafe.PollCounts,
 ) (threadsafe.DurationMap, threadsafe.ResultHistory) {
 	lastHealthDurations := threadsafe.NewDurationMap()
 	healthHistory := threadsafe.NewResultHistory()
@@ -63,6 +64,7 @@ func StartHealthResultManager(
 		errorCount,
 		events,
 		localCacheStatus,
+		pollCounts,
 		cfg,
 	)
 	return lastHealthDurations, healthHistory
@@ -80,6 +82,7 @@ func healthResultManagerListen(
 	errorCount threadsafe.Uint,
 	events health.ThreadsafeEvents,
 	localCacheStatus threadsafe.CacheAvailableStatus,
+	pollCounts threadsafe.PollCounts,
 	cfg config.Config,
 ) {
 	lastHealthEndTimes := map[tc.CacheName]time.Time{}
@@ -100,6 +103,7 @@ func healthResultManagerListen(
 			localCacheStatus,
 			lastHealthEndTimes,
 			healthHistory,
+			pollCounts,
 			results,
 			cfg,
 		)
@@ -146,6 +150,7 @@ func processHealthResult(
 	localCacheStatusThreadsafe threadsafe.CacheAvailableStatus,
 	lastHealthEndTimes map[tc.CacheName]time.Time,
 	healthHistory threadsafe.ResultHistory,
+	pollCounts threadsafe.PollCounts,
 	results []cache.Result,
 	cfg config.Config,
 ) {
@@ -185,6 +190,7 @@ func processHealthResult(
 	}
 
 	pollerName := "health"
+	pollCounts.Add(pollerName, results)
 	statResultHistoryNil := (*threadsafe.ResultStatHistory)(nil) // health poller doesn't have stats
 	health.CalcAvailability(results, pollerName, statResultHistoryNil, monitorConfigCopy, toDataCopy, localCacheStatusThreadsafe, localStates, events, cfg.CachePollingProtocol)
 
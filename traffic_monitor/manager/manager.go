@@ -33,6 +33,7 @@ import (
 	"github.com/apache/trafficcontrol/traffic_monitor/config"
 	"github.com/apache/trafficcontrol/traffic_monitor/handler"
 	"github.com/apache/trafficcontrol/traffic_monitor/health"
+	"github.com/apache/trafficcontrol/traffic_monitor/history"
 	"github.com/apache/trafficcontrol/traffic_monitor/peer"
 	"github.com/apache/trafficcontrol/traffic_monitor/poller"
 	"github.com/apache/trafficcontrol/traffic_monitor/threadsafe"
@@ -60,14 +61,27 @@ func Start(opsConfigFile string, cfg config.Config, appData config.StaticAppData
 	monitorConfigPoller := poller.NewMonitorConfig(cfg.MonitorConfigPollingInterval)
 	peerHandler := peer.NewHandler()
 	peerPoller := poller.NewCache(cfg.PeerPollingInterval, false, peerHandler, cfg, appData, cfg.PeerPollingProtocol)
+	cacheProbeHandler := cache.NewHandler()
+	cacheProbePoller := poller.NewCache(cfg.SyntheticProbeInterval, false, cacheProbeHandler, cfg, appData, cfg.CachePollingProtocol)
 
 	go monitorConfigPoller.Poll()
 	go cacheHealthPoller.Poll()
 	go cacheStatPoller.Poll()
 	go peerPoller.Poll()
+	go cacheProbePoller.Poll()
 
+	historyStore := (*history.Store)(nil)
 	events := health.NewThreadsafeEvents(cfg.MaxEvents)
+	if cfg.HistoryFile != "" {
+		store, err := history.Open(cfg.HistoryFile, cfg.HistoryRetention, cfg.HistorySampleInterval, cfg.HistoryStats)
+		if err != nil {
+			return fmt.Errorf("opening history file: %v", err)
+		}
+		historyStore = store
+		events = health.NewPersistentThreadsafeEvents(cfg.MaxEvents, historyStore)
+	}
 
+	pollCounts := threadsafe.NewPollCounts()
 	cachesChanged := make(chan struct{})
 	peerStates := peer.NewCRStatesPeersThreadsafe(cfg.PeerOptimisticQuorumMin) // each peer's last state is saved in this map
 
@@ -78,6 +92,7 @@ func Start(opsConfigFile string, cfg config.Config, appData config.StaticAppData
 		cacheStatPoller.ConfigChannel,
 		cacheHealthPoller.ConfigChannel,
 		peerPoller.ConfigChannel,
+		cacheProbePoller.ConfigChannel,
 		monitorConfigPoller.IntervalChan,
 		cachesChanged,
 		cfg,
@@ -86,7 +101,7 @@ func Start(opsConfigFile string, cfg config.Config, appData config.StaticAppData
 		toData,
 	)
 
-	combinedStates, combineStateFunc := StartStateCombiner(events, peerStates, localStates, toData)
+	combinedStates, explanations, combineStateFunc := StartStateCombiner(events, peerStates, localStates, toData, monitorConfig, cfg.PeerConsensus, appData.Hostname)
 
 	StartPeerManager(
 		peerHandler.ResultChannel,
@@ -106,6 +121,8 @@ func Start(opsConfigFile string, cfg config.Config, appData config.StaticAppData
 		monitorConfig,
 		events,
 		combineStateFunc,
+		historyStore,
+		pollCounts,
 	)
 
 	lastHealthDurations, healthHistory := StartHealthResultManager(
@@ -119,6 +136,18 @@ func Start(opsConfigFile string, cfg config.Config, appData config.StaticAppData
 		cfg,
 		events,
 		localCacheStatus,
+		pollCounts,
+	)
+
+	StartProbeResultManager(
+		cacheProbeHandler.ResultChan(),
+		toData,
+		localStates,
+		monitorConfig,
+		cfg,
+		events,
+		localCacheStatus,
+		combineStateFunc,
 	)
 
 	StartOpsConfigManager(
@@ -146,6 +175,9 @@ func Start(opsConfigFile string, cfg config.Config, appData config.StaticAppData
 		localCacheStatus,
 		unpolledCaches,
 		monitorConfig,
+		historyStore,
+		pollCounts,
+		explanations,
 		cfg,
 	)
 
@@ -125,6 +125,7 @@ func StartMonitorConfigManager(
 	statURLSubscriber chan<- poller.CachePollerConfig,
 	healthURLSubscriber chan<- poller.CachePollerConfig,
 	peerURLSubscriber chan<- poller.CachePollerConfig,
+	probeURLSubscriber chan<- poller.CachePollerConfig,
 	toIntervalSubscriber chan<- time.Duration,
 	cachesChangeSubscriber chan<- struct{},
 	cfg config.Config,
@@ -140,6 +141,7 @@ func StartMonitorConfigManager(
 		statURLSubscriber,
 		healthURLSubscriber,
 		peerURLSubscriber,
+		probeURLSubscriber,
 		toIntervalSubscriber,
 		cachesChangeSubscriber,
 		cfg,
@@ -195,6 +197,7 @@ func monitorConfigListen(
 	statURLSubscriber chan<- poller.CachePollerConfig,
 	healthURLSubscriber chan<- poller.CachePollerConfig,
 	peerURLSubscriber chan<- poller.CachePollerConfig,
+	probeURLSubscriber chan<- poller.CachePollerConfig,
 	toIntervalSubscriber chan<- time.Duration,
 	cachesChangeSubscriber chan<- struct{},
 	cfg config.Config,
@@ -221,9 +224,12 @@ func monitorConfigListen(
 			log.Errorln("Updating Traffic Ops Data: " + err.Error())
 		}
 
+		toDataCopy := toData.Get()
+
 		healthURLs := map[string]poller.PollConfig{}
 		statURLs := map[string]poller.PollConfig{}
 		peerURLs := map[string]poller.PollConfig{}
+		probeURLs := map[string]poller.PollConfig{}
 		caches := map[string]string{}
 
 		intervals, err := getIntervals(monitorConfig, cfg, logMissingIntervalParams)
@@ -282,6 +288,13 @@ func monitorConfigListen(
 			statURL4 := createServerStatPollURL(pollURL4Str)
 			statURL6 := createServerStatPollURL(pollURL6Str)
 			statURLs[srv.HostName] = poller.PollConfig{URL: statURL4, URLv6: statURL6, Host: srv.FQDN, Timeout: connTimeout, Format: format, PollType: pollType}
+
+			// Caches without any applicable probes are still "probed", so that
+			// the results of probes which no longer apply are cleared.
+			if len(cfg.SyntheticProbes) > 0 {
+				probes := createServerProbes(cfg.SyntheticProbes, srv, toDataCopy.ServerDeliveryServices[cacheName])
+				probeURLs[srv.HostName] = poller.PollConfig{URL: srv.IPv4(), URLv6: ipv6CIDRStrToAddr(srv.IPv6()), Host: srv.FQDN, Format: poller.PollerTypeProbe, PollType: poller.PollerTypeProbe, Probes: probes}
+			}
 		}
 
 		peerSet := map[tc.TrafficMonitorName]struct{}{}
@@ -302,6 +315,7 @@ func monitorConfigListen(
 		statURLSubscriber <- poller.CachePollerConfig{Urls: statURLs, PollingProtocol: cfg.CachePollingProtocol, Interval: intervals.Stat, NoKeepAlive: intervals.StatNoKeepAlive}
 		healthURLSubscriber <- poller.CachePollerConfig{Urls: healthURLs, PollingProtocol: cfg.CachePollingProtocol, Interval: intervals.Health, NoKeepAlive: intervals.HealthNoKeepAlive}
 		peerURLSubscriber <- poller.CachePollerConfig{Urls: peerURLs, PollingProtocol: cfg.PeerPollingProtocol, Interval: intervals.Peer, NoKeepAlive: intervals.PeerNoKeepAlive}
+		probeURLSubscriber <- poller.CachePollerConfig{Urls: probeURLs, PollingProtocol: cfg.CachePollingProtocol, Interval: cfg.SyntheticProbeInterval}
 		toIntervalSubscriber <- intervals.TO
 		peerStates.SetTimeout((intervals.Peer + cfg.HTTPTimeout) * 2)
 		peerStates.SetPeers(peerSet)
@@ -334,6 +348,37 @@ func monitorConfigListen(
 	}
 }
 
+// createServerProbes returns the synthetic probes to perform on srv, which is
+// assigned to the given Delivery Services. Probes for a Delivery Service are
+// only performed on its servers, and probes without a port use the server's
+// HTTP or HTTPS port.
+func createServerProbes(probes []config.SyntheticProbe, srv tc.TrafficServer, dses []tc.DeliveryServiceName) []config.SyntheticProbe {
+	srvProbes := []config.SyntheticProbe{}
+	for _, probe := range probes {
+		if probe.DeliveryService != "" && !containsDeliveryService(dses, tc.DeliveryServiceName(probe.DeliveryService)) {
+			continue
+		}
+		if probe.Port == 0 {
+			if probe.Type == config.SyntheticProbeTypeTLS || (probe.Type == config.SyntheticProbeTypeHTTP && probe.TLS) {
+				probe.Port = srv.HTTPSPort
+			} else {
+				probe.Port = srv.Port
+			}
+		}
+		srvProbes = append(srvProbes, probe)
+	}
+	return srvProbes
+}
+
+func containsDeliveryService(dses []tc.DeliveryServiceName, ds tc.DeliveryServiceName) bool {
+	for _, d := range dses {
+		if d == ds {
+			return true
+		}
+	}
+	return false
+}
+
 // createServerHealthPollURLs takes the template pollingURLStr, and replaces
 // variables with data from srv, and returns the polling URL for srv.
 //
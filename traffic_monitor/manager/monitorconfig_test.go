@@ -23,6 +23,7 @@ import (
 	"testing"
 
 	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_monitor/config"
 )
 
 func TestCreateServerHealthPollURL(t *testing.T) {
@@ -266,3 +267,37 @@ func TestCreateServerStatPollURL(t *testing.T) {
 		t.Errorf("incorrect IPv6 polling URL; expected: '%s', actual: '%s'", expectedV6, actualV6)
 	}
 }
+
+func TestCreateServerProbes(t *testing.T) {
+	probes := []config.SyntheticProbe{
+		{Name: "tcp", Type: config.SyntheticProbeTypeTCP},
+		{Name: "https", Type: config.SyntheticProbeTypeTLS},
+		{Name: "demo1", Type: config.SyntheticProbeTypeHTTP, DeliveryService: "demo1", Port: 8080},
+		{Name: "demo2", Type: config.SyntheticProbeTypeHTTP, DeliveryService: "demo2", TLS: true},
+	}
+	srv := tc.TrafficServer{Port: 81, HTTPSPort: 444}
+
+	srvProbes := createServerProbes(probes, srv, []tc.DeliveryServiceName{"demo2"})
+	expected := map[string]int{"tcp": 81, "https": 444, "demo2": 444}
+	if len(srvProbes) != len(expected) {
+		t.Fatalf("expected %d probes, actual: %+v", len(expected), srvProbes)
+	}
+	for _, probe := range srvProbes {
+		port, ok := expected[probe.Name]
+		if !ok {
+			t.Errorf("expected probe '%s' not to be performed on a server not assigned to its delivery service", probe.Name)
+		} else if probe.Port != port {
+			t.Errorf("probe '%s' port expected: %d, actual: %d", probe.Name, port, probe.Port)
+		}
+	}
+	if probes[0].Port != 0 {
+		t.Error("expected createServerProbes not to modify the given probes")
+	}
+
+	srvProbes = createServerProbes(probes, srv, []tc.DeliveryServiceName{"demo1"})
+	for _, probe := range srvProbes {
+		if probe.Name == "demo1" && probe.Port != 8080 {
+			t.Errorf("probe 'demo1' port expected: 8080, actual: %d", probe.Port)
+		}
+	}
+}
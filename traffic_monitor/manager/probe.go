@@ -0,0 +1,149 @@
+package manager
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR nCONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/traffic_monitor/cache"
+	"github.com/apache/trafficcontrol/traffic_monitor/config"
+	"github.com/apache/trafficcontrol/traffic_monitor/health"
+	"github.com/apache/trafficcontrol/traffic_monitor/peer"
+	"github.com/apache/trafficcontrol/traffic_monitor/threadsafe"
+	"github.com/apache/trafficcontrol/traffic_monitor/todata"
+)
+
+// StartProbeResultManager starts the goroutine which listens for the results of
+// synthetic probes, and combines them with the availability of caches from
+// their health and stat polls.
+// Returns the history of probe stats, which are evaluated against the "probe."
+// thresholds of caches' profiles.
+func StartProbeResultManager(
+	cacheProbeChan <-chan cache.Result,
+	toData todata.TODataThreadsafe,
+	localStates peer.CRStatesThreadsafe,
+	monitorConfig threadsafe.TrafficMonitorConfigMap,
+	cfg config.Config,
+	events health.ThreadsafeEvents,
+	localCacheStatus threadsafe.CacheAvailableStatus,
+	combineState func(),
+) threadsafe.ResultStatHistory {
+	probeStatHistory := threadsafe.NewResultStatHistory()
+	go probeResultManagerListen(
+		cacheProbeChan,
+		toData,
+		localStates,
+		monitorConfig,
+		cfg,
+		events,
+		localCacheStatus,
+		combineState,
+		probeStatHistory,
+	)
+	return probeStatHistory
+}
+
+func probeResultManagerListen(
+	cacheProbeChan <-chan cache.Result,
+	toData todata.TODataThreadsafe,
+	localStates peer.CRStatesThreadsafe,
+	monitorConfig threadsafe.TrafficMonitorConfigMap,
+	cfg config.Config,
+	events health.ThreadsafeEvents,
+	localCacheStatus threadsafe.CacheAvailableStatus,
+	combineState func(),
+	probeStatHistory threadsafe.ResultStatHistory,
+) {
+	// Like the health results, this reads as many results as are queued, and
+	// processes them together, until the flush interval is reached.
+	var ticker *time.Ticker
+	process := func(results []cache.Result) {
+		processProbeResults(results, toData, localStates, monitorConfig, cfg, events, localCacheStatus, combineState, probeStatHistory)
+	}
+
+	for {
+		var results []cache.Result
+		results = append(results, <-cacheProbeChan)
+		if ticker != nil {
+			ticker.Stop()
+		}
+		ticker = time.NewTicker(cfg.HealthFlushInterval)
+	innerLoop:
+		for {
+			select {
+			case <-ticker.C:
+				log.Infof("Probe Result Manager flushing queued results\n")
+				process(results)
+				break innerLoop
+			default:
+				select {
+				case r := <-cacheProbeChan:
+					results = append(results, r)
+				default:
+					process(results)
+					break innerLoop
+				}
+			}
+		}
+	}
+}
+
+// processProbeResults adds the stats of the given probe results to the probe
+// stat history, and calculates the resulting availability of the caches. This
+// MUST NOT be called from multiple threads.
+func processProbeResults(
+	results []cache.Result,
+	toData todata.TODataThreadsafe,
+	localStates peer.CRStatesThreadsafe,
+	monitorConfig threadsafe.TrafficMonitorConfigMap,
+	cfg config.Config,
+	events health.ThreadsafeEvents,
+	localCacheStatus threadsafe.CacheAvailableStatus,
+	combineState func(),
+	probeStatHistory threadsafe.ResultStatHistory,
+) {
+	if len(results) == 0 {
+		return
+	}
+	defer func() {
+		for _, r := range results {
+			r.PollFinished <- r.PollID
+		}
+	}()
+
+	mc := monitorConfig.Get()
+	for _, result := range results {
+		maxStats := uint64(mc.Profile[mc.TrafficServer[result.ID].Profile].Parameters.HistoryCount)
+		if maxStats < 1 {
+			maxStats = 1
+		}
+		if result.Error != nil {
+			log.Warnf("probing %v: %v\n", result.ID, result.Error)
+			continue
+		}
+		if err := probeStatHistory.Add(result, maxStats); err != nil {
+			log.Errorf("Adding probe result from %v: %v\n", result.ID, err)
+		}
+	}
+
+	health.CalcAvailability(results, health.ProbePollerName, &probeStatHistory, mc, toData.Get(), localCacheStatus, localStates, events, cfg.CachePollingProtocol)
+	combineState()
+}
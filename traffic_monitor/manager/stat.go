@@ -30,6 +30,7 @@ import (
 	"github.com/apache/trafficcontrol/traffic_monitor/config"
 	"github.com/apache/trafficcontrol/traffic_monitor/ds"
 	"github.com/apache/trafficcontrol/traffic_monitor/health"
+	"github.com/apache/trafficcontrol/traffic_monitor/history"
 	"github.com/apache/trafficcontrol/traffic_monitor/peer"
 	"github.com/apache/trafficcontrol/traffic_monitor/threadsafe"
 	"github.com/apache/trafficcontrol/traffic_monitor/todata"
@@ -58,6 +59,8 @@ func getNewCaches(localStates peer.CRStatesThreadsafe, monitorConfigTS threadsaf
 // StartStatHistoryManager fetches the full statistics data from ATS Astats. This includes everything needed for all calculations, such as Delivery Services. This is expensive, though, and may be hard on ATS, so it should poll less often.
 // For a fast 'is it alive' poll, use the Health Result Manager poll.
 // Returns the stat history, the duration between the stat poll for each cache, the last Kbps data, the calculated Delivery Service stats, and the unpolled caches list.
+// If historyStore isn't nil, samples of each cache's availability and vitals are stored in it.
+// The polls of each cache, and which of them failed, are counted in pollCounts.
 func StartStatHistoryManager(
 	cacheStatChan <-chan cache.Result,
 	localStates peer.CRStatesThreadsafe,
@@ -69,6 +72,8 @@ func StartStatHistoryManager(
 	monitorConfig threadsafe.TrafficMonitorConfigMap,
 	events health.ThreadsafeEvents,
 	combineState func(),
+	historyStore *history.Store,
+	pollCounts threadsafe.PollCounts,
 ) (threadsafe.ResultInfoHistory, threadsafe.ResultStatHistory, threadsafe.CacheKbpses, threadsafe.DurationMap, threadsafe.LastStats, threadsafe.DSStatsReader, threadsafe.UnpolledCaches, threadsafe.CacheAvailableStatus) {
 	statInfoHistory := threadsafe.NewResultInfoHistory()
 	statResultHistory := threadsafe.NewResultStatHistory()
@@ -98,7 +103,7 @@ func StartStatHistoryManager(
 		if haveCachesChanged() {
 			unpolledCaches.SetNewCaches(getNewCaches(localStates, monitorConfig))
 		}
-		processStatResults(results, statInfoHistory, statResultHistory, statMaxKbpses, combinedStates, lastStats, toData.Get(), errorCount, dsStats, lastStatEndTimes, lastStatDurations, unpolledCaches, monitorConfig.Get(), precomputedData, lastResults, localStates, events, localCacheStatus, overrideMap, combineState, cfg.CachePollingProtocol)
+		processStatResults(results, statInfoHistory, statResultHistory, statMaxKbpses, combinedStates, lastStats, toData.Get(), errorCount, dsStats, lastStatEndTimes, lastStatDurations, unpolledCaches, monitorConfig.Get(), precomputedData, lastResults, localStates, events, localCacheStatus, overrideMap, combineState, cfg.CachePollingProtocol, historyStore, pollCounts)
 	}
 
 	go func() {
@@ -255,6 +260,8 @@ func processStatResults(
 	overrideMap map[tc.CacheName]bool,
 	combineState func(),
 	pollingProtocol config.PollingProtocol,
+	historyStore *history.Store,
+	pollCounts threadsafe.PollCounts,
 ) {
 	if len(results) == 0 {
 		return
@@ -320,9 +327,16 @@ func processStatResults(
 	}
 
 	pollerName := "stat"
+	pollCounts.Add(pollerName, results)
 	health.CalcAvailability(results, pollerName, &statResultHistoryThreadsafe, mc, toData, localCacheStatusThreadsafe, localStates, events, pollingProtocol)
 	combineState()
 
+	if historyStore != nil {
+		if err := historyStore.AddCacheSamples(results, localCacheStatusThreadsafe.Get()); err != nil {
+			log.Errorf("storing cache history: %v\n", err)
+		}
+	}
+
 	endTime := time.Now()
 	lastStatDurations := threadsafe.CopyDurationMap(lastStatDurationsThreadsafe.Get())
 	for _, result := range results {
@@ -27,14 +27,26 @@ import (
 
 	"github.com/apache/trafficcontrol/lib/go-log"
 	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_monitor/config"
 	"github.com/apache/trafficcontrol/traffic_monitor/health"
 	"github.com/apache/trafficcontrol/traffic_monitor/peer"
+	"github.com/apache/trafficcontrol/traffic_monitor/threadsafe"
 	"github.com/apache/trafficcontrol/traffic_monitor/todata"
 )
 
-// StartStateCombiner starts the State Combiner goroutine, and returns the threadsafe CombinedStates, and a func to signal to combine states.
-func StartStateCombiner(events health.ThreadsafeEvents, peerStates peer.CRStatesPeersThreadsafe, localStates peer.CRStatesThreadsafe, toData todata.TODataThreadsafe) (peer.CRStatesThreadsafe, func()) {
+// StartStateCombiner starts the State Combiner goroutine, and returns the threadsafe CombinedStates, the explanations of how each cache's combined state was chosen, and a func to signal to combine states.
+// Cache states are combined with peer states by the policies of consensusCfg, where hostname is the name of this Traffic Monitor.
+func StartStateCombiner(
+	events health.ThreadsafeEvents,
+	peerStates peer.CRStatesPeersThreadsafe,
+	localStates peer.CRStatesThreadsafe,
+	toData todata.TODataThreadsafe,
+	monitorConfig threadsafe.TrafficMonitorConfigMap,
+	consensusCfg config.PeerConsensus,
+	hostname string,
+) (peer.CRStatesThreadsafe, peer.ExplanationsThreadsafe, func()) {
 	combinedStates := peer.NewCRStatesThreadsafe()
+	explanations := peer.NewExplanationsThreadsafe()
 
 	// the chan buffer just reduces the number of goroutines on our infinite buffer hack in combineState(), no real writer will block, since combineState() writes in a goroutine.
 	combineStateChan := make(chan struct{}, 5)
@@ -56,13 +68,191 @@ func StartStateCombiner(events health.ThreadsafeEvents, peerStates peer.CRStates
 
 	go func() {
 		overrideMap := map[tc.CacheName]bool{}
+		consensus := peerConsensus{cfg: consensusCfg, localName: tc.TrafficMonitorName(hostname), explanations: explanations}
 		for range combineStateChan {
 			drain(combineStateChan)
-			combineCrStates(events, true, peerStates, localStates.Get(), combinedStates, overrideMap, toData.Get())
+			consensus.locations = monitorLocations(monitorConfig.Get())
+			combineCrStates(events, true, peerStates, localStates.Get(), combinedStates, overrideMap, toData.Get(), consensus)
 		}
 	}()
 
-	return combinedStates, combineState
+	return combinedStates, explanations, combineState
+}
+
+// peerConsensus is what's needed to combine cache states with peer states by
+// the configured policies, besides the states themselves.
+type peerConsensus struct {
+	cfg       config.PeerConsensus
+	localName tc.TrafficMonitorName
+	// locations maps Traffic Monitors to their locations (Cache Groups).
+	locations    map[tc.TrafficMonitorName]string
+	explanations peer.ExplanationsThreadsafe
+}
+
+// monitorLocations returns the location of each Traffic Monitor in the given
+// monitoring config.
+func monitorLocations(monitorConfig tc.TrafficMonitorConfigMap) map[tc.TrafficMonitorName]string {
+	locations := make(map[tc.TrafficMonitorName]string, len(monitorConfig.TrafficMonitor))
+	for _, monitor := range monitorConfig.TrafficMonitor {
+		locations[tc.TrafficMonitorName(monitor.HostName)] = monitor.Location
+	}
+	return locations
+}
+
+// weight returns the weight of the given vote by the given policy, and how it
+// was chosen, if it isn't simply 1.
+func (c peerConsensus) weight(policy config.PeerPolicy, vote peer.Vote, latency time.Duration) (float64, string) {
+	switch policy {
+	case config.PeerPolicyWeightedLocation:
+		if weight, ok := c.cfg.LocationWeights[vote.Location]; ok {
+			return weight, "weight of location '" + vote.Location + "'"
+		}
+		return c.cfg.DefaultWeight, "default weight"
+	case config.PeerPolicyWeightedLatency:
+		reference := time.Duration(c.cfg.LatencyReferenceMs) * time.Millisecond
+		if vote.Local || latency <= reference {
+			return 1, ""
+		}
+		return float64(reference) / float64(latency), fmt.Sprintf("polled in %v, slower than %v", latency, reference)
+	}
+	return 1, ""
+}
+
+// castVotes returns the votes of this Traffic Monitor and each of its peers on
+// the availability of the given cache, weighted by the given policy. Peers are
+// in name order, after this Traffic Monitor.
+func castVotes(
+	cacheName tc.CacheName,
+	localCacheState tc.IsAvailable,
+	policy config.PeerPolicy,
+	peerStates peer.CRStatesPeersThreadsafe,
+	peerCrStates map[tc.TrafficMonitorName]tc.CRStates,
+	latencies map[tc.TrafficMonitorName]time.Duration,
+	consensus peerConsensus,
+) []peer.Vote {
+	localVote := peer.Vote{
+		Monitor:       consensus.localName,
+		Local:         true,
+		Location:      consensus.locations[consensus.localName],
+		Counted:       true,
+		Available:     localCacheState.Ipv4Available || localCacheState.Ipv6Available,
+		IPv4Available: localCacheState.Ipv4Available,
+		IPv6Available: localCacheState.Ipv6Available,
+	}
+	localVote.Weight, localVote.Note = consensus.weight(policy, localVote, 0)
+	votes := []peer.Vote{localVote}
+
+	peerNames := make([]string, 0, len(peerCrStates))
+	for peerName := range peerCrStates {
+		peerNames = append(peerNames, string(peerName))
+	}
+	sort.Strings(peerNames)
+
+	for _, name := range peerNames {
+		peerName := tc.TrafficMonitorName(name)
+		vote := peer.Vote{
+			Monitor:   peerName,
+			Location:  consensus.locations[peerName],
+			LatencyMs: float64(latencies[peerName]) / float64(time.Millisecond),
+		}
+		// the last state from unreachable peers is still given, to help diagnose split-brains
+		cacheState, ok := peerCrStates[peerName].Caches[cacheName]
+		if ok {
+			vote.Available = cacheState.IsAvailable
+			vote.IPv4Available = cacheState.Ipv4Available
+			vote.IPv6Available = cacheState.Ipv6Available
+		}
+		switch {
+		case !peerStates.GetPeerAvailability(peerName):
+			vote.Note = "peer unreachable"
+		case !ok:
+			vote.Note = "peer has no state for the cache"
+		default:
+			vote.Counted = true
+			vote.Weight, vote.Note = consensus.weight(policy, vote, latencies[peerName])
+		}
+		votes = append(votes, vote)
+	}
+	return votes
+}
+
+// tally returns whether the counted votes find the cache available by the
+// given policy, as given by the available func, and why. Ties between weighted
+// votes are broken by the local vote.
+func tally(policy config.PeerPolicy, votes []peer.Vote, available func(peer.Vote) bool) (bool, string) {
+	yes, no := 0.0, 0.0
+	unavailableOn := []string{}
+	localAvailable := false
+	for _, vote := range votes {
+		if !vote.Counted {
+			continue
+		}
+		if vote.Local {
+			localAvailable = available(vote)
+		}
+		if available(vote) {
+			yes += vote.Weight
+		} else {
+			no += vote.Weight
+			unavailableOn = append(unavailableOn, vote.Monitor.String())
+		}
+	}
+
+	if policy == config.PeerPolicyPessimistic {
+		if len(unavailableOn) > 0 {
+			return false, "unavailable on " + strings.Join(unavailableOn, ", ")
+		}
+		return true, "available on all reachable monitors"
+	}
+
+	switch {
+	case yes > no:
+		return true, fmt.Sprintf("available by %g votes to %g", yes, no)
+	case no > yes:
+		return false, fmt.Sprintf("unavailable by %g votes to %g", no, yes)
+	}
+	if localAvailable {
+		return true, fmt.Sprintf("tied at %g votes, available locally", yes)
+	}
+	return false, fmt.Sprintf("tied at %g votes, unavailable locally", yes)
+}
+
+// combineCacheStateByVote combines the state of the given cache with its peer
+// states by tallying the given votes by a policy other than optimistic, and
+// returns the combined state and why it was chosen.
+func combineCacheStateByVote(
+	cacheName tc.CacheName,
+	localCacheState tc.IsAvailable,
+	votes []peer.Vote,
+	policy config.PeerPolicy,
+	events health.ThreadsafeEvents,
+	combinedStates peer.CRStatesThreadsafe,
+	overrideMap map[tc.CacheName]bool,
+	toData todata.TOData,
+) (tc.IsAvailable, string) {
+	ipv4Available, ipv4Reason := tally(policy, votes, func(v peer.Vote) bool { return v.IPv4Available })
+	ipv6Available, ipv6Reason := tally(policy, votes, func(v peer.Vote) bool { return v.IPv6Available })
+	available := ipv4Available || ipv6Available
+	reason := fmt.Sprintf("%s consensus: IPv4 %s; IPv6 %s", policy, ipv4Reason, ipv6Reason)
+
+	overrideCondition := ""
+	localAvailable := localCacheState.Ipv4Available || localCacheState.Ipv6Available
+	override := overrideMap[cacheName]
+	if available != localAvailable && !override {
+		overrideCondition = "detected; " + reason
+		overrideMap[cacheName] = true
+	} else if available == localAvailable && override {
+		overrideCondition = "cleared; " + reason
+		overrideMap[cacheName] = false
+	}
+
+	if overrideCondition != "" {
+		events.Add(health.Event{Time: health.Time(time.Now()), Description: fmt.Sprintf("Health protocol override condition %s", overrideCondition), Name: cacheName.String(), Hostname: cacheName.String(), Type: toData.ServerTypes[cacheName].String(), Available: available, IPv4Available: ipv4Available, IPv6Available: ipv6Available})
+	}
+
+	state := tc.IsAvailable{IsAvailable: available, Ipv4Available: ipv4Available, Ipv6Available: ipv6Available}
+	combinedStates.AddCache(cacheName, state)
+	return state, reason
 }
 
 func combineCacheState(
@@ -74,9 +264,10 @@ func combineCacheState(
 	combinedStates peer.CRStatesThreadsafe,
 	overrideMap map[tc.CacheName]bool,
 	toData todata.TOData,
-) {
+) (tc.IsAvailable, string) {
 
 	overrideCondition := ""
+	reason := "peer states not combined, local state used"
 	available := localCacheState.Ipv4Available || localCacheState.Ipv6Available
 	ipv4Available := localCacheState.Ipv4Available
 	ipv6Available := localCacheState.Ipv6Available
@@ -84,12 +275,14 @@ func combineCacheState(
 
 	if localCacheState.Ipv4Available && localCacheState.Ipv6Available {
 		// we don't care about the peers, we got a "good one", and we're optimistic
+		reason = "available locally"
 		if override {
 			overrideCondition = "cleared; healthy locally"
 			overrideMap[cacheName] = false
 		}
 	} else if peerOptimistic {
 		if !peerStates.HasAvailablePeers() {
+			reason = "no reachable peers, local state used"
 			if override {
 				overrideCondition = "irrelevant; no peers online"
 				overrideMap[cacheName] = false
@@ -114,6 +307,7 @@ func combineCacheState(
 			}
 
 			if len(onlineOnPeers) > 0 {
+				reason = "available on (at least) " + strings.Join(onlineOnPeers, ", ")
 				available = true
 				ipv4Available = ipv4Available || len(ipv4OnlineOnPeers) > 0 // optimistically accept true from local or peer
 				ipv6Available = ipv6Available || len(ipv6OnlineOnPeers) > 0 // optimistically accept true from local or peer
@@ -123,6 +317,7 @@ func combineCacheState(
 					overrideMap[cacheName] = true
 				}
 			} else {
+				reason = "not available on any reachable peers, local state used"
 				if override {
 					overrideCondition = "irrelevant; not online on any peers"
 					overrideMap[cacheName] = false
@@ -135,7 +330,9 @@ func combineCacheState(
 		events.Add(health.Event{Time: health.Time(time.Now()), Description: fmt.Sprintf("Health protocol override condition %s", overrideCondition), Name: cacheName.String(), Hostname: cacheName.String(), Type: toData.ServerTypes[cacheName].String(), Available: available, IPv4Available: ipv4Available, IPv6Available: ipv6Available})
 	}
 
-	combinedStates.AddCache(cacheName, tc.IsAvailable{IsAvailable: available, Ipv4Available: ipv4Available, Ipv6Available: ipv6Available})
+	state := tc.IsAvailable{IsAvailable: available, Ipv4Available: ipv4Available, Ipv6Available: ipv6Available}
+	combinedStates.AddCache(cacheName, state)
+	return state, "optimistic: " + reason
 }
 
 func combineDSState(
@@ -199,10 +396,36 @@ func pruneCombinedCaches(combinedStates peer.CRStatesThreadsafe, localStates tc.
 	}
 }
 
-func combineCrStates(events health.ThreadsafeEvents, peerOptimistic bool, peerStates peer.CRStatesPeersThreadsafe, localStates tc.CRStates, combinedStates peer.CRStatesThreadsafe, overrideMap map[tc.CacheName]bool, toData todata.TOData) {
+func combineCrStates(events health.ThreadsafeEvents, peerOptimistic bool, peerStates peer.CRStatesPeersThreadsafe, localStates tc.CRStates, combinedStates peer.CRStatesThreadsafe, overrideMap map[tc.CacheName]bool, toData todata.TOData, consensus peerConsensus) {
+	peerCrStates := peerStates.GetCrstates()
+	latencies := peerStates.GetLatencies()
+	now := time.Now()
+	explanations := make(map[tc.CacheName]peer.CacheStateExplanation, len(localStates.Caches))
 	for cacheName, localCacheState := range localStates.Caches { // localStates gets pruned when servers are disabled, it's the source of truth
-		combineCacheState(cacheName, localCacheState, events, peerOptimistic, peerStates, combinedStates, overrideMap, toData)
+		cacheGroup := toData.ServerCachegroups[cacheName]
+		policy := consensus.cfg.CacheGroupPolicy(string(cacheGroup))
+		votes := castVotes(cacheName, localCacheState, policy, peerStates, peerCrStates, latencies, consensus)
+
+		var state tc.IsAvailable
+		var reason string
+		if policy == config.PeerPolicyOptimistic {
+			state, reason = combineCacheState(cacheName, localCacheState, events, peerOptimistic, peerStates, combinedStates, overrideMap, toData)
+		} else {
+			state, reason = combineCacheStateByVote(cacheName, localCacheState, votes, policy, events, combinedStates, overrideMap, toData)
+		}
+
+		explanations[cacheName] = peer.CacheStateExplanation{
+			CacheGroup:    cacheGroup,
+			Policy:        policy,
+			Votes:         votes,
+			Available:     state.IsAvailable,
+			IPv4Available: state.Ipv4Available,
+			IPv6Available: state.Ipv6Available,
+			Reason:        reason,
+			Time:          now,
+		}
 	}
+	consensus.explanations.Set(explanations)
 
 	for deliveryServiceName, localDeliveryService := range localStates.DeliveryService {
 		combineDSState(deliveryServiceName, localDeliveryService, peerStates, combinedStates)
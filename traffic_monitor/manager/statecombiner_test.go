@@ -21,6 +21,7 @@ package manager
 
 import (
 	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_monitor/config"
 	"github.com/apache/trafficcontrol/traffic_monitor/health"
 	"github.com/apache/trafficcontrol/traffic_monitor/peer"
 	"github.com/apache/trafficcontrol/traffic_monitor/todata"
@@ -154,3 +155,88 @@ func TestCombineCacheStateCacheDown(t *testing.T) {
 		t.Fatalf("cache IPv6 is unavailable and should be available")
 	}
 }
+
+func TestCombineCrStatesPolicies(t *testing.T) {
+	cacheName := tc.CacheName("testCache")
+	peerStates := peer.NewCRStatesPeersThreadsafe(1)
+	peerSet := map[tc.TrafficMonitorName]struct{}{}
+	peerVotes := map[tc.TrafficMonitorName]bool{"tm-east": false, "tm-west": false, "tm-far": true, "tm-down": true}
+	latencies := map[tc.TrafficMonitorName]time.Duration{"tm-east": 10 * time.Millisecond, "tm-west": 400 * time.Millisecond, "tm-far": 20 * time.Millisecond, "tm-down": 0}
+	for name, available := range peerVotes {
+		peerStates.Set(peer.Result{
+			ID:          name,
+			Available:   name != "tm-down",
+			RequestTime: latencies[name],
+			PeerStates: tc.CRStates{Caches: map[tc.CacheName]tc.IsAvailable{
+				cacheName: {IsAvailable: available, Ipv4Available: available, Ipv6Available: available},
+			}},
+			Time: time.Now(),
+		})
+		peerSet[name] = struct{}{}
+	}
+	peerStates.SetPeers(peerSet)
+
+	localStates := tc.NewCRStates()
+	localStates.Caches[cacheName] = tc.IsAvailable{IsAvailable: true, Ipv4Available: true, Ipv6Available: true}
+	toData := todata.TOData{
+		ServerTypes:       map[tc.CacheName]tc.CacheType{cacheName: tc.CacheTypeEdge},
+		ServerCachegroups: map[tc.CacheName]tc.CacheGroupName{cacheName: "cg-edge"},
+	}
+	locations := map[tc.TrafficMonitorName]string{"tm-local": "east", "tm-east": "east", "tm-west": "west", "tm-far": "far", "tm-down": "far"}
+
+	// the local monitor and tm-far find the cache available, tm-east and tm-west find it unavailable, and tm-down is unreachable
+	tests := []struct {
+		name      string
+		cfg       config.PeerConsensus
+		available bool
+	}{
+		{"optimistic", config.DefaultPeerConsensus, true},
+		{"pessimistic", config.PeerConsensus{Policy: config.PeerPolicyPessimistic}, false},
+		{"majority tie uses local state", config.PeerConsensus{Policy: config.PeerPolicyMajority}, true},
+		{"weighted location", config.PeerConsensus{Policy: config.PeerPolicyWeightedLocation, LocationWeights: map[string]float64{"west": 3}, DefaultWeight: 1}, false},
+		{"weighted latency", config.PeerConsensus{Policy: config.PeerPolicyWeightedLatency, LatencyReferenceMs: 100}, true},
+		{"cache group override", config.PeerConsensus{Policy: config.PeerPolicyOptimistic, CacheGroupPolicies: map[string]config.PeerPolicy{"cg-edge": config.PeerPolicyPessimistic}}, false},
+	}
+	for _, test := range tests {
+		combinedStates := peer.NewCRStatesThreadsafe()
+		consensus := peerConsensus{cfg: test.cfg, localName: "tm-local", locations: locations, explanations: peer.NewExplanationsThreadsafe()}
+		combineCrStates(health.NewThreadsafeEvents(1), true, peerStates, localStates, combinedStates, map[tc.CacheName]bool{}, toData, consensus)
+
+		if combinedStates.Get().Caches[cacheName].IsAvailable != test.available {
+			t.Errorf("%s: expected available %v, actual %v", test.name, test.available, !test.available)
+		}
+
+		explanation, ok := consensus.explanations.Get()[cacheName]
+		if !ok {
+			t.Errorf("%s: expected an explanation of the combined state", test.name)
+			continue
+		}
+		if explanation.Available != test.available || explanation.Reason == "" || len(explanation.Votes) != 5 {
+			t.Errorf("%s: expected an explanation with the combined state, a reason and 5 votes, actual: %+v", test.name, explanation)
+		}
+		if !explanation.Votes[0].Local || explanation.Votes[1].Monitor != "tm-down" || explanation.Votes[1].Counted {
+			t.Errorf("%s: expected the local vote first, and the unreachable peer's vote not counted, actual: %+v", test.name, explanation.Votes)
+		}
+	}
+}
+
+func TestTally(t *testing.T) {
+	votes := []peer.Vote{
+		{Monitor: "local", Local: true, Counted: true, IPv4Available: false, Weight: 1},
+		{Monitor: "a", Counted: true, IPv4Available: true, Weight: 0.5},
+		{Monitor: "b", Counted: true, IPv4Available: true, Weight: 0.5},
+		{Monitor: "c", Counted: false, IPv4Available: true, Weight: 5},
+	}
+	ipv4 := func(v peer.Vote) bool { return v.IPv4Available }
+
+	if available, reason := tally(config.PeerPolicyWeightedLatency, votes, ipv4); available {
+		t.Errorf("expected a tie broken by the local vote to be unavailable, actual: available (%s)", reason)
+	}
+	votes[2].Weight = 1
+	if available, reason := tally(config.PeerPolicyWeightedLatency, votes, ipv4); !available {
+		t.Errorf("expected 1.5 votes to 1 to be available, actual: unavailable (%s)", reason)
+	}
+	if available, reason := tally(config.PeerPolicyPessimistic, votes, ipv4); available || reason != "unavailable on local" {
+		t.Errorf("expected pessimistic tally to be unavailable on local, actual: %v (%s)", available, reason)
+	}
+}
@@ -0,0 +1,90 @@
+package peer
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_monitor/config"
+)
+
+// Vote is the availability of a cache server according to one Traffic Monitor,
+// as counted when combining the states of this Traffic Monitor and its peers.
+type Vote struct {
+	Monitor tc.TrafficMonitorName `json:"monitor"`
+	// Local is whether the vote is this Traffic Monitor's own.
+	Local    bool   `json:"local"`
+	Location string `json:"location"`
+	// Counted is whether the vote was counted. Votes of unreachable peers, and
+	// of peers without a state for the cache server, are not.
+	Counted       bool    `json:"counted"`
+	Available     bool    `json:"available"`
+	IPv4Available bool    `json:"ipv4Available"`
+	IPv6Available bool    `json:"ipv6Available"`
+	Weight        float64 `json:"weight"`
+	// LatencyMs is the time it took to poll the peer, in milliseconds.
+	LatencyMs float64 `json:"latencyMs,omitempty"`
+	// Note is why the vote wasn't counted, or how its weight was chosen.
+	Note string `json:"note,omitempty"`
+}
+
+// CacheStateExplanation explains how the combined state of a cache server was
+// chosen from the votes of this Traffic Monitor and its peers.
+type CacheStateExplanation struct {
+	CacheGroup    tc.CacheGroupName `json:"cachegroup"`
+	Policy        config.PeerPolicy `json:"policy"`
+	Votes         []Vote            `json:"votes"`
+	Available     bool              `json:"available"`
+	IPv4Available bool              `json:"ipv4Available"`
+	IPv6Available bool              `json:"ipv6Available"`
+	Reason        string            `json:"reason"`
+	Time          time.Time         `json:"time"`
+}
+
+// ExplanationsThreadsafe holds the latest explanation of the combined state of
+// each cache server, safe for multiple readers and a single writer.
+type ExplanationsThreadsafe struct {
+	explanations *map[tc.CacheName]CacheStateExplanation
+	m            *sync.RWMutex
+}
+
+// NewExplanationsThreadsafe returns a new, empty ExplanationsThreadsafe.
+func NewExplanationsThreadsafe() ExplanationsThreadsafe {
+	explanations := map[tc.CacheName]CacheStateExplanation{}
+	return ExplanationsThreadsafe{explanations: &explanations, m: &sync.RWMutex{}}
+}
+
+// Get returns the explanations of each cache server. Callers MUST NOT modify
+// the returned map.
+func (e ExplanationsThreadsafe) Get() map[tc.CacheName]CacheStateExplanation {
+	e.m.RLock()
+	defer e.m.RUnlock()
+	return *e.explanations
+}
+
+// Set replaces the explanations of all cache servers. This MUST NOT be called
+// by multiple goroutines.
+func (e ExplanationsThreadsafe) Set(explanations map[tc.CacheName]CacheStateExplanation) {
+	e.m.Lock()
+	*e.explanations = explanations
+	e.m.Unlock()
+}
@@ -121,6 +121,7 @@ type CRStatesPeersThreadsafe struct {
 	peerStates map[tc.TrafficMonitorName]bool
 	peerTimes  map[tc.TrafficMonitorName]time.Time
 	peerOnline map[tc.TrafficMonitorName]bool
+	latencies  map[tc.TrafficMonitorName]time.Duration
 	peerCount  *int
 	quorumMin  *int
 	timeout    *time.Duration
@@ -138,6 +139,7 @@ func NewCRStatesPeersThreadsafe(quorumMin int) CRStatesPeersThreadsafe {
 		crStates:   map[tc.TrafficMonitorName]tc.CRStates{},
 		peerStates: map[tc.TrafficMonitorName]bool{},
 		peerTimes:  map[tc.TrafficMonitorName]time.Time{},
+		latencies:  map[tc.TrafficMonitorName]time.Duration{},
 		peerCount:  &count,
 		quorumMin:  &quorumMin,
 	}
@@ -216,6 +218,17 @@ func (t *CRStatesPeersThreadsafe) GetQueryTimes() map[tc.TrafficMonitorName]time
 	return copyPeerTimes(t.peerTimes)
 }
 
+// GetLatencies returns the time it took to poll each peer, as of its last poll.
+func (t *CRStatesPeersThreadsafe) GetLatencies() map[tc.TrafficMonitorName]time.Duration {
+	t.m.RLock()
+	defer t.m.RUnlock()
+	m := make(map[tc.TrafficMonitorName]time.Duration, len(t.latencies))
+	for k, v := range t.latencies {
+		m[k] = v
+	}
+	return m
+}
+
 // HasAvailablePeers returns true if at least one peer is ONLINE and available (reachable via polling)
 func (t *CRStatesPeersThreadsafe) HasAvailablePeers() bool {
 	t.m.RLock()
@@ -254,6 +267,7 @@ func (t *CRStatesPeersThreadsafe) Set(result Result) {
 	t.crStates[result.ID] = result.PeerStates
 	t.peerStates[result.ID] = result.Available
 	t.peerTimes[result.ID] = result.Time
+	t.latencies[result.ID] = result.RequestTime
 	t.m.Unlock()
 }
 
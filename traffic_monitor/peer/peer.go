@@ -46,7 +46,9 @@ type Result struct {
 	PeerStates   tc.CRStates
 	PollID       uint64
 	PollFinished chan<- uint64
-	Time         time.Time
+	// RequestTime is the time it took to poll the peer.
+	RequestTime time.Duration
+	Time        time.Time
 }
 
 // Handle handles a response from a polled Traffic Monitor peer, parsing the data and forwarding it to the ResultChannel.
@@ -57,6 +59,7 @@ func (handler Handler) Handle(id string, r io.Reader, format string, reqTime tim
 		Errors:       []error{},
 		PollID:       pollID,
 		PollFinished: pollFinished,
+		RequestTime:  reqTime,
 		Time:         reqEnd,
 	}
 
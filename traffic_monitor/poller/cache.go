@@ -23,6 +23,7 @@ import (
 	"bytes"
 	"io"
 	"math/rand"
+	"reflect"
 	"runtime"
 	"sync/atomic"
 	"time"
@@ -47,6 +48,9 @@ type PollConfig struct {
 	Timeout  time.Duration
 	Format   string
 	PollType string
+	// Probes are the synthetic probes to perform, if PollType is
+	// PollerTypeProbe.
+	Probes []config.SyntheticProbe
 }
 
 type CachePollerConfig struct {
@@ -121,6 +125,7 @@ func (p CachePoller) Poll() {
 				Timeout:     info.Timeout,
 				NoKeepAlive: info.NoKeepAlive,
 				PollerID:    info.ID,
+				Probes:      info.Probes,
 			}
 			pollerCtx := interface{}(nil)
 			if pollerObj.Init != nil {
@@ -232,7 +237,7 @@ func diffConfigs(old CachePollerConfig, new CachePollerConfig) ([]string, []Cach
 		newPollCfg, newIdExists := new.Urls[id]
 		if !newIdExists {
 			deletions = append(deletions, id)
-		} else if newPollCfg != oldPollCfg {
+		} else if !reflect.DeepEqual(newPollCfg, oldPollCfg) {
 			deletions = append(deletions, id)
 			additions = append(additions, CachePollInfo{
 				Interval:        new.Interval,
@@ -43,9 +43,10 @@ func httpGlobalInit(cfg config.Config, appData config.StaticAppData) interface{}
 		Timeout:   cfg.HTTPTimeout,
 	}
 	return &HTTPPollGlobalCtx{
-		UserAgent:    appData.UserAgent,
-		Client:       sharedClient,
-		FormatAccept: cfg.HTTPPollingFormat,
+		UserAgent:       appData.UserAgent,
+		Client:          sharedClient,
+		FormatAccept:    cfg.HTTPPollingFormat,
+		PrometheusStats: cfg.PrometheusStats,
 	}
 }
 
@@ -72,21 +73,23 @@ func httpInit(cfg PollerConfig, globalCtxI interface{}) interface{} {
 	}
 
 	return &HTTPPollCtx{
-		Client:       gctx.Client,
-		UserAgent:    gctx.UserAgent,
-		NoKeepAlive:  cfg.NoKeepAlive,
-		URL:          cfg.URL,
-		URLv6:        cfg.URLv6,
-		Host:         cfg.Host,
-		PollerID:     cfg.PollerID,
-		FormatAccept: gctx.FormatAccept,
+		Client:          gctx.Client,
+		UserAgent:       gctx.UserAgent,
+		NoKeepAlive:     cfg.NoKeepAlive,
+		URL:             cfg.URL,
+		URLv6:           cfg.URLv6,
+		Host:            cfg.Host,
+		PollerID:        cfg.PollerID,
+		FormatAccept:    gctx.FormatAccept,
+		PrometheusStats: gctx.PrometheusStats,
 	}
 }
 
 type HTTPPollGlobalCtx struct {
-	Client       *http.Client
-	UserAgent    string
-	FormatAccept string
+	Client          *http.Client
+	UserAgent       string
+	FormatAccept    string
+	PrometheusStats config.PrometheusStats
 }
 
 type HTTPPollCtx struct {
@@ -99,6 +102,9 @@ type HTTPPollCtx struct {
 	PollerID     string
 	HTTPHeader   http.Header
 	FormatAccept string
+	// PrometheusStats maps the stats of caches polled in the "prometheus"
+	// format.
+	PrometheusStats config.PrometheusStats
 }
 
 func httpPoll(ctxI interface{}, url string, host string, pollID uint64) ([]byte, time.Time, time.Duration, error) {
@@ -0,0 +1,283 @@
+package poller
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/traffic_monitor/config"
+)
+
+// PollerTypeProbe is the poller type which performs synthetic probes of cache
+// servers, rather than fetching their stats. The URLs it's given are the
+// addresses of the cache servers, without a scheme or port.
+const PollerTypeProbe = "probe"
+
+// probeMaxBodyBytes is the most of an HTTP probe's response body which is read
+// and matched against its BodyRegex.
+const probeMaxBodyBytes = 1 << 20
+
+func init() {
+	AddPollerType(PollerTypeProbe, probeGlobalInit, probeInit, probePoll)
+}
+
+// ProbeResult is the result of a single synthetic probe of a cache server.
+// A probe poll returns the JSON encoding of an array of these.
+type ProbeResult struct {
+	Name      string                    `json:"name"`
+	Type      config.SyntheticProbeType `json:"type"`
+	Available bool                      `json:"available"`
+	// LatencyMs is the time the probe took, in milliseconds.
+	LatencyMs float64 `json:"latency_ms"`
+	// CertExpiryDays is the number of days until the certificate presented by
+	// the cache server expires. It's only set for probes using TLS.
+	CertExpiryDays *float64 `json:"cert_expiry_days,omitempty"`
+	// StatusCode is the status code of the response to HTTP probes.
+	StatusCode int `json:"status_code,omitempty"`
+	// Error describes why the probe failed, if it did.
+	Error string `json:"error,omitempty"`
+}
+
+type ProbePollGlobalCtx struct {
+	UserAgent string
+	Timeout   time.Duration
+}
+
+type ProbePollCtx struct {
+	UserAgent string
+	PollerID  string
+	Probes    []probe
+}
+
+// probe is a synthetic probe, with everything needed to perform it prepared
+// ahead of time.
+type probe struct {
+	config.SyntheticProbe
+	Timeout   time.Duration
+	Client    *http.Client
+	BodyRegex *regexp.Regexp
+}
+
+func probeGlobalInit(cfg config.Config, appData config.StaticAppData) interface{} {
+	return &ProbePollGlobalCtx{
+		UserAgent: appData.UserAgent,
+		Timeout:   cfg.HTTPTimeout,
+	}
+}
+
+func probeInit(cfg PollerConfig, globalCtxI interface{}) interface{} {
+	gctx := (globalCtxI).(*ProbePollGlobalCtx)
+	ctx := &ProbePollCtx{
+		UserAgent: gctx.UserAgent,
+		PollerID:  cfg.PollerID,
+		Probes:    make([]probe, 0, len(cfg.Probes)),
+	}
+	for _, sp := range cfg.Probes {
+		p := probe{SyntheticProbe: sp, Timeout: gctx.Timeout}
+		if sp.TimeoutMs != 0 {
+			p.Timeout = time.Duration(sp.TimeoutMs) * time.Millisecond
+		} else if cfg.Timeout != 0 {
+			p.Timeout = cfg.Timeout
+		}
+		if sp.Type == config.SyntheticProbeTypeHTTP {
+			p.Client = &http.Client{
+				Transport: &http.Transport{
+					TLSClientConfig:   &tls.Config{InsecureSkipVerify: true, ServerName: sp.Host},
+					DisableKeepAlives: true, // every probe should measure establishing a new connection
+				},
+				Timeout: p.Timeout,
+				// the probe checks the response of the cache server itself, not wherever it redirects to
+				CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+			}
+			if sp.BodyRegex != "" {
+				re, err := regexp.Compile(sp.BodyRegex)
+				if err != nil {
+					// the config is validated when it's loaded, so this should never happen
+					log.Errorf("poller %v probe '%v' body_regex '%v' failed to compile, not checking response body: %v\n", cfg.PollerID, sp.Name, sp.BodyRegex, err)
+				} else {
+					p.BodyRegex = re
+				}
+			}
+		}
+		ctx.Probes = append(ctx.Probes, p)
+	}
+	return ctx
+}
+
+// probePoll performs every probe against the given address concurrently. A
+// failed probe is reported in its ProbeResult, not as an error; an error is
+// only returned if the results can't be encoded.
+func probePoll(ctxI interface{}, addr string, host string, pollID uint64) ([]byte, time.Time, time.Duration, error) {
+	ctx := (ctxI).(*ProbePollCtx)
+	start := time.Now()
+	results := make([]ProbeResult, len(ctx.Probes))
+	wg := sync.WaitGroup{}
+	for i, p := range ctx.Probes {
+		wg.Add(1)
+		go func(i int, p probe) {
+			defer wg.Done()
+			results[i] = doProbe(p, addr, ctx.UserAgent)
+		}(i, p)
+	}
+	wg.Wait()
+	end := time.Now()
+
+	bts, err := json.Marshal(results)
+	if err != nil {
+		return nil, end, end.Sub(start), fmt.Errorf("id %v addr %v encoding probe results: %v", ctx.PollerID, addr, err)
+	}
+	return bts, end, end.Sub(start), nil
+}
+
+func doProbe(p probe, addr string, userAgent string) ProbeResult {
+	result := ProbeResult{Name: p.Name, Type: p.Type}
+	start := time.Now()
+	var err error
+	switch p.Type {
+	case config.SyntheticProbeTypeTCP:
+		err = probeTCP(p, addr)
+	case config.SyntheticProbeTypeTLS:
+		result.CertExpiryDays, err = probeTLS(p, addr, start)
+	case config.SyntheticProbeTypeHTTP:
+		result.StatusCode, result.CertExpiryDays, err = probeHTTP(p, addr, userAgent, start)
+	default:
+		err = errors.New("unknown probe type '" + string(p.Type) + "'")
+	}
+	result.LatencyMs = float64(time.Since(start)) / float64(time.Millisecond)
+	if err == nil && result.CertExpiryDays != nil && *result.CertExpiryDays < p.CertExpiryDays {
+		err = fmt.Errorf("certificate expires in %.1f days, less than %.1f", *result.CertExpiryDays, p.CertExpiryDays)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Available = true
+	return result
+}
+
+// probePort returns the port the probe connects to, defaulting to the
+// standard port of its protocol.
+func probePort(p probe) string {
+	switch {
+	case p.Port != 0:
+		return strconv.Itoa(p.Port)
+	case p.Type == config.SyntheticProbeTypeTCP || (p.Type == config.SyntheticProbeTypeHTTP && !p.TLS):
+		return "80"
+	default:
+		return "443"
+	}
+}
+
+func probeTCP(p probe, addr string) error {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(addr, probePort(p)), p.Timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func probeTLS(p probe, addr string, now time.Time) (*float64, error) {
+	dialer := &net.Dialer{Timeout: p.Timeout}
+	// the certificate isn't verified, because cache servers are probed by IP address; only its expiration is checked
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(addr, probePort(p)), &tls.Config{InsecureSkipVerify: true, ServerName: p.Host})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return certExpiryDays(conn.ConnectionState(), now)
+}
+
+func probeHTTP(p probe, addr string, userAgent string, now time.Time) (int, *float64, error) {
+	scheme := "http"
+	if p.TLS {
+		scheme = "https"
+	}
+	path := p.Path
+	if path == "" || path[0] != '/' {
+		path = "/" + path
+	}
+	req, err := http.NewRequest(http.MethodGet, scheme+"://"+net.JoinHostPort(addr, probePort(p))+path, nil)
+	if err != nil {
+		return 0, nil, errors.New("creating HTTP request: " + err.Error())
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if p.Host != "" {
+		req.Host = p.Host
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	var expiryDays *float64
+	if resp.TLS != nil {
+		if expiryDays, err = certExpiryDays(*resp.TLS, now); err != nil {
+			return resp.StatusCode, nil, err
+		}
+	}
+
+	expectedStatus := p.ExpectedStatus
+	if expectedStatus == 0 {
+		expectedStatus = http.StatusOK
+	}
+	if resp.StatusCode != expectedStatus {
+		return resp.StatusCode, expiryDays, fmt.Errorf("bad HTTP status: expected %v, got %v", expectedStatus, resp.StatusCode)
+	}
+
+	if p.BodyRegex == nil {
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, probeMaxBodyBytes))
+		return resp.StatusCode, expiryDays, nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, probeMaxBodyBytes))
+	if err != nil {
+		return resp.StatusCode, expiryDays, errors.New("reading body: " + err.Error())
+	}
+	if !p.BodyRegex.Match(body) {
+		return resp.StatusCode, expiryDays, errors.New("body does not match '" + p.BodyRegex.String() + "'")
+	}
+	return resp.StatusCode, expiryDays, nil
+}
+
+// certExpiryDays returns the number of days from now until the certificate
+// presented by the server in the given TLS connection expires.
+func certExpiryDays(state tls.ConnectionState, now time.Time) (*float64, error) {
+	if len(state.PeerCertificates) == 0 {
+		return nil, errors.New("no certificate presented")
+	}
+	days := float64(state.PeerCertificates[0].NotAfter.Sub(now)) / float64(24*time.Hour)
+	if days <= 0 {
+		return &days, errors.New("certificate expired at " + state.PeerCertificates[0].NotAfter.Format(time.RFC3339))
+	}
+	return &days, nil
+}
@@ -0,0 +1,126 @@
+package poller
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_monitor/config"
+)
+
+func splitHostPort(t *testing.T, addr string) (string, int) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("splitting test server address '%s': %v", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatalf("parsing test server port '%s': %v", portStr, err)
+	}
+	return host, port
+}
+
+func TestProbePoll(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "demo1.example.test" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("OK - healthy"))
+	}))
+	defer srv.Close()
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsSrv.Close()
+
+	addr, port := splitHostPort(t, srv.Listener.Addr().String())
+	_, tlsPort := splitHostPort(t, tlsSrv.Listener.Addr().String())
+
+	probes := []config.SyntheticProbe{
+		{Name: "tcp", Type: config.SyntheticProbeTypeTCP, Port: port},
+		{Name: "tls", Type: config.SyntheticProbeTypeTLS, Port: tlsPort, CertExpiryDays: 1},
+		{Name: "tls-expiring", Type: config.SyntheticProbeTypeTLS, Port: tlsPort, CertExpiryDays: 1000000},
+		{Name: "http", Type: config.SyntheticProbeTypeHTTP, Port: port, Host: "demo1.example.test", Path: "health", BodyRegex: "^OK"},
+		{Name: "http-status", Type: config.SyntheticProbeTypeHTTP, Port: port, ExpectedStatus: http.StatusNoContent, Host: "demo1.example.test"},
+		{Name: "http-body", Type: config.SyntheticProbeTypeHTTP, Port: port, Host: "demo1.example.test", BodyRegex: "unhealthy"},
+		{Name: "https", Type: config.SyntheticProbeTypeHTTP, Port: tlsPort, TLS: true},
+	}
+	gctx := probeGlobalInit(config.Config{HTTPTimeout: 5 * time.Second}, config.StaticAppData{UserAgent: "test"})
+	ctx := probeInit(PollerConfig{PollerID: "cache0", Probes: probes}, gctx)
+
+	bts, _, _, err := probePoll(ctx, addr, "", 1)
+	if err != nil {
+		t.Fatalf("expected no error polling probes, actual: %v", err)
+	}
+	results := []ProbeResult{}
+	if err := json.Unmarshal(bts, &results); err != nil {
+		t.Fatalf("decoding probe results: %v", err)
+	}
+	if len(results) != len(probes) {
+		t.Fatalf("expected %d probe results, actual: %d", len(probes), len(results))
+	}
+
+	expected := map[string]bool{
+		"tcp":          true,
+		"tls":          true,
+		"tls-expiring": false,
+		"http":         true,
+		"http-status":  false,
+		"http-body":    false,
+		"https":        true,
+	}
+	for i, result := range results {
+		if result.Name != probes[i].Name {
+			t.Errorf("expected result %d to be of probe '%s', actual: '%s'", i, probes[i].Name, result.Name)
+		}
+		if result.Available != expected[result.Name] {
+			t.Errorf("probe '%s' expected available %v, actual: %v (error '%s')", result.Name, expected[result.Name], result.Available, result.Error)
+		}
+		if !result.Available && result.Error == "" {
+			t.Errorf("probe '%s' expected an error describing why it failed, actual: none", result.Name)
+		}
+		if (result.Type == config.SyntheticProbeTypeTLS || result.Name == "https") && result.CertExpiryDays == nil {
+			t.Errorf("probe '%s' expected certificate expiry, actual: none", result.Name)
+		}
+	}
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	_, closedPort := splitHostPort(t, closed.Addr().String())
+	closed.Close()
+	ctx = probeInit(PollerConfig{PollerID: "cache0", Probes: []config.SyntheticProbe{{Name: "tcp", Type: config.SyntheticProbeTypeTCP, Port: closedPort}}}, gctx)
+	bts, _, _, err = probePoll(ctx, addr, "", 2)
+	if err != nil {
+		t.Fatalf("expected no error polling probes, actual: %v", err)
+	}
+	if err := json.Unmarshal(bts, &results); err != nil {
+		t.Fatalf("decoding probe results: %v", err)
+	}
+	if len(results) != 1 || results[0].Available {
+		t.Errorf("expected probe of a closed port to fail, actual: %+v", results)
+	}
+}
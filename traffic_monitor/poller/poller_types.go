@@ -42,6 +42,8 @@ type PollerConfig struct {
 	Timeout     time.Duration
 	NoKeepAlive bool
 	PollerID    string
+	// Probes are the synthetic probes to perform, for the "probe" poller type.
+	Probes []config.SyntheticProbe
 }
 
 // PollerGlobalInit performs global initialization, and returns a global context object.
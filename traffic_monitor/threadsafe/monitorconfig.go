@@ -21,6 +21,7 @@ package threadsafe
 
 import (
 	"sync"
+	"time"
 
 	tc "github.com/apache/trafficcontrol/lib/go-tc"
 )
@@ -28,12 +29,13 @@ import (
 // TrafficMonitorConfigMapThreadsafe encapsulates a LegacyTrafficMonitorConfigMap safe for multiple readers and a single writer.
 type TrafficMonitorConfigMap struct {
 	monitorConfig *tc.TrafficMonitorConfigMap
+	time          *time.Time
 	m             *sync.RWMutex
 }
 
 // NewTrafficMonitorConfigMap returns an encapsulated LegacyTrafficMonitorConfigMap safe for multiple readers and a single writer.
 func NewTrafficMonitorConfigMap() TrafficMonitorConfigMap {
-	return TrafficMonitorConfigMap{monitorConfig: &tc.TrafficMonitorConfigMap{}, m: &sync.RWMutex{}}
+	return TrafficMonitorConfigMap{monitorConfig: &tc.TrafficMonitorConfigMap{}, time: &time.Time{}, m: &sync.RWMutex{}}
 }
 
 // Get returns the LegacyTrafficMonitorConfigMap. Callers MUST NOT modify, it is not threadsafe for mutation. If mutation is necessary, call CopyTrafficMonitorConfigMap().
@@ -47,5 +49,13 @@ func (t *TrafficMonitorConfigMap) Get() tc.TrafficMonitorConfigMap {
 func (t *TrafficMonitorConfigMap) Set(c tc.TrafficMonitorConfigMap) {
 	t.m.Lock()
 	*t.monitorConfig = c
+	*t.time = time.Now()
 	t.m.Unlock()
 }
+
+// Time returns the time the LegacyTrafficMonitorConfigMap was last set, or the zero time if it never has been.
+func (t *TrafficMonitorConfigMap) Time() time.Time {
+	t.m.RLock()
+	defer t.m.RUnlock()
+	return *t.time
+}
@@ -0,0 +1,80 @@
+package threadsafe
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"sync"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_monitor/cache"
+)
+
+// PollCount is the number of times a cache has been polled by a poller, and
+// how many of those polls failed.
+type PollCount struct {
+	Polls  uint64
+	Errors uint64
+}
+
+// PollCounts counts the polls of each cache by each poller, e.g. "health" and
+// "stat", since Traffic Monitor started. It is safe for multiple readers and
+// writers.
+type PollCounts struct {
+	counts map[string]map[tc.CacheName]PollCount
+	m      *sync.RWMutex
+}
+
+// NewPollCounts returns a new, empty PollCounts.
+func NewPollCounts() PollCounts {
+	return PollCounts{counts: map[string]map[tc.CacheName]PollCount{}, m: &sync.RWMutex{}}
+}
+
+// Add counts the given results of the given poller, and which of them failed.
+func (p PollCounts) Add(poller string, results []cache.Result) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	counts, ok := p.counts[poller]
+	if !ok {
+		counts = map[tc.CacheName]PollCount{}
+		p.counts[poller] = counts
+	}
+	for _, result := range results {
+		count := counts[tc.CacheName(result.ID)]
+		count.Polls++
+		if result.Error != nil {
+			count.Errors++
+		}
+		counts[tc.CacheName(result.ID)] = count
+	}
+}
+
+// Get returns a copy of the poll counts of each poller, by cache.
+func (p PollCounts) Get() map[string]map[tc.CacheName]PollCount {
+	p.m.RLock()
+	defer p.m.RUnlock()
+	counts := make(map[string]map[tc.CacheName]PollCount, len(p.counts))
+	for poller, pollerCounts := range p.counts {
+		counts[poller] = make(map[tc.CacheName]PollCount, len(pollerCounts))
+		for cacheName, count := range pollerCounts {
+			counts[poller][cacheName] = count
+		}
+	}
+	return counts
+}
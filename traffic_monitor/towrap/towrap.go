@@ -421,6 +421,15 @@ func (s TrafficOpsSessionThreadsafe) LastCRConfig(cdn string) ([]byte, time.Time
 	return crConfig, crConfigTime, nil
 }
 
+// LastCRConfigStats returns the time the last CRConfig was returned by
+// CRConfigRaw, and its Stats section. Unlike LastCRConfig, this never requests
+// a CRConfig from Traffic Ops; if there is no last CRConfig, it returns the
+// zero time and nil Stats.
+func (s TrafficOpsSessionThreadsafe) LastCRConfigStats(cdn string) (time.Time, *tc.CRConfigStats) {
+	_, crConfigTime, crConfigStats := s.lastCRConfig.Get(cdn)
+	return crConfigTime, crConfigStats
+}
+
 func (s TrafficOpsSessionThreadsafe) fetchTMConfig(cdn string) (*tc.TrafficMonitorConfig, error) {
 	ss := s.get()
 	if ss == nil {
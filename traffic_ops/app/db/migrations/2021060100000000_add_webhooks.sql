@@ -0,0 +1,55 @@
+/*
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+-- +goose Up
+CREATE TABLE IF NOT EXISTS public.webhook (
+    id bigserial NOT NULL,
+    name text NOT NULL,
+    url text NOT NULL,
+    secret text NOT NULL,
+    events text[] NOT NULL DEFAULT '{}',
+    enabled boolean NOT NULL DEFAULT TRUE,
+    last_updated timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT pk_webhook PRIMARY KEY (id),
+    CONSTRAINT webhook_name_unique UNIQUE (name)
+);
+
+DROP TRIGGER IF EXISTS on_update_current_timestamp ON public.webhook;
+CREATE TRIGGER on_update_current_timestamp BEFORE UPDATE ON public.webhook FOR EACH ROW EXECUTE PROCEDURE on_update_current_timestamp_last_updated();
+
+CREATE TABLE IF NOT EXISTS public.webhook_delivery (
+    id bigserial NOT NULL,
+    webhook bigint NOT NULL,
+    event text NOT NULL,
+    payload json NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    attempts integer NOT NULL DEFAULT 0,
+    response_code integer,
+    error text,
+    created timestamp with time zone DEFAULT now() NOT NULL,
+    last_updated timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT pk_webhook_delivery PRIMARY KEY (id),
+    CONSTRAINT fk_webhook_delivery_webhook FOREIGN KEY (webhook) REFERENCES webhook(id) ON DELETE CASCADE,
+    CONSTRAINT webhook_delivery_status_check CHECK (status IN ('pending', 'delivering', 'succeeded', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_status_idx ON public.webhook_delivery (status);
+CREATE INDEX IF NOT EXISTS webhook_delivery_webhook_idx ON public.webhook_delivery (webhook);
+
+DROP TRIGGER IF EXISTS on_update_current_timestamp ON public.webhook_delivery;
+CREATE TRIGGER on_update_current_timestamp BEFORE UPDATE ON public.webhook_delivery FOR EACH ROW EXECUTE PROCEDURE on_update_current_timestamp_last_updated();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS on_update_current_timestamp ON public.webhook_delivery;
+DROP TABLE IF EXISTS public.webhook_delivery;
+DROP TRIGGER IF EXISTS on_update_current_timestamp ON public.webhook;
+DROP TABLE IF EXISTS public.webhook;
@@ -0,0 +1,33 @@
+/*
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+-- +goose Up
+CREATE TABLE IF NOT EXISTS public.snapshot_history (
+    id bigserial NOT NULL,
+    cdn text NOT NULL,
+    crconfig json NOT NULL,
+    monitoring json NOT NULL,
+    username text,
+    comment text,
+    created timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT pk_snapshot_history PRIMARY KEY (id),
+    CONSTRAINT fk_snapshot_history_cdn FOREIGN KEY (cdn) REFERENCES cdn(name) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS snapshot_history_cdn_idx ON public.snapshot_history (cdn, id DESC);
+
+INSERT INTO public.snapshot_history (cdn, crconfig, monitoring, created)
+SELECT cdn, crconfig, monitoring, last_updated
+FROM public.snapshot;
+
+-- +goose Down
+DROP TABLE IF EXISTS public.snapshot_history;
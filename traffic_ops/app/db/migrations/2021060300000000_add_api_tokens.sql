@@ -0,0 +1,34 @@
+/*
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+
+-- +goose Up
+CREATE TABLE IF NOT EXISTS public.api_token (
+    id bigserial NOT NULL,
+    tm_user bigint NOT NULL,
+    name text NOT NULL,
+    token_hash text NOT NULL,
+    routes bigint[] NOT NULL DEFAULT '{}',
+    methods text[] NOT NULL DEFAULT '{}',
+    expires timestamp with time zone NOT NULL,
+    last_used timestamp with time zone,
+    created timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT pk_api_token PRIMARY KEY (id),
+    CONSTRAINT fk_api_token_tm_user FOREIGN KEY (tm_user) REFERENCES tm_user(id) ON DELETE CASCADE,
+    CONSTRAINT api_token_hash_unique UNIQUE (token_hash),
+    CONSTRAINT api_token_user_name_unique UNIQUE (tm_user, name)
+);
+
+CREATE INDEX IF NOT EXISTS api_token_tm_user_idx ON public.api_token (tm_user);
+
+-- +goose Down
+DROP TABLE IF EXISTS public.api_token;
@@ -0,0 +1,49 @@
+/*
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+
+
+-- +goose Up
+CREATE TABLE IF NOT EXISTS public.job_schedule (
+    id bigserial NOT NULL,
+    deliveryservice bigint NOT NULL,
+    regex text NOT NULL,
+    ttl_hours bigint NOT NULL,
+    cron text NOT NULL,
+    enabled boolean NOT NULL DEFAULT TRUE,
+    job_user bigint NOT NULL,
+    last_run timestamp with time zone,
+    next_run timestamp with time zone,
+    last_updated timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT pk_job_schedule PRIMARY KEY (id),
+    CONSTRAINT fk_job_schedule_deliveryservice FOREIGN KEY (deliveryservice) REFERENCES deliveryservice(id) ON DELETE CASCADE,
+    CONSTRAINT fk_job_schedule_job_user FOREIGN KEY (job_user) REFERENCES tm_user(id)
+);
+
+CREATE INDEX IF NOT EXISTS job_schedule_next_run_idx ON public.job_schedule (next_run) WHERE enabled;
+
+DROP TRIGGER IF EXISTS on_update_current_timestamp ON public.job_schedule;
+CREATE TRIGGER on_update_current_timestamp BEFORE UPDATE ON public.job_schedule FOR EACH ROW EXECUTE PROCEDURE on_update_current_timestamp_last_updated();
+
+CREATE TABLE IF NOT EXISTS public.job_acknowledgement (
+    job bigint NOT NULL,
+    server bigint NOT NULL,
+    acknowledged timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT pk_job_acknowledgement PRIMARY KEY (job, server),
+    CONSTRAINT fk_job_acknowledgement_job FOREIGN KEY (job) REFERENCES job(id) ON DELETE CASCADE,
+    CONSTRAINT fk_job_acknowledgement_server FOREIGN KEY (server) REFERENCES server(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS public.job_acknowledgement;
+DROP TRIGGER IF EXISTS on_update_current_timestamp ON public.job_schedule;
+DROP TABLE IF EXISTS public.job_schedule;
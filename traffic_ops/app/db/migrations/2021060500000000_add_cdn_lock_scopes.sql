@@ -0,0 +1,31 @@
+/*
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+-- +goose Up
+ALTER TABLE public.cdn_lock DROP CONSTRAINT IF EXISTS pk_cdn_lock;
+ALTER TABLE public.cdn_lock ADD COLUMN IF NOT EXISTS scope text NOT NULL DEFAULT '';
+ALTER TABLE public.cdn_lock ADD COLUMN IF NOT EXISTS name text NOT NULL DEFAULT '';
+ALTER TABLE public.cdn_lock ADD COLUMN IF NOT EXISTS expires timestamp with time zone;
+ALTER TABLE public.cdn_lock ADD CONSTRAINT pk_cdn_lock PRIMARY KEY ("cdn", "scope", "name");
+ALTER TABLE public.cdn_lock ADD CONSTRAINT cdn_lock_scope_check CHECK (
+    (scope = '' AND name = '') OR
+    (scope IN ('deliveryservice', 'topology', 'cachegroup', 'profile') AND name <> '')
+);
+
+-- +goose Down
+DELETE FROM public.cdn_lock WHERE scope <> '';
+ALTER TABLE public.cdn_lock DROP CONSTRAINT IF EXISTS cdn_lock_scope_check;
+ALTER TABLE public.cdn_lock DROP CONSTRAINT IF EXISTS pk_cdn_lock;
+ALTER TABLE public.cdn_lock DROP COLUMN IF EXISTS expires;
+ALTER TABLE public.cdn_lock DROP COLUMN IF EXISTS name;
+ALTER TABLE public.cdn_lock DROP COLUMN IF EXISTS scope;
+ALTER TABLE public.cdn_lock ADD CONSTRAINT pk_cdn_lock PRIMARY KEY ("cdn");
@@ -0,0 +1,49 @@
+/*
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+-- +goose Up
+ALTER TABLE public.async_status ADD COLUMN IF NOT EXISTS type text NOT NULL DEFAULT '';
+ALTER TABLE public.async_status ADD COLUMN IF NOT EXISTS payload json;
+ALTER TABLE public.async_status ADD COLUMN IF NOT EXISTS username text;
+ALTER TABLE public.async_status ADD COLUMN IF NOT EXISTS progress smallint NOT NULL DEFAULT 0;
+ALTER TABLE public.async_status ADD COLUMN IF NOT EXISTS cancel_requested boolean NOT NULL DEFAULT FALSE;
+ALTER TABLE public.async_status ADD COLUMN IF NOT EXISTS heartbeat timestamp with time zone;
+ALTER TABLE public.async_status ADD COLUMN IF NOT EXISTS result json;
+ALTER TABLE public.async_status ADD COLUMN IF NOT EXISTS artifact text;
+ALTER TABLE public.async_status ADD CONSTRAINT async_status_progress_check CHECK (progress >= 0 AND progress <= 100);
+ALTER TABLE public.async_status ADD CONSTRAINT fk_async_status_username FOREIGN KEY (username) REFERENCES public.tm_user(username) ON DELETE SET NULL ON UPDATE CASCADE;
+CREATE INDEX IF NOT EXISTS async_status_queue_idx ON public.async_status (id) WHERE status = 'PENDING' AND type <> '';
+
+CREATE TABLE IF NOT EXISTS public.async_status_log (
+    id bigserial NOT NULL,
+    async_status bigint NOT NULL,
+    level text NOT NULL,
+    message text NOT NULL,
+    time timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT pk_async_status_log PRIMARY KEY (id),
+    CONSTRAINT fk_async_status_log_async_status FOREIGN KEY (async_status) REFERENCES public.async_status(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS async_status_log_async_status_idx ON public.async_status_log (async_status);
+
+-- +goose Down
+DROP TABLE IF EXISTS public.async_status_log;
+DROP INDEX IF EXISTS public.async_status_queue_idx;
+ALTER TABLE public.async_status DROP CONSTRAINT IF EXISTS fk_async_status_username;
+ALTER TABLE public.async_status DROP CONSTRAINT IF EXISTS async_status_progress_check;
+ALTER TABLE public.async_status DROP COLUMN IF EXISTS artifact;
+ALTER TABLE public.async_status DROP COLUMN IF EXISTS result;
+ALTER TABLE public.async_status DROP COLUMN IF EXISTS heartbeat;
+ALTER TABLE public.async_status DROP COLUMN IF EXISTS cancel_requested;
+ALTER TABLE public.async_status DROP COLUMN IF EXISTS progress;
+ALTER TABLE public.async_status DROP COLUMN IF EXISTS username;
+ALTER TABLE public.async_status DROP COLUMN IF EXISTS payload;
+ALTER TABLE public.async_status DROP COLUMN IF EXISTS type;
@@ -0,0 +1,45 @@
+/*
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+
+
+-- +goose Up
+CREATE TABLE IF NOT EXISTS public.dsr_approval_policy (
+    tenant_id bigint NOT NULL,
+    required_approvals bigint NOT NULL CHECK (required_approvals > 0),
+    approver_roles text[] NOT NULL DEFAULT '{}',
+    allow_self_approval boolean NOT NULL DEFAULT FALSE,
+    notify_by_email boolean NOT NULL DEFAULT FALSE,
+    last_updated timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT pk_dsr_approval_policy PRIMARY KEY (tenant_id),
+    CONSTRAINT fk_dsr_approval_policy_tenant FOREIGN KEY (tenant_id) REFERENCES tenant(id) ON DELETE CASCADE
+);
+
+DROP TRIGGER IF EXISTS on_update_current_timestamp ON public.dsr_approval_policy;
+CREATE TRIGGER on_update_current_timestamp BEFORE UPDATE ON public.dsr_approval_policy FOR EACH ROW EXECUTE PROCEDURE on_update_current_timestamp_last_updated();
+
+CREATE TABLE IF NOT EXISTS public.deliveryservice_request_approval (
+    id bigserial NOT NULL,
+    deliveryservice_request bigint NOT NULL,
+    approver bigint NOT NULL,
+    comment text,
+    approved timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT pk_deliveryservice_request_approval PRIMARY KEY (id),
+    CONSTRAINT deliveryservice_request_approval_unique UNIQUE (deliveryservice_request, approver),
+    CONSTRAINT fk_deliveryservice_request_approval_request FOREIGN KEY (deliveryservice_request) REFERENCES deliveryservice_request(id) ON DELETE CASCADE,
+    CONSTRAINT fk_deliveryservice_request_approval_approver FOREIGN KEY (approver) REFERENCES tm_user(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS public.deliveryservice_request_approval;
+DROP TRIGGER IF EXISTS on_update_current_timestamp ON public.dsr_approval_policy;
+DROP TABLE IF EXISTS public.dsr_approval_policy;
@@ -0,0 +1,23 @@
+/*
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+
+
+-- +goose Up
+CREATE TABLE IF NOT EXISTS public.sslkey_expiration_alert (
+    id boolean NOT NULL DEFAULT TRUE CHECK (id),
+    last_run timestamp with time zone NOT NULL,
+    CONSTRAINT pk_sslkey_expiration_alert PRIMARY KEY (id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS public.sslkey_expiration_alert;
@@ -0,0 +1,52 @@
+/*
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+
+-- +goose Up
+CREATE TABLE IF NOT EXISTS public.server_status_transition (
+    id bigserial NOT NULL,
+    from_status bigint NOT NULL,
+    to_status bigint NOT NULL,
+    last_updated timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT pk_server_status_transition PRIMARY KEY (id),
+    CONSTRAINT server_status_transition_unique UNIQUE (from_status, to_status),
+    CONSTRAINT server_status_transition_differs CHECK (from_status <> to_status),
+    CONSTRAINT fk_server_status_transition_from_status FOREIGN KEY (from_status) REFERENCES status(id) ON DELETE CASCADE,
+    CONSTRAINT fk_server_status_transition_to_status FOREIGN KEY (to_status) REFERENCES status(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS public.server_maintenance_window (
+    id bigserial NOT NULL,
+    server bigint NOT NULL,
+    start_time timestamp with time zone NOT NULL,
+    end_time timestamp with time zone NOT NULL,
+    reason text NOT NULL,
+    state text NOT NULL DEFAULT 'scheduled' CHECK (state IN ('scheduled', 'active', 'complete')),
+    previous_status bigint,
+    window_user bigint NOT NULL,
+    last_updated timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT pk_server_maintenance_window PRIMARY KEY (id),
+    CONSTRAINT server_maintenance_window_end_after_start CHECK (end_time > start_time),
+    CONSTRAINT fk_server_maintenance_window_server FOREIGN KEY (server) REFERENCES server(id) ON DELETE CASCADE,
+    CONSTRAINT fk_server_maintenance_window_previous_status FOREIGN KEY (previous_status) REFERENCES status(id),
+    CONSTRAINT fk_server_maintenance_window_window_user FOREIGN KEY (window_user) REFERENCES tm_user(id)
+);
+
+CREATE INDEX IF NOT EXISTS server_maintenance_window_due_idx ON public.server_maintenance_window (state, start_time, end_time) WHERE state <> 'complete';
+
+DROP TRIGGER IF EXISTS on_update_current_timestamp ON public.server_maintenance_window;
+CREATE TRIGGER on_update_current_timestamp BEFORE UPDATE ON public.server_maintenance_window FOR EACH ROW EXECUTE PROCEDURE on_update_current_timestamp_last_updated();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS on_update_current_timestamp ON public.server_maintenance_window;
+DROP TABLE IF EXISTS public.server_maintenance_window;
+DROP TABLE IF EXISTS public.server_status_transition;
@@ -0,0 +1,24 @@
+/*
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+
+-- +goose Up
+ALTER TABLE public.tm_user ADD COLUMN IF NOT EXISTS oidc_issuer text;
+ALTER TABLE public.tm_user ADD COLUMN IF NOT EXISTS oidc_subject text;
+ALTER TABLE public.tm_user ADD CONSTRAINT tm_user_oidc_identity_complete CHECK ((oidc_issuer IS NULL) = (oidc_subject IS NULL));
+CREATE UNIQUE INDEX IF NOT EXISTS tm_user_oidc_identity_idx ON public.tm_user (oidc_issuer, oidc_subject) WHERE oidc_subject IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS public.tm_user_oidc_identity_idx;
+ALTER TABLE public.tm_user DROP CONSTRAINT IF EXISTS tm_user_oidc_identity_complete;
+ALTER TABLE public.tm_user DROP COLUMN IF EXISTS oidc_subject;
+ALTER TABLE public.tm_user DROP COLUMN IF EXISTS oidc_issuer;
@@ -65,6 +65,7 @@ const PROPERTIES_FILE = "./reencrypt.conf"
 func main() {
 	previousKeyLocation := flag.String("previous-key", "/opt/traffic_ops/app/conf/aes.key", "(Optional) The file path for the previous base64 encoded AES key. Default is /opt/traffic_ops/app/conf/aes.key.")
 	newKeyLocation := flag.String("new-key", "/opt/traffic_ops/app/conf/new.key", "(Optional) The file path for the new base64 encoded AES key. Default is /opt/traffic_ops/app/conf/new.key.")
+	newKeyID := flag.String("new-key-id", "", "(Optional) The ID of the new AES key, i.e. the aes_key_id it will have in the Traffic Vault configuration. If not given, re-encrypted keys are stored without a key ID.")
 	cfg := flag.String("cfg", PROPERTIES_FILE, "(Optional) The path for the configuration file. Default is "+PROPERTIES_FILE+".")
 	help := flag.Bool("help", false, "(Optional) Print usage information and exit.")
 	flag.Parse()
@@ -104,25 +105,27 @@ func main() {
 		die("opening database: " + err.Error())
 	}
 
+	keyID := sql.NullString{String: *newKeyID, Valid: *newKeyID != ""}
+
 	tx, err := db.Begin()
 	if err != nil {
 		die(fmt.Sprintf("transaction begin failed %v %v ", err, tx))
 	}
 	defer tx.Commit()
 
-	if err = reEncryptSslKeys(tx, previousKey, newKey); err != nil {
+	if err = reEncryptSslKeys(tx, previousKey, newKey, keyID); err != nil {
 		tx.Rollback()
 		die("re-encrypting SSL Keys: " + err.Error())
 	}
-	if err = reEncryptUrlSigKeys(tx, previousKey, newKey); err != nil {
+	if err = reEncryptUrlSigKeys(tx, previousKey, newKey, keyID); err != nil {
 		tx.Rollback()
 		die("re-encrypting URL Sig Keys: " + err.Error())
 	}
-	if err = reEncryptUriSigningKeys(tx, previousKey, newKey); err != nil {
+	if err = reEncryptUriSigningKeys(tx, previousKey, newKey, keyID); err != nil {
 		tx.Rollback()
 		die("re-encrypting URI Signing Keys: " + err.Error())
 	}
-	if err = reEncryptDNSSECKeys(tx, previousKey, newKey); err != nil {
+	if err = reEncryptDNSSECKeys(tx, previousKey, newKey, keyID); err != nil {
 		tx.Rollback()
 		die("re-encrypting DNSSEC Keys: " + err.Error())
 	}
@@ -160,7 +163,7 @@ func readKey(keyLocation string) ([]byte, error) {
 	return key, nil
 }
 
-func reEncryptSslKeys(tx *sql.Tx, previousKey []byte, newKey []byte) error {
+func reEncryptSslKeys(tx *sql.Tx, previousKey []byte, newKey []byte, newKeyID sql.NullString) error {
 	rows, err := tx.Query("SELECT id, data FROM sslkey")
 	if err != nil {
 		return fmt.Errorf("querying: %w", err)
@@ -193,7 +196,7 @@ func reEncryptSslKeys(tx *sql.Tx, previousKey []byte, newKey []byte) error {
 	}
 
 	for id, reencryptedKeys := range sslKeyMap {
-		res, err := tx.Exec(`UPDATE sslkey SET data = $1 WHERE id = $2`, reencryptedKeys, id)
+		res, err := tx.Exec(`UPDATE sslkey SET data = $1, key_id = $3 WHERE id = $2`, reencryptedKeys, id, newKeyID)
 		if err != nil {
 			return fmt.Errorf("updating SSL Keys for id %d: %w", id, err)
 		}
@@ -209,7 +212,7 @@ func reEncryptSslKeys(tx *sql.Tx, previousKey []byte, newKey []byte) error {
 	return nil
 }
 
-func reEncryptUrlSigKeys(tx *sql.Tx, previousKey []byte, newKey []byte) error {
+func reEncryptUrlSigKeys(tx *sql.Tx, previousKey []byte, newKey []byte, newKeyID sql.NullString) error {
 	rows, err := tx.Query("SELECT deliveryservice, data FROM url_sig_key")
 	if err != nil {
 		return fmt.Errorf("querying: %w", err)
@@ -242,7 +245,7 @@ func reEncryptUrlSigKeys(tx *sql.Tx, previousKey []byte, newKey []byte) error {
 	}
 
 	for ds, reencryptedKeys := range urlSigKeysMap {
-		res, err := tx.Exec(`UPDATE url_sig_key SET data = $1 WHERE deliveryservice = $2`, reencryptedKeys, ds)
+		res, err := tx.Exec(`UPDATE url_sig_key SET data = $1, key_id = $3 WHERE deliveryservice = $2`, reencryptedKeys, ds, newKeyID)
 		if err != nil {
 			return fmt.Errorf("updating URL Sig Keys for deliveryservice %s: %w", ds, err)
 		}
@@ -258,7 +261,7 @@ func reEncryptUrlSigKeys(tx *sql.Tx, previousKey []byte, newKey []byte) error {
 	return nil
 }
 
-func reEncryptUriSigningKeys(tx *sql.Tx, previousKey []byte, newKey []byte) error {
+func reEncryptUriSigningKeys(tx *sql.Tx, previousKey []byte, newKey []byte, newKeyID sql.NullString) error {
 	rows, err := tx.Query("SELECT deliveryservice, data FROM uri_signing_key")
 	if err != nil {
 		return fmt.Errorf("querying: %w", err)
@@ -291,7 +294,7 @@ func reEncryptUriSigningKeys(tx *sql.Tx, previousKey []byte, newKey []byte) erro
 	}
 
 	for ds, reencryptedKeys := range uriSigningKeyMap {
-		res, err := tx.Exec(`UPDATE uri_signing_key SET data = $1 WHERE deliveryservice = $2`, reencryptedKeys, ds)
+		res, err := tx.Exec(`UPDATE uri_signing_key SET data = $1, key_id = $3 WHERE deliveryservice = $2`, reencryptedKeys, ds, newKeyID)
 		if err != nil {
 			return fmt.Errorf("updating URI Signing Keys for deliveryservice %s: %w", ds, err)
 		}
@@ -307,7 +310,7 @@ func reEncryptUriSigningKeys(tx *sql.Tx, previousKey []byte, newKey []byte) erro
 	return nil
 }
 
-func reEncryptDNSSECKeys(tx *sql.Tx, previousKey []byte, newKey []byte) error {
+func reEncryptDNSSECKeys(tx *sql.Tx, previousKey []byte, newKey []byte, newKeyID sql.NullString) error {
 	rows, err := tx.Query("SELECT cdn, data FROM dnssec")
 	if err != nil {
 		return fmt.Errorf("querying: %w", err)
@@ -340,7 +343,7 @@ func reEncryptDNSSECKeys(tx *sql.Tx, previousKey []byte, newKey []byte) error {
 	}
 
 	for cdn, reencryptedKeys := range dnssecKeyMap {
-		res, err := tx.Exec(`UPDATE dnssec SET data = $1 WHERE cdn = $2`, reencryptedKeys, cdn)
+		res, err := tx.Exec(`UPDATE dnssec SET data = $1, key_id = $3 WHERE cdn = $2`, reencryptedKeys, cdn, newKeyID)
 		if err != nil {
 			return fmt.Errorf("updating DNSSEC Keys for cdn %s: %w", cdn, err)
 		}
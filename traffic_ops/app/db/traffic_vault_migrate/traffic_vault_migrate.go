@@ -45,6 +45,7 @@ var (
 	keyFile     string
 	dry         bool
 	compare     bool
+	verify      bool
 	noConfirm   bool
 	dump        bool
 	logLevel    string
@@ -56,8 +57,9 @@ var (
 		LogLocationDebug:   log.LogLocationNull,
 		LogLocationEvent:   log.LogLocationNull,
 	}
-	riakBE RiakBackend = RiakBackend{}
-	pgBE   PGBackend   = PGBackend{}
+	riakBE RiakBackend      = RiakBackend{}
+	pgBE   PGBackend        = PGBackend{}
+	tvBE   TVBackendAdapter = TVBackendAdapter{}
 )
 
 func init() {
@@ -95,6 +97,9 @@ func init() {
 		SetFlag().
 		SetGroup("no_insert")
 
+	getopt.FlagLong(&verify, "verify", 'v', "Read the inserted keys back from the `to` server and verify they match").
+		SetFlag()
+
 	getopt.FlagLong(&noConfirm, "noConfirm", 'm', "Requires confirmation before inserting records").
 		SetFlag()
 
@@ -119,7 +124,7 @@ func init() {
 // supportBackends returns the backends available in this tool.
 func supportedBackends() []TVBackend {
 	return []TVBackend{
-		&riakBE, &pgBE,
+		&riakBE, &pgBE, &tvBE,
 	}
 }
 
@@ -308,6 +313,80 @@ func main() {
 		log.Errorln(err)
 		os.Exit(1)
 	}
+
+	if verify {
+		log.Infof("Verifying data in %s...\n", toSrv.Name())
+		if err := toSrv.Fetch(); err != nil {
+			log.Errorf("Unable to fetch toSrv data: %v\n", err)
+			os.Exit(1)
+		}
+		toSecret, err := GetKeys(toSrv)
+		if err != nil {
+			log.Errorln(err)
+			os.Exit(1)
+		}
+		if errs := VerifyKeys(fromSecret, toSecret); len(errs) > 0 {
+			log.Errorf("Verification of %s failed:\n%s\n", toSrv.Name(), strings.Join(errs, "\n"))
+			os.Exit(1)
+		}
+		log.Infof("All keys were verified in %s\n", toSrv.Name())
+	}
+}
+
+// VerifyKeys checks that every key in from is present and identical in to,
+// returning a description of each key that is not.
+func VerifyKeys(from Secrets, to Secrets) []string {
+	var errs []string
+
+	sslKeys := make(map[string]SSLKey, len(to.sslkeys))
+	for _, key := range to.sslkeys {
+		sslKeys[key.DeliveryService+"/"+key.Version] = key
+	}
+	for _, key := range from.sslkeys {
+		if toKey, ok := sslKeys[key.DeliveryService+"/"+key.Version]; !ok {
+			errs = append(errs, fmt.Sprintf("SSL Key DS '%s' version '%s': missing", key.DeliveryService, key.Version))
+		} else if !reflect.DeepEqual(key.DeliveryServiceSSLKeys, toKey.DeliveryServiceSSLKeys) {
+			errs = append(errs, fmt.Sprintf("SSL Key DS '%s' version '%s': does not match", key.DeliveryService, key.Version))
+		}
+	}
+
+	dnssecKeys := make(map[string]DNSSecKey, len(to.dnssecKeys))
+	for _, key := range to.dnssecKeys {
+		dnssecKeys[key.CDN] = key
+	}
+	for _, key := range from.dnssecKeys {
+		if toKey, ok := dnssecKeys[key.CDN]; !ok {
+			errs = append(errs, fmt.Sprintf("DNSSec Key CDN '%s': missing", key.CDN))
+		} else if !reflect.DeepEqual(key.DNSSECKeysTrafficVault, toKey.DNSSECKeysTrafficVault) {
+			errs = append(errs, fmt.Sprintf("DNSSec Key CDN '%s': does not match", key.CDN))
+		}
+	}
+
+	uriKeys := make(map[string]URISignKey, len(to.uriKeys))
+	for _, key := range to.uriKeys {
+		uriKeys[key.DeliveryService] = key
+	}
+	for _, key := range from.uriKeys {
+		if toKey, ok := uriKeys[key.DeliveryService]; !ok {
+			errs = append(errs, fmt.Sprintf("URI Signing Key DS '%s': missing", key.DeliveryService))
+		} else if !reflect.DeepEqual(key.Keys, toKey.Keys) {
+			errs = append(errs, fmt.Sprintf("URI Signing Key DS '%s': does not match", key.DeliveryService))
+		}
+	}
+
+	urlKeys := make(map[string]URLSigKey, len(to.urlKeys))
+	for _, key := range to.urlKeys {
+		urlKeys[urlSigKeyXMLID(key.DeliveryService)] = key
+	}
+	for _, key := range from.urlKeys {
+		if toKey, ok := urlKeys[urlSigKeyXMLID(key.DeliveryService)]; !ok {
+			errs = append(errs, fmt.Sprintf("URL Sig Key DS '%s': missing", key.DeliveryService))
+		} else if !reflect.DeepEqual(key.URLSigKeys, toKey.URLSigKeys) {
+			errs = append(errs, fmt.Sprintf("URL Sig Key DS '%s': does not match", key.DeliveryService))
+		}
+	}
+
+	return errs
 }
 
 // Validate runs the ValidateKey method on the backend.
@@ -557,10 +636,12 @@ func validateType(typ string) bool {
 	}
 	return false
 }
+// getBackendFromType returns a new backend of the given type, so that the
+// 'from' and 'to' servers may be of the same type.
 func getBackendFromType(typ string) TVBackend {
 	for _, be := range supportedBackends() {
 		if be.Name() == typ {
-			return be
+			return reflect.New(reflect.TypeOf(be).Elem()).Interface().(TVBackend)
 		}
 	}
 	return nil
@@ -0,0 +1,409 @@
+package main
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/trafficvault"
+	_ "github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/trafficvault/backends" // init traffic vault backends
+	_ "github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/trafficvault/backends/riaksvc"
+
+	_ "github.com/lib/pq"
+)
+
+const tvLatestVersion = "latest"
+
+// TVDBConfig represents the connection options for the Traffic Ops database,
+// which is used to enumerate the CDNs and Delivery Services whose keys are
+// migrated.
+type TVDBConfig struct {
+	Host     string `json:"host"`
+	Port     string `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	SSLMode  string `json:"sslmode"`
+	Database string `json:"database"`
+}
+
+// TVConfig represents the configuration options available to the TV backend.
+// TrafficVaultBackend and TrafficVaultConfig have the same meaning as the
+// traffic_vault_backend and traffic_vault_config options in cdn.conf.
+type TVConfig struct {
+	TrafficVaultBackend string          `json:"traffic_vault_backend"`
+	TrafficVaultConfig  json.RawMessage `json:"traffic_vault_config"`
+	TrafficOpsDB        TVDBConfig      `json:"traffic_ops_db"`
+}
+
+// TVBackendAdapter is an implementation of TVBackend which reads and writes
+// keys through the trafficvault.TrafficVault interface, so any Traffic Vault
+// backend registered with Traffic Ops may be migrated to or from.
+type TVBackendAdapter struct {
+	sslKeys    []SSLKey
+	dnssecKeys []DNSSecKey
+	uriKeys    []URISignKey
+	urlKeys    []URLSigKey
+	cfg        TVConfig
+	db         *sql.DB
+	tv         trafficvault.TrafficVault
+}
+
+// tvDeliveryService is a Delivery Service whose keys may be stored in Traffic Vault.
+type tvDeliveryService struct {
+	XMLID         string
+	CDN           string
+	SSLKeyVersion int64
+}
+
+// String returns a high level overview of the backend and its keys.
+func (tv *TVBackendAdapter) String() string {
+	data := fmt.Sprintf("Traffic Vault '%s' backend\n", tv.cfg.TrafficVaultBackend)
+	data += fmt.Sprintf("\tSSL Keys: %d\n", len(tv.sslKeys))
+	data += fmt.Sprintf("\tDNSSec Keys: %d\n", len(tv.dnssecKeys))
+	data += fmt.Sprintf("\tURI Signing Keys: %d\n", len(tv.uriKeys))
+	data += fmt.Sprintf("\tURL Sig Keys: %d\n", len(tv.urlKeys))
+	return data
+}
+
+// Name returns the name for this backend.
+func (tv *TVBackendAdapter) Name() string {
+	return "TV"
+}
+
+// ReadConfigFile takes in a filename and will read it into the backends config.
+func (tv *TVBackendAdapter) ReadConfigFile(configFile string) error {
+	if err := UnmarshalConfig(configFile, &tv.cfg); err != nil {
+		return err
+	}
+	if tv.cfg.TrafficVaultBackend == "" {
+		return errors.New("traffic_vault_backend is required")
+	}
+	return nil
+}
+
+// Start loads the configured Traffic Vault backend and initiates the connection to the Traffic Ops DB.
+func (tv *TVBackendAdapter) Start() error {
+	var err error
+	if tv.tv, err = trafficvault.GetBackend(tv.cfg.TrafficVaultBackend, tv.cfg.TrafficVaultConfig); err != nil {
+		return err
+	}
+
+	dbCfg := tv.cfg.TrafficOpsDB
+	sqlStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s", dbCfg.User, dbCfg.Password, dbCfg.Host, dbCfg.Port, dbCfg.Database, dbCfg.SSLMode)
+	db, err := sql.Open("postgres", sqlStr)
+	if err != nil {
+		sqlStr = strings.Replace(sqlStr, dbCfg.Password, "*", 1)
+		return fmt.Errorf("unable to start Traffic Ops DB client with connection string '%s': %w", sqlStr, err)
+	}
+	tv.db = db
+	return nil
+}
+
+// Close terminates the connection to the Traffic Ops DB.
+func (tv *TVBackendAdapter) Close() error {
+	return tv.db.Close()
+}
+
+// Ping checks the connection to both the Traffic Ops DB and the Traffic Vault backend.
+func (tv *TVBackendAdapter) Ping() error {
+	if err := tv.db.Ping(); err != nil {
+		return fmt.Errorf("pinging Traffic Ops DB: %w", err)
+	}
+	return tv.withTx(func(tx *sql.Tx) error {
+		_, err := tv.tv.Ping(tx, context.Background())
+		return err
+	})
+}
+
+// withTx runs f with a transaction on the Traffic Ops DB, which some Traffic
+// Vault backends use to look up their servers.
+func (tv *TVBackendAdapter) withTx(f func(*sql.Tx) error) error {
+	tx, err := tv.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning Traffic Ops DB transaction: %w", err)
+	}
+	if err := f(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Errorf("rolling back Traffic Ops DB transaction: %v", rbErr)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing Traffic Ops DB transaction: %w", err)
+	}
+	return nil
+}
+
+func getTVCDNs(tx *sql.Tx) ([]string, error) {
+	rows, err := tx.Query("SELECT name FROM cdn ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("querying CDNs: %w", err)
+	}
+	defer log.Close(rows, "closing cdn query")
+	cdns := []string{}
+	for rows.Next() {
+		name := ""
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scanning CDN: %w", err)
+		}
+		cdns = append(cdns, name)
+	}
+	return cdns, rows.Err()
+}
+
+func getTVDeliveryServices(tx *sql.Tx) ([]tvDeliveryService, error) {
+	qry := `
+SELECT ds.xml_id, c.name, COALESCE(ds.ssl_key_version, 0)
+FROM deliveryservice ds
+JOIN cdn c ON c.id = ds.cdn_id
+ORDER BY ds.xml_id
+`
+	rows, err := tx.Query(qry)
+	if err != nil {
+		return nil, fmt.Errorf("querying delivery services: %w", err)
+	}
+	defer log.Close(rows, "closing deliveryservice query")
+	dses := []tvDeliveryService{}
+	for rows.Next() {
+		ds := tvDeliveryService{}
+		if err := rows.Scan(&ds.XMLID, &ds.CDN, &ds.SSLKeyVersion); err != nil {
+			return nil, fmt.Errorf("scanning delivery service: %w", err)
+		}
+		dses = append(dses, ds)
+	}
+	return dses, rows.Err()
+}
+
+// Fetch gets all of the keys for every CDN and Delivery Service in the Traffic Ops DB
+// from the Traffic Vault backend.
+func (tv *TVBackendAdapter) Fetch() error {
+	tv.sslKeys = []SSLKey{}
+	tv.dnssecKeys = []DNSSecKey{}
+	tv.uriKeys = []URISignKey{}
+	tv.urlKeys = []URLSigKey{}
+	ctx := context.Background()
+
+	return tv.withTx(func(tx *sql.Tx) error {
+		cdns, err := getTVCDNs(tx)
+		if err != nil {
+			return err
+		}
+		for _, cdn := range cdns {
+			keys, ok, err := tv.tv.GetDNSSECKeys(cdn, tx, ctx)
+			if err != nil {
+				return fmt.Errorf("getting DNSSEC keys for CDN '%s': %w", cdn, err)
+			}
+			if ok {
+				tv.dnssecKeys = append(tv.dnssecKeys, DNSSecKey{CDN: cdn, DNSSECKeysTrafficVault: keys})
+			}
+		}
+
+		dses, err := getTVDeliveryServices(tx)
+		if err != nil {
+			return err
+		}
+		for _, ds := range dses {
+			versions := []string{tvLatestVersion}
+			for v := int64(0); v <= ds.SSLKeyVersion; v++ {
+				versions = append(versions, strconv.FormatInt(v, 10))
+			}
+			for _, version := range versions {
+				key, ok, err := tv.tv.GetDeliveryServiceSSLKeys(ds.XMLID, version, tx, ctx)
+				if err != nil {
+					return fmt.Errorf("getting SSL keys version '%s' for delivery service '%s': %w", version, ds.XMLID, err)
+				}
+				if ok {
+					tv.sslKeys = append(tv.sslKeys, SSLKey{DeliveryServiceSSLKeys: key.DeliveryServiceSSLKeys, Version: version})
+				}
+			}
+
+			urlKeys, ok, err := tv.tv.GetURLSigKeys(ds.XMLID, tx, ctx)
+			if err != nil {
+				return fmt.Errorf("getting URL sig keys for delivery service '%s': %w", ds.XMLID, err)
+			}
+			if ok {
+				tv.urlKeys = append(tv.urlKeys, URLSigKey{DeliveryService: ds.XMLID, URLSigKeys: urlKeys})
+			}
+
+			uriKeysJSON, ok, err := tv.tv.GetURISigningKeys(ds.XMLID, tx, ctx)
+			if err != nil {
+				return fmt.Errorf("getting URI signing keys for delivery service '%s': %w", ds.XMLID, err)
+			}
+			if ok {
+				uriKeys := map[string]tc.URISignerKeyset{}
+				if err := json.Unmarshal(uriKeysJSON, &uriKeys); err != nil {
+					return fmt.Errorf("unmarshalling URI signing keys for delivery service '%s': %w", ds.XMLID, err)
+				}
+				tv.uriKeys = append(tv.uriKeys, URISignKey{DeliveryService: ds.XMLID, Keys: uriKeys})
+			}
+		}
+		return nil
+	})
+}
+
+// sslKeyVersionNum returns the numeric version of the given SSL key, used to
+// order writes so that the highest version is written last.
+func sslKeyVersionNum(key SSLKey) int64 {
+	if v, err := strconv.ParseInt(key.Version, 10, 64); err == nil {
+		return v
+	}
+	return int64(key.DeliveryServiceSSLKeys.Version)
+}
+
+// Insert takes the current keys and writes them through the Traffic Vault backend.
+func (tv *TVBackendAdapter) Insert() error {
+	// Every Put of an SSL key also replaces the 'latest' version, so write
+	// each Delivery Service's numbered versions in ascending order and its
+	// 'latest' version last.
+	sslKeys := make([]SSLKey, len(tv.sslKeys))
+	copy(sslKeys, tv.sslKeys)
+	sort.SliceStable(sslKeys, func(a, b int) bool {
+		if sslKeys[a].DeliveryService != sslKeys[b].DeliveryService {
+			return sslKeys[a].DeliveryService < sslKeys[b].DeliveryService
+		}
+		aLatest := sslKeys[a].Version == tvLatestVersion
+		bLatest := sslKeys[b].Version == tvLatestVersion
+		if aLatest != bLatest {
+			return bLatest
+		}
+		return sslKeyVersionNum(sslKeys[a]) < sslKeyVersionNum(sslKeys[b])
+	})
+	ctx := context.Background()
+
+	return tv.withTx(func(tx *sql.Tx) error {
+		for _, key := range sslKeys {
+			if err := tv.tv.PutDeliveryServiceSSLKeys(key.DeliveryServiceSSLKeys, tx, ctx); err != nil {
+				return fmt.Errorf("putting SSL keys version '%s' for delivery service '%s': %w", key.Version, key.DeliveryService, err)
+			}
+		}
+		for _, key := range tv.dnssecKeys {
+			if err := tv.tv.PutDNSSECKeys(key.CDN, key.DNSSECKeysTrafficVault, tx, ctx); err != nil {
+				return fmt.Errorf("putting DNSSEC keys for CDN '%s': %w", key.CDN, err)
+			}
+		}
+		for _, key := range tv.urlKeys {
+			xmlID := urlSigKeyXMLID(key.DeliveryService)
+			if err := tv.tv.PutURLSigKeys(xmlID, key.URLSigKeys, tx, ctx); err != nil {
+				return fmt.Errorf("putting URL sig keys for delivery service '%s': %w", xmlID, err)
+			}
+		}
+		for _, key := range tv.uriKeys {
+			keysJSON, err := json.Marshal(key.Keys)
+			if err != nil {
+				return fmt.Errorf("marshalling URI signing keys for delivery service '%s': %w", key.DeliveryService, err)
+			}
+			if err := tv.tv.PutURISigningKeys(key.DeliveryService, keysJSON, tx, ctx); err != nil {
+				return fmt.Errorf("putting URI signing keys for delivery service '%s': %w", key.DeliveryService, err)
+			}
+		}
+		return nil
+	})
+}
+
+// urlSigKeyXMLID returns the Delivery Service XMLID of a URL Sig key. Some
+// backends (i.e. Riak) name these keys "url_sig_<xmlID>.config".
+func urlSigKeyXMLID(name string) string {
+	if strings.HasPrefix(name, "url_sig_") && strings.HasSuffix(name, ".config") {
+		return strings.TrimSuffix(strings.TrimPrefix(name, "url_sig_"), ".config")
+	}
+	return name
+}
+
+// ValidateKey validates that the keys are valid (in most cases, certain fields are not null).
+func (tv *TVBackendAdapter) ValidateKey() []string {
+	var errs []string
+	for _, key := range tv.sslKeys {
+		if key.DeliveryService == "" {
+			errs = append(errs, fmt.Sprintf("SSL Key '%s': DS is blank!", key.Key))
+		}
+		if key.CDN == "" {
+			errs = append(errs, fmt.Sprintf("SSL Key '%s': CDN is blank!", key.Key))
+		}
+	}
+	for i, key := range tv.dnssecKeys {
+		if key.CDN == "" {
+			errs = append(errs, fmt.Sprintf("DNSSEC Key #%d: CDN is blank!", i))
+		}
+	}
+	for i, key := range tv.uriKeys {
+		if key.DeliveryService == "" {
+			errs = append(errs, fmt.Sprintf("URI Signing Key #%d: DS is blank!", i))
+		}
+	}
+	for i, key := range tv.urlKeys {
+		if key.DeliveryService == "" {
+			errs = append(errs, fmt.Sprintf("URL Sig Key #%d: DS is blank!", i))
+		}
+	}
+	return errs
+}
+
+// GetSSLKeys converts the backends internal key representation into the common representation (SSLKey).
+func (tv *TVBackendAdapter) GetSSLKeys() ([]SSLKey, error) {
+	return tv.sslKeys, nil
+}
+
+// SetSSLKeys takes in keys and converts & encrypts the data into the backends internal format.
+func (tv *TVBackendAdapter) SetSSLKeys(keys []SSLKey) error {
+	tv.sslKeys = keys
+	return nil
+}
+
+// GetDNSSecKeys converts the backends internal key representation into the common representation (DNSSecKey).
+func (tv *TVBackendAdapter) GetDNSSecKeys() ([]DNSSecKey, error) {
+	return tv.dnssecKeys, nil
+}
+
+// SetDNSSecKeys takes in keys and converts & encrypts the data into the backends internal format.
+func (tv *TVBackendAdapter) SetDNSSecKeys(keys []DNSSecKey) error {
+	tv.dnssecKeys = keys
+	return nil
+}
+
+// GetURISignKeys converts the backends internal key representation into the common representation (URISignKey).
+func (tv *TVBackendAdapter) GetURISignKeys() ([]URISignKey, error) {
+	return tv.uriKeys, nil
+}
+
+// SetURISignKeys takes in keys and converts & encrypts the data into the backends internal format.
+func (tv *TVBackendAdapter) SetURISignKeys(keys []URISignKey) error {
+	tv.uriKeys = keys
+	return nil
+}
+
+// GetURLSigKeys converts the backends internal key representation into the common representation (URLSigKey).
+func (tv *TVBackendAdapter) GetURLSigKeys() ([]URLSigKey, error) {
+	return tv.urlKeys, nil
+}
+
+// SetURLSigKeys takes in keys and converts & encrypts the data into the backends internal format.
+func (tv *TVBackendAdapter) SetURLSigKeys(keys []URLSigKey) error {
+	tv.urlKeys = keys
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/trafficvault"
+
+	"github.com/lestrrat/go-jwx/jwk"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func newFilesystemTrafficVault(t *testing.T, dir string) trafficvault.TrafficVault {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("generating AES key: %v", err)
+	}
+	keyFile := filepath.Join(dir, "aes.key")
+	if err := ioutil.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)), 0600); err != nil {
+		t.Fatalf("writing AES key: %v", err)
+	}
+	cfg, err := json.Marshal(map[string]string{"directory": filepath.Join(dir, "vault"), "aes_key_location": keyFile})
+	if err != nil {
+		t.Fatalf("marshalling config: %v", err)
+	}
+	tv, err := trafficvault.GetBackend("filesystem", cfg)
+	if err != nil {
+		t.Fatalf("loading filesystem Traffic Vault backend: %v", err)
+	}
+	return tv
+}
+
+func TestTVBackendAdapter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "traffic_vault_migrate")
+	if err != nil {
+		t.Fatalf("creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	tv := TVBackendAdapter{db: db, tv: newFilesystemTrafficVault(t, dir), cfg: TVConfig{TrafficVaultBackend: "filesystem"}}
+
+	sslKey := tc.DeliveryServiceSSLKeys{CDN: "cdn1", DeliveryService: "ds1", Hostname: "*.ds1.example.test", Key: "ds1", Version: 1, Certificate: tc.DeliveryServiceSSLKeysCertificate{Crt: "crt1", Key: "key1"}}
+	sslKey2 := sslKey
+	sslKey2.Version = 2
+	sslKey2.Certificate.Crt = "crt2"
+	from := Secrets{
+		sslkeys: []SSLKey{
+			{DeliveryServiceSSLKeys: sslKey2, Version: "latest"},
+			{DeliveryServiceSSLKeys: sslKey2, Version: "2"},
+			{DeliveryServiceSSLKeys: sslKey, Version: "1"},
+		},
+		dnssecKeys: []DNSSecKey{{
+			CDN: "cdn1",
+			DNSSECKeysTrafficVault: tc.DNSSECKeysTrafficVault{
+				"cdn1": tc.DNSSECKeySetV11{ZSK: []tc.DNSSECKeyV11{{Name: "cdn1.", TTLSeconds: 60, Status: "new", Public: "pub", Private: "priv"}}},
+			},
+		}},
+		uriKeys: []URISignKey{{
+			DeliveryService: "ds1",
+			Keys: map[string]tc.URISignerKeyset{
+				"issuer": {RenewalKid: util.StrPtr("k"), Keys: []jwk.EssentialHeader{{Algorithm: "HS256", KeyID: "k"}}},
+			},
+		}},
+		urlKeys: []URLSigKey{{DeliveryService: "url_sig_ds1.config", URLSigKeys: tc.URLSigKeys{"key0": "abc"}}},
+	}
+
+	if err := SetKeys(&tv, from); err != nil {
+		t.Fatalf("setting keys: %v", err)
+	}
+	if err := Validate(&tv); err != nil {
+		t.Fatalf("validating keys: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	if err := tv.Insert(); err != nil {
+		t.Fatalf("inserting keys: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT name FROM cdn").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("cdn1").AddRow("cdn2"))
+	mock.ExpectQuery("SELECT ds.xml_id").WillReturnRows(sqlmock.NewRows([]string{"xml_id", "name", "ssl_key_version"}).AddRow("ds1", "cdn1", 2).AddRow("ds2", "cdn2", 0))
+	mock.ExpectCommit()
+	fetched := TVBackendAdapter{db: db, tv: tv.tv}
+	if err := fetched.Fetch(); err != nil {
+		t.Fatalf("fetching keys: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expected all queries to be executed: %v", err)
+	}
+
+	to, err := GetKeys(&fetched)
+	if err != nil {
+		t.Fatalf("getting keys: %v", err)
+	}
+	if len(to.sslkeys) != 3 || len(to.dnssecKeys) != 1 || len(to.uriKeys) != 1 || len(to.urlKeys) != 1 {
+		t.Errorf("expected 3 SSL keys and 1 of each other key, got %d %d %d %d", len(to.sslkeys), len(to.dnssecKeys), len(to.uriKeys), len(to.urlKeys))
+	}
+	if errs := VerifyKeys(from, to); len(errs) > 0 {
+		t.Errorf("expected fetched keys to match inserted keys, got: %v", errs)
+	}
+
+	to.sslkeys = to.sslkeys[1:]
+	to.urlKeys[0].URLSigKeys = tc.URLSigKeys{"key0": "changed"}
+	if errs := VerifyKeys(from, to); len(errs) != 2 {
+		t.Errorf("expected 2 verification errors, got: %v", errs)
+	}
+}
@@ -0,0 +1,48 @@
+/*
+
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- key_id is the ID of the AES key each secret is encrypted with; NULL for
+-- secrets that were encrypted before key IDs were recorded.
+ALTER TABLE dnssec ADD COLUMN IF NOT EXISTS key_id text;
+ALTER TABLE sslkey ADD COLUMN IF NOT EXISTS key_id text;
+ALTER TABLE uri_signing_key ADD COLUMN IF NOT EXISTS key_id text;
+ALTER TABLE url_sig_key ADD COLUMN IF NOT EXISTS key_id text;
+
+CREATE INDEX IF NOT EXISTS dnssec_key_id_idx ON dnssec USING btree (key_id);
+CREATE INDEX IF NOT EXISTS sslkey_key_id_idx ON sslkey USING btree (key_id);
+CREATE INDEX IF NOT EXISTS uri_signing_key_key_id_idx ON uri_signing_key USING btree (key_id);
+CREATE INDEX IF NOT EXISTS url_sig_key_key_id_idx ON url_sig_key USING btree (key_id);
+
+CREATE TABLE IF NOT EXISTS retired_encryption_key (
+    id text NOT NULL PRIMARY KEY,
+    retired_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS retired_encryption_key;
+
+DROP INDEX IF EXISTS url_sig_key_key_id_idx;
+DROP INDEX IF EXISTS uri_signing_key_key_id_idx;
+DROP INDEX IF EXISTS sslkey_key_id_idx;
+DROP INDEX IF EXISTS dnssec_key_id_idx;
+
+ALTER TABLE url_sig_key DROP COLUMN IF EXISTS key_id;
+ALTER TABLE uri_signing_key DROP COLUMN IF EXISTS key_id;
+ALTER TABLE sslkey DROP COLUMN IF EXISTS key_id;
+ALTER TABLE dnssec DROP COLUMN IF EXISTS key_id;
@@ -1018,8 +1018,13 @@ func ParseDBError(ierr error) (error, error, int) {
 }
 
 // GetUserFromReq returns the current user, any user error, any system error, and an error code to be returned if either error was not nil.
-// This also uses the given ResponseWriter to refresh the cookie, if it was valid.
+// Requests with an "Authorization: Bearer" header are authenticated with the personal access token it contains, in which case the returned user's Token is set.
+// Otherwise, this uses the given ResponseWriter to refresh the cookie, if it was valid.
 func GetUserFromReq(w http.ResponseWriter, r *http.Request, secret string) (auth.CurrentUser, error, error, int) {
+	if token, ok := getBearerToken(r); ok {
+		return getUserFromToken(r, token)
+	}
+
 	cookie, err := r.Cookie(tocookie.Name)
 	if err != nil {
 		return auth.CurrentUser{}, errors.New("Unauthorized, please log in."), errors.New("error getting cookie: " + err.Error()), http.StatusUnauthorized
@@ -1066,6 +1071,29 @@ func GetUserFromReq(w http.ResponseWriter, r *http.Request, secret string) (auth
 	return user, nil, nil, http.StatusOK
 }
 
+// getBearerToken returns the personal access token in the request's Authorization header, if it has one.
+func getBearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	header := r.Header.Get(rfc.Authorization)
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(prefix):]), true
+}
+
+// getUserFromToken returns the user authenticated by the given personal access token, like GetUserFromReq.
+func getUserFromToken(r *http.Request, token string) (auth.CurrentUser, error, error, int) {
+	db, err := GetDB(r.Context())
+	if err != nil {
+		return auth.CurrentUser{}, nil, errors.New("getting db: " + err.Error()), http.StatusInternalServerError
+	}
+	cfg, err := GetConfig(r.Context())
+	if err != nil {
+		return auth.CurrentUser{}, nil, errors.New("request context config missing"), http.StatusInternalServerError
+	}
+	return auth.GetCurrentUserFromToken(db, token, time.Duration(cfg.DBQueryTimeoutSeconds)*time.Second)
+}
+
 func AddUserToReq(r *http.Request, u auth.CurrentUser) {
 	ctx := r.Context()
 	ctx = context.WithValue(ctx, auth.CurrentUserKey, u)
@@ -23,28 +23,30 @@ import (
 	"database/sql"
 	"errors"
 	"net/http"
-	"time"
+	"strconv"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
 
 	"github.com/jmoiron/sqlx"
 )
 
 const (
-	AsyncSucceeded = "SUCCEEDED"
-	AsyncFailed    = "FAILED"
-	AsyncPending   = "PENDING"
+	AsyncSucceeded = tc.AsyncSucceeded
+	AsyncFailed    = tc.AsyncFailed
+	AsyncPending   = tc.AsyncPending
+	AsyncRunning   = tc.AsyncRunning
+	AsyncCancelled = tc.AsyncCancelled
 )
 
 const CurrentAsyncEndpoint = "/api/4.0/async_status/"
 
-type AsyncStatus struct {
-	Id        int        `json:"id,omitempty" db:"id"`
-	Status    string     `json:"status,omitempty" db:"status"`
-	StartTime time.Time  `json:"start_time,omitempty" db:"start_time"`
-	EndTime   *time.Time `json:"end_time,omitempty" db:"end_time"`
-	Message   *string    `json:"message,omitempty" db:"message"`
-}
+type AsyncStatus = tc.AsyncStatus
 
-const selectAsyncStatusQuery = `SELECT id, status, message, start_time, end_time from async_status WHERE id = $1`
+// SelectAsyncStatusQuery selects the columns of async_status which are
+// scanned by ScanAsyncStatus, and may be followed by a WHERE clause.
+const SelectAsyncStatusQuery = `SELECT id, status, message, start_time, end_time, type, username, progress, cancel_requested, result, artifact IS NOT NULL FROM async_status`
+
+const selectAsyncStatusQuery = SelectAsyncStatusQuery + ` WHERE id = $1`
 const insertAsyncStatusQuery = `INSERT INTO async_status (status, message) VALUES ($1, $2) RETURNING id`
 const updateAsyncStatusEndTimeQuery = `UPDATE async_status SET status = $1, message = $2, end_time = now() WHERE id = $3`
 const updateAsyncStatusQuery = `UPDATE async_status SET status = $1, message = $2 WHERE id = $3`
@@ -71,7 +73,7 @@ func GetAsyncStatus(w http.ResponseWriter, r *http.Request) {
 	rowCount := 0
 	for rows.Next() {
 		rowCount++
-		err := rows.Scan(&asyncStatus.Id, &asyncStatus.Status, &asyncStatus.Message, &asyncStatus.StartTime, &asyncStatus.EndTime)
+		asyncStatus, err = ScanAsyncStatus(rows)
 		if err != nil {
 			HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, err)
 			return
@@ -86,6 +88,25 @@ func GetAsyncStatus(w http.ResponseWriter, r *http.Request) {
 	WriteResp(w, r, asyncStatus)
 }
 
+// ScanAsyncStatus scans a row selected by SelectAsyncStatusQuery into an
+// AsyncStatus.
+func ScanAsyncStatus(row interface{ Scan(...interface{}) error }) (AsyncStatus, error) {
+	var s AsyncStatus
+	var result []byte
+	hasArtifact := false
+	if err := row.Scan(&s.Id, &s.Status, &s.Message, &s.StartTime, &s.EndTime, &s.Type, &s.UserName, &s.Progress, &s.CancelRequested, &result, &hasArtifact); err != nil {
+		return s, err
+	}
+	if len(result) > 0 {
+		s.Result = result
+	}
+	if hasArtifact {
+		artifact := CurrentAsyncEndpoint + strconv.Itoa(s.Id) + "/artifact"
+		s.Artifact = &artifact
+	}
+	return s, nil
+}
+
 // InsertAsyncStatus inserts a new status for an asynchronous job.
 func InsertAsyncStatus(tx *sql.Tx, message string) (int, int, error, error) {
 	defer tx.Commit()
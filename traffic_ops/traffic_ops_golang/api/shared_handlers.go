@@ -294,6 +294,13 @@ func UpdateHandler(updater Updater) http.HandlerFunc {
 			}
 		}
 
+		if l, ok := obj.(CDNLockable); ok {
+			if userErr, sysErr, errCode := l.CheckCDNLock(inf.User); userErr != nil || sysErr != nil {
+				HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+				return
+			}
+		}
+
 		userErr, sysErr, errCode = obj.Update(r.Header)
 		if userErr != nil || sysErr != nil {
 			HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
@@ -397,6 +404,13 @@ func DeleteHandler(deleter Deleter) http.HandlerFunc {
 			}
 		}
 
+		if l, ok := obj.(CDNLockable); ok {
+			if userErr, sysErr, errCode := l.CheckCDNLock(inf.User); userErr != nil || sysErr != nil {
+				HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+				return
+			}
+		}
+
 		if isOptionsDeleter {
 			obj := reflect.New(objectType).Interface().(OptionsDeleter)
 			obj.SetInfo(inf)
@@ -111,6 +111,17 @@ type Tenantable interface {
 	IsTenantAuthorized(user *auth.CurrentUser) (bool, error)
 }
 
+// CDNLockable is implemented by objects which may be locked by a CDN lock,
+// either on their own or through the CDN to which they belong. Generic
+// Update and Delete handlers call CheckCDNLock once the object's keys are
+// known, and reject the change if it returns an error.
+type CDNLockable interface {
+	// CheckCDNLock returns any user error, any system error, and the HTTP
+	// error code to be returned if the given user may not modify the object
+	// because another user holds a lock on it.
+	CheckCDNLock(user *auth.CurrentUser) (error, error, int)
+}
+
 // APIInfoer is an interface that guarantees the existance of a variable through its setters and getters.
 // Every CRUD operation uses this login session context
 type APIInfoer interface {
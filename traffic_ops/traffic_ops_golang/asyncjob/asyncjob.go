@@ -0,0 +1,220 @@
+// Package asyncjob runs long-running Traffic Ops operations - such as taking
+// Snapshots or generating ISOs - in the background.
+//
+// Jobs are queued in the async_status table by Enqueue, in the transaction
+// of the request that starts them, and are claimed and run by a pool of
+// workers in whichever Traffic Ops instance gets to them first. Each type of
+// job is run by the Func registered for it with Register. Clients follow a
+// job's progress, logs and result through the async_status API endpoints, and
+// may ask for it to be cancelled.
+package asyncjob
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// These are the levels of the messages a job may log.
+const (
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warning"
+	LogLevelError = "error"
+)
+
+// Func runs a single job. The APIInfo it's given is that of the user who
+// queued the job, and its transaction is committed if Func returns no errors,
+// and rolled back otherwise - including if the job is cancelled, which
+// cancels the transaction's context. Like the handlers of API requests, Func
+// returns any error which may be shown to the user and any error which must
+// only be logged; if it returns neither, the message it returns becomes the
+// final message of the job.
+type Func func(inf *api.APIInfo, job *Job) (string, error, error)
+
+var (
+	funcs     = map[string]Func{}
+	funcsLock sync.RWMutex
+)
+
+// Register registers the Func which runs jobs of the given type. It is meant
+// to be called from the init function of the package which queues such jobs,
+// and panics if the type is registered twice.
+func Register(jobType string, f Func) {
+	funcsLock.Lock()
+	defer funcsLock.Unlock()
+	if _, ok := funcs[jobType]; ok {
+		panic("asyncjob: job type '" + jobType + "' registered twice")
+	}
+	funcs[jobType] = f
+}
+
+func getFunc(jobType string) (Func, bool) {
+	funcsLock.RLock()
+	defer funcsLock.RUnlock()
+	f, ok := funcs[jobType]
+	return f, ok
+}
+
+const enqueueQuery = `
+INSERT INTO async_status (status, message, type, payload, username)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, start_time
+`
+
+// Enqueue queues a job of the given type, to be run with the given payload
+// on behalf of the given user. The job is inserted in tx, so it's only run if
+// tx is committed. The returned AsyncStatus is that of the queued job.
+func Enqueue(tx *sql.Tx, jobType string, user *auth.CurrentUser, payload interface{}, message string) (tc.AsyncStatus, error) {
+	status := tc.AsyncStatus{
+		Status:   tc.AsyncPending,
+		Message:  &message,
+		Type:     jobType,
+		UserName: &user.UserName,
+	}
+	if _, ok := getFunc(jobType); !ok {
+		return status, fmt.Errorf("no job type '%s' is registered", jobType)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return status, fmt.Errorf("marshalling %s job payload: %w", jobType, err)
+	}
+	if err := tx.QueryRow(enqueueQuery, tc.AsyncPending, message, jobType, body, user.UserName).Scan(&status.Id, &status.StartTime); err != nil {
+		return status, fmt.Errorf("inserting %s job: %w", jobType, err)
+	}
+	return status, nil
+}
+
+// WriteAccepted writes the response to a request which queued the job with
+// the given status: a 202 Accepted, with a Location header and an alert
+// giving the job's async_status endpoint.
+func WriteAccepted(w http.ResponseWriter, r *http.Request, status tc.AsyncStatus, what string) {
+	link := api.CurrentAsyncEndpoint + strconv.Itoa(status.Id)
+	alerts := tc.CreateAlerts(tc.SuccessLevel, what+" has been queued. Status updates can be found here: "+link)
+	w.Header().Add("Location", link)
+	api.WriteAlertsObj(w, r, http.StatusAccepted, alerts, status)
+}
+
+// Job is a single queued job, as given to the Func which runs it.
+type Job struct {
+	ID       int
+	Type     string
+	Payload  json.RawMessage
+	UserName string
+
+	ctx         context.Context
+	db          *sqlx.DB
+	artifactDir string
+	result      interface{}
+	artifact    string
+}
+
+// Context returns the context of the job, which is cancelled if a user
+// cancels the job.
+func (j *Job) Context() context.Context {
+	return j.ctx
+}
+
+// DB returns the database, for any work which must be done outside of the
+// job's transaction.
+func (j *Job) DB() *sqlx.DB {
+	return j.db
+}
+
+// DecodePayload decodes the payload the job was queued with into v.
+func (j *Job) DecodePayload(v interface{}) error {
+	if err := json.Unmarshal(j.Payload, v); err != nil {
+		return fmt.Errorf("decoding %s job payload: %w", j.Type, err)
+	}
+	return nil
+}
+
+// SetProgress records that the given percentage of the job has been
+// completed, along with a message describing what it's doing, if not empty.
+// Progress is recorded outside of the job's transaction, so it's visible
+// while the job runs; errors recording it are only logged.
+func (j *Job) SetProgress(percent int, message string) {
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+	var msg *string
+	if message != "" {
+		msg = &message
+	}
+	if _, err := j.db.Exec(`UPDATE async_status SET progress = $1, message = COALESCE($2, message) WHERE id = $3`, percent, msg, j.ID); err != nil {
+		log.Errorf("async job #%d: recording progress: %v", j.ID, err)
+	}
+	if message != "" {
+		j.Logf(LogLevelInfo, "%s (%d%%)", message, percent)
+	}
+}
+
+// Logf adds a message with the given level to the job's log, which users may
+// read while the job runs and after it finishes. Like progress, log messages
+// are recorded outside of the job's transaction.
+func (j *Job) Logf(level string, format string, v ...interface{}) {
+	message := fmt.Sprintf(format, v...)
+	if _, err := j.db.Exec(`INSERT INTO async_status_log (async_status, level, message) VALUES ($1, $2, $3)`, j.ID, level, message); err != nil {
+		log.Errorf("async job #%d: logging '%s': %v", j.ID, message, err)
+	}
+}
+
+// SetResult sets the result of the job, which is shown to users as JSON if
+// the job succeeds.
+func (j *Job) SetResult(v interface{}) {
+	j.result = v
+}
+
+// CreateArtifact creates the file, with the given name, in which the job
+// writes the file it produces, which users may download if the job
+// succeeds. A job may produce only one such file.
+func (j *Job) CreateArtifact(filename string) (*os.File, error) {
+	if j.artifact != "" {
+		return nil, errors.New("job already has an artifact")
+	}
+	dir := filepath.Join(j.artifactDir, strconv.Itoa(j.ID))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating artifact directory: %w", err)
+	}
+	path := filepath.Join(dir, filepath.Base(filename))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("creating artifact: %w", err)
+	}
+	j.artifact = path
+	return f, nil
+}
@@ -0,0 +1,379 @@
+package asyncjob
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/trafficvault"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/trafficvault/backends/disabled"
+
+	"github.com/jmoiron/sqlx"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func noopJob(inf *api.APIInfo, job *Job) (string, error, error) {
+	return "done", nil, nil
+}
+
+// registerForTest registers the Func which runs jobs of the given type for
+// the duration of a test.
+func registerForTest(t *testing.T, jobType string, f Func) {
+	Register(jobType, f)
+	t.Cleanup(func() {
+		funcsLock.Lock()
+		defer funcsLock.Unlock()
+		delete(funcs, jobType)
+	})
+}
+
+func TestRegister(t *testing.T) {
+	registerForTest(t, "test_register", noopJob)
+	if _, ok := getFunc("test_register"); !ok {
+		t.Fatal("expected a registered job type to have a Func")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering a job type twice to panic")
+		}
+	}()
+	Register("test_register", noopJob)
+}
+
+func TestEnqueue(t *testing.T) {
+	registerForTest(t, "test_enqueue", noopJob)
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	start := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO async_status").
+		WithArgs(tc.AsyncPending, "test queued", "test_enqueue", []byte(`{"cdn":"cdn1"}`), "admin").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "start_time"}).AddRow(7, start))
+
+	tx, err := mockDB.Begin()
+	if err != nil {
+		t.Fatalf("beginning transaction: %v", err)
+	}
+	user := auth.CurrentUser{UserName: "admin"}
+	status, err := Enqueue(tx, "test_enqueue", &user, map[string]string{"cdn": "cdn1"}, "test queued")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.Id != 7 || status.Status != tc.AsyncPending || status.Type != "test_enqueue" || !status.StartTime.Equal(start) {
+		t.Errorf("unexpected status of queued job: %+v", status)
+	}
+	if status.UserName == nil || *status.UserName != "admin" {
+		t.Errorf("expected queued job to have user 'admin', got %v", status.UserName)
+	}
+
+	if _, err := Enqueue(tx, "test_unregistered", &user, nil, "test queued"); err == nil {
+		t.Error("expected an error queueing a job of an unregistered type")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestWriteAccepted(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, "/api/4.0/snapshot", nil)
+	WriteAccepted(w, r, tc.AsyncStatus{Id: 12, Status: tc.AsyncPending}, "Snapshot of CDN cdn1")
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("expected status %d, got %d", http.StatusAccepted, w.Code)
+	}
+	if loc := w.Header().Get("Location"); loc != "/api/4.0/async_status/12" {
+		t.Errorf("expected Location '/api/4.0/async_status/12', got '%s'", loc)
+	}
+	resp := tc.AsyncStatusResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.Response.Id != 12 || len(resp.Alerts.Alerts) != 1 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestJobCreateArtifact(t *testing.T) {
+	dir, err := tempDir(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := Job{ID: 3, artifactDir: dir}
+	f, err := job.CreateArtifact("../host.example.com-centos72.iso")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Close()
+	if expected := filepath.Join(dir, "3", "host.example.com-centos72.iso"); job.artifact != expected {
+		t.Errorf("expected artifact '%s', got '%s'", expected, job.artifact)
+	}
+	if _, err := os.Stat(job.artifact); err != nil {
+		t.Errorf("expected artifact to exist: %v", err)
+	}
+	if _, err := job.CreateArtifact("other.iso"); err == nil {
+		t.Error("expected an error creating a second artifact")
+	}
+}
+
+func TestCheckAccess(t *testing.T) {
+	alice := "alice"
+	testCases := []struct {
+		name  string
+		user  auth.CurrentUser
+		owner *string
+		code  int
+	}{
+		{name: "creator", user: auth.CurrentUser{UserName: "alice", PrivLevel: auth.PrivLevelOperations}, owner: &alice, code: http.StatusOK},
+		{name: "another operations user", user: auth.CurrentUser{UserName: "bob", PrivLevel: auth.PrivLevelOperations}, owner: &alice, code: http.StatusForbidden},
+		{name: "admin", user: auth.CurrentUser{UserName: "admin", PrivLevel: auth.PrivLevelAdmin}, owner: &alice, code: http.StatusOK},
+		{name: "job without a user", user: auth.CurrentUser{UserName: "bob", PrivLevel: auth.PrivLevelOperations}, code: http.StatusForbidden},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer mockDB.Close()
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT username FROM async_status").WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow(testCase.owner))
+			tx, err := mockDB.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			userErr, sysErr, code := checkAccess(tx, 4, &testCase.user)
+			if sysErr != nil {
+				t.Fatalf("unexpected system error: %v", sysErr)
+			}
+			if code != testCase.code || (code == http.StatusOK) != (userErr == nil) {
+				t.Errorf("expected code %d, actual %d, user error: %v", testCase.code, code, userErr)
+			}
+		})
+	}
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT username FROM async_status").WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"username"}))
+	tx, err := mockDB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, code := checkAccess(tx, 4, &auth.CurrentUser{UserName: "admin", PrivLevel: auth.PrivLevelAdmin}); code != http.StatusNotFound {
+		t.Errorf("expected a missing job to be %d, actual %d", http.StatusNotFound, code)
+	}
+}
+
+// cancelRequest returns a request to cancel the async job with the given ID,
+// made by the given user.
+func cancelRequest(t *testing.T, db *sqlx.DB, id string, user auth.CurrentUser) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/4.0/async_status/"+id+"/cancel", nil)
+	cfg := config.Config{ConfigTrafficOpsGolang: config.ConfigTrafficOpsGolang{DBQueryTimeoutSeconds: 20}}
+	var tv trafficvault.TrafficVault = &disabled.Disabled{}
+	ctx := r.Context()
+	ctx = context.WithValue(ctx, auth.CurrentUserKey, user)
+	ctx = context.WithValue(ctx, "db", db)
+	ctx = context.WithValue(ctx, "context", &cfg)
+	ctx = context.WithValue(ctx, "reqid", uint64(0))
+	ctx = context.WithValue(ctx, api.TrafficVaultContextKey, tv)
+	ctx = context.WithValue(ctx, "pathParams", map[string]string{"id": id})
+	return r.WithContext(ctx)
+}
+
+func TestCancel(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT username FROM async_status").WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectRollback()
+	r := cancelRequest(t, db, "4", auth.CurrentUser{UserName: "bob", ID: 3, PrivLevel: auth.PrivLevelOperations})
+	Cancel(httptest.NewRecorder(), r)
+	if code, _ := r.Context().Value(tc.StatusKey).(int); code != http.StatusForbidden {
+		t.Errorf("expected another operations user's cancellation to be %d, actual %d", http.StatusForbidden, code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expected the job not to be changed: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT username FROM async_status").WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectQuery("SELECT status, type FROM async_status").WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"status", "type"}).AddRow(tc.AsyncPending, "test"))
+	mock.ExpectExec("UPDATE async_status").WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 1))
+	Cancel(httptest.NewRecorder(), cancelRequest(t, db, "4", auth.CurrentUser{UserName: "alice", ID: 2, PrivLevel: auth.PrivLevelOperations}))
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expected the creator to be able to cancel the job: %v", err)
+	}
+}
+
+const userQuery = "SELECT .* FROM tm_user"
+
+func userRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"priv_level", "role", "role_name", "id", "username", "tenant_id", "capabilities"}).
+		AddRow(30, 1, "admin", 2, "admin", 1, "{}")
+}
+
+func TestWorkerRun(t *testing.T) {
+	registerForTest(t, "test_succeed", func(inf *api.APIInfo, job *Job) (string, error, error) {
+		if inf.User.UserName != "admin" {
+			return "", nil, errors.New("wrong user " + inf.User.UserName)
+		}
+		job.SetResult(map[string]int{"servers": 2})
+		return "all done", nil, nil
+	})
+	registerForTest(t, "test_user_error", func(inf *api.APIInfo, job *Job) (string, error, error) {
+		return "", errors.New("no such CDN"), nil
+	})
+	registerForTest(t, "test_cancel", func(inf *api.APIInfo, job *Job) (string, error, error) {
+		inf.CancelTx()
+		return "", nil, job.Context().Err()
+	})
+
+	type testCase struct {
+		jobType string
+		status  string
+		message string
+		result  interface{}
+		db      func(mock sqlmock.Sqlmock)
+	}
+	testCases := []testCase{
+		{
+			jobType: "test_succeed",
+			status:  tc.AsyncSucceeded,
+			message: "all done",
+			result:  []byte(`{"servers":2}`),
+			db: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(userQuery).WithArgs("admin").WillReturnRows(userRows())
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+		},
+		{
+			jobType: "test_user_error",
+			status:  tc.AsyncFailed,
+			message: "no such CDN",
+			db: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(userQuery).WithArgs("admin").WillReturnRows(userRows())
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+		},
+		{
+			jobType: "test_cancel",
+			status:  tc.AsyncCancelled,
+			message: "The job was cancelled.",
+			db: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(userQuery).WithArgs("admin").WillReturnRows(userRows())
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+		},
+		{
+			jobType: "test_unknown",
+			status:  tc.AsyncFailed,
+			message: "No job type 'test_unknown' is known to the Traffic Ops instance which claimed this job.",
+			db:      func(mock sqlmock.Sqlmock) {},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.jobType, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer mockDB.Close()
+			db := sqlx.NewDb(mockDB, "sqlmock")
+
+			testCase.db(mock)
+			mock.ExpectExec("INSERT INTO async_status_log").WithArgs(5, sqlmock.AnyArg(), testCase.message).WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec("UPDATE async_status").WithArgs(testCase.status, testCase.message, testCase.result, nil, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+
+			wk := &Worker{db: db}
+			wk.run(&Job{ID: 5, Type: testCase.jobType, UserName: "admin", db: db})
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestRemoveExpiredArtifacts(t *testing.T) {
+	dir, err := tempDir(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"1", "2"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "1"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	wk := &Worker{artifactDir: dir, artifactLifetime: 24 * time.Hour}
+	wk.removeExpiredArtifacts(time.Now())
+	if _, err := os.Stat(filepath.Join(dir, "1")); !os.IsNotExist(err) {
+		t.Errorf("expected expired artifact to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2")); err != nil {
+		t.Errorf("expected unexpired artifact to be kept, got %v", err)
+	}
+}
+
+// tempDir creates a temporary directory which is removed when the test
+// finishes.
+func tempDir(t *testing.T) (string, error) {
+	dir, err := ioutil.TempDir("", "asyncjob")
+	if err != nil {
+		return "", err
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir, nil
+}
@@ -0,0 +1,277 @@
+package asyncjob
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-rfc"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
+)
+
+const readLogsQuery = `
+SELECT time, level, message FROM async_status_log
+WHERE async_status = $1
+ORDER BY time, id
+`
+
+const selectForCancelQuery = `SELECT status, type FROM async_status WHERE id = $1 FOR UPDATE`
+
+const cancelPendingQuery = `
+UPDATE async_status
+SET status = '` + tc.AsyncCancelled + `', cancel_requested = true, message = 'The job was cancelled.', end_time = now()
+WHERE id = $1
+`
+
+const cancelRunningQuery = `UPDATE async_status SET cancel_requested = true WHERE id = $1`
+
+const artifactQuery = `SELECT status, artifact FROM async_status WHERE id = $1`
+
+const ownerQuery = `SELECT username FROM async_status WHERE id = $1`
+
+// checkAccess checks that the given user may see, read the logs and artifact
+// of, or cancel the async job with the given ID; only the user who started a
+// job, and admins, may do so.
+func checkAccess(tx *sql.Tx, id int, user *auth.CurrentUser) (error, error, int) {
+	var owner *string
+	if err := tx.QueryRow(ownerQuery, id).Scan(&owner); err == sql.ErrNoRows {
+		return fmt.Errorf("no async status with id %d", id), nil, http.StatusNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("getting the user of async status #%d: %v", id, err), http.StatusInternalServerError
+	}
+	if user.PrivLevel >= auth.PrivLevelAdmin || (owner != nil && *owner == user.UserName) {
+		return nil, nil, http.StatusOK
+	}
+	return fmt.Errorf("async job #%d was started by another user", id), nil, http.StatusForbidden
+}
+
+// Read is the handler for GET requests to /async_status. Users other than
+// admins only see the jobs they started.
+func Read(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	cols := map[string]dbhelpers.WhereColumnInfo{
+		"id":       {Column: "id", Checker: api.IsInt},
+		"status":   {Column: "status", Checker: nil},
+		"type":     {Column: "type", Checker: nil},
+		"username": {Column: "username", Checker: nil},
+	}
+	where, orderBy, pagination, queryValues, errs := dbhelpers.BuildWhereAndOrderByAndPagination(inf.Params, cols)
+	if len(errs) > 0 {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, util.JoinErrs(errs), nil)
+		return
+	}
+	if orderBy == "" {
+		orderBy = " ORDER BY id DESC"
+	}
+	if inf.User.PrivLevel < auth.PrivLevelAdmin {
+		if len(where) > 0 {
+			where += " AND username = :requester "
+		} else {
+			where = dbhelpers.BaseWhere + " username = :requester "
+		}
+		if queryValues == nil {
+			queryValues = map[string]interface{}{}
+		}
+		queryValues["requester"] = inf.User.UserName
+	}
+
+	rows, err := inf.Tx.NamedQuery(api.SelectAsyncStatusQuery+where+orderBy+pagination, queryValues)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("querying async statuses: "+err.Error()))
+		return
+	}
+	defer log.Close(rows, "closing async status rows")
+
+	statuses := []tc.AsyncStatus{}
+	for rows.Next() {
+		s, err := api.ScanAsyncStatus(rows)
+		if err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("scanning async statuses: "+err.Error()))
+			return
+		}
+		statuses = append(statuses, s)
+	}
+	api.WriteResp(w, r, statuses)
+}
+
+// ReadLogs is the handler for GET requests to /async_status/{{ID}}/logs.
+func ReadLogs(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+	id := inf.IntParams["id"]
+
+	if userErr, sysErr, errCode := checkAccess(tx, id, inf.User); userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	rows, err := tx.Query(readLogsQuery, id)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("querying logs of async status #%d: %v", id, err))
+		return
+	}
+	defer log.Close(rows, "closing async status log rows")
+
+	entries := []tc.AsyncStatusLogEntry{}
+	for rows.Next() {
+		e := tc.AsyncStatusLogEntry{}
+		if err := rows.Scan(&e.Time, &e.Level, &e.Message); err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("scanning logs of async status #%d: %v", id, err))
+			return
+		}
+		entries = append(entries, e)
+	}
+	api.WriteResp(w, r, entries)
+}
+
+// Cancel is the handler for POST requests to /async_status/{{ID}}/cancel. A
+// job which hasn't started is cancelled immediately; a running job is asked
+// to stop, and is cancelled once the worker running it notices.
+func Cancel(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+	id := inf.IntParams["id"]
+
+	if userErr, sysErr, errCode := checkAccess(tx, id, inf.User); userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	status := ""
+	jobType := ""
+	if err := tx.QueryRow(selectForCancelQuery, id).Scan(&status, &jobType); err == sql.ErrNoRows {
+		api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no async status with id %d", id), nil)
+		return
+	} else if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("getting async status #%d: %v", id, err))
+		return
+	}
+	if jobType == "" {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, fmt.Errorf("async job #%d cannot be cancelled", id), nil)
+		return
+	}
+
+	alert := ""
+	switch status {
+	case tc.AsyncPending:
+		if _, err := tx.Exec(cancelPendingQuery, id); err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("cancelling async job #%d: %v", id, err))
+			return
+		}
+		alert = fmt.Sprintf("async job #%d was cancelled.", id)
+	case tc.AsyncRunning:
+		if _, err := tx.Exec(cancelRunningQuery, id); err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("requesting cancellation of async job #%d: %v", id, err))
+			return
+		}
+		alert = fmt.Sprintf("cancellation of async job #%d was requested.", id)
+	default:
+		api.HandleErr(w, r, tx, http.StatusBadRequest, fmt.Errorf("async job #%d has already finished", id), nil)
+		return
+	}
+
+	s, err := api.ScanAsyncStatus(tx.QueryRow(api.SelectAsyncStatusQuery+` WHERE id = $1`, id))
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("getting cancelled async status #%d: %v", id, err))
+		return
+	}
+	api.CreateChangeLogRawTx(api.ApiChange, fmt.Sprintf("ASYNC JOB: %d, TYPE: %s, ACTION: Cancelled", id, jobType), inf.User, tx)
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, alert, s)
+}
+
+// ReadArtifact is the handler for GET requests to
+// /async_status/{{ID}}/artifact, which downloads the file produced by a
+// successful job.
+func ReadArtifact(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+	id := inf.IntParams["id"]
+
+	if userErr, sysErr, errCode := checkAccess(tx, id, inf.User); userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	status := ""
+	var artifact *string
+	if err := tx.QueryRow(artifactQuery, id).Scan(&status, &artifact); err == sql.ErrNoRows {
+		api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no async status with id %d", id), nil)
+		return
+	} else if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("getting artifact of async status #%d: %v", id, err))
+		return
+	}
+	if status != tc.AsyncSucceeded || artifact == nil {
+		api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("async job #%d has no artifact", id), nil)
+		return
+	}
+
+	f, err := os.Open(*artifact)
+	if os.IsNotExist(err) {
+		api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("the artifact of async job #%d has expired, or is stored by a different Traffic Ops instance", id), nil)
+		return
+	} else if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("opening artifact of async job #%d: %v", id, err))
+		return
+	}
+	defer log.Close(f, "closing async job artifact")
+	if info, err := f.Stat(); err == nil {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	}
+	w.Header().Set(rfc.ContentDisposition, fmt.Sprintf("attachment; filename=%q", filepath.Base(*artifact)))
+	w.Header().Set(rfc.ContentType, rfc.ApplicationOctetStream)
+	if _, err := io.Copy(w, f); err != nil {
+		log.Errorf("writing artifact of async job #%d: %v", id, err)
+	}
+}
@@ -0,0 +1,329 @@
+package asyncjob
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/trafficvault"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PollInterval is how often workers check for queued jobs.
+const PollInterval = 2 * time.Second
+
+// HeartbeatInterval is how often a running job's heartbeat is recorded, and
+// how often it's checked for a request to cancel it.
+const HeartbeatInterval = 2 * time.Second
+
+// staleJobAge is how long a running job may go without a heartbeat before
+// it's assumed that the Traffic Ops instance running it stopped, and it is
+// marked failed.
+const staleJobAge = time.Minute
+
+// artifactCleanupInterval is how often expired artifacts are removed.
+const artifactCleanupInterval = time.Hour
+
+// userTimeout is the timeout of looking up the user who queued a job.
+const userTimeout = 10 * time.Second
+
+// claimQuery marks up to $1 queued jobs as running, and returns them. SKIP
+// LOCKED keeps multiple Traffic Ops instances from claiming the same job.
+const claimQuery = `
+UPDATE async_status SET status = '` + tc.AsyncRunning + `', heartbeat = now()
+WHERE id IN (
+	SELECT id FROM async_status
+	WHERE status = '` + tc.AsyncPending + `' AND type <> ''
+	ORDER BY id
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING id, type, payload, COALESCE(username, '')
+`
+
+const reapQuery = `
+UPDATE async_status
+SET status = '` + tc.AsyncFailed + `', message = 'The Traffic Ops instance running this job stopped before it finished.', end_time = now()
+WHERE status = '` + tc.AsyncRunning + `' AND type <> ''
+AND heartbeat < now() - $1 * interval '1 second'
+`
+
+const heartbeatQuery = `
+UPDATE async_status SET heartbeat = now()
+WHERE id = $1
+RETURNING cancel_requested
+`
+
+const finishQuery = `
+UPDATE async_status
+SET status = $1,
+	message = $2,
+	result = $3,
+	artifact = $4,
+	progress = CASE WHEN $1 = '` + tc.AsyncSucceeded + `' THEN 100 ELSE progress END,
+	end_time = now()
+WHERE id = $5
+`
+
+// Worker runs the jobs queued by Enqueue.
+type Worker struct {
+	db               *sqlx.DB
+	cfg              *config.Config
+	vault            trafficvault.TrafficVault
+	sem              chan struct{}
+	artifactDir      string
+	artifactLifetime time.Duration
+}
+
+// NewWorker returns a Worker which runs the jobs queued in db, as many at
+// once as the given configuration allows.
+func NewWorker(db *sqlx.DB, cfg *config.Config, vault trafficvault.TrafficVault) *Worker {
+	return &Worker{
+		db:               db,
+		cfg:              cfg,
+		vault:            vault,
+		sem:              make(chan struct{}, cfg.AsyncJobs.Workers),
+		artifactDir:      cfg.AsyncJobs.ArtifactDir,
+		artifactLifetime: time.Duration(cfg.AsyncJobs.ArtifactTTLHours) * time.Hour,
+	}
+}
+
+// StartWorker starts a Worker running the jobs queued in db, which runs for
+// the life of the process.
+func StartWorker(db *sqlx.DB, cfg *config.Config, vault trafficvault.TrafficVault) {
+	go NewWorker(db, cfg, vault).Run()
+}
+
+// Run polls for and runs queued jobs forever.
+func (wk *Worker) Run() {
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	cleanupTicker := time.NewTicker(artifactCleanupInterval)
+	defer cleanupTicker.Stop()
+	for {
+		select {
+		case <-cleanupTicker.C:
+			wk.removeExpiredArtifacts(time.Now())
+		case <-ticker.C:
+			if _, err := wk.db.Exec(reapQuery, int(staleJobAge.Seconds())); err != nil {
+				log.Errorln("async job: failing stale jobs: " + err.Error())
+			}
+			free := cap(wk.sem) - len(wk.sem)
+			if free == 0 {
+				continue
+			}
+			jobs, err := wk.claim(free)
+			if err != nil {
+				log.Errorln("async job: " + err.Error())
+				continue
+			}
+			for _, job := range jobs {
+				wk.sem <- struct{}{}
+				go func(job *Job) {
+					defer func() { <-wk.sem }()
+					wk.run(job)
+				}(job)
+			}
+		}
+	}
+}
+
+// claim marks up to limit queued jobs as being run by this Worker, and
+// returns them.
+func (wk *Worker) claim(limit int) ([]*Job, error) {
+	rows, err := wk.db.Query(claimQuery, limit)
+	if err != nil {
+		return nil, errors.New("claiming jobs: " + err.Error())
+	}
+	defer log.Close(rows, "closing async job rows")
+	jobs := []*Job{}
+	for rows.Next() {
+		job := &Job{db: wk.db, artifactDir: wk.artifactDir}
+		if err := rows.Scan(&job.ID, &job.Type, &job.Payload, &job.UserName); err != nil {
+			return nil, errors.New("scanning jobs: " + err.Error())
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// run runs a claimed job, recording its heartbeat while it runs and its
+// outcome once it finishes.
+func (wk *Worker) run(job *Job) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	job.ctx = ctx
+
+	done := make(chan struct{})
+	go wk.heartbeat(job.ID, cancel, done)
+	status, message := wk.execute(job, cancel)
+	close(done)
+
+	var result interface{}
+	if status == tc.AsyncSucceeded && job.result != nil {
+		if b, err := json.Marshal(job.result); err != nil {
+			log.Errorf("async job #%d: marshalling result: %v", job.ID, err)
+		} else {
+			result = b
+		}
+	}
+	var artifact *string
+	if status == tc.AsyncSucceeded && job.artifact != "" {
+		artifact = &job.artifact
+	} else if job.artifact != "" {
+		if err := os.RemoveAll(filepath.Dir(job.artifact)); err != nil {
+			log.Errorf("async job #%d: removing artifact of unsuccessful job: %v", job.ID, err)
+		}
+	}
+
+	level := LogLevelInfo
+	if status != tc.AsyncSucceeded {
+		level = LogLevelError
+	}
+	job.Logf(level, "%s", message)
+	if _, err := wk.db.Exec(finishQuery, status, message, result, artifact, job.ID); err != nil {
+		log.Errorf("async job #%d: recording %s outcome: %v", job.ID, status, err)
+	}
+}
+
+// heartbeat records the heartbeat of the job with the given ID until done is
+// closed, calling cancel if a user asks for the job to be cancelled.
+func (wk *Worker) heartbeat(id int, cancel context.CancelFunc, done <-chan struct{}) {
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			cancelRequested := false
+			if err := wk.db.QueryRow(heartbeatQuery, id).Scan(&cancelRequested); err != nil {
+				log.Errorf("async job #%d: recording heartbeat: %v", id, err)
+				continue
+			}
+			if cancelRequested {
+				cancel()
+			}
+		}
+	}
+}
+
+// execute runs the Func registered for the type of the given job, in a
+// transaction made on behalf of the user who queued it, and returns the
+// status and message with which the job finished.
+func (wk *Worker) execute(job *Job, cancel context.CancelFunc) (status string, message string) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Errorf("async job #%d: panic: (err: %v) stacktrace:\n%s\n", job.ID, err, util.Stacktrace())
+			status, message = tc.AsyncFailed, failedMessage(job.Type)
+		}
+	}()
+
+	f, ok := getFunc(job.Type)
+	if !ok {
+		return tc.AsyncFailed, "No job type '" + job.Type + "' is known to the Traffic Ops instance which claimed this job."
+	}
+	user, userErr, sysErr, _ := auth.GetCurrentUserFromDB(wk.db, job.UserName, userTimeout)
+	if userErr != nil || sysErr != nil {
+		log.Errorf("async job #%d: getting user '%s': %v", job.ID, job.UserName, sysErr)
+		return tc.AsyncFailed, "The user who queued this job no longer exists."
+	}
+
+	tx, err := wk.db.BeginTxx(job.ctx, nil)
+	if err != nil {
+		log.Errorf("async job #%d: beginning transaction: %v", job.ID, err)
+		return tc.AsyncFailed, failedMessage(job.Type)
+	}
+	inf := &api.APIInfo{
+		Params:    map[string]string{},
+		IntParams: map[string]int{},
+		User:      &user,
+		Version:   &api.Version{Major: 4, Minor: 0},
+		Tx:        tx,
+		CancelTx:  cancel,
+		Vault:     wk.vault,
+		Config:    wk.cfg,
+	}
+
+	message, userErr, sysErr = f(inf, job)
+	if job.ctx.Err() != nil {
+		rollback(job.ID, tx.Tx)
+		return tc.AsyncCancelled, "The job was cancelled."
+	}
+	if userErr != nil || sysErr != nil {
+		rollback(job.ID, tx.Tx)
+		if sysErr != nil {
+			log.Errorf("async job #%d: %s: %v", job.ID, job.Type, sysErr)
+		}
+		if userErr != nil {
+			return tc.AsyncFailed, userErr.Error()
+		}
+		return tc.AsyncFailed, failedMessage(job.Type)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Errorf("async job #%d: committing transaction: %v", job.ID, err)
+		return tc.AsyncFailed, failedMessage(job.Type)
+	}
+	return tc.AsyncSucceeded, message
+}
+
+func rollback(id int, tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		log.Errorf("async job #%d: rolling back transaction: %v", id, err)
+	}
+}
+
+func failedMessage(jobType string) string {
+	return "The " + jobType + " job failed due to an internal error."
+}
+
+// removeExpiredArtifacts removes the artifacts of jobs which were last
+// modified longer than the artifact lifetime before now.
+func (wk *Worker) removeExpiredArtifacts(now time.Time) {
+	dirs, err := ioutil.ReadDir(wk.artifactDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("async job: reading artifact directory: %v", err)
+		}
+		return
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() || now.Sub(dir.ModTime()) < wk.artifactLifetime {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(wk.artifactDir, dir.Name())); err != nil {
+			log.Errorf("async job: removing expired artifact '%s': %v", dir.Name(), err)
+		}
+	}
+}
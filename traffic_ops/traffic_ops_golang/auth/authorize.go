@@ -41,7 +41,11 @@ type CurrentUser struct {
 	PrivLevel    int            `json:"privLevel" db:"priv_level"`
 	TenantID     int            `json:"tenantId" db:"tenant_id"`
 	Role         int            `json:"role" db:"role"`
+	RoleName     string         `json:"roleName" db:"role_name"`
 	Capabilities pq.StringArray `json:"capabilities" db:"capabilities"`
+	// Token is the scope of the personal access token with which the user
+	// authenticated, or nil if they didn't authenticate with one.
+	Token *TokenScope `json:"-" db:"-"`
 }
 
 type PasswordForm struct {
@@ -49,7 +53,10 @@ type PasswordForm struct {
 	Password string `json:"p"`
 }
 
-const disallowed = "disallowed"
+// DisallowedRoleName is the name of the Role of users who aren't allowed to log in.
+const DisallowedRoleName = "disallowed"
+
+const disallowed = DisallowedRoleName
 
 // PrivLevelInvalid - The Default Priv level
 const PrivLevelInvalid = -1
@@ -73,7 +80,10 @@ const TenantIDInvalid = -1
 
 type key int
 
-const CurrentUserKey key = iota
+const (
+	CurrentUserKey key = iota
+	RouteKey
+)
 
 // GetCurrentUserFromDB  - returns the id and privilege level of the given user along with the username, or -1 as the id, - as the userName and PrivLevelInvalid if the user doesn't exist, along with a user facing error, a system error to log, and an error code to return
 func GetCurrentUserFromDB(DB *sqlx.DB, user string, timeout time.Duration) (CurrentUser, error, error, int) {
@@ -81,6 +91,7 @@ func GetCurrentUserFromDB(DB *sqlx.DB, user string, timeout time.Duration) (Curr
 SELECT
   r.priv_level,
   r.id as role,
+  r.name as role_name,
   u.id,
   u.username,
   COALESCE(u.tenant_id, -1) AS tenant_id,
@@ -95,7 +106,7 @@ WHERE
 
 	var currentUserInfo CurrentUser
 	if DB == nil {
-		return CurrentUser{"-", -1, PrivLevelInvalid, TenantIDInvalid, -1, []string{}}, nil, errors.New("no db provided to GetCurrentUserFromDB"), http.StatusInternalServerError
+		return CurrentUser{"-", -1, PrivLevelInvalid, TenantIDInvalid, -1, "", []string{}, nil}, nil, errors.New("no db provided to GetCurrentUserFromDB"), http.StatusInternalServerError
 	}
 	dbCtx, dbClose := context.WithTimeout(context.Background(), timeout)
 	defer dbClose()
@@ -103,11 +114,11 @@ WHERE
 	err := DB.GetContext(dbCtx, &currentUserInfo, qry, user)
 	switch {
 	case err == sql.ErrNoRows:
-		return CurrentUser{"-", -1, PrivLevelInvalid, TenantIDInvalid, -1, []string{}}, errors.New("user not found"), fmt.Errorf("checking user %v info: user not in database", user), http.StatusUnauthorized
+		return CurrentUser{"-", -1, PrivLevelInvalid, TenantIDInvalid, -1, "", []string{}, nil}, errors.New("user not found"), fmt.Errorf("checking user %v info: user not in database", user), http.StatusUnauthorized
 	case err == context.DeadlineExceeded || err == context.Canceled:
-		return CurrentUser{"-", -1, PrivLevelInvalid, TenantIDInvalid, -1, []string{}}, nil, fmt.Errorf("db access timed out: %s number of open connections: %d\n", err, DB.Stats().OpenConnections), http.StatusServiceUnavailable
+		return CurrentUser{"-", -1, PrivLevelInvalid, TenantIDInvalid, -1, "", []string{}, nil}, nil, fmt.Errorf("db access timed out: %s number of open connections: %d\n", err, DB.Stats().OpenConnections), http.StatusServiceUnavailable
 	case err != nil:
-		return CurrentUser{"-", -1, PrivLevelInvalid, TenantIDInvalid, -1, []string{}}, nil, fmt.Errorf("Error checking user %v info: %v", user, err.Error()), http.StatusInternalServerError
+		return CurrentUser{"-", -1, PrivLevelInvalid, TenantIDInvalid, -1, "", []string{}, nil}, nil, fmt.Errorf("Error checking user %v info: %v", user, err.Error()), http.StatusInternalServerError
 	default:
 		return currentUserInfo, nil, nil, http.StatusOK
 	}
@@ -118,12 +129,18 @@ func GetCurrentUser(ctx context.Context) (*CurrentUser, error) {
 	if val != nil {
 		switch v := val.(type) {
 		case CurrentUser:
+			if v.Token != nil {
+				route, _ := ctx.Value(RouteKey).(Route)
+				if err := v.Token.Check(route, time.Now()); err != nil {
+					return nil, err
+				}
+			}
 			return &v, nil
 		default:
 			return nil, fmt.Errorf("CurrentUser found with bad type: %T", v)
 		}
 	}
-	return &CurrentUser{"-", -1, PrivLevelInvalid, TenantIDInvalid, -1, []string{}}, errors.New("No user found in Context")
+	return &CurrentUser{"-", -1, PrivLevelInvalid, TenantIDInvalid, -1, "", []string{}, nil}, errors.New("No user found in Context")
 }
 
 func CheckLocalUserIsAllowed(form PasswordForm, db *sqlx.DB, timeout time.Duration) (bool, error, error) {
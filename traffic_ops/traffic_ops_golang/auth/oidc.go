@@ -0,0 +1,438 @@
+package auth
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// oidcDiscoveryPath is the path, relative to the issuer URL, of an OpenID Connect provider's discovery document, per OpenID Connect Discovery 1.0 §4.
+const oidcDiscoveryPath = "/.well-known/openid-configuration"
+
+// oidcMinKeyRefetchInterval is the minimum time between fetches of a provider's signing keys caused by tokens signed with unknown keys,
+// so that tokens with bogus key IDs can't be used to flood the provider with requests.
+const oidcMinKeyRefetchInterval = 10 * time.Second
+
+// oidcSigningMethods are the algorithms accepted for ID token signatures. Symmetric algorithms and "none" are never accepted.
+var oidcSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// ErrOIDCNoRole is returned when an OpenID Connect user isn't in any mapped group, and there's no default Role.
+var ErrOIDCNoRole = errors.New("user is not in any group which is mapped to a role")
+
+// OIDCDiscovery is the subset of an OpenID Connect provider's discovery document used by Traffic Ops.
+type OIDCDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCIdentity is a user, as identified by the claims of an OpenID Connect ID token.
+type OIDCIdentity struct {
+	// Issuer and Subject are the immutable identifier of the user at the identity provider, which Traffic Ops users are linked to.
+	Issuer  string
+	Subject string
+	// Username is the value of the configured username claim, which the user may be able to change at the identity provider,
+	// so it only names newly provisioned users and never identifies existing ones.
+	Username string
+	Email    *string
+	FullName *string
+	Groups   []string
+	// Tenant is the value of the configured tenant claim, if any.
+	Tenant string
+}
+
+// OIDCProvider is an OpenID Connect identity provider. It caches the provider's discovery document and signing keys, and is safe for concurrent use.
+type OIDCProvider struct {
+	cfg    config.ConfigOIDC
+	client *http.Client
+	now    func() time.Time
+
+	mutex         sync.Mutex
+	discovery     *OIDCDiscovery
+	discoveredAt  time.Time
+	keys          *jwk.Set
+	keysFetchedAt time.Time
+}
+
+var oidcProviders = struct {
+	sync.Mutex
+	provider *OIDCProvider
+}{}
+
+// GetOIDCProvider returns the OpenID Connect provider for the given configuration. The same provider is returned for as long as the
+// configuration doesn't change, so that its discovery document and signing keys stay cached between requests.
+func GetOIDCProvider(cfg config.ConfigOIDC) *OIDCProvider {
+	oidcProviders.Lock()
+	defer oidcProviders.Unlock()
+	if oidcProviders.provider == nil || !reflect.DeepEqual(oidcProviders.provider.cfg, cfg) {
+		oidcProviders.provider = NewOIDCProvider(cfg)
+	}
+	return oidcProviders.provider
+}
+
+// NewOIDCProvider returns a new OpenID Connect provider for the given configuration, which must have been validated with config.ValidateOIDC.
+func NewOIDCProvider(cfg config.ConfigOIDC) *OIDCProvider {
+	return &OIDCProvider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+		now:    time.Now,
+	}
+}
+
+func (p *OIDCProvider) refreshInterval() time.Duration {
+	return time.Duration(p.cfg.JWKSRefreshIntervalSeconds) * time.Second
+}
+
+// Discover returns the provider's discovery document, fetching it if it isn't cached or the cache has expired.
+func (p *OIDCProvider) Discover(ctx context.Context) (OIDCDiscovery, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.discover(ctx)
+}
+
+// discover is Discover, for callers which hold the mutex.
+func (p *OIDCProvider) discover(ctx context.Context) (OIDCDiscovery, error) {
+	if p.discovery != nil && p.now().Sub(p.discoveredAt) < p.refreshInterval() {
+		return *p.discovery, nil
+	}
+	discoveryURL := strings.TrimSuffix(p.cfg.IssuerURL, "/") + oidcDiscoveryPath
+	discovery := OIDCDiscovery{}
+	if err := p.getJSON(ctx, discoveryURL, &discovery); err != nil {
+		if p.discovery != nil {
+			log.Warnf("refreshing OpenID Connect discovery document, using the cached document: %v", err)
+			return *p.discovery, nil
+		}
+		return discovery, fmt.Errorf("getting OpenID Connect discovery document: %v", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != strings.TrimSuffix(p.cfg.IssuerURL, "/") {
+		return discovery, fmt.Errorf("OpenID Connect discovery document issuer '%s' does not match the configured issuer '%s'", discovery.Issuer, p.cfg.IssuerURL)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return discovery, errors.New("OpenID Connect discovery document is missing authorization_endpoint, token_endpoint, or jwks_uri")
+	}
+	p.discovery = &discovery
+	p.discoveredAt = p.now()
+	return discovery, nil
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer log.Close(resp.Body, "closing OpenID Connect provider response body")
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// signingKey returns the provider's public key with the given key ID. The provider's keys are re-fetched if the cache has expired,
+// or if there's no such key and they haven't been fetched recently, since the provider may have rotated its keys.
+func (p *OIDCProvider) signingKey(ctx context.Context, kid string) (interface{}, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.keys == nil || p.now().Sub(p.keysFetchedAt) >= p.refreshInterval() {
+		if err := p.fetchKeys(ctx); err != nil {
+			return nil, err
+		}
+	}
+	key := lookupKey(p.keys, kid)
+	if key == nil && p.now().Sub(p.keysFetchedAt) >= oidcMinKeyRefetchInterval {
+		if err := p.fetchKeys(ctx); err != nil {
+			return nil, err
+		}
+		key = lookupKey(p.keys, kid)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("no OpenID Connect provider signing key with ID '%s'", kid)
+	}
+	return key.Materialize()
+}
+
+// lookupKey returns the key with the given ID in the set or, if the ID is empty, the only key in the set.
+func lookupKey(keys *jwk.Set, kid string) jwk.Key {
+	if kid == "" {
+		if len(keys.Keys) == 1 {
+			return keys.Keys[0]
+		}
+		return nil
+	}
+	if found := keys.LookupKeyID(kid); len(found) > 0 {
+		return found[0]
+	}
+	return nil
+}
+
+// fetchKeys fetches the provider's signing keys. It must be called with the mutex held.
+func (p *OIDCProvider) fetchKeys(ctx context.Context) error {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.JWKSURI, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("getting OpenID Connect provider signing keys: %v", err)
+	}
+	defer log.Close(resp.Body, "closing OpenID Connect provider signing keys response body")
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("getting OpenID Connect provider signing keys: GET %s returned %d", discovery.JWKSURI, resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading OpenID Connect provider signing keys: %v", err)
+	}
+	keys, err := jwk.ParseBytes(body)
+	if err != nil {
+		return fmt.Errorf("parsing OpenID Connect provider signing keys: %v", err)
+	}
+	p.keys = keys
+	p.keysFetchedAt = p.now()
+	return nil
+}
+
+// AuthCodeURL returns the URL of the provider's authorization endpoint to which users are redirected to log in, with the given state and nonce.
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce string) (string, error) {
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("parsing OpenID Connect authorization endpoint: %v", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange exchanges an authorization code for tokens at the provider's token endpoint, per RFC6749§4.1.3, and returns the ID token.
+func (p *OIDCProvider) Exchange(ctx context.Context, code string) (string, error) {
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret)) // per RFC6749§2.3.1
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting OpenID Connect tokens: %v", err)
+	}
+	defer log.Close(resp.Body, "closing OpenID Connect token response body")
+	tokens := struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return "", fmt.Errorf("decoding OpenID Connect token response with status %d: %v", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || tokens.Error != "" {
+		return "", fmt.Errorf("OpenID Connect token endpoint returned %d: %s", resp.StatusCode, tokens.Error)
+	}
+	if tokens.IDToken == "" {
+		return "", errors.New("OpenID Connect token response has no id_token")
+	}
+	return tokens.IDToken, nil
+}
+
+// VerifyIDToken checks the signature and claims of the given ID token, per OpenID Connect Core 1.0 §3.1.3.7, and returns its claims.
+// If nonce isn't empty, the token must have been issued for it.
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawToken string, nonce string) (jwt.MapClaims, error) {
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims := jwt.MapClaims{}
+	parser := jwt.Parser{ValidMethods: oidcSigningMethods, SkipClaimsValidation: true}
+	if _, err := parser.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.signingKey(ctx, kid)
+	}); err != nil {
+		return nil, fmt.Errorf("invalid ID token: %v", err)
+	}
+
+	if iss, _ := claims["iss"].(string); iss != discovery.Issuer {
+		return nil, fmt.Errorf("invalid ID token: issuer '%s' is not '%s'", iss, discovery.Issuer)
+	}
+	audiences := claimStrings(claims["aud"])
+	if !containsString(audiences, p.cfg.ClientID) {
+		return nil, fmt.Errorf("invalid ID token: audience %v does not include '%s'", audiences, p.cfg.ClientID)
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != p.cfg.ClientID {
+		return nil, fmt.Errorf("invalid ID token: authorized party '%s' is not '%s'", azp, p.cfg.ClientID)
+	}
+
+	now := p.now().Unix()
+	skew := int64(p.cfg.ClockSkewSeconds)
+	exp, ok := claimTime(claims["exp"])
+	if !ok {
+		return nil, errors.New("invalid ID token: missing exp")
+	}
+	if now > exp+skew {
+		return nil, errors.New("invalid ID token: expired")
+	}
+	if nbf, ok := claimTime(claims["nbf"]); ok && now < nbf-skew {
+		return nil, errors.New("invalid ID token: not yet valid")
+	}
+	if iat, ok := claimTime(claims["iat"]); ok && iat > now+skew {
+		return nil, errors.New("invalid ID token: issued in the future")
+	}
+	if nonce != "" {
+		if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+			return nil, errors.New("invalid ID token: nonce does not match")
+		}
+	}
+	return claims, nil
+}
+
+// Identity returns the user identified by the given verified ID token claims.
+func (p *OIDCProvider) Identity(claims jwt.MapClaims) (OIDCIdentity, error) {
+	identity := OIDCIdentity{}
+	identity.Issuer, _ = claims["iss"].(string)
+	identity.Subject, _ = claims["sub"].(string)
+	if identity.Issuer == "" || identity.Subject == "" {
+		return identity, errors.New("ID token has no 'iss' or 'sub' claim")
+	}
+	username, _ := claims[p.cfg.UsernameClaim].(string)
+	if username == "" {
+		return identity, fmt.Errorf("ID token has no '%s' claim", p.cfg.UsernameClaim)
+	}
+	identity.Username = username
+	if email, ok := claims["email"].(string); ok && email != "" {
+		identity.Email = &email
+	}
+	if name, ok := claims["name"].(string); ok && name != "" {
+		identity.FullName = &name
+	}
+	identity.Groups = claimStrings(claims[p.cfg.GroupsClaim])
+	if p.cfg.TenantClaim != "" {
+		identity.Tenant, _ = claims[p.cfg.TenantClaim].(string)
+	}
+	return identity, nil
+}
+
+// RoleAndTenant returns the names of the Role and Tenant of the given user: those of the first group mapping which matches one of the
+// user's groups, or else the defaults. The Tenant from the user's tenant claim, if any, takes precedence.
+func (p *OIDCProvider) RoleAndTenant(identity OIDCIdentity) (string, string, error) {
+	role, tenant := "", ""
+	for _, mapping := range p.cfg.GroupMappings {
+		if containsString(identity.Groups, mapping.Group) {
+			role, tenant = mapping.Role, mapping.Tenant
+			break
+		}
+	}
+	if role == "" {
+		role = p.cfg.DefaultRole
+	}
+	if role == "" {
+		return "", "", ErrOIDCNoRole
+	}
+	if identity.Tenant != "" {
+		tenant = identity.Tenant
+	}
+	if tenant == "" {
+		tenant = p.cfg.DefaultTenant
+	}
+	if tenant == "" {
+		return "", "", errors.New("user has no tenant")
+	}
+	return role, tenant, nil
+}
+
+// claimStrings returns a claim which may be a single string or an array of strings as an array of strings.
+func claimStrings(claim interface{}) []string {
+	switch c := claim.(type) {
+	case string:
+		return []string{c}
+	case []interface{}:
+		strs := make([]string, 0, len(c))
+		for _, v := range c {
+			if s, ok := v.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		return strs
+	}
+	return nil
+}
+
+// claimTime returns a NumericDate claim, per RFC7519§2, as Unix seconds.
+func claimTime(claim interface{}) (int64, bool) {
+	switch c := claim.(type) {
+	case float64:
+		return int64(c), true
+	case json.Number:
+		i, err := c.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func containsString(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
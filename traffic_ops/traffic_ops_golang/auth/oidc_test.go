@@ -0,0 +1,234 @@
+package auth
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// mockIdP is a minimal OpenID Connect provider, which serves a discovery document and signing keys, and signs ID tokens.
+type mockIdP struct {
+	*httptest.Server
+	mutex      sync.Mutex
+	kid        string
+	key        *rsa.PrivateKey
+	keyFetches int
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	idp := &mockIdP{}
+	idp.rotateKey(t, "key-1")
+	mux := http.NewServeMux()
+	mux.HandleFunc(oidcDiscoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(OIDCDiscovery{
+			Issuer:                idp.URL,
+			AuthorizationEndpoint: idp.URL + "/authorize",
+			TokenEndpoint:         idp.URL + "/token",
+			JWKSURI:               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mutex.Lock()
+		defer idp.mutex.Unlock()
+		idp.keyFetches++
+		pub := idp.key.PublicKey
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": idp.kid,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}}})
+	})
+	idp.Server = httptest.NewServer(mux)
+	return idp
+}
+
+func (idp *mockIdP) rotateKey(t *testing.T, kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %v", err)
+	}
+	idp.mutex.Lock()
+	defer idp.mutex.Unlock()
+	idp.kid = kid
+	idp.key = key
+}
+
+func (idp *mockIdP) sign(t *testing.T, claims jwt.MapClaims) string {
+	idp.mutex.Lock()
+	defer idp.mutex.Unlock()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = idp.kid
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return signed
+}
+
+func (idp *mockIdP) claims(now time.Time) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":                idp.URL,
+		"aud":                "traffic-ops",
+		"sub":                "1234",
+		"preferred_username": "alice",
+		"email":              "alice@example.test",
+		"groups":             []string{"cdn-readers", "cdn-ops"},
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Minute).Unix(),
+		"nonce":              "n-0S6_WzA2Mj",
+	}
+}
+
+func testOIDCConfig(issuer string) config.ConfigOIDC {
+	cfg := config.ConfigOIDC{
+		IssuerURL:   issuer,
+		ClientID:    "traffic-ops",
+		RedirectURL: "https://to.example.test/api/4.0/user/login/oidc/callback",
+		GroupMappings: []config.OIDCGroupMapping{
+			{Group: "cdn-admins", Role: "admin", Tenant: "root"},
+			{Group: "cdn-ops", Role: "operations"},
+		},
+		DefaultTenant: "root",
+	}
+	if err := config.ValidateOIDC(&cfg); err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	idp := newMockIdP(t)
+	defer idp.Close()
+	now := time.Now()
+	provider := NewOIDCProvider(testOIDCConfig(idp.URL))
+	provider.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	claims, err := provider.VerifyIDToken(ctx, idp.sign(t, idp.claims(now)), "n-0S6_WzA2Mj")
+	if err != nil {
+		t.Fatalf("expected a valid token, got error: %v", err)
+	}
+	identity, err := provider.Identity(claims)
+	if err != nil {
+		t.Fatalf("unexpected error getting identity: %v", err)
+	}
+	if identity.Issuer != idp.URL || identity.Subject != "1234" || identity.Username != "alice" || identity.Email == nil || *identity.Email != "alice@example.test" || len(identity.Groups) != 2 {
+		t.Errorf("expected alice's identity, actual %+v", identity)
+	}
+	if _, err := provider.Identity(jwt.MapClaims{"iss": idp.URL, "preferred_username": "alice"}); err == nil {
+		t.Error("expected an error getting the identity of claims without a subject, got none")
+	}
+
+	invalid := map[string]func(jwt.MapClaims){
+		"wrong issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example.test" },
+		"wrong audience": func(c jwt.MapClaims) { c["aud"] = []string{"other-client"} },
+		"expired":        func(c jwt.MapClaims) { c["exp"] = now.Add(-2 * time.Minute).Unix() },
+		"missing exp":    func(c jwt.MapClaims) { delete(c, "exp") },
+		"wrong nonce":    func(c jwt.MapClaims) { c["nonce"] = "replayed" },
+	}
+	for name, modify := range invalid {
+		c := idp.claims(now)
+		modify(c)
+		if _, err := provider.VerifyIDToken(ctx, idp.sign(t, c), "n-0S6_WzA2Mj"); err == nil {
+			t.Errorf("%s: expected an error, got none", name)
+		}
+	}
+
+	withinSkew := idp.claims(now)
+	withinSkew["exp"] = now.Add(-30 * time.Second).Unix()
+	if _, err := provider.VerifyIDToken(ctx, idp.sign(t, withinSkew), ""); err != nil {
+		t.Errorf("expected a token expired within the allowed clock skew to be valid, got error: %v", err)
+	}
+
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.claims(now)).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("signing HMAC token: %v", err)
+	}
+	if _, err := provider.VerifyIDToken(ctx, hmacToken, ""); err == nil {
+		t.Error("expected a symmetrically signed token to be rejected, got no error")
+	}
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	idp := newMockIdP(t)
+	defer idp.Close()
+	now := time.Now()
+	provider := NewOIDCProvider(testOIDCConfig(idp.URL))
+	provider.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := provider.VerifyIDToken(ctx, idp.sign(t, idp.claims(now)), ""); err != nil {
+		t.Fatalf("expected a valid token, got error: %v", err)
+	}
+
+	idp.rotateKey(t, "key-2")
+	if _, err := provider.VerifyIDToken(ctx, idp.sign(t, idp.claims(now)), ""); err == nil || !strings.Contains(err.Error(), "key-2") {
+		t.Errorf("expected keys not to be re-fetched again immediately, actual error: %v", err)
+	}
+
+	now = now.Add(oidcMinKeyRefetchInterval)
+	if _, err := provider.VerifyIDToken(ctx, idp.sign(t, idp.claims(now)), ""); err != nil {
+		t.Errorf("expected a token signed with a rotated key to be valid, got error: %v", err)
+	}
+	if idp.keyFetches != 2 {
+		t.Errorf("expected keys to be fetched twice, actual %d", idp.keyFetches)
+	}
+}
+
+func TestOIDCRoleAndTenant(t *testing.T) {
+	cfg := testOIDCConfig("https://idp.example.test")
+	provider := NewOIDCProvider(cfg)
+
+	role, tenant, err := provider.RoleAndTenant(OIDCIdentity{Groups: []string{"cdn-ops", "cdn-admins"}})
+	if err != nil || role != "admin" || tenant != "root" {
+		t.Errorf("expected the first matching mapping to be used, actual role '%s', tenant '%s', error: %v", role, tenant, err)
+	}
+	role, tenant, err = provider.RoleAndTenant(OIDCIdentity{Groups: []string{"cdn-ops"}, Tenant: "customer"})
+	if err != nil || role != "operations" || tenant != "customer" {
+		t.Errorf("expected the tenant claim to be used, actual role '%s', tenant '%s', error: %v", role, tenant, err)
+	}
+	if _, _, err := provider.RoleAndTenant(OIDCIdentity{Groups: []string{"marketing"}}); err != ErrOIDCNoRole {
+		t.Errorf("expected ErrOIDCNoRole for an unmapped user, actual: %v", err)
+	}
+
+	cfg.DefaultRole = "read-only"
+	role, tenant, err = NewOIDCProvider(cfg).RoleAndTenant(OIDCIdentity{})
+	if err != nil || role != "read-only" || tenant != "root" {
+		t.Errorf("expected the defaults to be used, actual role '%s', tenant '%s', error: %v", role, tenant, err)
+	}
+}
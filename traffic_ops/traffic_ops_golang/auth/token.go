@@ -0,0 +1,189 @@
+package auth
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// apiTokenLastUsedInterval is how often the last-used time of a personal
+// access token is updated, so that every request made with it doesn't write to
+// the database.
+const apiTokenLastUsedInterval = time.Minute
+
+// ErrTokenRouteNotAllowed is returned when a personal access token is used
+// with a route or method outside its scope.
+var ErrTokenRouteNotAllowed = errors.New("this token is not permitted to use this route")
+
+// ErrTokenExpired is returned when a personal access token is used after it
+// has expired.
+var ErrTokenExpired = errors.New("this token has expired")
+
+// Route identifies the API route and method of a request, so that the scope of
+// a personal access token can be checked against it. It's stored in a
+// request's context under RouteKey.
+type Route struct {
+	ID     int
+	Method string
+}
+
+// TokenScope is the scope of the personal access token with which a user
+// authenticated.
+type TokenScope struct {
+	ID int
+	// Routes are the IDs of the routes the token may be used with; if empty,
+	// it may be used with any route.
+	Routes []int
+	// Methods are the request methods the token may be used with; if empty,
+	// it may be used with any method.
+	Methods []string
+	Expires time.Time
+}
+
+// Allows returns whether the token may be used for a request with the given
+// method to the route with the given ID.
+func (s TokenScope) Allows(method string, routeID int) bool {
+	if len(s.Routes) > 0 {
+		found := false
+		for _, id := range s.Routes {
+			if id == routeID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(s.Methods) == 0 {
+		return true
+	}
+	for _, m := range s.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an error if the token has expired as of now, or may not be
+// used with the given route.
+func (s TokenScope) Check(route Route, now time.Time) error {
+	if !now.Before(s.Expires) {
+		return ErrTokenExpired
+	}
+	if !s.Allows(route.Method, route.ID) {
+		return ErrTokenRouteNotAllowed
+	}
+	return nil
+}
+
+// GenerateAPIToken returns a new random personal access token, and the hash of
+// it which is stored in the database.
+func GenerateAPIToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", errors.New("generating random token: " + err.Error())
+	}
+	token := tc.APITokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return token, HashAPIToken(token), nil
+}
+
+// HashAPIToken returns the hash under which the given personal access token is
+// stored. Tokens have enough entropy that an unsalted hash is sufficient.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetCurrentUserFromToken is like GetCurrentUserFromDB, but identifies the
+// user by the given personal access token, and sets the Token of the returned
+// user to the token's scope. Expired tokens, and the tokens of users with the
+// "disallowed" Role, are rejected. It also updates the time the token was
+// last used.
+func GetCurrentUserFromToken(db *sqlx.DB, token string, timeout time.Duration) (CurrentUser, error, error, int) {
+	qry := `
+SELECT
+  r.priv_level,
+  r.id as role,
+  r.name as role_name,
+  u.id,
+  u.username,
+  COALESCE(u.tenant_id, -1) AS tenant_id,
+  ARRAY(SELECT rc.cap_name FROM role_capability AS rc WHERE rc.role_id=r.id) AS capabilities,
+  t.id,
+  t.routes,
+  t.methods,
+  t.expires
+FROM
+  api_token AS t
+JOIN
+  tm_user AS u ON t.tm_user = u.id
+JOIN
+  role AS r ON u.role = r.id
+WHERE
+  t.token_hash = $1 AND r.name != $2
+`
+	invalid := CurrentUser{"-", -1, PrivLevelInvalid, TenantIDInvalid, -1, "", []string{}, nil}
+	if db == nil {
+		return invalid, nil, errors.New("no db provided to GetCurrentUserFromToken"), http.StatusInternalServerError
+	}
+	dbCtx, dbClose := context.WithTimeout(context.Background(), timeout)
+	defer dbClose()
+
+	user := CurrentUser{}
+	scope := TokenScope{}
+	routes := []int64{}
+	err := db.QueryRowContext(dbCtx, qry, HashAPIToken(token), disallowed).Scan(&user.PrivLevel, &user.Role, &user.RoleName, &user.ID, &user.UserName, &user.TenantID, &user.Capabilities, &scope.ID, pq.Array(&routes), pq.Array(&scope.Methods), &scope.Expires)
+	switch {
+	case err == sql.ErrNoRows:
+		return invalid, errors.New("Invalid token."), nil, http.StatusUnauthorized
+	case err == context.DeadlineExceeded || err == context.Canceled:
+		return invalid, nil, fmt.Errorf("db access timed out: %s number of open connections: %d", err, db.Stats().OpenConnections), http.StatusServiceUnavailable
+	case err != nil:
+		return invalid, nil, errors.New("checking token: " + err.Error()), http.StatusInternalServerError
+	}
+	if !time.Now().Before(scope.Expires) {
+		return invalid, ErrTokenExpired, nil, http.StatusUnauthorized
+	}
+	for _, id := range routes {
+		scope.Routes = append(scope.Routes, int(id))
+	}
+	user.Token = &scope
+
+	if _, err := db.ExecContext(dbCtx, `UPDATE api_token SET last_used = now() WHERE id = $1 AND (last_used IS NULL OR last_used < now() - $2 * interval '1 second')`, scope.ID, apiTokenLastUsedInterval.Seconds()); err != nil {
+		return invalid, nil, fmt.Errorf("updating last use of token #%d: %v", scope.ID, err), http.StatusInternalServerError
+	}
+	return user, nil, nil, http.StatusOK
+}
@@ -0,0 +1,131 @@
+package auth
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+
+	"github.com/jmoiron/sqlx"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestTokenScopeAllows(t *testing.T) {
+	tests := []struct {
+		scope    TokenScope
+		method   string
+		routeID  int
+		expected bool
+	}{
+		{TokenScope{}, "DELETE", 1, true},
+		{TokenScope{Routes: []int{1, 2}}, "DELETE", 2, true},
+		{TokenScope{Routes: []int{1, 2}}, "GET", 3, false},
+		{TokenScope{Methods: []string{"GET"}}, "GET", 3, true},
+		{TokenScope{Methods: []string{"GET"}}, "PUT", 3, false},
+		{TokenScope{Routes: []int{1}, Methods: []string{"GET"}}, "GET", 1, true},
+		{TokenScope{Routes: []int{1}, Methods: []string{"GET"}}, "POST", 1, false},
+		{TokenScope{Routes: []int{1}}, "GET", 0, false},
+	}
+	for _, test := range tests {
+		if actual := test.scope.Allows(test.method, test.routeID); actual != test.expected {
+			t.Errorf("scope %+v allowing %s to route %d: expected %t, actual %t", test.scope, test.method, test.routeID, test.expected, actual)
+		}
+	}
+}
+
+func TestGetCurrentUserTokenScope(t *testing.T) {
+	scope := TokenScope{ID: 1, Routes: []int{1}, Expires: time.Now().Add(time.Hour)}
+	ctx := context.WithValue(context.Background(), CurrentUserKey, CurrentUser{UserName: "user1", Token: &scope})
+
+	if _, err := GetCurrentUser(context.WithValue(ctx, RouteKey, Route{ID: 1, Method: "GET"})); err != nil {
+		t.Errorf("expected a token to be usable with a route in its scope, actual error: %v", err)
+	}
+	if _, err := GetCurrentUser(context.WithValue(ctx, RouteKey, Route{ID: 2, Method: "GET"})); err != ErrTokenRouteNotAllowed {
+		t.Errorf("expected error '%v' using a token with a route outside its scope, actual: %v", ErrTokenRouteNotAllowed, err)
+	}
+	if _, err := GetCurrentUser(ctx); err != ErrTokenRouteNotAllowed {
+		t.Errorf("expected error '%v' using a restricted token with an unknown route, actual: %v", ErrTokenRouteNotAllowed, err)
+	}
+
+	scope.Expires = time.Now().Add(-time.Second)
+	if _, err := GetCurrentUser(context.WithValue(ctx, RouteKey, Route{ID: 1, Method: "GET"})); err != ErrTokenExpired {
+		t.Errorf("expected error '%v' using an expired token, actual: %v", ErrTokenExpired, err)
+	}
+}
+
+func TestGenerateAPIToken(t *testing.T) {
+	token, hash, err := GenerateAPIToken()
+	if err != nil {
+		t.Fatalf("unexpected error generating token: %v", err)
+	}
+	if !strings.HasPrefix(token, tc.APITokenPrefix) {
+		t.Errorf("expected token to start with '%s', actual: %s", tc.APITokenPrefix, token)
+	}
+	if hash != HashAPIToken(token) || hash == token {
+		t.Errorf("expected hash to be the hash of the token, actual: %s", hash)
+	}
+	if other, _, _ := GenerateAPIToken(); other == token {
+		t.Error("expected generated tokens to be unique")
+	}
+}
+
+func TestGetCurrentUserFromToken(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	cols := []string{"priv_level", "role", "role_name", "id", "username", "tenant_id", "capabilities", "id", "routes", "methods", "expires"}
+	rows := sqlmock.NewRows(cols).AddRow(20, 2, "operations", 3, "ci", 1, "{}", 7, "{10,11}", "{GET,POST}", time.Now().Add(time.Hour))
+	mock.ExpectQuery("SELECT").WithArgs(HashAPIToken("good"), disallowed).WillReturnRows(rows)
+	mock.ExpectExec("UPDATE api_token SET last_used").WithArgs(7, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	user, userErr, sysErr, _ := GetCurrentUserFromToken(db, "good", time.Second)
+	if userErr != nil || sysErr != nil {
+		t.Fatalf("unexpected error getting user from token: %v %v", userErr, sysErr)
+	}
+	if user.UserName != "ci" || user.PrivLevel != 20 || user.Token == nil {
+		t.Fatalf("expected user 'ci' with priv level 20 and a token scope, actual: %+v", user)
+	}
+	if len(user.Token.Routes) != 2 || user.Token.Routes[1] != 11 || len(user.Token.Methods) != 2 || user.Token.Methods[1] != "POST" {
+		t.Errorf("expected token scope of routes [10 11] and methods [GET POST], actual: %+v", *user.Token)
+	}
+
+	rows = sqlmock.NewRows(cols).AddRow(20, 2, "operations", 3, "ci", 1, "{}", 7, "{}", "{}", time.Now().Add(-time.Minute))
+	mock.ExpectQuery("SELECT").WithArgs(HashAPIToken("expired"), disallowed).WillReturnRows(rows)
+	if _, userErr, _, _ := GetCurrentUserFromToken(db, "expired", time.Second); userErr != ErrTokenExpired {
+		t.Errorf("expected error '%v' for an expired token, actual: %v", ErrTokenExpired, userErr)
+	}
+
+	mock.ExpectQuery("SELECT").WithArgs(HashAPIToken("unknown"), disallowed).WillReturnRows(sqlmock.NewRows(cols))
+	if _, userErr, _, _ := GetCurrentUserFromToken(db, "unknown", time.Second); userErr == nil {
+		t.Error("expected an error for an unknown token, actual: nil")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet database expectations: %v", err)
+	}
+}
@@ -35,6 +35,7 @@ import (
 	"github.com/apache/trafficcontrol/lib/go-tc/tovalidate"
 	"github.com/apache/trafficcontrol/lib/go-util"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
 
 	"github.com/go-ozzo/ozzo-validation"
@@ -84,6 +85,27 @@ func (cg TOCacheGroup) GetType() string {
 	return "cachegroup"
 }
 
+// CheckCDNLock implements the api.CDNLockable interface. Cache Groups don't
+// belong to any single CDN, so changes to one are prevented by locks on the
+// Cache Group itself - in any CDN - and by locks on every CDN it touches.
+func (cg *TOCacheGroup) CheckCDNLock(user *auth.CurrentUser) (error, error, int) {
+	if cg.ID == nil {
+		return nil, nil, http.StatusOK
+	}
+	name, ok, err := dbhelpers.GetCacheGroupNameFromID(cg.ReqInfo.Tx.Tx, *cg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("getting name of cachegroup #%d: %v", *cg.ID, err), http.StatusInternalServerError
+	}
+	if !ok {
+		return nil, nil, http.StatusOK
+	}
+	cdns, err := dbhelpers.GetCacheGroupCDNs(cg.ReqInfo.Tx.Tx, string(name))
+	if err != nil {
+		return nil, fmt.Errorf("getting cdns of cachegroup '%s': %v", name, err), http.StatusInternalServerError
+	}
+	return dbhelpers.CheckIfCurrentUserCanModifyMultiCDNObject(cg.ReqInfo.Tx.Tx, cdns, tc.CDNLockScopeCacheGroup, string(name), user.UserName)
+}
+
 func (cg *TOCacheGroup) SetID(i int) {
 	cg.ID = &i
 }
@@ -79,25 +79,33 @@ type CapData struct {
 	Capacity    float64
 }
 
-func getMonitorsCapacity(tx *sql.Tx, monitors map[tc.CDNName][]string) (CapacityResp, error) {
+// getMonitorClient returns an HTTP client for requests to Traffic Monitors, which uses the forward proxy in the global MonitorProxyParameter if it exists, and the URI of that proxy (or an empty string if there is none).
+func getMonitorClient(tx *sql.Tx) (*http.Client, string, error) {
 	monitorForwardProxy, monitorForwardProxyExists, err := dbhelpers.GetGlobalParam(tx, MonitorProxyParameter)
 	if err != nil {
-		return CapacityResp{}, errors.New("getting global monitor proxy parameter: " + err.Error())
+		return nil, "", errors.New("getting global monitor proxy parameter: " + err.Error())
 	}
-	client := &http.Client{Timeout: MonitorRequestTimeout}
-	if monitorForwardProxyExists {
-		proxyURI, err := url.Parse(monitorForwardProxy)
-		if err != nil {
-			return CapacityResp{}, errors.New("monitor forward proxy '" + monitorForwardProxy + "' in parameter '" + MonitorProxyParameter + "' not a URI: " + err.Error())
-		}
-		clientTransport := &http.Transport{Proxy: http.ProxyURL(proxyURI)}
-		if proxyURI.Scheme == "https" {
-			// TM does not support HTTP/2 and golang when connecting to https will use HTTP/2 by default causing a conflict
-			// The result will be an unsupported scheme error
-			// Setting TLSNextProto to any empty map will disable using HTTP/2 per https://golang.org/src/net/http/doc.go
-			clientTransport.TLSNextProto = make(map[string]func(authority string, c *tls.Conn) http.RoundTripper)
-		}
-		client = &http.Client{Timeout: MonitorRequestTimeout, Transport: clientTransport}
+	if !monitorForwardProxyExists {
+		return &http.Client{Timeout: MonitorRequestTimeout}, "", nil
+	}
+	proxyURI, err := url.Parse(monitorForwardProxy)
+	if err != nil {
+		return nil, "", errors.New("monitor forward proxy '" + monitorForwardProxy + "' in parameter '" + MonitorProxyParameter + "' not a URI: " + err.Error())
+	}
+	clientTransport := &http.Transport{Proxy: http.ProxyURL(proxyURI)}
+	if proxyURI.Scheme == "https" {
+		// TM does not support HTTP/2 and golang when connecting to https will use HTTP/2 by default causing a conflict
+		// The result will be an unsupported scheme error
+		// Setting TLSNextProto to any empty map will disable using HTTP/2 per https://golang.org/src/net/http/doc.go
+		clientTransport.TLSNextProto = make(map[string]func(authority string, c *tls.Conn) http.RoundTripper)
+	}
+	return &http.Client{Timeout: MonitorRequestTimeout, Transport: clientTransport}, monitorForwardProxy, nil
+}
+
+func getMonitorsCapacity(tx *sql.Tx, monitors map[tc.CDNName][]string) (CapacityResp, error) {
+	client, monitorForwardProxy, err := getMonitorClient(tx)
+	if err != nil {
+		return CapacityResp{}, err
 	}
 
 	thresholds, err := getEdgeProfileHealthThresholdBandwidth(tx)
@@ -141,20 +149,8 @@ func getCapacityData(monitors map[tc.CDNName][]string, thresholds map[string]flo
 				log.Warnln("getCapacity failed to get CRConfig from cdn '" + string(cdn) + " monitor '" + monitorFQDN + "', trying next monitor: " + err.Error())
 				continue
 			}
-			statsToFetch := []string{tc.StatNameKBPS, tc.StatNameMaxKBPS}
-			var monitorEndpoint string
-			if cacheStats, monitorEndpoint, err = monitorhlp.GetCacheStats(monitorFQDN, client, statsToFetch); err != nil {
-				proxyErr := ""
-				if forwardProxyURL != "" {
-					proxyErr = "using http proxy: " + forwardProxyURL + ", "
-				}
-				log.Warnln(proxyErr + "getCapacity failed to get '" + monitorEndpoint + "' from cdn '" + string(cdn) + "', Error: " + err.Error() + ", trying CacheStats")
-				legacyCacheStats, monitorEndpoint, err := monitorhlp.GetLegacyCacheStats(monitorFQDN, client, statsToFetch)
-				if err != nil {
-					log.Warnln(proxyErr + "getCapacity failed to get '" + monitorEndpoint + "' from cdn '" + string(cdn) + "', Error: " + err.Error())
-					continue
-				}
-				cacheStats = monitorhlp.UpgradeLegacyStats(legacyCacheStats)
+			if cacheStats, err = getMonitorCacheStats(monitorFQDN, client, cdn, forwardProxyURL, []string{tc.StatNameKBPS, tc.StatNameMaxKBPS}); err != nil {
+				continue
 			}
 
 			cap = addCapacity(cap, cacheStats, crStates, crConfig, thresholds, tx)
@@ -167,6 +163,25 @@ func getCapacityData(monitors map[tc.CDNName][]string, thresholds map[string]flo
 	return cap, nil
 }
 
+// getMonitorCacheStats fetches the given stats of all caches from the monitor, falling back to the legacy CacheStats endpoint if the monitor doesn't serve the current one.
+func getMonitorCacheStats(monitorFQDN string, client *http.Client, cdn tc.CDNName, forwardProxyURL string, statsToFetch []string) (tc.Stats, error) {
+	cacheStats, monitorEndpoint, err := monitorhlp.GetCacheStats(monitorFQDN, client, statsToFetch)
+	if err == nil {
+		return cacheStats, nil
+	}
+	proxyErr := ""
+	if forwardProxyURL != "" {
+		proxyErr = "using http proxy: " + forwardProxyURL + ", "
+	}
+	log.Warnln(proxyErr + "failed to get '" + monitorEndpoint + "' from cdn '" + string(cdn) + "' monitor '" + monitorFQDN + "', Error: " + err.Error() + ", trying CacheStats")
+	legacyCacheStats, monitorEndpoint, err := monitorhlp.GetLegacyCacheStats(monitorFQDN, client, statsToFetch)
+	if err != nil {
+		log.Warnln(proxyErr + "failed to get '" + monitorEndpoint + "' from cdn '" + string(cdn) + "' monitor '" + monitorFQDN + "', Error: " + err.Error())
+		return tc.Stats{}, errors.New("getting cache stats for CDN '" + string(cdn) + "' monitor '" + monitorFQDN + "': " + err.Error())
+	}
+	return monitorhlp.UpgradeLegacyStats(legacyCacheStats), nil
+}
+
 func addCapacity(cap CapData, cacheStats tc.Stats, crStates tc.CRStates, crConfig tc.CRConfig, thresholds map[string]float64, tx *sql.Tx) CapData {
 	for cacheName, stats := range cacheStats.Caches {
 		cache, ok := crConfig.ContentServers[(cacheName)]
@@ -0,0 +1,486 @@
+package cdn
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/util/monitorhlp"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/influxdata/influxdb/models"
+)
+
+// DefaultCapacityForecastHistoryDays is the number of days of Traffic Stats
+// history from which a capacity forecast is computed, if not requested.
+const DefaultCapacityForecastHistoryDays = 30
+
+// DefaultCapacityForecastHorizonDays is the number of days into the future
+// for which a capacity forecast projects threshold crossings, if not
+// requested.
+const DefaultCapacityForecastHorizonDays = 365
+
+const maxCapacityForecastDays = 3650
+
+// cacheGroupDailyPercentileQuery gets the daily 95th percentile of the summed
+// bandwidth of each Cache Group in a CDN. The inner query totals the
+// per-cache 1 minute bandwidth written by Traffic Stats for each Cache Group.
+const cacheGroupDailyPercentileQuery = `
+SELECT percentile(value, 95) AS p95 FROM (
+	SELECT sum(value) AS value FROM "%s"."monthly"."bandwidth.1min"
+		WHERE cdn = $cdn
+		AND time >= $start
+		AND time < $end
+		GROUP BY time(1m), cachegroup
+)
+	WHERE time >= $start
+	AND time < $end
+	GROUP BY time(1d), cachegroup`
+
+// cacheGroupCapacityQuery gets the total maximum bandwidth of the monitored
+// interfaces of each EDGE and MID tier server in a CDN that can serve traffic.
+const cacheGroupCapacityQuery = `
+SELECT cg.name,
+	(SELECT SUM(i.max_bandwidth)
+		FROM interface AS i
+		WHERE i.server = s.id
+		AND i.monitor
+		AND i.max_bandwidth IS NOT NULL) AS max_bandwidth
+FROM server AS s
+JOIN cachegroup AS cg ON cg.id = s.cachegroup
+JOIN type AS t ON t.id = s.type
+JOIN status AS st ON st.id = s.status
+JOIN cdn AS c ON c.id = s.cdn_id
+WHERE c.name = $1
+AND (t.name LIKE '` + tc.EdgeTypePrefix + `%' OR t.name LIKE '` + tc.MidTypePrefix + `%')
+AND st.name IN ('` + string(tc.CacheStatusOnline) + `', '` + string(tc.CacheStatusReported) + `')
+`
+
+// bandwidthSample is a single daily 95th percentile bandwidth, in kbps.
+type bandwidthSample struct {
+	Time time.Time
+	Kbps float64
+}
+
+// cacheGroupCapacity is the capacity, in kbps, of a Cache Group's servers.
+type cacheGroupCapacity struct {
+	Servers                    int
+	ServersMissingMaxBandwidth int
+	Kbps                       float64
+}
+
+// GetCapacityForecast is the handler for GET requests to
+// /cdns/{name}/capacity/forecast.
+func GetCapacityForecast(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"name"}, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	cdnName := inf.Params["name"]
+	if ok, err := dbhelpers.CDNExists(cdnName, tx); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("checking CDN existence: "+err.Error()))
+		return
+	} else if !ok {
+		api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no such CDN: '%s'", cdnName), nil)
+		return
+	}
+
+	historyDays, userErr := parseForecastDays(inf.Params, "days", DefaultCapacityForecastHistoryDays, 2)
+	if userErr != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, userErr, nil)
+		return
+	}
+	horizonDays, userErr := parseForecastDays(inf.Params, "horizonDays", DefaultCapacityForecastHorizonDays, 1)
+	if userErr != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, userErr, nil)
+		return
+	}
+	thresholds := config.DefaultCapacityForecastThresholds
+	if inf.Config != nil && len(inf.Config.CapacityForecastThresholds) > 0 {
+		thresholds = inf.Config.CapacityForecastThresholds
+	}
+	if thresholdsStr, ok := inf.Params["thresholds"]; ok {
+		if thresholds, userErr = parseForecastThresholds(thresholdsStr); userErr != nil {
+			api.HandleErr(w, r, tx, http.StatusBadRequest, userErr, nil)
+			return
+		}
+	}
+
+	client, err := inf.CreateInfluxClient()
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	} else if client == nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("Traffic Stats is not configured and a capacity forecast was requested"))
+		return
+	}
+	defer (*client).Close()
+
+	now := time.Now().UTC()
+	end := now.Truncate(24 * time.Hour)
+	start := end.AddDate(0, 0, -historyDays)
+
+	history, err := getCacheGroupDailyPercentiles(client, inf.Config.ConfigInflux.CacheDBName, cdnName, start, end)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("getting Cache Group bandwidth history: "+err.Error()))
+		return
+	}
+	capacities, err := getCacheGroupCapacities(tx, cdnName)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("getting Cache Group capacities: "+err.Error()))
+		return
+	}
+	current, err := getCacheGroupCurrentKbps(tx, tc.CDNName(cdnName))
+	if err != nil {
+		log.Warnf("capacity forecast for CDN '%s' will not include current bandwidth: %v", cdnName, err)
+		current = nil
+	}
+
+	forecast := tc.CDNCapacityForecast{
+		CDN:          cdnName,
+		GeneratedAt:  now,
+		HistoryStart: start,
+		HistoryEnd:   end,
+		HorizonDays:  horizonDays,
+		Thresholds:   thresholds,
+		CacheGroups:  forecastCacheGroups(capacities, history, current, thresholds, now, horizonDays),
+	}
+	api.WriteResp(w, r, forecast)
+}
+
+// parseForecastDays parses the positive number of days in the query
+// parameter with the given name, returning def if it isn't given.
+func parseForecastDays(params map[string]string, name string, def int, min int) (int, error) {
+	str, ok := params[name]
+	if !ok {
+		return def, nil
+	}
+	days, err := strconv.Atoi(str)
+	if err != nil || days < min || days > maxCapacityForecastDays {
+		return 0, fmt.Errorf("'%s' must be an integer between %d and %d", name, min, maxCapacityForecastDays)
+	}
+	return days, nil
+}
+
+// parseForecastThresholds parses a comma-separated list of utilization
+// percentages, returning them in ascending order without duplicates.
+func parseForecastThresholds(str string) ([]float64, error) {
+	seen := map[float64]struct{}{}
+	thresholds := []float64{}
+	for _, s := range strings.Split(str, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		pct, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(pct) || pct <= 0 || pct > 100 {
+			return nil, fmt.Errorf("'thresholds' must be a comma-separated list of percentages greater than 0 and at most 100, got '%s'", s)
+		}
+		if _, ok := seen[pct]; ok {
+			continue
+		}
+		seen[pct] = struct{}{}
+		thresholds = append(thresholds, pct)
+	}
+	if len(thresholds) == 0 {
+		return nil, errors.New("'thresholds' must contain at least one percentage")
+	}
+	sort.Float64s(thresholds)
+	return thresholds, nil
+}
+
+// getCacheGroupDailyPercentiles returns the daily 95th percentile bandwidth
+// of each Cache Group in the CDN from Traffic Stats, in chronological order.
+func getCacheGroupDailyPercentiles(client *influx.Client, db string, cdn string, start time.Time, end time.Time) (map[string][]bandwidthSample, error) {
+	q := influx.NewQueryWithParameters(
+		fmt.Sprintf(cacheGroupDailyPercentileQuery, db),
+		db,
+		"rfc3339",
+		map[string]interface{}{
+			"cdn":   cdn,
+			"start": start,
+			"end":   end,
+		},
+	)
+	log.Debugf("InfluxDB capacity forecast query: %+v", q)
+	resp, err := (*client).Query(q)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Error(); err != nil {
+		return nil, err
+	}
+	if len(resp.Results) == 0 {
+		return map[string][]bandwidthSample{}, nil
+	}
+	return parseDailyPercentileSeries(resp.Results[0].Series)
+}
+
+// parseDailyPercentileSeries parses the per-Cache Group series of a daily
+// percentile query. Days with no data are omitted.
+func parseDailyPercentileSeries(series []models.Row) (map[string][]bandwidthSample, error) {
+	history := map[string][]bandwidthSample{}
+	for _, row := range series {
+		cg := row.Tags["cachegroup"]
+		if cg == "" {
+			continue
+		}
+		if len(row.Columns) < 2 {
+			return nil, fmt.Errorf("series for Cache Group '%s' has %d columns, expected 2", cg, len(row.Columns))
+		}
+		for _, vals := range row.Values {
+			if len(vals) < 2 || vals[1] == nil {
+				continue
+			}
+			timeStr, ok := vals[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid type for time of Cache Group '%s' - expected string, got %T", cg, vals[0])
+			}
+			t, err := time.Parse(time.RFC3339, timeStr)
+			if err != nil {
+				return nil, fmt.Errorf("parsing time of Cache Group '%s': %v", cg, err)
+			}
+			var kbps float64
+			switch v := vals[1].(type) {
+			case json.Number:
+				if kbps, err = v.Float64(); err != nil {
+					return nil, fmt.Errorf("parsing bandwidth of Cache Group '%s': %v", cg, err)
+				}
+			case float64:
+				kbps = v
+			default:
+				return nil, fmt.Errorf("invalid type for bandwidth of Cache Group '%s' - expected 'float64' or 'json.Number', got %T", cg, vals[1])
+			}
+			history[cg] = append(history[cg], bandwidthSample{Time: t, Kbps: kbps})
+		}
+		sort.Slice(history[cg], func(i, j int) bool { return history[cg][i].Time.Before(history[cg][j].Time) })
+	}
+	return history, nil
+}
+
+// getCacheGroupCapacities returns the capacity of each Cache Group in the CDN
+// from the maximum bandwidths of its servers' monitored interfaces.
+func getCacheGroupCapacities(tx *sql.Tx, cdn string) (map[string]cacheGroupCapacity, error) {
+	rows, err := tx.Query(cacheGroupCapacityQuery, cdn)
+	if err != nil {
+		return nil, errors.New("querying server interface bandwidths: " + err.Error())
+	}
+	defer log.Close(rows, "closing server interface bandwidth rows")
+
+	capacities := map[string]cacheGroupCapacity{}
+	for rows.Next() {
+		cg := ""
+		maxBandwidth := sql.NullInt64{}
+		if err := rows.Scan(&cg, &maxBandwidth); err != nil {
+			return nil, errors.New("scanning server interface bandwidths: " + err.Error())
+		}
+		c := capacities[cg]
+		c.Servers++
+		if maxBandwidth.Valid {
+			c.Kbps += float64(maxBandwidth.Int64)
+		} else {
+			c.ServersMissingMaxBandwidth++
+		}
+		capacities[cg] = c
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("iterating over server interface bandwidths: " + err.Error())
+	}
+	return capacities, nil
+}
+
+// getCacheGroupCurrentKbps returns the current bandwidth of each Cache Group
+// in the CDN, as reported by the first of its Traffic Monitors to respond.
+func getCacheGroupCurrentKbps(tx *sql.Tx, cdn tc.CDNName) (map[string]float64, error) {
+	monitors, err := getCDNMonitorFQDNs(tx)
+	if err != nil {
+		return nil, errors.New("getting monitors: " + err.Error())
+	}
+	if len(monitors[cdn]) == 0 {
+		return nil, errors.New("no online monitors found")
+	}
+	client, forwardProxyURL, err := getMonitorClient(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, monitorFQDN := range monitors[cdn] {
+		crConfig, err := monitorhlp.GetCRConfig(monitorFQDN, client)
+		if err != nil {
+			log.Warnln("capacity forecast failed to get CRConfig from cdn '" + string(cdn) + "' monitor '" + monitorFQDN + "', trying next monitor: " + err.Error())
+			continue
+		}
+		cacheStats, err := getMonitorCacheStats(monitorFQDN, client, cdn, forwardProxyURL, []string{tc.StatNameKBPS})
+		if err != nil {
+			continue
+		}
+		return sumCacheGroupKbps(cacheStats, crConfig), nil
+	}
+	return nil, errors.New("no monitors responded")
+}
+
+// sumCacheGroupKbps totals the current bandwidth of the caches in each Cache
+// Group.
+func sumCacheGroupKbps(cacheStats tc.Stats, crConfig tc.CRConfig) map[string]float64 {
+	kbps := map[string]float64{}
+	for cacheName, stats := range cacheStats.Caches {
+		cache, ok := crConfig.ContentServers[cacheName]
+		if !ok || cache.CacheGroup == nil {
+			continue
+		}
+		raw, ok := stats.Stats[tc.StatNameKBPS]
+		if !ok || len(raw) < 1 {
+			continue
+		}
+		v, ok := util.ToNumeric(raw[0].Val)
+		if !ok {
+			continue
+		}
+		kbps[*cache.CacheGroup] += v
+	}
+	return kbps
+}
+
+// forecastCacheGroups builds the forecast of every Cache Group which has
+// either capacity or bandwidth history, sorted by name.
+func forecastCacheGroups(capacities map[string]cacheGroupCapacity, history map[string][]bandwidthSample, current map[string]float64, thresholds []float64, now time.Time, horizonDays int) []tc.CacheGroupCapacityForecast {
+	names := make([]string, 0, len(capacities))
+	for name := range capacities {
+		names = append(names, name)
+	}
+	for name := range history {
+		if _, ok := capacities[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	forecasts := make([]tc.CacheGroupCapacityForecast, 0, len(names))
+	for _, name := range names {
+		f := forecastCacheGroup(name, capacities[name], history[name], thresholds, now, horizonDays)
+		if kbps, ok := current[name]; ok {
+			f.CurrentKbps = util.FloatPtr(kbps)
+		}
+		forecasts = append(forecasts, f)
+	}
+	return forecasts
+}
+
+// forecastCacheGroup projects when a Cache Group's daily 95th percentile
+// bandwidth will cross each threshold by fitting a linear trend to its
+// history, which must be in chronological order.
+func forecastCacheGroup(name string, capacity cacheGroupCapacity, history []bandwidthSample, thresholds []float64, now time.Time, horizonDays int) tc.CacheGroupCapacityForecast {
+	f := tc.CacheGroupCapacityForecast{
+		CacheGroup:                 name,
+		Servers:                    capacity.Servers,
+		ServersMissingMaxBandwidth: capacity.ServersMissingMaxBandwidth,
+		CapacityKbps:               capacity.Kbps,
+		Thresholds:                 make([]tc.CapacityThresholdForecast, 0, len(thresholds)),
+	}
+
+	var p95, fitted, slope float64
+	hasTrend := false
+	if len(history) > 0 {
+		last := history[len(history)-1]
+		p95 = last.Kbps
+		f.Percentile95Kbps = util.FloatPtr(p95)
+		f.HeadroomKbps = util.FloatPtr(capacity.Kbps - p95)
+		if capacity.Kbps > 0 {
+			f.UtilizationPercent = util.FloatPtr(p95 * 100 / capacity.Kbps)
+		}
+		var intercept float64
+		if slope, intercept, hasTrend = linearFit(history); hasTrend {
+			f.TrendKbpsPerDay = util.FloatPtr(slope)
+			fitted = intercept + slope*daysSince(history[0].Time, now)
+		}
+	}
+
+	for _, pct := range thresholds {
+		t := tc.CapacityThresholdForecast{
+			Percent:       pct,
+			ThresholdKbps: capacity.Kbps * pct / 100,
+		}
+		if len(history) > 0 {
+			t.HeadroomKbps = util.FloatPtr(t.ThresholdKbps - p95)
+			if capacity.Kbps > 0 && p95 >= t.ThresholdKbps {
+				t.Exceeded = true
+			} else if capacity.Kbps > 0 && hasTrend && slope > 0 {
+				days := int(math.Ceil((t.ThresholdKbps - fitted) / slope))
+				if days < 0 {
+					days = 0
+				}
+				if days <= horizonDays {
+					date := now.AddDate(0, 0, days)
+					t.ProjectedDate = &date
+					t.DaysRemaining = util.IntPtr(days)
+				}
+			}
+		}
+		f.Thresholds = append(f.Thresholds, t)
+	}
+	return f
+}
+
+// linearFit returns the slope, in kbps per day, and intercept of the least
+// squares line through the samples, measured in days from the first sample.
+// It returns false if there are too few distinct days to fit a line.
+func linearFit(samples []bandwidthSample) (float64, float64, bool) {
+	if len(samples) < 2 {
+		return 0, 0, false
+	}
+	n := float64(len(samples))
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range samples {
+		x := daysSince(samples[0].Time, s.Time)
+		sumX += x
+		sumY += s.Kbps
+		sumXY += x * s.Kbps
+		sumXX += x * x
+	}
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0, 0, false
+	}
+	slope := (n*sumXY - sumX*sumY) / denom
+	intercept := (sumY - slope*sumX) / n
+	return slope, intercept, true
+}
+
+func daysSince(start time.Time, t time.Time) float64 {
+	return t.Sub(start).Hours() / 24
+}
@@ -0,0 +1,281 @@
+package cdn
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+
+	"github.com/influxdata/influxdb/models"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestParseForecastThresholds(t *testing.T) {
+	thresholds, err := parseForecastThresholds(" 95,70,85,70,")
+	if err != nil {
+		t.Fatalf("unexpected error parsing thresholds: %v", err)
+	}
+	if expected := []float64{70, 85, 95}; !reflect.DeepEqual(thresholds, expected) {
+		t.Errorf("expected thresholds %v, got %v", expected, thresholds)
+	}
+
+	for _, bad := range []string{"", ",", "0", "101", "-5", "abc", "50,NaN"} {
+		if _, err := parseForecastThresholds(bad); err == nil {
+			t.Errorf("expected an error parsing thresholds '%s', got none", bad)
+		}
+	}
+}
+
+func TestParseForecastDays(t *testing.T) {
+	days, err := parseForecastDays(map[string]string{}, "days", 30, 2)
+	if err != nil || days != 30 {
+		t.Errorf("expected default of 30 days with no error, got %d, %v", days, err)
+	}
+	days, err = parseForecastDays(map[string]string{"days": "14"}, "days", 30, 2)
+	if err != nil || days != 14 {
+		t.Errorf("expected 14 days with no error, got %d, %v", days, err)
+	}
+	for _, bad := range []string{"1", "0", "-3", "ten", "100000"} {
+		if _, err := parseForecastDays(map[string]string{"days": bad}, "days", 30, 2); err == nil {
+			t.Errorf("expected an error parsing days '%s', got none", bad)
+		}
+	}
+}
+
+func TestLinearFit(t *testing.T) {
+	start := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	samples := []bandwidthSample{}
+	for i := 0; i < 10; i++ {
+		samples = append(samples, bandwidthSample{Time: start.AddDate(0, 0, i), Kbps: 1000 + 50*float64(i)})
+	}
+	slope, intercept, ok := linearFit(samples)
+	if !ok {
+		t.Fatal("expected a fit for 10 samples")
+	}
+	if slope != 50 || intercept != 1000 {
+		t.Errorf("expected slope 50 and intercept 1000, got %v and %v", slope, intercept)
+	}
+
+	if _, _, ok := linearFit(samples[:1]); ok {
+		t.Error("expected no fit for a single sample")
+	}
+	if _, _, ok := linearFit([]bandwidthSample{samples[0], samples[0]}); ok {
+		t.Error("expected no fit for samples on the same day")
+	}
+}
+
+func TestForecastCacheGroup(t *testing.T) {
+	start := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	history := []bandwidthSample{}
+	for i := 0; i < 10; i++ {
+		history = append(history, bandwidthSample{Time: start.AddDate(0, 0, i), Kbps: 5000 + 100*float64(i)})
+	}
+	// The trend is at 6000 kbps "now", rising 100 kbps per day.
+	now := start.AddDate(0, 0, 10)
+	capacity := cacheGroupCapacity{Servers: 3, ServersMissingMaxBandwidth: 1, Kbps: 10000}
+
+	f := forecastCacheGroup("cg", capacity, history, []float64{50, 70, 95}, now, 30)
+	if f.CacheGroup != "cg" || f.Servers != 3 || f.ServersMissingMaxBandwidth != 1 || f.CapacityKbps != 10000 {
+		t.Errorf("unexpected Cache Group capacity fields: %+v", f)
+	}
+	if f.Percentile95Kbps == nil || *f.Percentile95Kbps != 5900 {
+		t.Errorf("expected 95th percentile of 5900, got %v", f.Percentile95Kbps)
+	}
+	if f.HeadroomKbps == nil || *f.HeadroomKbps != 4100 {
+		t.Errorf("expected headroom of 4100, got %v", f.HeadroomKbps)
+	}
+	if f.UtilizationPercent == nil || *f.UtilizationPercent != 59 {
+		t.Errorf("expected utilization of 59%%, got %v", f.UtilizationPercent)
+	}
+	if f.TrendKbpsPerDay == nil || *f.TrendKbpsPerDay != 100 {
+		t.Errorf("expected trend of 100 kbps/day, got %v", f.TrendKbpsPerDay)
+	}
+	if len(f.Thresholds) != 3 {
+		t.Fatalf("expected 3 thresholds, got %d", len(f.Thresholds))
+	}
+
+	exceeded := f.Thresholds[0]
+	if !exceeded.Exceeded || exceeded.ProjectedDate != nil || exceeded.ThresholdKbps != 5000 {
+		t.Errorf("expected the 50%% threshold of 5000 kbps to be exceeded with no projection, got %+v", exceeded)
+	}
+
+	projected := f.Thresholds[1]
+	if projected.Exceeded {
+		t.Error("expected the 70% threshold not to be exceeded")
+	}
+	if projected.DaysRemaining == nil || *projected.DaysRemaining != 10 {
+		t.Errorf("expected the 70%% threshold to be crossed in 10 days, got %v", projected.DaysRemaining)
+	}
+	if expected := now.AddDate(0, 0, 10); projected.ProjectedDate == nil || !projected.ProjectedDate.Equal(expected) {
+		t.Errorf("expected the 70%% threshold to be crossed on %v, got %v", expected, projected.ProjectedDate)
+	}
+	if projected.HeadroomKbps == nil || *projected.HeadroomKbps != 1100 {
+		t.Errorf("expected 1100 kbps of headroom below the 70%% threshold, got %v", projected.HeadroomKbps)
+	}
+
+	beyondHorizon := f.Thresholds[2]
+	if beyondHorizon.Exceeded || beyondHorizon.ProjectedDate != nil || beyondHorizon.DaysRemaining != nil {
+		t.Errorf("expected the 95%% threshold to be beyond the 30 day horizon, got %+v", beyondHorizon)
+	}
+}
+
+func TestForecastCacheGroupWithoutHistory(t *testing.T) {
+	f := forecastCacheGroup("cg", cacheGroupCapacity{Servers: 1, Kbps: 1000}, nil, []float64{80}, time.Now(), 365)
+	if f.Percentile95Kbps != nil || f.HeadroomKbps != nil || f.TrendKbpsPerDay != nil || f.UtilizationPercent != nil {
+		t.Errorf("expected no bandwidth fields without history, got %+v", f)
+	}
+	if len(f.Thresholds) != 1 || f.Thresholds[0].ThresholdKbps != 800 || f.Thresholds[0].HeadroomKbps != nil || f.Thresholds[0].Exceeded {
+		t.Errorf("expected an unprojected 800 kbps threshold, got %+v", f.Thresholds)
+	}
+}
+
+func TestForecastCacheGroups(t *testing.T) {
+	capacities := map[string]cacheGroupCapacity{
+		"b": {Servers: 1, Kbps: 100},
+		"a": {Servers: 2, Kbps: 200},
+	}
+	history := map[string][]bandwidthSample{
+		"c": {{Time: time.Now(), Kbps: 10}},
+	}
+	current := map[string]float64{"a": 42}
+
+	forecasts := forecastCacheGroups(capacities, history, current, []float64{80}, time.Now(), 365)
+	if len(forecasts) != 3 {
+		t.Fatalf("expected 3 Cache Group forecasts, got %d", len(forecasts))
+	}
+	for i, name := range []string{"a", "b", "c"} {
+		if forecasts[i].CacheGroup != name {
+			t.Errorf("expected forecast %d to be for Cache Group '%s', got '%s'", i, name, forecasts[i].CacheGroup)
+		}
+	}
+	if forecasts[0].CurrentKbps == nil || *forecasts[0].CurrentKbps != 42 {
+		t.Errorf("expected current bandwidth of 42 for Cache Group 'a', got %v", forecasts[0].CurrentKbps)
+	}
+	if forecasts[1].CurrentKbps != nil {
+		t.Errorf("expected no current bandwidth for Cache Group 'b', got %v", *forecasts[1].CurrentKbps)
+	}
+}
+
+func TestParseDailyPercentileSeries(t *testing.T) {
+	series := []models.Row{
+		{
+			Tags:    map[string]string{"cachegroup": "cg1"},
+			Columns: []string{"time", "p95"},
+			Values: [][]interface{}{
+				{"2021-03-02T00:00:00Z", json.Number("200")},
+				{"2021-03-01T00:00:00Z", json.Number("100.5")},
+				{"2021-03-03T00:00:00Z", nil},
+			},
+		},
+		{
+			Tags:    map[string]string{"cachegroup": "cg2"},
+			Columns: []string{"time", "p95"},
+			Values: [][]interface{}{
+				{"2021-03-01T00:00:00Z", 50.0},
+			},
+		},
+	}
+	history, err := parseDailyPercentileSeries(series)
+	if err != nil {
+		t.Fatalf("unexpected error parsing series: %v", err)
+	}
+	expected := map[string][]bandwidthSample{
+		"cg1": {
+			{Time: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), Kbps: 100.5},
+			{Time: time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC), Kbps: 200},
+		},
+		"cg2": {
+			{Time: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), Kbps: 50},
+		},
+	}
+	if !reflect.DeepEqual(history, expected) {
+		t.Errorf("expected history %+v, got %+v", expected, history)
+	}
+
+	series[1].Values[0][1] = "fifty"
+	if _, err := parseDailyPercentileSeries(series); err == nil {
+		t.Error("expected an error parsing a non-numeric bandwidth")
+	}
+}
+
+func TestGetCacheGroupCapacities(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	rows := sqlmock.NewRows([]string{"name", "max_bandwidth"})
+	rows.AddRow("cg1", 10000)
+	rows.AddRow("cg1", nil)
+	rows.AddRow("cg1", 5000)
+	rows.AddRow("cg2", 20000)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT cg.name").WithArgs("cdn1").WillReturnRows(rows)
+	mock.ExpectCommit()
+
+	tx, err := mockDB.Begin()
+	if err != nil {
+		t.Fatalf("creating transaction: %v", err)
+	}
+	capacities, err := getCacheGroupCapacities(tx, "cdn1")
+	if err != nil {
+		t.Fatalf("unexpected error getting capacities: %v", err)
+	}
+	tx.Commit()
+
+	expected := map[string]cacheGroupCapacity{
+		"cg1": {Servers: 3, ServersMissingMaxBandwidth: 1, Kbps: 15000},
+		"cg2": {Servers: 1, Kbps: 20000},
+	}
+	if !reflect.DeepEqual(capacities, expected) {
+		t.Errorf("expected capacities %+v, got %+v", expected, capacities)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSumCacheGroupKbps(t *testing.T) {
+	cg1 := "cg1"
+	crConfig := tc.CRConfig{
+		ContentServers: map[string]tc.CRConfigTrafficOpsServer{
+			"edge1": {CacheGroup: &cg1},
+			"edge2": {CacheGroup: &cg1},
+			"edge3": {},
+		},
+	}
+	stats := tc.Stats{
+		Caches: map[string]tc.ServerStats{
+			"edge1": {Stats: map[string][]tc.ResultStatVal{tc.StatNameKBPS: {{Val: 10.0}}}},
+			"edge2": {Stats: map[string][]tc.ResultStatVal{tc.StatNameKBPS: {{Val: 5.5}}}},
+			"edge3": {Stats: map[string][]tc.ResultStatVal{tc.StatNameKBPS: {{Val: 100.0}}}},
+			"edge4": {Stats: map[string][]tc.ResultStatVal{tc.StatNameKBPS: {{Val: 100.0}}}},
+		},
+	}
+	if kbps := sumCacheGroupKbps(stats, crConfig); !reflect.DeepEqual(kbps, map[string]float64{"cg1": 15.5}) {
+		t.Errorf("expected 15.5 kbps for cg1 only, got %v", kbps)
+	}
+}
@@ -36,6 +36,7 @@ import (
 	"github.com/apache/trafficcontrol/lib/go-tc"
 	"github.com/apache/trafficcontrol/lib/go-util"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/asyncjob"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/deliveryservice"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/trafficvault"
@@ -48,7 +49,37 @@ const (
 	DNSSECGenerationCPURatio = 0.66
 )
 
+// dnssecGenerateJobType is the type of the asynchronous jobs which generate
+// the DNSSEC keys of a CDN.
+const dnssecGenerateJobType = "dnssec_generate"
+
+// dnssecGenerateJobPayload is the payload of a dnssec_generate job.
+type dnssecGenerateJobPayload struct {
+	CDN               string `json:"cdn"`
+	CDNID             int    `json:"cdnID"`
+	CDNDomain         string `json:"cdnDomain"`
+	TTL               uint64 `json:"ttl"`
+	KSKExpirationDays uint64 `json:"kskExpirationDays"`
+	ZSKExpirationDays uint64 `json:"zskExpirationDays"`
+	EffectiveDateUnix int64  `json:"effectiveDate"`
+}
+
+func init() {
+	asyncjob.Register(dnssecGenerateJobType, runDNSSECGenerateJob)
+}
+
+// CreateDNSSECKeys generates and stores the DNSSEC keys of a CDN.
 func CreateDNSSECKeys(w http.ResponseWriter, r *http.Request) {
+	createDNSSECKeys(w, r, false)
+}
+
+// CreateDNSSECKeysV40 queues an asynchronous job which generates and stores
+// the DNSSEC keys of a CDN.
+func CreateDNSSECKeysV40(w http.ResponseWriter, r *http.Request) {
+	createDNSSECKeys(w, r, true)
+}
+
+func createDNSSECKeys(w http.ResponseWriter, r *http.Request, async bool) {
 	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
 	if userErr != nil || sysErr != nil {
 		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
@@ -90,6 +121,25 @@ func CreateDNSSECKeys(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if async {
+		payload := dnssecGenerateJobPayload{
+			CDN:               cdnName,
+			CDNID:             cdnID,
+			CDNDomain:         cdnDomain,
+			TTL:               uint64(*req.TTL),
+			KSKExpirationDays: uint64(*req.KSKExpirationDays),
+			ZSKExpirationDays: uint64(*req.ZSKExpirationDays),
+			EffectiveDateUnix: int64(*req.EffectiveDateUnix),
+		}
+		status, err := asyncjob.Enqueue(inf.Tx.Tx, dnssecGenerateJobType, inf.User, payload, "Generation of DNSSEC keys for CDN "+cdnName+" queued")
+		if err != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("queueing DNSSEC key generation: "+err.Error()))
+			return
+		}
+		asyncjob.WriteAccepted(w, r, status, "Generation of DNSSEC keys for CDN "+cdnName)
+		return
+	}
+
 	if err := generateStoreDNSSECKeys(inf.Tx.Tx, cdnName, cdnDomain, uint64(*req.TTL), uint64(*req.KSKExpirationDays), uint64(*req.ZSKExpirationDays), int64(*req.EffectiveDateUnix), inf.Vault, r.Context()); err != nil {
 		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("generating and storing DNSSEC CDN keys: "+err.Error()))
 		return
@@ -117,6 +167,24 @@ func CreateDNSSECKeys(w http.ResponseWriter, r *http.Request) {
 	api.WriteResp(w, r, "Successfully created dnssec keys for "+cdnName)
 }
 
+// runDNSSECGenerateJob is the asyncjob.Func which generates and stores the
+// DNSSEC keys of a CDN. Unlike CreateDNSSECKeys, it needs no separate
+// transaction for the changelog, because job transactions have no timeout.
+func runDNSSECGenerateJob(inf *api.APIInfo, job *asyncjob.Job) (string, error, error) {
+	p := dnssecGenerateJobPayload{}
+	if err := job.DecodePayload(&p); err != nil {
+		return "", nil, err
+	}
+	job.SetProgress(10, "Generating DNSSEC keys")
+	if err := generateStoreDNSSECKeys(inf.Tx.Tx, p.CDN, p.CDNDomain, p.TTL, p.KSKExpirationDays, p.ZSKExpirationDays, p.EffectiveDateUnix, inf.Vault, job.Context()); err != nil {
+		return "", nil, errors.New("generating and storing DNSSEC CDN keys: " + err.Error())
+	}
+	api.CreateChangeLogRawTx(api.ApiChange, "CDN: "+p.CDN+", ID: "+strconv.Itoa(p.CDNID)+", ACTION: Generated DNSSEC keys", inf.User, inf.Tx.Tx)
+	message := "Successfully created dnssec keys for " + p.CDN
+	job.SetResult(message)
+	return message, nil, nil
+}
+
 // DefaultDSTTL is the default DS Record TTL to use, if no CDN Snapshot exists, or if no tld.ttls.DS parameter exists.
 // This MUST be the same value as Traffic Router's default. Currently:
 // traffic_router/core/src/main/java/com/comcast/cdn/traffic_control/traffic_router/core/dns/SignatureManager.java:476
@@ -0,0 +1,730 @@
+package cdn
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-rfc"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/deliveryservice"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/parameter"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tenant"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"gopkg.in/yaml.v2"
+)
+
+// ExportFormatYAML is the value of the 'format' query parameter which requests a CDN export as YAML rather than JSON.
+const ExportFormatYAML = "yaml"
+
+// ExportHandler is the handler for GET requests to /cdns/{name}/export.
+// It writes the entire configuration of the CDN as a tc.CDNExport document, as JSON or, if requested with the 'format' query parameter or the Accept header, as YAML.
+// Secure Parameter values are only included for admin users.
+func ExportHandler(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"name"}, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	doc, ok, err := exportCDN(inf.Tx, inf.User, inf.Params["name"], inf.User.PrivLevel >= auth.PrivLevelAdmin)
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("exporting CDN: "+err.Error()))
+		return
+	}
+	if !ok {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusNotFound, errors.New("cdn not found"), nil)
+		return
+	}
+
+	if !wantsYAML(r) {
+		api.WriteRespRaw(w, r, doc)
+		return
+	}
+	bts, err := marshalYAML(doc)
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("marshalling CDN export as YAML: "+err.Error()))
+		return
+	}
+	w.Header().Set(rfc.ContentType, rfc.ApplicationYAML)
+	w.Write(bts)
+}
+
+// wantsYAML returns whether the client asked for a YAML response, either with the 'format' query parameter or the Accept header.
+func wantsYAML(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return strings.EqualFold(format, ExportFormatYAML)
+	}
+	return isYAMLMediaType(r.Header.Get("Accept"))
+}
+
+// isYAMLMediaType returns whether the given Accept or Content-Type header value names a YAML media type.
+func isYAMLMediaType(h string) bool {
+	h = strings.ToLower(h)
+	return strings.Contains(h, "/yaml") || strings.Contains(h, "/x-yaml")
+}
+
+// marshalYAML marshals v as YAML, with the same field names and order as its JSON encoding.
+func marshalYAML(v interface{}) ([]byte, error) {
+	bts, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is valid YAML, and a MapSlice preserves the order of the fields.
+	obj := yaml.MapSlice{}
+	if err := yaml.Unmarshal(bts, &obj); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(obj)
+}
+
+// exportCDN returns the export document of the CDN with the given name, and whether it exists.
+// Only the Delivery Services in the user's tenancy are included. If showSecure is false, the values of secure Parameters are hidden.
+func exportCDN(tx *sqlx.Tx, user *auth.CurrentUser, cdnName string, showSecure bool) (tc.CDNExport, bool, error) {
+	doc := tc.CDNExport{Version: tc.CDNExportVersion}
+	cdnID, ok, err := getExportCDN(tx.Tx, cdnName, &doc.CDN)
+	if err != nil || !ok {
+		return doc, ok, err
+	}
+
+	servers, err := getExportServers(tx.Tx, cdnID, nil)
+	if err != nil {
+		return doc, false, err
+	}
+	dses, err := getExportDeliveryServices(tx, user, cdnID, nil)
+	if err != nil {
+		return doc, false, err
+	}
+	profiles, err := getExportProfiles(tx.Tx, cdnID, nil, showSecure)
+	if err != nil {
+		return doc, false, err
+	}
+
+	topologyNames := map[string]struct{}{}
+	capabilityNames := map[string]struct{}{}
+	cacheGroupNames := map[string]struct{}{}
+	physLocationNames := map[string]struct{}{}
+	for _, ds := range dses {
+		if ds.Topology != nil {
+			topologyNames[*ds.Topology] = struct{}{}
+		}
+		for _, c := range ds.RequiredCapabilities {
+			capabilityNames[c] = struct{}{}
+		}
+		doc.DeliveryServices = append(doc.DeliveryServices, ds.CDNExportDeliveryService)
+	}
+	for _, s := range servers {
+		cacheGroupNames[s.CacheGroup] = struct{}{}
+		physLocationNames[s.PhysLocation] = struct{}{}
+		for _, c := range s.Capabilities {
+			capabilityNames[c] = struct{}{}
+		}
+		doc.Servers = append(doc.Servers, s.CDNExportServer)
+	}
+
+	for _, p := range profiles {
+		doc.Profiles = append(doc.Profiles, p.CDNExportProfile)
+	}
+
+	topologies, err := getExportTopologies(tx.Tx, setToSlice(topologyNames))
+	if err != nil {
+		return doc, false, err
+	}
+	for _, t := range topologies {
+		for _, node := range t.Nodes {
+			cacheGroupNames[node.CacheGroup] = struct{}{}
+		}
+		doc.Topologies = append(doc.Topologies, t)
+	}
+
+	// Cache Groups are added until the set is closed under parents and fallbacks.
+	cacheGroups := map[string]tc.CDNExportCacheGroup{}
+	for pending := setToSlice(cacheGroupNames); len(pending) > 0; {
+		found, err := getExportCacheGroups(tx.Tx, pending)
+		if err != nil {
+			return doc, false, err
+		}
+		pending = nil
+		for name, cg := range found {
+			cacheGroups[name] = cg
+		}
+		for _, cg := range found {
+			refs := append([]string{derefStr(cg.ParentCacheGroup), derefStr(cg.SecondaryParentCacheGroup)}, cg.Fallbacks...)
+			for _, ref := range refs {
+				if _, ok := cacheGroups[ref]; ref != "" && !ok {
+					cacheGroups[ref] = tc.CDNExportCacheGroup{}
+					pending = append(pending, ref)
+				}
+			}
+		}
+	}
+	for _, cg := range cacheGroups {
+		if cg.Name != "" {
+			doc.CacheGroups = append(doc.CacheGroups, cg)
+		}
+	}
+
+	physLocations, err := getExportPhysLocations(tx.Tx, setToSlice(physLocationNames))
+	if err != nil {
+		return doc, false, err
+	}
+	regionNames := map[string]struct{}{}
+	for _, pl := range physLocations {
+		regionNames[pl.Region] = struct{}{}
+		doc.PhysLocations = append(doc.PhysLocations, pl)
+	}
+	regions, err := getExportRegions(tx.Tx, setToSlice(regionNames))
+	if err != nil {
+		return doc, false, err
+	}
+	divisionNames := map[string]struct{}{}
+	for _, r := range regions {
+		divisionNames[r.Division] = struct{}{}
+		doc.Regions = append(doc.Regions, r)
+	}
+	divisions, err := getExportDivisions(tx.Tx, setToSlice(divisionNames))
+	if err != nil {
+		return doc, false, err
+	}
+	for _, d := range divisions {
+		doc.Divisions = append(doc.Divisions, d)
+	}
+	capabilities, err := getExportServerCapabilities(tx.Tx, setToSlice(capabilityNames))
+	if err != nil {
+		return doc, false, err
+	}
+	doc.ServerCapabilities = setToSlice(capabilities)
+
+	sortExport(&doc)
+	return doc, true, nil
+}
+
+// sortExport sorts every list of objects in the document by name, so that exports are stable.
+func sortExport(doc *tc.CDNExport) {
+	sort.Slice(doc.Divisions, func(i, j int) bool { return doc.Divisions[i].Name < doc.Divisions[j].Name })
+	sort.Slice(doc.Regions, func(i, j int) bool { return doc.Regions[i].Name < doc.Regions[j].Name })
+	sort.Slice(doc.PhysLocations, func(i, j int) bool { return doc.PhysLocations[i].Name < doc.PhysLocations[j].Name })
+	sort.Slice(doc.CacheGroups, func(i, j int) bool { return doc.CacheGroups[i].Name < doc.CacheGroups[j].Name })
+	sort.Slice(doc.Profiles, func(i, j int) bool { return doc.Profiles[i].Name < doc.Profiles[j].Name })
+	sort.Strings(doc.ServerCapabilities)
+	sort.Slice(doc.Servers, func(i, j int) bool { return doc.Servers[i].FQDN() < doc.Servers[j].FQDN() })
+	sort.Slice(doc.Topologies, func(i, j int) bool { return doc.Topologies[i].Name < doc.Topologies[j].Name })
+	sort.Slice(doc.DeliveryServices, func(i, j int) bool {
+		return derefStr(doc.DeliveryServices[i].XMLID) < derefStr(doc.DeliveryServices[j].XMLID)
+	})
+}
+
+func setToSlice(set map[string]struct{}) []string {
+	s := make([]string, 0, len(set))
+	for k := range set {
+		s = append(s, k)
+	}
+	sort.Strings(s)
+	return s
+}
+
+func derefStr(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func getExportCDN(tx *sql.Tx, name string, cdn *tc.CDNExportCDN) (int, bool, error) {
+	id := 0
+	err := tx.QueryRow(`SELECT id, name, domain_name, dnssec_enabled FROM cdn WHERE name = $1`, name).Scan(&id, &cdn.Name, &cdn.DomainName, &cdn.DNSSECEnabled)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, errors.New("querying cdn: " + err.Error())
+	}
+	return id, true, nil
+}
+
+func getExportDivisions(tx *sql.Tx, names []string) (map[string]tc.CDNExportDivision, error) {
+	rows, err := tx.Query(`SELECT name FROM division WHERE name = ANY($1)`, pq.Array(names))
+	if err != nil {
+		return nil, errors.New("querying divisions: " + err.Error())
+	}
+	defer log.Close(rows, "closing division rows")
+	divisions := map[string]tc.CDNExportDivision{}
+	for rows.Next() {
+		d := tc.CDNExportDivision{}
+		if err := rows.Scan(&d.Name); err != nil {
+			return nil, errors.New("scanning divisions: " + err.Error())
+		}
+		divisions[d.Name] = d
+	}
+	return divisions, rows.Err()
+}
+
+func getExportRegions(tx *sql.Tx, names []string) (map[string]tc.CDNExportRegion, error) {
+	rows, err := tx.Query(`
+SELECT r.name, d.name
+FROM region r
+JOIN division d ON r.division = d.id
+WHERE r.name = ANY($1)
+`, pq.Array(names))
+	if err != nil {
+		return nil, errors.New("querying regions: " + err.Error())
+	}
+	defer log.Close(rows, "closing region rows")
+	regions := map[string]tc.CDNExportRegion{}
+	for rows.Next() {
+		r := tc.CDNExportRegion{}
+		if err := rows.Scan(&r.Name, &r.Division); err != nil {
+			return nil, errors.New("scanning regions: " + err.Error())
+		}
+		regions[r.Name] = r
+	}
+	return regions, rows.Err()
+}
+
+func getExportPhysLocations(tx *sql.Tx, names []string) (map[string]tc.CDNExportPhysLocation, error) {
+	rows, err := tx.Query(`
+SELECT pl.name, pl.short_name, pl.address, pl.city, pl.state, pl.zip, pl.poc, pl.phone, pl.email, pl.comments, r.name
+FROM phys_location pl
+JOIN region r ON pl.region = r.id
+WHERE pl.name = ANY($1)
+`, pq.Array(names))
+	if err != nil {
+		return nil, errors.New("querying phys locations: " + err.Error())
+	}
+	defer log.Close(rows, "closing phys location rows")
+	physLocations := map[string]tc.CDNExportPhysLocation{}
+	for rows.Next() {
+		pl := tc.CDNExportPhysLocation{}
+		if err := rows.Scan(&pl.Name, &pl.ShortName, &pl.Address, &pl.City, &pl.State, &pl.Zip, &pl.POC, &pl.Phone, &pl.Email, &pl.Comments, &pl.Region); err != nil {
+			return nil, errors.New("scanning phys locations: " + err.Error())
+		}
+		physLocations[pl.Name] = pl
+	}
+	return physLocations, rows.Err()
+}
+
+func getExportCacheGroups(tx *sql.Tx, names []string) (map[string]tc.CDNExportCacheGroup, error) {
+	rows, err := tx.Query(`
+SELECT
+	cg.name,
+	cg.short_name,
+	t.name,
+	co.latitude,
+	co.longitude,
+	p.name,
+	sp.name,
+	COALESCE(cg.fallback_to_closest, TRUE),
+	ARRAY(SELECT b.name FROM cachegroup_fallbacks f JOIN cachegroup b ON f.backup_cg = b.id WHERE f.primary_cg = cg.id ORDER BY f.set_order),
+	ARRAY(SELECT m.method::text FROM cachegroup_localization_method m WHERE m.cachegroup = cg.id ORDER BY m.method)
+FROM cachegroup cg
+JOIN type t ON cg.type = t.id
+LEFT JOIN coordinate co ON cg.coordinate = co.id
+LEFT JOIN cachegroup p ON cg.parent_cachegroup_id = p.id
+LEFT JOIN cachegroup sp ON cg.secondary_parent_cachegroup_id = sp.id
+WHERE cg.name = ANY($1)
+`, pq.Array(names))
+	if err != nil {
+		return nil, errors.New("querying cache groups: " + err.Error())
+	}
+	defer log.Close(rows, "closing cache group rows")
+	cacheGroups := map[string]tc.CDNExportCacheGroup{}
+	for rows.Next() {
+		cg := tc.CDNExportCacheGroup{}
+		if err := rows.Scan(&cg.Name, &cg.ShortName, &cg.Type, &cg.Latitude, &cg.Longitude, &cg.ParentCacheGroup, &cg.SecondaryParentCacheGroup, &cg.FallbackToClosest, pq.Array(&cg.Fallbacks), pq.Array(&cg.LocalizationMethods)); err != nil {
+			return nil, errors.New("scanning cache groups: " + err.Error())
+		}
+		cacheGroups[cg.Name] = cg
+	}
+	return cacheGroups, rows.Err()
+}
+
+// exportProfile is a Profile in an export, along with its ID and the name of its CDN.
+type exportProfile struct {
+	ID  int
+	CDN string
+	tc.CDNExportProfile
+}
+
+// getExportProfiles returns the Profiles of the CDN with the given ID, and the Profiles with the given names, keyed by name.
+func getExportProfiles(tx *sql.Tx, cdnID int, names []string, showSecure bool) (map[string]exportProfile, error) {
+	if names == nil {
+		names = []string{}
+	}
+	rows, err := tx.Query(`
+SELECT p.id, p.name, COALESCE(p.description, ''), p.type::text, p.routing_disabled, c.name
+FROM profile p
+JOIN cdn c ON p.cdn = c.id
+WHERE p.cdn = $1 OR p.name = ANY($2)
+`, cdnID, pq.Array(names))
+	if err != nil {
+		return nil, errors.New("querying profiles: " + err.Error())
+	}
+	defer log.Close(rows, "closing profile rows")
+	profiles := map[string]exportProfile{}
+	names = []string{}
+	ids := []int{}
+	for rows.Next() {
+		p := exportProfile{}
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Type, &p.RoutingDisabled, &p.CDN); err != nil {
+			return nil, errors.New("scanning profiles: " + err.Error())
+		}
+		p.Parameters = []tc.CDNExportParameter{}
+		profiles[p.Name] = p
+		names = append(names, p.Name)
+		ids = append(ids, p.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("iterating profile rows: " + err.Error())
+	}
+
+	paramRows, err := tx.Query(`
+SELECT p.name, pa.name, COALESCE(pa.config_file, ''), pa.value, pa.secure
+FROM profile_parameter pp
+JOIN profile p ON pp.profile = p.id
+JOIN parameter pa ON pp.parameter = pa.id
+WHERE pp.profile = ANY($1)
+ORDER BY pa.config_file, pa.name, pa.value
+`, pq.Array(ids))
+	if err != nil {
+		return nil, errors.New("querying profile parameters: " + err.Error())
+	}
+	defer log.Close(paramRows, "closing profile parameter rows")
+	for paramRows.Next() {
+		profileName := ""
+		param := tc.CDNExportParameter{}
+		if err := paramRows.Scan(&profileName, &param.Name, &param.ConfigFile, &param.Value, &param.Secure); err != nil {
+			return nil, errors.New("scanning profile parameters: " + err.Error())
+		}
+		if param.Secure && !showSecure {
+			param.Value = parameter.HiddenField
+		}
+		p := profiles[profileName]
+		p.Parameters = append(p.Parameters, param)
+		profiles[profileName] = p
+	}
+	return profiles, paramRows.Err()
+}
+
+func getExportServerCapabilities(tx *sql.Tx, names []string) (map[string]struct{}, error) {
+	rows, err := tx.Query(`SELECT name FROM server_capability WHERE name = ANY($1)`, pq.Array(names))
+	if err != nil {
+		return nil, errors.New("querying server capabilities: " + err.Error())
+	}
+	defer log.Close(rows, "closing server capability rows")
+	capabilities := map[string]struct{}{}
+	for rows.Next() {
+		name := ""
+		if err := rows.Scan(&name); err != nil {
+			return nil, errors.New("scanning server capabilities: " + err.Error())
+		}
+		capabilities[name] = struct{}{}
+	}
+	return capabilities, rows.Err()
+}
+
+// exportServer is a server in an export, along with its ID and the name of its CDN.
+type exportServer struct {
+	ID  int
+	CDN string
+	tc.CDNExportServer
+}
+
+// getExportServers returns the servers of the CDN with the given ID, and the servers with the given FQDNs, keyed by FQDN.
+func getExportServers(tx *sql.Tx, cdnID int, fqdns []string) (map[string]exportServer, error) {
+	if fqdns == nil {
+		fqdns = []string{}
+	}
+	rows, err := tx.Query(`
+SELECT
+	s.id,
+	c.name,
+	s.host_name,
+	s.domain_name,
+	cg.name,
+	p.name,
+	t.name,
+	pl.name,
+	st.name,
+	s.offline_reason,
+	s.rack,
+	s.tcp_port,
+	s.https_port,
+	s.ilo_ip_address,
+	s.ilo_ip_gateway,
+	s.ilo_ip_netmask,
+	s.ilo_username,
+	s.mgmt_ip_address,
+	s.mgmt_ip_gateway,
+	s.mgmt_ip_netmask,
+	ARRAY(SELECT ssc.server_capability FROM server_server_capability ssc WHERE ssc.server = s.id ORDER BY ssc.server_capability)
+FROM server s
+JOIN cdn c ON s.cdn_id = c.id
+JOIN cachegroup cg ON s.cachegroup = cg.id
+JOIN profile p ON s.profile = p.id
+JOIN type t ON s.type = t.id
+JOIN phys_location pl ON s.phys_location = pl.id
+JOIN status st ON s.status = st.id
+WHERE s.cdn_id = $1 OR (s.host_name || '.' || s.domain_name) = ANY($2)
+`, cdnID, pq.Array(fqdns))
+	if err != nil {
+		return nil, errors.New("querying servers: " + err.Error())
+	}
+	defer log.Close(rows, "closing server rows")
+	servers := map[string]exportServer{}
+	byID := map[int]string{}
+	ids := []int{}
+	for rows.Next() {
+		s := exportServer{}
+		if err := rows.Scan(&s.ID, &s.CDN, &s.HostName, &s.DomainName, &s.CacheGroup, &s.Profile, &s.Type, &s.PhysLocation, &s.Status, &s.OfflineReason, &s.Rack, &s.TCPPort, &s.HTTPSPort, &s.ILOIPAddress, &s.ILOIPGateway, &s.ILOIPNetmask, &s.ILOUsername, &s.MgmtIPAddress, &s.MgmtIPGateway, &s.MgmtIPNetmask, pq.Array(&s.Capabilities)); err != nil {
+			return nil, errors.New("scanning servers: " + err.Error())
+		}
+		s.Interfaces = []tc.ServerInterfaceInfoV40{}
+		servers[s.FQDN()] = s
+		byID[s.ID] = s.FQDN()
+		ids = append(ids, s.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("iterating server rows: " + err.Error())
+	}
+
+	ifaceRows, err := tx.Query(`
+SELECT server, name, max_bandwidth, monitor, mtu, router_host_name, router_port_name
+FROM interface
+WHERE server = ANY($1)
+ORDER BY server, name
+`, pq.Array(ids))
+	if err != nil {
+		return nil, errors.New("querying server interfaces: " + err.Error())
+	}
+	defer log.Close(ifaceRows, "closing server interface rows")
+	for ifaceRows.Next() {
+		id := 0
+		iface := tc.ServerInterfaceInfoV40{}
+		if err := ifaceRows.Scan(&id, &iface.Name, &iface.MaxBandwidth, &iface.Monitor, &iface.MTU, &iface.RouterHostName, &iface.RouterPortName); err != nil {
+			return nil, errors.New("scanning server interfaces: " + err.Error())
+		}
+		iface.IPAddresses = []tc.ServerIPAddress{}
+		s := servers[byID[id]]
+		s.Interfaces = append(s.Interfaces, iface)
+		servers[byID[id]] = s
+	}
+	if err := ifaceRows.Err(); err != nil {
+		return nil, errors.New("iterating server interface rows: " + err.Error())
+	}
+
+	ipRows, err := tx.Query(`
+SELECT server, interface, address, gateway, service_address
+FROM ip_address
+WHERE server = ANY($1)
+ORDER BY server, interface, address
+`, pq.Array(ids))
+	if err != nil {
+		return nil, errors.New("querying server IP addresses: " + err.Error())
+	}
+	defer log.Close(ipRows, "closing server IP address rows")
+	for ipRows.Next() {
+		id := 0
+		ifaceName := ""
+		ip := tc.ServerIPAddress{}
+		if err := ipRows.Scan(&id, &ifaceName, &ip.Address, &ip.Gateway, &ip.ServiceAddress); err != nil {
+			return nil, errors.New("scanning server IP addresses: " + err.Error())
+		}
+		s := servers[byID[id]]
+		for i := range s.Interfaces {
+			if s.Interfaces[i].Name == ifaceName {
+				s.Interfaces[i].IPAddresses = append(s.Interfaces[i].IPAddresses, ip)
+			}
+		}
+	}
+	return servers, ipRows.Err()
+}
+
+func getExportTopologies(tx *sql.Tx, names []string) (map[string]tc.CDNExportTopology, error) {
+	rows, err := tx.Query(`SELECT name, description FROM topology WHERE name = ANY($1)`, pq.Array(names))
+	if err != nil {
+		return nil, errors.New("querying topologies: " + err.Error())
+	}
+	defer log.Close(rows, "closing topology rows")
+	topologies := map[string]tc.CDNExportTopology{}
+	for rows.Next() {
+		t := tc.CDNExportTopology{Nodes: []tc.CDNExportTopologyNode{}}
+		if err := rows.Scan(&t.Name, &t.Description); err != nil {
+			return nil, errors.New("scanning topologies: " + err.Error())
+		}
+		topologies[t.Name] = t
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("iterating topology rows: " + err.Error())
+	}
+
+	nodeRows, err := tx.Query(`
+SELECT
+	tc.topology,
+	tc.cachegroup,
+	ARRAY(
+		SELECT p.cachegroup
+		FROM topology_cachegroup_parents tcp
+		JOIN topology_cachegroup p ON tcp.parent = p.id
+		WHERE tcp.child = tc.id
+		ORDER BY tcp.rank
+	)
+FROM topology_cachegroup tc
+WHERE tc.topology = ANY($1)
+ORDER BY tc.topology, tc.cachegroup
+`, pq.Array(names))
+	if err != nil {
+		return nil, errors.New("querying topology nodes: " + err.Error())
+	}
+	defer log.Close(nodeRows, "closing topology node rows")
+	for nodeRows.Next() {
+		topology := ""
+		node := tc.CDNExportTopologyNode{}
+		if err := nodeRows.Scan(&topology, &node.CacheGroup, pq.Array(&node.Parents)); err != nil {
+			return nil, errors.New("scanning topology nodes: " + err.Error())
+		}
+		t := topologies[topology]
+		t.Nodes = append(t.Nodes, node)
+		topologies[topology] = t
+	}
+	return topologies, nodeRows.Err()
+}
+
+// exportDeliveryService is a Delivery Service in an export, along with the fields removed from the document which an import needs.
+type exportDeliveryService struct {
+	ID            int
+	CDN           string
+	SSLKeyVersion *int
+	tc.CDNExportDeliveryService
+}
+
+// getExportDeliveryServices returns the Delivery Services of the CDN with the given ID, and the Delivery Services with the given XMLIDs, keyed by XMLID.
+// Only Delivery Services in the user's tenancy are returned.
+func getExportDeliveryServices(tx *sqlx.Tx, user *auth.CurrentUser, cdnID int, xmlIDs []string) (map[string]exportDeliveryService, error) {
+	if xmlIDs == nil {
+		xmlIDs = []string{}
+	}
+	tenantIDs, err := tenant.GetUserTenantIDListTx(tx.Tx, user.TenantID)
+	if err != nil {
+		return nil, errors.New("getting user tenants: " + err.Error())
+	}
+	where := dbhelpers.BaseWhere + " (ds.cdn_id = :cdn_id OR ds.xml_id = ANY(CAST(:xml_ids AS text[])))"
+	where, queryValues := dbhelpers.AddTenancyCheck(where, map[string]interface{}{"cdn_id": cdnID, "xml_ids": pq.Array(xmlIDs)}, "ds.tenant_id", tenantIDs)
+	dses, userErr, sysErr, _ := deliveryservice.GetDeliveryServices(deliveryservice.SelectDeliveryServicesQuery+where, queryValues, tx)
+	if sysErr != nil {
+		return nil, errors.New("getting delivery services: " + sysErr.Error())
+	}
+	if userErr != nil {
+		return nil, errors.New("getting delivery services: " + userErr.Error())
+	}
+
+	exported := make(map[string]exportDeliveryService, len(dses))
+	byID := make(map[int]string, len(dses))
+	ids := make([]int, 0, len(dses))
+	for _, ds := range dses {
+		if ds.ID == nil || ds.XMLID == nil {
+			continue
+		}
+		e := exportDeliveryService{
+			ID:            *ds.ID,
+			CDN:           derefStr(ds.CDNName),
+			SSLKeyVersion: ds.SSLKeyVersion,
+			CDNExportDeliveryService: tc.CDNExportDeliveryService{
+				DeliveryServiceV4:    ds,
+				Regexes:              []tc.CDNExportRegex{},
+				RequiredCapabilities: []string{},
+				SteeringTargets:      []tc.CDNExportSteeringTarget{},
+			},
+		}
+		stripDeliveryService(&e.DeliveryServiceV4)
+		exported[*ds.XMLID] = e
+		byID[*ds.ID] = *ds.XMLID
+		ids = append(ids, *ds.ID)
+	}
+
+	regexes, err := deliveryservice.GetRegexes(tx.Tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	capabilities, err := deliveryservice.GetRequiredCapabilities(tx.Tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for id, xmlID := range byID {
+		e := exported[xmlID]
+		for _, re := range regexes[id] {
+			e.Regexes = append(e.Regexes, tc.CDNExportRegex{Type: re.Type, Pattern: re.Pattern, SetNumber: re.SetNumber})
+		}
+		if caps, ok := capabilities[id]; ok {
+			e.RequiredCapabilities = caps
+		}
+		exported[xmlID] = e
+	}
+
+	targetRows, err := tx.Tx.Query(`
+SELECT st.deliveryservice, ds.xml_id, t.name, st.value
+FROM steering_target st
+JOIN deliveryservice ds ON st.target = ds.id
+JOIN type t ON st.type = t.id
+WHERE st.deliveryservice = ANY($1)
+ORDER BY ds.xml_id
+`, pq.Array(ids))
+	if err != nil {
+		return nil, errors.New("querying steering targets: " + err.Error())
+	}
+	defer log.Close(targetRows, "closing steering target rows")
+	for targetRows.Next() {
+		id := 0
+		target := tc.CDNExportSteeringTarget{}
+		if err := targetRows.Scan(&id, &target.Target, &target.Type, &target.Value); err != nil {
+			return nil, errors.New("scanning steering targets: " + err.Error())
+		}
+		e := exported[byID[id]]
+		e.SteeringTargets = append(e.SteeringTargets, target)
+		exported[byID[id]] = e
+	}
+	return exported, targetRows.Err()
+}
+
+// stripDeliveryService removes the fields of a Delivery Service which are generated by Traffic Ops, or which refer to other objects by ID, and so aren't part of an export.
+func stripDeliveryService(ds *tc.DeliveryServiceV4) {
+	ds.ID = nil
+	ds.CDNID = nil
+	ds.CDNName = nil
+	ds.ExampleURLs = nil
+	ds.LastUpdated = nil
+	ds.MatchList = nil
+	ds.ProfileDesc = nil
+	ds.ProfileID = nil
+	ds.SSLKeyVersion = nil
+	ds.TenantID = nil
+	ds.TypeID = nil
+}
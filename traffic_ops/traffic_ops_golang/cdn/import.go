@@ -0,0 +1,1200 @@
+package cdn
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/apache/trafficcontrol/lib/go-rfc"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/cachegroup"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/deliveryservice"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/parameter"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/server"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/topology"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"gopkg.in/yaml.v2"
+)
+
+// The kinds of objects in a tc.CDNImportChange.
+const (
+	importKindCDN              = "cdn"
+	importKindDivision         = "division"
+	importKindRegion           = "region"
+	importKindPhysLocation     = "physLocation"
+	importKindServerCapability = "serverCapability"
+	importKindCacheGroup       = "cacheGroup"
+	importKindProfile          = "profile"
+	importKindTopology         = "topology"
+	importKindServer           = "server"
+	importKindDeliveryService  = "deliveryService"
+)
+
+// ImportHandler is the handler for POST requests to /cdns/import.
+// The request body is a tc.CDNExport document, as JSON or, if the Content-Type is a YAML media type, as YAML. The CDN it describes is created or updated to match it, in a single transaction.
+// If the 'dryRun' query parameter is true, nothing is changed, and the response is the plan of what would have been.
+func ImportHandler(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	dryRun := false
+	if dryRunStr, ok := inf.Params["dryRun"]; ok {
+		var err error
+		if dryRun, err = strconv.ParseBool(dryRunStr); err != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, errors.New("dryRun must be a boolean"), nil)
+			return
+		}
+	}
+
+	doc, err := parseImport(r.Body, isYAMLMediaType(r.Header.Get(rfc.ContentType)))
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, errors.New("parsing CDN import document: "+err.Error()), nil)
+		return
+	}
+	if err := doc.Validate(inf.Tx.Tx); err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	cur, err := getImportState(inf.Tx, inf.User, &doc)
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("getting current state of imported objects: "+err.Error()))
+		return
+	}
+	if cur.CDN != nil {
+		if userErr, sysErr, errCode := dbhelpers.CheckIfCurrentUserCanModifyCDN(inf.Tx.Tx, doc.CDN.Name, inf.User.UserName); userErr != nil || sysErr != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+			return
+		}
+	}
+
+	plan, err := planImport(&doc, cur)
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, err, nil)
+		return
+	}
+	plan.DryRun = dryRun
+	if dryRun {
+		api.WriteRespAlertObj(w, r, tc.SuccessLevel, fmt.Sprintf("Dry run: importing CDN '%s' would make %d creates, %d updates, and %d deletes", doc.CDN.Name, len(plan.Creates), len(plan.Updates), len(plan.Deletes)), plan)
+		return
+	}
+
+	imp := &importer{r: r, inf: inf, tx: inf.Tx.Tx, doc: &doc, cur: cur, created: newChangeSet(plan.Creates), updated: newChangeSet(plan.Updates)}
+	if userErr, sysErr, errCode := imp.apply(plan.Deletes); userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+
+	msg := fmt.Sprintf("CDN: %s, ID: %d, ACTION: Imported CDN configuration with %d creates, %d updates, and %d deletes", doc.CDN.Name, imp.cdnID, len(plan.Creates), len(plan.Updates), len(plan.Deletes))
+	api.CreateChangeLogRawTx(api.ApiChange, msg, inf.User, inf.Tx.Tx)
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, fmt.Sprintf("CDN '%s' imported with %d creates, %d updates, and %d deletes", doc.CDN.Name, len(plan.Creates), len(plan.Updates), len(plan.Deletes)), plan)
+}
+
+// parseImport decodes an import document from r, as YAML if isYAML is true, and as JSON otherwise.
+func parseImport(r io.Reader, isYAML bool) (tc.CDNExport, error) {
+	doc := tc.CDNExport{}
+	bts, err := ioutil.ReadAll(r)
+	if err != nil {
+		return doc, errors.New("reading body: " + err.Error())
+	}
+	if isYAML {
+		if bts, err = yamlToJSON(bts); err != nil {
+			return doc, err
+		}
+	}
+	if err := json.Unmarshal(bts, &doc); err != nil {
+		return doc, err
+	}
+	return doc, nil
+}
+
+// yamlToJSON converts a YAML document to JSON, so that it can be decoded into types with JSON field names.
+func yamlToJSON(bts []byte) ([]byte, error) {
+	var obj interface{}
+	if err := yaml.Unmarshal(bts, &obj); err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonCompatible(obj))
+}
+
+// jsonCompatible replaces the map[interface{}]interface{} values the YAML decoder produces with map[string]interface{}, which can be marshalled as JSON.
+func jsonCompatible(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[fmt.Sprint(key)] = jsonCompatible(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = jsonCompatible(val)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// importState is the current state in Traffic Ops of the objects in an import document, and of the objects belonging to the CDN it describes.
+type importState struct {
+	CDN              *tc.CDNExportCDN
+	CDNID            int
+	Divisions        map[string]tc.CDNExportDivision
+	Regions          map[string]tc.CDNExportRegion
+	PhysLocations    map[string]tc.CDNExportPhysLocation
+	CacheGroups      map[string]tc.CDNExportCacheGroup
+	Profiles         map[string]exportProfile
+	Capabilities     map[string]struct{}
+	Servers          map[string]exportServer
+	Topologies       map[string]tc.CDNExportTopology
+	DeliveryServices map[string]exportDeliveryService
+	// Types, Statuses, and Tenants are the names of the existing Types, Statuses, and Tenants the document refers to.
+	Types    map[string]struct{}
+	Statuses map[string]struct{}
+	Tenants  map[string]struct{}
+}
+
+func getImportState(tx *sqlx.Tx, user *auth.CurrentUser, doc *tc.CDNExport) (*importState, error) {
+	cur := &importState{}
+	cdn := tc.CDNExportCDN{}
+	cdnID, ok, err := getExportCDN(tx.Tx, doc.CDN.Name, &cdn)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		cur.CDN = &cdn
+		cur.CDNID = cdnID
+	}
+
+	divisions, regions, physLocations, cacheGroups, profiles, servers, topologies, dses := []string{}, []string{}, []string{}, []string{}, []string{}, []string{}, []string{}, []string{}
+	types, statuses, tenants := map[string]struct{}{}, map[string]struct{}{}, map[string]struct{}{}
+	for _, d := range doc.Divisions {
+		divisions = append(divisions, d.Name)
+	}
+	for _, r := range doc.Regions {
+		regions = append(regions, r.Name)
+	}
+	for _, pl := range doc.PhysLocations {
+		physLocations = append(physLocations, pl.Name)
+	}
+	for _, cg := range doc.CacheGroups {
+		cacheGroups = append(cacheGroups, cg.Name)
+		types[cg.Type] = struct{}{}
+	}
+	for _, p := range doc.Profiles {
+		profiles = append(profiles, p.Name)
+	}
+	for _, s := range doc.Servers {
+		servers = append(servers, s.FQDN())
+		types[s.Type] = struct{}{}
+		statuses[s.Status] = struct{}{}
+	}
+	for _, t := range doc.Topologies {
+		topologies = append(topologies, t.Name)
+	}
+	for _, ds := range doc.DeliveryServices {
+		dses = append(dses, derefStr(ds.XMLID))
+		types[string(*ds.Type)] = struct{}{}
+		tenants[*ds.Tenant] = struct{}{}
+		if ds.ProfileName != nil {
+			profiles = append(profiles, *ds.ProfileName)
+		}
+		for _, re := range ds.Regexes {
+			types[re.Type] = struct{}{}
+		}
+		for _, st := range ds.SteeringTargets {
+			types[st.Type] = struct{}{}
+		}
+	}
+
+	if cur.Divisions, err = getExportDivisions(tx.Tx, divisions); err != nil {
+		return nil, err
+	}
+	if cur.Regions, err = getExportRegions(tx.Tx, regions); err != nil {
+		return nil, err
+	}
+	if cur.PhysLocations, err = getExportPhysLocations(tx.Tx, physLocations); err != nil {
+		return nil, err
+	}
+	if cur.CacheGroups, err = getExportCacheGroups(tx.Tx, cacheGroups); err != nil {
+		return nil, err
+	}
+	if cur.Profiles, err = getExportProfiles(tx.Tx, cur.CDNID, profiles, true); err != nil {
+		return nil, err
+	}
+	if cur.Capabilities, err = getExportServerCapabilities(tx.Tx, doc.ServerCapabilities); err != nil {
+		return nil, err
+	}
+	if cur.Servers, err = getExportServers(tx.Tx, cur.CDNID, servers); err != nil {
+		return nil, err
+	}
+	if cur.Topologies, err = getExportTopologies(tx.Tx, topologies); err != nil {
+		return nil, err
+	}
+	if cur.DeliveryServices, err = getExportDeliveryServices(tx, user, cur.CDNID, dses); err != nil {
+		return nil, err
+	}
+	if cur.Types, err = getExistingNames(tx.Tx, "type", setToSlice(types)); err != nil {
+		return nil, err
+	}
+	if cur.Statuses, err = getExistingNames(tx.Tx, "status", setToSlice(statuses)); err != nil {
+		return nil, err
+	}
+	if cur.Tenants, err = getExistingNames(tx.Tx, "tenant", setToSlice(tenants)); err != nil {
+		return nil, err
+	}
+	return cur, nil
+}
+
+// getExistingNames returns which of the given names exist in the name column of the given table.
+func getExistingNames(tx *sql.Tx, table string, names []string) (map[string]struct{}, error) {
+	rows, err := tx.Query(`SELECT name FROM `+table+` WHERE name = ANY($1)`, pq.Array(names))
+	if err != nil {
+		return nil, errors.New("querying " + table + " names: " + err.Error())
+	}
+	defer rows.Close()
+	existing := map[string]struct{}{}
+	for rows.Next() {
+		name := ""
+		if err := rows.Scan(&name); err != nil {
+			return nil, errors.New("scanning " + table + " names: " + err.Error())
+		}
+		existing[name] = struct{}{}
+	}
+	return existing, rows.Err()
+}
+
+// getIDs returns the IDs of the rows of the given table with the given names, keyed by name.
+func getIDs(tx *sql.Tx, table string, names []string) (map[string]int, error) {
+	rows, err := tx.Query(`SELECT name, id FROM `+table+` WHERE name = ANY($1)`, pq.Array(names))
+	if err != nil {
+		return nil, errors.New("querying " + table + " IDs: " + err.Error())
+	}
+	defer rows.Close()
+	ids := map[string]int{}
+	for rows.Next() {
+		name := ""
+		id := 0
+		if err := rows.Scan(&name, &id); err != nil {
+			return nil, errors.New("scanning " + table + " IDs: " + err.Error())
+		}
+		ids[name] = id
+	}
+	return ids, rows.Err()
+}
+
+// sameJSON returns whether a and b have the same JSON encoding, which is how objects in a document are compared to their current state.
+func sameJSON(a, b interface{}) bool {
+	aBts, aErr := json.Marshal(a)
+	bBts, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && bytes.Equal(aBts, bBts)
+}
+
+// planImport returns the changes importing doc would make, given the current state of its objects.
+// It returns an error describing every problem which would make the import fail, such as references to Types which don't exist, or objects which already belong to another CDN.
+func planImport(doc *tc.CDNExport, cur *importState) (tc.CDNImportPlan, error) {
+	plan := tc.CDNImportPlan{Creates: []tc.CDNImportChange{}, Updates: []tc.CDNImportChange{}, Deletes: []tc.CDNImportChange{}}
+	errs := []error{}
+	add := func(kind, name string, exists, same bool) {
+		change := tc.CDNImportChange{Type: kind, Name: name}
+		if !exists {
+			plan.Creates = append(plan.Creates, change)
+		} else if !same {
+			plan.Updates = append(plan.Updates, change)
+		}
+	}
+	requireExisting := func(kind, name, field, value string, existing map[string]struct{}) {
+		if _, ok := existing[value]; !ok {
+			errs = append(errs, fmt.Errorf("%s '%s': %s '%s' does not exist", kind, name, field, value))
+		}
+	}
+	cdnName := doc.CDN.Name
+
+	add(importKindCDN, cdnName, cur.CDN != nil, cur.CDN != nil && *cur.CDN == doc.CDN)
+	for _, d := range doc.Divisions {
+		_, ok := cur.Divisions[d.Name]
+		add(importKindDivision, d.Name, ok, true)
+	}
+	for _, r := range doc.Regions {
+		existing, ok := cur.Regions[r.Name]
+		add(importKindRegion, r.Name, ok, existing == r)
+	}
+	for _, pl := range doc.PhysLocations {
+		existing, ok := cur.PhysLocations[pl.Name]
+		add(importKindPhysLocation, pl.Name, ok, sameJSON(existing, pl))
+	}
+	for _, c := range doc.ServerCapabilities {
+		_, ok := cur.Capabilities[c]
+		add(importKindServerCapability, c, ok, true)
+	}
+	for _, cg := range doc.CacheGroups {
+		requireExisting(importKindCacheGroup, cg.Name, "type", cg.Type, cur.Types)
+		existing, ok := cur.CacheGroups[cg.Name]
+		add(importKindCacheGroup, cg.Name, ok, sameJSON(existing, cg))
+	}
+	docProfiles := map[string]struct{}{}
+	for _, p := range doc.Profiles {
+		docProfiles[p.Name] = struct{}{}
+		for _, param := range p.Parameters {
+			if param.Secure && param.Value == parameter.HiddenField {
+				errs = append(errs, fmt.Errorf("profile '%s': parameter '%s' is secure, but its value is hidden; secure parameter values are only exported for admin users", p.Name, param.Name))
+			}
+		}
+		existing, ok := cur.Profiles[p.Name]
+		if ok && existing.CDN != cdnName {
+			errs = append(errs, fmt.Errorf("profile '%s' already exists in CDN '%s'", p.Name, existing.CDN))
+		}
+		add(importKindProfile, p.Name, ok, sameJSON(existing.CDNExportProfile, p))
+	}
+	for _, t := range doc.Topologies {
+		existing, ok := cur.Topologies[t.Name]
+		add(importKindTopology, t.Name, ok, sameJSON(existing, t))
+	}
+	docServers := map[string]struct{}{}
+	for _, s := range doc.Servers {
+		fqdn := s.FQDN()
+		docServers[fqdn] = struct{}{}
+		requireExisting(importKindServer, fqdn, "type", s.Type, cur.Types)
+		requireExisting(importKindServer, fqdn, "status", s.Status, cur.Statuses)
+		existing, ok := cur.Servers[fqdn]
+		if ok && existing.CDN != cdnName {
+			errs = append(errs, fmt.Errorf("server '%s' already exists in CDN '%s'", fqdn, existing.CDN))
+		}
+		add(importKindServer, fqdn, ok, sameJSON(existing.CDNExportServer, s))
+	}
+	docDSes := map[string]struct{}{}
+	dsProfiles := map[string]struct{}{}
+	for _, ds := range doc.DeliveryServices {
+		xmlID := derefStr(ds.XMLID)
+		docDSes[xmlID] = struct{}{}
+		requireExisting(importKindDeliveryService, xmlID, "type", string(*ds.Type), cur.Types)
+		requireExisting(importKindDeliveryService, xmlID, "tenant", *ds.Tenant, cur.Tenants)
+		for _, re := range ds.Regexes {
+			requireExisting(importKindDeliveryService, xmlID, "regex type", re.Type, cur.Types)
+		}
+		for _, st := range ds.SteeringTargets {
+			requireExisting(importKindDeliveryService, xmlID, "steering target type", st.Type, cur.Types)
+		}
+		if ds.ProfileName != nil {
+			dsProfiles[*ds.ProfileName] = struct{}{}
+			_, inDoc := docProfiles[*ds.ProfileName]
+			if _, exists := cur.Profiles[*ds.ProfileName]; !inDoc && !exists {
+				errs = append(errs, fmt.Errorf("deliveryService '%s': profile '%s' does not exist", xmlID, *ds.ProfileName))
+			}
+		}
+		existing, ok := cur.DeliveryServices[xmlID]
+		if ok && existing.CDN != cdnName {
+			errs = append(errs, fmt.Errorf("delivery service '%s' already exists in CDN '%s'", xmlID, existing.CDN))
+		}
+		stripped := ds
+		stripDeliveryService(&stripped.DeliveryServiceV4)
+		add(importKindDeliveryService, xmlID, ok, sameJSON(existing.CDNExportDeliveryService, stripped))
+	}
+
+	// Only objects which belong to the CDN are deleted; objects which may be shared between CDNs, like Cache Groups, are not.
+	deletes := func(kind string, existing []string, inDoc map[string]struct{}) {
+		sort.Strings(existing)
+		for _, name := range existing {
+			if _, ok := inDoc[name]; !ok {
+				plan.Deletes = append(plan.Deletes, tc.CDNImportChange{Type: kind, Name: name})
+			}
+		}
+	}
+	existingDSes := []string{}
+	for xmlID, ds := range cur.DeliveryServices {
+		if ds.CDN == cdnName {
+			existingDSes = append(existingDSes, xmlID)
+		}
+	}
+	deletes(importKindDeliveryService, existingDSes, docDSes)
+	existingServers := []string{}
+	for fqdn, s := range cur.Servers {
+		if s.CDN == cdnName {
+			existingServers = append(existingServers, fqdn)
+		}
+	}
+	deletes(importKindServer, existingServers, docServers)
+	existingProfiles := []string{}
+	for name, p := range cur.Profiles {
+		if _, used := dsProfiles[name]; p.CDN == cdnName && !used {
+			existingProfiles = append(existingProfiles, name)
+		}
+	}
+	deletes(importKindProfile, existingProfiles, docProfiles)
+
+	return plan, util.JoinErrs(errs)
+}
+
+// changeSet is a set of the objects in a list of tc.CDNImportChanges.
+type changeSet map[tc.CDNImportChange]struct{}
+
+func newChangeSet(changes []tc.CDNImportChange) changeSet {
+	set := make(changeSet, len(changes))
+	for _, change := range changes {
+		set[change] = struct{}{}
+	}
+	return set
+}
+
+func (s changeSet) has(kind, name string) bool {
+	_, ok := s[tc.CDNImportChange{Type: kind, Name: name}]
+	return ok
+}
+
+// importer applies an import document to Traffic Ops.
+type importer struct {
+	r       *http.Request
+	inf     *api.APIInfo
+	tx      *sql.Tx
+	doc     *tc.CDNExport
+	cur     *importState
+	created changeSet
+	updated changeSet
+	cdnID   int
+}
+
+// changed returns whether the object of the given kind and name is created or updated by the import.
+func (imp *importer) changed(kind, name string) bool {
+	return imp.created.has(kind, name) || imp.updated.has(kind, name)
+}
+
+// importDBErr returns the user and system errors and status code of a database error encountered while importing the given object.
+func importDBErr(err error, kind, name string) (error, error, int) {
+	userErr, sysErr, errCode := api.ParseDBError(err)
+	if userErr != nil {
+		userErr = fmt.Errorf("%s '%s': %s", kind, name, userErr.Error())
+	}
+	if sysErr != nil {
+		sysErr = fmt.Errorf("importing %s '%s': %s", kind, name, sysErr.Error())
+	}
+	return userErr, sysErr, errCode
+}
+
+// importValidationErr returns the error of an object of the import which is invalid by the rules of its endpoints.
+func importValidationErr(err error, kind, name string) (error, error, int) {
+	return fmt.Errorf("%s '%s': %s", kind, name, err.Error()), nil, http.StatusBadRequest
+}
+
+// apply makes the planned changes. The order matters, since objects must exist before they can be referred to, and must not be referred to when they are deleted.
+func (imp *importer) apply(deletes []tc.CDNImportChange) (error, error, int) {
+	steps := []func() (error, error, int){
+		imp.applyCDN,
+		imp.applyDivisions,
+		imp.applyRegions,
+		imp.applyPhysLocations,
+		imp.applyServerCapabilities,
+		imp.applyCacheGroups,
+		imp.applyProfiles,
+		imp.applyTopologies,
+		func() (error, error, int) { return imp.applyDeletes(deletes, importKindDeliveryService) },
+		func() (error, error, int) { return imp.applyDeletes(deletes, importKindServer) },
+		imp.applyServers,
+		imp.validateTopologies,
+		func() (error, error, int) { return imp.applyDeletes(deletes, importKindProfile) },
+		imp.applyDeliveryServices,
+	}
+	for _, step := range steps {
+		if userErr, sysErr, errCode := step(); userErr != nil || sysErr != nil {
+			return userErr, sysErr, errCode
+		}
+	}
+	return nil, nil, http.StatusOK
+}
+
+func (imp *importer) applyCDN() (error, error, int) {
+	cdn := imp.doc.CDN
+	imp.cdnID = imp.cur.CDNID
+	var err error
+	if imp.created.has(importKindCDN, cdn.Name) {
+		err = imp.tx.QueryRow(`INSERT INTO cdn (name, domain_name, dnssec_enabled) VALUES ($1, $2, $3) RETURNING id`, cdn.Name, cdn.DomainName, cdn.DNSSECEnabled).Scan(&imp.cdnID)
+	} else if imp.updated.has(importKindCDN, cdn.Name) {
+		_, err = imp.tx.Exec(`UPDATE cdn SET domain_name = $2, dnssec_enabled = $3 WHERE id = $1`, imp.cdnID, cdn.DomainName, cdn.DNSSECEnabled)
+	}
+	if err != nil {
+		return importDBErr(err, importKindCDN, cdn.Name)
+	}
+	return nil, nil, http.StatusOK
+}
+
+func (imp *importer) applyDivisions() (error, error, int) {
+	for _, d := range imp.doc.Divisions {
+		if !imp.created.has(importKindDivision, d.Name) {
+			continue
+		}
+		if _, err := imp.tx.Exec(`INSERT INTO division (name) VALUES ($1)`, d.Name); err != nil {
+			return importDBErr(err, importKindDivision, d.Name)
+		}
+	}
+	return nil, nil, http.StatusOK
+}
+
+func (imp *importer) applyRegions() (error, error, int) {
+	for _, r := range imp.doc.Regions {
+		var err error
+		if imp.created.has(importKindRegion, r.Name) {
+			_, err = imp.tx.Exec(`INSERT INTO region (name, division) VALUES ($1, (SELECT id FROM division WHERE name = $2))`, r.Name, r.Division)
+		} else if imp.updated.has(importKindRegion, r.Name) {
+			_, err = imp.tx.Exec(`UPDATE region SET division = (SELECT id FROM division WHERE name = $2) WHERE name = $1`, r.Name, r.Division)
+		}
+		if err != nil {
+			return importDBErr(err, importKindRegion, r.Name)
+		}
+	}
+	return nil, nil, http.StatusOK
+}
+
+func (imp *importer) applyPhysLocations() (error, error, int) {
+	for _, pl := range imp.doc.PhysLocations {
+		var err error
+		if imp.created.has(importKindPhysLocation, pl.Name) {
+			_, err = imp.tx.Exec(`
+INSERT INTO phys_location (name, short_name, address, city, state, zip, poc, phone, email, comments, region)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, (SELECT id FROM region WHERE name = $11))
+`, pl.Name, pl.ShortName, pl.Address, pl.City, pl.State, pl.Zip, pl.POC, pl.Phone, pl.Email, pl.Comments, pl.Region)
+		} else if imp.updated.has(importKindPhysLocation, pl.Name) {
+			_, err = imp.tx.Exec(`
+UPDATE phys_location SET
+	short_name = $2,
+	address = $3,
+	city = $4,
+	state = $5,
+	zip = $6,
+	poc = $7,
+	phone = $8,
+	email = $9,
+	comments = $10,
+	region = (SELECT id FROM region WHERE name = $11)
+WHERE name = $1
+`, pl.Name, pl.ShortName, pl.Address, pl.City, pl.State, pl.Zip, pl.POC, pl.Phone, pl.Email, pl.Comments, pl.Region)
+		}
+		if err != nil {
+			return importDBErr(err, importKindPhysLocation, pl.Name)
+		}
+	}
+	return nil, nil, http.StatusOK
+}
+
+func (imp *importer) applyServerCapabilities() (error, error, int) {
+	for _, c := range imp.doc.ServerCapabilities {
+		if !imp.created.has(importKindServerCapability, c) {
+			continue
+		}
+		if _, err := imp.tx.Exec(`INSERT INTO server_capability (name) VALUES ($1)`, c); err != nil {
+			return importDBErr(err, importKindServerCapability, c)
+		}
+	}
+	return nil, nil, http.StatusOK
+}
+
+// applyCacheGroups creates and updates Cache Groups in two passes: the first creates them, and the second sets their references to each other, which may be in any order.
+// They're then validated with the same rules as the Cache Group endpoints, once every reference can be resolved.
+func (imp *importer) applyCacheGroups() (error, error, int) {
+	if userErr, sysErr, errCode := imp.validateCacheGroupTypes(); userErr != nil || sysErr != nil {
+		return userErr, sysErr, errCode
+	}
+
+	for _, cg := range imp.doc.CacheGroups {
+		var err error
+		if imp.created.has(importKindCacheGroup, cg.Name) {
+			_, err = imp.tx.Exec(`INSERT INTO cachegroup (name, short_name, type, fallback_to_closest) VALUES ($1, $2, (SELECT id FROM type WHERE name = $3), $4)`, cg.Name, cg.ShortName, cg.Type, cg.FallbackToClosest)
+		} else if imp.updated.has(importKindCacheGroup, cg.Name) {
+			_, err = imp.tx.Exec(`UPDATE cachegroup SET short_name = $2, type = (SELECT id FROM type WHERE name = $3), fallback_to_closest = $4 WHERE name = $1`, cg.Name, cg.ShortName, cg.Type, cg.FallbackToClosest)
+		} else {
+			continue
+		}
+		if err == nil {
+			err = setCacheGroupCoordinate(imp.tx, cg.Name, cg.Latitude, cg.Longitude)
+		}
+		if err != nil {
+			return importDBErr(err, importKindCacheGroup, cg.Name)
+		}
+	}
+
+	for _, cg := range imp.doc.CacheGroups {
+		if !imp.changed(importKindCacheGroup, cg.Name) {
+			continue
+		}
+		if err := setCacheGroupReferences(imp.tx, cg); err != nil {
+			return importDBErr(err, importKindCacheGroup, cg.Name)
+		}
+	}
+
+	names := []string{}
+	for _, cg := range imp.doc.CacheGroups {
+		if imp.changed(importKindCacheGroup, cg.Name) {
+			names = append(names, cg.Name)
+		}
+	}
+	cacheGroups, userErr, sysErr, errCode := cachegroup.GetCacheGroupsByName(names, imp.inf.Tx)
+	if userErr != nil || sysErr != nil {
+		return userErr, sysErr, errCode
+	}
+	for _, name := range names {
+		toCG := cachegroup.TOCacheGroup{APIInfoImpl: api.APIInfoImpl{ReqInfo: imp.inf}, CacheGroupNullable: cacheGroups[name]}
+		if err := toCG.Validate(); err != nil {
+			return importValidationErr(err, importKindCacheGroup, name)
+		}
+	}
+	return nil, nil, http.StatusOK
+}
+
+// validateCacheGroupTypes checks that updated Cache Groups which are used by Topologies keep their Types, as the Cache Group endpoints do.
+// Unlike the rest of their validation, this must happen before they're updated.
+func (imp *importer) validateCacheGroupTypes() (error, error, int) {
+	names, typeNames := []string{}, []string{}
+	for _, cg := range imp.doc.CacheGroups {
+		if imp.updated.has(importKindCacheGroup, cg.Name) {
+			names = append(names, cg.Name)
+			typeNames = append(typeNames, cg.Type)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil, http.StatusOK
+	}
+	ids, err := getIDs(imp.tx, "cachegroup", names)
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	typeIDs, err := getIDs(imp.tx, "type", typeNames)
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	for i, name := range names {
+		toCG := cachegroup.TOCacheGroup{APIInfoImpl: api.APIInfoImpl{ReqInfo: imp.inf}}
+		toCG.ID = util.IntPtr(ids[name])
+		toCG.Name = util.StrPtr(name)
+		toCG.TypeID = util.IntPtr(typeIDs[typeNames[i]])
+		if err := toCG.ValidateTypeInTopology(); err != nil {
+			return importValidationErr(err, importKindCacheGroup, name)
+		}
+	}
+	return nil, nil, http.StatusOK
+}
+
+// setCacheGroupCoordinate creates, updates, or removes the Coordinate of the named Cache Group, as the Cache Group endpoints do.
+func setCacheGroupCoordinate(tx *sql.Tx, name string, latitude, longitude *float64) error {
+	var coordinateID *int
+	if err := tx.QueryRow(`SELECT coordinate FROM cachegroup WHERE name = $1`, name).Scan(&coordinateID); err != nil {
+		return err
+	}
+	switch {
+	case latitude == nil || longitude == nil:
+		if coordinateID == nil {
+			return nil
+		}
+		if _, err := tx.Exec(`UPDATE cachegroup SET coordinate = NULL WHERE name = $1`, name); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM coordinate WHERE id = $1`, *coordinateID)
+		return err
+	case coordinateID == nil:
+		_, err := tx.Exec(`
+WITH co AS (INSERT INTO coordinate (name, latitude, longitude) VALUES ($2, $3, $4) RETURNING id)
+UPDATE cachegroup SET coordinate = (SELECT id FROM co) WHERE name = $1
+`, name, tc.CachegroupCoordinateNamePrefix+name, *latitude, *longitude)
+		return err
+	default:
+		_, err := tx.Exec(`UPDATE coordinate SET latitude = $2, longitude = $3 WHERE id = $1`, *coordinateID, *latitude, *longitude)
+		return err
+	}
+}
+
+// setCacheGroupReferences sets the parents, fallbacks, and localization methods of a Cache Group.
+func setCacheGroupReferences(tx *sql.Tx, cg tc.CDNExportCacheGroup) error {
+	id := 0
+	if err := tx.QueryRow(`
+UPDATE cachegroup SET
+	parent_cachegroup_id = (SELECT id FROM cachegroup WHERE name = $2),
+	secondary_parent_cachegroup_id = (SELECT id FROM cachegroup WHERE name = $3)
+WHERE name = $1
+RETURNING id
+`, cg.Name, cg.ParentCacheGroup, cg.SecondaryParentCacheGroup).Scan(&id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM cachegroup_fallbacks WHERE primary_cg = $1`, id); err != nil {
+		return err
+	}
+	for i, fallback := range cg.Fallbacks {
+		if _, err := tx.Exec(`INSERT INTO cachegroup_fallbacks (primary_cg, backup_cg, set_order) VALUES ($1, (SELECT id FROM cachegroup WHERE name = $2), $3)`, id, fallback, i); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM cachegroup_localization_method WHERE cachegroup = $1`, id); err != nil {
+		return err
+	}
+	for _, method := range cg.LocalizationMethods {
+		if _, err := tx.Exec(`INSERT INTO cachegroup_localization_method (cachegroup, method) VALUES ($1, $2)`, id, tc.LocalizationMethodFromString(method).String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *importer) applyProfiles() (error, error, int) {
+	for _, p := range imp.doc.Profiles {
+		id := 0
+		var err error
+		if imp.created.has(importKindProfile, p.Name) {
+			err = imp.tx.QueryRow(`INSERT INTO profile (name, description, type, routing_disabled, cdn) VALUES ($1, $2, $3, $4, $5) RETURNING id`, p.Name, p.Description, p.Type, p.RoutingDisabled, imp.cdnID).Scan(&id)
+		} else if imp.updated.has(importKindProfile, p.Name) {
+			err = imp.tx.QueryRow(`UPDATE profile SET description = $2, type = $3, routing_disabled = $4, cdn = $5 WHERE name = $1 RETURNING id`, p.Name, p.Description, p.Type, p.RoutingDisabled, imp.cdnID).Scan(&id)
+		} else {
+			continue
+		}
+		if err == nil {
+			err = setProfileParameters(imp.tx, id, p.Parameters)
+		}
+		if err != nil {
+			return importDBErr(err, importKindProfile, p.Name)
+		}
+	}
+	return nil, nil, http.StatusOK
+}
+
+// setProfileParameters replaces the Parameters of the Profile with the given ID. Parameters are shared between Profiles, so existing Parameters are reused where possible.
+func setProfileParameters(tx *sql.Tx, profileID int, params []tc.CDNExportParameter) error {
+	if _, err := tx.Exec(`DELETE FROM profile_parameter WHERE profile = $1`, profileID); err != nil {
+		return err
+	}
+	for _, param := range params {
+		paramID := 0
+		err := tx.QueryRow(`SELECT id FROM parameter WHERE name = $1 AND COALESCE(config_file, '') = $2 AND value = $3`, param.Name, param.ConfigFile, param.Value).Scan(&paramID)
+		if err == sql.ErrNoRows {
+			err = tx.QueryRow(`INSERT INTO parameter (name, config_file, value, secure) VALUES ($1, $2, $3, $4) RETURNING id`, param.Name, param.ConfigFile, param.Value, param.Secure).Scan(&paramID)
+		} else if err == nil {
+			_, err = tx.Exec(`UPDATE parameter SET secure = $2 WHERE id = $1 AND secure <> $2`, paramID, param.Secure)
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO profile_parameter (profile, parameter) VALUES ($1, $2) ON CONFLICT DO NOTHING`, profileID, paramID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *importer) applyTopologies() (error, error, int) {
+	for _, t := range imp.doc.Topologies {
+		var err error
+		if imp.created.has(importKindTopology, t.Name) {
+			_, err = imp.tx.Exec(`INSERT INTO topology (name, description) VALUES ($1, $2)`, t.Name, t.Description)
+		} else if imp.updated.has(importKindTopology, t.Name) {
+			_, err = imp.tx.Exec(`UPDATE topology SET description = $2 WHERE name = $1`, t.Name, t.Description)
+		} else {
+			continue
+		}
+		if err == nil {
+			err = setTopologyNodes(imp.tx, t)
+		}
+		if err != nil {
+			return importDBErr(err, importKindTopology, t.Name)
+		}
+	}
+	return nil, nil, http.StatusOK
+}
+
+// validateTopologies validates the created and updated Topologies with the same rules as the Topology endpoints, including for cycles
+// within and across Topologies. It's called once every Topology, Cache Group, and server has been written, since whether a Topology is
+// valid depends on all of them.
+func (imp *importer) validateTopologies() (error, error, int) {
+	for _, t := range imp.doc.Topologies {
+		if !imp.changed(importKindTopology, t.Name) {
+			continue
+		}
+		inf := *imp.inf
+		inf.Params = map[string]string{"name": t.Name}
+		toTopology := topology.TOTopology{APIInfoImpl: api.APIInfoImpl{ReqInfo: &inf}, Topology: topologyFromExport(t)}
+		if err := toTopology.Validate(); err != nil {
+			return importValidationErr(err, importKindTopology, t.Name)
+		}
+	}
+	return nil, nil, http.StatusOK
+}
+
+// topologyFromExport returns the Topology of a Topology in a CDNExport, whose node parents are referenced by index rather than by Cache Group name.
+// A parent which isn't a node of the Topology is given the index -1, which is invalid.
+func topologyFromExport(t tc.CDNExportTopology) tc.Topology {
+	indexes := make(map[string]int, len(t.Nodes))
+	for i, node := range t.Nodes {
+		indexes[node.CacheGroup] = i
+	}
+	top := tc.Topology{Name: t.Name, Description: t.Description, Nodes: make([]tc.TopologyNode, 0, len(t.Nodes))}
+	for _, node := range t.Nodes {
+		parents := make([]int, 0, len(node.Parents))
+		for _, parent := range node.Parents {
+			index, ok := indexes[parent]
+			if !ok {
+				index = -1
+			}
+			parents = append(parents, index)
+		}
+		top.Nodes = append(top.Nodes, tc.TopologyNode{Cachegroup: node.CacheGroup, Parents: parents})
+	}
+	return top
+}
+
+// setTopologyNodes replaces the nodes of a Topology.
+func setTopologyNodes(tx *sql.Tx, t tc.CDNExportTopology) error {
+	if _, err := tx.Exec(`DELETE FROM topology_cachegroup WHERE topology = $1`, t.Name); err != nil {
+		return err
+	}
+	nodeIDs := make(map[string]int, len(t.Nodes))
+	for _, node := range t.Nodes {
+		id := 0
+		if err := tx.QueryRow(`INSERT INTO topology_cachegroup (topology, cachegroup) VALUES ($1, $2) RETURNING id`, t.Name, node.CacheGroup).Scan(&id); err != nil {
+			return err
+		}
+		nodeIDs[node.CacheGroup] = id
+	}
+	for _, node := range t.Nodes {
+		for i, parent := range node.Parents {
+			parentID, ok := nodeIDs[parent]
+			if !ok {
+				return fmt.Errorf("parent '%s' of node '%s' is not a node of the topology", parent, node.CacheGroup)
+			}
+			if _, err := tx.Exec(`INSERT INTO topology_cachegroup_parents (child, parent, rank) VALUES ($1, $2, $3)`, nodeIDs[node.CacheGroup], parentID, i+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyDeletes deletes the objects of the given kind in the plan's deletes.
+func (imp *importer) applyDeletes(deletes []tc.CDNImportChange, kind string) (error, error, int) {
+	for _, change := range deletes {
+		if change.Type != kind {
+			continue
+		}
+		var err error
+		switch kind {
+		case importKindDeliveryService:
+			ds := imp.cur.DeliveryServices[change.Name]
+			toDS := &deliveryservice.TODeliveryService{APIInfoImpl: api.APIInfoImpl{ReqInfo: imp.inf}}
+			toDS.ID = util.IntPtr(ds.ID)
+			if userErr, sysErr, errCode := toDS.Delete(); userErr != nil || sysErr != nil {
+				return userErr, sysErr, errCode
+			}
+			err = api.CreateChangeLogRawErr(api.ApiChange, fmt.Sprintf("DS: %s, ID: %d, ACTION: Deleted delivery service", change.Name, ds.ID), imp.inf.User, imp.tx)
+		case importKindServer:
+			err = deleteServer(imp.tx, imp.cur.Servers[change.Name].ID)
+		case importKindProfile:
+			_, err = imp.tx.Exec(`DELETE FROM profile WHERE id = $1`, imp.cur.Profiles[change.Name].ID)
+		}
+		if err != nil {
+			return importDBErr(err, kind, change.Name)
+		}
+	}
+	return nil, nil, http.StatusOK
+}
+
+func deleteServer(tx *sql.Tx, id int) error {
+	if _, err := tx.Exec(`DELETE FROM ip_address WHERE server = $1`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM interface WHERE server = $1`, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM server WHERE id = $1`, id)
+	return err
+}
+
+const insertServerQuery = `
+INSERT INTO server (
+	host_name,
+	domain_name,
+	cdn_id,
+	cachegroup,
+	profile,
+	type,
+	phys_location,
+	status,
+	offline_reason,
+	rack,
+	tcp_port,
+	https_port,
+	ilo_ip_address,
+	ilo_ip_gateway,
+	ilo_ip_netmask,
+	ilo_username,
+	mgmt_ip_address,
+	mgmt_ip_gateway,
+	mgmt_ip_netmask,
+	xmpp_id
+) VALUES (
+	$1, $2, $3,
+	(SELECT id FROM cachegroup WHERE name = $4),
+	(SELECT id FROM profile WHERE name = $5),
+	(SELECT id FROM type WHERE name = $6),
+	(SELECT id FROM phys_location WHERE name = $7),
+	(SELECT id FROM status WHERE name = $8),
+	$9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20
+) RETURNING id
+`
+
+const updateServerQuery = `
+UPDATE server SET
+	host_name = $1,
+	domain_name = $2,
+	cdn_id = $3,
+	cachegroup = (SELECT id FROM cachegroup WHERE name = $4),
+	profile = (SELECT id FROM profile WHERE name = $5),
+	type = (SELECT id FROM type WHERE name = $6),
+	phys_location = (SELECT id FROM phys_location WHERE name = $7),
+	status = (SELECT id FROM status WHERE name = $8),
+	offline_reason = $9,
+	rack = $10,
+	tcp_port = $11,
+	https_port = $12,
+	ilo_ip_address = $13,
+	ilo_ip_gateway = $14,
+	ilo_ip_netmask = $15,
+	ilo_username = $16,
+	mgmt_ip_address = $17,
+	mgmt_ip_gateway = $18,
+	mgmt_ip_netmask = $19
+WHERE id = $20
+RETURNING id
+`
+
+// applyServers creates and updates servers, each of which is first validated with the same rules as the server endpoints.
+func (imp *importer) applyServers() (error, error, int) {
+	names := map[string][]string{}
+	for _, s := range imp.doc.Servers {
+		names["cachegroup"] = append(names["cachegroup"], s.CacheGroup)
+		names["profile"] = append(names["profile"], s.Profile)
+		names["type"] = append(names["type"], s.Type)
+		names["phys_location"] = append(names["phys_location"], s.PhysLocation)
+		names["status"] = append(names["status"], s.Status)
+	}
+	ids := map[string]map[string]int{}
+	for table, tableNames := range names {
+		tableIDs, err := getIDs(imp.tx, table, tableNames)
+		if err != nil {
+			return nil, err, http.StatusInternalServerError
+		}
+		ids[table] = tableIDs
+	}
+
+	for _, s := range imp.doc.Servers {
+		fqdn := s.FQDN()
+		if !imp.changed(importKindServer, fqdn) {
+			continue
+		}
+		validated := serverFromExport(s, imp.cdnID, ids)
+		if imp.updated.has(importKindServer, fqdn) {
+			validated.ID = util.IntPtr(imp.cur.Servers[fqdn].ID)
+		}
+		if userErr, sysErr, errCode := server.ValidateV4(&validated, imp.inf.Tx); userErr != nil || sysErr != nil {
+			if userErr != nil {
+				userErr = fmt.Errorf("%s '%s': %s", importKindServer, fqdn, userErr.Error())
+			}
+			return userErr, sysErr, errCode
+		}
+
+		args := []interface{}{s.HostName, s.DomainName, imp.cdnID, s.CacheGroup, s.Profile, s.Type, s.PhysLocation, s.Status, s.OfflineReason, s.Rack, s.TCPPort, s.HTTPSPort, s.ILOIPAddress, s.ILOIPGateway, s.ILOIPNetmask, s.ILOUsername, s.MgmtIPAddress, s.MgmtIPGateway, s.MgmtIPNetmask}
+		id := 0
+		var err error
+		if imp.created.has(importKindServer, fqdn) {
+			err = imp.tx.QueryRow(insertServerQuery, append(args, uuid.New().String())...).Scan(&id)
+		} else {
+			err = imp.tx.QueryRow(updateServerQuery, append(args, imp.cur.Servers[fqdn].ID)...).Scan(&id)
+		}
+		if err == nil {
+			err = setServerInterfaces(imp.tx, id, s.Interfaces)
+		}
+		if err == nil {
+			err = setServerCapabilities(imp.tx, id, s.Capabilities)
+		}
+		if err != nil {
+			return importDBErr(err, importKindServer, fqdn)
+		}
+	}
+	return nil, nil, http.StatusOK
+}
+
+// serverFromExport returns the server of a server in a CDNExport, with the IDs of the objects it refers to, by table and name, for validation.
+func serverFromExport(s tc.CDNExportServer, cdnID int, ids map[string]map[string]int) tc.ServerV40 {
+	srv := tc.ServerV40{Interfaces: s.Interfaces}
+	srv.HostName = util.StrPtr(s.HostName)
+	srv.DomainName = util.StrPtr(s.DomainName)
+	srv.CDNID = util.IntPtr(cdnID)
+	srv.CachegroupID = util.IntPtr(ids["cachegroup"][s.CacheGroup])
+	srv.ProfileID = util.IntPtr(ids["profile"][s.Profile])
+	srv.TypeID = util.IntPtr(ids["type"][s.Type])
+	srv.PhysLocationID = util.IntPtr(ids["phys_location"][s.PhysLocation])
+	srv.StatusID = util.IntPtr(ids["status"][s.Status])
+	srv.UpdPending = util.BoolPtr(false)
+	srv.TCPPort = s.TCPPort
+	srv.HTTPSPort = s.HTTPSPort
+	return srv
+}
+
+// setServerInterfaces replaces the interfaces and IP addresses of the server with the given ID.
+func setServerInterfaces(tx *sql.Tx, id int, interfaces []tc.ServerInterfaceInfoV40) error {
+	if _, err := tx.Exec(`DELETE FROM ip_address WHERE server = $1`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM interface WHERE server = $1`, id); err != nil {
+		return err
+	}
+	for _, iface := range interfaces {
+		if _, err := tx.Exec(`INSERT INTO interface (server, name, max_bandwidth, monitor, mtu, router_host_name, router_port_name) VALUES ($1, $2, $3, $4, $5, $6, $7)`, id, iface.Name, iface.MaxBandwidth, iface.Monitor, iface.MTU, iface.RouterHostName, iface.RouterPortName); err != nil {
+			return err
+		}
+		for _, ip := range iface.IPAddresses {
+			if _, err := tx.Exec(`INSERT INTO ip_address (server, interface, address, gateway, service_address) VALUES ($1, $2, $3, $4, $5)`, id, iface.Name, ip.Address, ip.Gateway, ip.ServiceAddress); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setServerCapabilities replaces the Server Capabilities of the server with the given ID.
+func setServerCapabilities(tx *sql.Tx, id int, capabilities []string) error {
+	if _, err := tx.Exec(`DELETE FROM server_server_capability WHERE server = $1`, id); err != nil {
+		return err
+	}
+	if len(capabilities) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(`INSERT INTO server_server_capability (server, server_capability) SELECT $1, UNNEST($2::text[])`, id, pq.Array(capabilities))
+	return err
+}
+
+// applyDeliveryServices creates and updates Delivery Services with the same logic as the Delivery Service endpoints, then sets their regexes, required capabilities, and, once every Delivery Service exists, their steering targets.
+func (imp *importer) applyDeliveryServices() (error, error, int) {
+	typeNames, tenantNames, profileNames := []string{}, []string{}, []string{}
+	for _, ds := range imp.doc.DeliveryServices {
+		typeNames = append(typeNames, string(*ds.Type))
+		tenantNames = append(tenantNames, *ds.Tenant)
+		if ds.ProfileName != nil {
+			profileNames = append(profileNames, *ds.ProfileName)
+		}
+	}
+	typeIDs, err := getIDs(imp.tx, "type", typeNames)
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	tenantIDs, err := getIDs(imp.tx, "tenant", tenantNames)
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	profileIDs, err := getIDs(imp.tx, "profile", profileNames)
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+
+	changedIDs := map[string]int{}
+	for _, docDS := range imp.doc.DeliveryServices {
+		xmlID := *docDS.XMLID
+		if !imp.changed(importKindDeliveryService, xmlID) {
+			continue
+		}
+		ds := docDS.DeliveryServiceV4
+		stripDeliveryService(&ds)
+		ds.CDNID = util.IntPtr(imp.cdnID)
+		ds.TypeID = util.IntPtr(typeIDs[string(*ds.Type)])
+		ds.TenantID = util.IntPtr(tenantIDs[*ds.Tenant])
+		if ds.ProfileName != nil {
+			ds.ProfileID = util.IntPtr(profileIDs[*ds.ProfileName])
+		}
+
+		var res *tc.DeliveryServiceV4
+		var userErr, sysErr error
+		var errCode int
+		if imp.created.has(importKindDeliveryService, xmlID) {
+			res, errCode, userErr, sysErr = deliveryservice.CreateInTx(imp.r, imp.inf, ds)
+		} else {
+			existing := imp.cur.DeliveryServices[xmlID]
+			ds.ID = util.IntPtr(existing.ID)
+			ds.SSLKeyVersion = existing.SSLKeyVersion
+			res, errCode, userErr, sysErr = deliveryservice.UpdateInTx(imp.r, imp.inf, &ds)
+		}
+		if userErr != nil {
+			return fmt.Errorf("%s '%s': %s", importKindDeliveryService, xmlID, userErr.Error()), sysErr, errCode
+		}
+		if sysErr != nil {
+			return nil, fmt.Errorf("importing %s '%s': %s", importKindDeliveryService, xmlID, sysErr.Error()), errCode
+		}
+		changedIDs[xmlID] = *res.ID
+
+		regexes := make([]tc.DeliveryServiceRegex, 0, len(docDS.Regexes))
+		for _, re := range docDS.Regexes {
+			regexes = append(regexes, tc.DeliveryServiceRegex{Type: re.Type, Pattern: re.Pattern, SetNumber: re.SetNumber})
+		}
+		if err := deliveryservice.SetRegexes(imp.tx, *res.ID, regexes); err != nil {
+			return importDBErr(err, importKindDeliveryService, xmlID)
+		}
+		if err := deliveryservice.SetRequiredCapabilities(imp.tx, *res.ID, docDS.RequiredCapabilities); err != nil {
+			return importDBErr(err, importKindDeliveryService, xmlID)
+		}
+	}
+
+	for _, ds := range imp.doc.DeliveryServices {
+		id, ok := changedIDs[*ds.XMLID]
+		if !ok {
+			continue
+		}
+		if err := setSteeringTargets(imp.tx, id, ds.SteeringTargets); err != nil {
+			return importDBErr(err, importKindDeliveryService, *ds.XMLID)
+		}
+	}
+	return nil, nil, http.StatusOK
+}
+
+// setSteeringTargets replaces the steering targets of the Delivery Service with the given ID.
+func setSteeringTargets(tx *sql.Tx, dsID int, targets []tc.CDNExportSteeringTarget) error {
+	if _, err := tx.Exec(`DELETE FROM steering_target WHERE deliveryservice = $1`, dsID); err != nil {
+		return err
+	}
+	for _, target := range targets {
+		if _, err := tx.Exec(`
+INSERT INTO steering_target (deliveryservice, target, value, type)
+VALUES ($1, (SELECT id FROM deliveryservice WHERE xml_id = $2), $3, (SELECT id FROM type WHERE name = $4))
+`, dsID, target.Target, target.Value, target.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
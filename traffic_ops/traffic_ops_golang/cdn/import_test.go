@@ -0,0 +1,276 @@
+package cdn
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/parameter"
+)
+
+func testImportDoc() tc.CDNExport {
+	dsType := tc.DSTypeHTTP
+	ds := tc.CDNExportDeliveryService{Regexes: []tc.CDNExportRegex{{Type: "HOST_REGEXP", Pattern: `.*\.ds1\..*`}}}
+	ds.XMLID = util.StrPtr("ds1")
+	ds.Type = &dsType
+	ds.Tenant = util.StrPtr("root")
+	ds.Active = util.BoolPtr(true)
+	return tc.CDNExport{
+		Version:            tc.CDNExportVersion,
+		CDN:                tc.CDNExportCDN{Name: "cdn", DomainName: "cdn.test"},
+		Divisions:          []tc.CDNExportDivision{{Name: "div"}},
+		Regions:            []tc.CDNExportRegion{{Name: "reg", Division: "div"}},
+		PhysLocations:      []tc.CDNExportPhysLocation{{Name: "pl", Region: "reg"}},
+		CacheGroups:        []tc.CDNExportCacheGroup{{Name: "edge", Type: "EDGE_LOC", Latitude: util.FloatPtr(1), Longitude: util.FloatPtr(2)}},
+		Profiles:           []tc.CDNExportProfile{{Name: "EDGE", Type: "ATS_PROFILE", Parameters: []tc.CDNExportParameter{{Name: "location", ConfigFile: "remap.config", Value: "/etc"}}}},
+		ServerCapabilities: []string{"ram"},
+		Servers: []tc.CDNExportServer{
+			{HostName: "edge1", DomainName: "test", CacheGroup: "edge", Profile: "EDGE", Type: "EDGE", PhysLocation: "pl", Status: "ONLINE"},
+		},
+		DeliveryServices: []tc.CDNExportDeliveryService{ds},
+	}
+}
+
+// testImportState returns the state of Traffic Ops after testImportDoc has been imported.
+func testImportState(doc tc.CDNExport) *importState {
+	cdn := doc.CDN
+	cur := &importState{
+		CDN:              &cdn,
+		CDNID:            1,
+		Divisions:        map[string]tc.CDNExportDivision{},
+		Regions:          map[string]tc.CDNExportRegion{},
+		PhysLocations:    map[string]tc.CDNExportPhysLocation{},
+		CacheGroups:      map[string]tc.CDNExportCacheGroup{},
+		Profiles:         map[string]exportProfile{},
+		Capabilities:     map[string]struct{}{},
+		Servers:          map[string]exportServer{},
+		Topologies:       map[string]tc.CDNExportTopology{},
+		DeliveryServices: map[string]exportDeliveryService{},
+		Types:            map[string]struct{}{"EDGE_LOC": {}, "EDGE": {}, "HTTP": {}, "HOST_REGEXP": {}},
+		Statuses:         map[string]struct{}{"ONLINE": {}},
+		Tenants:          map[string]struct{}{"root": {}},
+	}
+	for _, d := range doc.Divisions {
+		cur.Divisions[d.Name] = d
+	}
+	for _, r := range doc.Regions {
+		cur.Regions[r.Name] = r
+	}
+	for _, pl := range doc.PhysLocations {
+		cur.PhysLocations[pl.Name] = pl
+	}
+	for _, cg := range doc.CacheGroups {
+		cur.CacheGroups[cg.Name] = cg
+	}
+	for i, p := range doc.Profiles {
+		cur.Profiles[p.Name] = exportProfile{ID: i + 1, CDN: cdn.Name, CDNExportProfile: p}
+	}
+	for _, c := range doc.ServerCapabilities {
+		cur.Capabilities[c] = struct{}{}
+	}
+	for i, s := range doc.Servers {
+		cur.Servers[s.FQDN()] = exportServer{ID: i + 1, CDN: cdn.Name, CDNExportServer: s}
+	}
+	for i, ds := range doc.DeliveryServices {
+		// the current state has the fields generated by Traffic Ops, which aren't compared
+		ds.ID = util.IntPtr(i + 1)
+		ds.CDNName = util.StrPtr(cdn.Name)
+		ds.ExampleURLs = []string{"http://ds1.cdn.test"}
+		stripDeliveryService(&ds.DeliveryServiceV4)
+		cur.DeliveryServices[*ds.XMLID] = exportDeliveryService{ID: i + 1, CDN: cdn.Name, CDNExportDeliveryService: ds}
+	}
+	return cur
+}
+
+func TestPlanImportNewCDN(t *testing.T) {
+	doc := testImportDoc()
+	cur := testImportState(tc.CDNExport{})
+	cur.CDN = nil
+	cur.CDNID = 0
+
+	plan, err := planImport(&doc, cur)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []tc.CDNImportChange{
+		{Type: importKindCDN, Name: "cdn"},
+		{Type: importKindDivision, Name: "div"},
+		{Type: importKindRegion, Name: "reg"},
+		{Type: importKindPhysLocation, Name: "pl"},
+		{Type: importKindServerCapability, Name: "ram"},
+		{Type: importKindCacheGroup, Name: "edge"},
+		{Type: importKindProfile, Name: "EDGE"},
+		{Type: importKindServer, Name: "edge1.test"},
+		{Type: importKindDeliveryService, Name: "ds1"},
+	}
+	if !reflect.DeepEqual(plan.Creates, expected) {
+		t.Errorf("expected creates %+v, actual %+v", expected, plan.Creates)
+	}
+	if len(plan.Updates) != 0 || len(plan.Deletes) != 0 {
+		t.Errorf("expected no updates or deletes, actual updates %+v, deletes %+v", plan.Updates, plan.Deletes)
+	}
+}
+
+func TestPlanImportUnchanged(t *testing.T) {
+	doc := testImportDoc()
+	plan, err := planImport(&doc, testImportState(testImportDoc()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Creates) != 0 || len(plan.Updates) != 0 || len(plan.Deletes) != 0 {
+		t.Errorf("expected an empty plan, actual %+v", plan)
+	}
+}
+
+func TestPlanImportChanges(t *testing.T) {
+	existing := testImportDoc()
+	existing.Servers = append(existing.Servers, tc.CDNExportServer{HostName: "edge2", DomainName: "test"})
+	existing.Profiles = append(existing.Profiles, tc.CDNExportProfile{Name: "OLD"})
+	cur := testImportState(existing)
+	// shared objects which aren't in the document are never deleted
+	cur.CacheGroups["other"] = tc.CDNExportCacheGroup{Name: "other"}
+
+	doc := testImportDoc()
+	doc.CDN.DNSSECEnabled = true
+	doc.CacheGroups[0].Latitude = util.FloatPtr(3)
+	doc.Profiles[0].Parameters[0].Value = "/opt"
+	doc.DeliveryServices[0].Active = util.BoolPtr(false)
+
+	plan, err := planImport(&doc, cur)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedUpdates := []tc.CDNImportChange{
+		{Type: importKindCDN, Name: "cdn"},
+		{Type: importKindCacheGroup, Name: "edge"},
+		{Type: importKindProfile, Name: "EDGE"},
+		{Type: importKindDeliveryService, Name: "ds1"},
+	}
+	if !reflect.DeepEqual(plan.Updates, expectedUpdates) {
+		t.Errorf("expected updates %+v, actual %+v", expectedUpdates, plan.Updates)
+	}
+	expectedDeletes := []tc.CDNImportChange{
+		{Type: importKindServer, Name: "edge2.test"},
+		{Type: importKindProfile, Name: "OLD"},
+	}
+	if !reflect.DeepEqual(plan.Deletes, expectedDeletes) {
+		t.Errorf("expected deletes %+v, actual %+v", expectedDeletes, plan.Deletes)
+	}
+	if len(plan.Creates) != 0 {
+		t.Errorf("expected no creates, actual %+v", plan.Creates)
+	}
+}
+
+func TestPlanImportErrors(t *testing.T) {
+	doc := testImportDoc()
+	doc.Servers[0].Status = "BOGUS"
+	doc.Profiles[0].Parameters = append(doc.Profiles[0].Parameters, tc.CDNExportParameter{Name: "secret", Value: parameter.HiddenField, Secure: true})
+	doc.DeliveryServices[0].ProfileName = util.StrPtr("MISSING")
+
+	cur := testImportState(testImportDoc())
+	ds := cur.DeliveryServices["ds1"]
+	ds.CDN = "other"
+	cur.DeliveryServices["ds1"] = ds
+
+	_, err := planImport(&doc, cur)
+	if err == nil {
+		t.Fatal("expected an error, got none")
+	}
+	for _, expected := range []string{
+		"server 'edge1.test': status 'BOGUS' does not exist",
+		"parameter 'secret' is secure, but its value is hidden",
+		"deliveryService 'ds1': profile 'MISSING' does not exist",
+		"delivery service 'ds1' already exists in CDN 'other'",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error to contain '%s', actual: %v", expected, err)
+		}
+	}
+}
+
+func TestImportYAMLRoundTrip(t *testing.T) {
+	doc := testImportDoc()
+	bts, err := marshalYAML(doc)
+	if err != nil {
+		t.Fatalf("marshalling YAML: %v", err)
+	}
+	if !strings.HasPrefix(string(bts), "version: \"1.0\"\ncdn:\n") {
+		t.Errorf("expected YAML to keep the JSON field names and order, actual:\n%s", bts)
+	}
+
+	parsed, err := parseImport(bytes.NewReader(bts), true)
+	if err != nil {
+		t.Fatalf("parsing YAML: %v", err)
+	}
+	if !sameJSON(doc, parsed) {
+		t.Errorf("expected YAML round trip to preserve the document, actual %+v", parsed)
+	}
+
+	jsonBts, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshalling JSON: %v", err)
+	}
+	if parsed, err = parseImport(bytes.NewReader(jsonBts), false); err != nil {
+		t.Fatalf("parsing JSON: %v", err)
+	}
+	if !sameJSON(doc, parsed) {
+		t.Errorf("expected JSON round trip to preserve the document, actual %+v", parsed)
+	}
+}
+
+func TestTopologyFromExport(t *testing.T) {
+	top := topologyFromExport(tc.CDNExportTopology{
+		Name: "top",
+		Nodes: []tc.CDNExportTopologyNode{
+			{CacheGroup: "edge", Parents: []string{"mid1", "mid2"}},
+			{CacheGroup: "mid1"},
+			{CacheGroup: "mid2", Parents: []string{"missing"}},
+		},
+	})
+	expected := []tc.TopologyNode{
+		{Cachegroup: "edge", Parents: []int{1, 2}},
+		{Cachegroup: "mid1", Parents: []int{}},
+		{Cachegroup: "mid2", Parents: []int{-1}},
+	}
+	if top.Name != "top" || !reflect.DeepEqual(top.Nodes, expected) {
+		t.Errorf("expected nodes %+v, actual %+v", expected, top.Nodes)
+	}
+}
+
+func TestServerFromExport(t *testing.T) {
+	s := testImportDoc().Servers[0]
+	ids := map[string]map[string]int{
+		"cachegroup":    {"edge": 2},
+		"profile":       {"EDGE": 3},
+		"type":          {"EDGE": 4},
+		"phys_location": {"pl": 5},
+		"status":        {"ONLINE": 6},
+	}
+	srv := serverFromExport(s, 1, ids)
+	if srv.ID != nil || *srv.HostName != "edge1" || *srv.DomainName != "test" || *srv.CDNID != 1 || *srv.CachegroupID != 2 || *srv.ProfileID != 3 || *srv.TypeID != 4 || *srv.PhysLocationID != 5 || *srv.StatusID != 6 || *srv.UpdPending {
+		t.Errorf("expected the server with the IDs of its references, actual %+v", srv.CommonServerProperties)
+	}
+}
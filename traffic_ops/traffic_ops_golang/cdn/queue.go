@@ -29,10 +29,37 @@ import (
 	"github.com/apache/trafficcontrol/lib/go-tc"
 
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/asyncjob"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
 )
 
+// queueUpdateJobType is the type of the asynchronous jobs which queue or
+// dequeue updates on the servers of a CDN.
+const queueUpdateJobType = "cdn_queue_update"
+
+// queueUpdateJobPayload is the payload of a cdn_queue_update job.
+type queueUpdateJobPayload struct {
+	CDN    string `json:"cdn"`
+	CDNID  int64  `json:"cdnID"`
+	Action string `json:"action"`
+}
+
+func init() {
+	asyncjob.Register(queueUpdateJobType, runQueueUpdateJob)
+}
+
+// Queue queues or dequeues updates on the servers of a CDN.
 func Queue(w http.ResponseWriter, r *http.Request) {
+	queue(w, r, false)
+}
+
+// QueueV40 queues an asynchronous job which queues or dequeues updates on the
+// servers of a CDN.
+func QueueV40(w http.ResponseWriter, r *http.Request) {
+	queue(w, r, true)
+}
+
+func queue(w http.ResponseWriter, r *http.Request, async bool) {
 	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
 	if userErr != nil || sysErr != nil {
 		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
@@ -48,6 +75,27 @@ func Queue(w http.ResponseWriter, r *http.Request) {
 		api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, errors.New("action must be 'queue' or 'dequeue'"), nil)
 		return
 	}
+
+	if async {
+		cdnName, ok, err := dbhelpers.GetCDNNameFromID(inf.Tx.Tx, int64(inf.IntParams["id"]))
+		if err != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("getting cdn name from ID '"+inf.Params["id"]+"': "+err.Error()))
+			return
+		} else if !ok {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusNotFound, nil, nil)
+			return
+		}
+		payload := queueUpdateJobPayload{CDN: string(cdnName), CDNID: int64(inf.IntParams["id"]), Action: reqObj.Action}
+		what := "Request to " + reqObj.Action + " server updates on CDN " + string(cdnName)
+		status, err := asyncjob.Enqueue(inf.Tx.Tx, queueUpdateJobType, inf.User, payload, what+" queued")
+		if err != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("queueing CDN server updates job: "+err.Error()))
+			return
+		}
+		asyncjob.WriteAccepted(w, r, status, what)
+		return
+	}
+
 	if err := queueUpdates(inf.Tx.Tx, int64(inf.IntParams["id"]), reqObj.Action == "queue"); err != nil {
 		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("CDN queueing updates: "+err.Error()))
 		return
@@ -65,6 +113,21 @@ func Queue(w http.ResponseWriter, r *http.Request) {
 	api.WriteResp(w, r, tc.CDNQueueUpdateResponse{Action: reqObj.Action, CDNID: int64(inf.IntParams["id"])})
 }
 
+// runQueueUpdateJob is the asyncjob.Func which queues or dequeues updates on
+// the servers of a CDN.
+func runQueueUpdateJob(inf *api.APIInfo, job *asyncjob.Job) (string, error, error) {
+	p := queueUpdateJobPayload{}
+	if err := job.DecodePayload(&p); err != nil {
+		return "", nil, err
+	}
+	if err := queueUpdates(inf.Tx.Tx, p.CDNID, p.Action == "queue"); err != nil {
+		return "", nil, errors.New("CDN queueing updates: " + err.Error())
+	}
+	api.CreateChangeLogRawTx(api.ApiChange, "CDN: "+p.CDN+", ID: "+strconv.FormatInt(p.CDNID, 10)+", ACTION: CDN server updates "+p.Action+"d", inf.User, inf.Tx.Tx)
+	job.SetResult(tc.CDNQueueUpdateResponse{Action: p.Action, CDNID: p.CDNID})
+	return "CDN " + p.CDN + " server updates " + p.Action + "d", nil, nil
+}
+
 func queueUpdates(tx *sql.Tx, cdnID int64, queue bool) error {
 	if _, err := tx.Exec(`UPDATE server SET upd_pending = $1 WHERE server.cdn_id = $2`, queue, cdnID); err != nil {
 		return errors.New("querying queue updates: " + err.Error())
@@ -32,14 +32,33 @@ import (
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/webhook"
 )
 
-const readQuery = `SELECT username, cdn, message, soft, last_updated FROM cdn_lock`
-const insertQuery = `INSERT INTO cdn_lock (username, cdn, message, soft) VALUES (:username, :cdn, :message, :soft) RETURNING username, cdn, message, soft, last_updated`
-const deleteQuery = `DELETE FROM cdn_lock WHERE cdn=$1 AND username=$2 RETURNING username, cdn, message, soft, last_updated`
-const deleteAdminQuery = `DELETE FROM cdn_lock WHERE cdn=$1 RETURNING username, cdn, message, soft, last_updated`
+const lockColumns = `username, cdn, scope, name, message, soft, expires, last_updated`
+const readQuery = `SELECT ` + lockColumns + ` FROM cdn_lock`
+const insertQuery = `INSERT INTO cdn_lock (username, cdn, scope, name, message, soft, expires) VALUES (:username, :cdn, :scope, :name, :message, :soft, :expires) RETURNING ` + lockColumns
+const deleteQuery = `DELETE FROM cdn_lock WHERE cdn=$1 AND scope=$2 AND name=$3 AND username=$4 RETURNING ` + lockColumns
+const deleteAdminQuery = `DELETE FROM cdn_lock WHERE cdn=$1 AND scope=$2 AND name=$3 RETURNING ` + lockColumns
+const deleteExpiredQuery = `DELETE FROM cdn_lock WHERE expires <= now()`
+const notExpiredCondition = `(cdn_lock.expires IS NULL OR cdn_lock.expires > now())`
+
+// otherUsersScopedLockQuery selects an unexpired lock held by a user other
+// than the one given on any single object within the given CDN.
+const otherUsersScopedLockQuery = `
+SELECT username, cdn, scope, name, message, expires
+FROM cdn_lock
+WHERE cdn = $1
+AND scope <> ''
+AND username <> $2
+AND (expires IS NULL OR expires > now())
+ORDER BY scope, name
+LIMIT 1
+`
 
 // Read is the handler for GET requests to /cdn_locks.
+//
+// Expired locks are never returned.
 func Read(w http.ResponseWriter, r *http.Request) {
 	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
 	tx := inf.Tx.Tx
@@ -52,6 +71,8 @@ func Read(w http.ResponseWriter, r *http.Request) {
 	cols := map[string]dbhelpers.WhereColumnInfo{
 		"cdn":      {Column: "cdn_lock.cdn", Checker: nil},
 		"username": {Column: "cdn_lock.username", Checker: nil},
+		"scope":    {Column: "cdn_lock.scope", Checker: nil},
+		"name":     {Column: "cdn_lock.name", Checker: nil},
 	}
 
 	where, orderBy, pagination, queryValues, errs := dbhelpers.BuildWhereAndOrderByAndPagination(inf.Params, cols)
@@ -61,6 +82,11 @@ func Read(w http.ResponseWriter, r *http.Request) {
 		api.HandleErr(w, r, tx, errCode, userErr, nil)
 		return
 	}
+	if where == "" {
+		where = dbhelpers.BaseWhere + " " + notExpiredCondition
+	} else {
+		where += " AND " + notExpiredCondition
+	}
 
 	cdnLock := []tc.CDNLock{}
 	query := readQuery + where + orderBy + pagination
@@ -73,7 +99,7 @@ func Read(w http.ResponseWriter, r *http.Request) {
 
 	for rows.Next() {
 		var cLock tc.CDNLock
-		if err = rows.Scan(&cLock.UserName, &cLock.CDN, &cLock.Message, &cLock.Soft, &cLock.LastUpdated); err != nil {
+		if err = rows.Scan(&cLock.UserName, &cLock.CDN, &cLock.Scope, &cLock.Name, &cLock.Message, &cLock.Soft, &cLock.Expires, &cLock.LastUpdated); err != nil {
 			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("scanning cdn locks: "+err.Error()))
 			return
 		}
@@ -94,20 +120,30 @@ func Create(w http.ResponseWriter, r *http.Request) {
 	tx := inf.Tx.Tx
 	if inf.User == nil {
 		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("couldn't get user for the current request"))
+		return
 	}
 	var cdnLock tc.CDNLock
 	if err := json.NewDecoder(r.Body).Decode(&cdnLock); err != nil {
 		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
 		return
 	}
-	if cdnLock.Soft == nil {
-		api.HandleErr(w, r, tx, http.StatusBadRequest, errors.New("field 'soft' must be present"), nil)
+	if err := cdnLock.Validate(); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
+		return
+	}
+	if userErr, sysErr, errCode := checkLockedObject(tx, cdnLock); userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	if _, err := tx.Exec(deleteExpiredQuery); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("cdn lock create: removing expired locks: "+err.Error()))
 		return
 	}
-	if cdnLock.CDN == "" {
-		api.HandleErr(w, r, tx, http.StatusBadRequest, errors.New("field 'cdn' must be present"), nil)
+	if userErr, sysErr, errCode := checkConflictingLocks(tx, cdnLock, inf.User.UserName); userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
 		return
 	}
+
 	cdnLock.UserName = inf.User.UserName
 	resultRows, err := inf.Tx.NamedQuery(insertQuery, cdnLock)
 	if err != nil {
@@ -120,7 +156,7 @@ func Create(w http.ResponseWriter, r *http.Request) {
 	rowsAffected := 0
 	for resultRows.Next() {
 		rowsAffected++
-		if err := resultRows.Scan(&cdnLock.UserName, &cdnLock.CDN, &cdnLock.Message, &cdnLock.Soft, &cdnLock.LastUpdated); err != nil {
+		if err := resultRows.Scan(&cdnLock.UserName, &cdnLock.CDN, &cdnLock.Scope, &cdnLock.Name, &cdnLock.Message, &cdnLock.Soft, &cdnLock.Expires, &cdnLock.LastUpdated); err != nil {
 			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("cdn lock create: scanning locks: "+err.Error()))
 			return
 		}
@@ -132,11 +168,16 @@ func Create(w http.ResponseWriter, r *http.Request) {
 	alerts := tc.CreateAlerts(tc.SuccessLevel, "CDN lock acquired!")
 	api.WriteAlertsObj(w, r, http.StatusCreated, alerts, cdnLock)
 
-	changeLogMsg := fmt.Sprintf("USER: %s, CDN: %s, ACTION: Lock Acquired", inf.User.UserName, cdnLock.CDN)
+	changeLogMsg := fmt.Sprintf("USER: %s, %s, ACTION: Lock Acquired", inf.User.UserName, changeLogSubject(cdnLock))
 	api.CreateChangeLogRawTx(api.ApiChange, changeLogMsg, inf.User, tx)
+	webhook.Enqueue(tx, tc.WebhookEventCDNLockCreate, inf.User, cdnLock)
 }
 
 // Delete is the handler for DELETE requests to /cdn_locks.
+//
+// Users may only release their own locks, except for admins, who may release
+// any lock; an admin releasing another user's lock is recorded in the change
+// log as an override.
 func Delete(w http.ResponseWriter, r *http.Request) {
 	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"cdn"}, nil)
 	if userErr != nil || sysErr != nil {
@@ -146,31 +187,131 @@ func Delete(w http.ResponseWriter, r *http.Request) {
 	defer inf.Close()
 
 	cdn := inf.Params["cdn"]
+	scope := tc.CDNLockScope(inf.Params["scope"])
+	name := inf.Params["name"]
 	tx := inf.Tx.Tx
 	if inf.User == nil {
 		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("couldn't get user for the current request"))
+		return
+	}
+	if !scope.IsValid() {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, fmt.Errorf("invalid scope '%s'", scope), nil)
+		return
 	}
+	if (scope == tc.CDNLockScopeCDN) != (name == "") {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, errors.New("'scope' and 'name' must be given together, or not at all"), nil)
+		return
+	}
+	description := scope.Description(name, cdn)
+
 	var result tc.CDNLock
-	var err error
+	var row *sql.Row
 	if inf.User.PrivLevel == auth.PrivLevelAdmin {
-		err = inf.Tx.Tx.QueryRow(deleteAdminQuery, cdn).Scan(&result.UserName, &result.CDN, &result.Message, &result.Soft, &result.LastUpdated)
+		row = tx.QueryRow(deleteAdminQuery, cdn, scope, name)
 	} else {
-		err = inf.Tx.Tx.QueryRow(deleteQuery, cdn, inf.User.UserName).Scan(&result.UserName, &result.CDN, &result.Message, &result.Soft, &result.LastUpdated)
+		row = tx.QueryRow(deleteQuery, cdn, scope, name, inf.User.UserName)
 	}
-	if err != nil {
+	if err := row.Scan(&result.UserName, &result.CDN, &result.Scope, &result.Name, &result.Message, &result.Soft, &result.Expires, &result.LastUpdated); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			if inf.User.PrivLevel != auth.PrivLevelAdmin {
-				api.HandleErr(w, r, tx, http.StatusForbidden, fmt.Errorf("deleting cdn lock with cdn name %s: operation forbidden", cdn), nil)
+				api.HandleErr(w, r, tx, http.StatusForbidden, fmt.Errorf("deleting cdn lock on %s: operation forbidden", description), nil)
 				return
 			}
-			api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("deleting cdn lock with cdn name %s: lock not found", cdn), nil)
+			api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("deleting cdn lock on %s: lock not found", description), nil)
 			return
 		}
-		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("deleting cdn lock with cdn name %s : %w", cdn, err))
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("deleting cdn lock on %s : %w", description, err))
 		return
 	}
+
 	alerts := tc.CreateAlerts(tc.SuccessLevel, "cdn lock deleted")
+	changeLogMsg := fmt.Sprintf("USER: %s, %s, ACTION: Lock Released", result.UserName, changeLogSubject(result))
+	if result.UserName != inf.User.UserName {
+		alerts.AddNewAlert(tc.WarnLevel, fmt.Sprintf("lock held by user %s was overridden", result.UserName))
+		changeLogMsg = fmt.Sprintf("USER: %s, %s, ACTION: Lock Overridden by %s", result.UserName, changeLogSubject(result), inf.User.UserName)
+	}
 	api.WriteAlertsObj(w, r, http.StatusOK, alerts, result)
-	changeLogMsg := fmt.Sprintf("USER: %s, CDN: %s, ACTION: Lock Released", result.UserName, cdn)
 	api.CreateChangeLogRawTx(api.ApiChange, changeLogMsg, inf.User, tx)
+	webhook.Enqueue(tx, tc.WebhookEventCDNLockDelete, inf.User, result)
+}
+
+// changeLogSubject describes what a lock is on, for use in change log
+// entries.
+func changeLogSubject(lock tc.CDNLock) string {
+	if lock.Scope == tc.CDNLockScopeCDN {
+		return "CDN: " + lock.CDN
+	}
+	return fmt.Sprintf("CDN: %s, SCOPE: %s, NAME: %s", lock.CDN, lock.Scope, lock.Name)
+}
+
+// checkLockedObject checks that the object to which a scoped lock applies
+// exists and - if it belongs to a CDN - that it belongs to the CDN of the
+// lock. Topologies and Cache Groups don't belong to any single CDN, so locks
+// on them may be made within any CDN.
+func checkLockedObject(tx *sql.Tx, lock tc.CDNLock) (error, error, int) {
+	var query string
+	switch lock.Scope {
+	case tc.CDNLockScopeCDN:
+		return nil, nil, http.StatusOK
+	case tc.CDNLockScopeDeliveryService:
+		query = `SELECT cdn.name FROM deliveryservice AS ds JOIN cdn ON cdn.id = ds.cdn_id WHERE ds.xml_id = $1`
+	case tc.CDNLockScopeProfile:
+		query = `SELECT cdn.name FROM profile AS p JOIN cdn ON cdn.id = p.cdn WHERE p.name = $1`
+	case tc.CDNLockScopeTopology:
+		query = `SELECT '' FROM topology WHERE name = $1`
+	case tc.CDNLockScopeCacheGroup:
+		query = `SELECT '' FROM cachegroup WHERE name = $1`
+	}
+
+	cdn := ""
+	if err := tx.QueryRow(query, lock.Name).Scan(&cdn); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("no %s named '%s' exists", lock.Scope, lock.Name), nil, http.StatusNotFound
+		}
+		return nil, fmt.Errorf("checking existence of %s '%s': %w", lock.Scope, lock.Name, err), http.StatusInternalServerError
+	}
+	if cdn != "" && cdn != lock.CDN {
+		return fmt.Errorf("%s '%s' belongs to cdn %s, not %s", lock.Scope, lock.Name, cdn, lock.CDN), nil, http.StatusBadRequest
+	}
+	return nil, nil, http.StatusOK
+}
+
+// checkConflictingLocks checks that no user other than the one given holds a
+// lock which would conflict with the given lock: a lock on the same object,
+// a lock on the whole CDN, or - if the given lock is on the whole CDN - a lock
+// on any object within it. Topologies and Cache Groups may span CDNs, so
+// locks on them conflict with locks on the same object in any CDN, and with
+// locks on any CDN they touch.
+func checkConflictingLocks(tx *sql.Tx, lock tc.CDNLock, user string) (error, error, int) {
+	var cdns []string
+	var err error
+	switch lock.Scope {
+	case tc.CDNLockScopeTopology:
+		cdns, err = dbhelpers.GetTopologyCDNs(tx, lock.Name, nil)
+	case tc.CDNLockScopeCacheGroup:
+		cdns, err = dbhelpers.GetCacheGroupCDNs(tx, lock.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting cdns of %s '%s': %w", lock.Scope, lock.Name, err), http.StatusInternalServerError
+	}
+	if cdns != nil {
+		if userErr, sysErr, errCode := dbhelpers.CheckIfCurrentUserCanModifyMultiCDNObject(tx, append(cdns, lock.CDN), lock.Scope, lock.Name, user); userErr != nil || sysErr != nil {
+			return userErr, sysErr, errCode
+		}
+	} else if userErr, sysErr, errCode := dbhelpers.CheckIfCurrentUserCanModifyCDNObject(tx, lock.CDN, lock.Scope, lock.Name, user); userErr != nil || sysErr != nil {
+		return userErr, sysErr, errCode
+	}
+	if lock.Scope != tc.CDNLockScopeCDN {
+		return nil, nil, http.StatusOK
+	}
+
+	var held tc.CDNLock
+	err = tx.QueryRow(otherUsersScopedLockQuery, lock.CDN, user).Scan(&held.UserName, &held.CDN, &held.Scope, &held.Name, &held.Message, &held.Expires)
+	if err == nil {
+		return errors.New(held.ConflictMessage()), nil, http.StatusForbidden
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("querying scoped locks on cdn %s: %w", lock.CDN, err), http.StatusInternalServerError
+	}
+	return nil, nil, http.StatusOK
 }
@@ -0,0 +1,138 @@
+package cdn_lock
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+var lockCols = []string{"username", "cdn", "scope", "name", "message", "expires"}
+
+func TestChangeLogSubject(t *testing.T) {
+	if s := changeLogSubject(tc.CDNLock{CDN: "cdn1"}); s != "CDN: cdn1" {
+		t.Errorf("unexpected change log subject for a CDN-wide lock: %s", s)
+	}
+	lock := tc.CDNLock{CDN: "cdn1", Scope: tc.CDNLockScopeTopology, Name: "mso"}
+	if s := changeLogSubject(lock); s != "CDN: cdn1, SCOPE: topology, NAME: mso" {
+		t.Errorf("unexpected change log subject for a topology lock: %s", s)
+	}
+}
+
+func TestCheckLockedObject(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT cdn.name FROM deliveryservice").WithArgs("ds1").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("cdn2"))
+	mock.ExpectQuery("SELECT cdn.name FROM deliveryservice").WithArgs("ds2").WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	mock.ExpectQuery("SELECT '' FROM topology").WithArgs("mso").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow(""))
+	tx, err := mockDB.Begin()
+	if err != nil {
+		t.Fatalf("beginning transaction: %v", err)
+	}
+
+	lock := tc.CDNLock{CDN: "cdn1", Scope: tc.CDNLockScopeDeliveryService, Name: "ds1"}
+	if userErr, _, code := checkLockedObject(tx, lock); code != http.StatusBadRequest || userErr == nil {
+		t.Errorf("expected a lock on a delivery service in another CDN to be rejected, got: %d %v", code, userErr)
+	}
+	lock.Name = "ds2"
+	if userErr, _, code := checkLockedObject(tx, lock); code != http.StatusNotFound || userErr == nil {
+		t.Errorf("expected a lock on a nonexistent delivery service to be rejected, got: %d %v", code, userErr)
+	}
+	lock = tc.CDNLock{CDN: "cdn1", Scope: tc.CDNLockScopeTopology, Name: "mso"}
+	if userErr, sysErr, code := checkLockedObject(tx, lock); userErr != nil || sysErr != nil {
+		t.Errorf("expected a lock on an existing topology to be accepted, got: %d %v %v", code, userErr, sysErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestCheckConflictingLocks(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT username, cdn, scope, name, message, expires FROM cdn_lock").WithArgs("cdn1", "", "").WillReturnRows(sqlmock.NewRows(lockCols))
+	mock.ExpectQuery("SELECT username, cdn, scope, name, message, expires FROM cdn_lock WHERE cdn = \\$1 AND scope <> ''").WithArgs("cdn1", "bob").WillReturnRows(sqlmock.NewRows(lockCols).AddRow("alice", "cdn1", "deliveryservice", "ds1", "rotating keys", nil))
+	tx, err := mockDB.Begin()
+	if err != nil {
+		t.Fatalf("beginning transaction: %v", err)
+	}
+
+	lock := tc.CDNLock{CDN: "cdn1", Soft: util.BoolPtr(true)}
+	userErr, sysErr, code := checkConflictingLocks(tx, lock, "bob")
+	if sysErr != nil {
+		t.Fatalf("unexpected system error: %v", sysErr)
+	}
+	if code != http.StatusForbidden || userErr == nil {
+		t.Fatalf("expected a CDN-wide lock to conflict with another user's delivery service lock, got: %d %v", code, userErr)
+	}
+	if !strings.Contains(userErr.Error(), "user alice currently has a lock on delivery service ds1 in cdn cdn1: rotating keys") {
+		t.Errorf("expected the conflict to name the lock holder, got: %v", userErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestCheckConflictingLocksAcrossCDNs(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT cdn.name FROM deliveryservice").WithArgs("mso", sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("cdn1").AddRow("cdn2"))
+	mock.ExpectQuery("SELECT username, cdn, scope, name, message, expires FROM cdn_lock").WithArgs(sqlmock.AnyArg(), "topology", "mso").WillReturnRows(sqlmock.NewRows(lockCols).AddRow("alice", "cdn2", "", "", "snapshotting", nil))
+	tx, err := mockDB.Begin()
+	if err != nil {
+		t.Fatalf("beginning transaction: %v", err)
+	}
+
+	lock := tc.CDNLock{CDN: "cdn1", Scope: tc.CDNLockScopeTopology, Name: "mso", Soft: util.BoolPtr(true)}
+	userErr, sysErr, code := checkConflictingLocks(tx, lock, "bob")
+	if sysErr != nil {
+		t.Fatalf("unexpected system error: %v", sysErr)
+	}
+	if code != http.StatusForbidden || userErr == nil {
+		t.Fatalf("expected a topology lock to conflict with another user's lock on a CDN the topology touches, got: %d %v", code, userErr)
+	}
+	if !strings.Contains(userErr.Error(), "user alice currently has a lock on cdn cdn2") {
+		t.Errorf("expected the conflict to name the lock holder, got: %v", userErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -106,6 +107,18 @@ type ConfigTrafficOpsGolang struct {
 	TLSConfig            *tls.Config     `json:"tls_config"`
 	TrafficVaultBackend  string          `json:"traffic_vault_backend"`
 	TrafficVaultConfig   json.RawMessage `json:"traffic_vault_config"`
+	// RateLimit configures per-user request rate limits on authenticated API routes. If nil, no limits are enforced.
+	RateLimit *ConfigRateLimit `json:"rate_limit"`
+	// SnapshotHistoryLength is the number of Snapshots kept for each CDN, which may be restored. If zero, DefaultSnapshotHistoryLength is used.
+	SnapshotHistoryLength int `json:"snapshot_history_length"`
+	// OIDC configures login with an OpenID Connect identity provider. If nil, OpenID Connect login is disabled.
+	OIDC *ConfigOIDC `json:"oidc"`
+	// AsyncJobs configures the workers which run queued asynchronous jobs, such as Snapshots.
+	AsyncJobs ConfigAsyncJobs `json:"async_jobs"`
+	// CapacityForecastThresholds are the default utilization percentages for which CDN capacity forecasts project crossing dates. If empty, DefaultCapacityForecastThresholds is used.
+	CapacityForecastThresholds []float64 `json:"capacity_forecast_thresholds"`
+	// CertExpirationAlerts configures the periodic alerts about Delivery Service SSL certificates which will soon expire.
+	CertExpirationAlerts ConfigCertExpirationAlerts `json:"cert_expiration_alerts"`
 
 	// CRConfigUseRequestHost is whether to use the client request host header in the CRConfig. If false, uses the tm.url parameter.
 	// This defaults to false. Traffic Ops used to always use the host header, setting this true will resume that legacy behavior.
@@ -124,6 +137,99 @@ type RoutingBlacklist struct {
 	DisabledRoutes      []int `json:"disabled_routes"`
 }
 
+// ConfigRateLimit contains the token-bucket request limits enforced on authenticated API routes.
+// A user's overall limit is taken from Users if the user is listed there, else from Roles by the user's role name, else from Default.
+// Limits in Routes, keyed by Route ID, are enforced for each user in addition to their overall limit.
+type ConfigRateLimit struct {
+	Default *RateLimit           `json:"default"`
+	Users   map[string]RateLimit `json:"users"`
+	Roles   map[string]RateLimit `json:"roles"`
+	Routes  map[int]RateLimit    `json:"routes"`
+}
+
+// RateLimit is a single token-bucket limit: a sustained rate of RequestsPerSecond, with bursts of up to Burst requests.
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+}
+
+// ConfigAsyncJobs configures the pool of workers which run queued asynchronous jobs.
+type ConfigAsyncJobs struct {
+	// Workers is the number of jobs this Traffic Ops instance runs at once. If zero, DefaultAsyncJobWorkers is used.
+	Workers int `json:"workers"`
+	// ArtifactDir is the directory in which files produced by jobs, e.g. ISO images, are kept until they expire. If empty,
+	// a directory named DefaultAsyncJobArtifactDirName in the system temporary directory is used.
+	ArtifactDir string `json:"artifact_dir"`
+	// ArtifactTTLHours is the number of hours for which files produced by jobs are kept. If zero, DefaultAsyncJobArtifactTTLHours
+	// is used.
+	ArtifactTTLHours int `json:"artifact_ttl_hours"`
+}
+
+// ConfigCertExpirationAlerts configures the periodic check for Delivery Service SSL certificates which will soon expire.
+// Each check emails a digest of them to DigestEmail, if set and SMTP is enabled, enqueues a Webhook event, and, if
+// NotificationUser is set, posts a notification as that user to each CDN with such certificates.
+type ConfigCertExpirationAlerts struct {
+	// Enabled is whether the periodic check is run at all.
+	Enabled bool `json:"enabled"`
+	// IntervalHours is the number of hours between checks. If zero, DefaultCertExpirationAlertIntervalHours is used.
+	IntervalHours int `json:"interval_hours"`
+	// DaysBeforeExpiration is how many days before it expires a certificate is alerted about. If zero,
+	// DefaultCertExpirationAlertDays is used.
+	DaysBeforeExpiration int `json:"days_before_expiration"`
+	// DigestEmail is the address to which the digest is emailed. If empty, no email is sent.
+	DigestEmail string `json:"digest_email"`
+	// NotificationUser is the username as which CDN notifications are posted. If empty, none are posted.
+	NotificationUser string `json:"notification_user"`
+}
+
+// ConfigOIDC contains the settings for logging in with an OpenID Connect identity provider.
+// The provider's endpoints and signing keys are discovered from IssuerURL.
+// Users are identified by the UsernameClaim of their ID tokens. Their Role and Tenant are taken from the first of GroupMappings
+// which matches one of the groups in their GroupsClaim, falling back to DefaultRole and DefaultTenant; if TenantClaim is set and
+// present in the ID token, it is used as the Tenant instead.
+type ConfigOIDC struct {
+	IssuerURL     string   `json:"issuer_url"`
+	ClientID      string   `json:"client_id"`
+	ClientSecret  string   `json:"client_secret"`
+	RedirectURL   string   `json:"redirect_url"`
+	Scopes        []string `json:"scopes"`
+	UsernameClaim string   `json:"username_claim"`
+	GroupsClaim   string   `json:"groups_claim"`
+	TenantClaim   string   `json:"tenant_claim"`
+
+	GroupMappings []OIDCGroupMapping `json:"group_mappings"`
+	DefaultRole   string             `json:"default_role"`
+	DefaultTenant string             `json:"default_tenant"`
+	// AutoProvision is whether users who don't exist in Traffic Ops are created on their first login.
+	AutoProvision bool `json:"auto_provision"`
+	// SyncUsers is whether the Role and Tenant of existing users are updated from their groups on each login.
+	SyncUsers bool `json:"sync_users"`
+
+	// JWKSRefreshIntervalSeconds is how long the provider's discovery document and signing keys are cached. Keys are also re-fetched
+	// whenever a token is signed with an unknown key, so that key rotation doesn't wait for the interval.
+	JWKSRefreshIntervalSeconds int `json:"jwks_refresh_interval_seconds"`
+	// ClockSkewSeconds is the allowed difference between the clocks of Traffic Ops and the provider when checking token lifetimes.
+	ClockSkewSeconds int `json:"clock_skew_seconds"`
+}
+
+// OIDCGroupMapping maps users in an identity provider group to a Traffic Ops Role and, optionally, Tenant.
+type OIDCGroupMapping struct {
+	Group  string `json:"group"`
+	Role   string `json:"role"`
+	Tenant string `json:"tenant"`
+}
+
+// These are the defaults of ConfigOIDC fields which aren't set.
+const (
+	DefaultOIDCUsernameClaim              = "preferred_username"
+	DefaultOIDCGroupsClaim                = "groups"
+	DefaultOIDCJWKSRefreshIntervalSeconds = 3600
+	DefaultOIDCClockSkewSeconds           = 60
+)
+
+// DefaultOIDCScopes are the scopes requested from the identity provider if none are configured.
+var DefaultOIDCScopes = []string{"openid", "profile", "email"}
+
 // ConfigTO contains information to identify Traffic Ops in a network sense.
 type ConfigTO struct {
 	BaseURL               *rfc.URL          `json:"base_url"`
@@ -215,6 +321,15 @@ func NewFakeConfig() Config {
 
 const DefaultLDAPTimeoutSecs = 60
 const DefaultDBQueryTimeoutSecs = 20
+const DefaultSnapshotHistoryLength = 10
+const DefaultAsyncJobWorkers = 4
+const DefaultAsyncJobArtifactDirName = "traffic_ops_async_jobs"
+const DefaultAsyncJobArtifactTTLHours = 24
+const DefaultCertExpirationAlertIntervalHours = 24
+const DefaultCertExpirationAlertDays = 30
+
+// DefaultCapacityForecastThresholds are the utilization percentages used by capacity forecasts when none are configured.
+var DefaultCapacityForecastThresholds = []float64{70, 85, 95}
 
 // ErrorLog - critical messages
 func (c Config) ErrorLog() log.LogLocation {
@@ -392,6 +507,27 @@ func ParseConfig(cfg Config) (Config, error) {
 	if cfg.DBQueryTimeoutSeconds == 0 {
 		cfg.DBQueryTimeoutSeconds = DefaultDBQueryTimeoutSecs
 	}
+	if cfg.SnapshotHistoryLength <= 0 {
+		cfg.SnapshotHistoryLength = DefaultSnapshotHistoryLength
+	}
+	if cfg.AsyncJobs.Workers <= 0 {
+		cfg.AsyncJobs.Workers = DefaultAsyncJobWorkers
+	}
+	if cfg.AsyncJobs.ArtifactDir == "" {
+		cfg.AsyncJobs.ArtifactDir = filepath.Join(os.TempDir(), DefaultAsyncJobArtifactDirName)
+	}
+	if cfg.AsyncJobs.ArtifactTTLHours <= 0 {
+		cfg.AsyncJobs.ArtifactTTLHours = DefaultAsyncJobArtifactTTLHours
+	}
+	if len(cfg.CapacityForecastThresholds) == 0 {
+		cfg.CapacityForecastThresholds = DefaultCapacityForecastThresholds
+	}
+	if cfg.CertExpirationAlerts.IntervalHours <= 0 {
+		cfg.CertExpirationAlerts.IntervalHours = DefaultCertExpirationAlertIntervalHours
+	}
+	if cfg.CertExpirationAlerts.DaysBeforeExpiration <= 0 {
+		cfg.CertExpirationAlerts.DaysBeforeExpiration = DefaultCertExpirationAlertDays
+	}
 
 	invalidTOURLStr := ""
 	var err error
@@ -440,9 +576,38 @@ func ParseConfig(cfg Config) (Config, error) {
 		return Config{}, err
 	}
 
+	if cfg.RateLimit != nil {
+		if err := ValidateRateLimit(*cfg.RateLimit); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if cfg.OIDC != nil {
+		if err := ValidateOIDC(cfg.OIDC); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := ValidateCapacityForecastThresholds(cfg.CapacityForecastThresholds); err != nil {
+		return Config{}, err
+	}
+
 	return cfg, nil
 }
 
+// ValidateCapacityForecastThresholds returns an error if any of the given capacity forecast thresholds isn't a percentage greater than 0 and at most 100, or if they aren't in strictly ascending order, e.g. a warning threshold after a higher critical one.
+func ValidateCapacityForecastThresholds(thresholds []float64) error {
+	for i, pct := range thresholds {
+		if math.IsNaN(pct) || pct <= 0 || pct > 100 {
+			return fmt.Errorf("capacity_forecast_thresholds: %v is not a percentage greater than 0 and at most 100", pct)
+		}
+		if i > 0 && pct <= thresholds[i-1] {
+			return fmt.Errorf("capacity_forecast_thresholds: must be in ascending order without duplicates, but %v follows %v", pct, thresholds[i-1])
+		}
+	}
+	return nil
+}
+
 func ValidateRoutingBlacklist(blacklist RoutingBlacklist) error {
 	seenDisabledIDs := make(map[int]struct{}, len(blacklist.DisabledRoutes))
 	for _, id := range blacklist.DisabledRoutes {
@@ -455,6 +620,86 @@ func ValidateRoutingBlacklist(blacklist RoutingBlacklist) error {
 	return nil
 }
 
+// ValidateRateLimit returns an error if any of the limits in the given rate limit configuration are unusable.
+func ValidateRateLimit(rl ConfigRateLimit) error {
+	errs := []error{}
+	if rl.Default != nil {
+		if err := validateRateLimit(*rl.Default); err != nil {
+			errs = append(errs, fmt.Errorf("rate_limit.default: %v", err))
+		}
+	}
+	for user, limit := range rl.Users {
+		if err := validateRateLimit(limit); err != nil {
+			errs = append(errs, fmt.Errorf("rate_limit.users['%s']: %v", user, err))
+		}
+	}
+	for role, limit := range rl.Roles {
+		if err := validateRateLimit(limit); err != nil {
+			errs = append(errs, fmt.Errorf("rate_limit.roles['%s']: %v", role, err))
+		}
+	}
+	for routeID, limit := range rl.Routes {
+		if err := validateRateLimit(limit); err != nil {
+			errs = append(errs, fmt.Errorf("rate_limit.routes['%d']: %v", routeID, err))
+		}
+	}
+	return util.JoinErrs(errs)
+}
+
+func validateRateLimit(limit RateLimit) error {
+	if limit.RequestsPerSecond <= 0 {
+		return errors.New("requests_per_second must be greater than zero")
+	}
+	if limit.Burst < 1 {
+		return errors.New("burst must be at least 1")
+	}
+	return nil
+}
+
+// ValidateOIDC returns an error if the given OpenID Connect configuration is unusable, and sets the defaults of fields which aren't set.
+func ValidateOIDC(oidc *ConfigOIDC) error {
+	errs := []error{}
+	if oidc.IssuerURL == "" {
+		errs = append(errs, errors.New("oidc.issuer_url is required"))
+	} else if u, err := url.Parse(oidc.IssuerURL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("oidc.issuer_url '%s' is not an absolute URL", oidc.IssuerURL))
+	}
+	if oidc.ClientID == "" {
+		errs = append(errs, errors.New("oidc.client_id is required"))
+	}
+	if oidc.RedirectURL == "" {
+		errs = append(errs, errors.New("oidc.redirect_url is required"))
+	}
+	for i, mapping := range oidc.GroupMappings {
+		if mapping.Group == "" || mapping.Role == "" {
+			errs = append(errs, fmt.Errorf("oidc.group_mappings[%d]: group and role are required", i))
+		}
+	}
+	if oidc.DefaultRole != "" && oidc.DefaultTenant == "" && oidc.TenantClaim == "" {
+		errs = append(errs, errors.New("oidc.default_tenant or oidc.tenant_claim is required with oidc.default_role"))
+	}
+	if len(errs) > 0 {
+		return util.JoinErrs(errs)
+	}
+
+	if len(oidc.Scopes) == 0 {
+		oidc.Scopes = DefaultOIDCScopes
+	}
+	if oidc.UsernameClaim == "" {
+		oidc.UsernameClaim = DefaultOIDCUsernameClaim
+	}
+	if oidc.GroupsClaim == "" {
+		oidc.GroupsClaim = DefaultOIDCGroupsClaim
+	}
+	if oidc.JWKSRefreshIntervalSeconds <= 0 {
+		oidc.JWKSRefreshIntervalSeconds = DefaultOIDCJWKSRefreshIntervalSeconds
+	}
+	if oidc.ClockSkewSeconds <= 0 {
+		oidc.ClockSkewSeconds = DefaultOIDCClockSkewSeconds
+	}
+	return nil
+}
+
 func GetLDAPConfig(LDAPConfPath string) (bool, *ConfigLDAP, error) {
 	LDAPConfBytes, err := ioutil.ReadFile(LDAPConfPath)
 	if err != nil {
@@ -24,6 +24,7 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"strings"
 	"testing"
@@ -306,3 +307,126 @@ func TestValidateRoutingBlacklist(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateRateLimit(t *testing.T) {
+	type testCase struct {
+		Input     ConfigRateLimit
+		ExpectErr bool
+	}
+	testCases := []testCase{
+		{
+			Input:     ConfigRateLimit{},
+			ExpectErr: false,
+		},
+		{
+			Input: ConfigRateLimit{
+				Default: &RateLimit{RequestsPerSecond: 10, Burst: 20},
+				Users:   map[string]RateLimit{"bot": {RequestsPerSecond: 0.5, Burst: 1}},
+				Roles:   map[string]RateLimit{"admin": {RequestsPerSecond: 100, Burst: 100}},
+				Routes:  map[int]RateLimit{42: {RequestsPerSecond: 1, Burst: 5}},
+			},
+			ExpectErr: false,
+		},
+		{
+			Input: ConfigRateLimit{
+				Default: &RateLimit{RequestsPerSecond: 0, Burst: 20},
+			},
+			ExpectErr: true,
+		},
+		{
+			Input: ConfigRateLimit{
+				Users: map[string]RateLimit{"bot": {RequestsPerSecond: 1, Burst: 0}},
+			},
+			ExpectErr: true,
+		},
+		{
+			Input: ConfigRateLimit{
+				Routes: map[int]RateLimit{42: {RequestsPerSecond: -1, Burst: 1}},
+			},
+			ExpectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		if err := ValidateRateLimit(tc.Input); err != nil && !tc.ExpectErr {
+			t.Errorf("Expected: no error, actual: %v", err)
+		} else if err == nil && tc.ExpectErr {
+			t.Errorf("Expected: non-nil error, actual: nil")
+		}
+	}
+}
+
+func TestValidateCapacityForecastThresholds(t *testing.T) {
+	type testCase struct {
+		Input     []float64
+		ExpectErr bool
+	}
+	testCases := []testCase{
+		{Input: DefaultCapacityForecastThresholds, ExpectErr: false},
+		{Input: []float64{50, 100}, ExpectErr: false},
+		{Input: []float64{-5, 85}, ExpectErr: true},
+		{Input: []float64{0}, ExpectErr: true},
+		{Input: []float64{70, 120}, ExpectErr: true},
+		{Input: []float64{95, 85}, ExpectErr: true},
+		{Input: []float64{85, 85}, ExpectErr: true},
+		{Input: []float64{math.NaN()}, ExpectErr: true},
+	}
+	for _, tc := range testCases {
+		if err := ValidateCapacityForecastThresholds(tc.Input); err != nil && !tc.ExpectErr {
+			t.Errorf("Expected: no error for %v, actual: %v", tc.Input, err)
+		} else if err == nil && tc.ExpectErr {
+			t.Errorf("Expected: non-nil error for %v, actual: nil", tc.Input)
+		}
+	}
+}
+
+func TestValidateOIDC(t *testing.T) {
+	type testCase struct {
+		Input     ConfigOIDC
+		ExpectErr bool
+	}
+	testCases := []testCase{
+		{
+			Input:     ConfigOIDC{IssuerURL: "https://idp.example.test/realms/cdn", ClientID: "traffic-ops", RedirectURL: "https://to.example.test/api/4.0/user/login/oidc/callback"},
+			ExpectErr: false,
+		},
+		{
+			Input:     ConfigOIDC{ClientID: "traffic-ops", RedirectURL: "https://to.example.test/api/4.0/user/login/oidc/callback"},
+			ExpectErr: true,
+		},
+		{
+			Input:     ConfigOIDC{IssuerURL: "idp.example.test", ClientID: "traffic-ops", RedirectURL: "https://to.example.test/api/4.0/user/login/oidc/callback"},
+			ExpectErr: true,
+		},
+		{
+			Input: ConfigOIDC{
+				IssuerURL:     "https://idp.example.test",
+				ClientID:      "traffic-ops",
+				RedirectURL:   "https://to.example.test/api/4.0/user/login/oidc/callback",
+				GroupMappings: []OIDCGroupMapping{{Group: "cdn-admins"}},
+			},
+			ExpectErr: true,
+		},
+		{
+			Input: ConfigOIDC{
+				IssuerURL:   "https://idp.example.test",
+				ClientID:    "traffic-ops",
+				RedirectURL: "https://to.example.test/api/4.0/user/login/oidc/callback",
+				DefaultRole: "read-only",
+			},
+			ExpectErr: true,
+		},
+	}
+	for i := range testCases {
+		tc := &testCases[i]
+		if err := ValidateOIDC(&tc.Input); err != nil && !tc.ExpectErr {
+			t.Errorf("Expected: no error, actual: %v", err)
+		} else if err == nil && tc.ExpectErr {
+			t.Errorf("Expected: non-nil error, actual: nil")
+		}
+	}
+
+	defaulted := testCases[0].Input
+	if defaulted.UsernameClaim != DefaultOIDCUsernameClaim || defaulted.GroupsClaim != DefaultOIDCGroupsClaim || len(defaulted.Scopes) != len(DefaultOIDCScopes) || defaulted.JWKSRefreshIntervalSeconds != DefaultOIDCJWKSRefreshIntervalSeconds {
+		t.Errorf("Expected: defaults to be set, actual: %+v", defaulted)
+	}
+}
@@ -0,0 +1,212 @@
+package crconfig
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+)
+
+// Diff returns the semantic difference between the CRConfigs old and new.
+func Diff(old, new *tc.CRConfig) (tc.CRConfigDiff, error) {
+	diff := tc.CRConfigDiff{}
+	sections := []struct {
+		name     string
+		old      interface{}
+		new      interface{}
+		sectDiff *tc.CRConfigSectionDiff
+	}{
+		{"config", old.Config, new.Config, &diff.Config},
+		{"contentRouters", old.ContentRouters, new.ContentRouters, &diff.ContentRouters},
+		{"contentServers", old.ContentServers, new.ContentServers, &diff.ContentServers},
+		{"deliveryServices", old.DeliveryServices, new.DeliveryServices, &diff.DeliveryServices},
+		{"edgeLocations", old.EdgeLocations, new.EdgeLocations, &diff.EdgeLocations},
+		{"monitors", old.Monitors, new.Monitors, &diff.Monitors},
+		{"trafficRouterLocations", old.RouterLocations, new.RouterLocations, &diff.RouterLocations},
+		{"topologies", old.Topologies, new.Topologies, &diff.Topologies},
+	}
+	for _, section := range sections {
+		oldGeneric, err := toGenericMap(section.old)
+		if err != nil {
+			return tc.CRConfigDiff{}, fmt.Errorf("converting old %s: %v", section.name, err)
+		}
+		newGeneric, err := toGenericMap(section.new)
+		if err != nil {
+			return tc.CRConfigDiff{}, fmt.Errorf("converting new %s: %v", section.name, err)
+		}
+		*section.sectDiff = diffSection(oldGeneric, newGeneric)
+	}
+	return diff, nil
+}
+
+// toGenericMap converts a CRConfig section to the generic form it has when
+// decoded from JSON, so that entries are compared exactly as they would be
+// serialized, and fields are named by their JSON keys.
+func toGenericMap(section interface{}) (map[string]interface{}, error) {
+	bts, err := json.Marshal(section)
+	if err != nil {
+		return nil, errors.New("marshalling: " + err.Error())
+	}
+	generic := map[string]interface{}{}
+	if string(bts) == "null" {
+		return generic, nil
+	}
+	if err := json.Unmarshal(bts, &generic); err != nil {
+		return nil, errors.New("unmarshalling: " + err.Error())
+	}
+	return generic, nil
+}
+
+// diffSection returns the difference between two generic CRConfig sections.
+// Names in the diff are sorted, so it is deterministic.
+func diffSection(old, new map[string]interface{}) tc.CRConfigSectionDiff {
+	diff := tc.CRConfigSectionDiff{Added: []string{}, Removed: []string{}, Changed: []tc.CRConfigChange{}}
+	for name, newVal := range new {
+		oldVal, ok := old[name]
+		if !ok {
+			diff.Added = append(diff.Added, name)
+			continue
+		}
+		if fields := diffValues("", oldVal, newVal, nil); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, tc.CRConfigChange{Name: name, Fields: fields})
+		}
+	}
+	for name := range old {
+		if _, ok := new[name]; !ok {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Name < diff.Changed[j].Name })
+	return diff
+}
+
+// diffValues appends the changes between the generic values old and new at
+// the given field path to changes, and returns it. Objects are compared
+// field-by-field; any other values, including arrays, are compared whole.
+func diffValues(path string, old, new interface{}, changes []tc.CRConfigFieldChange) []tc.CRConfigFieldChange {
+	oldObj, oldIsObj := old.(map[string]interface{})
+	newObj, newIsObj := new.(map[string]interface{})
+	if !oldIsObj || !newIsObj {
+		if !reflect.DeepEqual(old, new) {
+			changes = append(changes, tc.CRConfigFieldChange{Field: path, Old: old, New: new})
+		}
+		return changes
+	}
+
+	keys := make([]string, 0, len(oldObj)+len(newObj))
+	for key := range oldObj {
+		keys = append(keys, key)
+	}
+	for key := range newObj {
+		if _, ok := oldObj[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+		changes = diffValues(fieldPath, oldObj[key], newObj[key], changes)
+	}
+	return changes
+}
+
+// WriteDiffText writes a human-readable form of diff to w, in which added,
+// removed, and changed entries are prefixed with '+', '-', and '~'
+// respectively.
+func WriteDiffText(w io.Writer, diff tc.CRConfigDiff) error {
+	sections := []struct {
+		name string
+		diff tc.CRConfigSectionDiff
+	}{
+		{"config", diff.Config},
+		{"contentRouters", diff.ContentRouters},
+		{"contentServers", diff.ContentServers},
+		{"deliveryServices", diff.DeliveryServices},
+		{"edgeLocations", diff.EdgeLocations},
+		{"monitors", diff.Monitors},
+		{"trafficRouterLocations", diff.RouterLocations},
+		{"topologies", diff.Topologies},
+	}
+	empty := true
+	for _, section := range sections {
+		if section.diff.Empty() {
+			continue
+		}
+		empty = false
+		if _, err := fmt.Fprintf(w, "%s:\n", section.name); err != nil {
+			return err
+		}
+		for _, name := range section.diff.Added {
+			if _, err := fmt.Fprintf(w, "  + %s\n", name); err != nil {
+				return err
+			}
+		}
+		for _, name := range section.diff.Removed {
+			if _, err := fmt.Fprintf(w, "  - %s\n", name); err != nil {
+				return err
+			}
+		}
+		for _, change := range section.diff.Changed {
+			if len(change.Fields) == 1 && change.Fields[0].Field == "" {
+				if _, err := fmt.Fprintf(w, "  ~ %s: %s -> %s\n", change.Name, textValue(change.Fields[0].Old), textValue(change.Fields[0].New)); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "  ~ %s\n", change.Name); err != nil {
+				return err
+			}
+			for _, field := range change.Fields {
+				if _, err := fmt.Fprintf(w, "      %s: %s -> %s\n", field.Field, textValue(field.Old), textValue(field.New)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if empty {
+		_, err := io.WriteString(w, "no changes\n")
+		return err
+	}
+	return nil
+}
+
+// textValue returns the JSON representation of a generic value, or
+// "(none)" for a field that doesn't exist.
+func textValue(v interface{}) string {
+	if v == nil {
+		return "(none)"
+	}
+	bts, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(bts)
+}
@@ -0,0 +1,166 @@
+package crconfig
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+)
+
+func diffTestCRConfigs() (*tc.CRConfig, *tc.CRConfig) {
+	old := &tc.CRConfig{
+		Config: map[string]interface{}{
+			"domain_name": "cdn.test",
+			"soa":         map[string]interface{}{"minimum": "30", "expire": "604800"},
+		},
+		ContentServers: map[string]tc.CRConfigTrafficOpsServer{
+			"edge1": {HashCount: util.IntPtr(999), CacheGroup: util.StrPtr("cg1")},
+			"edge2": {HashCount: util.IntPtr(999), CacheGroup: util.StrPtr("cg1")},
+		},
+		DeliveryServices: map[string]tc.CRConfigDeliveryService{
+			"ds1": {Domains: []string{"ds1.cdn.test"}, IP6RoutingEnabled: util.BoolPtr(false)},
+			"ds2": {Domains: []string{"ds2.cdn.test"}},
+		},
+		EdgeLocations: map[string]tc.CRConfigLatitudeLongitude{
+			"cg1": {Lat: 1, Lon: 2},
+		},
+		Stats: tc.CRConfigStats{DateUnixSeconds: util.Int64Ptr(1)},
+	}
+	new := &tc.CRConfig{
+		Config: map[string]interface{}{
+			"domain_name": "cdn.test",
+			"soa":         map[string]interface{}{"minimum": "60", "expire": "604800"},
+			"ttls":        map[string]interface{}{"A": "3600"},
+		},
+		ContentServers: map[string]tc.CRConfigTrafficOpsServer{
+			"edge1": {HashCount: util.IntPtr(999), CacheGroup: util.StrPtr("cg1")},
+			"edge3": {HashCount: util.IntPtr(999), CacheGroup: util.StrPtr("cg2")},
+		},
+		DeliveryServices: map[string]tc.CRConfigDeliveryService{
+			"ds1": {Domains: []string{"ds1.cdn.test", "ds1-alias.cdn.test"}, IP6RoutingEnabled: util.BoolPtr(true)},
+			"ds2": {Domains: []string{"ds2.cdn.test"}},
+		},
+		EdgeLocations: map[string]tc.CRConfigLatitudeLongitude{
+			"cg1": {Lat: 1, Lon: 2},
+			"cg2": {Lat: 3, Lon: 4},
+		},
+		Topologies: map[string]tc.CRConfigTopology{
+			"top1": {Nodes: []string{"cg1", "cg2"}},
+		},
+		Stats: tc.CRConfigStats{DateUnixSeconds: util.Int64Ptr(2)},
+	}
+	return old, new
+}
+
+func TestDiff(t *testing.T) {
+	old, new := diffTestCRConfigs()
+	diff, err := Diff(old, new)
+	if err != nil {
+		t.Fatalf("Diff: unexpected error: %v", err)
+	}
+
+	expectedConfig := tc.CRConfigSectionDiff{
+		Added:   []string{"ttls"},
+		Removed: []string{},
+		Changed: []tc.CRConfigChange{{Name: "soa", Fields: []tc.CRConfigFieldChange{{Field: "minimum", Old: "30", New: "60"}}}},
+	}
+	if !reflect.DeepEqual(diff.Config, expectedConfig) {
+		t.Errorf("config: expected %+v, actual %+v", expectedConfig, diff.Config)
+	}
+
+	expectedServers := tc.CRConfigSectionDiff{Added: []string{"edge3"}, Removed: []string{"edge2"}, Changed: []tc.CRConfigChange{}}
+	if !reflect.DeepEqual(diff.ContentServers, expectedServers) {
+		t.Errorf("contentServers: expected %+v, actual %+v", expectedServers, diff.ContentServers)
+	}
+
+	expectedDSes := tc.CRConfigSectionDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []tc.CRConfigChange{{Name: "ds1", Fields: []tc.CRConfigFieldChange{
+			{Field: "domains", Old: []interface{}{"ds1.cdn.test"}, New: []interface{}{"ds1.cdn.test", "ds1-alias.cdn.test"}},
+			{Field: "ip6RoutingEnabled", Old: "false", New: "true"},
+		}}},
+	}
+	if !reflect.DeepEqual(diff.DeliveryServices, expectedDSes) {
+		t.Errorf("deliveryServices: expected %+v, actual %+v", expectedDSes, diff.DeliveryServices)
+	}
+
+	if len(diff.EdgeLocations.Added) != 1 || diff.EdgeLocations.Added[0] != "cg2" {
+		t.Errorf("edgeLocations: expected cg2 added, actual %+v", diff.EdgeLocations)
+	}
+	if len(diff.Topologies.Added) != 1 || diff.Topologies.Added[0] != "top1" {
+		t.Errorf("topologies: expected top1 added, actual %+v", diff.Topologies)
+	}
+	if !diff.Monitors.Empty() || !diff.ContentRouters.Empty() || !diff.RouterLocations.Empty() {
+		t.Errorf("expected no changes to monitors, routers, or router locations, actual %+v", diff)
+	}
+}
+
+func TestDiffIdentical(t *testing.T) {
+	old, _ := diffTestCRConfigs()
+	same, _ := diffTestCRConfigs()
+	same.Stats.DateUnixSeconds = util.Int64Ptr(2)
+	diff, err := Diff(old, same)
+	if err != nil {
+		t.Fatalf("Diff: unexpected error: %v", err)
+	}
+	buf := bytes.Buffer{}
+	if err := WriteDiffText(&buf, diff); err != nil {
+		t.Fatalf("WriteDiffText: unexpected error: %v", err)
+	}
+	if buf.String() != "no changes\n" {
+		t.Errorf("expected no changes, actual:\n%s", buf.String())
+	}
+}
+
+func TestWriteDiffText(t *testing.T) {
+	old, new := diffTestCRConfigs()
+	diff, err := Diff(old, new)
+	if err != nil {
+		t.Fatalf("Diff: unexpected error: %v", err)
+	}
+	buf := bytes.Buffer{}
+	if err := WriteDiffText(&buf, diff); err != nil {
+		t.Fatalf("WriteDiffText: unexpected error: %v", err)
+	}
+	expected := `config:
+  + ttls
+  ~ soa
+      minimum: "30" -> "60"
+contentServers:
+  + edge3
+  - edge2
+deliveryServices:
+  ~ ds1
+      domains: ["ds1.cdn.test"] -> ["ds1.cdn.test","ds1-alias.cdn.test"]
+      ip6RoutingEnabled: "false" -> "true"
+edgeLocations:
+  + cg2
+topologies:
+  + top1
+`
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+}
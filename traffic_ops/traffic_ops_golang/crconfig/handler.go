@@ -20,6 +20,8 @@ package crconfig
  */
 
 import (
+	"bytes"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -31,9 +33,12 @@ import (
 	"github.com/apache/trafficcontrol/lib/go-rfc"
 	"github.com/apache/trafficcontrol/lib/go-tc"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/asyncjob"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/deliveryservice"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/metrics"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/monitoring"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/webhook"
 	client "github.com/apache/trafficcontrol/traffic_ops/v1-client"
 )
 
@@ -89,6 +94,64 @@ func SnapshotGetHandler(w http.ResponseWriter, r *http.Request) {
 	api.WriteResp(w, r, decoded)
 }
 
+// DiffHandler serves the difference between the CDN's current Snapshot and
+// the CRConfig that would be produced by taking a new one. It is served as
+// JSON, or as text if the 'format' query parameter is "text".
+func DiffHandler(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"cdn"}, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	cdn := inf.Params["cdn"]
+	format := inf.Params["format"]
+	if format != "" && format != "json" && format != "text" {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, errors.New("format must be 'json' or 'text'"), nil)
+		return
+	}
+
+	snapshot, cdnExists, err := GetSnapshot(inf.Tx.Tx, cdn)
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("getting snapshot: "+err.Error()))
+		return
+	}
+	if !cdnExists {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusNotFound, errors.New("CDN not found"), nil)
+		return
+	}
+	current := tc.CRConfig{}
+	if err := json.Unmarshal([]byte(snapshot), &current); err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, fmt.Errorf("failed to unmarshal stored snapshot for cdn '%s': %v", cdn, err))
+		return
+	}
+
+	pending, err := Make(inf.Tx.Tx, cdn, inf.User.UserName, r.Host, inf.Config.Version, inf.Config.CRConfigUseRequestHost, false)
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+
+	diff, err := Diff(&current, pending)
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("diffing snapshots: "+err.Error()))
+		return
+	}
+	if format != "text" {
+		api.WriteResp(w, r, diff)
+		return
+	}
+
+	buf := bytes.Buffer{}
+	if err := WriteDiffText(&buf, diff); err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("writing snapshot diff text: "+err.Error()))
+		return
+	}
+	w.Header().Set(rfc.ContentType, rfc.ContentTypeTextPlain)
+	w.Write(buf.Bytes())
+}
+
 func SnapshotGetMonitoringLegacyHandler(w http.ResponseWriter, r *http.Request) {
 	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"cdn"}, nil)
 	if userErr != nil || sysErr != nil {
@@ -159,18 +222,38 @@ func SnapshotOldGetHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(snapshot))
 }
 
+// snapshotJobType is the type of the asynchronous jobs which take Snapshots.
+const snapshotJobType = "snapshot"
+
+func init() {
+	asyncjob.Register(snapshotJobType, runSnapshotJob)
+}
+
+// snapshotJobPayload is the payload of a snapshot job.
+type snapshotJobPayload struct {
+	CDN     string  `json:"cdn"`
+	CDNID   int     `json:"cdnID"`
+	Host    string  `json:"host"`
+	Comment *string `json:"comment,omitempty"`
+}
+
 // SnapshotHandler creates the CRConfig JSON and writes it to the snapshot table in the database.
 func SnapshotHandler(w http.ResponseWriter, r *http.Request) {
-	snapshotHandler(w, r, false)
+	snapshotHandler(w, r, false, false)
+}
+
+// SnapshotHandlerV40 queues an asynchronous job which creates the CRConfig JSON and writes it to the snapshot table in the database.
+func SnapshotHandlerV40(w http.ResponseWriter, r *http.Request) {
+	snapshotHandler(w, r, false, true)
 }
 
 // SnapshotHandlerDeprecated creates the CRConfig JSON and writes it to the snapshot table in the database for deprecated routes.
 func SnapshotHandlerDeprecated(w http.ResponseWriter, r *http.Request) {
-	snapshotHandler(w, r, true)
+	snapshotHandler(w, r, true, false)
 }
 
-// SnapshotHandler creates the CRConfig JSON and writes it to the snapshot table in the database.
-func snapshotHandler(w http.ResponseWriter, r *http.Request, deprecated bool) {
+// SnapshotHandler creates the CRConfig JSON and writes it to the snapshot table in the database - or, if async is true, queues a job which does.
+func snapshotHandler(w http.ResponseWriter, r *http.Request, deprecated bool, async bool) {
 	alt := "PUT /snapshots with either the query parameter cdn or cdnID"
 	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, []string{"id", "cdnID"})
 	if userErr != nil || sysErr != nil {
@@ -224,29 +307,27 @@ func snapshotHandler(w http.ResponseWriter, r *http.Request, deprecated bool) {
 		}
 	}
 
-	// We never store tm_path, even though low API versions show it in responses.
-	crConfig, err := Make(inf.Tx.Tx, cdn, inf.User.UserName, r.Host, inf.Config.Version, inf.Config.CRConfigUseRequestHost, false)
-	if err != nil {
-		api.HandleErrOptionalDeprecation(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, err, deprecated, &alt)
-		return
-	}
-	monitoringJSON, err := monitoring.GetMonitoringJSON(inf.Tx.Tx, cdn)
-	if err != nil {
-		api.HandleErrOptionalDeprecation(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New(r.RemoteAddr+" getting monitoring.json data: "+err.Error()), deprecated, &alt)
-		return
+	var comment *string
+	if c, ok := inf.Params["comment"]; ok {
+		comment = &c
 	}
 
-	if err := Snapshot(inf.Tx.Tx, crConfig, monitoringJSON); err != nil {
-		api.HandleErrOptionalDeprecation(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New(r.RemoteAddr+" snaphsotting CRConfig and Monitoring: "+err.Error()), deprecated, &alt)
+	if async {
+		payload := snapshotJobPayload{CDN: cdn, CDNID: id, Host: r.Host, Comment: comment}
+		status, err := asyncjob.Enqueue(inf.Tx.Tx, snapshotJobType, inf.User, payload, "Snapshot of CDN "+cdn+" queued")
+		if err != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("queueing snapshot: "+err.Error()))
+			return
+		}
+		asyncjob.WriteAccepted(w, r, status, "Snapshot of CDN "+cdn)
 		return
 	}
 
-	if err := deliveryservice.DeleteOldCerts(db.DB, inf.Tx.Tx, inf.Config, tc.CDNName(cdn), inf.Vault); err != nil {
-		api.HandleErrOptionalDeprecation(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New(r.RemoteAddr+" snapshotting CRConfig and Monitoring: starting old certificate deletion job: "+err.Error()), deprecated, &alt)
+	if err := takeSnapshot(db.DB, inf, cdn, id, r.Host, comment, func(int, string) {}); err != nil {
+		api.HandleErrOptionalDeprecation(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New(r.RemoteAddr+" "+err.Error()), deprecated, &alt)
 		return
 	}
 
-	api.CreateChangeLogRawTx(api.ApiChange, "CDN: "+cdn+", ID: "+strconv.Itoa(id)+", ACTION: Snapshot of CRConfig and Monitor", inf.User, inf.Tx.Tx)
 	if deprecated {
 		api.WriteAlertsObj(w, r, http.StatusOK, api.CreateDeprecationAlerts(&alt), "SUCCESS")
 		return
@@ -254,6 +335,57 @@ func snapshotHandler(w http.ResponseWriter, r *http.Request, deprecated bool) {
 	api.WriteResp(w, r, "SUCCESS")
 }
 
+// runSnapshotJob is the asyncjob.Func which takes a Snapshot.
+func runSnapshotJob(inf *api.APIInfo, job *asyncjob.Job) (string, error, error) {
+	payload := snapshotJobPayload{}
+	if err := job.DecodePayload(&payload); err != nil {
+		return "", nil, err
+	}
+	if err := takeSnapshot(job.DB().DB, inf, payload.CDN, payload.CDNID, payload.Host, payload.Comment, job.SetProgress); err != nil {
+		return "", nil, err
+	}
+	job.SetResult("SUCCESS")
+	return "Snapshot of CDN " + payload.CDN + " taken", nil, nil
+}
+
+// takeSnapshot creates the CRConfig and monitoring JSON of the given CDN,
+// and writes them to the snapshot table in the database, reporting its
+// progress to the given function. All returned errors are system errors.
+func takeSnapshot(db *sql.DB, inf *api.APIInfo, cdn string, id int, host string, comment *string, progress func(int, string)) error {
+	tx := inf.Tx.Tx
+	// We never store tm_path, even though low API versions show it in responses.
+	start := time.Now()
+	progress(10, "Generating CRConfig")
+	crConfig, err := Make(tx, cdn, inf.User.UserName, host, inf.Config.Version, inf.Config.CRConfigUseRequestHost, false)
+	if err != nil {
+		return err
+	}
+	progress(50, "Generating monitoring configuration")
+	monitoringJSON, err := monitoring.GetMonitoringJSON(tx, cdn)
+	if err != nil {
+		return errors.New("getting monitoring.json data: " + err.Error())
+	}
+
+	progress(70, "Saving Snapshot")
+	if err := Snapshot(tx, crConfig, monitoringJSON); err != nil {
+		return errors.New("snaphsotting CRConfig and Monitoring: " + err.Error())
+	}
+	metrics.SnapshotDuration.ObserveSince(start, cdn)
+
+	if _, err := AddSnapshotHistory(tx, cdn, inf.User.UserName, comment, inf.Config.SnapshotHistoryLength); err != nil {
+		return errors.New("snapshotting CRConfig and Monitoring: " + err.Error())
+	}
+
+	progress(90, "Removing old certificates")
+	if err := deliveryservice.DeleteOldCerts(db, tx, inf.Config, tc.CDNName(cdn), inf.Vault); err != nil {
+		return errors.New("snapshotting CRConfig and Monitoring: starting old certificate deletion job: " + err.Error())
+	}
+
+	api.CreateChangeLogRawTx(api.ApiChange, "CDN: "+cdn+", ID: "+strconv.Itoa(id)+", ACTION: Snapshot of CRConfig and Monitor", inf.User, tx)
+	webhook.Enqueue(tx, tc.WebhookEventSnapshot, inf.User, tc.WebhookSnapshotData{CDN: cdn, CDNID: id})
+	return nil
+}
+
 // SnapshotOldGUIHandler creates the CRConfig JSON and writes it to the snapshot table in the database. The response emulates the old Perl UI function. This should go away when the old Perl UI ceases to exist.
 func SnapshotOldGUIHandler(w http.ResponseWriter, r *http.Request) {
 	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"cdn"}, nil)
@@ -270,12 +402,13 @@ func SnapshotOldGUIHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	cdn := inf.Params["cdn"]
-	_, exists, _ := dbhelpers.GetCDNIDFromName(inf.Tx.Tx, tc.CDNName(cdn))
+	cdnID, exists, _ := dbhelpers.GetCDNIDFromName(inf.Tx.Tx, tc.CDNName(cdn))
 	if !exists {
 		api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, errors.New("unable to find the CDN: "+cdn), nil)
 		return
 	}
 	// We never store tm_path, even though low API versions show it in responses.
+	start := time.Now()
 	crConfig, err := Make(inf.Tx.Tx, cdn, inf.User.UserName, r.Host, inf.Config.Version, inf.Config.CRConfigUseRequestHost, false)
 	if err != nil {
 		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New(r.RemoteAddr+" making CRConfig: "+err.Error()))
@@ -292,6 +425,12 @@ func SnapshotOldGUIHandler(w http.ResponseWriter, r *http.Request) {
 		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New(r.RemoteAddr+" making CRConfig: "+err.Error()))
 		return
 	}
+	metrics.SnapshotDuration.ObserveSince(start, cdn)
+
+	if _, err := AddSnapshotHistory(inf.Tx.Tx, cdn, inf.User.UserName, nil, inf.Config.SnapshotHistoryLength); err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New(r.RemoteAddr+" making CRConfig: "+err.Error()))
+		return
+	}
 
 	if err := deliveryservice.DeleteOldCerts(db.DB, inf.Tx.Tx, inf.Config, tc.CDNName(cdn), inf.Vault); err != nil {
 		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New(r.RemoteAddr+" old snapshotting CRConfig and Monitoring: starting old certificate deletion job: "+err.Error()))
@@ -299,5 +438,6 @@ func SnapshotOldGUIHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	api.CreateChangeLogRawTx(api.ApiChange, "Snapshot of CRConfig performed for "+cdn, inf.User, inf.Tx.Tx)
+	webhook.Enqueue(inf.Tx.Tx, tc.WebhookEventSnapshot, inf.User, tc.WebhookSnapshotData{CDN: cdn, CDNID: cdnID})
 	http.Redirect(w, r, client.API_v13_CDNs+"/"+cdn+"/snapshot", http.StatusFound)
 }
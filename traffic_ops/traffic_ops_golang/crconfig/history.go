@@ -0,0 +1,214 @@
+package crconfig
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/webhook"
+)
+
+// addSnapshotHistoryQuery copies the CDN's current Snapshot into its history.
+const addSnapshotHistoryQuery = `
+INSERT INTO snapshot_history (cdn, crconfig, monitoring, username, comment, created)
+SELECT cdn, crconfig, monitoring, $2, $3, last_updated
+FROM snapshot
+WHERE cdn = $1
+RETURNING id, cdn, username, comment, created
+`
+
+const pruneSnapshotHistoryQuery = `
+DELETE FROM snapshot_history
+WHERE cdn = $1 AND id NOT IN (
+	SELECT id FROM snapshot_history WHERE cdn = $1 ORDER BY id DESC LIMIT $2
+)
+`
+
+const selectSnapshotHistoryQuery = `
+SELECT id, cdn, username, comment, created
+FROM snapshot_history
+WHERE cdn = $1
+ORDER BY id DESC
+`
+
+const selectHistorySnapshotQuery = `
+SELECT crconfig, monitoring
+FROM snapshot_history
+WHERE id = $1 AND cdn = $2
+`
+
+// AddSnapshotHistory records the CDN's current Snapshot, which must have
+// just been taken by the user with the given username, in its history, and
+// deletes all but the newest keep Snapshots from the history.
+func AddSnapshotHistory(tx *sql.Tx, cdn string, user string, comment *string, keep int) (tc.SnapshotHistoryEntry, error) {
+	entry := tc.SnapshotHistoryEntry{Current: true}
+	if err := tx.QueryRow(addSnapshotHistoryQuery, cdn, user, comment).Scan(&entry.ID, &entry.CDN, &entry.User, &entry.Comment, &entry.Created); err != nil {
+		return tc.SnapshotHistoryEntry{}, errors.New("inserting snapshot history: " + err.Error())
+	}
+	if _, err := tx.Exec(pruneSnapshotHistoryQuery, cdn, keep); err != nil {
+		return tc.SnapshotHistoryEntry{}, errors.New("pruning snapshot history: " + err.Error())
+	}
+	return entry, nil
+}
+
+// GetSnapshotHistory returns the Snapshot history of the given CDN, newest
+// first. The newest entry is the CDN's current Snapshot.
+func GetSnapshotHistory(tx *sql.Tx, cdn string) ([]tc.SnapshotHistoryEntry, error) {
+	rows, err := tx.Query(selectSnapshotHistoryQuery, cdn)
+	if err != nil {
+		return nil, errors.New("querying snapshot history: " + err.Error())
+	}
+	defer log.Close(rows, "closing snapshot history rows")
+	entries := []tc.SnapshotHistoryEntry{}
+	for rows.Next() {
+		entry := tc.SnapshotHistoryEntry{}
+		if err := rows.Scan(&entry.ID, &entry.CDN, &entry.User, &entry.Comment, &entry.Created); err != nil {
+			return nil, errors.New("scanning snapshot history: " + err.Error())
+		}
+		entry.Current = len(entries) == 0
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// restoreSnapshot makes the given historical CRConfig and monitoring
+// Snapshots current for the CDN. The CRConfig's date and user are updated,
+// because Traffic Router ignores Snapshots older than the one it has.
+func restoreSnapshot(tx *sql.Tx, cdn string, crConfigJSON []byte, monitoringJSON []byte, user string) error {
+	crc := tc.CRConfig{}
+	if err := json.Unmarshal(crConfigJSON, &crc); err != nil {
+		return errors.New("unmarshalling CRConfig: " + err.Error())
+	}
+	now := time.Now()
+	crc.Stats.DateUnixSeconds = new(int64)
+	*crc.Stats.DateUnixSeconds = now.Unix()
+	crc.Stats.TMUser = &user
+	bts, err := json.Marshal(crc)
+	if err != nil {
+		return errors.New("marshalling CRConfig: " + err.Error())
+	}
+	if _, err := tx.Exec(upsertSnapshotQuery, cdn, bts, now, monitoringJSON); err != nil {
+		return errors.New("writing snapshot: " + err.Error())
+	}
+	return nil
+}
+
+// HistoryHandler is the handler for GET requests to /cdns/{{Name}}/snapshots.
+func HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"cdn"}, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	cdn := inf.Params["cdn"]
+	if ok, err := dbhelpers.CDNExists(cdn, inf.Tx.Tx); err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("checking CDN existence: "+err.Error()))
+		return
+	} else if !ok {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusNotFound, errors.New("CDN not found"), nil)
+		return
+	}
+
+	history, err := GetSnapshotHistory(inf.Tx.Tx, cdn)
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	api.WriteResp(w, r, history)
+}
+
+// RestoreHandler is the handler for POST requests to
+// /cdns/{{Name}}/snapshots/{{ID}}/restore, which makes the historical
+// Snapshot with the given ID current again.
+func RestoreHandler(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"cdn", "id"}, []string{"id"})
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	cdn := inf.Params["cdn"]
+	id := inf.IntParams["id"]
+
+	req := tc.SnapshotRestoreRequest{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.HandleErr(w, r, tx, http.StatusBadRequest, errors.New("malformed request body: "+err.Error()), nil)
+			return
+		}
+	}
+
+	cdnID, ok, err := dbhelpers.GetCDNIDFromName(tx, tc.CDNName(cdn))
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("getting CDN ID from name: "+err.Error()))
+		return
+	}
+	if !ok {
+		api.HandleErr(w, r, tx, http.StatusNotFound, errors.New("CDN not found"), nil)
+		return
+	}
+	if userErr, sysErr, errCode := dbhelpers.CheckIfCurrentUserCanModifyCDN(tx, cdn, inf.User.UserName); userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	var crConfigJSON, monitoringJSON []byte
+	if err := tx.QueryRow(selectHistorySnapshotQuery, id, cdn).Scan(&crConfigJSON, &monitoringJSON); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no snapshot #%d exists for CDN %s", id, cdn), nil)
+			return
+		}
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("getting snapshot #%d: %v", id, err))
+		return
+	}
+	if err := restoreSnapshot(tx, cdn, crConfigJSON, monitoringJSON, inf.User.UserName); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("restoring snapshot #%d: %v", id, err))
+		return
+	}
+
+	comment := req.Comment
+	if comment == nil {
+		comment = new(string)
+		*comment = "restored snapshot #" + strconv.Itoa(id)
+	}
+	entry, err := AddSnapshotHistory(tx, cdn, inf.User.UserName, comment, inf.Config.SnapshotHistoryLength)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, fmt.Sprintf("Snapshot #%d restored", id), entry)
+	api.CreateChangeLogRawTx(api.ApiChange, fmt.Sprintf("CDN: %s, ID: %d, ACTION: Restored Snapshot #%d", cdn, cdnID, id), inf.User, tx)
+	webhook.Enqueue(tx, tc.WebhookEventSnapshot, inf.User, tc.WebhookSnapshotData{CDN: cdn, CDNID: cdnID})
+}
@@ -0,0 +1,157 @@
+package crconfig
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestAddSnapshotHistory(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	created := time.Now()
+	comment := "adding ds1"
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO snapshot_history").WithArgs("cdn1", "bob", comment).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "cdn", "username", "comment", "created"}).AddRow(7, "cdn1", "bob", comment, created))
+	mock.ExpectExec("DELETE FROM snapshot_history").WithArgs("cdn1", 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := mockDB.Begin()
+	if err != nil {
+		t.Fatalf("creating transaction: %v", err)
+	}
+	entry, err := AddSnapshotHistory(tx, "cdn1", "bob", &comment, 5)
+	if err != nil {
+		t.Fatalf("AddSnapshotHistory err expected: nil, actual: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("committing transaction: %v", err)
+	}
+	if entry.ID != 7 || !entry.Current || entry.User == nil || *entry.User != "bob" || entry.Comment == nil || *entry.Comment != comment {
+		t.Errorf("unexpected history entry: %+v", entry)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %v", err)
+	}
+}
+
+func TestGetSnapshotHistory(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM snapshot_history").WithArgs("cdn1").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "cdn", "username", "comment", "created"}).
+			AddRow(3, "cdn1", "bob", nil, now).
+			AddRow(2, "cdn1", "alice", "first", now.Add(-time.Hour)).
+			AddRow(1, "cdn1", nil, nil, now.Add(-2*time.Hour)))
+
+	tx, err := mockDB.Begin()
+	if err != nil {
+		t.Fatalf("creating transaction: %v", err)
+	}
+	history, err := GetSnapshotHistory(tx, "cdn1")
+	if err != nil {
+		t.Fatalf("GetSnapshotHistory err expected: nil, actual: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected 3 history entries, actual %d", len(history))
+	}
+	for i, entry := range history {
+		if entry.Current != (i == 0) {
+			t.Errorf("entry #%d: expected current %t, actual %t", entry.ID, i == 0, entry.Current)
+		}
+	}
+	if history[2].User != nil {
+		t.Errorf("expected no user for the oldest entry, actual %s", *history[2].User)
+	}
+}
+
+// crConfigDateMatcher matches a marshalled CRConfig whose date and user show
+// it was restored after the given time by the given user.
+type crConfigDateMatcher struct {
+	after int64
+	user  string
+}
+
+func (m crConfigDateMatcher) Match(v driver.Value) bool {
+	bts, ok := v.([]byte)
+	if !ok {
+		return false
+	}
+	crc := tc.CRConfig{}
+	if err := json.Unmarshal(bts, &crc); err != nil {
+		return false
+	}
+	return crc.Stats.DateUnixSeconds != nil && *crc.Stats.DateUnixSeconds >= m.after &&
+		crc.Stats.TMUser != nil && *crc.Stats.TMUser == m.user &&
+		len(crc.DeliveryServices) == 1
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	oldUser := "alice"
+	oldDate := int64(1)
+	old := tc.CRConfig{
+		DeliveryServices: map[string]tc.CRConfigDeliveryService{"ds1": {}},
+		Stats:            tc.CRConfigStats{DateUnixSeconds: &oldDate, TMUser: &oldUser},
+	}
+	crConfigJSON, err := json.Marshal(old)
+	if err != nil {
+		t.Fatalf("marshalling CRConfig: %v", err)
+	}
+	monitoringJSON := []byte(`{"trafficServers":[]}`)
+
+	start := time.Now().Unix()
+	mock.ExpectBegin()
+	mock.ExpectExec("insert into snapshot").WithArgs("cdn1", crConfigDateMatcher{after: start, user: "bob"}, sqlmock.AnyArg(), monitoringJSON).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	tx, err := mockDB.Begin()
+	if err != nil {
+		t.Fatalf("creating transaction: %v", err)
+	}
+	if err := restoreSnapshot(tx, "cdn1", crConfigJSON, monitoringJSON, "bob"); err != nil {
+		t.Fatalf("restoreSnapshot err expected: nil, actual: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %v", err)
+	}
+}
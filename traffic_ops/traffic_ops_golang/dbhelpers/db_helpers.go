@@ -408,6 +408,26 @@ func GetProfileNameFromID(id int, tx *sql.Tx) (string, bool, error) {
 	return name, true, nil
 }
 
+// GetProfileNameAndCDNFromID returns the name of the profile with the given
+// ID and the name of the CDN to which it belongs, whether such a profile
+// exists, and any error.
+func GetProfileNameAndCDNFromID(tx *sql.Tx, id int) (string, tc.CDNName, bool, error) {
+	name := ""
+	cdn := tc.CDNName("")
+	if err := tx.QueryRow(`
+SELECT p.name, cdn.name
+FROM profile AS p
+JOIN cdn ON cdn.id = p.cdn
+WHERE p.id = $1
+`, id).Scan(&name, &cdn); err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", false, nil
+		}
+		return "", "", false, errors.New("querying profile name and cdn from id: " + err.Error())
+	}
+	return name, cdn, true, nil
+}
+
 // GetProfileIDFromName returns the profile's ID, whether a profile with name exists, or any error.
 func GetProfileIDFromName(name string, tx *sql.Tx) (int, bool, error) {
 	id := 0
@@ -1031,6 +1051,18 @@ func GetTypeIDByName(t string, tx *sql.Tx) (int, bool, error) {
 	return id, true, nil
 }
 
+// GetTenantIDFromName returns the ID of the Tenant with the given name, whether it exists, and any error.
+func GetTenantIDFromName(name string, tx *sql.Tx) (int, bool, error) {
+	id := 0
+	if err := tx.QueryRow(`SELECT id FROM tenant WHERE name = $1`, name).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, errors.New("querying tenant id from name: " + err.Error())
+	}
+	return id, true, nil
+}
+
 // GetUserByEmail retrieves the user with the given email. If no such user exists, the boolean
 // returned will be 'false', while the error indicates unexpected errors that occurred when querying.
 func GetUserByEmail(email string, tx *sql.Tx) (tc.User, bool, error) {
@@ -1223,3 +1255,129 @@ func CheckTopologyOrgServerCGInDSCG(tx *sql.Tx, cdnIds []int, dsTopology string,
 	}
 	return nil, nil, http.StatusOK
 }
+
+// CheckIfCurrentUserCanModifyCDN checks whether the user with the given
+// username may make changes to the CDN with the given name which affect what
+// is Snapshotted or queued, i.e. that no other user holds an unexpired lock on
+// the CDN as a whole. Both soft and hard locks prevent such changes.
+func CheckIfCurrentUserCanModifyCDN(tx *sql.Tx, cdn, user string) (error, error, int) {
+	return CheckIfCurrentUserCanModifyCDNObject(tx, cdn, tc.CDNLockScopeCDN, "", user)
+}
+
+// CheckIfCurrentUserCanModifyCDNObject checks whether the user with the given
+// username may modify the object of the given scope and name, i.e. that no
+// other user holds an unexpired lock on either the CDN with the given name as
+// a whole, or on that specific object within it. If cdn is empty - because
+// the object, e.g. a Topology, does not belong to any single CDN - locks on
+// the object in any CDN are checked instead.
+//
+// The returned user error names the holder of the conflicting lock.
+func CheckIfCurrentUserCanModifyCDNObject(tx *sql.Tx, cdn string, scope tc.CDNLockScope, name, user string) (error, error, int) {
+	query := `
+SELECT username, cdn, scope, name, message, expires
+FROM cdn_lock
+WHERE (expires IS NULL OR expires > now())
+AND ((cdn = $1 AND scope = '') OR (scope = $2 AND name = $3 AND ($1 = '' OR cdn = $1)))
+ORDER BY scope, cdn
+`
+	return checkCDNLocks(tx, scope.Description(name, cdn), user, query, cdn, scope, name)
+}
+
+// CheckIfCurrentUserCanModifyMultiCDNObject checks whether the user with the
+// given username may modify the object of the given scope and name which -
+// like a Topology or Cache Group - may span the CDNs with the given names,
+// i.e. that no other user holds an unexpired lock on any of those CDNs as a
+// whole, or on the object itself in any CDN.
+//
+// The returned user error names the holder of the conflicting lock.
+func CheckIfCurrentUserCanModifyMultiCDNObject(tx *sql.Tx, cdns []string, scope tc.CDNLockScope, name, user string) (error, error, int) {
+	query := `
+SELECT username, cdn, scope, name, message, expires
+FROM cdn_lock
+WHERE (expires IS NULL OR expires > now())
+AND ((cdn = ANY($1) AND scope = '') OR (scope = $2 AND name = $3))
+ORDER BY scope, cdn
+`
+	return checkCDNLocks(tx, scope.Description(name, ""), user, query, pq.Array(cdns), scope, name)
+}
+
+// checkCDNLocks returns a user error describing the first lock selected by
+// the given query which is held by a user other than the one given.
+func checkCDNLocks(tx *sql.Tx, description string, user string, query string, args ...interface{}) (error, error, int) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying cdn_lock for %s: %v", description, err), http.StatusInternalServerError
+	}
+	defer log.Close(rows, "closing cdn_lock rows")
+
+	for rows.Next() {
+		lock := tc.CDNLock{}
+		if err := rows.Scan(&lock.UserName, &lock.CDN, &lock.Scope, &lock.Name, &lock.Message, &lock.Expires); err != nil {
+			return nil, fmt.Errorf("scanning cdn_lock for %s: %v", description, err), http.StatusInternalServerError
+		}
+		if lock.UserName != user {
+			return errors.New(lock.ConflictMessage()), nil, http.StatusForbidden
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating over cdn_lock for %s: %v", description, err), http.StatusInternalServerError
+	}
+	return nil, nil, http.StatusOK
+}
+
+// GetTopologyCDNs returns the names of the CDNs which the Topology with the
+// given name touches: those of the Delivery Services assigned to it, and of
+// the servers in its Cache Groups or in any of the given Cache Groups, which
+// may be about to be added to it.
+func GetTopologyCDNs(tx *sql.Tx, name string, cacheGroups []string) ([]string, error) {
+	query := `
+SELECT cdn.name FROM deliveryservice ds
+JOIN cdn ON cdn.id = ds.cdn_id
+WHERE ds.topology = $1
+UNION
+SELECT cdn.name FROM server s
+JOIN cachegroup cg ON cg.id = s.cachegroup
+JOIN cdn ON cdn.id = s.cdn_id
+WHERE cg.name IN (SELECT tc.cachegroup FROM topology_cachegroup tc WHERE tc.topology = $1)
+OR cg.name = ANY($2)
+`
+	return getCDNNames(tx, query, name, pq.Array(cacheGroups))
+}
+
+// GetCacheGroupCDNs returns the names of the CDNs which the Cache Group with
+// the given name touches: those of its servers, and of the Delivery Services
+// assigned to Topologies which include it.
+func GetCacheGroupCDNs(tx *sql.Tx, name string) ([]string, error) {
+	query := `
+SELECT cdn.name FROM server s
+JOIN cachegroup cg ON cg.id = s.cachegroup
+JOIN cdn ON cdn.id = s.cdn_id
+WHERE cg.name = $1
+UNION
+SELECT cdn.name FROM deliveryservice ds
+JOIN topology_cachegroup tc ON tc.topology = ds.topology
+JOIN cdn ON cdn.id = ds.cdn_id
+WHERE tc.cachegroup = $1
+`
+	return getCDNNames(tx, query, name)
+}
+
+func getCDNNames(tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, errors.New("querying cdns: " + err.Error())
+	}
+	defer log.Close(rows, "closing cdn rows")
+	cdns := []string{}
+	for rows.Next() {
+		cdn := ""
+		if err := rows.Scan(&cdn); err != nil {
+			return nil, errors.New("scanning cdns: " + err.Error())
+		}
+		cdns = append(cdns, cdn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("iterating over cdns: " + err.Error())
+	}
+	return cdns, nil
+}
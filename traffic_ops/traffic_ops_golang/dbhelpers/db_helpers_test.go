@@ -21,7 +21,9 @@ package dbhelpers
 
 import (
 	"context"
+	"database/sql/driver"
 	"errors"
+	"net/http"
 	"reflect"
 	"strconv"
 	"strings"
@@ -408,3 +410,211 @@ func TestGetCDNIDFromName(t *testing.T) {
 	}
 
 }
+
+func TestCheckIfCurrentUserCanModifyCDN(t *testing.T) {
+	var testCases = []struct {
+		description  string
+		lockUser     string
+		storageError error
+		expectedCode int
+	}{
+		{
+			description:  "Success: CDN not locked",
+			expectedCode: http.StatusOK,
+		},
+		{
+			description:  "Success: CDN locked by the current user",
+			lockUser:     "bob",
+			expectedCode: http.StatusOK,
+		},
+		{
+			description:  "Failure: CDN locked by another user",
+			lockUser:     "alice",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			description:  "Failure: Storage error getting the lock",
+			storageError: errors.New("error getting the lock"),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.description, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer mockDB.Close()
+			db := sqlx.NewDb(mockDB, "sqlmock")
+			defer db.Close()
+
+			mock.ExpectBegin()
+			if testCase.storageError != nil {
+				mock.ExpectQuery("SELECT username, cdn, scope, name, message, expires FROM cdn_lock").WillReturnError(testCase.storageError)
+			} else {
+				rows := sqlmock.NewRows([]string{"username", "cdn", "scope", "name", "message", "expires"})
+				if testCase.lockUser != "" {
+					rows = rows.AddRow(testCase.lockUser, "cdn1", "", "", nil, nil)
+				}
+				mock.ExpectQuery("SELECT username, cdn, scope, name, message, expires FROM cdn_lock").WithArgs("cdn1", "", "").WillReturnRows(rows)
+			}
+
+			userErr, sysErr, code := CheckIfCurrentUserCanModifyCDN(db.MustBegin().Tx, "cdn1", "bob")
+			if code != testCase.expectedCode {
+				t.Errorf("expected code %d, actual %d", testCase.expectedCode, code)
+			}
+			if (code == http.StatusForbidden) != (userErr != nil) {
+				t.Errorf("expected a user error only for a forbidden modification, actual: %v", userErr)
+			}
+			if (code == http.StatusInternalServerError) != (sysErr != nil) {
+				t.Errorf("expected a system error only for a storage error, actual: %v", sysErr)
+			}
+		})
+	}
+}
+
+func TestCheckIfCurrentUserCanModifyCDNObject(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	var testCases = []struct {
+		description  string
+		cdn          string
+		locks        [][]driver.Value
+		expectedCode int
+		expectedErr  string
+	}{
+		{
+			description:  "Success: neither the CDN nor the delivery service is locked",
+			cdn:          "cdn1",
+			expectedCode: http.StatusOK,
+		},
+		{
+			description: "Success: delivery service locked by the current user",
+			cdn:         "cdn1",
+			locks: [][]driver.Value{
+				{"bob", "cdn1", "deliveryservice", "ds1", nil, nil},
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			description: "Failure: delivery service locked by another user",
+			cdn:         "cdn1",
+			locks: [][]driver.Value{
+				{"bob", "cdn1", "", "", nil, nil},
+				{"alice", "cdn1", "deliveryservice", "ds1", "moving origins", expires},
+			},
+			expectedCode: http.StatusForbidden,
+			expectedErr:  "user alice currently has a lock on delivery service ds1 in cdn cdn1 until",
+		},
+		{
+			description: "Failure: CDN locked by another user",
+			cdn:         "cdn1",
+			locks: [][]driver.Value{
+				{"alice", "cdn1", "", "", nil, nil},
+			},
+			expectedCode: http.StatusForbidden,
+			expectedErr:  "user alice currently has a lock on cdn cdn1",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.description, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer mockDB.Close()
+			db := sqlx.NewDb(mockDB, "sqlmock")
+			defer db.Close()
+
+			mock.ExpectBegin()
+			rows := sqlmock.NewRows([]string{"username", "cdn", "scope", "name", "message", "expires"})
+			for _, lock := range testCase.locks {
+				rows = rows.AddRow(lock...)
+			}
+			mock.ExpectQuery("SELECT username, cdn, scope, name, message, expires FROM cdn_lock").WithArgs(testCase.cdn, "deliveryservice", "ds1").WillReturnRows(rows)
+
+			userErr, sysErr, code := CheckIfCurrentUserCanModifyCDNObject(db.MustBegin().Tx, testCase.cdn, tc.CDNLockScopeDeliveryService, "ds1", "bob")
+			if code != testCase.expectedCode {
+				t.Errorf("expected code %d, actual %d", testCase.expectedCode, code)
+			}
+			if sysErr != nil {
+				t.Errorf("unexpected system error: %v", sysErr)
+			}
+			if testCase.expectedErr == "" && userErr != nil {
+				t.Errorf("unexpected user error: %v", userErr)
+			} else if testCase.expectedErr != "" && (userErr == nil || !strings.HasPrefix(userErr.Error(), testCase.expectedErr)) {
+				t.Errorf("expected a user error starting with '%s', actual: %v", testCase.expectedErr, userErr)
+			}
+		})
+	}
+}
+
+func TestCheckIfCurrentUserCanModifyMultiCDNObject(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	var testCases = []struct {
+		description  string
+		locks        [][]driver.Value
+		expectedCode int
+		expectedErr  string
+	}{
+		{
+			description:  "Success: none of the CDNs nor the topology is locked",
+			expectedCode: http.StatusOK,
+		},
+		{
+			description: "Success: CDN and topology locked by the current user",
+			locks: [][]driver.Value{
+				{"bob", "cdn1", "", "", nil, nil},
+				{"bob", "cdn1", "topology", "mso", nil, nil},
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			description: "Failure: another CDN the topology touches locked by another user",
+			locks: [][]driver.Value{
+				{"bob", "cdn1", "", "", nil, nil},
+				{"alice", "cdn2", "", "", "snapshotting", expires},
+			},
+			expectedCode: http.StatusForbidden,
+			expectedErr:  "user alice currently has a lock on cdn cdn2",
+		},
+		{
+			description: "Failure: topology locked by another user in another CDN",
+			locks: [][]driver.Value{
+				{"alice", "cdn2", "topology", "mso", nil, nil},
+			},
+			expectedCode: http.StatusForbidden,
+			expectedErr:  "user alice currently has a lock on topology mso in cdn cdn2",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.description, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer mockDB.Close()
+			db := sqlx.NewDb(mockDB, "sqlmock")
+			defer db.Close()
+
+			mock.ExpectBegin()
+			rows := sqlmock.NewRows([]string{"username", "cdn", "scope", "name", "message", "expires"})
+			for _, lock := range testCase.locks {
+				rows = rows.AddRow(lock...)
+			}
+			mock.ExpectQuery("SELECT username, cdn, scope, name, message, expires FROM cdn_lock").WithArgs(sqlmock.AnyArg(), "topology", "mso").WillReturnRows(rows)
+
+			userErr, sysErr, code := CheckIfCurrentUserCanModifyMultiCDNObject(db.MustBegin().Tx, []string{"cdn1", "cdn2"}, tc.CDNLockScopeTopology, "mso", "bob")
+			if code != testCase.expectedCode {
+				t.Errorf("expected code %d, actual %d", testCase.expectedCode, code)
+			}
+			if sysErr != nil {
+				t.Errorf("unexpected system error: %v", sysErr)
+			}
+			if testCase.expectedErr == "" && userErr != nil {
+				t.Errorf("unexpected user error: %v", userErr)
+			} else if testCase.expectedErr != "" && (userErr == nil || !strings.HasPrefix(userErr.Error(), testCase.expectedErr)) {
+				t.Errorf("expected a user error starting with '%s', actual: %v", testCase.expectedErr, userErr)
+			}
+		})
+	}
+}
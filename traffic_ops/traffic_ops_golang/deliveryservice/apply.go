@@ -0,0 +1,620 @@
+package deliveryservice
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tenant"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ApplyHandler is the handler for POST requests to /deliveryservices/apply.
+// It reconciles the Delivery Services of a CDN, a Tenant, or a Tenant within a CDN with the desired set in the request, in a single transaction.
+// With the 'dryRun' query parameter, nothing is changed, and the changes which would have been made are returned instead.
+func ApplyHandler(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	dryRun := false
+	if dryRunStr, ok := inf.Params["dryRun"]; ok {
+		var err error
+		if dryRun, err = strconv.ParseBool(dryRunStr); err != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, errors.New("dryRun must be a boolean"), nil)
+			return
+		}
+	}
+
+	req := tc.DeliveryServiceApplyRequest{}
+	if err := api.Parse(r.Body, inf.Tx.Tx, &req); err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	scope, userErr, sysErr, errCode := getApplyScope(inf.Tx.Tx, inf.User, &req)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+
+	xmlIDs := make([]string, 0, len(req.DeliveryServices))
+	for _, ds := range req.DeliveryServices {
+		xmlIDs = append(xmlIDs, *ds.XMLID)
+	}
+	cur, err := getAppliedDeliveryServices(inf.Tx, inf.User, scope, xmlIDs)
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("getting current delivery services: "+err.Error()))
+		return
+	}
+
+	plan, desired, err := planApply(req.DeliveryServices, cur)
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, err, nil)
+		return
+	}
+	plan.DryRun = dryRun
+	creates, updates, deletes := plan.Counts()
+	if dryRun {
+		api.WriteRespAlertObj(w, r, tc.SuccessLevel, fmt.Sprintf("Dry run: applying would create %d, update %d, and delete %d delivery services", creates, updates, deletes), plan)
+		return
+	}
+
+	for _, change := range plan.Changes {
+		cdns := map[string]struct{}{}
+		if change.Action != tc.DeliveryServiceApplyDelete {
+			cdns[*desired[change.XMLID].CDNName] = struct{}{}
+		}
+		if existing, ok := cur[change.XMLID]; ok && existing.DS.CDNName != nil {
+			cdns[*existing.DS.CDNName] = struct{}{}
+		}
+		for cdn := range cdns {
+			if userErr, sysErr, errCode := dbhelpers.CheckIfCurrentUserCanModifyCDNObject(inf.Tx.Tx, cdn, tc.CDNLockScopeDeliveryService, change.XMLID, inf.User.UserName); userErr != nil || sysErr != nil {
+				api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+				return
+			}
+		}
+	}
+
+	for _, change := range plan.Changes {
+		var userErr, sysErr error
+		var errCode int
+		if change.Action == tc.DeliveryServiceApplyDelete {
+			userErr, sysErr, errCode = applyDelete(inf, change.XMLID, cur[change.XMLID].ID)
+		} else {
+			existing, ok := cur[change.XMLID]
+			var existingPtr *appliedDeliveryService
+			if ok {
+				existingPtr = &existing
+			}
+			userErr, sysErr, errCode = applyDeliveryService(r, inf, desired[change.XMLID], existingPtr)
+		}
+		if userErr != nil || sysErr != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+			return
+		}
+	}
+
+	msg := fmt.Sprintf("ACTION: Applied delivery services for %s: %d created, %d updated, %d deleted", scope, creates, updates, deletes)
+	api.CreateChangeLogRawTx(api.ApiChange, msg, inf.User, inf.Tx.Tx)
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, fmt.Sprintf("Delivery services applied: %d created, %d updated, %d deleted", creates, updates, deletes), plan)
+}
+
+// applyScope is the set of Delivery Services which an apply reconciles: those in the CDN, the Tenant, or both.
+type applyScope struct {
+	CDNID    *int
+	CDN      *string
+	TenantID *int
+	Tenant   *string
+}
+
+func (s applyScope) String() string {
+	switch {
+	case s.CDN != nil && s.Tenant != nil:
+		return "CDN: " + *s.CDN + ", Tenant: " + *s.Tenant
+	case s.CDN != nil:
+		return "CDN: " + *s.CDN
+	case s.Tenant != nil:
+		return "Tenant: " + *s.Tenant
+	}
+	return ""
+}
+
+// contains returns whether a Delivery Service in the given CDN and Tenant is in the scope.
+func (s applyScope) contains(cdnID, tenantID *int) bool {
+	if s.CDNID != nil && (cdnID == nil || *cdnID != *s.CDNID) {
+		return false
+	}
+	if s.TenantID != nil && (tenantID == nil || *tenantID != *s.TenantID) {
+		return false
+	}
+	return true
+}
+
+// getApplyScope resolves the CDN and Tenant of the request, and checks that the user has access to the Tenant.
+func getApplyScope(tx *sql.Tx, user *auth.CurrentUser, req *tc.DeliveryServiceApplyRequest) (applyScope, error, error, int) {
+	scope := applyScope{CDN: req.CDN, Tenant: req.Tenant}
+	if req.CDN != nil {
+		id, ok, err := dbhelpers.GetCDNIDFromName(tx, tc.CDNName(*req.CDN))
+		if err != nil {
+			return scope, nil, errors.New("getting CDN ID: " + err.Error()), http.StatusInternalServerError
+		}
+		if !ok {
+			return scope, fmt.Errorf("cdn '%s' does not exist", *req.CDN), nil, http.StatusBadRequest
+		}
+		scope.CDNID = &id
+	}
+	if req.Tenant != nil {
+		id, ok, err := dbhelpers.GetTenantIDFromName(*req.Tenant, tx)
+		if err != nil {
+			return scope, nil, errors.New("getting tenant ID: " + err.Error()), http.StatusInternalServerError
+		}
+		if !ok {
+			return scope, fmt.Errorf("tenant '%s' does not exist", *req.Tenant), nil, http.StatusBadRequest
+		}
+		authorized, err := tenant.IsResourceAuthorizedToUserTx(id, user, tx)
+		if err != nil {
+			return scope, nil, errors.New("checking tenancy: " + err.Error()), http.StatusInternalServerError
+		}
+		if !authorized {
+			return scope, errors.New("not authorized on this tenant"), nil, http.StatusForbidden
+		}
+		scope.TenantID = &id
+	}
+	return scope, nil, nil, http.StatusOK
+}
+
+// appliedDeliveryService is the current state of a Delivery Service, as compared with the desired state of an apply.
+type appliedDeliveryService struct {
+	ID            int
+	SSLKeyVersion *int
+	InScope       bool
+	// DS has the fields generated by Traffic Ops, and the IDs of the objects it refers to by name, removed.
+	DS                   tc.DeliveryServiceV4
+	Regexes              []tc.DeliveryServiceRegex
+	RequiredCapabilities []string
+}
+
+// getAppliedDeliveryServices returns the Delivery Services in the scope, and the Delivery Services with the given XMLIDs, keyed by XMLID.
+// Only Delivery Services in the user's tenancy are returned.
+func getAppliedDeliveryServices(tx *sqlx.Tx, user *auth.CurrentUser, scope applyScope, xmlIDs []string) (map[string]appliedDeliveryService, error) {
+	tenantIDs, err := tenant.GetUserTenantIDListTx(tx.Tx, user.TenantID)
+	if err != nil {
+		return nil, errors.New("getting user tenants: " + err.Error())
+	}
+	where := dbhelpers.BaseWhere + " ((ds.cdn_id = :cdn_id OR CAST(:cdn_id AS bigint) IS NULL) AND (ds.tenant_id = :tenant_id OR CAST(:tenant_id AS bigint) IS NULL) OR ds.xml_id = ANY(CAST(:xml_ids AS text[])))"
+	params := map[string]interface{}{"cdn_id": scope.CDNID, "tenant_id": scope.TenantID, "xml_ids": pq.Array(xmlIDs)}
+	where, queryValues := dbhelpers.AddTenancyCheck(where, params, "ds.tenant_id", tenantIDs)
+	dses, userErr, sysErr, _ := GetDeliveryServices(SelectDeliveryServicesQuery+where, queryValues, tx)
+	if sysErr != nil {
+		return nil, errors.New("getting delivery services: " + sysErr.Error())
+	}
+	if userErr != nil {
+		return nil, errors.New("getting delivery services: " + userErr.Error())
+	}
+
+	ids := make([]int, 0, len(dses))
+	for _, ds := range dses {
+		if ds.ID != nil {
+			ids = append(ids, *ds.ID)
+		}
+	}
+	regexes, err := GetRegexes(tx.Tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	capabilities, err := GetRequiredCapabilities(tx.Tx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[string]appliedDeliveryService, len(dses))
+	for _, ds := range dses {
+		if ds.ID == nil || ds.XMLID == nil {
+			continue
+		}
+		a := appliedDeliveryService{
+			ID:                   *ds.ID,
+			SSLKeyVersion:        ds.SSLKeyVersion,
+			InScope:              scope.contains(ds.CDNID, ds.TenantID),
+			Regexes:              regexes[*ds.ID],
+			RequiredCapabilities: capabilities[*ds.ID],
+		}
+		if a.Regexes == nil {
+			a.Regexes = []tc.DeliveryServiceRegex{}
+		}
+		if a.RequiredCapabilities == nil {
+			a.RequiredCapabilities = []string{}
+		}
+		removeGeneratedFields(&ds)
+		a.DS = ds
+		applied[*ds.XMLID] = a
+	}
+	return applied, nil
+}
+
+// removeGeneratedFields removes the fields of a Delivery Service which are generated by Traffic Ops, or which refer to other objects by ID.
+// An apply refers to other objects only by name.
+func removeGeneratedFields(ds *tc.DeliveryServiceV4) {
+	ds.ID = nil
+	ds.CDNID = nil
+	ds.ExampleURLs = nil
+	ds.LastUpdated = nil
+	ds.MatchList = nil
+	ds.ProfileDesc = nil
+	ds.ProfileID = nil
+	ds.SSLKeyVersion = nil
+	ds.TenantID = nil
+	ds.TypeID = nil
+}
+
+// planApply returns the changes needed to make the current Delivery Services match the desired ones, and the full desired state of each created or updated Delivery Service, keyed by XMLID.
+// The full desired state of an updated Delivery Service is its current state, with the non-null fields of the request replacing the current values.
+func planApply(items []tc.DeliveryServiceApplyItem, cur map[string]appliedDeliveryService) (tc.DeliveryServiceApplyPlan, map[string]tc.DeliveryServiceApplyItem, error) {
+	plan := tc.DeliveryServiceApplyPlan{Changes: []tc.DeliveryServiceApplyChange{}}
+	desired := make(map[string]tc.DeliveryServiceApplyItem, len(items))
+	errs := []error{}
+	for _, item := range items {
+		xmlID := *item.XMLID
+		removeGeneratedFields(&item.DeliveryServiceV4)
+
+		existing, exists := cur[xmlID]
+		if exists && !existing.InScope {
+			errs = append(errs, fmt.Errorf("delivery service '%s' already exists outside of the CDN and tenant of the request", xmlID))
+			continue
+		}
+
+		var curDS *tc.DeliveryServiceV4
+		if exists {
+			curDS = &existing.DS
+		}
+		fields, merged, err := diffDeliveryService(curDS, item)
+		if err != nil {
+			return plan, nil, fmt.Errorf("comparing delivery service '%s': %v", xmlID, err)
+		}
+		item.DeliveryServiceV4 = merged
+
+		if item.Regexes != nil {
+			sortRegexes(item.Regexes)
+			var old interface{}
+			if exists {
+				old = existing.Regexes
+			}
+			if !exists || !reflect.DeepEqual(existing.Regexes, item.Regexes) {
+				fields = append(fields, tc.DeliveryServiceFieldChange{Field: "regexes", Old: old, New: item.Regexes})
+			}
+		}
+		if item.RequiredCapabilities != nil {
+			sort.Strings(item.RequiredCapabilities)
+			var old interface{}
+			if exists {
+				old = existing.RequiredCapabilities
+			}
+			if !exists || !reflect.DeepEqual(existing.RequiredCapabilities, item.RequiredCapabilities) {
+				fields = append(fields, tc.DeliveryServiceFieldChange{Field: "requiredCapabilities", Old: old, New: item.RequiredCapabilities})
+			}
+		}
+
+		action := tc.DeliveryServiceApplyUpdate
+		if !exists {
+			action = tc.DeliveryServiceApplyCreate
+		} else if len(fields) == 0 {
+			continue
+		}
+		plan.Changes = append(plan.Changes, tc.DeliveryServiceApplyChange{XMLID: xmlID, Action: action, Fields: fields})
+		desired[xmlID] = item
+	}
+	if len(errs) > 0 {
+		return plan, nil, util.JoinErrs(errs)
+	}
+
+	requested := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		requested[*item.XMLID] = struct{}{}
+	}
+	for xmlID, existing := range cur {
+		if _, ok := requested[xmlID]; !ok && existing.InScope {
+			plan.Changes = append(plan.Changes, tc.DeliveryServiceApplyChange{XMLID: xmlID, Action: tc.DeliveryServiceApplyDelete, Fields: []tc.DeliveryServiceFieldChange{}})
+		}
+	}
+	sort.Slice(plan.Changes, func(i, j int) bool { return plan.Changes[i].XMLID < plan.Changes[j].XMLID })
+	return plan, desired, nil
+}
+
+// diffDeliveryService returns the changes to the fields of cur made by the managed, non-null fields of item, sorted by field name, and the result of making them.
+// If cur is nil, the Delivery Service of item is returned unmodified.
+func diffDeliveryService(cur *tc.DeliveryServiceV4, item tc.DeliveryServiceApplyItem) ([]tc.DeliveryServiceFieldChange, tc.DeliveryServiceV4, error) {
+	desired := item.DeliveryServiceV4
+	desiredFields, err := toJSONObject(desired)
+	if err != nil {
+		return nil, desired, err
+	}
+	curFields := map[string]interface{}{}
+	if cur != nil {
+		if curFields, err = toJSONObject(*cur); err != nil {
+			return nil, desired, err
+		}
+	}
+
+	changes := []tc.DeliveryServiceFieldChange{}
+	for field, value := range desiredFields {
+		if value == nil || !item.Manages(field) {
+			continue
+		}
+		old := curFields[field]
+		if reflect.DeepEqual(old, value) {
+			continue
+		}
+		changes = append(changes, tc.DeliveryServiceFieldChange{Field: field, Old: old, New: value})
+		curFields[field] = value
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	if cur == nil {
+		return changes, desired, nil
+	}
+
+	merged := tc.DeliveryServiceV4{}
+	bts, err := json.Marshal(curFields)
+	if err != nil {
+		return nil, desired, err
+	}
+	if err := json.Unmarshal(bts, &merged); err != nil {
+		return nil, desired, err
+	}
+	return changes, merged, nil
+}
+
+// toJSONObject returns the fields of v as they would be encoded in JSON.
+func toJSONObject(v interface{}) (map[string]interface{}, error) {
+	bts, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	obj := map[string]interface{}{}
+	err = json.Unmarshal(bts, &obj)
+	return obj, err
+}
+
+func sortRegexes(regexes []tc.DeliveryServiceRegex) {
+	sort.Slice(regexes, func(i, j int) bool {
+		if regexes[i].SetNumber != regexes[j].SetNumber {
+			return regexes[i].SetNumber < regexes[j].SetNumber
+		}
+		if regexes[i].Type != regexes[j].Type {
+			return regexes[i].Type < regexes[j].Type
+		}
+		return regexes[i].Pattern < regexes[j].Pattern
+	})
+}
+
+// applyDelete deletes the Delivery Service with the given XMLID and ID, exactly as a DELETE request to /deliveryservices/{id} would.
+func applyDelete(inf *api.APIInfo, xmlID string, id int) (error, error, int) {
+	ds := &TODeliveryService{APIInfoImpl: api.APIInfoImpl{ReqInfo: inf}}
+	ds.ID = util.IntPtr(id)
+	if userErr, sysErr, errCode := ds.Delete(); userErr != nil || sysErr != nil {
+		return userErr, sysErr, errCode
+	}
+	if err := api.CreateChangeLogRawErr(api.ApiChange, fmt.Sprintf("DS: %s, ID: %d, ACTION: Deleted delivery service", xmlID, id), inf.User, inf.Tx.Tx); err != nil {
+		return nil, errors.New("writing change log: " + err.Error()), http.StatusInternalServerError
+	}
+	return nil, nil, http.StatusOK
+}
+
+// applyDeliveryService creates the desired Delivery Service or, if existing isn't nil, updates it, then sets its regexes and required capabilities if they're managed by the request.
+func applyDeliveryService(r *http.Request, inf *api.APIInfo, item tc.DeliveryServiceApplyItem, existing *appliedDeliveryService) (error, error, int) {
+	tx := inf.Tx.Tx
+	ds := item.DeliveryServiceV4
+	xmlID := *ds.XMLID
+	if userErr, sysErr, errCode := resolveApplyIDs(tx, &ds); userErr != nil || sysErr != nil {
+		return userErr, sysErr, errCode
+	}
+
+	var res *tc.DeliveryServiceV4
+	var userErr, sysErr error
+	var errCode int
+	if existing == nil {
+		res, errCode, userErr, sysErr = CreateInTx(r, inf, ds)
+	} else {
+		ds.ID = util.IntPtr(existing.ID)
+		ds.SSLKeyVersion = existing.SSLKeyVersion
+		res, errCode, userErr, sysErr = UpdateInTx(r, inf, &ds)
+	}
+	if userErr != nil {
+		return fmt.Errorf("delivery service '%s': %v", xmlID, userErr), sysErr, errCode
+	}
+	if sysErr != nil {
+		return nil, fmt.Errorf("applying delivery service '%s': %v", xmlID, sysErr), errCode
+	}
+
+	if item.Regexes != nil {
+		if err := SetRegexes(tx, *res.ID, item.Regexes); err != nil {
+			userErr, sysErr, errCode := api.ParseDBError(err)
+			return wrapApplyErr(xmlID, userErr), wrapApplyErr(xmlID, sysErr), errCode
+		}
+	}
+	if item.RequiredCapabilities != nil {
+		if res.Topology != nil {
+			if userErr, sysErr, errCode := EnsureTopologyBasedRequiredCapabilities(tx, *res.ID, *res.Topology, item.RequiredCapabilities); userErr != nil || sysErr != nil {
+				return wrapApplyErr(xmlID, userErr), wrapApplyErr(xmlID, sysErr), errCode
+			}
+		}
+		if err := SetRequiredCapabilities(tx, *res.ID, item.RequiredCapabilities); err != nil {
+			userErr, sysErr, errCode := api.ParseDBError(err)
+			return wrapApplyErr(xmlID, userErr), wrapApplyErr(xmlID, sysErr), errCode
+		}
+	}
+	return nil, nil, http.StatusOK
+}
+
+func wrapApplyErr(xmlID string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("delivery service '%s': %v", xmlID, err)
+}
+
+// resolveApplyIDs sets the IDs of the CDN, Type, Tenant, and Profile of the Delivery Service from their names.
+func resolveApplyIDs(tx *sql.Tx, ds *tc.DeliveryServiceV4) (error, error, int) {
+	xmlID := *ds.XMLID
+	cdnID, ok, err := dbhelpers.GetCDNIDFromName(tx, tc.CDNName(*ds.CDNName))
+	if err != nil {
+		return nil, errors.New("getting CDN ID: " + err.Error()), http.StatusInternalServerError
+	} else if !ok {
+		return fmt.Errorf("delivery service '%s': cdn '%s' does not exist", xmlID, *ds.CDNName), nil, http.StatusBadRequest
+	}
+	ds.CDNID = &cdnID
+
+	if ds.Type == nil {
+		return fmt.Errorf("delivery service '%s': type is required", xmlID), nil, http.StatusBadRequest
+	}
+	typeID, ok, err := dbhelpers.GetTypeIDByName(string(*ds.Type), tx)
+	if err != nil {
+		return nil, errors.New("getting type ID: " + err.Error()), http.StatusInternalServerError
+	} else if !ok {
+		return fmt.Errorf("delivery service '%s': type '%s' does not exist", xmlID, *ds.Type), nil, http.StatusBadRequest
+	}
+	ds.TypeID = &typeID
+
+	tenantID, ok, err := dbhelpers.GetTenantIDFromName(*ds.Tenant, tx)
+	if err != nil {
+		return nil, errors.New("getting tenant ID: " + err.Error()), http.StatusInternalServerError
+	} else if !ok {
+		return fmt.Errorf("delivery service '%s': tenant '%s' does not exist", xmlID, *ds.Tenant), nil, http.StatusBadRequest
+	}
+	ds.TenantID = &tenantID
+
+	if ds.ProfileName != nil {
+		profileID, ok, err := dbhelpers.GetProfileIDFromName(*ds.ProfileName, tx)
+		if err != nil {
+			return nil, errors.New("getting profile ID: " + err.Error()), http.StatusInternalServerError
+		} else if !ok {
+			return fmt.Errorf("delivery service '%s': profile '%s' does not exist", xmlID, *ds.ProfileName), nil, http.StatusBadRequest
+		}
+		ds.ProfileID = &profileID
+	}
+	return nil, nil, http.StatusOK
+}
+
+// GetRegexes returns the regexes of the Delivery Services with the given IDs, keyed by Delivery Service ID, ordered by set number, type, and pattern.
+func GetRegexes(tx *sql.Tx, dsIDs []int) (map[int][]tc.DeliveryServiceRegex, error) {
+	rows, err := tx.Query(`
+SELECT dr.deliveryservice, t.name, r.pattern, COALESCE(dr.set_number, 0)
+FROM deliveryservice_regex dr
+JOIN regex r ON dr.regex = r.id
+JOIN type t ON r.type = t.id
+WHERE dr.deliveryservice = ANY($1)
+ORDER BY dr.deliveryservice, COALESCE(dr.set_number, 0), t.name, r.pattern
+`, pq.Array(dsIDs))
+	if err != nil {
+		return nil, errors.New("querying delivery service regexes: " + err.Error())
+	}
+	defer log.Close(rows, "closing delivery service regex rows")
+	regexes := map[int][]tc.DeliveryServiceRegex{}
+	for rows.Next() {
+		id := 0
+		re := tc.DeliveryServiceRegex{}
+		if err := rows.Scan(&id, &re.Type, &re.Pattern, &re.SetNumber); err != nil {
+			return nil, errors.New("scanning delivery service regexes: " + err.Error())
+		}
+		regexes[id] = append(regexes[id], re)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("iterating delivery service regex rows: " + err.Error())
+	}
+	return regexes, nil
+}
+
+// GetRequiredCapabilities returns the required capabilities of the Delivery Services with the given IDs, keyed by Delivery Service ID, in alphabetical order.
+func GetRequiredCapabilities(tx *sql.Tx, dsIDs []int) (map[int][]string, error) {
+	rows, err := tx.Query(`
+SELECT deliveryservice_id, required_capability
+FROM deliveryservices_required_capability
+WHERE deliveryservice_id = ANY($1)
+ORDER BY required_capability
+`, pq.Array(dsIDs))
+	if err != nil {
+		return nil, errors.New("querying delivery service required capabilities: " + err.Error())
+	}
+	defer log.Close(rows, "closing delivery service required capability rows")
+	capabilities := map[int][]string{}
+	for rows.Next() {
+		id := 0
+		capability := ""
+		if err := rows.Scan(&id, &capability); err != nil {
+			return nil, errors.New("scanning delivery service required capabilities: " + err.Error())
+		}
+		capabilities[id] = append(capabilities[id], capability)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("iterating delivery service required capability rows: " + err.Error())
+	}
+	return capabilities, nil
+}
+
+// SetRegexes replaces the regexes of the Delivery Service with the given ID.
+func SetRegexes(tx *sql.Tx, dsID int, regexes []tc.DeliveryServiceRegex) error {
+	// regexes MUST be deleted before deliveryservice_regex, because of the ON DELETE CASCADE on deliveryservice_regex
+	if _, err := tx.Exec(`DELETE FROM regex WHERE id IN (SELECT regex FROM deliveryservice_regex WHERE deliveryservice = $1)`, dsID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM deliveryservice_regex WHERE deliveryservice = $1`, dsID); err != nil {
+		return err
+	}
+	for _, re := range regexes {
+		regexID := 0
+		if err := tx.QueryRow(`INSERT INTO regex (type, pattern) VALUES ((SELECT id FROM type WHERE name = $1), $2) RETURNING id`, re.Type, re.Pattern).Scan(&regexID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO deliveryservice_regex (deliveryservice, regex, set_number) VALUES ($1, $2, $3)`, dsID, regexID, re.SetNumber); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetRequiredCapabilities replaces the required capabilities of the Delivery Service with the given ID.
+func SetRequiredCapabilities(tx *sql.Tx, dsID int, capabilities []string) error {
+	if _, err := tx.Exec(`DELETE FROM deliveryservices_required_capability WHERE deliveryservice_id = $1`, dsID); err != nil {
+		return err
+	}
+	if len(capabilities) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(`INSERT INTO deliveryservices_required_capability (deliveryservice_id, required_capability) SELECT $1, UNNEST($2::text[])`, dsID, pq.Array(capabilities))
+	return err
+}
@@ -0,0 +1,119 @@
+package deliveryservice
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+)
+
+func testAppliedDeliveryService(xmlID string) appliedDeliveryService {
+	dsType := tc.DSTypeHTTP
+	ds := tc.DeliveryServiceV4{}
+	ds.XMLID = util.StrPtr(xmlID)
+	ds.CDNName = util.StrPtr("cdn1")
+	ds.Tenant = util.StrPtr("root")
+	ds.Type = &dsType
+	ds.Active = util.BoolPtr(true)
+	ds.EcsEnabled = true
+	ds.LongDesc = util.StrPtr("long description")
+	return appliedDeliveryService{
+		ID:                   1,
+		InScope:              true,
+		DS:                   ds,
+		Regexes:              []tc.DeliveryServiceRegex{{Type: "HOST_REGEXP", Pattern: `.*\.` + xmlID + `\..*`}},
+		RequiredCapabilities: []string{},
+	}
+}
+
+func decodeApplyItems(t *testing.T, items string) []tc.DeliveryServiceApplyItem {
+	decoded := []tc.DeliveryServiceApplyItem{}
+	if err := json.Unmarshal([]byte(items), &decoded); err != nil {
+		t.Fatalf("decoding items: %v", err)
+	}
+	return decoded
+}
+
+func TestPlanApply(t *testing.T) {
+	cur := map[string]appliedDeliveryService{
+		"unchanged": testAppliedDeliveryService("unchanged"),
+		"changed":   testAppliedDeliveryService("changed"),
+		"removed":   testAppliedDeliveryService("removed"),
+	}
+	outOfScope := testAppliedDeliveryService("other")
+	outOfScope.InScope = false
+	cur["other"] = outOfScope
+
+	items := decodeApplyItems(t, `[
+		{"xmlId": "unchanged", "cdnName": "cdn1", "active": true, "regexes": [{"type": "HOST_REGEXP", "pattern": ".*\\.unchanged\\..*"}]},
+		{"xmlId": "changed", "cdnName": "cdn1", "active": false, "id": 7, "requiredCapabilities": ["ram"]},
+		{"xmlId": "new", "cdnName": "cdn1", "tenant": "root", "type": "HTTP"}
+	]`)
+
+	plan, desired, err := planApply(items, cur)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []tc.DeliveryServiceApplyChange{
+		{XMLID: "changed", Action: tc.DeliveryServiceApplyUpdate, Fields: []tc.DeliveryServiceFieldChange{
+			{Field: "active", Old: true, New: false},
+			{Field: "requiredCapabilities", Old: []string{}, New: []string{"ram"}},
+		}},
+		{XMLID: "new", Action: tc.DeliveryServiceApplyCreate, Fields: []tc.DeliveryServiceFieldChange{
+			{Field: "cdnName", New: "cdn1"},
+			{Field: "tenant", New: "root"},
+			{Field: "type", New: "HTTP"},
+			{Field: "xmlId", New: "new"},
+		}},
+		{XMLID: "removed", Action: tc.DeliveryServiceApplyDelete, Fields: []tc.DeliveryServiceFieldChange{}},
+	}
+	if !reflect.DeepEqual(plan.Changes, expected) {
+		t.Errorf("expected changes %+v, actual %+v", expected, plan.Changes)
+	}
+
+	changed, ok := desired["changed"]
+	if !ok {
+		t.Fatal("expected the desired state of the changed delivery service")
+	}
+	// fields which aren't given keep their current values, including those which can't be null
+	if changed.Active == nil || *changed.Active || !changed.EcsEnabled || changed.LongDesc == nil || *changed.LongDesc != "long description" || changed.ID != nil {
+		t.Errorf("expected the request to be merged with the current delivery service, actual %+v", changed.DeliveryServiceV4)
+	}
+	if _, ok := desired["unchanged"]; ok {
+		t.Error("expected no desired state for the unchanged delivery service")
+	}
+}
+
+func TestPlanApplyOutOfScope(t *testing.T) {
+	cur := map[string]appliedDeliveryService{"other": testAppliedDeliveryService("other")}
+	other := cur["other"]
+	other.InScope = false
+	cur["other"] = other
+
+	_, _, err := planApply(decodeApplyItems(t, `[{"xmlId": "other", "cdnName": "cdn1"}]`), cur)
+	if err == nil || !strings.Contains(err.Error(), "delivery service 'other' already exists outside of the CDN and tenant of the request") {
+		t.Errorf("expected an out of scope error, actual: %v", err)
+	}
+}
@@ -110,6 +110,29 @@ func (ds *TODeliveryService) IsTenantAuthorized(user *auth.CurrentUser) (bool, e
 	return isTenantAuthorized(ds.ReqInfo, &ds.DeliveryServiceV4)
 }
 
+// CheckCDNLock implements the api.CDNLockable interface by rejecting changes
+// to a Delivery Service when another user has locked either the Delivery
+// Service itself or the CDN to which it belongs.
+func (ds *TODeliveryService) CheckCDNLock(user *auth.CurrentUser) (error, error, int) {
+	if ds.ID == nil {
+		return nil, nil, http.StatusOK
+	}
+	return checkCDNLock(ds.ReqInfo.Tx.Tx, *ds.ID, user)
+}
+
+// checkCDNLock checks that no user other than the given one holds a lock on
+// the Delivery Service with the given ID or on its CDN.
+func checkCDNLock(tx *sql.Tx, id int, user *auth.CurrentUser) (error, error, int) {
+	xmlID, cdn, ok, err := dbhelpers.GetDSNameAndCDNFromID(tx, id)
+	if err != nil {
+		return nil, fmt.Errorf("getting xml_id and cdn of delivery service #%d: %v", id, err), http.StatusInternalServerError
+	}
+	if !ok {
+		return nil, nil, http.StatusOK
+	}
+	return dbhelpers.CheckIfCurrentUserCanModifyCDNObject(tx, string(cdn), tc.CDNLockScopeDeliveryService, string(xmlID), user.UserName)
+}
+
 func CreateV12(w http.ResponseWriter, r *http.Request) {
 	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
 	if userErr != nil || sysErr != nil {
@@ -500,6 +523,12 @@ func createV40(w http.ResponseWriter, r *http.Request, inf *api.APIInfo, dsV40 t
 	return &dsV40, http.StatusOK, nil, nil
 }
 
+// CreateInTx creates the given Delivery Service within the transaction of inf, exactly as a POST request to /deliveryservices would, including its change log entry.
+// It exists for endpoints which create Delivery Services in bulk; r is the request being served, which is used for its context.
+func CreateInTx(r *http.Request, inf *api.APIInfo, ds tc.DeliveryServiceV4) (*tc.DeliveryServiceV4, int, error, error) {
+	return createV40(nil, r, inf, ds)
+}
+
 func createDefaultRegex(tx *sql.Tx, dsID int, xmlID string) error {
 	regexStr := `.*\.` + xmlID + `\..*`
 	regexID := 0
@@ -933,6 +962,10 @@ func updateV40(w http.ResponseWriter, r *http.Request, inf *api.APIInfo, dsV40 *
 		return nil, http.StatusBadRequest, errors.New("missing id"), nil
 	}
 
+	if userErr, sysErr, errCode := checkCDNLock(tx, *ds.ID, user); userErr != nil || sysErr != nil {
+		return nil, errCode, userErr, sysErr
+	}
+
 	dsType, ok, err := getDSType(tx, *ds.XMLID)
 	if !ok {
 		return nil, http.StatusNotFound, errors.New("delivery service '" + *ds.XMLID + "' not found"), nil
@@ -1153,6 +1186,12 @@ func updateV40(w http.ResponseWriter, r *http.Request, inf *api.APIInfo, dsV40 *
 	return dsV40, http.StatusOK, nil, nil
 }
 
+// UpdateInTx updates the Delivery Service with the given Delivery Service's ID within the transaction of inf, exactly as a PUT request to /deliveryservices/{id} would, including its change log entry.
+// It exists for endpoints which update Delivery Services in bulk; r is the request being served, which is used for its context and headers.
+func UpdateInTx(r *http.Request, inf *api.APIInfo, ds *tc.DeliveryServiceV4) (*tc.DeliveryServiceV4, int, error, error) {
+	return updateV40(nil, r, inf, ds)
+}
+
 //Delete is the DeliveryService implementation of the Deleter interface.
 func (ds *TODeliveryService) Delete() (error, error, int) {
 	if ds.ID == nil {
@@ -0,0 +1,294 @@
+package request
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tenant"
+
+	"github.com/lib/pq"
+)
+
+const selectApprovalPolicyQuery = `
+SELECT p.tenant_id,
+       t.name AS tenant,
+       p.required_approvals,
+       p.approver_roles,
+       p.allow_self_approval,
+       p.notify_by_email,
+       p.last_updated
+FROM dsr_approval_policy p
+JOIN tenant t ON t.id = p.tenant_id
+`
+
+const insertApprovalPolicyQuery = `
+INSERT INTO dsr_approval_policy (tenant_id, required_approvals, approver_roles, allow_self_approval, notify_by_email)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING (SELECT name FROM tenant WHERE id = $1), last_updated
+`
+
+const updateApprovalPolicyQuery = `
+UPDATE dsr_approval_policy
+SET required_approvals=$1, approver_roles=$2, allow_self_approval=$3, notify_by_email=$4
+WHERE tenant_id=$5
+RETURNING (SELECT name FROM tenant WHERE id = $5), last_updated
+`
+
+const deleteApprovalPolicyQuery = `DELETE FROM dsr_approval_policy WHERE tenant_id=$1`
+
+// governingApprovalPolicyQuery selects the Approval Policy of the given
+// Tenant or, failing that, of its nearest ancestor that has one.
+const governingApprovalPolicyQuery = `
+WITH RECURSIVE ancestor AS (
+	SELECT id, parent_id, 0 AS depth FROM tenant WHERE id = $1
+	UNION ALL
+	SELECT t.id, t.parent_id, a.depth + 1
+	FROM tenant t
+	JOIN ancestor a ON t.id = a.parent_id
+)
+` + selectApprovalPolicyQuery + `
+JOIN ancestor a ON a.id = p.tenant_id
+ORDER BY a.depth
+LIMIT 1
+`
+
+func scanApprovalPolicy(scanner interface{ Scan(...interface{}) error }, p *tc.DSRApprovalPolicy) error {
+	return scanner.Scan(&p.TenantID, &p.Tenant, &p.RequiredApprovals, pq.Array(&p.ApproverRoles), &p.AllowSelfApproval, &p.NotifyByEmail, &p.LastUpdated)
+}
+
+// getGoverningApprovalPolicy returns the Approval Policy that governs
+// Delivery Service Requests for Delivery Services in the given Tenant, or
+// nil if there is none.
+func getGoverningApprovalPolicy(tx *sql.Tx, tenantID int) (*tc.DSRApprovalPolicy, error) {
+	p := tc.DSRApprovalPolicy{}
+	if err := scanApprovalPolicy(tx.QueryRow(governingApprovalPolicyQuery, tenantID), &p); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("querying approval policy for Tenant #%d: %v", tenantID, err)
+	}
+	return &p, nil
+}
+
+// setApprovalPolicyDefaults sets the optional fields of a DSRApprovalPolicy
+// submitted by a client.
+func setApprovalPolicyDefaults(p *tc.DSRApprovalPolicy) {
+	if p.ApproverRoles == nil {
+		p.ApproverRoles = []string{}
+	}
+	if p.AllowSelfApproval == nil {
+		p.AllowSelfApproval = util.BoolPtr(false)
+	}
+	if p.NotifyByEmail == nil {
+		p.NotifyByEmail = util.BoolPtr(false)
+	}
+}
+
+// checkApprovalPolicyTenancy returns an error suitable for returning to the
+// user, and its status code, if the current user may not manage the Approval
+// Policy of the given Tenant.
+func checkApprovalPolicyTenancy(inf *api.APIInfo, tenantID int) (int, error, error) {
+	ok, err := tenant.IsResourceAuthorizedToUserTx(tenantID, inf.User, inf.Tx.Tx)
+	if err != nil {
+		return http.StatusInternalServerError, nil, fmt.Errorf("checking user tenancy for Tenant #%d: %v", tenantID, err)
+	}
+	if !ok {
+		return http.StatusForbidden, errors.New("not authorized on this tenant"), nil
+	}
+	return http.StatusOK, nil, nil
+}
+
+func approvalPolicyChangeLog(action string, p tc.DSRApprovalPolicy) string {
+	return fmt.Sprintf("DSR APPROVAL POLICY: %s, ID: %d, ACTION: %s Delivery Service Request approval policy requiring %d approvals", *p.Tenant, *p.TenantID, action, *p.RequiredApprovals)
+}
+
+// GetApprovalPolicies is the handler for GET requests to
+// /deliveryservice_request_approval_policies.
+func GetApprovalPolicies(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	tx := inf.Tx.Tx
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	cols := map[string]dbhelpers.WhereColumnInfo{
+		"tenantId": {Column: "p.tenant_id", Checker: api.IsInt},
+		"tenant":   {Column: "t.name", Checker: nil},
+	}
+	where, orderBy, pagination, queryValues, errs := dbhelpers.BuildWhereAndOrderByAndPagination(inf.Params, cols)
+	if len(errs) > 0 {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, util.JoinErrs(errs), nil)
+		return
+	}
+
+	accessibleTenants, err := tenant.GetUserTenantIDListTx(tx, inf.User.TenantID)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("getting accessible tenants for user: "+err.Error()))
+		return
+	}
+	if len(where) > 0 {
+		where += " AND p.tenant_id = ANY(:tenants) "
+	} else {
+		where = dbhelpers.BaseWhere + " p.tenant_id = ANY(:tenants) "
+	}
+	queryValues["tenants"] = pq.Array(accessibleTenants)
+	if orderBy == "" {
+		orderBy = "\nORDER BY t.name"
+	}
+
+	rows, err := inf.Tx.NamedQuery(selectApprovalPolicyQuery+where+orderBy+pagination, queryValues)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("querying Delivery Service Request approval policies: "+err.Error()))
+		return
+	}
+	defer rows.Close()
+
+	policies := []tc.DSRApprovalPolicy{}
+	for rows.Next() {
+		p := tc.DSRApprovalPolicy{}
+		if err := scanApprovalPolicy(rows, &p); err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("scanning Delivery Service Request approval policies: "+err.Error()))
+			return
+		}
+		policies = append(policies, p)
+	}
+	api.WriteResp(w, r, policies)
+}
+
+// CreateApprovalPolicy is the handler for POST requests to
+// /deliveryservice_request_approval_policies.
+func CreateApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	tx := inf.Tx.Tx
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	p := tc.DSRApprovalPolicy{}
+	if err := api.Parse(r.Body, tx, &p); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
+		return
+	}
+	setApprovalPolicyDefaults(&p)
+
+	if errCode, userErr, sysErr = checkApprovalPolicyTenancy(inf, *p.TenantID); userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	if err := tx.QueryRow(insertApprovalPolicyQuery, p.TenantID, p.RequiredApprovals, pq.Array(p.ApproverRoles), p.AllowSelfApproval, p.NotifyByEmail).Scan(&p.Tenant, &p.LastUpdated); err != nil {
+		userErr, sysErr, errCode = api.ParseDBError(err)
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "Delivery Service Request approval policy was created.", p)
+	api.CreateChangeLogRawTx(api.ApiChange, approvalPolicyChangeLog(api.Created, p), inf.User, tx)
+}
+
+// UpdateApprovalPolicy is the handler for PUT requests to
+// /deliveryservice_request_approval_policies/{{tenant ID}}.
+func UpdateApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"tenantId"}, []string{"tenantId"})
+	tx := inf.Tx.Tx
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tenantID := inf.IntParams["tenantId"]
+
+	p := tc.DSRApprovalPolicy{}
+	if err := api.Parse(r.Body, tx, &p); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
+		return
+	}
+	setApprovalPolicyDefaults(&p)
+	if *p.TenantID != tenantID {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, errors.New("'tenantId' cannot be changed"), nil)
+		return
+	}
+
+	if errCode, userErr, sysErr = checkApprovalPolicyTenancy(inf, tenantID); userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	if err := tx.QueryRow(updateApprovalPolicyQuery, p.RequiredApprovals, pq.Array(p.ApproverRoles), p.AllowSelfApproval, p.NotifyByEmail, tenantID).Scan(&p.Tenant, &p.LastUpdated); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no Delivery Service Request approval policy exists for Tenant #%d", tenantID), nil)
+			return
+		}
+		userErr, sysErr, errCode = api.ParseDBError(err)
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "Delivery Service Request approval policy was updated.", p)
+	api.CreateChangeLogRawTx(api.ApiChange, approvalPolicyChangeLog(api.Updated, p), inf.User, tx)
+}
+
+// DeleteApprovalPolicy is the handler for DELETE requests to
+// /deliveryservice_request_approval_policies/{{tenant ID}}. Approvals
+// already given to Delivery Service Requests are not deleted.
+func DeleteApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"tenantId"}, []string{"tenantId"})
+	tx := inf.Tx.Tx
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tenantID := inf.IntParams["tenantId"]
+
+	if errCode, userErr, sysErr = checkApprovalPolicyTenancy(inf, tenantID); userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	p := tc.DSRApprovalPolicy{}
+	if err := scanApprovalPolicy(tx.QueryRow(selectApprovalPolicyQuery+"WHERE p.tenant_id=$1", tenantID), &p); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no Delivery Service Request approval policy exists for Tenant #%d", tenantID), nil)
+			return
+		}
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("getting approval policy for Tenant #%d: %v", tenantID, err))
+		return
+	}
+	if _, err := tx.Exec(deleteApprovalPolicyQuery, tenantID); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("deleting approval policy for Tenant #%d: %v", tenantID, err))
+		return
+	}
+
+	api.WriteRespAlert(w, r, tc.SuccessLevel, "Delivery Service Request approval policy was deleted.")
+	api.CreateChangeLogRawTx(api.ApiChange, approvalPolicyChangeLog(api.Deleted, p), inf.User, tx)
+}
@@ -0,0 +1,392 @@
+package request
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-rfc"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+)
+
+const selectApprovalsQuery = `
+SELECT a.id, a.deliveryservice_request, u.username, a.comment, a.approved
+FROM deliveryservice_request_approval a
+JOIN tm_user u ON u.id = a.approver
+WHERE a.deliveryservice_request = $1
+ORDER BY a.approved, a.id
+`
+
+const insertApprovalQuery = `
+INSERT INTO deliveryservice_request_approval (deliveryservice_request, approver, comment)
+VALUES ($1, $2, $3)
+ON CONFLICT (deliveryservice_request, approver) DO NOTHING
+RETURNING id, approved
+`
+
+const deleteApprovalsQuery = `DELETE FROM deliveryservice_request_approval WHERE deliveryservice_request = $1`
+
+// approvalNotificationRecipientsQuery selects the email addresses of the
+// author and assignee of a Delivery Service Request, except for the given
+// user.
+const approvalNotificationRecipientsQuery = `
+SELECT DISTINCT u.email
+FROM deliveryservice_request r
+JOIN tm_user u ON u.id = r.author_id OR u.id = r.assignee_id
+WHERE r.id = $1
+AND u.id <> $2
+AND u.email IS NOT NULL
+AND u.email <> ''
+`
+
+const approvalEmail = "From: %s\r\nTo: %s\r\nSubject: Delivery Service Request #%d for %s approved\r\n\r\n" +
+	"%s approved Delivery Service Request #%d to %s Delivery Service '%s'. It now has %d of the %d approvals it requires.\r\n"
+
+// dsrCurrentTenantsQuery selects the Tenants of the existing Delivery
+// Services which an update request may apply to, whether by ID or XMLID.
+const dsrCurrentTenantsQuery = `SELECT DISTINCT tenant_id FROM deliveryservice WHERE id = $1 OR xml_id = $2`
+
+// dsrTenantIDs returns the IDs of the Tenants whose Approval Policies govern
+// a Delivery Service Request: that of the Delivery Service it creates or
+// deletes, or, for an update, both the Tenant it requests and the Tenant the
+// Delivery Service is currently in. The requester controls the requested
+// Tenant, so an update moving a Delivery Service out of a Tenant must still
+// satisfy that Tenant's policy.
+func dsrTenantIDs(tx *sql.Tx, dsr tc.DeliveryServiceRequestV40) ([]int, error) {
+	ds := dsr.Requested
+	if dsr.ChangeType == tc.DSRChangeTypeDelete {
+		ds = dsr.Original
+	}
+	if ds == nil || ds.TenantID == nil {
+		return nil, fmt.Errorf("Delivery Service Request has no %s Delivery Service Tenant", dsr.ChangeType)
+	}
+	tenantIDs := []int{*ds.TenantID}
+	if dsr.ChangeType != tc.DSRChangeTypeUpdate {
+		return tenantIDs, nil
+	}
+
+	if dsr.Original != nil && dsr.Original.TenantID != nil {
+		tenantIDs = appendTenantID(tenantIDs, *dsr.Original.TenantID)
+	}
+	rows, err := tx.Query(dsrCurrentTenantsQuery, dsr.Requested.ID, dsr.Requested.XMLID)
+	if err != nil {
+		return nil, fmt.Errorf("querying current Tenants of Delivery Service Request #%d: %v", *dsr.ID, err)
+	}
+	defer log.Close(rows, "closing Delivery Service Request Tenant rows")
+	for rows.Next() {
+		tenantID := 0
+		if err := rows.Scan(&tenantID); err != nil {
+			return nil, fmt.Errorf("scanning current Tenants of Delivery Service Request #%d: %v", *dsr.ID, err)
+		}
+		tenantIDs = appendTenantID(tenantIDs, tenantID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating over current Tenants of Delivery Service Request #%d: %v", *dsr.ID, err)
+	}
+	return tenantIDs, nil
+}
+
+// appendTenantID appends the given Tenant ID to the given IDs, unless it's
+// already one of them.
+func appendTenantID(tenantIDs []int, tenantID int) []int {
+	for _, id := range tenantIDs {
+		if id == tenantID {
+			return tenantIDs
+		}
+	}
+	return append(tenantIDs, tenantID)
+}
+
+func getApprovals(tx *sql.Tx, dsrID int) ([]tc.DeliveryServiceRequestApproval, error) {
+	rows, err := tx.Query(selectApprovalsQuery, dsrID)
+	if err != nil {
+		return nil, fmt.Errorf("querying approvals of Delivery Service Request #%d: %v", dsrID, err)
+	}
+	defer log.Close(rows, "closing Delivery Service Request approval rows")
+
+	approvals := []tc.DeliveryServiceRequestApproval{}
+	for rows.Next() {
+		a := tc.DeliveryServiceRequestApproval{}
+		if err := rows.Scan(&a.ID, &a.DeliveryServiceRequestID, &a.Approver, &a.Comment, &a.Approved); err != nil {
+			return nil, fmt.Errorf("scanning approvals of Delivery Service Request #%d: %v", dsrID, err)
+		}
+		approvals = append(approvals, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating over approvals of Delivery Service Request #%d: %v", dsrID, err)
+	}
+	return approvals, nil
+}
+
+// getApprovalState returns the approval state of the given Delivery Service
+// Request under the policies governing its Delivery Service's Tenants. It
+// requires as many approvals as the strictest of them.
+func getApprovalState(tx *sql.Tx, dsr tc.DeliveryServiceRequestV40) (tc.DeliveryServiceRequestApprovals, error) {
+	state := tc.DeliveryServiceRequestApprovals{DeliveryServiceRequestID: *dsr.ID, Policies: []tc.DSRApprovalPolicy{}}
+	tenantIDs, err := dsrTenantIDs(tx, dsr)
+	if err != nil {
+		return state, err
+	}
+	for _, tenantID := range tenantIDs {
+		policy, err := getGoverningApprovalPolicy(tx, tenantID)
+		if err != nil {
+			return state, err
+		}
+		if policy == nil || hasApprovalPolicy(state.Policies, *policy.TenantID) {
+			continue
+		}
+		state.Policies = append(state.Policies, *policy)
+		if state.Policy == nil || *policy.RequiredApprovals > state.RequiredApprovals {
+			state.Policy = policy
+			state.RequiredApprovals = *policy.RequiredApprovals
+		}
+	}
+	if state.Approvals, err = getApprovals(tx, *dsr.ID); err != nil {
+		return state, err
+	}
+	state.Approved = len(state.Approvals) >= state.RequiredApprovals
+	return state, nil
+}
+
+// hasApprovalPolicy returns whether the given policies include that of the
+// Tenant with the given ID, which may govern more than one of a Delivery
+// Service Request's Tenants.
+func hasApprovalPolicy(policies []tc.DSRApprovalPolicy, tenantID int) bool {
+	for _, p := range policies {
+		if *p.TenantID == tenantID {
+			return true
+		}
+	}
+	return false
+}
+
+// checkApprovedTransition returns an error suitable for returning to the
+// user if the Delivery Service Request may not move to the given status
+// because it hasn't been approved as its policy requires.
+func checkApprovedTransition(state tc.DeliveryServiceRequestApprovals, from tc.RequestStatus, to tc.RequestStatus) error {
+	if from == to || (to != tc.RequestStatusPending && to != tc.RequestStatusComplete) || state.Approved {
+		return nil
+	}
+	return fmt.Errorf("Delivery Service Request #%d requires %d approvals before it can be '%s', but has %d", state.DeliveryServiceRequestID, state.RequiredApprovals, to, len(state.Approvals))
+}
+
+// checkApprover returns an error suitable for returning to the user, and its
+// status code, if the given user may not approve the Delivery Service
+// Request.
+func checkApprover(state tc.DeliveryServiceRequestApprovals, dsr tc.DeliveryServiceRequestV40, userID int, userName string, roleName string) (int, error) {
+	if dsr.Status != tc.RequestStatusSubmitted {
+		return http.StatusBadRequest, fmt.Errorf("only '%s' Delivery Service Requests can be approved, this one is '%s'", tc.RequestStatusSubmitted, dsr.Status)
+	}
+	for _, a := range state.Approvals {
+		if a.Approver == userName {
+			return http.StatusConflict, fmt.Errorf("you have already approved Delivery Service Request #%d", *dsr.ID)
+		}
+	}
+	for _, policy := range state.Policies {
+		if !policy.MayApprove(roleName) {
+			return http.StatusForbidden, fmt.Errorf("users with the '%s' Role may not approve Delivery Service Requests for Tenant '%s'", roleName, *policy.Tenant)
+		}
+		if !*policy.AllowSelfApproval && dsr.AuthorID != nil && *dsr.AuthorID == userID {
+			return http.StatusForbidden, errors.New("you may not approve your own Delivery Service Request")
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// clearApprovals deletes all approvals of the given Delivery Service
+// Request, which must be approved again once it has changed.
+func clearApprovals(tx *sql.Tx, dsrID int) error {
+	if _, err := tx.Exec(deleteApprovalsQuery, dsrID); err != nil {
+		return fmt.Errorf("clearing approvals of Delivery Service Request #%d: %v", dsrID, err)
+	}
+	return nil
+}
+
+// getDSR returns the Delivery Service Request with the given ID, checking
+// that the current user is authorized on its Tenant. The returned errors and
+// status code are suitable for passing to api.HandleErr.
+func getDSR(inf *api.APIInfo, id int) (tc.DeliveryServiceRequestV40, int, error, error) {
+	var dsr tc.DeliveryServiceRequestV40
+	if err := inf.Tx.QueryRowx(selectQuery+"WHERE r.id=$1", id).StructScan(&dsr); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return dsr, http.StatusNotFound, fmt.Errorf("no such Delivery Service Request: %d", id), nil
+		}
+		return dsr, http.StatusInternalServerError, nil, fmt.Errorf("looking for DSR: %v", err)
+	}
+	dsr.SetXMLID()
+
+	authorized, err := isTenantAuthorized(dsr, inf)
+	if err != nil {
+		return dsr, http.StatusInternalServerError, nil, err
+	}
+	if !authorized {
+		return dsr, http.StatusForbidden, errors.New("not authorized on this tenant"), nil
+	}
+	return dsr, http.StatusOK, nil, nil
+}
+
+// GetApprovals is the handler for GET requests to
+// /deliveryservice_requests/{{ID}}/approvals.
+func GetApprovals(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	tx := inf.Tx.Tx
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	dsr, errCode, userErr, sysErr := getDSR(inf, inf.IntParams["id"])
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	state, err := getApprovalState(tx, dsr)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	api.WriteResp(w, r, state)
+}
+
+// PostApproval is the handler for POST requests to
+// /deliveryservice_requests/{{ID}}/approvals, by which the current user
+// approves a Delivery Service Request.
+func PostApproval(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	tx := inf.Tx.Tx
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	var req tc.DeliveryServiceRequestApprovalRequest
+	if err := api.Parse(r.Body, tx, &req); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	dsr, errCode, userErr, sysErr := getDSR(inf, inf.IntParams["id"])
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	// Lock the request so that concurrent approvals and status changes are
+	// evaluated against each other.
+	if _, err := tx.Exec(`SELECT id FROM deliveryservice_request WHERE id = $1 FOR UPDATE`, *dsr.ID); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("locking Delivery Service Request #%d: %v", *dsr.ID, err))
+		return
+	}
+
+	state, err := getApprovalState(tx, dsr)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	if errCode, err := checkApprover(state, dsr, inf.User.ID, inf.User.UserName, inf.User.RoleName); err != nil {
+		api.HandleErr(w, r, tx, errCode, err, nil)
+		return
+	}
+
+	approval := tc.DeliveryServiceRequestApproval{
+		DeliveryServiceRequestID: *dsr.ID,
+		Approver:                 inf.User.UserName,
+		Comment:                  req.Comment,
+	}
+	if err := tx.QueryRow(insertApprovalQuery, dsr.ID, inf.User.ID, req.Comment).Scan(&approval.ID, &approval.Approved); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			api.HandleErr(w, r, tx, http.StatusConflict, fmt.Errorf("you have already approved Delivery Service Request #%d", *dsr.ID), nil)
+			return
+		}
+		userErr, sysErr, errCode = api.ParseDBError(err)
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	state.Approvals = append(state.Approvals, approval)
+	state.Approved = len(state.Approvals) >= state.RequiredApprovals
+
+	message := fmt.Sprintf("Approved '%s' Delivery Service Request #%d (%d of %d required approvals)", dsr.XMLID, *dsr.ID, len(state.Approvals), state.RequiredApprovals)
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, message, state)
+	changeLogMsg := fmt.Sprintf("Delivery Service Request: %d, ID: %d, ACTION: %s deliveryservice_request, keys: {id:%d }", *dsr.ID, *dsr.ID, message, *dsr.ID)
+	api.CreateChangeLogRawTx(api.ApiChange, changeLogMsg, inf.User, tx)
+
+	if notifyByEmail(state.Policies) && inf.Config.SMTP != nil && inf.Config.SMTP.Enabled && inf.Config.ConfigTO != nil {
+		recipients, err := getApprovalNotificationRecipients(tx, *dsr.ID, inf.User.ID)
+		if err != nil {
+			log.Errorf("not sending approval notifications for Delivery Service Request #%d: %v", *dsr.ID, err)
+			return
+		}
+		go sendApprovalNotifications(inf.Config, recipients, dsr, state, inf.User.UserName)
+	}
+}
+
+// notifyByEmail returns whether any of the given policies require emailing
+// approvals.
+func notifyByEmail(policies []tc.DSRApprovalPolicy) bool {
+	for _, p := range policies {
+		if *p.NotifyByEmail {
+			return true
+		}
+	}
+	return false
+}
+
+func getApprovalNotificationRecipients(tx *sql.Tx, dsrID int, approverID int) ([]string, error) {
+	rows, err := tx.Query(approvalNotificationRecipientsQuery, dsrID, approverID)
+	if err != nil {
+		return nil, fmt.Errorf("querying recipients: %v", err)
+	}
+	defer log.Close(rows, "closing approval notification recipient rows")
+	recipients := []string{}
+	for rows.Next() {
+		email := ""
+		if err := rows.Scan(&email); err != nil {
+			return nil, fmt.Errorf("scanning recipients: %v", err)
+		}
+		recipients = append(recipients, email)
+	}
+	return recipients, rows.Err()
+}
+
+// sendApprovalNotifications emails each of the recipients that the Delivery
+// Service Request was approved. Failures are logged, but not returned, since
+// the approval itself has already succeeded.
+func sendApprovalNotifications(cfg *config.Config, recipients []string, dsr tc.DeliveryServiceRequestV40, state tc.DeliveryServiceRequestApprovals, approver string) {
+	for _, recipient := range recipients {
+		addr, err := mail.ParseAddress(recipient)
+		if err != nil {
+			log.Warnf("not sending approval notification for Delivery Service Request #%d to invalid address '%s': %v", *dsr.ID, recipient, err)
+			continue
+		}
+		msg := fmt.Sprintf(approvalEmail, cfg.ConfigTO.EmailFrom, addr, *dsr.ID, dsr.XMLID, approver, *dsr.ID, dsr.ChangeType, dsr.XMLID, len(state.Approvals), state.RequiredApprovals)
+		if _, userErr, sysErr := api.SendMail(rfc.EmailAddress{Address: *addr}, []byte(msg), cfg); userErr != nil || sysErr != nil {
+			log.Errorf("sending approval notification for Delivery Service Request #%d to '%s': %v %v", *dsr.ID, recipient, userErr, sysErr)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package request
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func testApprovalPolicy() *tc.DSRApprovalPolicy {
+	return &tc.DSRApprovalPolicy{
+		TenantID:          util.IntPtr(2),
+		Tenant:            util.StrPtr("child"),
+		RequiredApprovals: util.IntPtr(2),
+		ApproverRoles:     []string{"admin", "operations"},
+		AllowSelfApproval: util.BoolPtr(false),
+		NotifyByEmail:     util.BoolPtr(false),
+	}
+}
+
+func TestDSRTenantIDs(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("opening mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	defer db.Close()
+
+	dsr := tc.DeliveryServiceRequestV40{
+		ID:         util.IntPtr(7),
+		ChangeType: tc.DSRChangeTypeUpdate,
+		Requested:  &tc.DeliveryServiceV4{},
+		Original:   &tc.DeliveryServiceV4{},
+	}
+	dsr.Requested.ID = util.IntPtr(1)
+	dsr.Requested.XMLID = util.StrPtr("ds1")
+	dsr.Requested.TenantID = util.IntPtr(3)
+	dsr.Original.TenantID = util.IntPtr(4)
+
+	// an update is governed by the requested Tenant, and by the Tenants the
+	// Delivery Service was and is in
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT DISTINCT tenant_id").WithArgs(1, "ds1").WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}).AddRow(4).AddRow(5))
+	if ids, err := dsrTenantIDs(db.MustBegin().Tx, dsr); err != nil || !reflect.DeepEqual(ids, []int{3, 4, 5}) {
+		t.Errorf("expected an update request to use the requested, original, and current Tenants [3 4 5], got %v, %v", ids, err)
+	}
+	dsr.ChangeType = tc.DSRChangeTypeDelete
+	if ids, err := dsrTenantIDs(nil, dsr); err != nil || !reflect.DeepEqual(ids, []int{4}) {
+		t.Errorf("expected a delete request to use the original Tenant [4], got %v, %v", ids, err)
+	}
+	dsr.Original = nil
+	if _, err := dsrTenantIDs(nil, dsr); err == nil {
+		t.Error("expected an error for a delete request with no original")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCheckApprovedTransition(t *testing.T) {
+	state := tc.DeliveryServiceRequestApprovals{
+		DeliveryServiceRequestID: 1,
+		Policy:                   testApprovalPolicy(),
+		RequiredApprovals:        2,
+		Approvals:                []tc.DeliveryServiceRequestApproval{{Approver: "a"}},
+	}
+
+	for _, to := range []tc.RequestStatus{tc.RequestStatusPending, tc.RequestStatusComplete} {
+		if err := checkApprovedTransition(state, tc.RequestStatusSubmitted, to); err == nil {
+			t.Errorf("expected an unapproved request not to be allowed to become '%s'", to)
+		}
+	}
+	for _, to := range []tc.RequestStatus{tc.RequestStatusDraft, tc.RequestStatusRejected, tc.RequestStatusSubmitted} {
+		if err := checkApprovedTransition(state, tc.RequestStatusSubmitted, to); err != nil {
+			t.Errorf("expected an unapproved request to be allowed to become '%s', got: %v", to, err)
+		}
+	}
+
+	state.Approvals = append(state.Approvals, tc.DeliveryServiceRequestApproval{Approver: "b"})
+	state.Approved = true
+	if err := checkApprovedTransition(state, tc.RequestStatusSubmitted, tc.RequestStatusComplete); err != nil {
+		t.Errorf("expected an approved request to be allowed to become complete, got: %v", err)
+	}
+}
+
+func TestCheckApprover(t *testing.T) {
+	dsr := tc.DeliveryServiceRequestV40{
+		ID:       util.IntPtr(7),
+		AuthorID: util.IntPtr(10),
+		Status:   tc.RequestStatusSubmitted,
+	}
+	state := tc.DeliveryServiceRequestApprovals{
+		DeliveryServiceRequestID: 7,
+		Policy:                   testApprovalPolicy(),
+		Policies:                 []tc.DSRApprovalPolicy{*testApprovalPolicy()},
+		RequiredApprovals:        2,
+		Approvals:                []tc.DeliveryServiceRequestApproval{{Approver: "alice"}},
+	}
+
+	tests := []struct {
+		name     string
+		userID   int
+		userName string
+		role     string
+		status   tc.RequestStatus
+		expected int
+	}{
+		{"allowed approver", 11, "bob", "operations", tc.RequestStatusSubmitted, http.StatusOK},
+		{"draft request", 11, "bob", "operations", tc.RequestStatusDraft, http.StatusBadRequest},
+		{"pending request", 11, "bob", "operations", tc.RequestStatusPending, http.StatusBadRequest},
+		{"repeat approval", 12, "alice", "operations", tc.RequestStatusSubmitted, http.StatusConflict},
+		{"disallowed role", 11, "bob", "portal", tc.RequestStatusSubmitted, http.StatusForbidden},
+		{"self-approval", 10, "carol", "admin", tc.RequestStatusSubmitted, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dsr.Status = test.status
+			code, err := checkApprover(state, dsr, test.userID, test.userName, test.role)
+			if code != test.expected {
+				t.Errorf("expected status %d, got %d (%v)", test.expected, code, err)
+			}
+			if (err == nil) != (test.expected == http.StatusOK) {
+				t.Errorf("expected error only for a non-OK status, got: %v", err)
+			}
+		})
+	}
+
+	dsr.Status = tc.RequestStatusSubmitted
+	state.Policies[0].AllowSelfApproval = util.BoolPtr(true)
+	if _, err := checkApprover(state, dsr, 10, "carol", "admin"); err != nil {
+		t.Errorf("expected self-approval to be allowed by the policy, got: %v", err)
+	}
+
+	// approvers must satisfy every policy governing the request
+	other := testApprovalPolicy()
+	other.Tenant = util.StrPtr("other")
+	other.ApproverRoles = []string{"admin"}
+	state.Policies = append(state.Policies, *other)
+	if code, err := checkApprover(state, dsr, 11, "bob", "operations"); code != http.StatusForbidden {
+		t.Errorf("expected an approver disallowed by one of the policies to be forbidden, got %d (%v)", code, err)
+	}
+	if _, err := checkApprover(state, dsr, 10, "carol", "admin"); err == nil {
+		t.Error("expected self-approval to be forbidden by one of the policies, got no error")
+	}
+
+	state.Policy = nil
+	state.Policies = nil
+	if _, err := checkApprover(state, dsr, 10, "carol", "portal"); err != nil {
+		t.Errorf("expected anyone to be able to approve without a policy, got: %v", err)
+	}
+}
+
+func TestGetApprovalState(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("opening mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	defer db.Close()
+
+	dsr := tc.DeliveryServiceRequestV40{
+		ID:         util.IntPtr(7),
+		ChangeType: tc.DSRChangeTypeCreate,
+		Requested:  &tc.DeliveryServiceV4{},
+	}
+	dsr.Requested.TenantID = util.IntPtr(3)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	policyRows := sqlmock.NewRows([]string{"tenant_id", "tenant", "required_approvals", "approver_roles", "allow_self_approval", "notify_by_email", "last_updated"})
+	policyRows.AddRow(2, "parent", 2, "{admin}", false, true, now)
+	mock.ExpectQuery("WITH RECURSIVE ancestor").WithArgs(3).WillReturnRows(policyRows)
+	approvalRows := sqlmock.NewRows([]string{"id", "deliveryservice_request", "username", "comment", "approved"})
+	approvalRows.AddRow(1, 7, "alice", "looks good", now)
+	approvalRows.AddRow(2, 7, "bob", nil, now)
+	mock.ExpectQuery("SELECT a.id").WithArgs(7).WillReturnRows(approvalRows)
+
+	state, err := getApprovalState(db.MustBegin().Tx, dsr)
+	if err != nil {
+		t.Fatalf("unexpected error getting approval state: %v", err)
+	}
+	if state.Policy == nil || *state.Policy.Tenant != "parent" || len(state.Policy.ApproverRoles) != 1 || len(state.Policies) != 1 {
+		t.Errorf("expected the policy of the 'parent' Tenant, got: %+v", state.Policy)
+	}
+	if state.RequiredApprovals != 2 || len(state.Approvals) != 2 || !state.Approved {
+		t.Errorf("expected 2 of 2 required approvals, got %d of %d (approved: %t)", len(state.Approvals), state.RequiredApprovals, state.Approved)
+	}
+	if state.Approvals[0].Comment == nil || *state.Approvals[0].Comment != "looks good" || state.Approvals[1].Comment != nil {
+		t.Errorf("unexpected approval comments: %+v", state.Approvals)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("WITH RECURSIVE ancestor").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}))
+	mock.ExpectQuery("SELECT a.id").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"id", "deliveryservice_request", "username", "comment", "approved"}))
+	if state, err = getApprovalState(db.MustBegin().Tx, dsr); err != nil {
+		t.Fatalf("unexpected error getting approval state without a policy: %v", err)
+	}
+	if state.Policy != nil || len(state.Policies) != 0 || state.RequiredApprovals != 0 || !state.Approved {
+		t.Errorf("expected a request with no policy to need no approvals, got: %+v", state)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetApprovalStateUpdate(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("opening mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	defer db.Close()
+
+	dsr := tc.DeliveryServiceRequestV40{
+		ID:         util.IntPtr(7),
+		ChangeType: tc.DSRChangeTypeUpdate,
+		Requested:  &tc.DeliveryServiceV4{},
+	}
+	dsr.Requested.ID = util.IntPtr(1)
+	dsr.Requested.XMLID = util.StrPtr("ds1")
+	dsr.Requested.TenantID = util.IntPtr(3)
+
+	// moving a Delivery Service from a Tenant requiring 3 approvals to one
+	// with no policy still requires 3
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT DISTINCT tenant_id").WithArgs(1, "ds1").WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}).AddRow(4))
+	mock.ExpectQuery("WITH RECURSIVE ancestor").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}))
+	policyRows := sqlmock.NewRows([]string{"tenant_id", "tenant", "required_approvals", "approver_roles", "allow_self_approval", "notify_by_email", "last_updated"})
+	policyRows.AddRow(4, "strict", 3, "{admin}", false, false, now)
+	mock.ExpectQuery("WITH RECURSIVE ancestor").WithArgs(4).WillReturnRows(policyRows)
+	approvalRows := sqlmock.NewRows([]string{"id", "deliveryservice_request", "username", "comment", "approved"})
+	approvalRows.AddRow(1, 7, "alice", nil, now)
+	mock.ExpectQuery("SELECT a.id").WithArgs(7).WillReturnRows(approvalRows)
+
+	state, err := getApprovalState(db.MustBegin().Tx, dsr)
+	if err != nil {
+		t.Fatalf("unexpected error getting approval state: %v", err)
+	}
+	if state.Policy == nil || *state.Policy.Tenant != "strict" || len(state.Policies) != 1 || state.RequiredApprovals != 3 || state.Approved {
+		t.Errorf("expected the policy of the 'strict' Tenant the Delivery Service is in to require 3 approvals, got: %+v", state)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
@@ -889,6 +889,12 @@ func Put(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Approvals are of the request as it was, so they don't survive changes.
+	if err := clearApprovals(tx, id); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+
 	var result dsrManipulationResult
 	if inf.Version.Major >= 4 {
 		result = putV40(w, r, inf)
@@ -29,6 +29,7 @@ import (
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/deliveryservice"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/routing/middleware"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/webhook"
 )
 
 // GetStatus is the handler for GET requests to
@@ -162,6 +163,28 @@ func PutStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Lock the request so that concurrent approvals and status changes are
+	// evaluated against each other.
+	if _, err := tx.Exec(`SELECT id FROM deliveryservice_request WHERE id = $1 FOR UPDATE`, dsrID); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("locking Delivery Service Request #%d: %v", dsrID, err))
+		return
+	}
+	approvals, err := getApprovalState(tx, dsr)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	if err := checkApprovedTransition(approvals, dsr.Status, req.Status); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
+		return
+	}
+	if req.Status == tc.RequestStatusDraft && dsr.Status != tc.RequestStatusDraft {
+		if err := clearApprovals(tx, dsrID); err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+			return
+		}
+	}
+
 	dsr.LastEditedBy = inf.User.UserName
 	dsr.LastEditedByID = new(int)
 	*dsr.LastEditedByID = inf.User.ID
@@ -222,6 +245,12 @@ func PutStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	message := fmt.Sprintf("Changed status of '%s' Delivery Service Request from '%s' to '%s'", dsr.XMLID, dsr.Status, req.Status)
+	event := tc.WebhookDeliveryServiceRequestStatusData{
+		ID:        *dsr.ID,
+		XMLID:     dsr.XMLID,
+		OldStatus: dsr.Status,
+		NewStatus: req.Status,
+	}
 	dsr.Status = req.Status
 
 	var resp interface{}
@@ -234,4 +263,5 @@ func PutStatus(w http.ResponseWriter, r *http.Request) {
 	api.WriteRespAlertObj(w, r, tc.SuccessLevel, message, resp)
 	message = fmt.Sprintf("Delivery Service Request: %d, ID: %d, ACTION: %s deliveryservice_request, keys: {id:%d }", *dsr.ID, *dsr.ID, message, *dsr.ID)
 	inf.CreateChangeLog(message)
+	webhook.Enqueue(tx, tc.WebhookEventDeliveryServiceRequestStatus, inf.User, event)
 }
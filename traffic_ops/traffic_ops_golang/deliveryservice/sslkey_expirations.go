@@ -0,0 +1,408 @@
+package deliveryservice
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-rfc"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tenant"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/trafficvault"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/webhook"
+
+	"github.com/lib/pq"
+)
+
+// SSLKeyExpirationCheckInterval is how often each Traffic Ops instance
+// checks whether the periodic SSL certificate expiration alerts are due.
+const SSLKeyExpirationCheckInterval = time.Hour
+
+// sslKeyExpirationCheckTimeout limits the time taken by a single expiration
+// alert check, most of which is spent fetching keys from Traffic Vault.
+const sslKeyExpirationCheckTimeout = 10 * time.Minute
+
+// sslKeyExpirationNotificationPrefix begins the text of every CDN
+// notification posted about expiring certificates, so that they can be
+// replaced by the next check.
+const sslKeyExpirationNotificationPrefix = "Expiring SSL certificates: "
+
+const sslKeyExpirationsQuery = `
+SELECT ds.xml_id, ds.ssl_key_version, cdn.name
+FROM deliveryservice AS ds
+JOIN cdn ON cdn.id = ds.cdn_id
+WHERE ds.ssl_key_version != 0
+`
+
+// claimSSLKeyExpirationAlertQuery records that the alerts are being sent
+// now ($1), unless they were last sent after $2, in which case no row is
+// returned. It serves to send the alerts only once for all Traffic Ops
+// instances serving the same database.
+const claimSSLKeyExpirationAlertQuery = `
+INSERT INTO sslkey_expiration_alert (last_run) VALUES ($1)
+ON CONFLICT (id) DO UPDATE SET last_run = EXCLUDED.last_run
+WHERE sslkey_expiration_alert.last_run <= $2
+RETURNING last_run
+`
+
+const deleteSSLKeyExpirationNotificationsQuery = `
+DELETE FROM cdn_notification
+WHERE "user" = $1
+AND notification LIKE $2 || '%'
+`
+
+const insertSSLKeyExpirationNotificationQuery = `
+INSERT INTO cdn_notification (cdn, "user", notification)
+VALUES ($1, $2, $3)
+`
+
+const sslKeyExpirationEmail = "From: %s\r\nTo: %s\r\nSubject: %d SSL certificate(s) expiring within %d days\r\n\r\n" +
+	"The following Delivery Service SSL certificates expire within %d days:\r\n\r\n%s"
+
+// sslKeyExpirationFilter restricts the certificates described by
+// getSSLKeyExpirations.
+type sslKeyExpirationFilter struct {
+	// CDN, if not empty, is the name of the only CDN whose Delivery
+	// Services' certificates are described.
+	CDN string
+	// Days, if not nil, excludes certificates which expire more than that
+	// many days from now.
+	Days *int
+	// TenantIDs, if not nil, are the only Tenants whose Delivery Services'
+	// certificates are described.
+	TenantIDs []int
+}
+
+// GetSSLKeyExpirations is the handler for GET requests to
+// /sslkeys/expirations.
+func GetSSLKeyExpirations(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	if !inf.Config.TrafficVaultEnabled {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("getting SSL key expirations: Traffic Vault is not configured"))
+		return
+	}
+
+	filter := sslKeyExpirationFilter{CDN: inf.Params["cdn"]}
+	if daysStr, ok := inf.Params["days"]; ok {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil {
+			api.HandleErr(w, r, tx, http.StatusBadRequest, errors.New("'days' must be an integer"), nil)
+			return
+		}
+		filter.Days = &days
+	}
+
+	tenantIDs, err := tenant.GetUserTenantIDListTx(tx, inf.User.TenantID)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("getting accessible tenants for user: "+err.Error()))
+		return
+	}
+	filter.TenantIDs = tenantIDs
+
+	expirations, keyErrs, err := getSSLKeyExpirations(tx, inf.Vault, inf.Config, r.Context(), filter, time.Now())
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	if len(keyErrs) == 0 {
+		api.WriteResp(w, r, expirations)
+		return
+	}
+
+	alerts := tc.Alerts{}
+	for _, keyErr := range keyErrs {
+		log.Warnln(keyErr.Error())
+		alerts.AddNewAlert(tc.WarnLevel, keyErr.Error())
+	}
+	api.WriteAlertsObj(w, r, http.StatusOK, alerts, expirations)
+}
+
+// getSSLKeyExpirations describes the certificates of the current SSL keys of
+// the Delivery Services which match the filter, in order of expiration. The
+// certificates of Delivery Services whose keys can't be retrieved or parsed
+// are omitted, and the reasons returned as the second return value.
+func getSSLKeyExpirations(tx *sql.Tx, tv trafficvault.TrafficVault, cfg *config.Config, ctx context.Context, filter sslKeyExpirationFilter, now time.Time) ([]tc.SSLKeyExpiration, []error, error) {
+	query := sslKeyExpirationsQuery
+	args := []interface{}{}
+	if filter.CDN != "" {
+		args = append(args, filter.CDN)
+		query += " AND cdn.name = $" + strconv.Itoa(len(args))
+	}
+	if filter.TenantIDs != nil {
+		args = append(args, pq.Array(filter.TenantIDs))
+		query += " AND ds.tenant_id = ANY($" + strconv.Itoa(len(args)) + ")"
+	}
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, nil, errors.New("querying Delivery Services with SSL keys: " + err.Error())
+	}
+	// The keys can't be retrieved until the rows are closed, because some
+	// Traffic Vault backends query the same transaction.
+	candidates := []tc.SSLKeyExpiration{}
+	for rows.Next() {
+		exp := tc.SSLKeyExpiration{}
+		if err := rows.Scan(&exp.DeliveryService, &exp.Version, &exp.CDN); err != nil {
+			rows.Close()
+			return nil, nil, errors.New("scanning Delivery Services with SSL keys: " + err.Error())
+		}
+		candidates = append(candidates, exp)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.New("iterating over Delivery Services with SSL keys: " + err.Error())
+	}
+
+	expirations := []tc.SSLKeyExpiration{}
+	keyErrs := []error{}
+	for _, exp := range candidates {
+		keys, ok, err := tv.GetDeliveryServiceSSLKeys(exp.DeliveryService, strconv.Itoa(exp.Version), tx, ctx)
+		if err != nil {
+			keyErrs = append(keyErrs, fmt.Errorf("getting SSL keys for Delivery Service '%s': %v", exp.DeliveryService, err))
+			continue
+		}
+		if !ok {
+			keyErrs = append(keyErrs, fmt.Errorf("version %d of the SSL keys for Delivery Service '%s' was not found in Traffic Vault", exp.Version, exp.DeliveryService))
+			continue
+		}
+		if err := Base64DecodeCertificate(&keys.Certificate); err != nil {
+			keyErrs = append(keyErrs, fmt.Errorf("decoding the SSL keys for Delivery Service '%s': %v", exp.DeliveryService, err))
+			continue
+		}
+		if err := describeCertificate([]byte(keys.Certificate.Crt), &exp); err != nil {
+			keyErrs = append(keyErrs, fmt.Errorf("parsing the certificate of Delivery Service '%s': %v", exp.DeliveryService, err))
+			continue
+		}
+
+		exp.DaysUntilExpiration = int(math.Floor(exp.Expiration.Sub(now).Hours() / 24))
+		if filter.Days != nil && exp.DaysUntilExpiration > *filter.Days {
+			continue
+		}
+		exp.AuthType = keys.AuthType
+		exp.AutoRenew = autoRenewable(cfg, keys.AuthType)
+		expirations = append(expirations, exp)
+	}
+
+	sort.SliceStable(expirations, func(i, j int) bool {
+		return expirations[i].Expiration.Before(expirations[j].Expiration)
+	})
+	return expirations, keyErrs, nil
+}
+
+// describeCertificate sets the expiration, issuer, SANs and key type of exp
+// from the first certificate in the given PEM-encoded chain.
+func describeCertificate(crt []byte, exp *tc.SSLKeyExpiration) error {
+	block, _ := pem.Decode(crt)
+	if block == nil {
+		return errors.New("no PEM-encoded certificate found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return err
+	}
+
+	exp.Expiration = cert.NotAfter
+	exp.Issuer = cert.Issuer.String()
+	exp.SANs = make([]string, 0, len(cert.DNSNames)+len(cert.IPAddresses))
+	exp.SANs = append(exp.SANs, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		exp.SANs = append(exp.SANs, ip.String())
+	}
+
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		exp.KeyType = "RSA " + strconv.Itoa(key.N.BitLen())
+	case *ecdsa.PublicKey:
+		exp.KeyType = "ECDSA " + key.Curve.Params().Name
+	case ed25519.PublicKey:
+		exp.KeyType = "Ed25519"
+	default:
+		exp.KeyType = cert.PublicKeyAlgorithm.String()
+	}
+	return nil
+}
+
+// autoRenewable returns whether RunAutorenewal renews certificates of the
+// given auth type.
+func autoRenewable(cfg *config.Config, authType string) bool {
+	switch authType {
+	case tc.LetsEncryptAuthType:
+		return true
+	case tc.SelfSignedCertAuthType:
+		return cfg.ConfigLetsEncrypt.ConvertSelfSigned
+	}
+	return GetAcmeAccountConfig(cfg, authType) != nil
+}
+
+// StartSSLKeyExpirationAlerts starts periodically alerting about Delivery
+// Service SSL certificates which will soon expire, as configured by
+// cfg.CertExpirationAlerts, for the life of the process. It does nothing if
+// the alerts aren't enabled, or Traffic Vault isn't.
+func StartSSLKeyExpirationAlerts(db *sql.DB, cfg *config.Config, tv trafficvault.TrafficVault) {
+	if !cfg.CertExpirationAlerts.Enabled || !cfg.TrafficVaultEnabled {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(SSLKeyExpirationCheckInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if err := AlertSSLKeyExpirations(db, cfg, tv, now); err != nil {
+				log.Errorln("SSL certificate expiration alerts: " + err.Error())
+			}
+		}
+	}()
+}
+
+// AlertSSLKeyExpirations alerts about the Delivery Service SSL certificates
+// which expire within cfg.CertExpirationAlerts.DaysBeforeExpiration days of
+// now, unless that was already done - by any Traffic Ops instance - within
+// the last cfg.CertExpirationAlerts.IntervalHours hours.
+//
+// It enqueues a Webhook event describing them, replaces the CDN notifications
+// posted by cfg.CertExpirationAlerts.NotificationUser about them, if set, and
+// emails a digest of them to cfg.CertExpirationAlerts.DigestEmail, if set
+// and SMTP is enabled. Nothing is sent if no certificates expire soon,
+// although outdated CDN notifications are still removed.
+func AlertSSLKeyExpirations(db *sql.DB, cfg *config.Config, tv trafficvault.TrafficVault, now time.Time) error {
+	alertCfg := cfg.CertExpirationAlerts
+	ctx, cancel := context.WithTimeout(context.Background(), sslKeyExpirationCheckTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("beginning transaction: " + err.Error())
+	}
+	defer tx.Rollback()
+
+	// Ticks aren't exact, so allow a little slack lest an alert due on this
+	// tick is put off until the next.
+	dueAfter := now.Add(-time.Duration(alertCfg.IntervalHours)*time.Hour + time.Minute)
+	lastRun := time.Time{}
+	if err := tx.QueryRow(claimSSLKeyExpirationAlertQuery, now, dueAfter).Scan(&lastRun); err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return errors.New("claiming alert run: " + err.Error())
+	}
+
+	days := alertCfg.DaysBeforeExpiration
+	expirations, keyErrs, err := getSSLKeyExpirations(tx, tv, cfg, ctx, sslKeyExpirationFilter{Days: &days}, now)
+	if err != nil {
+		return err
+	}
+	for _, keyErr := range keyErrs {
+		log.Warnln("SSL certificate expiration alerts: " + keyErr.Error())
+	}
+
+	if alertCfg.NotificationUser != "" {
+		if err := replaceSSLKeyExpirationNotifications(tx, alertCfg.NotificationUser, days, expirations); err != nil {
+			return err
+		}
+	}
+	if len(expirations) > 0 {
+		webhook.Enqueue(tx, tc.WebhookEventSSLKeysExpiring, nil, tc.WebhookSSLKeysExpiringData{Days: days, Expirations: expirations})
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.New("committing transaction: " + err.Error())
+	}
+
+	if len(expirations) > 0 && alertCfg.DigestEmail != "" && cfg.SMTP != nil && cfg.SMTP.Enabled {
+		if err := sendSSLKeyExpirationDigest(cfg, alertCfg.DigestEmail, days, expirations); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceSSLKeyExpirationNotifications removes the CDN notifications
+// previously posted by user about expiring certificates, and posts one to
+// each CDN with any of the given expirations.
+func replaceSSLKeyExpirationNotifications(tx *sql.Tx, user string, days int, expirations []tc.SSLKeyExpiration) error {
+	exists := false
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM tm_user WHERE username = $1)`, user).Scan(&exists); err != nil {
+		return errors.New("checking notification user: " + err.Error())
+	}
+	if !exists {
+		log.Warnf("SSL certificate expiration alerts: notification user '%s' does not exist; not posting CDN notifications", user)
+		return nil
+	}
+
+	if _, err := tx.Exec(deleteSSLKeyExpirationNotificationsQuery, user, sslKeyExpirationNotificationPrefix); err != nil {
+		return errors.New("deleting outdated CDN notifications: " + err.Error())
+	}
+
+	cdns := []string{}
+	byCDN := map[string][]string{}
+	for _, exp := range expirations {
+		if _, ok := byCDN[exp.CDN]; !ok {
+			cdns = append(cdns, exp.CDN)
+		}
+		byCDN[exp.CDN] = append(byCDN[exp.CDN], exp.DeliveryService+" ("+exp.Expiration.Format("2006-01-02")+")")
+	}
+	for _, cdn := range cdns {
+		notification := fmt.Sprintf("%s%d Delivery Service certificate(s) expire within %d days: %s", sslKeyExpirationNotificationPrefix, len(byCDN[cdn]), days, strings.Join(byCDN[cdn], ", "))
+		if _, err := tx.Exec(insertSSLKeyExpirationNotificationQuery, cdn, user, notification); err != nil {
+			return fmt.Errorf("posting notification to CDN '%s': %v", cdn, err)
+		}
+	}
+	return nil
+}
+
+// sendSSLKeyExpirationDigest emails a plain-text list of the given
+// expirations to the given address.
+func sendSSLKeyExpirationDigest(cfg *config.Config, to string, days int, expirations []tc.SSLKeyExpiration) error {
+	addr, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("parsing digest email address '%s': %v", to, err)
+	}
+	lines := strings.Builder{}
+	for _, exp := range expirations {
+		fmt.Fprintf(&lines, "%s (CDN %s): expires %s (%d days), issued by %s, auto-renew: %t\r\n", exp.DeliveryService, exp.CDN, exp.Expiration.Format(time.RFC3339), exp.DaysUntilExpiration, exp.Issuer, exp.AutoRenew)
+	}
+	msg := fmt.Sprintf(sslKeyExpirationEmail, cfg.ConfigTO.EmailFrom, addr, len(expirations), days, days, lines.String())
+	if _, userErr, sysErr := api.SendMail(rfc.EmailAddress{Address: *addr}, []byte(msg), cfg); userErr != nil || sysErr != nil {
+		return fmt.Errorf("sending digest email: %v %v", userErr, sysErr)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package deliveryservice
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/trafficvault"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// testCertificate returns a PEM-encoded self-signed certificate for the given
+// names, which expires at notAfter.
+func testCertificate(t *testing.T, notAfter time.Time, dnsNames []string, ips []net.IP) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		Issuer:       pkix.Name{CommonName: "test"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// testVault is a Traffic Vault which serves the given SSL keys; its other
+// methods are unimplemented.
+type testVault struct {
+	trafficvault.TrafficVault
+	keys map[string]tc.DeliveryServiceSSLKeysV15
+}
+
+func (tv testVault) GetDeliveryServiceSSLKeys(xmlID string, version string, tx *sql.Tx, ctx context.Context) (tc.DeliveryServiceSSLKeysV15, bool, error) {
+	keys, ok := tv.keys[xmlID]
+	if !ok || version != keys.Version.String() {
+		return tc.DeliveryServiceSSLKeysV15{}, false, nil
+	}
+	return keys, true, nil
+}
+
+func testKeys(xmlID string, version int, authType string, crt string) tc.DeliveryServiceSSLKeysV15 {
+	keys := tc.DeliveryServiceSSLKeysV15{}
+	keys.DeliveryService = xmlID
+	keys.Version = util.JSONIntStr(version)
+	keys.AuthType = authType
+	keys.Certificate.Crt = base64.StdEncoding.EncodeToString([]byte(crt))
+	return keys
+}
+
+func TestDescribeCertificate(t *testing.T) {
+	notAfter := time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC)
+	crt := testCertificate(t, notAfter, []string{"demo1.mycdn.ciab.test", "*.demo1.mycdn.ciab.test"}, []net.IP{net.ParseIP("192.0.2.1")})
+
+	exp := tc.SSLKeyExpiration{}
+	if err := describeCertificate([]byte(crt), &exp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !exp.Expiration.Equal(notAfter) {
+		t.Errorf("expected expiration %v, got %v", notAfter, exp.Expiration)
+	}
+	if exp.Issuer != "CN=test" {
+		t.Errorf("expected issuer 'CN=test', got '%s'", exp.Issuer)
+	}
+	expectedSANs := []string{"demo1.mycdn.ciab.test", "*.demo1.mycdn.ciab.test", "192.0.2.1"}
+	if !reflect.DeepEqual(exp.SANs, expectedSANs) {
+		t.Errorf("expected SANs %v, got %v", expectedSANs, exp.SANs)
+	}
+	if exp.KeyType != "ECDSA P-256" {
+		t.Errorf("expected key type 'ECDSA P-256', got '%s'", exp.KeyType)
+	}
+
+	if err := describeCertificate([]byte(BadCertData), &exp); err == nil {
+		t.Error("expected an error describing a certificate that isn't PEM-encoded")
+	}
+}
+
+func TestAutoRenewable(t *testing.T) {
+	cfg := config.Config{AcmeAccounts: []config.ConfigAcmeAccount{{AcmeProvider: "Example CA"}}}
+	cases := map[string]bool{
+		tc.LetsEncryptAuthType:    true,
+		tc.SelfSignedCertAuthType: false,
+		"Example CA":              true,
+		"Certificate Authority":   false,
+		"":                        false,
+	}
+	for authType, expected := range cases {
+		if actual := autoRenewable(&cfg, authType); actual != expected {
+			t.Errorf("expected auth type '%s' to be auto-renewable: %t, got: %t", authType, expected, actual)
+		}
+	}
+
+	cfg.ConfigLetsEncrypt.ConvertSelfSigned = true
+	if !autoRenewable(&cfg, tc.SelfSignedCertAuthType) {
+		t.Error("expected self-signed certificates to be auto-renewable when converting them to Let's Encrypt")
+	}
+}
+
+func TestGetSSLKeyExpirations(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("opening mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	now := time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC)
+	soon := now.Add(10 * 24 * time.Hour)
+	later := now.Add(90 * 24 * time.Hour)
+	expired := now.Add(-time.Hour)
+	tv := testVault{keys: map[string]tc.DeliveryServiceSSLKeysV15{
+		"later":   testKeys("later", 2, tc.SelfSignedCertAuthType, testCertificate(t, later, []string{"later.test"}, nil)),
+		"soon":    testKeys("soon", 1, tc.LetsEncryptAuthType, testCertificate(t, soon, []string{"soon.test"}, nil)),
+		"expired": testKeys("expired", 3, tc.SelfSignedCertAuthType, testCertificate(t, expired, []string{"expired.test"}, nil)),
+		"bad":     testKeys("bad", 1, tc.SelfSignedCertAuthType, BadCertData),
+	}}
+
+	rows := sqlmock.NewRows([]string{"xml_id", "ssl_key_version", "name"})
+	rows.AddRow("later", 2, "cdn1")
+	rows.AddRow("soon", 1, "cdn1")
+	rows.AddRow("expired", 3, "cdn1")
+	rows.AddRow("bad", 1, "cdn1")
+	rows.AddRow("missing", 1, "cdn1")
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT ds.xml_id.*AND cdn.name = \\$1 AND ds.tenant_id = ANY\\(\\$2\\)").WithArgs("cdn1", sqlmock.AnyArg()).WillReturnRows(rows)
+
+	tx, err := mockDB.Begin()
+	if err != nil {
+		t.Fatalf("beginning transaction: %v", err)
+	}
+
+	days := 30
+	filter := sslKeyExpirationFilter{CDN: "cdn1", Days: &days, TenantIDs: []int{1}}
+	expirations, keyErrs, err := getSSLKeyExpirations(tx, tv, &config.Config{}, context.Background(), filter, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keyErrs) != 2 {
+		t.Errorf("expected errors for the bad and missing keys, got: %v", keyErrs)
+	}
+
+	if len(expirations) != 2 {
+		t.Fatalf("expected the expired and soon-to-expire certificates, got %d: %+v", len(expirations), expirations)
+	}
+	if expirations[0].DeliveryService != "expired" || expirations[1].DeliveryService != "soon" {
+		t.Errorf("expected certificates in order of expiration, got '%s' then '%s'", expirations[0].DeliveryService, expirations[1].DeliveryService)
+	}
+	if expirations[0].DaysUntilExpiration != -1 {
+		t.Errorf("expected expired certificate to have -1 days until expiration, got %d", expirations[0].DaysUntilExpiration)
+	}
+	if expirations[1].DaysUntilExpiration != 10 {
+		t.Errorf("expected certificate to have 10 days until expiration, got %d", expirations[1].DaysUntilExpiration)
+	}
+	if !expirations[1].AutoRenew || expirations[0].AutoRenew {
+		t.Errorf("expected only the Let's Encrypt certificate to be auto-renewable, got: %t, %t", expirations[0].AutoRenew, expirations[1].AutoRenew)
+	}
+	if expirations[1].CDN != "cdn1" || expirations[1].Version != 1 || expirations[1].AuthType != tc.LetsEncryptAuthType {
+		t.Errorf("unexpected description of certificate: %+v", expirations[1])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package invalidationjobs
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+
+	"github.com/lib/pq"
+)
+
+const acknowledgeQuery = `
+INSERT INTO job_acknowledgement (job, server)
+SELECT job.id, server.id
+FROM job, server
+WHERE job.id = ANY($1)
+  AND server.host_name = $2
+ON CONFLICT (job, server) DO UPDATE SET acknowledged = now()
+`
+
+const clearAcknowledgementsQuery = `DELETE FROM job_acknowledgement WHERE job=$1`
+
+// progressQuery selects the servers to which a job applies - those whose
+// reval or update flags are set when it's created, see revalQuery - and
+// whether each has acknowledged it.
+const progressQuery = `
+SELECT server.host_name,
+       EXISTS (
+         SELECT 1
+         FROM job_acknowledgement
+         WHERE job_acknowledgement.job = $1
+           AND job_acknowledgement.server = server.id
+       ) AS acknowledged
+FROM server
+WHERE server.status NOT IN (
+                             SELECT status.id
+                             FROM status
+                             WHERE name IN ('OFFLINE', 'PRE_PROD')
+                           )
+     AND server.profile IN (
+                             SELECT profile_parameter.profile
+                             FROM profile_parameter
+                             JOIN parameter ON parameter.id = profile_parameter.parameter
+                             WHERE parameter.name='location'
+                               AND parameter.config_file='regex_revalidate.config'
+                           )
+     AND server.cdn_id = (
+                           SELECT deliveryservice.cdn_id
+                           FROM deliveryservice
+                           WHERE deliveryservice.id = $2
+                         )
+ORDER BY server.host_name
+`
+
+// Acknowledge is the handler for POST requests to /jobs/acknowledgements,
+// which cache servers use to report the content invalidation jobs they have
+// applied. Unknown job IDs are ignored, since jobs may be deleted between a
+// cache fetching them and acknowledging them. Acknowledgements are frequent
+// and made by automation, so they aren't recorded in the change log.
+func Acknowledge(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	ack := tc.InvalidationJobAcknowledgement{}
+	if err := api.Parse(r.Body, tx, &ack); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	exists := false
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM server WHERE host_name=$1)`, *ack.HostName).Scan(&exists); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("checking server existence: "+err.Error()))
+		return
+	}
+	if !exists {
+		api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no server exists by host name '%s'", *ack.HostName), nil)
+		return
+	}
+
+	ids := make([]int64, 0, len(ack.JobIDs))
+	for _, id := range ack.JobIDs {
+		ids = append(ids, int64(id))
+	}
+	if _, err := tx.Exec(acknowledgeQuery, pq.Array(ids), *ack.HostName); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("inserting job acknowledgements: "+err.Error()))
+		return
+	}
+
+	api.WriteRespAlert(w, r, tc.SuccessLevel, "invalidation jobs were acknowledged.")
+}
+
+// GetProgress is the handler for GET requests to /jobs/{{ID}}/progress.
+func GetProgress(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+	id := inf.IntParams["id"]
+
+	progress := tc.InvalidationJobProgress{JobID: uint64(id), Pending: []string{}}
+	var dsID uint
+	if err := tx.QueryRow(`SELECT job.job_deliveryservice, deliveryservice.xml_id FROM job JOIN deliveryservice ON deliveryservice.id = job.job_deliveryservice WHERE job.id=$1`, id).Scan(&dsID, &progress.DeliveryService); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("No job by id '%d'!", id), nil)
+			return
+		}
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("getting job #%d: %v", id, err))
+		return
+	}
+
+	if ok, err := IsUserAuthorizedToModifyDSID(inf, dsID); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("checking current user permissions for DS #%d: %v", dsID, err))
+		return
+	} else if !ok {
+		api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("No job by id '%d'!", id), nil)
+		return
+	}
+
+	rows, err := tx.Query(progressQuery, id, dsID)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("querying job progress: "+err.Error()))
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		hostName := ""
+		acknowledged := false
+		if err := rows.Scan(&hostName, &acknowledged); err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("scanning job progress: "+err.Error()))
+			return
+		}
+		progress.Servers++
+		if acknowledged {
+			progress.Acknowledged++
+		} else {
+			progress.Pending = append(progress.Pending, hostName)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("iterating job progress: "+err.Error()))
+		return
+	}
+
+	progress.Percent = 100
+	if progress.Servers > 0 {
+		progress.Percent = 100 * float64(progress.Acknowledged) / float64(progress.Servers)
+	}
+	api.WriteResp(w, r, progress)
+}
@@ -40,6 +40,7 @@ import (
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tenant"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/webhook"
 )
 
 type InvalidationJob struct {
@@ -410,6 +411,7 @@ func Create(w http.ResponseWriter, r *http.Request) {
 	api.CreateChangeLogRawTx(api.ApiChange, api.Created+" content invalidation job "+duplicate+"- ID: "+
 		strconv.FormatUint(*result.ID, 10)+" DS: "+*result.DeliveryService+" URL: '"+*result.AssetURL+
 		"' Params: '"+*result.Parameters+"'", inf.User, inf.Tx.Tx)
+	webhook.Enqueue(inf.Tx.Tx, tc.WebhookEventInvalidationJobCreate, inf.User, result)
 }
 
 // Used by PUT requests to `/jobs`, replaces an existing content invalidation job
@@ -547,6 +549,12 @@ func Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// caches must apply the modified job again
+	if _, err = inf.Tx.Tx.Exec(clearAcknowledgementsQuery, *job.ID); err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, fmt.Errorf("clearing job acknowledgements: %v", err))
+		return
+	}
+
 	ttlHours := input.TTLHours()
 	conflicts := tc.ValidateJobUniqueness(inf.Tx.Tx, dsid, input.StartTime.Time, *input.AssetURL, ttlHours)
 	response := apiResponse{
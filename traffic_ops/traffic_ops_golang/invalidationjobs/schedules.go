@@ -0,0 +1,460 @@
+package invalidationjobs
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tenant"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/webhook"
+
+	"github.com/lib/pq"
+)
+
+// SchedulerInterval is how often the scheduler looks for invalidation job
+// schedules which are due. Cron expressions have a resolution of one minute.
+const SchedulerInterval = time.Minute
+
+const readSchedulesQuery = `
+SELECT s.id,
+       ds.xml_id,
+       s.regex,
+       s.ttl_hours,
+       s.cron,
+       s.enabled,
+       u.username,
+       s.last_run,
+       s.next_run,
+       s.last_updated
+FROM job_schedule s
+JOIN deliveryservice ds ON ds.id = s.deliveryservice
+JOIN tm_user u ON u.id = s.job_user
+`
+
+const insertScheduleQuery = `
+INSERT INTO job_schedule (deliveryservice, regex, ttl_hours, cron, enabled, job_user, next_run)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, last_updated
+`
+
+const updateScheduleQuery = `
+UPDATE job_schedule SET deliveryservice=$1, regex=$2, ttl_hours=$3, cron=$4, enabled=$5, next_run=$6
+WHERE id=$7
+RETURNING (SELECT username FROM tm_user WHERE tm_user.id = job_user), last_run, last_updated
+`
+
+const deleteScheduleQuery = `DELETE FROM job_schedule WHERE id=$1`
+
+const scheduleDSQuery = `SELECT deliveryservice FROM job_schedule WHERE id=$1`
+
+const dueSchedulesQuery = `SELECT id FROM job_schedule WHERE enabled AND next_run <= $1`
+
+const claimScheduleQuery = `
+SELECT s.deliveryservice, s.regex, s.ttl_hours, s.cron, u.id, u.username
+FROM job_schedule s
+JOIN tm_user u ON u.id = s.job_user
+WHERE s.id = $1 AND s.enabled AND s.next_run <= $2
+FOR UPDATE OF s SKIP LOCKED
+`
+
+const advanceScheduleQuery = `UPDATE job_schedule SET last_run=$1, next_run=$2 WHERE id=$3`
+
+const skipScheduleQuery = `UPDATE job_schedule SET next_run=$1 WHERE id=$2`
+
+// nextRun returns the time at which a schedule with the given cron expression
+// should next create a job, or nil if it never should.
+func nextRun(cron string, enabled bool, now time.Time) (*time.Time, error) {
+	if !enabled {
+		return nil, nil
+	}
+	sched, err := util.ParseCronSchedule(cron)
+	if err != nil {
+		return nil, err
+	}
+	next := sched.Next(now.UTC())
+	if next.IsZero() {
+		return nil, nil
+	}
+	return &next, nil
+}
+
+// getScheduleDSID returns the ID of the Delivery Service with the given
+// XMLID, if the current user's Tenant may modify it. The returned error is
+// suitable for returning to the user if it has a non-zero status code.
+func getScheduleDSID(inf *api.APIInfo, xmlID string) (uint, int, error) {
+	if ok, err := IsUserAuthorizedToModifyDSXMLID(inf, xmlID); err != nil {
+		return 0, http.StatusInternalServerError, fmt.Errorf("checking current user permissions for DS '%s': %v", xmlID, err)
+	} else if !ok {
+		return 0, http.StatusNotFound, errors.New("No such Delivery Service!")
+	}
+
+	var dsID uint
+	hasOrigin := false
+	if err := inf.Tx.Tx.QueryRow(`SELECT ds.id, EXISTS(SELECT 1 FROM origin o WHERE o.deliveryservice = ds.id AND o.is_primary) FROM deliveryservice ds WHERE ds.xml_id=$1`, xmlID).Scan(&dsID, &hasOrigin); err != nil {
+		return 0, http.StatusInternalServerError, fmt.Errorf("getting DS '%s' ID: %v", xmlID, err)
+	}
+	if !hasOrigin {
+		return 0, http.StatusBadRequest, fmt.Errorf("Delivery Service '%s' has no primary origin", xmlID)
+	}
+	return dsID, http.StatusOK, nil
+}
+
+func handleScheduleDSErr(w http.ResponseWriter, r *http.Request, tx *sql.Tx, code int, err error) {
+	if code == http.StatusInternalServerError {
+		api.HandleErr(w, r, tx, code, nil, err)
+		return
+	}
+	api.HandleErr(w, r, tx, code, err, nil)
+}
+
+// setScheduleDefaults sets the optional fields of an InvalidationJobSchedule
+// submitted by a client.
+func setScheduleDefaults(s *tc.InvalidationJobSchedule) {
+	if s.Enabled == nil {
+		s.Enabled = util.BoolPtr(true)
+	}
+}
+
+// ReadSchedules is the handler for GET requests to /jobs/schedules.
+func ReadSchedules(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	cols := map[string]dbhelpers.WhereColumnInfo{
+		"id":              {Column: "s.id", Checker: api.IsInt},
+		"deliveryService": {Column: "ds.xml_id", Checker: nil},
+		"dsId":            {Column: "s.deliveryservice", Checker: api.IsInt},
+		"enabled":         {Column: "s.enabled", Checker: api.IsBool},
+	}
+	where, orderBy, pagination, queryValues, errs := dbhelpers.BuildWhereAndOrderByAndPagination(inf.Params, cols)
+	if len(errs) > 0 {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, util.JoinErrs(errs), nil)
+		return
+	}
+
+	accessibleTenants, err := tenant.GetUserTenantIDListTx(tx, inf.User.TenantID)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("getting accessible tenants for user: "+err.Error()))
+		return
+	}
+	if len(where) > 0 {
+		where += " AND ds.tenant_id = ANY(:tenants) "
+	} else {
+		where = dbhelpers.BaseWhere + " ds.tenant_id = ANY(:tenants) "
+	}
+	queryValues["tenants"] = pq.Array(accessibleTenants)
+
+	rows, err := inf.Tx.NamedQuery(readSchedulesQuery+where+orderBy+pagination, queryValues)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("querying invalidation job schedules: "+err.Error()))
+		return
+	}
+	defer rows.Close()
+
+	schedules := []tc.InvalidationJobSchedule{}
+	for rows.Next() {
+		s := tc.InvalidationJobSchedule{}
+		if err := rows.Scan(&s.ID, &s.DeliveryService, &s.Regex, &s.TTLHours, &s.Cron, &s.Enabled, &s.CreatedBy, &s.LastRun, &s.NextRun, &s.LastUpdated); err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("scanning invalidation job schedules: "+err.Error()))
+			return
+		}
+		schedules = append(schedules, s)
+	}
+	api.WriteResp(w, r, schedules)
+}
+
+// CreateSchedule is the handler for POST requests to /jobs/schedules.
+func CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	s := tc.InvalidationJobSchedule{}
+	if err := api.Parse(r.Body, tx, &s); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
+		return
+	}
+	setScheduleDefaults(&s)
+
+	dsID, code, err := getScheduleDSID(inf, *s.DeliveryService)
+	if err != nil {
+		handleScheduleDSErr(w, r, tx, code, err)
+		return
+	}
+
+	if s.NextRun, err = nextRun(*s.Cron, *s.Enabled, time.Now()); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("computing next run of validated cron expression: "+err.Error()))
+		return
+	}
+
+	if err := tx.QueryRow(insertScheduleQuery, dsID, s.Regex, s.TTLHours, s.Cron, s.Enabled, inf.User.ID, s.NextRun).Scan(&s.ID, &s.LastUpdated); err != nil {
+		userErr, sysErr, errCode := api.ParseDBError(err)
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	s.CreatedBy = &inf.User.UserName
+
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "invalidation job schedule was created.", s)
+	changeLogMsg := fmt.Sprintf("INVALIDATION JOB SCHEDULE: %d, DS: %s, ACTION: %s content invalidation job schedule '%s' regex '%s' TTL %dh", *s.ID, *s.DeliveryService, api.Created, *s.Cron, *s.Regex, *s.TTLHours)
+	api.CreateChangeLogRawTx(api.ApiChange, changeLogMsg, inf.User, tx)
+}
+
+// checkScheduleTenancy checks that the invalidation job schedule with the
+// given ID exists, and that its Delivery Service may be modified by the
+// current user.
+func checkScheduleTenancy(inf *api.APIInfo, id int) (int, error) {
+	var dsID uint
+	if err := inf.Tx.Tx.QueryRow(scheduleDSQuery, id).Scan(&dsID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return http.StatusNotFound, fmt.Errorf("no invalidation job schedule exists by id %d", id)
+		}
+		return http.StatusInternalServerError, fmt.Errorf("getting invalidation job schedule #%d: %v", id, err)
+	}
+	if ok, err := IsUserAuthorizedToModifyDSID(inf, dsID); err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("checking current user permissions for DS #%d: %v", dsID, err)
+	} else if !ok {
+		// conceal the existence of schedules for Delivery Services the user can't see
+		return http.StatusNotFound, fmt.Errorf("no invalidation job schedule exists by id %d", id)
+	}
+	return http.StatusOK, nil
+}
+
+// UpdateSchedule is the handler for PUT requests to /jobs/schedules/{{ID}}.
+func UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+	id := inf.IntParams["id"]
+
+	if code, err := checkScheduleTenancy(inf, id); err != nil {
+		handleScheduleDSErr(w, r, tx, code, err)
+		return
+	}
+
+	s := tc.InvalidationJobSchedule{}
+	if err := api.Parse(r.Body, tx, &s); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
+		return
+	}
+	setScheduleDefaults(&s)
+	s.ID = &id
+
+	dsID, code, err := getScheduleDSID(inf, *s.DeliveryService)
+	if err != nil {
+		handleScheduleDSErr(w, r, tx, code, err)
+		return
+	}
+
+	if s.NextRun, err = nextRun(*s.Cron, *s.Enabled, time.Now()); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("computing next run of validated cron expression: "+err.Error()))
+		return
+	}
+
+	if err := tx.QueryRow(updateScheduleQuery, dsID, s.Regex, s.TTLHours, s.Cron, s.Enabled, s.NextRun, id).Scan(&s.CreatedBy, &s.LastRun, &s.LastUpdated); err != nil {
+		userErr, sysErr, errCode := api.ParseDBError(err)
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "invalidation job schedule was updated.", s)
+	changeLogMsg := fmt.Sprintf("INVALIDATION JOB SCHEDULE: %d, DS: %s, ACTION: %s content invalidation job schedule '%s' regex '%s' TTL %dh", id, *s.DeliveryService, api.Updated, *s.Cron, *s.Regex, *s.TTLHours)
+	api.CreateChangeLogRawTx(api.ApiChange, changeLogMsg, inf.User, tx)
+}
+
+// DeleteSchedule is the handler for DELETE requests to
+// /jobs/schedules/{{ID}}. Jobs already created by the schedule are not
+// deleted.
+func DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+	id := inf.IntParams["id"]
+
+	if code, err := checkScheduleTenancy(inf, id); err != nil {
+		handleScheduleDSErr(w, r, tx, code, err)
+		return
+	}
+
+	if _, err := tx.Exec(deleteScheduleQuery, id); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("deleting invalidation job schedule #%d: %v", id, err))
+		return
+	}
+
+	api.WriteRespAlert(w, r, tc.SuccessLevel, "invalidation job schedule was deleted.")
+	changeLogMsg := fmt.Sprintf("INVALIDATION JOB SCHEDULE: %d, ACTION: %s content invalidation job schedule", id, api.Deleted)
+	api.CreateChangeLogRawTx(api.ApiChange, changeLogMsg, inf.User, tx)
+}
+
+// StartScheduler starts creating content invalidation jobs from the
+// schedules in db as they come due, for the life of the process. Schedules
+// are claimed with row locks, so any number of Traffic Ops instances may run
+// the scheduler against the same database.
+func StartScheduler(db *sql.DB) {
+	go func() {
+		ticker := time.NewTicker(SchedulerInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if err := RunDueSchedules(db, now); err != nil {
+				log.Errorln("invalidation job scheduler: " + err.Error())
+			}
+		}
+	}()
+}
+
+// RunDueSchedules creates a content invalidation job for each enabled
+// schedule in db whose next run is at or before now. Each schedule is run in
+// its own transaction, so one failing doesn't affect the others; a schedule
+// which fails is skipped until its next run, rather than retried.
+func RunDueSchedules(db *sql.DB, now time.Time) error {
+	rows, err := db.Query(dueSchedulesQuery, now)
+	if err != nil {
+		return errors.New("querying due schedules: " + err.Error())
+	}
+	ids := []int{}
+	for rows.Next() {
+		id := 0
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return errors.New("scanning due schedules: " + err.Error())
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return errors.New("iterating due schedules: " + err.Error())
+	}
+
+	for _, id := range ids {
+		if err := runSchedule(db, id, now); err != nil {
+			log.Errorf("invalidation job scheduler: running schedule #%d: %v", id, err)
+			next := skipNextRun(db, id, now)
+			if _, err := db.Exec(skipScheduleQuery, next, id); err != nil {
+				log.Errorf("invalidation job scheduler: skipping schedule #%d: %v", id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// skipNextRun returns the next run of the schedule with the given ID after
+// now, or nil if it can't be determined.
+func skipNextRun(db *sql.DB, id int, now time.Time) *time.Time {
+	cron := ""
+	if err := db.QueryRow(`SELECT cron FROM job_schedule WHERE id=$1`, id).Scan(&cron); err != nil {
+		return nil
+	}
+	next, err := nextRun(cron, true, now)
+	if err != nil {
+		return nil
+	}
+	return next
+}
+
+// runSchedule creates a job from the schedule with the given ID, if it's
+// still due and no other Traffic Ops instance is running it, and advances
+// its next run.
+func runSchedule(db *sql.DB, id int, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.New("beginning transaction: " + err.Error())
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	var dsID uint
+	var regex, cron string
+	var ttl uint
+	user := auth.CurrentUser{}
+	if err := tx.QueryRow(claimScheduleQuery, id, now).Scan(&dsID, &regex, &ttl, &cron, &user.ID, &user.UserName); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil // already run, or being run, by another instance
+		}
+		return errors.New("claiming schedule: " + err.Error())
+	}
+
+	next, err := nextRun(cron, true, now)
+	if err != nil {
+		return errors.New("parsing cron expression: " + err.Error())
+	}
+
+	job := tc.InvalidationJob{}
+	err = tx.QueryRow(insertQuery, dsID, regex, now, dsID, user.ID, fmt.Sprintf("TTL:%dh", ttl), now).Scan(
+		&job.AssetURL,
+		&job.DeliveryService,
+		&job.ID,
+		&job.CreatedBy,
+		&job.Keyword,
+		&job.Parameters,
+		&job.StartTime)
+	if err != nil {
+		return errors.New("creating job: " + err.Error())
+	}
+
+	if err := setRevalFlags(dsID, tx); err != nil {
+		return errors.New("setting reval flags: " + err.Error())
+	}
+
+	if _, err := tx.Exec(advanceScheduleQuery, now, next, id); err != nil {
+		return errors.New("advancing schedule: " + err.Error())
+	}
+
+	api.CreateChangeLogRawTx(api.ApiChange, api.Created+" content invalidation job from schedule "+strconv.Itoa(id)+" - ID: "+
+		strconv.FormatUint(*job.ID, 10)+" DS: "+*job.DeliveryService+" URL: '"+*job.AssetURL+
+		"' Params: '"+*job.Parameters+"'", &user, tx)
+	webhook.Enqueue(tx, tc.WebhookEventInvalidationJobCreate, &user, job)
+
+	if err := tx.Commit(); err != nil {
+		return errors.New("committing: " + err.Error())
+	}
+	committed = true
+	return nil
+}
@@ -0,0 +1,112 @@
+package invalidationjobs
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"testing"
+	"time"
+
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestNextRun(t *testing.T) {
+	now := time.Date(2021, time.June, 4, 10, 17, 0, 0, time.UTC)
+	next, err := nextRun("0 * * * *", true, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := time.Date(2021, time.June, 4, 11, 0, 0, 0, time.UTC); next == nil || !next.Equal(expected) {
+		t.Errorf("expected next run %v, got %v", expected, next)
+	}
+
+	if next, err := nextRun("0 * * * *", false, now); err != nil || next != nil {
+		t.Errorf("expected a disabled schedule to have no next run, got %v, %v", next, err)
+	}
+	if _, err := nextRun("bogus", true, now); err == nil {
+		t.Error("expected an error for an invalid cron expression, got none")
+	}
+}
+
+func TestRunDueSchedules(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	now := time.Date(2021, time.June, 4, 3, 0, 0, 0, time.UTC)
+	next := time.Date(2021, time.June, 5, 3, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT id FROM job_schedule").WithArgs(now).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+
+	// schedule 1 is run
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE OF s SKIP LOCKED").WithArgs(1, now).WillReturnRows(
+		sqlmock.NewRows([]string{"deliveryservice", "regex", "ttl_hours", "cron", "id", "username"}).AddRow(7, "/images/.*", 24, "0 3 * * *", 3, "operator"))
+	mock.ExpectQuery("INSERT INTO job").WithArgs(7, "/images/.*", now, 7, 3, "TTL:24h", now).WillReturnRows(
+		sqlmock.NewRows([]string{"asset_url", "deliveryservice", "id", "createdBy", "keyword", "parameters", "start_time"}).
+			AddRow("http://origin.test/images/.*", "demo1", 42, "operator", "PURGE", "TTL:24h", now))
+	mock.ExpectQuery("SELECT value FROM parameter").WillReturnRows(sqlmock.NewRows([]string{"value"}))
+	mock.ExpectQuery("UPDATE server SET upd_pending").WithArgs(7).WillReturnRows(sqlmock.NewRows(nil))
+	mock.ExpectExec("UPDATE job_schedule SET last_run").WithArgs(now, next, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO log").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO webhook_delivery").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// schedule 2 was claimed by another instance in the meantime
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE OF s SKIP LOCKED").WithArgs(2, now).WillReturnRows(
+		sqlmock.NewRows([]string{"deliveryservice", "regex", "ttl_hours", "cron", "id", "username"}))
+	mock.ExpectRollback()
+
+	if err := RunDueSchedules(mockDB, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %v", err)
+	}
+}
+
+func TestRunDueSchedulesSkipsFailures(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	now := time.Date(2021, time.June, 4, 3, 0, 0, 0, time.UTC)
+	next := time.Date(2021, time.June, 5, 3, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT id FROM job_schedule").WithArgs(now).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE OF s SKIP LOCKED").WithArgs(1, now).WillReturnRows(
+		sqlmock.NewRows([]string{"deliveryservice", "regex", "ttl_hours", "cron", "id", "username"}).AddRow(7, "/images/.*", 24, "0 3 * * *", 3, "operator"))
+	mock.ExpectQuery("INSERT INTO job").WillReturnError(sqlmock.ErrCancelled)
+	mock.ExpectRollback()
+	mock.ExpectQuery("SELECT cron FROM job_schedule").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"cron"}).AddRow("0 3 * * *"))
+	mock.ExpectExec("UPDATE job_schedule SET next_run").WithArgs(next, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := RunDueSchedules(mockDB, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %v", err)
+	}
+}
@@ -24,8 +24,10 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -34,6 +36,7 @@ import (
 	"github.com/apache/trafficcontrol/lib/go-rfc"
 	"github.com/apache/trafficcontrol/lib/go-util"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/asyncjob"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
 	"github.com/jmoiron/sqlx"
 )
@@ -64,6 +67,20 @@ const (
 	ksFilesParamConfigFile = "mkisofs"
 )
 
+// isoJobType is the type of the asynchronous jobs which generate ISOs.
+const isoJobType = "iso"
+
+// isoJobPayload is the payload of an iso job. The root password is only
+// stored in its crypted form.
+type isoJobPayload struct {
+	Request         isoRequest `json:"request"`
+	CryptedRootPass string     `json:"cryptedRootPass"`
+}
+
+func init() {
+	asyncjob.Register(isoJobType, runISOJob)
+}
+
 // ISOs handler is responsible for generating and returning an ISO image,
 // as a streaming download.
 //
@@ -101,6 +118,83 @@ func ISOs(w http.ResponseWriter, req *http.Request) {
 	isos(w, req, inf.Tx, inf.User, ir)
 }
 
+// ISOsV40 handler validates a request for an ISO image, and queues an
+// asynchronous job which generates it. The image may be downloaded from the
+// job's artifact endpoint once the job succeeds.
+func ISOsV40(w http.ResponseWriter, req *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(req, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, req, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	ir := isoRequest{}
+
+	if err := api.Parse(req.Body, inf.Tx.Tx, &ir); err != nil {
+		api.HandleErr(w, req, inf.Tx.Tx, http.StatusBadRequest, err, nil)
+		return
+	}
+	if ok, err := ir.validateOSDir(inf.Tx); err != nil {
+		api.HandleErr(w, req, inf.Tx.Tx, http.StatusInternalServerError, nil, fmt.Errorf("unable to read osversions configuration: %v", err))
+		return
+	} else if !ok {
+		api.HandleErr(w, req, inf.Tx.Tx, http.StatusBadRequest, fmt.Errorf("invalid OS version directory: %q", ir.OSVersionDir), nil)
+		return
+	}
+
+	cryptedPw, err := crypt(ir.RootPass, rndSalt(8))
+	if err != nil {
+		api.HandleErr(w, req, inf.Tx.Tx, http.StatusInternalServerError, nil, fmt.Errorf("crypting root password: %v", err))
+		return
+	}
+	ir.RootPass = ""
+
+	payload := isoJobPayload{Request: ir, CryptedRootPass: cryptedPw}
+	status, err := asyncjob.Enqueue(inf.Tx.Tx, isoJobType, inf.User, payload, "Generation of ISO for "+ir.fqdn()+" queued")
+	if err != nil {
+		api.HandleErr(w, req, inf.Tx.Tx, http.StatusInternalServerError, nil, fmt.Errorf("queueing ISO generation: %v", err))
+		return
+	}
+	asyncjob.WriteAccepted(w, req, status, "Generation of ISO for "+ir.fqdn())
+}
+
+// runISOJob is the asyncjob.Func which generates an ISO image, as the job's
+// artifact.
+func runISOJob(inf *api.APIInfo, job *asyncjob.Job) (string, error, error) {
+	payload := isoJobPayload{}
+	if err := job.DecodePayload(&payload); err != nil {
+		return "", nil, err
+	}
+	ir := payload.Request
+	ir.cryptedRootPass = payload.CryptedRootPass
+
+	var artifact *os.File
+	defer func() {
+		if artifact != nil {
+			log.Close(artifact, "closing ISO artifact")
+		}
+	}()
+	open := func(filename string) (io.Writer, error) {
+		job.SetProgress(20, "Generating "+filename)
+		f, err := job.CreateArtifact(filename)
+		artifact = f
+		return f, err
+	}
+	isoFilename, err := generateISO(inf.Tx, ir, nil, open)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := artifact.Close(); err != nil {
+		return "", nil, fmt.Errorf("closing ISO artifact: %v", err)
+	}
+	artifact = nil
+
+	if err := createISOChangeLog(inf.User, inf.Tx.Tx, ir); err != nil {
+		return "", nil, fmt.Errorf("creating changelog entry for ISO creation: %v", err)
+	}
+	return "Generated " + isoFilename, nil, nil
+}
+
 // cmdOverwriteCtxKey is used in an http.Request's context
 // to set a cmd override value.
 var cmdOverwriteCtxKey struct{}
@@ -120,12 +214,37 @@ func isos(w http.ResponseWriter, req *http.Request, tx *sqlx.Tx, user *auth.Curr
 		return
 	}
 
+	// Allow for the request context to carry a modifier function that can change the
+	// genISOCmd's command. This is purely used for testing.
+	cmdMod, _ := req.Context().Value(cmdOverwriteCtxKey).(func(in *exec.Cmd) *exec.Cmd)
+
+	open := func(isoFilename string) (io.Writer, error) {
+		w.Header().Set(rfc.ContentDisposition, fmt.Sprintf("attachment; filename=%q", isoFilename))
+		w.Header().Set(rfc.ContentType, rfc.ApplicationOctetStream)
+		return w, nil
+	}
+	if _, err := generateISO(tx, ir, cmdMod, open); err != nil {
+		api.HandleErr(w, req, tx.Tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+
+	if err := createISOChangeLog(user, tx.Tx, ir); err != nil {
+		// At this point, it's not possible to modify the HTTP response.
+		log.Errorf("error creating changelog entry for ISO creation: %v", err)
+	}
+}
+
+// generateISO generates the ISO image requested by ir, writing it to the
+// writer returned by open, which is given the image's filename and is only
+// called once the image is ready to be written. If not nil, cmdMod may
+// change the command which generates the image. The filename of the image
+// is returned.
+func generateISO(tx *sqlx.Tx, ir isoRequest, cmdMod func(in *exec.Cmd) *exec.Cmd, open func(isoFilename string) (io.Writer, error)) (string, error) {
 	// Determine the kickstart root directory, which is either a default
 	// value or may be overridden by a database/Parameter entry.
 	ksDir, err := kickstarterDir(tx, ir.OSVersionDir)
 	if err != nil {
-		api.HandleErr(w, req, tx.Tx, http.StatusInternalServerError, nil, fmt.Errorf("unable to determine kickstarter directory: %v", err))
-		return
+		return "", fmt.Errorf("unable to determine kickstarter directory: %v", err)
 	}
 
 	// cfgDir holds the kickstart config files within the root
@@ -135,50 +254,46 @@ func isos(w http.ResponseWriter, req *http.Request, tx *sqlx.Tx, user *auth.Curr
 
 	genISOCmd, err := newStreamISOCmd(ksDir)
 	if err != nil {
-		api.HandleErr(w, req, tx.Tx, http.StatusInternalServerError, nil, fmt.Errorf("unable to initialize genISO command: %v", err))
-		return
+		return "", fmt.Errorf("unable to initialize genISO command: %v", err)
 	}
 	defer genISOCmd.cleanup()
 
-	// Allow for the request context to carry a modifier function that can change the
-	// genISOCmd's command. This is purely used for testing.
-	if cmdMod, ok := req.Context().Value(cmdOverwriteCtxKey).(func(in *exec.Cmd) *exec.Cmd); ok {
+	if cmdMod != nil {
 		genISOCmd.cmd = cmdMod(genISOCmd.cmd)
 	}
 
 	log.Infof("Using %s ISO generation command: %s", genISOCmd.cmdType, genISOCmd.String())
 
 	if err = writeKSCfgs(cfgDir, ir, genISOCmd.String()); err != nil {
-		api.HandleErr(w, req, tx.Tx, http.StatusInternalServerError, nil, fmt.Errorf("unable to create kickstarter files: %v", err))
-		return
+		return "", fmt.Errorf("unable to create kickstarter files: %v", err)
 	}
 
 	isoFilename := fmt.Sprintf("%s-%s.iso", ir.fqdn(), ir.OSVersionDir)
 	// strings.ReplaceAll was added in Go 1.12
 	isoFilename = strings.Replace(isoFilename, "/", "_", -1)
 
-	w.Header().Set(rfc.ContentDisposition, fmt.Sprintf("attachment; filename=%q", isoFilename))
-	w.Header().Set(rfc.ContentType, rfc.ApplicationOctetStream)
-
+	w, err := open(isoFilename)
+	if err != nil {
+		return "", fmt.Errorf("unable to open ISO destination: %v", err)
+	}
 	if err = genISOCmd.stream(w); err != nil {
-		api.HandleErr(w, req, tx.Tx, http.StatusInternalServerError, nil, fmt.Errorf("unable to generate ISO: %v", err))
-		return
+		return "", fmt.Errorf("unable to generate ISO: %v", err)
 	}
+	return isoFilename, nil
+}
 
-	// Create changelog entry
-	err = api.CreateChangeLogBuildMsg(
+// createISOChangeLog creates the changelog entry for the creation of the ISO
+// requested by ir.
+func createISOChangeLog(user *auth.CurrentUser, tx *sql.Tx, ir isoRequest) error {
+	return api.CreateChangeLogBuildMsg(
 		api.ApiChange,
 		api.Created,
 		user,
-		tx.Tx,
+		tx,
 		"ISO",
 		ir.fqdn(),
 		map[string]interface{}{"OS": ir.OSVersionDir},
 	)
-	if err != nil {
-		// At this point, it's not possible to modify the HTTP response.
-		log.Errorf("error creating changelog entry for ISO creation: %v", err)
-	}
 }
 
 // isoRequest represents the JSON object clients use to
@@ -201,6 +316,11 @@ type isoRequest struct {
 	MgmtIPNetmask net.IP          `json:"mgmtIpNetmask"`
 	MgmtIPGateway net.IP          `json:"mgmtIpGateway"`
 	MgmtInterface string          `json:"mgmtInterface"`
+
+	// cryptedRootPass, if set, is used instead of RootPass by
+	// writePasswordCfg, so that requests for ISOs generated asynchronously
+	// needn't store the root password in plain text.
+	cryptedRootPass string
 }
 
 func (i *isoRequest) fqdn() string {
@@ -318,6 +438,18 @@ func (b *boolStr) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// MarshalText encodes the boolStr such that UnmarshalText decodes it to the
+// same value.
+func (b boolStr) MarshalText() ([]byte, error) {
+	if !b.isSet {
+		return []byte{}, nil
+	}
+	if b.v {
+		return []byte("yes"), nil
+	}
+	return []byte("no"), nil
+}
+
 // val returns the boolean value and whether
 // the value was set or not.
 func (b *boolStr) val() (value, ok bool) {
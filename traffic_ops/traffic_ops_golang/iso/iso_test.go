@@ -532,6 +532,28 @@ func TestBoolStr_UnmarshalText(t *testing.T) {
 	}
 }
 
+func TestBoolStr_MarshalText(t *testing.T) {
+	cases := []boolStr{
+		{isSet: true, v: false},
+		{isSet: true, v: true},
+		{isSet: false, v: false},
+	}
+
+	for _, tc := range cases {
+		text, err := tc.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got boolStr
+		if err := got.UnmarshalText(text); err != nil {
+			t.Fatal(err)
+		}
+		if got != tc {
+			t.Errorf("%+v marshalled to %q, which unmarshalled to %+v", tc, text, got)
+		}
+	}
+}
+
 func TestISORequest_validateOSDir(t *testing.T) {
 	const (
 		validDir1  = "VALID-OS-DIR"
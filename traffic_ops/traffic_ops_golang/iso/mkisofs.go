@@ -326,18 +326,22 @@ func writeDiskCfg(w io.Writer, r isoRequest) error {
 
 // writePasswordCfg writes the password.cfg config to w.
 // The salt parameter is optional. If salt is blank, then a
-// random 8-character salt will be used.
+// random 8-character salt will be used. If the request's root
+// password has already been crypted, that is used instead.
 func writePasswordCfg(w io.Writer, r isoRequest, salt string) error {
-	if salt == "" {
-		salt = rndSalt(8)
-	}
+	cryptedPw := r.cryptedRootPass
+	if cryptedPw == "" {
+		if salt == "" {
+			salt = rndSalt(8)
+		}
 
-	cryptedPw, err := crypt(r.RootPass, salt)
-	if err != nil {
-		return err
+		var err error
+		if cryptedPw, err = crypt(r.RootPass, salt); err != nil {
+			return err
+		}
 	}
 
-	_, err = fmt.Fprintf(w, "rootpw --iscrypted %s\n", cryptedPw)
+	_, err := fmt.Fprintf(w, "rootpw --iscrypted %s\n", cryptedPw)
 	return err
 }
 
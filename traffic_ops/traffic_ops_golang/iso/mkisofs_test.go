@@ -627,6 +627,14 @@ func TestWritePasswordCfg(t *testing.T) {
 			"salt",
 			"rootpw --iscrypted $1$salt$17HeaymOIi.65dl76MkK01\n",
 		},
+		{
+			"pre-crypted",
+			isoRequest{
+				cryptedRootPass: "$1$salt$17HeaymOIi.65dl76MkK01",
+			},
+			"other",
+			"rootpw --iscrypted $1$salt$17HeaymOIi.65dl76MkK01\n",
+		},
 	}
 
 	for _, tc := range cases {
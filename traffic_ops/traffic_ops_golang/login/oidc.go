@@ -0,0 +1,412 @@
+package login
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/jmoiron/sqlx"
+)
+
+// oidcStateCookieName is the name of the cookie which holds the state of an OpenID Connect login while the user is at the identity provider.
+const oidcStateCookieName = "oidc_state"
+
+// oidcStateDuration is how long a user has to log in at the identity provider.
+const oidcStateDuration = 10 * time.Minute
+
+// oidcState is the state of an OpenID Connect login, kept in a signed cookie between OIDCLoginHandler and OIDCCallbackHandler.
+type oidcState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Redirect string `json:"redirect"`
+}
+
+// oidcStateSecret returns the secret with which login state cookies are signed. It differs from the secret which signs
+// login cookies, so that neither can be used as the other.
+func oidcStateSecret(cfg config.Config) string {
+	return "oidc-state:" + cfg.Secrets[0]
+}
+
+// OIDCLoginHandler starts an OpenID Connect login by redirecting the user to the identity provider. The optional 'redirect'
+// query parameter is where the user is sent once they've logged in; it must be a path, or a URL on the Traffic Portal or Traffic Ops host.
+func OIDCLoginHandler(db *sqlx.DB, cfg config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.OIDC == nil {
+			api.HandleErr(w, r, nil, http.StatusNotFound, errors.New("OpenID Connect login is not enabled"), nil)
+			return
+		}
+		redirect := r.URL.Query().Get("redirect")
+		if redirect != "" && !isAllowedOIDCRedirect(redirect, cfg) {
+			api.HandleErr(w, r, nil, http.StatusBadRequest, errors.New("redirect must be a path, or a URL on the Traffic Portal or Traffic Ops host"), nil)
+			return
+		}
+
+		state := oidcState{Redirect: redirect}
+		var err error
+		if state.State, err = generateToken(); err != nil {
+			api.HandleErr(w, r, nil, http.StatusInternalServerError, nil, errors.New("generating OpenID Connect state: "+err.Error()))
+			return
+		}
+		if state.Nonce, err = generateToken(); err != nil {
+			api.HandleErr(w, r, nil, http.StatusInternalServerError, nil, errors.New("generating OpenID Connect nonce: "+err.Error()))
+			return
+		}
+
+		authURL, err := auth.GetOIDCProvider(*cfg.OIDC).AuthCodeURL(r.Context(), state.State, state.Nonce)
+		if err != nil {
+			api.HandleErr(w, r, nil, http.StatusBadGateway, errors.New("OpenID Connect provider is unavailable"), err)
+			return
+		}
+
+		stateBts, err := json.Marshal(state)
+		if err != nil {
+			api.HandleErr(w, r, nil, http.StatusInternalServerError, nil, errors.New("marshalling OpenID Connect state: "+err.Error()))
+			return
+		}
+		expiry := time.Now().Add(oidcStateDuration)
+		cookieBts, err := json.Marshal(tocookie.Cookie{AuthData: string(stateBts), ExpiresUnix: expiry.Unix(), By: tocookie.GeneratedByStr})
+		if err != nil {
+			api.HandleErr(w, r, nil, http.StatusInternalServerError, nil, errors.New("marshalling OpenID Connect state cookie: "+err.Error()))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookieName,
+			Value:    tocookie.NewRawMsg(cookieBts, []byte(oidcStateSecret(cfg))),
+			Path:     "/",
+			Expires:  expiry,
+			MaxAge:   int(oidcStateDuration.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// isAllowedOIDCRedirect returns whether the user may be redirected to the given location after logging in, which prevents
+// the login from being used as an open redirect. Browsers treat '\' as '/', so a path like '/\evil.com' would be followed
+// to the host evil.com; no location containing one is allowed, and a path may not start with anything that could begin a host.
+func isAllowedOIDCRedirect(redirect string, cfg config.Config) bool {
+	if strings.ContainsRune(redirect, '\\') {
+		return false
+	}
+	u, err := url.Parse(redirect)
+	if err != nil {
+		return false
+	}
+	if !u.IsAbs() {
+		return u.Host == "" && u.Opaque == "" && strings.HasPrefix(redirect, "/") && !strings.HasPrefix(redirect, "//")
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return false
+	}
+	if cfg.ConfigPortal.BaseURL.Host != "" && u.Host == cfg.ConfigPortal.BaseURL.Host {
+		return true
+	}
+	return cfg.ConfigTO != nil && cfg.ConfigTO.BaseURL != nil && u.Host == cfg.ConfigTO.BaseURL.Host
+}
+
+// OIDCCallbackHandler completes an OpenID Connect login started by OIDCLoginHandler. The identity provider redirects the user to it
+// with an authorization code, which is exchanged for an ID token identifying the user, who is then logged in.
+func OIDCCallbackHandler(db *sqlx.DB, cfg config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.OIDC == nil {
+			api.HandleErr(w, r, nil, http.StatusNotFound, errors.New("OpenID Connect login is not enabled"), nil)
+			return
+		}
+		params := r.URL.Query()
+		if idpErr := params.Get("error"); idpErr != "" {
+			api.HandleErr(w, r, nil, http.StatusUnauthorized, fmt.Errorf("OpenID Connect provider returned an error: %s %s", idpErr, params.Get("error_description")), nil)
+			return
+		}
+
+		stateCookie, err := r.Cookie(oidcStateCookieName)
+		if err != nil {
+			api.HandleErr(w, r, nil, http.StatusBadRequest, errors.New("missing login state; please log in again"), nil)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: oidcStateCookieName, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+
+		parsed, err := tocookie.Parse(oidcStateSecret(cfg), stateCookie.Value)
+		if err != nil {
+			api.HandleErr(w, r, nil, http.StatusBadRequest, errors.New("invalid or expired login state; please log in again"), nil)
+			return
+		}
+		state := oidcState{}
+		if err := json.Unmarshal([]byte(parsed.AuthData), &state); err != nil || state.State == "" || params.Get("state") != state.State {
+			api.HandleErr(w, r, nil, http.StatusBadRequest, errors.New("invalid or expired login state; please log in again"), nil)
+			return
+		}
+		code := params.Get("code")
+		if code == "" {
+			api.HandleErr(w, r, nil, http.StatusBadRequest, errors.New("missing authorization code"), nil)
+			return
+		}
+
+		provider := auth.GetOIDCProvider(*cfg.OIDC)
+		rawToken, err := provider.Exchange(r.Context(), code)
+		if err != nil {
+			api.HandleErr(w, r, nil, http.StatusBadGateway, errors.New("could not get an ID token from the OpenID Connect provider"), err)
+			return
+		}
+		claims, err := provider.VerifyIDToken(r.Context(), rawToken, state.Nonce)
+		if err != nil {
+			api.HandleErr(w, r, nil, http.StatusUnauthorized, errors.New("invalid ID token"), err)
+			return
+		}
+		if userErr, sysErr, errCode := loginOIDCUser(w, r, db, cfg, provider, claims); userErr != nil || sysErr != nil {
+			api.HandleErr(w, r, nil, errCode, userErr, sysErr)
+			return
+		}
+
+		if state.Redirect != "" {
+			http.Redirect(w, r, state.Redirect, http.StatusFound)
+			return
+		}
+		api.WriteAlerts(w, r, http.StatusOK, tc.CreateAlerts(tc.SuccessLevel, "Successfully logged in."))
+	}
+}
+
+// OIDCTokenLoginHandler logs in the user identified by an ID token which the client obtained from the OpenID Connect provider itself,
+// for clients which can't follow the redirects of OIDCLoginHandler. The token must have been issued to Traffic Ops' client ID.
+func OIDCTokenLoginHandler(db *sqlx.DB, cfg config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		if cfg.OIDC == nil {
+			api.HandleErr(w, r, nil, http.StatusNotFound, errors.New("OpenID Connect login is not enabled"), nil)
+			return
+		}
+		req := tc.OIDCTokenLoginRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.HandleErr(w, r, nil, http.StatusBadRequest, fmt.Errorf("Invalid request: %v", err), nil)
+			return
+		}
+		if req.IDToken == "" {
+			api.HandleErr(w, r, nil, http.StatusBadRequest, errors.New("idToken is required"), nil)
+			return
+		}
+
+		provider := auth.GetOIDCProvider(*cfg.OIDC)
+		claims, err := provider.VerifyIDToken(r.Context(), req.IDToken, "")
+		if err != nil {
+			api.HandleErr(w, r, nil, http.StatusUnauthorized, errors.New("invalid ID token"), err)
+			return
+		}
+		if userErr, sysErr, errCode := loginOIDCUser(w, r, db, cfg, provider, claims); userErr != nil || sysErr != nil {
+			api.HandleErr(w, r, nil, errCode, userErr, sysErr)
+			return
+		}
+		api.WriteAlerts(w, r, http.StatusOK, tc.CreateAlerts(tc.SuccessLevel, "Successfully logged in."))
+	}
+}
+
+// loginOIDCUser provisions or updates the user linked to the OpenID Connect identity of the given verified ID token claims, checks that
+// they're allowed to log in, and sets their login cookie.
+func loginOIDCUser(w http.ResponseWriter, r *http.Request, db *sqlx.DB, cfg config.Config, provider *auth.OIDCProvider, claims jwt.MapClaims) (error, error, int) {
+	identity, err := provider.Identity(claims)
+	if err != nil {
+		return errors.New("invalid ID token"), err, http.StatusUnauthorized
+	}
+	role, tenant, err := provider.RoleAndTenant(identity)
+	if err != nil {
+		return fmt.Errorf("user '%s' is not authorized to use Traffic Ops: %v", identity.Username, err), nil, http.StatusForbidden
+	}
+
+	timeout := time.Duration(cfg.DBQueryTimeoutSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errors.New("beginning transaction: " + err.Error()), http.StatusInternalServerError
+	}
+	username, userErr, sysErr, errCode := provisionOIDCUser(tx, *cfg.OIDC, identity, role, tenant)
+	if userErr != nil || sysErr != nil {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Errorln("rolling back OpenID Connect user transaction: " + err.Error())
+		}
+		return userErr, sysErr, errCode
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, errors.New("committing OpenID Connect user transaction: " + err.Error()), http.StatusInternalServerError
+	}
+
+	allowed, err, blockingErr := auth.CheckLocalUserIsAllowed(auth.PasswordForm{Username: username}, db, timeout)
+	if blockingErr != nil {
+		return nil, fmt.Errorf("checking local user: %v", blockingErr), http.StatusServiceUnavailable
+	}
+	if err != nil {
+		return nil, fmt.Errorf("checking local user: %v", err), http.StatusInternalServerError
+	}
+	if !allowed {
+		return fmt.Errorf("user '%s' is not allowed to log in", username), nil, http.StatusForbidden
+	}
+
+	http.SetCookie(w, tocookie.GetCookie(username, defaultCookieDuration, cfg.Secrets[0]))
+	return nil, nil, http.StatusOK
+}
+
+// provisionOIDCUser returns the username of the user linked to the given OpenID Connect identity. If no user is linked to it and users
+// are automatically provisioned, one is created with the given Role and Tenant; if users are synchronized, the Role and Tenant of a linked
+// user are updated. Users with the "disallowed" Role are never updated, so that a user disabled in Traffic Ops stays disabled.
+//
+// Users are only ever found by the issuer and subject of the identity, which can't be changed at the identity provider. An identity is
+// never linked to an existing user by username, so that nobody can log in as another user by changing their username claim.
+func provisionOIDCUser(tx *sql.Tx, cfg config.ConfigOIDC, identity auth.OIDCIdentity, role string, tenant string) (string, error, error, int) {
+	userID, roleID, tenantID, roleName, username := 0, 0, 0, "", ""
+	err := tx.QueryRow(`
+SELECT u.id, u.username, COALESCE(u.role, 0), u.tenant_id, COALESCE(r.name, '')
+FROM tm_user u
+LEFT JOIN role r ON r.id = u.role
+WHERE u.oidc_issuer = $1 AND u.oidc_subject = $2
+`, identity.Issuer, identity.Subject).Scan(&userID, &username, &roleID, &tenantID, &roleName)
+	exists := true
+	if err == sql.ErrNoRows {
+		exists = false
+	} else if err != nil {
+		return "", nil, errors.New("getting OpenID Connect user: " + err.Error()), http.StatusInternalServerError
+	}
+	if !exists {
+		taken := false
+		if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM tm_user WHERE username = $1)`, identity.Username).Scan(&taken); err != nil {
+			return "", nil, errors.New("checking OpenID Connect username: " + err.Error()), http.StatusInternalServerError
+		}
+		if taken {
+			return "", fmt.Errorf("user '%s' already exists in Traffic Ops and is not linked to this OpenID Connect identity; it must be linked by logging in as that user and using POST user/login/oidc/link", identity.Username), nil, http.StatusForbidden
+		}
+		if !cfg.AutoProvision {
+			return "", fmt.Errorf("user '%s' does not exist in Traffic Ops", identity.Username), nil, http.StatusForbidden
+		}
+		username = identity.Username
+	}
+	if exists && (!cfg.SyncUsers || roleName == auth.DisallowedRoleName) {
+		return username, nil, nil, http.StatusOK
+	}
+
+	newRoleID, newTenantID := 0, 0
+	if err := tx.QueryRow(`SELECT id FROM role WHERE name = $1`, role).Scan(&newRoleID); err == sql.ErrNoRows {
+		return "", nil, fmt.Errorf("OpenID Connect role '%s' for user '%s' does not exist", role, username), http.StatusInternalServerError
+	} else if err != nil {
+		return "", nil, errors.New("getting OpenID Connect user role: " + err.Error()), http.StatusInternalServerError
+	}
+	if err := tx.QueryRow(`SELECT id FROM tenant WHERE name = $1`, tenant).Scan(&newTenantID); err == sql.ErrNoRows {
+		return "", fmt.Errorf("tenant '%s' of user '%s' does not exist", tenant, username), nil, http.StatusForbidden
+	} else if err != nil {
+		return "", nil, errors.New("getting OpenID Connect user tenant: " + err.Error()), http.StatusInternalServerError
+	}
+
+	action := ""
+	if !exists {
+		if err := tx.QueryRow(`
+INSERT INTO tm_user (username, role, tenant_id, email, full_name, new_user, oidc_issuer, oidc_subject)
+VALUES ($1, $2, $3, $4, $5, FALSE, $6, $7)
+RETURNING id
+`, username, newRoleID, newTenantID, identity.Email, identity.FullName, identity.Issuer, identity.Subject).Scan(&userID); err != nil {
+			userErr, sysErr, errCode := api.ParseDBError(err)
+			return "", userErr, sysErr, errCode
+		}
+		action = fmt.Sprintf("Created user from OpenID Connect login with role %s and tenant %s", role, tenant)
+	} else if roleID != newRoleID || tenantID != newTenantID {
+		if _, err := tx.Exec(`UPDATE tm_user SET role = $1, tenant_id = $2 WHERE id = $3`, newRoleID, newTenantID, userID); err != nil {
+			return "", nil, errors.New("updating OpenID Connect user: " + err.Error()), http.StatusInternalServerError
+		}
+		action = fmt.Sprintf("Updated user from OpenID Connect login to role %s and tenant %s", role, tenant)
+	} else {
+		return username, nil, nil, http.StatusOK
+	}
+
+	user := &auth.CurrentUser{UserName: username, ID: userID}
+	msg := fmt.Sprintf("USER: %s, ID: %d, ACTION: %s", username, userID, action)
+	if err := api.CreateChangeLogRawErr(api.ApiChange, msg, user, tx); err != nil {
+		return "", nil, err, http.StatusInternalServerError
+	}
+	return username, nil, nil, http.StatusOK
+}
+
+// OIDCLinkHandler links the logged-in user to the OpenID Connect identity of the given ID token, which the client obtained from the
+// OpenID Connect provider, so that they can then log in through the provider. This is the only way that an existing user is linked
+// to an identity.
+func OIDCLinkHandler(cfg config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+		if userErr != nil || sysErr != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+			return
+		}
+		defer inf.Close()
+		if cfg.OIDC == nil {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusNotFound, errors.New("OpenID Connect login is not enabled"), nil)
+			return
+		}
+		req := tc.OIDCTokenLoginRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, fmt.Errorf("Invalid request: %v", err), nil)
+			return
+		}
+		if req.IDToken == "" {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, errors.New("idToken is required"), nil)
+			return
+		}
+
+		provider := auth.GetOIDCProvider(*cfg.OIDC)
+		claims, err := provider.VerifyIDToken(r.Context(), req.IDToken, "")
+		if err != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusUnauthorized, errors.New("invalid ID token"), err)
+			return
+		}
+		identity, err := provider.Identity(claims)
+		if err != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusUnauthorized, errors.New("invalid ID token"), err)
+			return
+		}
+
+		linkedID := 0
+		if err := inf.Tx.Tx.QueryRow(`SELECT id FROM tm_user WHERE oidc_issuer = $1 AND oidc_subject = $2`, identity.Issuer, identity.Subject).Scan(&linkedID); err == nil {
+			if linkedID != inf.User.ID {
+				api.HandleErr(w, r, inf.Tx.Tx, http.StatusConflict, errors.New("this OpenID Connect identity is already linked to another user"), nil)
+				return
+			}
+		} else if err != sql.ErrNoRows {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("getting OpenID Connect user: "+err.Error()))
+			return
+		}
+		if _, err := inf.Tx.Tx.Exec(`UPDATE tm_user SET oidc_issuer = $1, oidc_subject = $2 WHERE id = $3`, identity.Issuer, identity.Subject, inf.User.ID); err != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("linking OpenID Connect user: "+err.Error()))
+			return
+		}
+		msg := fmt.Sprintf("USER: %s, ID: %d, ACTION: Linked user to OpenID Connect subject %s of %s", inf.User.UserName, inf.User.ID, identity.Subject, identity.Issuer)
+		api.CreateChangeLogRawTx(api.ApiChange, msg, inf.User, inf.Tx.Tx)
+		api.WriteAlerts(w, r, http.StatusOK, tc.CreateAlerts(tc.SuccessLevel, "User linked to OpenID Connect identity."))
+	}
+}
@@ -0,0 +1,300 @@
+package login
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-rfc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// newMockIdP returns an OpenID Connect provider which issues an ID token for the given claims, with the nonce of the
+// authorization request, in exchange for any authorization code.
+func newMockIdP(t *testing.T, claims jwt.MapClaims) *httptest.Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %v", err)
+	}
+	var idp *httptest.Server
+	nonces := map[string]string{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(auth.OIDCDiscovery{
+			Issuer:                idp.URL,
+			AuthorizationEndpoint: idp.URL + "/authorize",
+			TokenEndpoint:         idp.URL + "/token",
+			JWKSURI:               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		nonces["code-1"] = q.Get("nonce")
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code=code-1&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		nonce, ok := nonces[r.PostForm.Get("code")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		c := jwt.MapClaims{"iss": idp.URL, "aud": "traffic-ops", "exp": time.Now().Add(time.Minute).Unix(), "nonce": nonce}
+		for k, v := range claims {
+			c[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+		token.Header["kid"] = "key-1"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Errorf("signing ID token: %v", err)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "id_token": signed, "token_type": "Bearer"})
+	})
+	idp = httptest.NewServer(mux)
+	return idp
+}
+
+func testOIDCConfig(t *testing.T, issuer string) config.Config {
+	oidc := &config.ConfigOIDC{
+		IssuerURL:     issuer,
+		ClientID:      "traffic-ops",
+		RedirectURL:   "https://to.example.test/api/4.0/user/login/oidc/callback",
+		GroupMappings: []config.OIDCGroupMapping{{Group: "cdn-ops", Role: "operations", Tenant: "root"}},
+		AutoProvision: true,
+	}
+	if err := config.ValidateOIDC(oidc); err != nil {
+		t.Fatalf("invalid OpenID Connect config: %v", err)
+	}
+	cfg := config.Config{Secrets: []string{"secret"}}
+	cfg.OIDC = oidc
+	cfg.DBQueryTimeoutSeconds = 10
+	cfg.ConfigPortal.BaseURL = rfc.URL{URL: url.URL{Scheme: "https", Host: "tp.example.test"}}
+	return cfg
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := newMockIdP(t, jwt.MapClaims{"sub": "alice-subject", "preferred_username": "alice", "email": "alice@example.test", "groups": []string{"cdn-ops"}})
+	defer idp.Close()
+	cfg := testOIDCConfig(t, idp.URL)
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT u.id").WithArgs(idp.URL, "alice-subject").WillReturnRows(sqlmock.NewRows([]string{"id", "username", "role", "tenant_id", "name"}))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("alice").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("operations").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery("SELECT id FROM tenant").WithArgs("root").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO tm_user").WithArgs("alice", 3, 1, "alice@example.test", nil, idp.URL, "alice-subject").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	mock.ExpectExec("INSERT INTO log").WithArgs("APICHANGE", "USER: alice, ID: 42, ACTION: Created user from OpenID Connect login with role operations and tenant root", 42).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT role.name").WithArgs("alice").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("operations"))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/4.0/user/login/oidc?redirect=/dashboard", nil)
+	OIDCLoginHandler(db, cfg)(w, r)
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected login to redirect to the identity provider, actual status %d: %s", w.Code, w.Body.String())
+	}
+	authURL := w.Header().Get("Location")
+	if !strings.HasPrefix(authURL, idp.URL+"/authorize?") {
+		t.Fatalf("expected a redirect to the authorization endpoint, actual '%s'", authURL)
+	}
+	stateCookie := w.Result().Cookies()[0]
+
+	// follow the identity provider's redirect back to the callback
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("requesting authorization: %v", err)
+	}
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parsing callback URL: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/api/4.0/user/login/oidc/callback?"+callback.RawQuery, nil)
+	r.AddCookie(stateCookie)
+	OIDCCallbackHandler(db, cfg)(w, r)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/dashboard" {
+		t.Fatalf("expected a redirect to /dashboard, actual status %d, location '%s': %s", w.Code, w.Header().Get("Location"), w.Body.String())
+	}
+	loggedIn := false
+	for _, c := range w.Result().Cookies() {
+		if c.Name == tocookie.Name {
+			cookie, err := tocookie.Parse(cfg.Secrets[0], c.Value)
+			loggedIn = err == nil && cookie.AuthData == "alice"
+		}
+	}
+	if !loggedIn {
+		t.Error("expected alice to be given a login cookie")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	// the state can't be used with a different state parameter, or after it's been tampered with
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/api/4.0/user/login/oidc/callback?code=code-1&state=forged", nil)
+	r.AddCookie(stateCookie)
+	OIDCCallbackHandler(db, cfg)(w, r)
+	if !strings.Contains(w.Body.String(), "invalid or expired login state") {
+		t.Errorf("expected a forged state to be rejected, actual: %s", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/api/4.0/user/login/oidc/callback?"+callback.RawQuery, nil)
+	r.AddCookie(&http.Cookie{Name: oidcStateCookieName, Value: stateCookie.Value + "0"})
+	OIDCCallbackHandler(db, cfg)(w, r)
+	if !strings.Contains(w.Body.String(), "invalid or expired login state") {
+		t.Errorf("expected a tampered state cookie to be rejected, actual: %s", w.Body.String())
+	}
+}
+
+func TestOIDCLoginUnmappedUser(t *testing.T) {
+	idp := newMockIdP(t, jwt.MapClaims{"sub": "mallory-subject", "preferred_username": "mallory", "groups": []string{"marketing"}})
+	defer idp.Close()
+	cfg := testOIDCConfig(t, idp.URL)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/4.0/user/login/oidc", nil)
+	OIDCLoginHandler(nil, cfg)(w, r)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("requesting authorization: %v", err)
+	}
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parsing callback URL: %v", err)
+	}
+
+	stateCookie := w.Result().Cookies()[0]
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/api/4.0/user/login/oidc/callback?"+callback.RawQuery, nil)
+	r.AddCookie(stateCookie)
+	OIDCCallbackHandler(nil, cfg)(w, r)
+	if !strings.Contains(w.Body.String(), "user 'mallory' is not authorized to use Traffic Ops") {
+		t.Errorf("expected a user in no mapped group to be forbidden, actual: %s", w.Body.String())
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == tocookie.Name {
+			t.Error("expected no login cookie for a user in no mapped group")
+		}
+	}
+}
+
+func TestIsAllowedOIDCRedirect(t *testing.T) {
+	cfg := testOIDCConfig(t, "https://idp.example.test")
+	for redirect, expected := range map[string]bool{
+		"/":                                true,
+		"/#!/delivery-services":            true,
+		"https://tp.example.test/#!/login": true,
+		"//evil.example.test/":             false,
+		"/\\evil.example.test/":            false,
+		"/\\/evil.example.test/":           false,
+		"/\tevil.example.test/":            false,
+		"/\t/evil.example.test/":           false,
+		"https://tp.example.test\\@evil/":  false,
+		"https://evil.example.test/":       false,
+		"javascript:alert(1)":              false,
+		"dashboard":                        false,
+	} {
+		if actual := isAllowedOIDCRedirect(redirect, cfg); actual != expected {
+			t.Errorf("expected isAllowedOIDCRedirect(%s) to be %t, actual %t", redirect, expected, actual)
+		}
+	}
+}
+
+func TestProvisionOIDCUser(t *testing.T) {
+	cfg := config.ConfigOIDC{AutoProvision: true, SyncUsers: true}
+	identity := auth.OIDCIdentity{Issuer: "https://idp.example.test", Subject: "mallory-subject", Username: "admin"}
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	// an identity claiming the username of an existing user isn't linked to them
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT u.id").WithArgs(identity.Issuer, identity.Subject).WillReturnRows(sqlmock.NewRows([]string{"id", "username", "role", "tenant_id", "name"}))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("admin").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	tx, err := mockDB.Begin()
+	if err != nil {
+		t.Fatalf("beginning transaction: %v", err)
+	}
+	username, userErr, sysErr, errCode := provisionOIDCUser(tx, cfg, identity, "admin", "root")
+	if username != "" || userErr == nil || sysErr != nil || errCode != http.StatusForbidden {
+		t.Errorf("expected an identity claiming an existing username to be forbidden, actual username '%s', errors %v, %v, code %d", username, userErr, sysErr, errCode)
+	}
+
+	// a linked user is found by their identity, whatever their username claim
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT u.id").WithArgs(identity.Issuer, identity.Subject).WillReturnRows(sqlmock.NewRows([]string{"id", "username", "role", "tenant_id", "name"}).AddRow(7, "mallory", 3, 1, "operations"))
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("operations").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery("SELECT id FROM tenant").WithArgs("root").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	tx, err = mockDB.Begin()
+	if err != nil {
+		t.Fatalf("beginning transaction: %v", err)
+	}
+	username, userErr, sysErr, _ = provisionOIDCUser(tx, cfg, identity, "operations", "root")
+	if username != "mallory" || userErr != nil || sysErr != nil {
+		t.Errorf("expected the linked user 'mallory', actual username '%s', errors %v, %v", username, userErr, sysErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
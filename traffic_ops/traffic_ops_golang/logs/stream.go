@@ -0,0 +1,317 @@
+package logs
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-rfc"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+)
+
+// StreamPollInterval is how often the change log is checked for new entries while there are streaming clients.
+const StreamPollInterval = time.Second
+
+// StreamKeepAliveInterval is how often a comment is sent to idle streaming clients, to keep intermediate proxies from closing the connection.
+const StreamKeepAliveInterval = 15 * time.Second
+
+// StreamMaxBacklog is the maximum number of entries sent to a client resuming a stream with a Last-Event-ID header, or read per poll.
+const StreamMaxBacklog = 1000
+
+// streamBufferSize is the number of entries buffered for each streaming client. Clients which fall this far behind are disconnected, and may resume with the Last-Event-ID header.
+const streamBufferSize = 256
+
+// LastEventIDHeader is the header sent by Server-Sent Event clients reconnecting to a stream, containing the ID of the last event they received.
+const LastEventIDHeader = "Last-Event-ID"
+
+const selectLogsAfterQuery = `
+SELECT l.id, l.level, l.message, u.username as user, l.ticketnum, l.last_updated
+FROM "log" as l JOIN tm_user as u ON l.tm_user = u.id
+WHERE l.id > $1
+ORDER BY l.id ASC
+LIMIT $2
+`
+
+// streamFilter is the set of optional filters a client may apply to a change log stream. Empty fields match all entries.
+type streamFilter struct {
+	User  string
+	CDN   string
+	Table string
+}
+
+// cdnRegex matches the name of a CDN in a change log message, e.g. "CDN: foo, ACTION: ...".
+var cdnRegex = regexp.MustCompile(`(?i)\bCDN: ([^,\s]+)`)
+
+// matches returns whether the change log entry l passes the filter.
+// The change log has no structured CDN or table columns, so these are matched against the message: the table is the object type the message starts with (e.g. "DIVISION: ..."), and the CDN is any "CDN: name" in the message.
+func (f streamFilter) matches(l tc.Log) bool {
+	if f.User != "" && (l.User == nil || *l.User != f.User) {
+		return false
+	}
+	msg := ""
+	if l.Message != nil {
+		msg = *l.Message
+	}
+	if f.Table != "" {
+		i := strings.Index(msg, ":")
+		if i < 0 || !strings.EqualFold(strings.TrimSpace(msg[:i]), f.Table) {
+			return false
+		}
+	}
+	if f.CDN != "" {
+		found := false
+		for _, match := range cdnRegex.FindAllStringSubmatch(msg, -1) {
+			if match[1] == f.CDN {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// broadcaster polls the change log for new entries while it has subscribers, and sends them to every subscriber.
+type broadcaster struct {
+	m       sync.Mutex
+	subs    map[chan tc.Log]struct{}
+	running bool
+}
+
+var logBroadcaster = &broadcaster{subs: map[chan tc.Log]struct{}{}}
+
+// subscribe returns a channel on which new change log entries will be sent, starting the poller with the given db if it isn't running.
+// The channel is closed if the subscriber falls too far behind, or when unsubscribe is called.
+func (b *broadcaster) subscribe(db *sql.DB) chan tc.Log {
+	ch := make(chan tc.Log, streamBufferSize)
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.subs[ch] = struct{}{}
+	if !b.running {
+		b.running = true
+		go b.run(db)
+	}
+	return ch
+}
+
+func (b *broadcaster) unsubscribe(ch chan tc.Log) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// run polls the change log until there are no subscribers left.
+func (b *broadcaster) run(db *sql.DB) {
+	lastID, err := getMaxLogID(db)
+	if err != nil {
+		log.Errorln("change log stream: getting latest log ID: " + err.Error())
+	}
+	ticker := time.NewTicker(StreamPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		b.m.Lock()
+		if len(b.subs) == 0 {
+			b.running = false
+			b.m.Unlock()
+			return
+		}
+		b.m.Unlock()
+
+		logs, err := getLogsAfter(db, lastID, StreamMaxBacklog)
+		if err != nil {
+			log.Errorln("change log stream: " + err.Error())
+			continue
+		}
+		if len(logs) == 0 {
+			continue
+		}
+		lastID = *logs[len(logs)-1].ID
+		b.publish(logs)
+	}
+}
+
+func (b *broadcaster) publish(logs []tc.Log) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	for ch := range b.subs {
+		for _, l := range logs {
+			if !trySend(ch, l) {
+				log.Warnln("change log stream: subscriber fell behind, disconnecting")
+				delete(b.subs, ch)
+				close(ch)
+				break
+			}
+		}
+	}
+}
+
+// trySend sends l on ch without blocking, and returns whether it was sent.
+func trySend(ch chan tc.Log, l tc.Log) bool {
+	select {
+	case ch <- l:
+		return true
+	default:
+		return false
+	}
+}
+
+func getMaxLogID(db *sql.DB) (int, error) {
+	id := 0
+	if err := db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM "log"`).Scan(&id); err != nil {
+		return 0, errors.New("querying max log ID: " + err.Error())
+	}
+	return id, nil
+}
+
+// getLogsAfter returns up to limit change log entries with IDs greater than id, in ascending ID order.
+func getLogsAfter(db *sql.DB, id int, limit int) ([]tc.Log, error) {
+	rows, err := db.Query(selectLogsAfterQuery, id, limit)
+	if err != nil {
+		return nil, errors.New("querying logs: " + err.Error())
+	}
+	defer log.Close(rows, "closing log stream rows")
+	ls := []tc.Log{}
+	for rows.Next() {
+		l := tc.Log{}
+		if err = rows.Scan(&l.ID, &l.Level, &l.Message, &l.User, &l.TicketNum, &l.LastUpdated); err != nil {
+			return nil, errors.New("scanning logs: " + err.Error())
+		}
+		ls = append(ls, l)
+	}
+	return ls, rows.Err()
+}
+
+// writeEvent writes the change log entry l to w as a Server-Sent Event of type "log", with the entry's ID as the event ID.
+func writeEvent(w io.Writer, l tc.Log) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return errors.New("marshalling log: " + err.Error())
+	}
+	id := 0
+	if l.ID != nil {
+		id = *l.ID
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: log\ndata: %s\n\n", id, data)
+	return err
+}
+
+// Stream is the handler for GET requests to /logs/stream.
+// It sends new change log entries to the client as Server-Sent Events until the client disconnects. Entries may be filtered with the 'user', 'cdn', and 'table' query parameters.
+// Clients reconnecting with a Last-Event-ID header are first sent the entries they missed.
+func Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		api.HandleErr(w, r, nil, http.StatusInternalServerError, nil, errors.New("log stream: response writer does not support flushing"))
+		return
+	}
+	db, err := api.GetDB(r.Context())
+	if err != nil {
+		api.HandleErr(w, r, nil, http.StatusInternalServerError, nil, errors.New("log stream: "+err.Error()))
+		return
+	}
+
+	lastID := -1
+	if lastEventID := r.Header.Get(LastEventIDHeader); lastEventID != "" {
+		if lastID, err = strconv.Atoi(lastEventID); err != nil || lastID < 0 {
+			api.HandleErr(w, r, nil, http.StatusBadRequest, errors.New(LastEventIDHeader+" header must be a non-negative integer"), nil)
+			return
+		}
+	}
+	params := r.URL.Query()
+	filter := streamFilter{User: params.Get("user"), CDN: params.Get("cdn"), Table: params.Get("table")}
+
+	// subscribe before reading the backlog, so nothing is missed between the two; duplicates are skipped by ID.
+	ch := logBroadcaster.subscribe(db.DB)
+	defer logBroadcaster.unsubscribe(ch)
+
+	backlog := []tc.Log{}
+	if lastID >= 0 {
+		if backlog, err = getLogsAfter(db.DB, lastID, StreamMaxBacklog); err != nil {
+			api.HandleErr(w, r, nil, http.StatusInternalServerError, nil, errors.New("log stream: getting backlog: "+err.Error()))
+			return
+		}
+	}
+
+	w.Header().Set(rfc.ContentType, "text/event-stream")
+	w.Header().Set(rfc.CacheControl, "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(l tc.Log) bool {
+		if l.ID == nil || *l.ID <= lastID {
+			return true
+		}
+		lastID = *l.ID
+		if !filter.matches(l) {
+			return true
+		}
+		if err := writeEvent(w, l); err != nil {
+			log.Warnln("log stream: writing event: " + err.Error())
+			return false
+		}
+		return true
+	}
+	for _, l := range backlog {
+		if !send(l) {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(StreamKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ":\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case l, ok := <-ch:
+			if !ok {
+				return // fell behind; the client will reconnect with Last-Event-ID
+			}
+			if !send(l) {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
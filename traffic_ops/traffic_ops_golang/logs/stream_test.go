@@ -0,0 +1,98 @@
+package logs
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+)
+
+func TestStreamFilterMatches(t *testing.T) {
+	l := tc.Log{
+		ID:      util.IntPtr(5),
+		Message: util.StrPtr("DELIVERYSERVICE: demo1, ID: 1, ACTION: Updated deliveryservice, CDN: cdn1"),
+		User:    util.StrPtr("admin"),
+	}
+	tests := []struct {
+		filter  streamFilter
+		matches bool
+	}{
+		{streamFilter{}, true},
+		{streamFilter{User: "admin"}, true},
+		{streamFilter{User: "operator"}, false},
+		{streamFilter{Table: "deliveryservice"}, true},
+		{streamFilter{Table: "server"}, false},
+		{streamFilter{CDN: "cdn1"}, true},
+		{streamFilter{CDN: "cdn"}, false},
+		{streamFilter{User: "admin", Table: "DeliveryService", CDN: "cdn1"}, true},
+		{streamFilter{User: "admin", Table: "DeliveryService", CDN: "cdn2"}, false},
+	}
+	for _, test := range tests {
+		if actual := test.filter.matches(l); actual != test.matches {
+			t.Errorf("filter %+v: expected matches %t, actual %t", test.filter, test.matches, actual)
+		}
+	}
+
+	if (streamFilter{Table: "cdn"}).matches(tc.Log{Message: util.StrPtr("no object type here")}) {
+		t.Error("expected a message without an object type not to match a table filter")
+	}
+}
+
+func TestWriteEvent(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := tc.Log{ID: util.IntPtr(42), Level: util.StrPtr("APICHANGE"), Message: util.StrPtr("CDN: cdn1, ACTION: Lock Acquired")}
+	if err := writeEvent(buf, l); err != nil {
+		t.Fatalf("writing event: %v", err)
+	}
+	expected := "id: 42\nevent: log\ndata: {\"id\":42,\"lastUpdated\":null,\"level\":\"APICHANGE\",\"message\":\"CDN: cdn1, ACTION: Lock Acquired\",\"ticketNum\":null,\"user\":null}\n\n"
+	if buf.String() != expected {
+		t.Errorf("expected event %q, actual %q", expected, buf.String())
+	}
+}
+
+func TestBroadcasterPublish(t *testing.T) {
+	b := &broadcaster{subs: map[chan tc.Log]struct{}{}}
+	fast := make(chan tc.Log, 2)
+	slow := make(chan tc.Log, 1)
+	b.subs[fast] = struct{}{}
+	b.subs[slow] = struct{}{}
+
+	b.publish([]tc.Log{{ID: util.IntPtr(1)}, {ID: util.IntPtr(2)}})
+
+	if len(fast) != 2 {
+		t.Errorf("expected 2 entries sent to subscriber, actual %d", len(fast))
+	}
+	if _, ok := b.subs[slow]; ok {
+		t.Error("expected subscriber which fell behind to be removed")
+	}
+	<-slow
+	if _, ok := <-slow; ok {
+		t.Error("expected channel of subscriber which fell behind to be closed")
+	}
+
+	b.unsubscribe(fast)
+	if len(b.subs) != 0 {
+		t.Errorf("expected no subscribers after unsubscribe, actual %d", len(b.subs))
+	}
+	b.unsubscribe(slow) // must not double-close
+}
@@ -0,0 +1,347 @@
+// Package metrics collects operational metrics of Traffic Ops, and serves them
+// in the OpenMetrics text format for scraping by Prometheus.
+package metrics
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-rfc"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ContentType is the media type of the OpenMetrics text format.
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// Namespace is the prefix of the names of all Traffic Ops metrics.
+const Namespace = "traffic_ops"
+
+// DefaultBuckets are the upper bounds, in seconds, of the buckets of
+// histograms of durations.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// These are the metrics collected by Traffic Ops.
+var (
+	// Requests counts handled API requests by route ID and response status
+	// code.
+	Requests = NewCounterVec("http_requests", "Number of handled HTTP requests, by route ID and response status code.", "route_id", "code")
+	// RequestDuration observes the time taken to handle API requests by
+	// route ID and response status code.
+	RequestDuration = NewHistogramVec("http_request_duration_seconds", "Time taken to handle HTTP requests, by route ID and response status code.", DefaultBuckets, "route_id", "code")
+	// TrafficVaultDuration observes the time taken by calls to the Traffic
+	// Vault backend, by method and whether the call returned an error.
+	TrafficVaultDuration = NewHistogramVec("traffic_vault_duration_seconds", "Time taken by calls to the Traffic Vault backend, by method and result.", DefaultBuckets, "method", "result")
+	// PluginOnRequestDuration observes the time taken by the OnRequest
+	// function of each plugin.
+	PluginOnRequestDuration = NewHistogramVec("plugin_on_request_duration_seconds", "Time taken by the OnRequest function of each plugin.", DefaultBuckets, "plugin")
+	// SnapshotDuration observes the time taken to generate and store
+	// Snapshots, by CDN.
+	SnapshotDuration = NewHistogramVec("snapshot_duration_seconds", "Time taken to generate and store a Snapshot, by CDN.", DefaultBuckets, "cdn")
+)
+
+// collectors are the metrics which are written by Handler, in order.
+var collectors = []collector{Requests, RequestDuration, TrafficVaultDuration, PluginOnRequestDuration, SnapshotDuration}
+
+// collector is a family of metrics which can write itself in the OpenMetrics
+// text format.
+type collector interface {
+	write(w io.Writer)
+}
+
+// Result returns the value of the "result" label of an operation which
+// returned the given error.
+func Result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// series is the label values of one series of a metric family, in the order
+// of the family's label names.
+type series []string
+
+// key returns a unique key for the series.
+func (s series) key() string {
+	return strings.Join(s, "\xff")
+}
+
+// labels formats the series' label values with the given names, plus any
+// extra label pairs, as an OpenMetrics label set.
+func (s series) labels(names []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+len(extra)/2)
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabelValue(s[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabelValue(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// escapeLabelValue escapes a label value as required by the OpenMetrics text
+// format.
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// formatFloat formats a sample value.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// writeHeader writes the TYPE and HELP lines of a metric family.
+func writeHeader(w io.Writer, name string, typ string, help string) {
+	fmt.Fprintf(w, "# TYPE %s %s\n# HELP %s %s\n", name, typ, name, help)
+}
+
+// CounterVec is a family of counters, partitioned by label values.
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+	mutex  sync.Mutex
+	series map[string]series
+	values map[string]float64
+}
+
+// NewCounterVec returns a new CounterVec with the given name (which is
+// prefixed with Namespace, and must not end in "_total"), help text, and label
+// names.
+func NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	return &CounterVec{name: Namespace + "_" + name, help: help, labels: labels, series: map[string]series{}, values: map[string]float64{}}
+}
+
+// Inc increments the counter with the given label values, which must be given
+// in the order of the CounterVec's label names.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds the given non-negative value to the counter with the given label
+// values.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	s := series(labelValues)
+	k := s.key()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.series[k]; !ok {
+		c.series[k] = append(series(nil), s...)
+	}
+	c.values[k] += v
+}
+
+// Get returns the value of the counter with the given label values.
+func (c *CounterVec) Get(labelValues ...string) float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.values[series(labelValues).key()]
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	writeHeader(w, c.name, "counter", c.help)
+	for _, k := range sortedKeys(c.series) {
+		fmt.Fprintf(w, "%s_total%s %s\n", c.name, c.series[k].labels(c.labels), formatFloat(c.values[k]))
+	}
+}
+
+// histogram is the state of one series of a HistogramVec.
+type histogram struct {
+	counts []uint64 // counts[i] is the number of observations in bucket i, non-cumulatively; the last is +Inf.
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec is a family of histograms, partitioned by label values.
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	mutex   sync.Mutex
+	series  map[string]series
+	values  map[string]*histogram
+}
+
+// NewHistogramVec returns a new HistogramVec with the given name (which is
+// prefixed with Namespace), help text, bucket upper bounds (which must be
+// sorted in increasing order), and label names.
+func NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{name: Namespace + "_" + name, help: help, labels: labels, buckets: buckets, series: map[string]series{}, values: map[string]*histogram{}}
+}
+
+// Observe adds an observation of the given value to the histogram with the
+// given label values, which must be given in the order of the HistogramVec's
+// label names.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	s := series(labelValues)
+	k := s.key()
+	i := sort.SearchFloat64s(h.buckets, v) // the first bucket with an upper bound >= v, or len(h.buckets) for +Inf
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	hist, ok := h.values[k]
+	if !ok {
+		h.series[k] = append(series(nil), s...)
+		hist = &histogram{counts: make([]uint64, len(h.buckets)+1)}
+		h.values[k] = hist
+	}
+	hist.counts[i]++
+	hist.count++
+	hist.sum += v
+}
+
+// ObserveSince observes the time elapsed since start, in seconds.
+func (h *HistogramVec) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// Count returns the number of observations made of the histogram with the
+// given label values.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if hist, ok := h.values[series(labelValues).key()]; ok {
+		return hist.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	writeHeader(w, h.name, "histogram", h.help)
+	for _, k := range sortedKeys(h.series) {
+		s := h.series[k]
+		hist := h.values[k]
+		cumulative := uint64(0)
+		for i, count := range hist.counts {
+			cumulative += count
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, s.labels(h.labels, "le", formatFloat(le)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, s.labels(h.labels), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, s.labels(h.labels), formatFloat(hist.sum))
+	}
+}
+
+// sortedKeys returns the keys of the given series, sorted, so that metrics are
+// written in a stable order.
+func sortedKeys(m map[string]series) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeGauge writes a metric family of a single gauge without labels.
+func writeGauge(w io.Writer, name string, help string, v float64) {
+	name = Namespace + "_" + name
+	writeHeader(w, name, "gauge", help)
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(v))
+}
+
+// writeDBStats writes the connection pool statistics of the Traffic Ops
+// Database.
+func writeDBStats(w io.Writer, stats sql.DBStats) {
+	writeGauge(w, "db_max_open_connections", "Maximum number of open connections to the database; zero is unlimited.", float64(stats.MaxOpenConnections))
+	name := Namespace + "_db_connections"
+	writeHeader(w, name, "gauge", "Number of open connections to the database, by state.")
+	fmt.Fprintf(w, "%s{state=\"idle\"} %d\n", name, stats.Idle)
+	fmt.Fprintf(w, "%s{state=\"in_use\"} %d\n", name, stats.InUse)
+	name = Namespace + "_db_wait"
+	writeHeader(w, name, "counter", "Number of times a connection to the database was waited for.")
+	fmt.Fprintf(w, "%s_total %d\n", name, stats.WaitCount)
+	name = Namespace + "_db_wait_duration_seconds"
+	writeHeader(w, name, "counter", "Total time spent waiting for connections to the database.")
+	fmt.Fprintf(w, "%s_total %s\n", name, formatFloat(stats.WaitDuration.Seconds()))
+}
+
+// pendingAsyncJobsQuery counts the asynchronous jobs which haven't finished.
+const pendingAsyncJobsQuery = `SELECT COUNT(*) FROM async_status WHERE status = 'PENDING'`
+
+// Write writes all metrics in the OpenMetrics text format. The database pool
+// statistics and the number of pending asynchronous jobs are read from db,
+// taking up to timeout.
+func Write(w io.Writer, db *sql.DB, timeout time.Duration) {
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	if db != nil {
+		writeDBStats(bw, db.Stats())
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		pending := 0
+		if err := db.QueryRowContext(ctx, pendingAsyncJobsQuery).Scan(&pending); err != nil {
+			log.Errorln("metrics: counting pending async jobs: " + err.Error())
+		} else {
+			writeGauge(bw, "async_jobs_pending", "Number of asynchronous jobs which haven't finished.", float64(pending))
+		}
+	}
+	fmt.Fprint(bw, "# EOF\n")
+	if err := bw.Flush(); err != nil {
+		log.Errorln("metrics: writing response: " + err.Error())
+	}
+}
+
+// Handler returns an http.HandlerFunc which serves all metrics in the
+// OpenMetrics text format.
+func Handler(db *sqlx.DB, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var sqlDB *sql.DB
+		if db != nil {
+			sqlDB = db.DB
+		}
+		w.Header().Set(rfc.ContentType, ContentType)
+		Write(w, sqlDB, timeout)
+	}
+}
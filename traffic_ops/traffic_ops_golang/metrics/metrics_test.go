@@ -0,0 +1,116 @@
+package metrics
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestCounterVec(t *testing.T) {
+	c := NewCounterVec("test_things", "Things.", "kind", "code")
+	c.Inc("a", "200")
+	c.Inc("a", "200")
+	c.Add(3, `b"\`, "500")
+
+	if actual := c.Get("a", "200"); actual != 2 {
+		t.Errorf("expected counter to be 2, actual: %v", actual)
+	}
+
+	buf := &bytes.Buffer{}
+	c.write(buf)
+	expected := `# TYPE traffic_ops_test_things counter
+# HELP traffic_ops_test_things Things.
+traffic_ops_test_things_total{kind="a",code="200"} 2
+traffic_ops_test_things_total{kind="b\"\\",code="500"} 3
+`
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	h := NewHistogramVec("test_duration_seconds", "Durations.", []float64{0.1, 1}, "method")
+	h.Observe(0.05, "Get")
+	h.Observe(0.1, "Get")
+	h.Observe(0.5, "Get")
+	h.Observe(2, "Get")
+
+	if actual := h.Count("Get"); actual != 4 {
+		t.Errorf("expected 4 observations, actual: %d", actual)
+	}
+	if actual := h.Count("Put"); actual != 0 {
+		t.Errorf("expected no observations of an unobserved series, actual: %d", actual)
+	}
+
+	buf := &bytes.Buffer{}
+	h.write(buf)
+	expected := `# TYPE traffic_ops_test_duration_seconds histogram
+# HELP traffic_ops_test_duration_seconds Durations.
+traffic_ops_test_duration_seconds_bucket{method="Get",le="0.1"} 2
+traffic_ops_test_duration_seconds_bucket{method="Get",le="1"} 3
+traffic_ops_test_duration_seconds_bucket{method="Get",le="+Inf"} 4
+traffic_ops_test_duration_seconds_count{method="Get"} 4
+traffic_ops_test_duration_seconds_sum{method="Get"} 2.65
+`
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+}
+
+func TestWrite(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	SnapshotDuration.Observe(0.2, "test-cdn")
+
+	buf := &bytes.Buffer{}
+	Write(buf, mockDB, time.Second)
+	out := buf.String()
+
+	for _, expected := range []string{
+		"# TYPE traffic_ops_http_requests counter\n",
+		"# TYPE traffic_ops_http_request_duration_seconds histogram\n",
+		"# TYPE traffic_ops_traffic_vault_duration_seconds histogram\n",
+		"# TYPE traffic_ops_plugin_on_request_duration_seconds histogram\n",
+		`traffic_ops_snapshot_duration_seconds_count{cdn="test-cdn"} 1` + "\n",
+		"traffic_ops_db_max_open_connections 0\n",
+		`traffic_ops_db_connections{state="in_use"} `,
+		"traffic_ops_async_jobs_pending 3\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected output to contain '%s', actual:\n%s", expected, out)
+		}
+	}
+	if !strings.HasSuffix(out, "\n# EOF\n") {
+		t.Errorf("expected output to end with '# EOF', actual:\n%s", out)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet database expectations: %v", err)
+	}
+}
@@ -106,12 +106,13 @@ type LegacyMonitoring struct {
 }
 
 type Monitoring struct {
-	TrafficServers   []Cache                `json:"trafficServers"`
-	TrafficMonitors  []Monitor              `json:"trafficMonitors"`
-	Cachegroups      []Cachegroup           `json:"cacheGroups"`
-	Profiles         []Profile              `json:"profiles"`
-	DeliveryServices []DeliveryService      `json:"deliveryServices"`
-	Config           map[string]interface{} `json:"config"`
+	TrafficServers     []Cache                  `json:"trafficServers"`
+	TrafficMonitors    []Monitor                `json:"trafficMonitors"`
+	Cachegroups        []Cachegroup             `json:"cacheGroups"`
+	Profiles           []Profile                `json:"profiles"`
+	DeliveryServices   []DeliveryService        `json:"deliveryServices"`
+	Config             map[string]interface{}   `json:"config"`
+	MaintenanceWindows []tc.TMMaintenanceWindow `json:"maintenanceWindows"`
 }
 
 // LegacyMonitoringResponse represents MontiroingResponse for ATC versions before 5.0.
@@ -171,13 +172,19 @@ func GetMonitoringJSON(tx *sql.Tx, cdnName string) (*Monitoring, error) {
 		return nil, fmt.Errorf("error getting config: %v", err)
 	}
 
+	maintenanceWindows, err := getMaintenanceWindows(tx, cdnName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting maintenance windows: %v", err)
+	}
+
 	return &Monitoring{
-		TrafficServers:   caches,
-		TrafficMonitors:  monitors,
-		Cachegroups:      cachegroups,
-		Profiles:         profiles,
-		DeliveryServices: deliveryServices,
-		Config:           config,
+		TrafficServers:     caches,
+		TrafficMonitors:    monitors,
+		Cachegroups:        cachegroups,
+		Profiles:           profiles,
+		DeliveryServices:   deliveryServices,
+		Config:             config,
+		MaintenanceWindows: maintenanceWindows,
 	}, nil
 }
 
@@ -518,3 +525,33 @@ AND c.name = $3
 	}
 	return cfg, nil
 }
+
+// getMaintenanceWindows returns the maintenance windows of the servers in the
+// given CDN which haven't yet ended.
+func getMaintenanceWindows(tx *sql.Tx, cdnName string) ([]tc.TMMaintenanceWindow, error) {
+	query := `
+SELECT s.host_name, w.start_time, w.end_time, w.reason
+FROM server_maintenance_window w
+JOIN server s ON s.id = w.server
+JOIN cdn c ON c.id = s.cdn_id
+WHERE c.name = $1
+AND w.state <> '` + tc.MaintenanceWindowComplete + `'
+AND w.end_time > now()
+ORDER BY s.host_name, w.start_time
+`
+	rows, err := tx.Query(query, cdnName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []tc.TMMaintenanceWindow{}
+	for rows.Next() {
+		window := tc.TMMaintenanceWindow{}
+		if err := rows.Scan(&window.HostName, &window.Start, &window.End, &window.Reason); err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
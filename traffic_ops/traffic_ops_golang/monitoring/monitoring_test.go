@@ -516,6 +516,23 @@ func TestGetMonitoringJSON(t *testing.T) {
 		mock.ExpectQuery("SELECT").WillReturnRows(rows)
 		resp.Response.Config = config
 	}
+	{
+		//
+		// getMaintenanceWindows
+		//
+		window := tc.TMMaintenanceWindow{
+			HostName: "test",
+			Start:    time.Date(2021, time.June, 9, 3, 0, 0, 0, time.UTC),
+			End:      time.Date(2021, time.June, 9, 5, 0, 0, 0, time.UTC),
+			Reason:   "disk replacement",
+		}
+
+		rows := sqlmock.NewRows([]string{"host_name", "start_time", "end_time", "reason"})
+		rows = rows.AddRow(window.HostName, window.Start, window.End, window.Reason)
+
+		mock.ExpectQuery("SELECT").WithArgs(cdn).WillReturnRows(rows)
+		resp.Response.MaintenanceWindows = []tc.TMMaintenanceWindow{window}
+	}
 
 	dbCtx, f := context.WithTimeout(context.TODO(), time.Duration(10)*time.Second)
 	defer f()
@@ -563,6 +580,9 @@ func TestGetMonitoringJSON(t *testing.T) {
 	if !reflect.DeepEqual(sqlResp.Config, resp.Response.Config) {
 		t.Errorf("GetMonitoringJSON expected Config: %+v actual: %+v", resp.Response.Config, sqlResp.Config)
 	}
+	if !reflect.DeepEqual(sqlResp.MaintenanceWindows, resp.Response.MaintenanceWindows) {
+		t.Errorf("GetMonitoringJSON expected MaintenanceWindows: %+v actual: %+v", resp.Response.MaintenanceWindows, sqlResp.MaintenanceWindows)
+	}
 }
 
 type SortableProfiles []Profile
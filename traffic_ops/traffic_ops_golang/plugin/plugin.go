@@ -27,6 +27,7 @@ import (
 
 	"github.com/apache/trafficcontrol/lib/go-log"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/metrics"
 )
 
 // List returns the list of plugin names compiled into the calling executable.
@@ -196,7 +197,10 @@ func (ps plugins) OnRequest(d OnRequestData) bool {
 		d.Ctx = ps.ctx[p.info.Name]
 		d.Cfg = ps.cfg[p.info.Name]
 		log.Debugln("plugins.OnRequest plugging " + p.info.Name)
-		if stop := p.funcs.onRequest(d); stop {
+		start := time.Now()
+		stop := p.funcs.onRequest(d)
+		metrics.PluginOnRequestDuration.ObserveSince(start, p.info.Name)
+		if stop {
 			return true
 		}
 	}
@@ -16,13 +16,16 @@ package plugin
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/apache/trafficcontrol/lib/go-log"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
 )
 
 // The proxy plugin reverse-proxies to other HTTP services, as configured.
@@ -92,11 +95,17 @@ func proxyOnReq(d OnRequestData) IsRequestHandled {
 }
 
 func proxyHandle(w http.ResponseWriter, r *http.Request, d OnRequestData, proxyURI *url.URL) IsRequestHandled {
-	_, userErr, sysErr, errCode := api.GetUserFromReq(w, r, d.AppCfg.Secrets[0]) // require login
+	user, userErr, sysErr, errCode := api.GetUserFromReq(w, r, d.AppCfg.Secrets[0]) // require login
 	if userErr != nil || sysErr != nil {
 		api.HandleErr(w, r, nil, errCode, userErr, sysErr)
 		return RequestHandled
 	}
+	if user.Token != nil { // proxied paths have no route ID, so only unrestricted tokens may use them
+		if err := user.Token.Check(auth.Route{Method: r.Method}, time.Now()); err != nil {
+			api.HandleErr(w, r, nil, http.StatusForbidden, errors.New("Forbidden: "+err.Error()+"."), nil)
+			return RequestHandled
+		}
+	}
 	rp := httputil.NewSingleHostReverseProxy(proxyURI)
 	rp.ServeHTTP(w, r)
 	return RequestHandled
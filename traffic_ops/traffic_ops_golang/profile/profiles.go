@@ -21,6 +21,7 @@ package profile
 
 import (
 	"errors"
+	"fmt"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/util/ims"
 	"net/http"
 	"strconv"
@@ -94,6 +95,23 @@ func (prof *TOProfile) GetType() string {
 	return "profile"
 }
 
+// CheckCDNLock implements the api.CDNLockable interface by rejecting changes
+// to a Profile when another user has locked either the Profile itself or the
+// CDN to which it belongs.
+func (prof *TOProfile) CheckCDNLock(user *auth.CurrentUser) (error, error, int) {
+	if prof.ID == nil {
+		return nil, nil, http.StatusOK
+	}
+	name, cdn, ok, err := dbhelpers.GetProfileNameAndCDNFromID(prof.ReqInfo.Tx.Tx, *prof.ID)
+	if err != nil {
+		return nil, fmt.Errorf("getting name and cdn of profile #%d: %v", *prof.ID, err), http.StatusInternalServerError
+	}
+	if !ok {
+		return nil, nil, http.StatusOK
+	}
+	return dbhelpers.CheckIfCurrentUserCanModifyCDNObject(prof.ReqInfo.Tx.Tx, string(cdn), tc.CDNLockScopeProfile, name, user.UserName)
+}
+
 func (prof *TOProfile) Validate() error {
 	errs := validation.Errors{
 		NameQueryParam:        validation.Validate(prof.Name, validation.Required),
@@ -0,0 +1,155 @@
+package middleware
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-rfc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+)
+
+// RateLimiter enforces the per-user token-bucket request limits of a config.ConfigRateLimit.
+// It is safe for use by multiple goroutines.
+type RateLimiter struct {
+	cfg     config.ConfigRateLimit
+	now     func() time.Time
+	m       sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewRateLimiter returns a RateLimiter enforcing the given limits.
+func NewRateLimiter(cfg config.ConfigRateLimit) *RateLimiter {
+	return &RateLimiter{cfg: cfg, now: time.Now, buckets: map[string]*tokenBucket{}}
+}
+
+// tokenBucket is a single token bucket. It holds up to burst tokens, and is refilled at rate tokens per second.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit config.RateLimit, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: limit.RequestsPerSecond, burst: float64(limit.Burst), tokens: float64(limit.Burst), last: now}
+}
+
+// refill adds the tokens accrued since the bucket was last refilled.
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// wait returns how long until the bucket will have a token available, which is zero if it has one now.
+func (b *tokenBucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// userLimit returns the overall limit of the given user, and whether the user has a limit at all.
+func (l *RateLimiter) userLimit(user auth.CurrentUser) (config.RateLimit, bool) {
+	if limit, ok := l.cfg.Users[user.UserName]; ok {
+		return limit, true
+	}
+	if limit, ok := l.cfg.Roles[user.RoleName]; ok {
+		return limit, true
+	}
+	if l.cfg.Default != nil {
+		return *l.cfg.Default, true
+	}
+	return config.RateLimit{}, false
+}
+
+// getBucket returns the bucket with the given key, creating it with the given limit if it doesn't exist.
+// The caller must hold the lock.
+func (l *RateLimiter) getBucket(key string, limit config.RateLimit, now time.Time) *tokenBucket {
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = newTokenBucket(limit, now)
+		l.buckets[key] = bucket
+	}
+	bucket.refill(now)
+	return bucket
+}
+
+// Allow returns whether the given user may make a request to the Route with the given ID now, consuming a token from each of the user's applicable buckets if so.
+// If the request is not allowed, the returned Duration is how long the user must wait before the request would be allowed.
+func (l *RateLimiter) Allow(user auth.CurrentUser, routeID int) (bool, time.Duration) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	now := l.now()
+
+	buckets := make([]*tokenBucket, 0, 2)
+	if limit, ok := l.userLimit(user); ok {
+		buckets = append(buckets, l.getBucket("user:"+user.UserName, limit, now))
+	}
+	if limit, ok := l.cfg.Routes[routeID]; ok {
+		buckets = append(buckets, l.getBucket("route:"+strconv.Itoa(routeID)+":"+user.UserName, limit, now))
+	}
+
+	// Tokens are only taken if every bucket has one, so a request denied by one limit doesn't count against another.
+	retryAfter := time.Duration(0)
+	for _, bucket := range buckets {
+		if wait := bucket.wait(); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	if retryAfter > 0 {
+		return false, retryAfter
+	}
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+	return true, 0
+}
+
+// Wrapper returns a Middleware which enforces the configured limits for the Route with the given ID.
+// The limits are per-user, so this must be applied after the authentication Middleware. Requests without a current user are not limited.
+// Requests exceeding a limit are rejected with a 429 Too Many Requests response, with a Retry-After header giving the number of seconds until the request would be allowed.
+func (l *RateLimiter) Wrapper(routeID int) Middleware {
+	return func(handlerFunc http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			user, err := auth.GetCurrentUser(r.Context())
+			if err != nil {
+				handlerFunc(w, r)
+				return
+			}
+			if allowed, retryAfter := l.Allow(*user, routeID); !allowed {
+				retrySeconds := int(math.Ceil(retryAfter.Seconds()))
+				w.Header().Set(rfc.RetryAfter, strconv.Itoa(retrySeconds))
+				api.HandleErr(w, r, nil, http.StatusTooManyRequests, fmt.Errorf("rate limit exceeded, retry in %d seconds", retrySeconds), nil)
+				return
+			}
+			handlerFunc(w, r)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package middleware
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-rfc"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+)
+
+func newTestRateLimiter(cfg config.ConfigRateLimit, now *time.Time) *RateLimiter {
+	l := NewRateLimiter(cfg)
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestRateLimiterUserLimitPrecedence(t *testing.T) {
+	now := time.Now()
+	l := newTestRateLimiter(config.ConfigRateLimit{
+		Default: &config.RateLimit{RequestsPerSecond: 1, Burst: 1},
+		Users:   map[string]config.RateLimit{"bot": {RequestsPerSecond: 1, Burst: 3}},
+		Roles:   map[string]config.RateLimit{"admin": {RequestsPerSecond: 1, Burst: 2}},
+	}, &now)
+
+	tests := []struct {
+		user    auth.CurrentUser
+		allowed int
+	}{
+		{auth.CurrentUser{UserName: "bot", RoleName: "admin"}, 3},
+		{auth.CurrentUser{UserName: "alice", RoleName: "admin"}, 2},
+		{auth.CurrentUser{UserName: "bob", RoleName: "read-only"}, 1},
+	}
+	for _, test := range tests {
+		for i := 0; i < test.allowed; i++ {
+			if ok, _ := l.Allow(test.user, 1); !ok {
+				t.Errorf("user '%s' request %d: expected allowed, actual denied", test.user.UserName, i+1)
+			}
+		}
+		if ok, _ := l.Allow(test.user, 1); ok {
+			t.Errorf("user '%s' request %d: expected denied, actual allowed", test.user.UserName, test.allowed+1)
+		}
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	now := time.Now()
+	l := newTestRateLimiter(config.ConfigRateLimit{Default: &config.RateLimit{RequestsPerSecond: 2, Burst: 1}}, &now)
+	user := auth.CurrentUser{UserName: "bob"}
+
+	if ok, _ := l.Allow(user, 1); !ok {
+		t.Fatal("first request: expected allowed, actual denied")
+	}
+	ok, retryAfter := l.Allow(user, 1)
+	if ok {
+		t.Fatal("second request: expected denied, actual allowed")
+	}
+	if retryAfter != 500*time.Millisecond {
+		t.Errorf("expected retry after 500ms, actual %v", retryAfter)
+	}
+
+	now = now.Add(retryAfter)
+	if ok, _ := l.Allow(user, 1); !ok {
+		t.Error("request after refill: expected allowed, actual denied")
+	}
+}
+
+func TestRateLimiterRouteLimit(t *testing.T) {
+	now := time.Now()
+	l := newTestRateLimiter(config.ConfigRateLimit{
+		Default: &config.RateLimit{RequestsPerSecond: 1, Burst: 3},
+		Routes:  map[int]config.RateLimit{42: {RequestsPerSecond: 1, Burst: 1}},
+	}, &now)
+	user := auth.CurrentUser{UserName: "bob"}
+	other := auth.CurrentUser{UserName: "alice"}
+
+	if ok, _ := l.Allow(user, 42); !ok {
+		t.Error("first route request: expected allowed, actual denied")
+	}
+	if ok, _ := l.Allow(user, 42); ok {
+		t.Error("second route request: expected denied, actual allowed")
+	}
+	if ok, _ := l.Allow(other, 42); !ok {
+		t.Error("other user's route request: expected allowed, actual denied")
+	}
+	// the denied route request must not have consumed a token from the user's overall limit
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow(user, 7); !ok {
+			t.Errorf("unlimited route request %d: expected allowed, actual denied", i+1)
+		}
+	}
+	if ok, _ := l.Allow(user, 7); ok {
+		t.Error("request over the user limit: expected denied, actual allowed")
+	}
+}
+
+func TestRateLimiterNoLimit(t *testing.T) {
+	now := time.Now()
+	l := newTestRateLimiter(config.ConfigRateLimit{Roles: map[string]config.RateLimit{"admin": {RequestsPerSecond: 1, Burst: 1}}}, &now)
+	user := auth.CurrentUser{UserName: "bob", RoleName: "operations"}
+	for i := 0; i < 100; i++ {
+		if ok, _ := l.Allow(user, 1); !ok {
+			t.Fatalf("request %d: expected allowed, actual denied", i+1)
+		}
+	}
+}
+
+func TestRateLimiterWrapper(t *testing.T) {
+	now := time.Now()
+	l := newTestRateLimiter(config.ConfigRateLimit{Default: &config.RateLimit{RequestsPerSecond: 0.5, Burst: 1}}, &now)
+	handler := Use(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}, []Middleware{WrapHeaders, l.Wrapper(1)})
+
+	newReq := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/4.0/servers", nil)
+		return r.WithContext(context.WithValue(r.Context(), auth.CurrentUserKey, auth.CurrentUser{UserName: "bob"}))
+	}
+
+	w := httptest.NewRecorder()
+	handler(w, newReq())
+	if w.Code != http.StatusOK {
+		t.Fatalf("first request: expected status %d, actual %d", http.StatusOK, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, newReq())
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: expected status %d, actual %d", http.StatusTooManyRequests, w.Code)
+	}
+	if retryAfter := w.Header().Get(rfc.RetryAfter); retryAfter != "2" {
+		t.Errorf("expected %s header '2', actual '%s'", rfc.RetryAfter, retryAfter)
+	}
+	alerts := tc.Alerts{}
+	if err := json.Unmarshal(w.Body.Bytes(), &alerts); err != nil {
+		t.Fatalf("unmarshalling response body: %v", err)
+	}
+	if len(alerts.Alerts) != 1 || alerts.Alerts[0].Level != tc.ErrorLevel.String() {
+		t.Errorf("expected a single error alert, actual %+v", alerts.Alerts)
+	}
+}
@@ -23,11 +23,13 @@ package middleware
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/sha512"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/apache/trafficcontrol/lib/go-log"
@@ -36,6 +38,8 @@ import (
 	"github.com/apache/trafficcontrol/lib/go-util"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/about"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/metrics"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
 )
 
@@ -70,6 +74,12 @@ func GetDefault(secret string, requestTimeout time.Duration) []Middleware {
 	return []Middleware{GetWrapAccessLog(secret), TimeOutWrapper(requestTimeout), WrapHeaders, WrapPanicRecover}
 }
 
+// GetDefaultStreaming returns the default middleware for Traffic Ops handlers which stream their response, such as Server-Sent Events.
+// This is GetDefault without the request timeout and the whole-body headers and compression of WrapHeaders, none of which can be applied to an unbounded response.
+func GetDefaultStreaming(secret string) []Middleware {
+	return []Middleware{GetWrapAccessLog(secret), WrapStreamHeaders, WrapPanicRecover}
+}
+
 // Use takes a slice of middlewares, and applies them in reverse order (which is the intuitive behavior) to the given HandlerFunc h.
 // It returns a HandlerFunc which will call all middlewares, and then h.
 func Use(h http.HandlerFunc, middlewares []Middleware) http.HandlerFunc {
@@ -87,8 +97,15 @@ type AuthBase struct {
 }
 
 // GetWrapper returns a Middleware which performs authentication of the current user at the given privilege level.
-// The returned Middleware also adds the auth.CurrentUser object to the request context, which may be retrieved by a handler via api.NewInfo or auth.GetCurrentUser.
+// Requests authenticated with a personal access token restricted to specific routes are always forbidden; see GetRouteWrapper.
 func (a AuthBase) GetWrapper(privLevelRequired int) Middleware {
+	return a.GetRouteWrapper(privLevelRequired, 0)
+}
+
+// GetRouteWrapper returns a Middleware which performs authentication of the current user at the given privilege level,
+// for the route with the given ID. Requests authenticated with a personal access token are forbidden unless the token's
+// scope allows the route and request method.
+func (a AuthBase) GetRouteWrapper(privLevelRequired int, routeID int) Middleware {
 	if a.Override != nil {
 		return a.Override
 	}
@@ -99,10 +116,18 @@ func (a AuthBase) GetWrapper(privLevelRequired int) Middleware {
 				api.HandleErr(w, r, nil, errCode, userErr, sysErr)
 				return
 			}
+			route := auth.Route{ID: routeID, Method: r.Method}
+			if user.Token != nil {
+				if err := user.Token.Check(route, time.Now()); err != nil {
+					api.HandleErr(w, r, nil, http.StatusForbidden, errors.New("Forbidden: "+err.Error()+"."), nil)
+					return
+				}
+			}
 			if user.PrivLevel < privLevelRequired {
 				api.HandleErr(w, r, nil, http.StatusForbidden, errors.New("Forbidden."), nil)
 				return
 			}
+			*r = *r.WithContext(context.WithValue(r.Context(), auth.RouteKey, route))
 			api.AddUserToReq(r, user)
 			handlerFunc(w, r)
 		}
@@ -126,13 +151,8 @@ func TimeOutWrapper(timeout time.Duration) Middleware {
 //  - Adds the Vary: Accept-Encoding header to the response
 func WrapHeaders(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, Set-Cookie, Cookie")
-		w.Header().Set("Access-Control-Allow-Methods", "POST,GET,OPTIONS,PUT,DELETE")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		setDefaultHeaders(w)
 		w.Header().Set(rfc.Vary, rfc.AcceptEncoding)
-		w.Header().Set("X-Server-Name", ServerName)
-		w.Header().Set(rfc.PermissionsPolicy, "interest-cohort=()")
 		iw := &util.BodyInterceptor{W: w}
 		h(iw, r)
 
@@ -144,6 +164,99 @@ func WrapHeaders(h http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// WrapStreamHeaders is a Middleware which adds the common headers of WrapHeaders to the handler's response, without buffering, hashing, or compressing the body.
+// This is for handlers which write their response incrementally, and must be able to flush it to the client.
+// Like WrapHeaders, it writes the status code set in the request context by api.HandleErr and friends.
+func WrapStreamHeaders(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setDefaultHeaders(w)
+		h(&streamWriter{ResponseWriter: w, r: r}, r)
+	}
+}
+
+// streamWriter is an http.ResponseWriter and http.Flusher which writes the status code in its request's context, if any, before its first write.
+type streamWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	wroteHeader bool
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (s *streamWriter) WriteHeader(code int) {
+	s.wroteHeader = true
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (s *streamWriter) Write(b []byte) (int, error) {
+	if !s.wroteHeader {
+		if status, ok := s.r.Context().Value(tc.StatusKey).(int); ok {
+			s.WriteHeader(status)
+		}
+		s.wroteHeader = true
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher.
+func (s *streamWriter) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// GetWrapMetrics returns a Middleware which records the count and duration of requests to the route with the given ID,
+// by response status code, in metrics.Requests and metrics.RequestDuration.
+func GetWrapMetrics(routeID int) Middleware {
+	route := strconv.Itoa(routeID)
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			mw := &metricsWriter{ResponseWriter: w}
+			defer func() {
+				code := strconv.Itoa(mw.code)
+				if mw.code == 0 {
+					code = strconv.Itoa(http.StatusOK)
+				}
+				metrics.Requests.Inc(route, code)
+				metrics.RequestDuration.ObserveSince(start, route, code)
+			}()
+			h(mw, r)
+		}
+	}
+}
+
+// metricsWriter is an http.ResponseWriter and http.Flusher which records the status code of its response.
+type metricsWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (m *metricsWriter) WriteHeader(code int) {
+	if m.code == 0 {
+		m.code = code
+	}
+	m.ResponseWriter.WriteHeader(code)
+}
+
+// Flush implements http.Flusher.
+func (m *metricsWriter) Flush() {
+	if f, ok := m.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// setDefaultHeaders sets the CORS and identifying headers common to all Traffic Ops responses.
+func setDefaultHeaders(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, Set-Cookie, Cookie")
+	w.Header().Set("Access-Control-Allow-Methods", "POST,GET,OPTIONS,PUT,DELETE")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("X-Server-Name", ServerName)
+	w.Header().Set(rfc.PermissionsPolicy, "interest-cohort=()")
+}
+
 // WrapPanicRecover is a Middleware which adds a panic recover call to the given HandlerFunc h.
 // If h throws an unhandled panic, an error is logged and an Internal Server Error is returned to the client.
 func WrapPanicRecover(h http.HandlerFunc) http.HandlerFunc {
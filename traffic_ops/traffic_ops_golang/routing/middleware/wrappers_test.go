@@ -24,6 +24,7 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
@@ -36,6 +37,7 @@ import (
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/metrics"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
 
 	"github.com/jmoiron/sqlx"
@@ -111,6 +113,51 @@ func TestWrapPanicRecover(t *testing.T) {
 	}
 }
 
+// TestWrapStreamHeaders checks that default headers are added, writes are flushed unbuffered, and error codes are written
+func TestWrapStreamHeaders(t *testing.T) {
+	body := "data: streamed\n\n"
+	f := WrapStreamHeaders(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("expected stream ResponseWriter to be an http.Flusher")
+		}
+		flusher.Flush()
+	})
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("", "/", nil)
+	if err != nil {
+		t.Error("Error creating new request")
+	}
+	f(w, r)
+	if w.Body.String() != body {
+		t.Errorf("expected body '%s', actual '%s'", body, w.Body.String())
+	}
+	if !w.Flushed {
+		t.Error("expected response to be flushed")
+	}
+	if w.Header().Get("X-Server-Name") != ServerName {
+		t.Errorf("expected X-Server-Name header '%s', actual '%s'", ServerName, w.Header().Get("X-Server-Name"))
+	}
+	if w.Header().Get("Whole-Content-Sha512") != "" {
+		t.Error("expected no Whole-Content-Sha512 header on a streamed response")
+	}
+
+	f = WrapStreamHeaders(func(w http.ResponseWriter, r *http.Request) {
+		api.HandleErr(w, r, nil, http.StatusBadRequest, errors.New("bad"), nil)
+	})
+	w = httptest.NewRecorder()
+	r, err = http.NewRequest("", "/", nil)
+	if err != nil {
+		t.Error("Error creating new request")
+	}
+	f(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected error to return a %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
 // TestGzip checks that if Accept-Encoding contains "gzip" that the body is indeed gzip'd
 func TestGzip(t *testing.T) {
 	body := "am I gzip'd?"
@@ -238,4 +285,97 @@ func TestWrapAuth(t *testing.T) {
 	}
 }
 
+func TestWrapAuthToken(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	defer db.Close()
+
+	token := "tops_abc"
+	expectToken := func() {
+		rows := sqlmock.NewRows([]string{"priv_level", "role", "role_name", "id", "username", "tenant_id", "capabilities", "id", "routes", "methods", "expires"})
+		rows.AddRow(30, 1, "admin", 1, "user1", 1, "{}", 7, "{42}", "{GET}", time.Now().Add(time.Hour))
+		mock.ExpectQuery("SELECT").WithArgs(auth.HashAPIToken(token), "disallowed").WillReturnRows(rows)
+		mock.ExpectExec("UPDATE api_token").WithArgs(7, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		user, err := auth.GetCurrentUser(r.Context())
+		if err != nil {
+			t.Errorf("unable to get user: %v", err)
+			return
+		}
+		fmt.Fprintf(w, "%s", user.UserName)
+	}
+
+	tests := []struct {
+		method   string
+		routeID  int
+		expected string
+	}{
+		{http.MethodGet, 42, "user1"},
+		{http.MethodPost, 42, `{"alerts":[{"text":"Forbidden: this token is not permitted to use this route.","level":"error"}]}` + "\n"},
+		{http.MethodGet, 43, `{"alerts":[{"text":"Forbidden: this token is not permitted to use this route.","level":"error"}]}` + "\n"},
+	}
+	for _, test := range tests {
+		expectToken()
+		f := AuthBase{"secret", nil}.GetRouteWrapper(15, test.routeID)(handler)
+
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(test.method, "/", nil)
+		if err != nil {
+			t.Fatalf("creating request: %v", err)
+		}
+		r.Header.Set(rfc.Authorization, "Bearer "+token)
+		r = r.WithContext(context.WithValue(context.Background(), api.DBContextKey, db))
+		r = r.WithContext(context.WithValue(r.Context(), api.ConfigContextKey, &config.Config{ConfigTrafficOpsGolang: config.ConfigTrafficOpsGolang{DBQueryTimeoutSeconds: 20}}))
+
+		f(w, r)
+
+		if w.Body.String() != test.expected {
+			t.Errorf("%s route %d: expected response '%s', actual: '%s'", test.method, test.routeID, test.expected, w.Body.String())
+		}
+		for _, c := range w.Result().Cookies() {
+			if c.Name == tocookie.Name {
+				t.Errorf("%s route %d: expected no login cookie for a request authenticated with a token", test.method, test.routeID)
+			}
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet database expectations: %v", err)
+	}
+}
+
+func TestWrapMetrics(t *testing.T) {
+	routeID := 987654321
+	notFound := GetWrapMetrics(routeID)(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	ok := GetWrapMetrics(routeID)(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+		if _, isFlusher := w.(http.Flusher); !isFlusher {
+			t.Error("expected the metrics middleware's ResponseWriter to be an http.Flusher")
+		}
+	})
+
+	for i := 0; i < 2; i++ {
+		notFound(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	ok(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if actual := metrics.Requests.Get("987654321", "404"); actual != 2 {
+		t.Errorf("expected 2 requests counted with status 404, actual: %v", actual)
+	}
+	if actual := metrics.Requests.Get("987654321", "200"); actual != 1 {
+		t.Errorf("expected 1 request counted with status 200, actual: %v", actual)
+	}
+	if actual := metrics.RequestDuration.Count("987654321", "404"); actual != 2 {
+		t.Errorf("expected 2 request durations observed with status 404, actual: %v", actual)
+	}
+}
+
 // TODO: TestWrapAccessLog
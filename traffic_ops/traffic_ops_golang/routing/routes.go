@@ -25,8 +25,10 @@ import (
 	"fmt"
 	"net/http"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/apache/trafficcontrol/lib/go-log"
 	"github.com/apache/trafficcontrol/lib/go-tc"
@@ -37,6 +39,7 @@ import (
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/apicapability"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/apitenant"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/asn"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/asyncjob"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/cachegroup"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/cachegroupparameter"
@@ -65,6 +68,7 @@ import (
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/iso"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/login"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/logs"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/metrics"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/origin"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/parameter"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/physlocation"
@@ -91,6 +95,7 @@ import (
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/urisigning"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/user"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/vault"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/webhook"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -139,8 +144,16 @@ func Routes(d ServerData) ([]Route, []RawRoute, http.Handler, error) {
 		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `cdn_locks/?$`, cdn_lock.Create, auth.PrivLevelOperations, Authenticated, nil, 4134390562},
 		{api.Version{Major: 4, Minor: 0}, http.MethodDelete, `cdn_locks/?$`, cdn_lock.Delete, auth.PrivLevelOperations, Authenticated, nil, 4134390564},
 
+		// Webhooks
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `webhooks/?$`, webhook.Read, auth.PrivLevelOperations, Authenticated, nil, 4713390521},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `webhooks/?$`, webhook.Create, auth.PrivLevelAdmin, Authenticated, nil, 4713390522},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPut, `webhooks/{id}/?$`, webhook.Update, auth.PrivLevelAdmin, Authenticated, nil, 4713390523},
+		{api.Version{Major: 4, Minor: 0}, http.MethodDelete, `webhooks/{id}/?$`, webhook.Delete, auth.PrivLevelAdmin, Authenticated, nil, 4713390524},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `webhooks/{id}/deliveries/?$`, webhook.GetDeliveries, auth.PrivLevelOperations, Authenticated, nil, 4713390525},
+
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `acme_accounts/providers?$`, acme.ReadProviders, auth.PrivLevelOperations, Authenticated, nil, 4034390565},
 		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `deliveryservices/sslkeys/generate/acme/?$`, deliveryservice.GenerateAcmeCertificates, auth.PrivLevelOperations, Authenticated, nil, 2534390576},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `sslkeys/expirations/?$`, deliveryservice.GetSSLKeyExpirations, auth.PrivLevelOperations, Authenticated, nil, 4534390530},
 
 		// ACME account information
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `acme_accounts/?$`, acme.Read, auth.PrivLevelAdmin, Authenticated, nil, 4034390561},
@@ -151,6 +164,10 @@ func Routes(d ServerData) ([]Route, []RawRoute, http.Handler, error) {
 		//Delivery service ACME
 		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `deliveryservices/xmlId/{xmlid}/sslkeys/renew$`, deliveryservice.RenewAcmeCertificate, auth.PrivLevelOperations, Authenticated, nil, 2534390573},
 		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `acme_autorenew/?$`, deliveryservice.RenewCertificates, auth.PrivLevelOperations, Authenticated, nil, 2534390574},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `async_status/?$`, asyncjob.Read, auth.PrivLevelOperations, Authenticated, nil, 4107752116},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `async_status/{id}/logs/?$`, asyncjob.ReadLogs, auth.PrivLevelOperations, Authenticated, nil, 4107752127},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `async_status/{id}/cancel/?$`, asyncjob.Cancel, auth.PrivLevelOperations, Authenticated, nil, 4107752138},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `async_status/{id}/artifact/?$`, asyncjob.ReadArtifact, auth.PrivLevelOperations, Authenticated, nil, 4107752149},
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `async_status/{id}$`, api.GetAsyncStatus, auth.PrivLevelOperations, Authenticated, nil, 2534390575},
 
 		// API Capability
@@ -195,6 +212,7 @@ func Routes(d ServerData) ([]Route, []RawRoute, http.Handler, error) {
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `cdns/name/{name}/sslkeys/?$`, cdn.GetSSLKeys, auth.PrivLevelAdmin, Authenticated, nil, 42785817723},
 
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `cdns/capacity$`, cdn.GetCapacity, auth.PrivLevelReadOnly, Authenticated, nil, 4971852813},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `cdns/{name}/capacity/forecast/?$`, cdn.GetCapacityForecast, auth.PrivLevelReadOnly, Authenticated, nil, 4971852824},
 
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `cdns/{name}/health/?$`, cdn.GetNameHealth, auth.PrivLevelReadOnly, Authenticated, nil, 41353481943},
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `cdns/health/?$`, cdn.GetHealth, auth.PrivLevelReadOnly, Authenticated, nil, 40853811343},
@@ -204,10 +222,12 @@ func Routes(d ServerData) ([]Route, []RawRoute, http.Handler, error) {
 
 		//CDN: CRUD
 		{api.Version{Major: 4, Minor: 0}, http.MethodDelete, `cdns/name/{name}$`, cdn.DeleteName, auth.PrivLevelOperations, Authenticated, nil, 4088049593},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `cdns/{name}/export/?$`, cdn.ExportHandler, auth.PrivLevelReadOnly, Authenticated, nil, 4482905121},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `cdns/import/?$`, cdn.ImportHandler, auth.PrivLevelOperations, Authenticated, nil, 4482905122},
 
 		//CDN: queue updates
-		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `cdns/{id}/queue_update$`, cdn.Queue, auth.PrivLevelOperations, Authenticated, nil, 4215159803},
-		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `cdns/dnsseckeys/generate?$`, cdn.CreateDNSSECKeys, auth.PrivLevelAdmin, Authenticated, nil, 4753363},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `cdns/{id}/queue_update$`, cdn.QueueV40, auth.PrivLevelOperations, Authenticated, nil, 4215159803},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `cdns/dnsseckeys/generate?$`, cdn.CreateDNSSECKeysV40, auth.PrivLevelAdmin, Authenticated, nil, 4753363},
 		{api.Version{Major: 4, Minor: 0}, http.MethodDelete, `cdns/name/{name}/dnsseckeys?$`, cdn.DeleteDNSSECKeys, auth.PrivLevelAdmin, Authenticated, nil, 4711042073},
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `cdns/name/{name}/dnsseckeys/?$`, cdn.GetDNSSECKeys, auth.PrivLevelAdmin, Authenticated, nil, 4790106093},
 
@@ -227,8 +247,15 @@ func Routes(d ServerData) ([]Route, []RawRoute, http.Handler, error) {
 
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `logs/?$`, logs.Get, auth.PrivLevelReadOnly, Authenticated, nil, 4483405503},
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `logs/newcount/?$`, logs.GetNewCount, auth.PrivLevelReadOnly, Authenticated, nil, 44058330123},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `logs/stream/?$`, logs.Stream, auth.PrivLevelReadOnly, Authenticated, middleware.GetDefaultStreaming(d.Secrets[0]), 4483405513},
 
 		//Content invalidation jobs
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `jobs/schedules/?$`, invalidationjobs.ReadSchedules, auth.PrivLevelReadOnly, Authenticated, nil, 4371958221},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `jobs/schedules/?$`, invalidationjobs.CreateSchedule, auth.PrivLevelPortal, Authenticated, nil, 4371958222},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPut, `jobs/schedules/{id}/?$`, invalidationjobs.UpdateSchedule, auth.PrivLevelPortal, Authenticated, nil, 4371958223},
+		{api.Version{Major: 4, Minor: 0}, http.MethodDelete, `jobs/schedules/{id}/?$`, invalidationjobs.DeleteSchedule, auth.PrivLevelPortal, Authenticated, nil, 4371958224},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `jobs/acknowledgements/?$`, invalidationjobs.Acknowledge, auth.PrivLevelOperations, Authenticated, nil, 4371958225},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `jobs/{id}/progress/?$`, invalidationjobs.GetProgress, auth.PrivLevelReadOnly, Authenticated, nil, 4371958226},
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `jobs/?$`, api.ReadHandler(&invalidationjobs.InvalidationJob{}), auth.PrivLevelReadOnly, Authenticated, nil, 49667820413},
 		{api.Version{Major: 4, Minor: 0}, http.MethodDelete, `jobs/?$`, invalidationjobs.Delete, auth.PrivLevelPortal, Authenticated, nil, 4167807763},
 		{api.Version{Major: 4, Minor: 0}, http.MethodPut, `jobs/?$`, invalidationjobs.Update, auth.PrivLevelPortal, Authenticated, nil, 4861342263},
@@ -239,12 +266,16 @@ func Routes(d ServerData) ([]Route, []RawRoute, http.Handler, error) {
 		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `user/logout/?$`, login.LogoutHandler(d.Config.Secrets[0]), 0, Authenticated, nil, 4434348253},
 		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `user/login/oauth/?$`, login.OauthLoginHandler(d.DB, d.Config), 0, NoAuth, nil, 44158860093},
 		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `user/login/token/?$`, login.TokenLoginHandler(d.DB, d.Config), 0, NoAuth, nil, 4024088413},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `user/login/oidc/?$`, login.OIDCLoginHandler(d.DB, d.Config), 0, NoAuth, nil, 44158860094},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `user/login/oidc/callback/?$`, login.OIDCCallbackHandler(d.DB, d.Config), 0, NoAuth, nil, 44158860095},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `user/login/oidc/?$`, login.OIDCTokenLoginHandler(d.DB, d.Config), 0, NoAuth, nil, 44158860096},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `user/login/oidc/link/?$`, login.OIDCLinkHandler(d.Config), 0, Authenticated, nil, 44158860097},
 		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `user/reset_password/?$`, login.ResetPassword(d.DB, d.Config), 0, NoAuth, nil, 42929146303},
 		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `users/register/?$`, login.RegisterUser, auth.PrivLevelOperations, Authenticated, nil, 43373},
 
 		//ISO
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `osversions/?$`, iso.GetOSVersions, auth.PrivLevelReadOnly, Authenticated, nil, 4760886573},
-		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `isos/?$`, iso.ISOs, auth.PrivLevelOperations, Authenticated, nil, 4760336573},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `isos/?$`, iso.ISOsV40, auth.PrivLevelOperations, Authenticated, nil, 4760336573},
 
 		//User: CRUD
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `users/?$`, api.ReadHandler(&user.TOUser{}), auth.PrivLevelReadOnly, Authenticated, nil, 44919299003},
@@ -254,6 +285,9 @@ func Routes(d ServerData) ([]Route, []RawRoute, http.Handler, error) {
 
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `user/current/?$`, user.Current, auth.PrivLevelReadOnly, Authenticated, nil, 46107016143},
 		{api.Version{Major: 4, Minor: 0}, http.MethodPut, `user/current/?$`, user.ReplaceCurrent, auth.PrivLevelReadOnly, Authenticated, nil, 4203},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `user/current/tokens/?$`, user.GetTokens, auth.PrivLevelReadOnly, Authenticated, nil, 4621784321},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `user/current/tokens/?$`, user.CreateToken, auth.PrivLevelReadOnly, Authenticated, nil, 4621784322},
+		{api.Version{Major: 4, Minor: 0}, http.MethodDelete, `user/current/tokens/{id}/?$`, user.DeleteToken, auth.PrivLevelReadOnly, Authenticated, nil, 4621784323},
 
 		//Parameter: CRUD
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `parameters/?$`, api.ReadHandler(&parameter.TOParameter{}), auth.PrivLevelReadOnly, Authenticated, nil, 42125542923},
@@ -270,6 +304,9 @@ func Routes(d ServerData) ([]Route, []RawRoute, http.Handler, error) {
 		//Ping
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `ping$`, ping.Handler, 0, NoAuth, nil, 45556615973},
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `vault/ping/?$`, ping.Vault, auth.PrivLevelReadOnly, Authenticated, nil, 48840121143},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `vault/keys/?$`, vault.GetEncryptionKeys, auth.PrivLevelAdmin, Authenticated, nil, 48840121144},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `vault/keys/reencrypt/?$`, vault.ReencryptSecrets, auth.PrivLevelAdmin, Authenticated, nil, 48840121145},
+		{api.Version{Major: 4, Minor: 0}, http.MethodDelete, `vault/keys/{id}/?$`, vault.RetireEncryptionKey, auth.PrivLevelAdmin, Authenticated, nil, 48840121146},
 
 		//Profile: CRUD
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `profiles/?$`, api.ReadHandler(&profile.TOProfile{}), auth.PrivLevelReadOnly, Authenticated, nil, 4687585893},
@@ -296,6 +333,7 @@ func Routes(d ServerData) ([]Route, []RawRoute, http.Handler, error) {
 		{api.Version{Major: 4, Minor: 0}, http.MethodDelete, `topologies/?$`, api.DeleteHandler(&topology.TOTopology{}), auth.PrivLevelOperations, Authenticated, nil, 4871452224},
 
 		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `topologies/{name}/queue_update$`, topology.QueueUpdateHandler, auth.PrivLevelOperations, Authenticated, nil, 4205351748},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `topologies/{name}/simulate/?$`, topology.SimulateHandler, auth.PrivLevelReadOnly, Authenticated, nil, 4871452225},
 
 		// get all edge servers associated with a delivery service (from deliveryservice_server table)
 
@@ -322,6 +360,15 @@ func Routes(d ServerData) ([]Route, []RawRoute, http.Handler, error) {
 
 		//Server status
 		{api.Version{Major: 4, Minor: 0}, http.MethodPut, `servers/{id}/status$`, server.UpdateStatusHandler, auth.PrivLevelOperations, Authenticated, nil, 4766638513},
+
+		//Server lifecycle
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `server_status_transitions/?$`, server.GetStatusTransitions, auth.PrivLevelReadOnly, Authenticated, nil, 4766638520},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `server_status_transitions/?$`, server.CreateStatusTransition, auth.PrivLevelAdmin, Authenticated, nil, 4766638521},
+		{api.Version{Major: 4, Minor: 0}, http.MethodDelete, `server_status_transitions/{id}$`, server.DeleteStatusTransition, auth.PrivLevelAdmin, Authenticated, nil, 4766638522},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `server_maintenance_windows/?$`, server.GetMaintenanceWindows, auth.PrivLevelReadOnly, Authenticated, nil, 4766638523},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `server_maintenance_windows/?$`, server.CreateMaintenanceWindow, auth.PrivLevelOperations, Authenticated, nil, 4766638524},
+		{api.Version{Major: 4, Minor: 0}, http.MethodDelete, `server_maintenance_windows/{id}$`, server.DeleteMaintenanceWindow, auth.PrivLevelOperations, Authenticated, nil, 4766638525},
+
 		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `servers/{id}/queue_update$`, server.QueueUpdateHandler, auth.PrivLevelOperations, Authenticated, nil, 41894713},
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `servers/{host_name}/update_status$`, server.GetServerUpdateStatusHandler, auth.PrivLevelReadOnly, Authenticated, nil, 4384515993},
 		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `servers/{id-or-name}/update$`, server.UpdateHandler, auth.PrivLevelOperations, Authenticated, nil, 443813233},
@@ -389,6 +436,12 @@ func Routes(d ServerData) ([]Route, []RawRoute, http.Handler, error) {
 		{api.Version{Major: 4, Minor: 0}, http.MethodPut, `deliveryservice_requests/{id}/assign$`, dsrequest.PutAssignment, auth.PrivLevelOperations, Authenticated, nil, 47031602903},
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `deliveryservice_requests/{id}/status$`, dsrequest.GetStatus, auth.PrivLevelPortal, Authenticated, nil, 4684150994},
 		{api.Version{Major: 4, Minor: 0}, http.MethodPut, `deliveryservice_requests/{id}/status$`, dsrequest.PutStatus, auth.PrivLevelPortal, Authenticated, nil, 4684150993},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `deliveryservice_requests/{id}/approvals/?$`, dsrequest.GetApprovals, auth.PrivLevelPortal, Authenticated, nil, 4684150995},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `deliveryservice_requests/{id}/approvals/?$`, dsrequest.PostApproval, auth.PrivLevelOperations, Authenticated, nil, 4684150996},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `deliveryservice_request_approval_policies/?$`, dsrequest.GetApprovalPolicies, auth.PrivLevelReadOnly, Authenticated, nil, 4420770201},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `deliveryservice_request_approval_policies/?$`, dsrequest.CreateApprovalPolicy, auth.PrivLevelAdmin, Authenticated, nil, 4420770202},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPut, `deliveryservice_request_approval_policies/{tenantId}$`, dsrequest.UpdateApprovalPolicy, auth.PrivLevelAdmin, Authenticated, nil, 4420770203},
+		{api.Version{Major: 4, Minor: 0}, http.MethodDelete, `deliveryservice_request_approval_policies/{tenantId}$`, dsrequest.DeleteApprovalPolicy, auth.PrivLevelAdmin, Authenticated, nil, 4420770204},
 
 		//Delivery service request comment: CRUD
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `deliveryservice_request_comments/?$`, api.ReadHandler(&comment.TODeliveryServiceRequestComment{}), auth.PrivLevelReadOnly, Authenticated, nil, 40326507373},
@@ -466,7 +519,10 @@ func Routes(d ServerData) ([]Route, []RawRoute, http.Handler, error) {
 		//CRConfig
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `cdns/{cdn}/snapshot/?$`, crconfig.SnapshotGetHandler, auth.PrivLevelReadOnly, Authenticated, nil, 49572736953},
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `cdns/{cdn}/snapshot/new/?$`, crconfig.Handler, auth.PrivLevelReadOnly, Authenticated, nil, 4767168893},
-		{api.Version{Major: 4, Minor: 0}, http.MethodPut, `snapshot/?$`, crconfig.SnapshotHandler, auth.PrivLevelOperations, Authenticated, nil, 49699118293},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `cdns/{cdn}/snapshot/diff/?$`, crconfig.DiffHandler, auth.PrivLevelReadOnly, Authenticated, nil, 4767168894},
+		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `cdns/{cdn}/snapshots/?$`, crconfig.HistoryHandler, auth.PrivLevelReadOnly, Authenticated, nil, 4767168895},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `cdns/{cdn}/snapshots/{id}/restore/?$`, crconfig.RestoreHandler, auth.PrivLevelOperations, Authenticated, nil, 4767168896},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPut, `snapshot/?$`, crconfig.SnapshotHandlerV40, auth.PrivLevelOperations, Authenticated, nil, 49699118293},
 
 		// Federations
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `federations/all/?$`, federations.GetAll, auth.PrivLevelAdmin, Authenticated, nil, 410599863},
@@ -493,6 +549,7 @@ func Routes(d ServerData) ([]Route, []RawRoute, http.Handler, error) {
 		////DeliveryServices
 		{api.Version{Major: 4, Minor: 0}, http.MethodGet, `deliveryservices/?$`, api.ReadHandler(&deliveryservice.TODeliveryService{}), auth.PrivLevelReadOnly, Authenticated, nil, 42383172943},
 		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `deliveryservices/?$`, deliveryservice.CreateV40, auth.PrivLevelOperations, Authenticated, nil, 4064315323},
+		{api.Version{Major: 4, Minor: 0}, http.MethodPost, `deliveryservices/apply/?$`, deliveryservice.ApplyHandler, auth.PrivLevelOperations, Authenticated, nil, 4064315324},
 		{api.Version{Major: 4, Minor: 0}, http.MethodPut, `deliveryservices/{id}/?$`, deliveryservice.UpdateV40, auth.PrivLevelOperations, Authenticated, nil, 47665675673},
 		{api.Version{Major: 4, Minor: 0}, http.MethodPut, `deliveryservices/{id}/safe/?$`, deliveryservice.UpdateSafe, auth.PrivLevelOperations, Authenticated, nil, 4472109313},
 		{api.Version{Major: 4, Minor: 0}, http.MethodDelete, `deliveryservices/{id}/?$`, api.DeleteHandler(&deliveryservice.TODeliveryService{}), auth.PrivLevelOperations, Authenticated, nil, 4226420743},
@@ -1781,12 +1838,32 @@ func Routes(d ServerData) ([]Route, []RawRoute, http.Handler, error) {
 		}
 	}
 
+	if d.RateLimit != nil {
+		unknownRateLimitIDs := []string{}
+		for routeID := range d.RateLimit.Routes {
+			if _, known := knownRouteIDs[routeID]; !known {
+				unknownRateLimitIDs = append(unknownRateLimitIDs, fmt.Sprintf("%d", routeID))
+			}
+		}
+		if len(unknownRateLimitIDs) > 0 {
+			sort.Strings(unknownRateLimitIDs)
+			msg := "unknown route IDs in rate_limit: " + strings.Join(unknownRateLimitIDs, ", ")
+			if d.IgnoreUnknownRoutes {
+				log.Warnln(msg)
+			} else {
+				return nil, nil, nil, errors.New(msg)
+			}
+		}
+	}
+
 	// rawRoutes are served at the root path. These should be almost exclusively old Perl pre-API routes, which have yet to be converted in all clients. New routes should be in the versioned API path.
 	rawRoutes := []RawRoute{
 		// DEPRECATED - use PUT /api/1.2/snapshot/{cdn}
-		{http.MethodGet, `tools/write_crconfig/{cdn}/?$`, crconfig.SnapshotOldGUIHandler, auth.PrivLevelOperations, Authenticated, nil},
+		{http.MethodGet, `tools/write_crconfig/{cdn}/?$`, crconfig.SnapshotOldGUIHandler, auth.PrivLevelOperations, Authenticated, nil, 94720183651},
 		// DEPRECATED - use GET /api/1.2/cdns/{cdn}/snapshot
-		{http.MethodGet, `CRConfig-Snapshots/{cdn}/CRConfig.json?$`, crconfig.SnapshotOldGetHandler, auth.PrivLevelReadOnly, Authenticated, nil},
+		{http.MethodGet, `CRConfig-Snapshots/{cdn}/CRConfig.json?$`, crconfig.SnapshotOldGetHandler, auth.PrivLevelReadOnly, Authenticated, nil, 94720183652},
+		// Operational metrics in the OpenMetrics format, for scraping by Prometheus
+		{http.MethodGet, `^metrics/?$`, metrics.Handler(d.DB, time.Duration(d.Config.DBQueryTimeoutSeconds)*time.Second), auth.PrivLevelReadOnly, Authenticated, nil, 94720183653},
 	}
 
 	return routes, rawRoutes, proxyHandler, nil
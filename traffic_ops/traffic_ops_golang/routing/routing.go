@@ -72,6 +72,7 @@ type RawRoute struct {
 	RequiredPrivLevel int
 	Authenticated     bool
 	Middlewares       []middleware.Middleware
+	ID                int // unique identifier - used for metrics and the scope of personal access tokens
 }
 
 // ServerData ...
@@ -148,8 +149,9 @@ type PathHandler struct {
 }
 
 // CreateRouteMap returns a map of methods to a slice of paths and handlers; wrapping the handlers in the appropriate middleware. Uses Semantic Versioning: routes are added to every subsequent minor version, but not subsequent major versions. For example, a 1.2 route is added to 1.3 but not 2.1. Also truncates '2.0' to '2', creating succinct major versions.
+// If rateLimiter is not nil, its limits are enforced on authenticated routes.
 // Returns the map of routes, and a map of API versions served.
-func CreateRouteMap(rs []Route, rawRoutes []RawRoute, disabledRouteIDs []int, perlHandler http.HandlerFunc, authBase middleware.AuthBase, reqTimeOutSeconds int) (map[string][]PathHandler, map[api.Version]struct{}) {
+func CreateRouteMap(rs []Route, rawRoutes []RawRoute, disabledRouteIDs []int, perlHandler http.HandlerFunc, authBase middleware.AuthBase, reqTimeOutSeconds int, rateLimiter *middleware.RateLimiter) (map[string][]PathHandler, map[api.Version]struct{}) {
 	// TODO strong types for method, path
 	versions := getSortedRouteVersions(rs)
 	requestTimeout := middleware.DefaultRequestTimeout
@@ -168,7 +170,7 @@ func CreateRouteMap(rs []Route, rawRoutes []RawRoute, disabledRouteIDs []int, pe
 			}
 			vstr := strconv.FormatUint(version.Major, 10) + "." + strconv.FormatUint(version.Minor, 10)
 			path := RoutePrefix + "/" + vstr + "/" + r.Path
-			middlewares := getRouteMiddleware(r.Middlewares, authBase, r.Authenticated, r.RequiredPrivLevel, requestTimeout)
+			middlewares := getRouteMiddleware(r.Middlewares, authBase, r.Authenticated, r.RequiredPrivLevel, requestTimeout, rateLimiter, r.ID)
 
 			if isDisabledRoute {
 				m[r.Method] = append(m[r.Method], PathHandler{Path: path, Handler: middleware.WrapAccessLog(authBase.Secret, middleware.DisabledRouteHandler()), ID: r.ID})
@@ -179,8 +181,8 @@ func CreateRouteMap(rs []Route, rawRoutes []RawRoute, disabledRouteIDs []int, pe
 		}
 	}
 	for _, r := range rawRoutes {
-		middlewares := getRouteMiddleware(r.Middlewares, authBase, r.Authenticated, r.RequiredPrivLevel, requestTimeout)
-		m[r.Method] = append(m[r.Method], PathHandler{Path: r.Path, Handler: middleware.Use(r.Handler, middlewares)})
+		middlewares := getRouteMiddleware(r.Middlewares, authBase, r.Authenticated, r.RequiredPrivLevel, requestTimeout, nil, r.ID)
+		m[r.Method] = append(m[r.Method], PathHandler{Path: r.Path, Handler: middleware.Use(r.Handler, middlewares), ID: r.ID})
 		log.Infof("adding raw route %v %v\n", r.Method, r.Path)
 	}
 
@@ -191,13 +193,17 @@ func CreateRouteMap(rs []Route, rawRoutes []RawRoute, disabledRouteIDs []int, pe
 	return m, versionSet
 }
 
-func getRouteMiddleware(middlewares []middleware.Middleware, authBase middleware.AuthBase, authenticated bool, privLevel int, requestTimeout time.Duration) []middleware.Middleware {
+func getRouteMiddleware(middlewares []middleware.Middleware, authBase middleware.AuthBase, authenticated bool, privLevel int, requestTimeout time.Duration, rateLimiter *middleware.RateLimiter, routeID int) []middleware.Middleware {
 	if middlewares == nil {
 		middlewares = middleware.GetDefault(authBase.Secret, requestTimeout)
 	}
+	middlewares = append([]middleware.Middleware{middleware.GetWrapMetrics(routeID)}, middlewares...)
 	if authenticated { // a privLevel of zero is an unauthenticated endpoint.
-		authWrapper := authBase.GetWrapper(privLevel)
+		authWrapper := authBase.GetRouteWrapper(privLevel, routeID)
 		middlewares = append(middlewares, authWrapper)
+		if rateLimiter != nil { // limits are per-user, so they must come after authentication.
+			middlewares = append(middlewares, rateLimiter.Wrapper(routeID))
+		}
 	}
 	return middlewares
 }
@@ -343,7 +349,11 @@ func RegisterRoutes(d ServerData) error {
 	}
 
 	authBase := middleware.AuthBase{Secret: d.Config.Secrets[0], Override: nil} //we know d.Config.Secrets is a slice of at least one or start up would fail.
-	routes, versions := CreateRouteMap(routeSlice, rawRoutes, d.DisabledRoutes, handlerToFunc(catchall), authBase, d.RequestTimeout)
+	var rateLimiter *middleware.RateLimiter
+	if d.RateLimit != nil {
+		rateLimiter = middleware.NewRateLimiter(*d.RateLimit)
+	}
+	routes, versions := CreateRouteMap(routeSlice, rawRoutes, d.DisabledRoutes, handlerToFunc(catchall), authBase, d.RequestTimeout, rateLimiter)
 
 	compiledRoutes := CompileRoutes(routes)
 	getReqID := nextReqIDGetter()
@@ -150,7 +150,7 @@ func TestCompileRoutes(t *testing.T) {
 	}
 
 	authBase := middleware.AuthBase{Secret: d.Secrets[0], Override: nil}
-	routes, versions := CreateRouteMap(routeSlice, nil, nil, nil, authBase, 1)
+	routes, versions := CreateRouteMap(routeSlice, nil, nil, nil, authBase, 1, nil)
 	if len(routes) == 0 {
 		t.Error("no routes handler defined")
 	}
@@ -251,7 +251,7 @@ func TestCreateRouteMap(t *testing.T) {
 	disabledRoutesIDs := []int{4}
 
 	rawRoutes := []RawRoute{}
-	routeMap, _ := CreateRouteMap(routes, rawRoutes, disabledRoutesIDs, CatchallHandler, authBase, 60)
+	routeMap, _ := CreateRouteMap(routes, rawRoutes, disabledRoutesIDs, CatchallHandler, authBase, 60, nil)
 
 	route1Handler := routeMap["GET"][0].Handler
 
@@ -0,0 +1,506 @@
+package server
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/webhook"
+)
+
+// MaintenanceSchedulerInterval is how often the scheduler looks for server
+// maintenance windows which have started or ended.
+const MaintenanceSchedulerInterval = time.Minute
+
+const readMaintenanceWindowsQuery = `
+SELECT w.id,
+       w.server,
+       s.host_name,
+       w.start_time,
+       w.end_time,
+       w.reason,
+       w.state,
+       ps.name AS previous_status,
+       u.username,
+       w.last_updated
+FROM server_maintenance_window w
+JOIN server s ON s.id = w.server
+JOIN tm_user u ON u.id = w.window_user
+LEFT JOIN status ps ON ps.id = w.previous_status
+`
+
+const insertMaintenanceWindowQuery = `
+INSERT INTO server_maintenance_window (server, start_time, end_time, reason, window_user)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id
+`
+
+const overlappingMaintenanceWindowQuery = `
+SELECT EXISTS(
+	SELECT 1
+	FROM server_maintenance_window
+	WHERE server = $1
+	AND state <> '` + tc.MaintenanceWindowComplete + `'
+	AND start_time < $3
+	AND end_time > $2
+)
+`
+
+const dueMaintenanceWindowsQuery = `
+SELECT id
+FROM server_maintenance_window
+WHERE (state = '` + tc.MaintenanceWindowScheduled + `' AND start_time <= $1)
+OR (state <> '` + tc.MaintenanceWindowComplete + `' AND end_time <= $1)
+ORDER BY start_time
+`
+
+const claimMaintenanceWindowQuery = `
+SELECT w.server, w.start_time, w.end_time, w.reason, w.state, w.previous_status, u.id, u.username
+FROM server_maintenance_window w
+JOIN tm_user u ON u.id = w.window_user
+WHERE w.id = $1 AND w.state <> '` + tc.MaintenanceWindowComplete + `'
+FOR UPDATE OF w SKIP LOCKED
+`
+
+const lockMaintenanceWindowQuery = `
+SELECT server, state, previous_status
+FROM server_maintenance_window
+WHERE id = $1
+FOR UPDATE
+`
+
+const setMaintenanceWindowStateQuery = `
+UPDATE server_maintenance_window
+SET state = $1, previous_status = $2
+WHERE id = $3
+`
+
+const deleteMaintenanceWindowQuery = `DELETE FROM server_maintenance_window WHERE id = $1`
+
+const serverStatusIDQuery = `SELECT status FROM server WHERE id = $1`
+
+// scanMaintenanceWindow scans a row selected by readMaintenanceWindowsQuery.
+func scanMaintenanceWindow(row interface{ Scan(...interface{}) error }) (tc.ServerMaintenanceWindow, error) {
+	m := tc.ServerMaintenanceWindow{}
+	err := row.Scan(&m.ID, &m.ServerID, &m.HostName, &m.Start, &m.End, &m.Reason, &m.State, &m.PreviousStatus, &m.CreatedBy, &m.LastUpdated)
+	return m, err
+}
+
+// getMaintenanceWindow returns the maintenance window with the given ID.
+func getMaintenanceWindow(tx *sql.Tx, id int) (tc.ServerMaintenanceWindow, error) {
+	return scanMaintenanceWindow(tx.QueryRow(readMaintenanceWindowsQuery+" WHERE w.id = $1", id))
+}
+
+// GetMaintenanceWindows is the handler for GET requests to
+// /server_maintenance_windows.
+func GetMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	cols := map[string]dbhelpers.WhereColumnInfo{
+		"id":       {Column: "w.id", Checker: api.IsInt},
+		"serverId": {Column: "w.server", Checker: api.IsInt},
+		"hostName": {Column: "s.host_name", Checker: nil},
+		"state":    {Column: "w.state", Checker: nil},
+		"start":    {Column: "w.start_time", Checker: nil},
+	}
+	if _, ok := inf.Params["orderby"]; !ok {
+		inf.Params["orderby"] = "start"
+	}
+	where, orderBy, pagination, queryValues, errs := dbhelpers.BuildWhereAndOrderByAndPagination(inf.Params, cols)
+	if len(errs) > 0 {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, util.JoinErrs(errs), nil)
+		return
+	}
+
+	rows, err := inf.Tx.NamedQuery(readMaintenanceWindowsQuery+where+orderBy+pagination, queryValues)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("querying server maintenance windows: "+err.Error()))
+		return
+	}
+	defer rows.Close()
+
+	windows := []tc.ServerMaintenanceWindow{}
+	for rows.Next() {
+		m, err := scanMaintenanceWindow(rows)
+		if err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("scanning server maintenance windows: "+err.Error()))
+			return
+		}
+		windows = append(windows, m)
+	}
+	api.WriteResp(w, r, windows)
+}
+
+// CreateMaintenanceWindow is the handler for POST requests to
+// /server_maintenance_windows. A window which has already started is started
+// immediately, rather than by the scheduler.
+func CreateMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	m := tc.ServerMaintenanceWindow{}
+	if err := api.Parse(r.Body, tx, &m); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	serverInfo, exists, err := dbhelpers.GetServerInfo(*m.ServerID, tx)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	if !exists {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, fmt.Errorf("no such server: #%d", *m.ServerID), nil)
+		return
+	}
+
+	overlaps := false
+	if err := tx.QueryRow(overlappingMaintenanceWindowQuery, *m.ServerID, *m.Start, *m.End).Scan(&overlaps); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("checking for overlapping maintenance windows: "+err.Error()))
+		return
+	}
+	if overlaps {
+		api.HandleErr(w, r, tx, http.StatusConflict, fmt.Errorf("server '%s' already has a maintenance window overlapping %s to %s", serverInfo.HostName, m.Start.Format(time.RFC3339), m.End.Format(time.RFC3339)), nil)
+		return
+	}
+
+	if !isDownStatus(serverInfo.Status) {
+		currentStatusID, adminDownID, err := getMaintenanceStatusIDs(tx, *m.ServerID)
+		if err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+			return
+		}
+		if userErr, sysErr, errCode := checkStatusTransition(tx, currentStatusID, adminDownID); userErr != nil || sysErr != nil {
+			api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+			return
+		}
+	}
+
+	id := 0
+	if err := tx.QueryRow(insertMaintenanceWindowQuery, *m.ServerID, *m.Start, *m.End, *m.Reason, inf.User.ID).Scan(&id); err != nil {
+		userErr, sysErr, errCode := api.ParseDBError(err)
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	if !m.Start.After(time.Now()) {
+		if err := startMaintenanceWindow(tx, id, *m.ServerID, *m.Reason, inf.User); err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("starting maintenance window #%d: %v", id, err))
+			return
+		}
+	}
+
+	if m, err = getMaintenanceWindow(tx, id); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("getting created maintenance window #%d: %v", id, err))
+		return
+	}
+
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "server maintenance window was created.", m)
+	changeLogMsg := fmt.Sprintf("SERVER MAINTENANCE WINDOW: %d, SERVER: %s, ACTION: %s maintenance window from %s to %s: %s", id, serverInfo.HostName, api.Created, m.Start.Format(time.RFC3339), m.End.Format(time.RFC3339), *m.Reason)
+	api.CreateChangeLogRawTx(api.ApiChange, changeLogMsg, inf.User, tx)
+}
+
+// DeleteMaintenanceWindow is the handler for DELETE requests to
+// /server_maintenance_windows/{{ID}}. Deleting an active window ends it
+// first, restoring the server's previous Status.
+func DeleteMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+	id := inf.IntParams["id"]
+
+	serverID := 0
+	state := ""
+	var previousStatusID *int
+	if err := tx.QueryRow(lockMaintenanceWindowQuery, id).Scan(&serverID, &state, &previousStatusID); err == sql.ErrNoRows {
+		api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no server maintenance window exists by id %d", id), nil)
+		return
+	} else if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("getting server maintenance window #%d: %v", id, err))
+		return
+	}
+
+	msg := "server maintenance window was deleted."
+	if state == tc.MaintenanceWindowActive {
+		if err := endMaintenanceWindow(tx, id, serverID, previousStatusID, inf.User); err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("ending maintenance window #%d: %v", id, err))
+			return
+		}
+		msg = "server maintenance window was ended and deleted."
+	}
+
+	if _, err := tx.Exec(deleteMaintenanceWindowQuery, id); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("deleting server maintenance window #%d: %v", id, err))
+		return
+	}
+
+	api.WriteRespAlert(w, r, tc.SuccessLevel, msg)
+	changeLogMsg := fmt.Sprintf("SERVER MAINTENANCE WINDOW: %d, ACTION: %s maintenance window", id, api.Deleted)
+	api.CreateChangeLogRawTx(api.ApiChange, changeLogMsg, inf.User, tx)
+}
+
+// isDownStatus returns whether servers with the named Status are already out
+// of service, and so need not be set ADMIN_DOWN for maintenance.
+func isDownStatus(status string) bool {
+	return status == tc.CacheStatusAdminDown.String() || status == tc.CacheStatusOffline.String()
+}
+
+// getMaintenanceStatusIDs returns the ID of the Status of the server with the
+// given ID, and the ID of the ADMIN_DOWN Status.
+func getMaintenanceStatusIDs(tx *sql.Tx, serverID int) (int, int, error) {
+	currentStatusID := 0
+	if err := tx.QueryRow(serverStatusIDQuery, serverID).Scan(&currentStatusID); err != nil {
+		return 0, 0, fmt.Errorf("getting server #%d status: %v", serverID, err)
+	}
+	adminDown, exists, err := dbhelpers.GetStatusByName(tc.CacheStatusAdminDown.String(), tx)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !exists || adminDown.ID == nil {
+		return 0, 0, errors.New("no " + tc.CacheStatusAdminDown.String() + " status exists")
+	}
+	return currentStatusID, *adminDown.ID, nil
+}
+
+// setMaintenanceStatus moves the server with the given ID from the Status
+// with ID fromStatusID to the Status with ID toStatusID on behalf of the
+// maintenance window with ID windowID, queueing updates on its child caches
+// and notifying webhooks as a change made through the API would.
+func setMaintenanceStatus(tx *sql.Tx, windowID int, serverInfo tc.ServerInfo, fromStatusID, toStatusID int, offlineReason *string, user *auth.CurrentUser) error {
+	toStatus, exists, err := dbhelpers.GetStatusByID(toStatusID, tx)
+	if err != nil {
+		return err
+	}
+	if !exists || toStatus.Name == nil {
+		return fmt.Errorf("no status exists by id %d", toStatusID)
+	}
+	if err := updateServerStatusAndOfflineReason(fromStatusID, toStatusID, serverInfo.ID, time.Time{}, offlineReason, tx); err != nil {
+		return err
+	}
+	reason := ""
+	if offlineReason != nil {
+		reason = *offlineReason
+	}
+	msg := fmt.Sprintf("Updated status [ %s ] for %s.%s [ %s ] by maintenance window #%d", *toStatus.Name, serverInfo.HostName, serverInfo.DomainName, reason, windowID)
+	if strings.HasPrefix(serverInfo.Type, tc.CacheTypeEdge.String()) || strings.HasPrefix(serverInfo.Type, tc.CacheTypeMid.String()) {
+		if err := queueUpdatesOnChildCaches(tx, serverInfo.CDNID, serverInfo.CachegroupID); err != nil {
+			return err
+		}
+		msg += " and queued updates on all child caches"
+	}
+	api.CreateChangeLogRawTx(api.ApiChange, msg, user, tx)
+	webhook.Enqueue(tx, tc.WebhookEventServerStatus, user, tc.WebhookServerStatusData{
+		ID:            serverInfo.ID,
+		HostName:      serverInfo.HostName,
+		Status:        *toStatus.Name,
+		OfflineReason: offlineReason,
+	})
+	return nil
+}
+
+// startMaintenanceWindow sets the server of the maintenance window with the
+// given ID ADMIN_DOWN, and marks the window active. Servers which are
+// already ADMIN_DOWN or OFFLINE, or which may not be moved to ADMIN_DOWN from
+// their current Status, are left alone.
+func startMaintenanceWindow(tx *sql.Tx, id, serverID int, reason string, user *auth.CurrentUser) error {
+	serverInfo, exists, err := dbhelpers.GetServerInfo(serverID, tx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("server #%d not found", serverID)
+	}
+
+	var previousStatusID *int
+	if !isDownStatus(serverInfo.Status) {
+		currentStatusID, adminDownID, err := getMaintenanceStatusIDs(tx, serverID)
+		if err != nil {
+			return err
+		}
+		userErr, sysErr, _ := checkStatusTransition(tx, currentStatusID, adminDownID)
+		if sysErr != nil {
+			return sysErr
+		}
+		if userErr != nil {
+			log.Warnf("maintenance window #%d: leaving server %s %s: %v", id, serverInfo.HostName, serverInfo.Status, userErr)
+		} else {
+			offlineReason := fmt.Sprintf("%s: maintenance window #%d: %s", user.UserName, id, reason)
+			if err := setMaintenanceStatus(tx, id, serverInfo, currentStatusID, adminDownID, &offlineReason, user); err != nil {
+				return err
+			}
+			previousStatusID = &currentStatusID
+		}
+	}
+
+	if _, err := tx.Exec(setMaintenanceWindowStateQuery, tc.MaintenanceWindowActive, previousStatusID, id); err != nil {
+		return errors.New("marking maintenance window active: " + err.Error())
+	}
+	return nil
+}
+
+// endMaintenanceWindow restores the server of the maintenance window with the
+// given ID to the Status it had when the window started, and marks the window
+// complete. Servers whose Status has been changed from ADMIN_DOWN during the
+// window are left alone. Restoring a server's Status is always allowed, since
+// it undoes the window's own change.
+func endMaintenanceWindow(tx *sql.Tx, id, serverID int, previousStatusID *int, user *auth.CurrentUser) error {
+	if previousStatusID != nil {
+		serverInfo, exists, err := dbhelpers.GetServerInfo(serverID, tx)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("server #%d not found", serverID)
+		}
+		currentStatusID, adminDownID, err := getMaintenanceStatusIDs(tx, serverID)
+		if err != nil {
+			return err
+		}
+		if currentStatusID != adminDownID {
+			log.Infof("maintenance window #%d: server %s is no longer %s, leaving it %s", id, serverInfo.HostName, tc.CacheStatusAdminDown, serverInfo.Status)
+		} else if err := setMaintenanceStatus(tx, id, serverInfo, currentStatusID, *previousStatusID, nil, user); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(setMaintenanceWindowStateQuery, tc.MaintenanceWindowComplete, previousStatusID, id); err != nil {
+		return errors.New("marking maintenance window complete: " + err.Error())
+	}
+	return nil
+}
+
+// StartMaintenanceScheduler starts and ends the server maintenance windows in
+// db as they come due, for the life of the process. Windows are claimed with
+// row locks, so any number of Traffic Ops instances may run the scheduler
+// against the same database.
+func StartMaintenanceScheduler(db *sql.DB) {
+	go func() {
+		ticker := time.NewTicker(MaintenanceSchedulerInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if err := RunMaintenanceWindows(db, now); err != nil {
+				log.Errorln("server maintenance scheduler: " + err.Error())
+			}
+		}
+	}()
+}
+
+// RunMaintenanceWindows starts each scheduled maintenance window in db whose
+// start is at or before now, and ends each whose end is. Each window is run
+// in its own transaction, so one failing doesn't affect the others; a window
+// which fails is retried at the next run.
+func RunMaintenanceWindows(db *sql.DB, now time.Time) error {
+	rows, err := db.Query(dueMaintenanceWindowsQuery, now)
+	if err != nil {
+		return errors.New("querying due maintenance windows: " + err.Error())
+	}
+	ids := []int{}
+	for rows.Next() {
+		id := 0
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return errors.New("scanning due maintenance windows: " + err.Error())
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return errors.New("iterating due maintenance windows: " + err.Error())
+	}
+
+	for _, id := range ids {
+		if err := runMaintenanceWindow(db, id, now); err != nil {
+			log.Errorf("server maintenance scheduler: running maintenance window #%d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// runMaintenanceWindow starts or ends the maintenance window with the given
+// ID, as appropriate at now, if no other Traffic Ops instance is doing so. A
+// window which ends before it could be started is just marked complete.
+func runMaintenanceWindow(db *sql.DB, id int, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.New("beginning transaction: " + err.Error())
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	serverID := 0
+	var start, end time.Time
+	var reason, state string
+	var previousStatusID *int
+	user := auth.CurrentUser{}
+	if err := tx.QueryRow(claimMaintenanceWindowQuery, id).Scan(&serverID, &start, &end, &reason, &state, &previousStatusID, &user.ID, &user.UserName); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil // already run, or being run, by another instance
+		}
+		return errors.New("claiming maintenance window: " + err.Error())
+	}
+
+	switch {
+	case !end.After(now) && state == tc.MaintenanceWindowActive:
+		err = endMaintenanceWindow(tx, id, serverID, previousStatusID, &user)
+	case !end.After(now):
+		err = endMaintenanceWindow(tx, id, serverID, nil, &user)
+	case !start.After(now) && state == tc.MaintenanceWindowScheduled:
+		err = startMaintenanceWindow(tx, id, serverID, reason, &user)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.New("committing: " + err.Error())
+	}
+	committed = true
+	return nil
+}
@@ -0,0 +1,159 @@
+package server
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestCheckStatusTransition(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	cols := []string{"from", "to", "allowed", "ok"}
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM server_status_transition").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows(cols).AddRow("PRE_PROD", "REPORTED", "{REPORTED}", true))
+	mock.ExpectQuery("FROM server_status_transition").WithArgs(2, 1).WillReturnRows(sqlmock.NewRows(cols).AddRow("REPORTED", "PRE_PROD", "{}", false))
+	mock.ExpectQuery("FROM server_status_transition").WithArgs(1, 3).WillReturnRows(sqlmock.NewRows(cols).AddRow("PRE_PROD", "OFFLINE", "{REPORTED}", false))
+	tx, err := mockDB.Begin()
+	if err != nil {
+		t.Fatalf("beginning transaction: %v", err)
+	}
+
+	if userErr, sysErr, _ := checkStatusTransition(tx, 1, 1); userErr != nil || sysErr != nil {
+		t.Errorf("keeping a status: expected no errors, got user error: %v, system error: %v", userErr, sysErr)
+	}
+	if userErr, sysErr, _ := checkStatusTransition(tx, 1, 2); userErr != nil || sysErr != nil {
+		t.Errorf("allowed transition: expected no errors, got user error: %v, system error: %v", userErr, sysErr)
+	}
+	if userErr, sysErr, _ := checkStatusTransition(tx, 2, 1); userErr != nil || sysErr != nil {
+		t.Errorf("transition from an unrestricted status: expected no errors, got user error: %v, system error: %v", userErr, sysErr)
+	}
+	userErr, sysErr, code := checkStatusTransition(tx, 1, 3)
+	if sysErr != nil {
+		t.Errorf("disallowed transition: unexpected system error: %v", sysErr)
+	}
+	if userErr == nil {
+		t.Error("disallowed transition: expected a user error, got none")
+	} else if expected := "server status may not change from 'PRE_PROD' to 'OFFLINE'; allowed: REPORTED"; userErr.Error() != expected {
+		t.Errorf("disallowed transition: expected user error '%s', got '%s'", expected, userErr.Error())
+	}
+	if code != 409 {
+		t.Errorf("disallowed transition: expected status code 409, got %d", code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %v", err)
+	}
+}
+
+func TestRunMaintenanceWindows(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	now := time.Date(2021, time.June, 9, 3, 0, 0, 0, time.UTC)
+	start := now.Add(-time.Minute)
+	end := now.Add(time.Hour)
+
+	claimCols := []string{"server", "start_time", "end_time", "reason", "state", "previous_status", "id", "username"}
+	serverInfoCols := []string{"cachegroup", "name", "host_name", "domain_name", "cdn_id", "type", "id", "status"}
+	statusCols := []string{"description", "id", "last_updated", "name"}
+
+	mock.ExpectQuery("SELECT id\\s+FROM server_maintenance_window").WithArgs(now).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3))
+
+	// window 1 starts
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE OF w SKIP LOCKED").WithArgs(1).WillReturnRows(
+		sqlmock.NewRows(claimCols).AddRow(5, start, end, "disk replacement", tc.MaintenanceWindowScheduled, nil, 3, "operator"))
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(serverInfoCols).AddRow(7, "edge-cg", "edge", "example.net", 1, "EDGE", 5, "REPORTED"))
+	mock.ExpectQuery("SELECT status FROM server").WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(2))
+	mock.ExpectQuery("FROM\\s+status").WillReturnRows(sqlmock.NewRows(statusCols).AddRow("", 4, now, "ADMIN_DOWN"))
+	mock.ExpectQuery("FROM server_status_transition").WithArgs(2, 4).WillReturnRows(
+		sqlmock.NewRows([]string{"from", "to", "allowed", "ok"}).AddRow("REPORTED", "ADMIN_DOWN", "{ADMIN_DOWN}", true))
+	mock.ExpectQuery("FROM\\s+status").WithArgs(4).WillReturnRows(sqlmock.NewRows(statusCols).AddRow("", 4, now, "ADMIN_DOWN"))
+	mock.ExpectExec("UPDATE server\\s+SET\\s+status").WithArgs(4, "operator: maintenance window #1: disk replacement", sqlmock.AnyArg(), 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SET upd_pending = TRUE").WithArgs(1, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO log").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO webhook_delivery").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE server_maintenance_window").WithArgs(tc.MaintenanceWindowActive, 2, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// window 2 ends
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE OF w SKIP LOCKED").WithArgs(2).WillReturnRows(
+		sqlmock.NewRows(claimCols).AddRow(6, start.Add(-time.Hour), now, "firmware upgrade", tc.MaintenanceWindowActive, 2, 3, "operator"))
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(serverInfoCols).AddRow(8, "mid-cg", "mid", "example.net", 1, "MID", 6, "ADMIN_DOWN"))
+	mock.ExpectQuery("SELECT status FROM server").WithArgs(6).WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(4))
+	mock.ExpectQuery("FROM\\s+status").WillReturnRows(sqlmock.NewRows(statusCols).AddRow("", 4, now, "ADMIN_DOWN"))
+	mock.ExpectQuery("FROM\\s+status").WithArgs(2).WillReturnRows(sqlmock.NewRows(statusCols).AddRow("", 2, now, "REPORTED"))
+	mock.ExpectExec("UPDATE server\\s+SET\\s+status").WithArgs(2, nil, sqlmock.AnyArg(), 6).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SET upd_pending = TRUE").WithArgs(1, 8).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO log").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO webhook_delivery").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE server_maintenance_window").WithArgs(tc.MaintenanceWindowComplete, 2, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// window 3 was claimed by another instance in the meantime
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE OF w SKIP LOCKED").WithArgs(3).WillReturnRows(sqlmock.NewRows(claimCols))
+	mock.ExpectRollback()
+
+	if err := RunMaintenanceWindows(mockDB, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %v", err)
+	}
+}
+
+func TestRunMaintenanceWindowsNeverStarted(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	now := time.Date(2021, time.June, 9, 3, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT id\\s+FROM server_maintenance_window").WithArgs(now).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE OF w SKIP LOCKED").WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"server", "start_time", "end_time", "reason", "state", "previous_status", "id", "username"}).
+			AddRow(5, now.Add(-2*time.Hour), now.Add(-time.Hour), "disk replacement", tc.MaintenanceWindowScheduled, nil, 3, "operator"))
+	mock.ExpectExec("UPDATE server_maintenance_window").WithArgs(tc.MaintenanceWindowComplete, nil, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := RunMaintenanceWindows(mockDB, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %v", err)
+	}
+}
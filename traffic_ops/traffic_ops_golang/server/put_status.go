@@ -33,6 +33,7 @@ import (
 	"github.com/apache/trafficcontrol/lib/go-tc"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/webhook"
 )
 
 // InvalidStatusForDeliveryServicesAlertText returns a string describing that
@@ -116,6 +117,10 @@ func UpdateStatusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	existingStatus, existingStatusUpdatedTime := checkExistingStatusInfo(id, tx)
+	if userErr, sysErr, errCode := checkStatusTransition(tx, existingStatus, *status.ID); userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
 	if *status.Name != string(tc.CacheStatusOnline) && *status.Name != string(tc.CacheStatusReported) && *status.ID != existingStatus {
 		dsIDs, err := getActiveDeliveryServicesThatOnlyHaveThisServerAssigned(id, tx)
 		if err != nil {
@@ -148,6 +153,12 @@ func UpdateStatusHandler(w http.ResponseWriter, r *http.Request) {
 		msg += " and queued updates on all child caches"
 	}
 	api.CreateChangeLogRawTx(api.ApiChange, msg, inf.User, tx)
+	webhook.Enqueue(tx, tc.WebhookEventServerStatus, inf.User, tc.WebhookServerStatusData{
+		ID:            id,
+		HostName:      serverInfo.HostName,
+		Status:        *status.Name,
+		OfflineReason: reqObj.OfflineReason,
+	})
 	api.WriteRespAlert(w, r, tc.SuccessLevel, msg)
 }
 
@@ -717,6 +717,19 @@ and p.id = $1
 	return serviceInterface, util.JoinErrs(errs)
 }
 
+// ValidateV4 validates a server with the same rules as the server endpoints,
+// for creating it if it has no ID, and for updating it otherwise. It's for
+// other endpoints which write servers, such as CDN import.
+func ValidateV4(s *tc.ServerV40, tx *sqlx.Tx) (error, error, int) {
+	if _, err := validateV4(s, tx.Tx); err != nil {
+		return err, nil, http.StatusBadRequest
+	}
+	if s.ID == nil {
+		return nil, nil, http.StatusOK
+	}
+	return checkTypeChangeSafety(s.CommonServerProperties, tx)
+}
+
 func validateV3(s *tc.ServerV30, tx *sql.Tx) (string, error) {
 
 	if len(s.Interfaces) == 0 {
@@ -1620,6 +1633,10 @@ func Update(w http.ResponseWriter, r *http.Request) {
 		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, sysErr)
 		return
 	}
+	if userErr, sysErr, errCode = checkStatusTransition(tx, originalStatusID, *server.StatusID); userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
 	if *status.Name != string(tc.CacheStatusOnline) && *status.Name != string(tc.CacheStatusReported) {
 		dsIDs, err := getActiveDeliveryServicesThatOnlyHaveThisServerAssigned(id, tx)
 		if err != nil {
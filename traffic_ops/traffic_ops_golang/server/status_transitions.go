@@ -0,0 +1,190 @@
+package server
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
+
+	"github.com/lib/pq"
+)
+
+const readStatusTransitionsQuery = `
+SELECT t.id, fs.name AS from_status, ts.name AS to_status, t.last_updated
+FROM server_status_transition t
+JOIN status fs ON fs.id = t.from_status
+JOIN status ts ON ts.id = t.to_status
+`
+
+const insertStatusTransitionQuery = `
+INSERT INTO server_status_transition (from_status, to_status)
+SELECT fs.id, ts.id
+FROM status fs, status ts
+WHERE fs.name = $1 AND ts.name = $2
+RETURNING id, last_updated
+`
+
+const deleteStatusTransitionQuery = `
+DELETE FROM server_status_transition
+WHERE id = $1
+RETURNING (SELECT name FROM status WHERE id = from_status), (SELECT name FROM status WHERE id = to_status)
+`
+
+// statusTransitionQuery selects the names of the Statuses with IDs $1 and $2,
+// the names of the Statuses to which a transition from Status $1 exists, and
+// whether one of those is Status $2.
+const statusTransitionQuery = `
+SELECT (SELECT name FROM status WHERE id = $1),
+       (SELECT name FROM status WHERE id = $2),
+       COALESCE(ARRAY_AGG(ts.name ORDER BY ts.name) FILTER (WHERE ts.name IS NOT NULL), '{}'),
+       COALESCE(BOOL_OR(t.to_status = $2), FALSE)
+FROM server_status_transition t
+JOIN status ts ON ts.id = t.to_status
+WHERE t.from_status = $1
+`
+
+// checkStatusTransition checks that a server may be moved from the Status
+// with ID fromStatusID to the Status with ID toStatusID. Servers may always
+// keep their Status, and may be moved from a Status from which no
+// transitions are defined to any other.
+func checkStatusTransition(tx *sql.Tx, fromStatusID, toStatusID int) (error, error, int) {
+	if fromStatusID == toStatusID {
+		return nil, nil, http.StatusOK
+	}
+	var fromStatus, toStatus sql.NullString
+	allowed := []string{}
+	ok := false
+	if err := tx.QueryRow(statusTransitionQuery, fromStatusID, toStatusID).Scan(&fromStatus, &toStatus, pq.Array(&allowed), &ok); err != nil {
+		return nil, fmt.Errorf("checking server status transition from #%d to #%d: %v", fromStatusID, toStatusID, err), http.StatusInternalServerError
+	}
+	if ok || len(allowed) == 0 {
+		return nil, nil, http.StatusOK
+	}
+	return fmt.Errorf("server status may not change from '%s' to '%s'; allowed: %s", fromStatus.String, toStatus.String, strings.Join(allowed, ", ")), nil, http.StatusConflict
+}
+
+// GetStatusTransitions is the handler for GET requests to
+// /server_status_transitions.
+func GetStatusTransitions(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	cols := map[string]dbhelpers.WhereColumnInfo{
+		"id":         {Column: "t.id", Checker: api.IsInt},
+		"fromStatus": {Column: "fs.name", Checker: nil},
+		"toStatus":   {Column: "ts.name", Checker: nil},
+	}
+	if _, ok := inf.Params["orderby"]; !ok {
+		inf.Params["orderby"] = "fromStatus"
+	}
+	where, orderBy, pagination, queryValues, errs := dbhelpers.BuildWhereAndOrderByAndPagination(inf.Params, cols)
+	if len(errs) > 0 {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, util.JoinErrs(errs), nil)
+		return
+	}
+
+	rows, err := inf.Tx.NamedQuery(readStatusTransitionsQuery+where+orderBy+pagination, queryValues)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("querying server status transitions: "+err.Error()))
+		return
+	}
+	defer rows.Close()
+
+	transitions := []tc.ServerStatusTransition{}
+	for rows.Next() {
+		t := tc.ServerStatusTransition{}
+		if err := rows.Scan(&t.ID, &t.FromStatus, &t.ToStatus, &t.LastUpdated); err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("scanning server status transitions: "+err.Error()))
+			return
+		}
+		transitions = append(transitions, t)
+	}
+	api.WriteResp(w, r, transitions)
+}
+
+// CreateStatusTransition is the handler for POST requests to
+// /server_status_transitions.
+func CreateStatusTransition(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	t := tc.ServerStatusTransition{}
+	if err := api.Parse(r.Body, tx, &t); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if err := tx.QueryRow(insertStatusTransitionQuery, t.FromStatus, t.ToStatus).Scan(&t.ID, &t.LastUpdated); err == sql.ErrNoRows {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, fmt.Errorf("no such Status: '%s' or '%s'", *t.FromStatus, *t.ToStatus), nil)
+		return
+	} else if err != nil {
+		userErr, sysErr, errCode := api.ParseDBError(err)
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "server status transition was created.", t)
+	changeLogMsg := fmt.Sprintf("SERVER STATUS TRANSITION: %d, ACTION: %s server status transition from %s to %s", *t.ID, api.Created, *t.FromStatus, *t.ToStatus)
+	api.CreateChangeLogRawTx(api.ApiChange, changeLogMsg, inf.User, tx)
+}
+
+// DeleteStatusTransition is the handler for DELETE requests to
+// /server_status_transitions/{{ID}}.
+func DeleteStatusTransition(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+	id := inf.IntParams["id"]
+
+	t := tc.ServerStatusTransition{ID: &id}
+	if err := tx.QueryRow(deleteStatusTransitionQuery, id).Scan(&t.FromStatus, &t.ToStatus); err == sql.ErrNoRows {
+		api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no server status transition exists by id %d", id), nil)
+		return
+	} else if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("deleting server status transition #%d: %v", id, err))
+		return
+	}
+
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "server status transition was deleted.", t)
+	changeLogMsg := fmt.Sprintf("SERVER STATUS TRANSITION: %d, ACTION: %s server status transition from %s to %s", id, api.Deleted, *t.FromStatus, *t.ToStatus)
+	api.CreateChangeLogRawTx(api.ApiChange, changeLogMsg, inf.User, tx)
+}
@@ -0,0 +1,350 @@
+package topology
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/apache/trafficcontrol/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tenant"
+
+	"github.com/lib/pq"
+)
+
+const simulationDSQuery = `
+SELECT ds.id, ds.xml_id, ds.tenant_id, cdn.name, ds.multi_site_origin, ds.topology,
+(SELECT o.protocol::text || '://' || o.fqdn || rtrim(concat(':', o.port::text), ':')
+FROM origin o
+WHERE o.deliveryservice = ds.id
+AND o.is_primary) AS org_server_fqdn,
+ARRAY(SELECT drc.required_capability
+	FROM deliveryservices_required_capability drc
+	WHERE drc.deliveryservice_id = ds.id) AS required_capabilities
+FROM deliveryservice ds
+JOIN cdn ON cdn.id = ds.cdn_id
+WHERE ds.xml_id = $1
+`
+
+const simulationCacheGroupsQuery = `
+SELECT cg.name, t.name
+FROM cachegroup cg
+JOIN type t ON t.id = cg.type
+WHERE cg.name = ANY($1)
+`
+
+const simulationServersQuery = `
+SELECT s.id, s.host_name, s.domain_name, cg.name, cdn.name, st.name, t.name, p.name, s.tcp_port,
+(SELECT host(ip.address)
+	FROM ip_address ip
+	WHERE ip.server = s.id
+	AND ip.service_address
+	AND family(ip.address) = 4
+	LIMIT 1) AS service_ipv4,
+ARRAY(SELECT ssc.server_capability
+	FROM server_server_capability ssc
+	WHERE ssc.server = s.id) AS capabilities
+FROM server s
+JOIN cachegroup cg ON cg.id = s.cachegroup
+JOIN cdn ON cdn.id = s.cdn_id
+JOIN status st ON st.id = s.status
+JOIN type t ON t.id = s.type
+JOIN profile p ON p.id = s.profile
+WHERE cg.name = ANY($1)
+`
+
+const simulationParentConfigParamsQuery = `
+SELECT p.name, p.value, ARRAY_AGG(pr.name) AS profiles
+FROM parameter p
+JOIN profile_parameter pp ON pp.parameter = p.id
+JOIN profile pr ON pr.id = pp.profile
+WHERE p.config_file = '` + atscfg.ParentConfigFileName + `'
+GROUP BY p.id
+`
+
+const simulationDSSQuery = `
+SELECT dss.server
+FROM deliveryservice_server dss
+WHERE dss.deliveryservice = $1
+`
+
+// SimulateHandler is the handler for GET requests to /topologies/{name}/simulate.
+// It shows the Cache Groups and servers through which requests for a Delivery
+// Service pass from a Cache Group in the Topology, using the same logic as
+// parent.config generation.
+func SimulateHandler(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"name", "ds", "cachegroup"}, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	topology, ok, err := getTopology(tx, inf.Params["name"])
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	if !ok {
+		api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no such Topology: %s", inf.Params["name"]), nil)
+		return
+	}
+
+	cacheGroup := inf.Params["cachegroup"]
+	cacheGroupNames := make([]string, 0, len(topology.Nodes))
+	inTopology := false
+	for _, node := range topology.Nodes {
+		cacheGroupNames = append(cacheGroupNames, node.Cachegroup)
+		inTopology = inTopology || node.Cachegroup == cacheGroup
+	}
+	if !inTopology {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, fmt.Errorf("Cache Group '%s' is not in Topology '%s'", cacheGroup, topology.Name), nil)
+		return
+	}
+
+	ds, tenantID, dsRequiredCapabilities, ok, err := getSimulationDS(tx, inf.Params["ds"])
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	if !ok {
+		api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no such Delivery Service: %s", inf.Params["ds"]), nil)
+		return
+	}
+	if authorized, err := tenant.IsResourceAuthorizedToUserTx(tenantID, inf.User, tx); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("checking tenancy: "+err.Error()))
+		return
+	} else if !authorized {
+		api.HandleErr(w, r, tx, http.StatusForbidden, errors.New("not authorized on this tenant"), nil)
+		return
+	}
+	if userErr := checkSimulationTopology(ds, topology.Name); userErr != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, userErr, nil)
+		return
+	}
+	if ds.OrgServerFQDN == nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, fmt.Errorf("Delivery Service '%s' has no origin", *ds.XMLID), nil)
+		return
+	}
+
+	cacheGroups, err := getSimulationCacheGroups(tx, cacheGroupNames)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	servers, serverCapabilities, err := getSimulationServers(tx, cacheGroupNames)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	params, err := getSimulationParentConfigParams(tx)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	dss, err := getSimulationDSS(tx, *ds.ID)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+
+	sim, warnings, err := atscfg.SimulateTopology(&ds, tc.CacheGroupName(cacheGroup), topology, servers, params, serverCapabilities, dsRequiredCapabilities, cacheGroups, dss)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("simulating topology: "+err.Error()))
+		return
+	}
+	if len(warnings) == 0 {
+		api.WriteResp(w, r, sim)
+		return
+	}
+	alerts := tc.Alerts{}
+	for _, warning := range warnings {
+		alerts.AddNewAlert(tc.WarnLevel, warning)
+	}
+	api.WriteAlertsObj(w, r, http.StatusOK, alerts, sim)
+}
+
+// checkSimulationTopology returns an error if the given Delivery Service
+// isn't assigned to the Topology with the given name, since the simulation
+// would then show a path its requests never take.
+func checkSimulationTopology(ds atscfg.DeliveryService, topology string) error {
+	if ds.Topology == nil || *ds.Topology != topology {
+		return fmt.Errorf("Delivery Service '%s' is not assigned to Topology '%s'", *ds.XMLID, topology)
+	}
+	return nil
+}
+
+// getTopology returns the Topology with the given name, and whether it
+// exists.
+func getTopology(tx *sql.Tx, name string) (tc.Topology, bool, error) {
+	rows, err := tx.Query(selectQuery()+" WHERE t.name = $1", name)
+	if err != nil {
+		return tc.Topology{}, false, errors.New("querying topology: " + err.Error())
+	}
+	defer log.Close(rows, "closing topology rows")
+
+	topology := tc.Topology{Name: name, Nodes: []tc.TopologyNode{}}
+	for rows.Next() {
+		node := tc.TopologyNode{Parents: []int{}}
+		var parents pq.Int64Array
+		lastUpdated := tc.TimeNoMod{}
+		if err := rows.Scan(&topology.Name, &topology.Description, &lastUpdated, &node.Id, &node.Cachegroup, &parents); err != nil {
+			return tc.Topology{}, false, errors.New("scanning topology: " + err.Error())
+		}
+		topology.LastUpdated = &lastUpdated
+		for _, id := range parents {
+			node.Parents = append(node.Parents, int(id))
+		}
+		topology.Nodes = append(topology.Nodes, node)
+	}
+	if err := rows.Err(); err != nil {
+		return tc.Topology{}, false, errors.New("iterating over topology rows: " + err.Error())
+	}
+	if len(topology.Nodes) == 0 {
+		return tc.Topology{}, false, nil
+	}
+
+	// Parents are selected by ID, but referred to by index.
+	indices := map[int]int{}
+	for i, node := range topology.Nodes {
+		indices[node.Id] = i
+	}
+	for _, node := range topology.Nodes {
+		for i, parent := range node.Parents {
+			node.Parents[i] = indices[parent]
+		}
+	}
+	return topology, true, nil
+}
+
+// getSimulationDS returns the Delivery Service with the given XMLID, its
+// Tenant's ID, its required capabilities, and whether it exists.
+func getSimulationDS(tx *sql.Tx, xmlID string) (atscfg.DeliveryService, int, map[int]map[atscfg.ServerCapability]struct{}, bool, error) {
+	ds := atscfg.DeliveryService{}
+	tenantID := 0
+	requiredCapabilities := []string{}
+	if err := tx.QueryRow(simulationDSQuery, xmlID).Scan(&ds.ID, &ds.XMLID, &tenantID, &ds.CDNName, &ds.MultiSiteOrigin, &ds.Topology, &ds.OrgServerFQDN, pq.Array(&requiredCapabilities)); err == sql.ErrNoRows {
+		return ds, 0, nil, false, nil
+	} else if err != nil {
+		return ds, 0, nil, false, errors.New("querying Delivery Service: " + err.Error())
+	}
+	caps := map[atscfg.ServerCapability]struct{}{}
+	for _, capability := range requiredCapabilities {
+		caps[atscfg.ServerCapability(capability)] = struct{}{}
+	}
+	return ds, tenantID, map[int]map[atscfg.ServerCapability]struct{}{*ds.ID: caps}, true, nil
+}
+
+// getSimulationCacheGroups returns the Cache Groups with the given names.
+func getSimulationCacheGroups(tx *sql.Tx, names []string) ([]tc.CacheGroupNullable, error) {
+	rows, err := tx.Query(simulationCacheGroupsQuery, pq.Array(names))
+	if err != nil {
+		return nil, errors.New("querying cachegroups: " + err.Error())
+	}
+	defer log.Close(rows, "closing cachegroup rows")
+	cacheGroups := []tc.CacheGroupNullable{}
+	for rows.Next() {
+		cg := tc.CacheGroupNullable{}
+		if err := rows.Scan(&cg.Name, &cg.Type); err != nil {
+			return nil, errors.New("scanning cachegroups: " + err.Error())
+		}
+		cacheGroups = append(cacheGroups, cg)
+	}
+	return cacheGroups, rows.Err()
+}
+
+// getSimulationServers returns the servers in the Cache Groups with the given
+// names, and their capabilities.
+func getSimulationServers(tx *sql.Tx, cacheGroups []string) ([]atscfg.Server, map[int]map[atscfg.ServerCapability]struct{}, error) {
+	rows, err := tx.Query(simulationServersQuery, pq.Array(cacheGroups))
+	if err != nil {
+		return nil, nil, errors.New("querying servers: " + err.Error())
+	}
+	defer log.Close(rows, "closing server rows")
+	servers := []atscfg.Server{}
+	serverCapabilities := map[int]map[atscfg.ServerCapability]struct{}{}
+	for rows.Next() {
+		sv := atscfg.Server{}
+		serviceIPv4 := sql.NullString{}
+		capabilities := []string{}
+		if err := rows.Scan(&sv.ID, &sv.HostName, &sv.DomainName, &sv.Cachegroup, &sv.CDNName, &sv.Status, &sv.Type, &sv.Profile, &sv.TCPPort, &serviceIPv4, pq.Array(&capabilities)); err != nil {
+			return nil, nil, errors.New("scanning servers: " + err.Error())
+		}
+		if serviceIPv4.Valid {
+			sv.Interfaces = []tc.ServerInterfaceInfo{{IPAddresses: []tc.ServerIPAddress{{Address: serviceIPv4.String, ServiceAddress: true}}}}
+		}
+		caps := map[atscfg.ServerCapability]struct{}{}
+		for _, capability := range capabilities {
+			caps[atscfg.ServerCapability(capability)] = struct{}{}
+		}
+		serverCapabilities[*sv.ID] = caps
+		servers = append(servers, sv)
+	}
+	return servers, serverCapabilities, rows.Err()
+}
+
+// getSimulationParentConfigParams returns the parent.config Parameters, with
+// the names of their Profiles.
+func getSimulationParentConfigParams(tx *sql.Tx) ([]tc.Parameter, error) {
+	rows, err := tx.Query(simulationParentConfigParamsQuery)
+	if err != nil {
+		return nil, errors.New("querying parent.config parameters: " + err.Error())
+	}
+	defer log.Close(rows, "closing parameter rows")
+	params := []tc.Parameter{}
+	for rows.Next() {
+		param := tc.Parameter{ConfigFile: atscfg.ParentConfigFileName}
+		profiles := []string{}
+		if err := rows.Scan(&param.Name, &param.Value, pq.Array(&profiles)); err != nil {
+			return nil, errors.New("scanning parent.config parameters: " + err.Error())
+		}
+		if param.Profiles, err = json.Marshal(profiles); err != nil {
+			return nil, errors.New("marshalling parameter profiles: " + err.Error())
+		}
+		params = append(params, param)
+	}
+	return params, rows.Err()
+}
+
+// getSimulationDSS returns the servers assigned to the Delivery Service with
+// the given ID, which for Topology-based Delivery Services are its origins.
+func getSimulationDSS(tx *sql.Tx, dsID int) ([]atscfg.DeliveryServiceServer, error) {
+	rows, err := tx.Query(simulationDSSQuery, dsID)
+	if err != nil {
+		return nil, errors.New("querying Delivery Service servers: " + err.Error())
+	}
+	defer log.Close(rows, "closing Delivery Service server rows")
+	dss := []atscfg.DeliveryServiceServer{}
+	for rows.Next() {
+		d := atscfg.DeliveryServiceServer{DeliveryService: dsID}
+		if err := rows.Scan(&d.Server); err != nil {
+			return nil, errors.New("scanning Delivery Service servers: " + err.Error())
+		}
+		dss = append(dss, d)
+	}
+	return dss, rows.Err()
+}
@@ -0,0 +1,49 @@
+package topology
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"testing"
+
+	"github.com/apache/trafficcontrol/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/lib/go-util"
+)
+
+func TestCheckSimulationTopology(t *testing.T) {
+	testCases := []struct {
+		name      string
+		topology  *string
+		expectErr bool
+	}{
+		{name: "on the Topology", topology: util.StrPtr("mso-topology"), expectErr: false},
+		{name: "on another Topology", topology: util.StrPtr("other-topology"), expectErr: true},
+		{name: "not on any Topology", topology: nil, expectErr: true},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ds := atscfg.DeliveryService{}
+			ds.XMLID = util.StrPtr("demo1")
+			ds.Topology = testCase.topology
+			if err := checkSimulationTopology(ds, "mso-topology"); (err != nil) != testCase.expectErr {
+				t.Errorf("expected error: %t, actual: %v", testCase.expectErr, err)
+			}
+		})
+	}
+}
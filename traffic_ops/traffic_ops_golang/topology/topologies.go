@@ -32,6 +32,7 @@ import (
 	"github.com/apache/trafficcontrol/lib/go-tc/tovalidate"
 	"github.com/apache/trafficcontrol/lib/go-util"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/cachegroup"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/deliveryservice"
@@ -407,6 +408,25 @@ func (topology *TOTopology) GetAuditName() string {
 	return topology.Name
 }
 
+// CheckCDNLock implements the api.CDNLockable interface. Topologies don't
+// belong to any single CDN, so changes to one are prevented by locks on the
+// Topology itself - in any CDN - and by locks on every CDN it touches, or
+// would touch once its new Cache Groups are added.
+func (topology *TOTopology) CheckCDNLock(user *auth.CurrentUser) (error, error, int) {
+	if topology.Name == "" {
+		return nil, nil, http.StatusOK
+	}
+	cacheGroups := make([]string, 0, len(topology.Nodes))
+	for _, node := range topology.Nodes {
+		cacheGroups = append(cacheGroups, node.Cachegroup)
+	}
+	cdns, err := dbhelpers.GetTopologyCDNs(topology.ReqInfo.Tx.Tx, topology.Name, cacheGroups)
+	if err != nil {
+		return nil, fmt.Errorf("getting cdns of topology '%s': %v", topology.Name, err), http.StatusInternalServerError
+	}
+	return dbhelpers.CheckIfCurrentUserCanModifyMultiCDNObject(topology.ReqInfo.Tx.Tx, cdns, tc.CDNLockScopeTopology, topology.Name, user.UserName)
+}
+
 // Create is a requirement of the api.Creator interface.
 func (topology *TOTopology) Create() (error, error, int) {
 	tx := topology.APIInfo().Tx.Tx
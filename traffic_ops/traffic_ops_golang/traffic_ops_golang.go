@@ -36,14 +36,19 @@ import (
 
 	"github.com/apache/trafficcontrol/lib/go-log"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/about"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/asyncjob"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/deliveryservice"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/invalidationjobs"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/plugin"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/routing"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/server"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/trafficvault"
 	_ "github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/trafficvault/backends" // init traffic vault backends
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/trafficvault/backends/disabled"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/trafficvault/backends/riaksvc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/webhook"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
@@ -169,6 +174,12 @@ func main() {
 
 	plugins.OnStartup(plugin.StartupData{Data: plugin.Data{SharedCfg: cfg.PluginSharedConfig, AppCfg: cfg}})
 
+	webhook.StartWorker(db.DB)
+	asyncjob.StartWorker(db, &cfg, trafficVault)
+	invalidationjobs.StartScheduler(db.DB)
+	server.StartMaintenanceScheduler(db.DB)
+	deliveryservice.StartSSLKeyExpirationAlerts(db.DB, &cfg, trafficVault)
+
 	log.Infof("Listening on " + cfg.Port)
 
 	server := &http.Server{
@@ -286,7 +297,7 @@ func setupTrafficVault(riakConfigFileName string, cfg *config.Config) trafficvau
 			log.Errorf("failed to get Traffic Vault backend '%s': %s", cfg.TrafficVaultBackend, err.Error())
 			os.Exit(1)
 		}
-		return trafficVault
+		return trafficvault.WithMetrics(trafficVault)
 	}
 	return &disabled.Disabled{}
 }
@@ -0,0 +1,531 @@
+// Package filesystem provides a TrafficVault implementation which stores keys as
+// AES-GCM encrypted files in a directory tree on the local filesystem.
+package filesystem
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"crypto/aes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-tc/tovalidate"
+	"github.com/apache/trafficcontrol/lib/go-util"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/trafficvault"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+const (
+	notImplementedErr = Error("this Traffic Vault functionality is not implemented for the filesystem backend")
+
+	filesystemBackendName = "filesystem"
+
+	latestVersion = "latest"
+
+	sslKeysDir     = "sslkeys"
+	dnssecKeysDir  = "dnssec"
+	urlSigKeysDir  = "url_sig_keys"
+	uriSignKeysDir = "uri_signing_keys"
+
+	keyFileExtension = ".enc"
+
+	dirPerms  = 0700
+	filePerms = 0600
+)
+
+type Config struct {
+	Directory      string `json:"directory"`
+	AesKeyLocation string `json:"aes_key_location"`
+}
+
+// Filesystem is a TrafficVault that stores each key as its own encrypted file
+// beneath a single base directory:
+//
+//	<directory>/sslkeys/<xmlID>/<version>.enc (plus latest.enc)
+//	<directory>/dnssec/<cdn>.enc
+//	<directory>/url_sig_keys/<xmlID>.enc
+//	<directory>/uri_signing_keys/<xmlID>.enc
+//
+// Files are written atomically (to a temporary file which is then renamed),
+// so a reader never observes a partially written key.
+type Filesystem struct {
+	cfg    Config
+	aesKey []byte
+	mutex  sync.RWMutex
+}
+
+// checkName ensures the given Delivery Service or CDN name can be used as a
+// single path component without escaping the Traffic Vault directory.
+func checkName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || strings.ContainsRune(name, 0) {
+		return errors.New("invalid Traffic Vault key name '" + name + "'")
+	}
+	return nil
+}
+
+func (f *Filesystem) path(elems ...string) string {
+	return filepath.Join(append([]string{f.cfg.Directory}, elems...)...)
+}
+
+// readFile decrypts and returns the contents of the given file. The returned
+// bool is false if the file does not exist.
+func (f *Filesystem) readFile(path string) ([]byte, bool, error) {
+	encrypted, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, errors.New("Traffic Vault filesystem: reading '" + path + "': " + err.Error())
+	}
+	decrypted, err := util.AESDecrypt(encrypted, f.aesKey)
+	if err != nil {
+		return nil, false, errors.New("Traffic Vault filesystem: decrypting '" + path + "': " + err.Error())
+	}
+	return decrypted, true, nil
+}
+
+// writeFile encrypts the given data and atomically replaces the given file with it.
+func (f *Filesystem) writeFile(path string, data []byte) error {
+	encrypted, err := util.AESEncrypt(data, f.aesKey)
+	if err != nil {
+		return errors.New("encrypting keys: " + err.Error())
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, dirPerms); err != nil {
+		return errors.New("Traffic Vault filesystem: creating directory '" + dir + "': " + err.Error())
+	}
+	tmp, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return errors.New("Traffic Vault filesystem: creating temporary file in '" + dir + "': " + err.Error())
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(encrypted); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return errors.New("Traffic Vault filesystem: writing '" + tmpName + "': " + err.Error())
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return errors.New("Traffic Vault filesystem: syncing '" + tmpName + "': " + err.Error())
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return errors.New("Traffic Vault filesystem: closing '" + tmpName + "': " + err.Error())
+	}
+	if err := os.Chmod(tmpName, filePerms); err != nil {
+		os.Remove(tmpName)
+		return errors.New("Traffic Vault filesystem: setting permissions on '" + tmpName + "': " + err.Error())
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return errors.New("Traffic Vault filesystem: renaming '" + tmpName + "' to '" + path + "': " + err.Error())
+	}
+	return nil
+}
+
+// removeFile removes the given file; it is not an error if it doesn't exist.
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.New("Traffic Vault filesystem: removing '" + path + "': " + err.Error())
+	}
+	return nil
+}
+
+// listDir returns the names of the entries in the given directory, or nothing
+// if it doesn't exist.
+func listDir(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.New("Traffic Vault filesystem: listing '" + dir + "': " + err.Error())
+	}
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		names = append(names, info.Name())
+	}
+	return names, nil
+}
+
+func (f *Filesystem) sslKeyPath(xmlID string, version string) string {
+	return f.path(sslKeysDir, xmlID, version+keyFileExtension)
+}
+
+// getSSLKeys reads and unmarshals a single version of a Delivery Service's SSL
+// keys. The caller must hold the lock.
+func (f *Filesystem) getSSLKeys(xmlID string, version string) (tc.DeliveryServiceSSLKeysV15, bool, error) {
+	data, ok, err := f.readFile(f.sslKeyPath(xmlID, version))
+	if err != nil || !ok {
+		return tc.DeliveryServiceSSLKeysV15{}, false, err
+	}
+	sslKey := tc.DeliveryServiceSSLKeysV15{}
+	if err := json.Unmarshal(data, &sslKey); err != nil {
+		return tc.DeliveryServiceSSLKeysV15{}, false, errors.New("unmarshalling ssl keys: " + err.Error())
+	}
+	return sslKey, true, nil
+}
+
+// GetDeliveryServiceSSLKeys retrieves the SSL keys of the given version for
+// the delivery service identified by the given xmlID. If version is empty,
+// the implementation should return the latest version.
+func (f *Filesystem) GetDeliveryServiceSSLKeys(xmlID string, version string, tx *sql.Tx, ctx context.Context) (tc.DeliveryServiceSSLKeysV15, bool, error) {
+	if version == "" {
+		version = latestVersion
+	}
+	if err := checkName(xmlID); err != nil {
+		return tc.DeliveryServiceSSLKeysV15{}, false, err
+	}
+	if err := checkName(version); err != nil {
+		return tc.DeliveryServiceSSLKeysV15{}, false, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.getSSLKeys(xmlID, version)
+}
+
+// PutDeliveryServiceSSLKeys stores the given SSL keys for a delivery service.
+func (f *Filesystem) PutDeliveryServiceSSLKeys(key tc.DeliveryServiceSSLKeys, tx *sql.Tx, ctx context.Context) error {
+	if err := checkName(key.DeliveryService); err != nil {
+		return err
+	}
+	keyJSON, err := json.Marshal(&key)
+	if err != nil {
+		return errors.New("marshalling keys: " + err.Error())
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.writeFile(f.sslKeyPath(key.DeliveryService, strconv.FormatInt(int64(key.Version), 10)), keyJSON); err != nil {
+		return err
+	}
+	return f.writeFile(f.sslKeyPath(key.DeliveryService, latestVersion), keyJSON)
+}
+
+// DeleteDeliveryServiceSSLKeys removes the SSL keys of the given version (or latest
+// if version is empty) for the delivery service identified by the given xmlID.
+func (f *Filesystem) DeleteDeliveryServiceSSLKeys(xmlID string, version string, tx *sql.Tx, ctx context.Context) error {
+	if version == "" {
+		version = latestVersion
+	}
+	if err := checkName(xmlID); err != nil {
+		return err
+	}
+	if err := checkName(version); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return removeFile(f.sslKeyPath(xmlID, version))
+}
+
+// DeleteOldDeliveryServiceSSLKeys takes a set of existingXMLIDs as input and will remove
+// all SSL keys for delivery services in the CDN identified by the given cdnName that
+// do not contain an xmlID in the given set of existingXMLIDs. This method is called
+// during a snapshot operation in order to delete SSL keys for delivery services that
+// no longer exist.
+func (f *Filesystem) DeleteOldDeliveryServiceSSLKeys(existingXMLIDs map[string]struct{}, cdnName string, tx *sql.Tx, ctx context.Context) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	xmlIDs, err := listDir(f.path(sslKeysDir))
+	if err != nil {
+		return err
+	}
+	for _, xmlID := range xmlIDs {
+		if _, ok := existingXMLIDs[xmlID]; ok {
+			continue
+		}
+		key, ok, err := f.getSSLKeys(xmlID, latestVersion)
+		if err != nil {
+			log.Errorf("Traffic Vault filesystem: reading SSL keys for delivery service '%s': %v", xmlID, err)
+			continue
+		}
+		if !ok || key.CDN != cdnName {
+			continue
+		}
+		dir := f.path(sslKeysDir, xmlID)
+		if err := os.RemoveAll(dir); err != nil {
+			return errors.New("Traffic Vault filesystem: removing '" + dir + "': " + err.Error())
+		}
+	}
+	return nil
+}
+
+// GetCDNSSLKeys retrieves all the SSL keys for delivery services in the CDN identified
+// by the given cdnName.
+func (f *Filesystem) GetCDNSSLKeys(cdnName string, tx *sql.Tx, ctx context.Context) ([]tc.CDNSSLKey, error) {
+	keys := []tc.CDNSSLKey{}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	xmlIDs, err := listDir(f.path(sslKeysDir))
+	if err != nil {
+		return keys, err
+	}
+	for _, xmlID := range xmlIDs {
+		data, ok, err := f.readFile(f.sslKeyPath(xmlID, latestVersion))
+		if err != nil {
+			log.Errorf("couldn't read key: %v", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		dsKey := tc.DeliveryServiceSSLKeys{}
+		if err := json.Unmarshal(data, &dsKey); err != nil {
+			log.Errorf("couldn't unmarshal json key: %v", err)
+			continue
+		}
+		if dsKey.CDN != cdnName {
+			continue
+		}
+		keys = append(keys, tc.CDNSSLKey{
+			DeliveryService: dsKey.DeliveryService,
+			HostName:        dsKey.Hostname,
+			Certificate:     tc.CDNSSLKeyCert{Crt: dsKey.Certificate.Crt, Key: dsKey.Certificate.Key},
+		})
+	}
+	return keys, nil
+}
+
+// GetDNSSECKeys retrieves all the DNSSEC keys associated with the CDN identified by the
+// given cdnName.
+func (f *Filesystem) GetDNSSECKeys(cdnName string, tx *sql.Tx, ctx context.Context) (tc.DNSSECKeysTrafficVault, bool, error) {
+	if err := checkName(cdnName); err != nil {
+		return tc.DNSSECKeysTrafficVault{}, false, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	data, ok, err := f.readFile(f.path(dnssecKeysDir, cdnName+keyFileExtension))
+	if err != nil || !ok {
+		return tc.DNSSECKeysTrafficVault{}, false, err
+	}
+	dnssecKeys := tc.DNSSECKeysTrafficVault{}
+	if err := json.Unmarshal(data, &dnssecKeys); err != nil {
+		return tc.DNSSECKeysTrafficVault{}, false, errors.New("unmarshalling DNSSEC keys: " + err.Error())
+	}
+	return dnssecKeys, true, nil
+}
+
+// PutDNSSECKeys stores all the DNSSEC keys for the CDN identified by the given cdnName.
+func (f *Filesystem) PutDNSSECKeys(cdnName string, keys tc.DNSSECKeysTrafficVault, tx *sql.Tx, ctx context.Context) error {
+	if err := checkName(cdnName); err != nil {
+		return err
+	}
+	dnssecJSON, err := json.Marshal(&keys)
+	if err != nil {
+		return errors.New("marshalling DNSSEC keys: " + err.Error())
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.writeFile(f.path(dnssecKeysDir, cdnName+keyFileExtension), dnssecJSON)
+}
+
+// DeleteDNSSECKeys removes all the DNSSEC keys for the CDN identified by the given cdnName.
+func (f *Filesystem) DeleteDNSSECKeys(cdnName string, tx *sql.Tx, ctx context.Context) error {
+	if err := checkName(cdnName); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return removeFile(f.path(dnssecKeysDir, cdnName+keyFileExtension))
+}
+
+// GetURLSigKeys retrieves the URL sig keys for the delivery service identified by the
+// given xmlID.
+func (f *Filesystem) GetURLSigKeys(xmlID string, tx *sql.Tx, ctx context.Context) (tc.URLSigKeys, bool, error) {
+	if err := checkName(xmlID); err != nil {
+		return tc.URLSigKeys{}, false, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	data, ok, err := f.readFile(f.path(urlSigKeysDir, xmlID+keyFileExtension))
+	if err != nil || !ok {
+		return tc.URLSigKeys{}, false, err
+	}
+	keys := tc.URLSigKeys{}
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return tc.URLSigKeys{}, false, errors.New("unmarshalling keys: " + err.Error())
+	}
+	return keys, true, nil
+}
+
+// PutURLSigKeys stores the given URL sig keys for the delivery service identified by
+// the given xmlID.
+func (f *Filesystem) PutURLSigKeys(xmlID string, keys tc.URLSigKeys, tx *sql.Tx, ctx context.Context) error {
+	if err := checkName(xmlID); err != nil {
+		return err
+	}
+	keyJSON, err := json.Marshal(&keys)
+	if err != nil {
+		return errors.New("marshalling keys: " + err.Error())
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.writeFile(f.path(urlSigKeysDir, xmlID+keyFileExtension), keyJSON)
+}
+
+// DeleteURLSigKeys deletes the URL sig keys for the delivery service identified
+// by the given xmlID.
+func (f *Filesystem) DeleteURLSigKeys(xmlID string, tx *sql.Tx, ctx context.Context) error {
+	if err := checkName(xmlID); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return removeFile(f.path(urlSigKeysDir, xmlID+keyFileExtension))
+}
+
+// GetURISigningKeys retrieves the URI signing keys (as raw JSON bytes) for the delivery
+// service identified by the given xmlID.
+func (f *Filesystem) GetURISigningKeys(xmlID string, tx *sql.Tx, ctx context.Context) ([]byte, bool, error) {
+	if err := checkName(xmlID); err != nil {
+		return []byte{}, false, err
+	}
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	data, ok, err := f.readFile(f.path(uriSignKeysDir, xmlID+keyFileExtension))
+	if err != nil || !ok {
+		return []byte{}, false, err
+	}
+	return data, true, nil
+}
+
+// PutURISigningKeys stores the given URI signing keys (as raw JSON bytes) for the delivery
+// service identified by the given xmlID.
+func (f *Filesystem) PutURISigningKeys(xmlID string, keysJson []byte, tx *sql.Tx, ctx context.Context) error {
+	if err := checkName(xmlID); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.writeFile(f.path(uriSignKeysDir, xmlID+keyFileExtension), keysJson)
+}
+
+// DeleteURISigningKeys removes the URI signing keys for the delivery service identified by
+// the given xmlID.
+func (f *Filesystem) DeleteURISigningKeys(xmlID string, tx *sql.Tx, ctx context.Context) error {
+	if err := checkName(xmlID); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return removeFile(f.path(uriSignKeysDir, xmlID+keyFileExtension))
+}
+
+// Ping checks that the Traffic Vault directory exists and is writable.
+func (f *Filesystem) Ping(tx *sql.Tx, ctx context.Context) (tc.TrafficVaultPing, error) {
+	info, err := os.Stat(f.cfg.Directory)
+	if err != nil {
+		return tc.TrafficVaultPing{}, errors.New("Traffic Vault filesystem: checking directory: " + err.Error())
+	}
+	if !info.IsDir() {
+		return tc.TrafficVaultPing{}, errors.New("Traffic Vault filesystem: '" + f.cfg.Directory + "' is not a directory")
+	}
+	tmp, err := ioutil.TempFile(f.cfg.Directory, ".ping-")
+	if err != nil {
+		return tc.TrafficVaultPing{}, errors.New("Traffic Vault filesystem: directory is not writable: " + err.Error())
+	}
+	tmp.Close()
+	if err := os.Remove(tmp.Name()); err != nil {
+		return tc.TrafficVaultPing{}, errors.New("Traffic Vault filesystem: removing ping file: " + err.Error())
+	}
+	return tc.TrafficVaultPing{Status: "OK", Server: f.cfg.Directory}, nil
+}
+
+func (f *Filesystem) GetBucketKey(bucket string, key string, tx *sql.Tx) ([]byte, bool, error) {
+	return nil, false, notImplementedErr
+}
+
+func init() {
+	trafficvault.AddBackend(filesystemBackendName, filesystemLoad)
+}
+
+func filesystemLoad(b json.RawMessage) (trafficvault.TrafficVault, error) {
+	fsCfg := Config{}
+	if err := json.Unmarshal(b, &fsCfg); err != nil {
+		return nil, errors.New("unmarshalling filesystem config: " + err.Error())
+	}
+	if err := validateConfig(fsCfg); err != nil {
+		return nil, errors.New("validating filesystem config: " + err.Error())
+	}
+	fsCfg.Directory = filepath.Clean(fsCfg.Directory)
+	if err := os.MkdirAll(fsCfg.Directory, dirPerms); err != nil {
+		return nil, errors.New("creating Traffic Vault directory: " + err.Error())
+	}
+
+	aesKey, err := readKey(fsCfg.AesKeyLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Filesystem{cfg: fsCfg, aesKey: aesKey}, nil
+}
+
+func validateConfig(cfg Config) error {
+	errs := tovalidate.ToErrors(validation.Errors{
+		"directory":        validation.Validate(cfg.Directory, validation.Required),
+		"aes_key_location": validation.Validate(cfg.AesKeyLocation, validation.Required),
+	})
+	if len(errs) == 0 {
+		return nil
+	}
+	return util.JoinErrs(errs)
+}
+
+// readKey reads the AES key (encoded in base64) used for encryption/decryption from the given file.
+func readKey(location string) ([]byte, error) {
+	keyBase64Bytes, err := ioutil.ReadFile(location)
+	if err != nil {
+		return []byte{}, errors.New("reading file '" + location + "':" + err.Error())
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(keyBase64Bytes)))
+	if err != nil {
+		return []byte{}, errors.New("AES key cannot be decoded from base64")
+	}
+
+	// verify the key works
+	if _, err = aes.NewCipher(key); err != nil {
+		return []byte{}, err
+	}
+
+	return key, nil
+}
@@ -0,0 +1,239 @@
+package filesystem
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+)
+
+func newTestFilesystem(t *testing.T) (*Filesystem, func()) {
+	dir, err := ioutil.TempDir("", "trafficvault-filesystem")
+	if err != nil {
+		t.Fatalf("creating temporary directory: %v", err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("generating AES key: %v", err)
+	}
+	keyFile := filepath.Join(dir, "aes.key")
+	if err := ioutil.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600); err != nil {
+		t.Fatalf("writing AES key: %v", err)
+	}
+	cfg, err := json.Marshal(Config{Directory: filepath.Join(dir, "vault"), AesKeyLocation: keyFile})
+	if err != nil {
+		t.Fatalf("marshalling config: %v", err)
+	}
+	tv, err := filesystemLoad(cfg)
+	if err != nil {
+		t.Fatalf("loading filesystem backend: %v", err)
+	}
+	return tv.(*Filesystem), func() { os.RemoveAll(dir) }
+}
+
+func TestFilesystemLoadInvalid(t *testing.T) {
+	if _, err := filesystemLoad([]byte(`{}`)); err == nil {
+		t.Error("expected an error loading a config without a directory or key, got nil")
+	}
+	if _, err := filesystemLoad([]byte(`{"directory": "/tmp", "aes_key_location": "/does/not/exist"}`)); err == nil {
+		t.Error("expected an error loading a config with a missing key file, got nil")
+	}
+}
+
+func TestFilesystemSSLKeys(t *testing.T) {
+	f, cleanup := newTestFilesystem(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for _, xmlID := range []string{"", ".", "..", "../escape", "a/b"} {
+		if _, _, err := f.GetDeliveryServiceSSLKeys(xmlID, "", nil, ctx); err == nil {
+			t.Errorf("expected an error getting SSL keys for invalid xmlID '%s', got nil", xmlID)
+		}
+	}
+
+	if _, ok, err := f.GetDeliveryServiceSSLKeys("ds1", "", nil, ctx); err != nil || ok {
+		t.Fatalf("getting nonexistent SSL keys: expected not found and no error, got %t %v", ok, err)
+	}
+
+	key1 := tc.DeliveryServiceSSLKeys{CDN: "cdn1", DeliveryService: "ds1", Hostname: "*.ds1.example.test", Key: "ds1", Version: 1, Certificate: tc.DeliveryServiceSSLKeysCertificate{Crt: "crt1", Key: "secret-key-1"}}
+	key2 := key1
+	key2.Version = 2
+	key2.Certificate.Crt = "crt2"
+	other := tc.DeliveryServiceSSLKeys{CDN: "cdn2", DeliveryService: "ds2", Key: "ds2", Version: 1, Certificate: tc.DeliveryServiceSSLKeysCertificate{Crt: "crt3", Key: "key3"}}
+	for _, k := range []tc.DeliveryServiceSSLKeys{key1, key2, other} {
+		if err := f.PutDeliveryServiceSSLKeys(k, nil, ctx); err != nil {
+			t.Fatalf("putting SSL keys: %v", err)
+		}
+	}
+
+	latest, ok, err := f.GetDeliveryServiceSSLKeys("ds1", "", nil, ctx)
+	if err != nil || !ok {
+		t.Fatalf("getting latest SSL keys: expected found and no error, got %t %v", ok, err)
+	}
+	if !reflect.DeepEqual(latest.DeliveryServiceSSLKeys, key2) {
+		t.Errorf("latest SSL keys: expected %+v, got %+v", key2, latest.DeliveryServiceSSLKeys)
+	}
+	v1, ok, err := f.GetDeliveryServiceSSLKeys("ds1", "1", nil, ctx)
+	if err != nil || !ok {
+		t.Fatalf("getting version 1 SSL keys: expected found and no error, got %t %v", ok, err)
+	}
+	if !reflect.DeepEqual(v1.DeliveryServiceSSLKeys, key1) {
+		t.Errorf("version 1 SSL keys: expected %+v, got %+v", key1, v1.DeliveryServiceSSLKeys)
+	}
+
+	raw, err := ioutil.ReadFile(f.sslKeyPath("ds1", "1"))
+	if err != nil {
+		t.Fatalf("reading SSL key file: %v", err)
+	}
+	if bytes.Contains(raw, []byte("secret-key-1")) {
+		t.Error("expected SSL key file to be encrypted, but it contains the plaintext private key")
+	}
+	if info, err := os.Stat(f.sslKeyPath("ds1", "1")); err != nil {
+		t.Errorf("checking SSL key file: %v", err)
+	} else if info.Mode().Perm() != filePerms {
+		t.Errorf("expected SSL key file permissions %o, got %o", filePerms, info.Mode().Perm())
+	}
+
+	cdnKeys, err := f.GetCDNSSLKeys("cdn1", nil, ctx)
+	if err != nil {
+		t.Fatalf("getting CDN SSL keys: %v", err)
+	}
+	expectedCDNKeys := []tc.CDNSSLKey{{DeliveryService: "ds1", HostName: "*.ds1.example.test", Certificate: tc.CDNSSLKeyCert{Crt: "crt2", Key: "secret-key-1"}}}
+	if !reflect.DeepEqual(cdnKeys, expectedCDNKeys) {
+		t.Errorf("CDN SSL keys: expected %+v, got %+v", expectedCDNKeys, cdnKeys)
+	}
+
+	if err := f.DeleteDeliveryServiceSSLKeys("ds1", "1", nil, ctx); err != nil {
+		t.Fatalf("deleting version 1 SSL keys: %v", err)
+	}
+	if _, ok, err := f.GetDeliveryServiceSSLKeys("ds1", "1", nil, ctx); err != nil || ok {
+		t.Errorf("getting deleted SSL keys: expected not found and no error, got %t %v", ok, err)
+	}
+	if _, ok, err := f.GetDeliveryServiceSSLKeys("ds1", "", nil, ctx); err != nil || !ok {
+		t.Errorf("getting latest SSL keys after deleting version 1: expected found and no error, got %t %v", ok, err)
+	}
+
+	if err := f.DeleteOldDeliveryServiceSSLKeys(map[string]struct{}{}, "cdn1", nil, ctx); err != nil {
+		t.Fatalf("deleting old SSL keys: %v", err)
+	}
+	if _, ok, err := f.GetDeliveryServiceSSLKeys("ds1", "2", nil, ctx); err != nil || ok {
+		t.Errorf("getting old SSL keys: expected not found and no error, got %t %v", ok, err)
+	}
+	if _, ok, err := f.GetDeliveryServiceSSLKeys("ds2", "", nil, ctx); err != nil || !ok {
+		t.Errorf("getting SSL keys in another CDN: expected found and no error, got %t %v", ok, err)
+	}
+}
+
+func TestFilesystemDNSSECKeys(t *testing.T) {
+	f, cleanup := newTestFilesystem(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	keys := tc.DNSSECKeysTrafficVault{
+		"cdn1": tc.DNSSECKeySetV11{
+			ZSK: []tc.DNSSECKeyV11{{InceptionDateUnix: 1, ExpirationDateUnix: 2, Name: "cdn1.", TTLSeconds: 60, Status: "new", EffectiveDateUnix: 1, Public: "pub", Private: "priv"}},
+		},
+	}
+	if err := f.PutDNSSECKeys("cdn1", keys, nil, ctx); err != nil {
+		t.Fatalf("putting DNSSEC keys: %v", err)
+	}
+	actual, ok, err := f.GetDNSSECKeys("cdn1", nil, ctx)
+	if err != nil || !ok {
+		t.Fatalf("getting DNSSEC keys: expected found and no error, got %t %v", ok, err)
+	}
+	if !reflect.DeepEqual(actual, keys) {
+		t.Errorf("DNSSEC keys: expected %+v, got %+v", keys, actual)
+	}
+	if err := f.DeleteDNSSECKeys("cdn1", nil, ctx); err != nil {
+		t.Fatalf("deleting DNSSEC keys: %v", err)
+	}
+	if _, ok, err := f.GetDNSSECKeys("cdn1", nil, ctx); err != nil || ok {
+		t.Errorf("getting deleted DNSSEC keys: expected not found and no error, got %t %v", ok, err)
+	}
+	if err := f.DeleteDNSSECKeys("cdn1", nil, ctx); err != nil {
+		t.Errorf("deleting nonexistent DNSSEC keys: expected no error, got %v", err)
+	}
+}
+
+func TestFilesystemURLSigAndURISigningKeys(t *testing.T) {
+	f, cleanup := newTestFilesystem(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	urlKeys := tc.URLSigKeys{"key0": "abc", "key1": "def"}
+	if err := f.PutURLSigKeys("ds1", urlKeys, nil, ctx); err != nil {
+		t.Fatalf("putting URL sig keys: %v", err)
+	}
+	actualURLKeys, ok, err := f.GetURLSigKeys("ds1", nil, ctx)
+	if err != nil || !ok {
+		t.Fatalf("getting URL sig keys: expected found and no error, got %t %v", ok, err)
+	}
+	if !reflect.DeepEqual(actualURLKeys, urlKeys) {
+		t.Errorf("URL sig keys: expected %+v, got %+v", urlKeys, actualURLKeys)
+	}
+
+	uriKeys := []byte(`{"ds1":{"renewal_kid":"k","keys":[{"alg":"HS256","kid":"k","kty":"oct","k":"secret"}]}}`)
+	if err := f.PutURISigningKeys("ds1", uriKeys, nil, ctx); err != nil {
+		t.Fatalf("putting URI signing keys: %v", err)
+	}
+	actualURIKeys, ok, err := f.GetURISigningKeys("ds1", nil, ctx)
+	if err != nil || !ok {
+		t.Fatalf("getting URI signing keys: expected found and no error, got %t %v", ok, err)
+	}
+	if !bytes.Equal(actualURIKeys, uriKeys) {
+		t.Errorf("URI signing keys: expected %s, got %s", uriKeys, actualURIKeys)
+	}
+
+	if err := f.DeleteURLSigKeys("ds1", nil, ctx); err != nil {
+		t.Fatalf("deleting URL sig keys: %v", err)
+	}
+	if _, ok, err := f.GetURLSigKeys("ds1", nil, ctx); err != nil || ok {
+		t.Errorf("getting deleted URL sig keys: expected not found and no error, got %t %v", ok, err)
+	}
+	if err := f.DeleteURISigningKeys("ds1", nil, ctx); err != nil {
+		t.Fatalf("deleting URI signing keys: %v", err)
+	}
+	if _, ok, err := f.GetURISigningKeys("ds1", nil, ctx); err != nil || ok {
+		t.Errorf("getting deleted URI signing keys: expected not found and no error, got %t %v", ok, err)
+	}
+}
+
+func TestFilesystemPing(t *testing.T) {
+	f, cleanup := newTestFilesystem(t)
+	defer cleanup()
+
+	ping, err := f.Ping(nil, context.Background())
+	if err != nil {
+		t.Fatalf("pinging: %v", err)
+	}
+	if ping.Status != "OK" || ping.Server != f.cfg.Directory {
+		t.Errorf("expected ping status OK from '%s', got %+v", f.cfg.Directory, ping)
+	}
+}
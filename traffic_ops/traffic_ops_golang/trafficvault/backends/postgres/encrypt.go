@@ -34,11 +34,7 @@ import (
 func readKey(cfg Config) ([]byte, error) {
 	var keyBase64 string
 	if cfg.AesKeyLocation != "" {
-		keyBase64Bytes, err := ioutil.ReadFile(cfg.AesKeyLocation)
-		if err != nil {
-			return []byte{}, errors.New("reading file '" + cfg.AesKeyLocation + "':" + err.Error())
-		}
-		keyBase64 = string(keyBase64Bytes)
+		return readKeyFile(cfg.AesKeyLocation)
 	} else {
 		hashiVault := hashicorpvault.NewClient(
 			cfg.HashiCorpVault.Address,
@@ -58,7 +54,20 @@ func readKey(cfg Config) ([]byte, error) {
 		}
 		keyBase64 = key
 	}
+	return decodeKey(keyBase64)
+}
+
+// readKeyFile reads the AES key (encoded in base64) in the given on-disk file.
+func readKeyFile(location string) ([]byte, error) {
+	keyBase64Bytes, err := ioutil.ReadFile(location)
+	if err != nil {
+		return []byte{}, errors.New("reading file '" + location + "':" + err.Error())
+	}
+	return decodeKey(string(keyBase64Bytes))
+}
 
+// decodeKey decodes the given base64-encoded AES key, and verifies that it's a valid key.
+func decodeKey(keyBase64 string) ([]byte, error) {
 	key, err := base64.StdEncoding.DecodeString(keyBase64)
 	if err != nil {
 		return []byte{}, errors.New("AES key cannot be decoded from base64")
@@ -0,0 +1,351 @@
+package postgres
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// secretTables are the tables which hold encrypted secrets. Each has a data
+// column holding a secret and a key_id column holding the ID of the key which
+// encrypted it.
+var secretTables = []string{"dnssec", "sslkey", "uri_signing_key", "url_sig_key"}
+
+// allSecretsQuery selects the key_id of every stored secret.
+const allSecretsQuery = `
+SELECT key_id FROM dnssec
+UNION ALL SELECT key_id FROM sslkey
+UNION ALL SELECT key_id FROM uri_signing_key
+UNION ALL SELECT key_id FROM url_sig_key
+`
+
+// keyRing holds the AES keys a Postgres Traffic Vault may use, by ID. The
+// current key encrypts all new secrets; the others are only used to decrypt
+// secrets which haven't yet been re-encrypted with the current key.
+type keyRing struct {
+	currentID string
+	// ids are the IDs of the configured keys, current first.
+	ids  []string
+	keys map[string][]byte
+
+	retiredMutex sync.RWMutex
+	retired      map[string]struct{}
+}
+
+func newKeyRing(currentID string, currentKey []byte) *keyRing {
+	return &keyRing{
+		currentID: currentID,
+		ids:       []string{currentID},
+		keys:      map[string][]byte{currentID: currentKey},
+		retired:   map[string]struct{}{},
+	}
+}
+
+// add adds a key which may be used to decrypt secrets.
+func (k *keyRing) add(id string, key []byte) {
+	k.ids = append(k.ids, id)
+	k.keys[id] = key
+}
+
+func (k *keyRing) isRetired(id string) bool {
+	k.retiredMutex.RLock()
+	defer k.retiredMutex.RUnlock()
+	_, ok := k.retired[id]
+	return ok
+}
+
+func (k *keyRing) retire(id string) {
+	k.retiredMutex.Lock()
+	defer k.retiredMutex.Unlock()
+	k.retired[id] = struct{}{}
+}
+
+// key returns the key with the given ID, unless it isn't configured or has
+// been retired.
+func (k *keyRing) key(id string) ([]byte, bool) {
+	key, ok := k.keys[id]
+	if !ok || k.isRetired(id) {
+		return nil, false
+	}
+	return key, true
+}
+
+// encrypt encrypts the given data with the current key, returning the
+// encrypted data and the ID of the key, to be stored with it.
+func (k *keyRing) encrypt(data []byte) ([]byte, string, error) {
+	encrypted, err := util.AESEncrypt(data, k.keys[k.currentID])
+	if err != nil {
+		return nil, "", err
+	}
+	return encrypted, k.currentID, nil
+}
+
+// decrypt decrypts the given data with the key identified by keyID. Secrets
+// stored before key IDs were recorded have no key ID, and are tried with each
+// configured key in turn; AES-GCM authenticates the data, so a wrong key
+// can't silently produce garbage.
+func (k *keyRing) decrypt(data []byte, keyID sql.NullString) ([]byte, error) {
+	if keyID.Valid {
+		key, ok := k.key(keyID.String)
+		if !ok {
+			return nil, fmt.Errorf("no usable AES key with ID '%s' is configured", keyID.String)
+		}
+		return util.AESDecrypt(data, key)
+	}
+	err := errors.New("no usable AES keys are configured")
+	for _, id := range k.ids {
+		key, ok := k.key(id)
+		if !ok {
+			continue
+		}
+		var decrypted []byte
+		if decrypted, err = util.AESDecrypt(data, key); err == nil {
+			return decrypted, nil
+		}
+	}
+	return nil, err
+}
+
+// loadRetiredKeys reads the IDs of the retired keys from the database.
+func (k *keyRing) loadRetiredKeys(db *sqlx.DB, ctx context.Context) error {
+	rows, err := db.QueryContext(ctx, "SELECT id FROM retired_encryption_key")
+	if err != nil {
+		return errors.New("querying retired encryption keys: " + err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		id := ""
+		if err := rows.Scan(&id); err != nil {
+			return errors.New("scanning retired encryption keys: " + err.Error())
+		}
+		k.retire(id)
+	}
+	return rows.Err()
+}
+
+// GetEncryptionKeys returns every encryption key the backend knows of,
+// along with the number of stored secrets encrypted with each.
+func (p *Postgres) GetEncryptionKeys(ctx context.Context) (tc.TrafficVaultEncryptionKeys, error) {
+	tvTx, dbCtx, cancelFunc, err := p.beginTransaction(ctx)
+	if err != nil {
+		return tc.TrafficVaultEncryptionKeys{}, err
+	}
+	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
+
+	keys := tc.TrafficVaultEncryptionKeys{Keys: []tc.TrafficVaultEncryptionKey{}}
+	counts := map[string]int{}
+	rows, err := tvTx.Query("SELECT key_id, count(*) FROM (" + allSecretsQuery + ") AS secrets GROUP BY key_id")
+	if err != nil {
+		return keys, checkErrWithContext("Traffic Vault PostgreSQL: executing SELECT encryption key counts query", err, ctx.Err())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		keyID := sql.NullString{}
+		count := 0
+		if err := rows.Scan(&keyID, &count); err != nil {
+			return keys, checkErrWithContext("Traffic Vault PostgreSQL: scanning encryption key counts", err, ctx.Err())
+		}
+		if keyID.Valid {
+			counts[keyID.String] = count
+		} else {
+			keys.UnidentifiedSecrets = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return keys, checkErrWithContext("Traffic Vault PostgreSQL: iterating encryption key counts", err, ctx.Err())
+	}
+
+	retired := map[string]struct{}{}
+	retiredRows, err := tvTx.Query("SELECT id FROM retired_encryption_key ORDER BY id")
+	if err != nil {
+		return keys, checkErrWithContext("Traffic Vault PostgreSQL: executing SELECT retired encryption keys query", err, ctx.Err())
+	}
+	defer retiredRows.Close()
+	retiredIDs := []string{}
+	for retiredRows.Next() {
+		id := ""
+		if err := retiredRows.Scan(&id); err != nil {
+			return keys, checkErrWithContext("Traffic Vault PostgreSQL: scanning retired encryption keys", err, ctx.Err())
+		}
+		retired[id] = struct{}{}
+		retiredIDs = append(retiredIDs, id)
+	}
+
+	seen := map[string]struct{}{}
+	add := func(id string) {
+		if _, ok := seen[id]; ok {
+			return
+		}
+		seen[id] = struct{}{}
+		_, configured := p.keys.keys[id]
+		_, isRetired := retired[id]
+		keys.Keys = append(keys.Keys, tc.TrafficVaultEncryptionKey{
+			ID:         id,
+			Current:    id == p.keys.currentID,
+			Configured: configured,
+			Retired:    isRetired,
+			Secrets:    counts[id],
+		})
+	}
+	for _, id := range p.keys.ids {
+		add(id)
+	}
+	// keys which aren't configured, but still encrypt secrets or were retired
+	for id := range counts {
+		if _, ok := p.keys.keys[id]; !ok {
+			add(id)
+		}
+	}
+	for _, id := range retiredIDs {
+		add(id)
+	}
+	return keys, nil
+}
+
+// CountStaleSecrets returns the number of stored secrets which are not
+// encrypted with the current key.
+func (p *Postgres) CountStaleSecrets(ctx context.Context) (int, error) {
+	tvTx, dbCtx, cancelFunc, err := p.beginTransaction(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
+
+	count := 0
+	if err := tvTx.QueryRow("SELECT count(*) FROM ("+allSecretsQuery+") AS secrets WHERE key_id IS DISTINCT FROM $1", p.keys.currentID).Scan(&count); err != nil {
+		return 0, checkErrWithContext("Traffic Vault PostgreSQL: executing SELECT stale secret count query", err, ctx.Err())
+	}
+	return count, nil
+}
+
+// ReencryptSecrets re-encrypts up to limit stored secrets which are not
+// encrypted with the current key, returning how many it re-encrypted.
+func (p *Postgres) ReencryptSecrets(limit int, ctx context.Context) (int, error) {
+	total := 0
+	for _, table := range secretTables {
+		if total >= limit {
+			break
+		}
+		n, err := p.reencryptTable(table, limit-total, ctx)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// reencryptTable re-encrypts up to limit secrets in the given table, in a
+// transaction of its own. Rows are locked with SKIP LOCKED, so concurrent
+// re-encryption (e.g. by multiple Traffic Ops instances) never conflicts.
+func (p *Postgres) reencryptTable(table string, limit int, ctx context.Context) (int, error) {
+	tvTx, dbCtx, cancelFunc, err := p.beginTransaction(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer cancelFunc()
+	n, err := reencryptRows(tvTx, table, limit, p.keys)
+	if err != nil {
+		if rbErr := tvTx.Rollback(); rbErr != nil {
+			err = fmt.Errorf("%v (rolling back: %v)", err, rbErr)
+		}
+		return 0, checkErrWithContext("Traffic Vault PostgreSQL: re-encrypting "+table, err, dbCtx.Err())
+	}
+	if err := tvTx.Commit(); err != nil {
+		return 0, checkErrWithContext("Traffic Vault PostgreSQL: committing re-encryption of "+table, err, dbCtx.Err())
+	}
+	return n, nil
+}
+
+type encryptedRow struct {
+	ctid  string
+	data  []byte
+	keyID sql.NullString
+}
+
+func reencryptRows(tvTx *sqlx.Tx, table string, limit int, keys *keyRing) (int, error) {
+	rows, err := tvTx.Query("SELECT ctid::text, data, key_id FROM "+table+" WHERE key_id IS DISTINCT FROM $1 LIMIT $2 FOR UPDATE SKIP LOCKED", keys.currentID, limit)
+	if err != nil {
+		return 0, err
+	}
+	toUpdate := []encryptedRow{}
+	for rows.Next() {
+		row := encryptedRow{}
+		if err := rows.Scan(&row.ctid, &row.data, &row.keyID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		toUpdate = append(toUpdate, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, row := range toUpdate {
+		decrypted, err := keys.decrypt(row.data, row.keyID)
+		if err != nil {
+			return 0, errors.New("decrypting secret: " + err.Error())
+		}
+		encrypted, keyID, err := keys.encrypt(decrypted)
+		if err != nil {
+			return 0, errors.New("encrypting secret: " + err.Error())
+		}
+		if _, err := tvTx.Exec("UPDATE "+table+" SET data = $1, key_id = $2 WHERE ctid = $3::tid", encrypted, keyID, row.ctid); err != nil {
+			return 0, err
+		}
+	}
+	return len(toUpdate), nil
+}
+
+// RetireEncryptionKey retires the encryption key identified by the given
+// ID, after which the backend never uses it.
+func (p *Postgres) RetireEncryptionKey(id string, ctx context.Context) error {
+	if id == p.keys.currentID {
+		return errors.New("the current encryption key cannot be retired")
+	}
+	tvTx, dbCtx, cancelFunc, err := p.beginTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
+
+	count := 0
+	if err := tvTx.QueryRow("SELECT count(*) FROM ("+allSecretsQuery+") AS secrets WHERE key_id = $1 OR key_id IS NULL", id).Scan(&count); err != nil {
+		return checkErrWithContext("Traffic Vault PostgreSQL: executing SELECT encryption key references query", err, ctx.Err())
+	}
+	if count > 0 {
+		return fmt.Errorf("encryption key '%s' may still be needed to decrypt %d secrets", id, count)
+	}
+	if _, err := tvTx.Exec("INSERT INTO retired_encryption_key (id) VALUES ($1) ON CONFLICT (id) DO NOTHING", id); err != nil {
+		return checkErrWithContext("Traffic Vault PostgreSQL: executing INSERT retired encryption key query", err, ctx.Err())
+	}
+	p.keys.retire(id)
+	return nil
+}
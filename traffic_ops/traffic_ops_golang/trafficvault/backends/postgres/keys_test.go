@@ -0,0 +1,142 @@
+package postgres
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/apache/trafficcontrol/lib/go-util"
+
+	"github.com/jmoiron/sqlx"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+var (
+	oldKey = []byte("0123456789abcdef0123456789abcdef")
+	newKey = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func TestKeyRingDecrypt(t *testing.T) {
+	keys := newKeyRing("new", newKey)
+	keys.add("old", oldKey)
+
+	encrypted, keyID, err := keys.encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("encrypting: %v", err)
+	}
+	if keyID != "new" {
+		t.Errorf("expected secrets to be encrypted with the current key 'new', actual: '%s'", keyID)
+	}
+	if decrypted, err := keys.decrypt(encrypted, sql.NullString{String: keyID, Valid: true}); err != nil {
+		t.Errorf("decrypting with key ID '%s': %v", keyID, err)
+	} else if string(decrypted) != "secret" {
+		t.Errorf("expected decrypted secret 'secret', actual: '%s'", decrypted)
+	}
+	if _, err := keys.decrypt(encrypted, sql.NullString{String: "old", Valid: true}); err == nil {
+		t.Error("expected an error decrypting a secret with the wrong key ID, actual: nil")
+	}
+	if _, err := keys.decrypt(encrypted, sql.NullString{String: "unknown", Valid: true}); err == nil {
+		t.Error("expected an error decrypting a secret with an unknown key ID, actual: nil")
+	}
+
+	legacy, err := util.AESEncrypt([]byte("legacy"), oldKey)
+	if err != nil {
+		t.Fatalf("encrypting: %v", err)
+	}
+	if decrypted, err := keys.decrypt(legacy, sql.NullString{}); err != nil {
+		t.Errorf("decrypting a secret without a key ID: %v", err)
+	} else if string(decrypted) != "legacy" {
+		t.Errorf("expected decrypted secret 'legacy', actual: '%s'", decrypted)
+	}
+
+	keys.retire("old")
+	if _, err := keys.decrypt(legacy, sql.NullString{}); err == nil {
+		t.Error("expected an error decrypting a secret encrypted with a retired key, actual: nil")
+	}
+	if _, err := keys.decrypt(legacy, sql.NullString{String: "old", Valid: true}); err == nil {
+		t.Error("expected an error decrypting a secret with a retired key ID, actual: nil")
+	}
+}
+
+func TestReencryptRows(t *testing.T) {
+	keys := newKeyRing("new", newKey)
+	keys.add("old", oldKey)
+
+	withID, err := util.AESEncrypt([]byte("with id"), oldKey)
+	if err != nil {
+		t.Fatalf("encrypting: %v", err)
+	}
+	withoutID, err := util.AESEncrypt([]byte("without id"), oldKey)
+	if err != nil {
+		t.Fatalf("encrypting: %v", err)
+	}
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	mock.ExpectBegin()
+	rows := sqlmock.NewRows([]string{"ctid", "data", "key_id"})
+	rows.AddRow("(0,1)", withID, "old")
+	rows.AddRow("(0,2)", withoutID, nil)
+	mock.ExpectQuery("SELECT ctid::text, data, key_id FROM sslkey WHERE key_id IS DISTINCT FROM").WithArgs("new", 10).WillReturnRows(rows)
+	mock.ExpectExec("UPDATE sslkey SET data").WithArgs(decryptsTo{keys, "with id"}, "new", "(0,1)").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE sslkey SET data").WithArgs(decryptsTo{keys, "without id"}, "new", "(0,2)").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Beginx()
+	if err != nil {
+		t.Fatalf("beginning transaction: %v", err)
+	}
+	n, err := reencryptRows(tx, "sslkey", 10, keys)
+	if err != nil {
+		t.Fatalf("re-encrypting rows: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 re-encrypted rows, actual: %d", n)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("committing: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// decryptsTo is a sqlmock.Argument matching data which the current key of
+// keys decrypts to the expected plaintext.
+type decryptsTo struct {
+	keys     *keyRing
+	expected string
+}
+
+func (d decryptsTo) Match(v driver.Value) bool {
+	data, ok := v.([]byte)
+	if !ok {
+		return false
+	}
+	decrypted, err := d.keys.decrypt(data, sql.NullString{String: d.keys.currentID, Valid: true})
+	return err == nil && string(decrypted) == d.expected
+}
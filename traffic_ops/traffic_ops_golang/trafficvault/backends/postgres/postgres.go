@@ -60,6 +60,8 @@ const (
 	defaultHashiCorpVaultTimeoutSec = 30
 
 	latestVersion = "latest"
+
+	defaultAesKeyID = "default"
 )
 
 type Config struct {
@@ -74,9 +76,18 @@ type Config struct {
 	ConnMaxLifetimeSeconds int             `json:"conn_max_lifetime_seconds"`
 	QueryTimeoutSeconds    int             `json:"query_timeout_seconds"`
 	AesKeyLocation         string          `json:"aes_key_location"`
+	AesKeyID               string          `json:"aes_key_id"`
+	PreviousAesKeys        []AesKey        `json:"previous_aes_keys"`
 	HashiCorpVault         *HashiCorpVault `json:"hashicorp_vault"`
 }
 
+// AesKey is an AES key, other than the current one, which may have encrypted
+// secrets that haven't yet been re-encrypted with the current key.
+type AesKey struct {
+	ID       string `json:"id"`
+	Location string `json:"location"`
+}
+
 type HashiCorpVault struct {
 	Address    string `json:"address"`
 	RoleID     string `json:"role_id"`
@@ -88,9 +99,9 @@ type HashiCorpVault struct {
 }
 
 type Postgres struct {
-	cfg    Config
-	db     *sqlx.DB
-	aesKey []byte
+	cfg  Config
+	db   *sqlx.DB
+	keys *keyRing
 }
 
 func checkErrWithContext(prefix string, err error, ctxErr error) error {
@@ -130,11 +141,12 @@ func (p *Postgres) GetDeliveryServiceSSLKeys(xmlID string, version string, tx *s
 	}
 	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
 	var encryptedSslKeys []byte
-	query := "SELECT data FROM sslkey WHERE deliveryservice=$1 AND version=$2"
+	var keyID sql.NullString
+	query := "SELECT data, key_id FROM sslkey WHERE deliveryservice=$1 AND version=$2"
 	if version == "" {
 		version = "latest"
 	}
-	err = tvTx.QueryRow(query, xmlID, version).Scan(&encryptedSslKeys)
+	err = tvTx.QueryRow(query, xmlID, version).Scan(&encryptedSslKeys, &keyID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return tc.DeliveryServiceSSLKeysV15{}, false, nil
@@ -143,7 +155,7 @@ func (p *Postgres) GetDeliveryServiceSSLKeys(xmlID string, version string, tx *s
 		return tc.DeliveryServiceSSLKeysV15{}, false, e
 	}
 
-	jsonKeys, err := util.AESDecrypt(encryptedSslKeys, p.aesKey)
+	jsonKeys, err := p.keys.decrypt(encryptedSslKeys, keyID)
 	if err != nil {
 		return tc.DeliveryServiceSSLKeysV15{}, false, err
 	}
@@ -176,13 +188,13 @@ func (p *Postgres) PutDeliveryServiceSSLKeys(key tc.DeliveryServiceSSLKeys, tx *
 		return e
 	}
 
-	encryptedKey, err := util.AESEncrypt(keyJSON, p.aesKey)
+	encryptedKey, keyID, err := p.keys.encrypt(keyJSON)
 	if err != nil {
 		return errors.New("encrypting keys: " + err.Error())
 	}
 
 	// insert the new ssl keys now
-	res, err := tvTx.Exec("INSERT INTO sslkey (deliveryservice, data, cdn, version) VALUES ($1, $2, $3, $4), ($5, $6, $7, $8)", key.DeliveryService, encryptedKey, key.CDN, strconv.FormatInt(int64(key.Version), 10), key.DeliveryService, encryptedKey, key.CDN, latestVersion)
+	res, err := tvTx.Exec("INSERT INTO sslkey (deliveryservice, data, cdn, version, key_id) VALUES ($1, $2, $3, $4, $5), ($1, $2, $3, $6, $5)", key.DeliveryService, encryptedKey, key.CDN, strconv.FormatInt(int64(key.Version), 10), keyID, latestVersion)
 	if err != nil {
 		e := checkErrWithContext("Traffic Vault PostgreSQL: executing INSERT SSL Key query", err, ctx.Err())
 		return e
@@ -254,7 +266,7 @@ func (p *Postgres) GetCDNSSLKeys(cdnName string, tx *sql.Tx, ctx context.Context
 	}
 	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
 
-	rows, err := tvTx.Query("SELECT data from sslkey WHERE cdn=$1 AND version=$2", cdnName, latestVersion)
+	rows, err := tvTx.Query("SELECT data, key_id from sslkey WHERE cdn=$1 AND version=$2", cdnName, latestVersion)
 	if err != nil {
 		e := checkErrWithContext("Traffic Vault PostgreSQL: executing GET SSL Keys for CDN query", err, ctx.Err())
 		return keys, e
@@ -262,12 +274,13 @@ func (p *Postgres) GetCDNSSLKeys(cdnName string, tx *sql.Tx, ctx context.Context
 	defer rows.Close()
 	for rows.Next() {
 		encryptedSslKeys := []byte{}
-		if err := rows.Scan(&encryptedSslKeys); err != nil {
+		keyID := sql.NullString{}
+		if err := rows.Scan(&encryptedSslKeys, &keyID); err != nil {
 			e := checkErrWithContext("Traffic Vault PostgreSQL: scanning CDN SSL keys", err, ctx.Err())
 			return keys, e
 		}
 
-		jsonKey, err := util.AESDecrypt(encryptedSslKeys, p.aesKey)
+		jsonKey, err := p.keys.decrypt(encryptedSslKeys, keyID)
 		if err != nil {
 			log.Errorf("couldn't decrypt key: %v", err)
 			continue
@@ -290,7 +303,8 @@ func (p *Postgres) GetDNSSECKeys(cdnName string, tx *sql.Tx, ctx context.Context
 	}
 	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
 	var encryptedDnssecKey []byte
-	if err := tvTx.QueryRow("SELECT data FROM dnssec WHERE cdn = $1", cdnName).Scan(&encryptedDnssecKey); err != nil {
+	var keyID sql.NullString
+	if err := tvTx.QueryRow("SELECT data, key_id FROM dnssec WHERE cdn = $1", cdnName).Scan(&encryptedDnssecKey, &keyID); err != nil {
 		if err == sql.ErrNoRows {
 			return tc.DNSSECKeysTrafficVault{}, false, nil
 		}
@@ -298,7 +312,7 @@ func (p *Postgres) GetDNSSECKeys(cdnName string, tx *sql.Tx, ctx context.Context
 		return tc.DNSSECKeysTrafficVault{}, false, e
 	}
 
-	dnssecJSON, err := util.AESDecrypt(encryptedDnssecKey, p.aesKey)
+	dnssecJSON, err := p.keys.decrypt(encryptedDnssecKey, keyID)
 	if err != nil {
 		return tc.DNSSECKeysTrafficVault{}, false, err
 	}
@@ -326,12 +340,12 @@ func (p *Postgres) PutDNSSECKeys(cdnName string, keys tc.DNSSECKeysTrafficVault,
 		return e
 	}
 
-	encryptedKey, err := util.AESEncrypt(dnssecJSON, p.aesKey)
+	encryptedKey, keyID, err := p.keys.encrypt(dnssecJSON)
 	if err != nil {
 		return errors.New("encrypting keys: " + err.Error())
 	}
 
-	res, err := tvTx.Exec("INSERT INTO dnssec (cdn, data) VALUES ($1, $2)", cdnName, encryptedKey)
+	res, err := tvTx.Exec("INSERT INTO dnssec (cdn, data, key_id) VALUES ($1, $2, $3)", cdnName, encryptedKey, keyID)
 	if err != nil {
 		e := checkErrWithContext("Traffic Vault PostgreSQL: executing INSERT DNSSEC keys query", err, ctx.Err())
 		return e
@@ -364,7 +378,7 @@ func (p *Postgres) GetURLSigKeys(xmlID string, tx *sql.Tx, ctx context.Context)
 		return tc.URLSigKeys{}, false, err
 	}
 	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
-	return getURLSigKeys(xmlID, tvTx, ctx, p.aesKey)
+	return getURLSigKeys(xmlID, tvTx, ctx, p.keys)
 }
 
 func (p *Postgres) PutURLSigKeys(xmlID string, keys tc.URLSigKeys, tx *sql.Tx, ctx context.Context) error {
@@ -374,7 +388,7 @@ func (p *Postgres) PutURLSigKeys(xmlID string, keys tc.URLSigKeys, tx *sql.Tx, c
 	}
 	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
 
-	return putURLSigKeys(xmlID, tvTx, keys, ctx, p.aesKey)
+	return putURLSigKeys(xmlID, tvTx, keys, ctx, p.keys)
 }
 
 func (p *Postgres) DeleteURLSigKeys(xmlID string, tx *sql.Tx, ctx context.Context) error {
@@ -393,7 +407,7 @@ func (p *Postgres) GetURISigningKeys(xmlID string, tx *sql.Tx, ctx context.Conte
 		return []byte{}, false, err
 	}
 	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
-	return getURISigningKeys(xmlID, tvTx, ctx, p.aesKey)
+	return getURISigningKeys(xmlID, tvTx, ctx, p.keys)
 }
 
 func (p *Postgres) PutURISigningKeys(xmlID string, keysJson []byte, tx *sql.Tx, ctx context.Context) error {
@@ -403,7 +417,7 @@ func (p *Postgres) PutURISigningKeys(xmlID string, keysJson []byte, tx *sql.Tx,
 	}
 	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
 
-	return putURISigningKeys(xmlID, tvTx, keysJson, ctx, p.aesKey)
+	return putURISigningKeys(xmlID, tvTx, keysJson, ctx, p.keys)
 }
 
 func (p *Postgres) DeleteURISigningKeys(xmlID string, tx *sql.Tx, ctx context.Context) error {
@@ -458,6 +472,9 @@ func postgresLoad(b json.RawMessage) (trafficvault.TrafficVault, error) {
 	if pgCfg.QueryTimeoutSeconds == 0 {
 		pgCfg.QueryTimeoutSeconds = defaultDBQueryTimeoutSecs
 	}
+	if pgCfg.AesKeyID == "" {
+		pgCfg.AesKeyID = defaultAesKeyID
+	}
 	if pgCfg.HashiCorpVault != nil {
 		if pgCfg.HashiCorpVault.LoginPath == "" {
 			pgCfg.HashiCorpVault.LoginPath = defaultHashiCorpVaultLoginPath
@@ -479,6 +496,19 @@ func postgresLoad(b json.RawMessage) (trafficvault.TrafficVault, error) {
 	db.SetMaxIdleConns(pgCfg.MaxIdleConnections)
 	db.SetConnMaxLifetime(time.Duration(pgCfg.ConnMaxLifetimeSeconds) * time.Second)
 
+	aesKey, err := readKey(pgCfg)
+	if err != nil {
+		return nil, err
+	}
+	keys := newKeyRing(pgCfg.AesKeyID, aesKey)
+	for _, previous := range pgCfg.PreviousAesKeys {
+		previousKey, err := readKeyFile(previous.Location)
+		if err != nil {
+			return nil, fmt.Errorf("reading previous AES key '%s': %w", previous.ID, err)
+		}
+		keys.add(previous.ID, previousKey)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(pgCfg.QueryTimeoutSeconds)*time.Second)
 	defer cancel()
 	if err := db.PingContext(ctx); err != nil {
@@ -486,14 +516,14 @@ func postgresLoad(b json.RawMessage) (trafficvault.TrafficVault, error) {
 		log.Errorln("pinging the Traffic Vault database: " + err.Error())
 	} else {
 		log.Infoln("successfully pinged the Traffic Vault database")
+		if err := keys.loadRetiredKeys(db, ctx); err != nil {
+			log.Errorln("loading retired Traffic Vault encryption keys: " + err.Error())
+		} else if keys.isRetired(pgCfg.AesKeyID) {
+			return nil, fmt.Errorf("the AES key '%s' has been retired, and cannot be the current key", pgCfg.AesKeyID)
+		}
 	}
 
-	aesKey, err := readKey(pgCfg)
-	if err != nil {
-		return nil, err
-	}
-
-	return &Postgres{cfg: pgCfg, db: db, aesKey: aesKey}, nil
+	return &Postgres{cfg: pgCfg, db: db, keys: keys}, nil
 }
 
 func validateConfig(cfg Config) error {
@@ -521,6 +551,22 @@ func validateConfig(cfg Config) error {
 	} else if !aesKeyLocSet {
 		errs = append(errs, errors.New("one of either aes_key_location or hashicorp_vault is required"))
 	}
+	currentID := cfg.AesKeyID
+	if currentID == "" {
+		currentID = defaultAesKeyID
+	}
+	keyIDs := map[string]struct{}{currentID: {}}
+	for i, previous := range cfg.PreviousAesKeys {
+		if previous.ID == "" {
+			errs = append(errs, fmt.Errorf("previous_aes_keys[%d]: id is required", i))
+		} else if _, ok := keyIDs[previous.ID]; ok {
+			errs = append(errs, fmt.Errorf("previous_aes_keys[%d]: duplicate key id '%s'", i, previous.ID))
+		}
+		if previous.Location == "" {
+			errs = append(errs, fmt.Errorf("previous_aes_keys[%d]: location is required", i))
+		}
+		keyIDs[previous.ID] = struct{}{}
+	}
 	if len(errs) == 0 {
 		return nil
 	}
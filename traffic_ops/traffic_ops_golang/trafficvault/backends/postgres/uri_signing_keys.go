@@ -24,14 +24,13 @@ import (
 	"database/sql"
 	"errors"
 
-	"github.com/apache/trafficcontrol/lib/go-util"
-
 	"github.com/jmoiron/sqlx"
 )
 
-func getURISigningKeys(xmlID string, tvTx *sqlx.Tx, ctx context.Context, aesKey []byte) ([]byte, bool, error) {
+func getURISigningKeys(xmlID string, tvTx *sqlx.Tx, ctx context.Context, keys *keyRing) ([]byte, bool, error) {
 	var encryptedUriSigningKey []byte
-	if err := tvTx.QueryRow("SELECT data FROM uri_signing_key WHERE deliveryservice = $1", xmlID).Scan(&encryptedUriSigningKey); err != nil {
+	var keyID sql.NullString
+	if err := tvTx.QueryRow("SELECT data, key_id FROM uri_signing_key WHERE deliveryservice = $1", xmlID).Scan(&encryptedUriSigningKey, &keyID); err != nil {
 		if err == sql.ErrNoRows {
 			return []byte{}, false, nil
 		}
@@ -39,7 +38,7 @@ func getURISigningKeys(xmlID string, tvTx *sqlx.Tx, ctx context.Context, aesKey
 		return []byte{}, false, e
 	}
 
-	jsonUriKeys, err := util.AESDecrypt(encryptedUriSigningKey, aesKey)
+	jsonUriKeys, err := keys.decrypt(encryptedUriSigningKey, keyID)
 	if err != nil {
 		return []byte{}, false, err
 	}
@@ -47,18 +46,18 @@ func getURISigningKeys(xmlID string, tvTx *sqlx.Tx, ctx context.Context, aesKey
 	return jsonUriKeys, true, nil
 }
 
-func putURISigningKeys(xmlID string, tvTx *sqlx.Tx, keys []byte, ctx context.Context, aesKey []byte) error {
+func putURISigningKeys(xmlID string, tvTx *sqlx.Tx, keys []byte, ctx context.Context, aesKeys *keyRing) error {
 	// Delete old keys first if they exist
 	if err := deleteURISigningKeys(xmlID, tvTx, ctx); err != nil {
 		return err
 	}
 
-	encryptedKey, err := util.AESEncrypt(keys, aesKey)
+	encryptedKey, keyID, err := aesKeys.encrypt(keys)
 	if err != nil {
 		return errors.New("encrypting keys: " + err.Error())
 	}
 
-	res, err := tvTx.Exec("INSERT INTO uri_signing_key (deliveryservice, data) VALUES ($1, $2)", xmlID, encryptedKey)
+	res, err := tvTx.Exec("INSERT INTO uri_signing_key (deliveryservice, data, key_id) VALUES ($1, $2, $3)", xmlID, encryptedKey, keyID)
 	if err != nil {
 		e := checkErrWithContext("Traffic Vault PostgreSQL: executing INSERT URI Sig Keys query", err, ctx.Err())
 		return e
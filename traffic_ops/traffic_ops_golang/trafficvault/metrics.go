@@ -0,0 +1,159 @@
+package trafficvault
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/metrics"
+)
+
+// WithMetrics returns a TrafficVault which calls the given backend, and records
+// the duration of each call in metrics.TrafficVaultDuration.
+func WithMetrics(tv TrafficVault) TrafficVault {
+	return &instrumented{tv: tv}
+}
+
+// instrumented is a TrafficVault which records the duration of the calls made
+// to another TrafficVault.
+type instrumented struct {
+	tv TrafficVault
+}
+
+// observe records the duration of a call of the given method which began at
+// start and returned err.
+func observe(method string, start time.Time, err error) {
+	metrics.TrafficVaultDuration.ObserveSince(start, method, metrics.Result(err))
+}
+
+func (i *instrumented) GetDeliveryServiceSSLKeys(xmlID string, version string, tx *sql.Tx, ctx context.Context) (tc.DeliveryServiceSSLKeysV15, bool, error) {
+	start := time.Now()
+	keys, ok, err := i.tv.GetDeliveryServiceSSLKeys(xmlID, version, tx, ctx)
+	observe("GetDeliveryServiceSSLKeys", start, err)
+	return keys, ok, err
+}
+
+func (i *instrumented) PutDeliveryServiceSSLKeys(key tc.DeliveryServiceSSLKeys, tx *sql.Tx, ctx context.Context) error {
+	start := time.Now()
+	err := i.tv.PutDeliveryServiceSSLKeys(key, tx, ctx)
+	observe("PutDeliveryServiceSSLKeys", start, err)
+	return err
+}
+
+func (i *instrumented) DeleteDeliveryServiceSSLKeys(xmlID string, version string, tx *sql.Tx, ctx context.Context) error {
+	start := time.Now()
+	err := i.tv.DeleteDeliveryServiceSSLKeys(xmlID, version, tx, ctx)
+	observe("DeleteDeliveryServiceSSLKeys", start, err)
+	return err
+}
+
+func (i *instrumented) DeleteOldDeliveryServiceSSLKeys(existingXMLIDs map[string]struct{}, cdnName string, tx *sql.Tx, ctx context.Context) error {
+	start := time.Now()
+	err := i.tv.DeleteOldDeliveryServiceSSLKeys(existingXMLIDs, cdnName, tx, ctx)
+	observe("DeleteOldDeliveryServiceSSLKeys", start, err)
+	return err
+}
+
+func (i *instrumented) GetCDNSSLKeys(cdnName string, tx *sql.Tx, ctx context.Context) ([]tc.CDNSSLKey, error) {
+	start := time.Now()
+	keys, err := i.tv.GetCDNSSLKeys(cdnName, tx, ctx)
+	observe("GetCDNSSLKeys", start, err)
+	return keys, err
+}
+
+func (i *instrumented) GetDNSSECKeys(cdnName string, tx *sql.Tx, ctx context.Context) (tc.DNSSECKeysTrafficVault, bool, error) {
+	start := time.Now()
+	keys, ok, err := i.tv.GetDNSSECKeys(cdnName, tx, ctx)
+	observe("GetDNSSECKeys", start, err)
+	return keys, ok, err
+}
+
+func (i *instrumented) PutDNSSECKeys(cdnName string, keys tc.DNSSECKeysTrafficVault, tx *sql.Tx, ctx context.Context) error {
+	start := time.Now()
+	err := i.tv.PutDNSSECKeys(cdnName, keys, tx, ctx)
+	observe("PutDNSSECKeys", start, err)
+	return err
+}
+
+func (i *instrumented) DeleteDNSSECKeys(cdnName string, tx *sql.Tx, ctx context.Context) error {
+	start := time.Now()
+	err := i.tv.DeleteDNSSECKeys(cdnName, tx, ctx)
+	observe("DeleteDNSSECKeys", start, err)
+	return err
+}
+
+func (i *instrumented) GetURLSigKeys(xmlID string, tx *sql.Tx, ctx context.Context) (tc.URLSigKeys, bool, error) {
+	start := time.Now()
+	keys, ok, err := i.tv.GetURLSigKeys(xmlID, tx, ctx)
+	observe("GetURLSigKeys", start, err)
+	return keys, ok, err
+}
+
+func (i *instrumented) PutURLSigKeys(xmlID string, keys tc.URLSigKeys, tx *sql.Tx, ctx context.Context) error {
+	start := time.Now()
+	err := i.tv.PutURLSigKeys(xmlID, keys, tx, ctx)
+	observe("PutURLSigKeys", start, err)
+	return err
+}
+
+func (i *instrumented) DeleteURLSigKeys(xmlID string, tx *sql.Tx, ctx context.Context) error {
+	start := time.Now()
+	err := i.tv.DeleteURLSigKeys(xmlID, tx, ctx)
+	observe("DeleteURLSigKeys", start, err)
+	return err
+}
+
+func (i *instrumented) GetURISigningKeys(xmlID string, tx *sql.Tx, ctx context.Context) ([]byte, bool, error) {
+	start := time.Now()
+	keys, ok, err := i.tv.GetURISigningKeys(xmlID, tx, ctx)
+	observe("GetURISigningKeys", start, err)
+	return keys, ok, err
+}
+
+func (i *instrumented) PutURISigningKeys(xmlID string, keysJson []byte, tx *sql.Tx, ctx context.Context) error {
+	start := time.Now()
+	err := i.tv.PutURISigningKeys(xmlID, keysJson, tx, ctx)
+	observe("PutURISigningKeys", start, err)
+	return err
+}
+
+func (i *instrumented) DeleteURISigningKeys(xmlID string, tx *sql.Tx, ctx context.Context) error {
+	start := time.Now()
+	err := i.tv.DeleteURISigningKeys(xmlID, tx, ctx)
+	observe("DeleteURISigningKeys", start, err)
+	return err
+}
+
+func (i *instrumented) Ping(tx *sql.Tx, ctx context.Context) (tc.TrafficVaultPing, error) {
+	start := time.Now()
+	ping, err := i.tv.Ping(tx, ctx)
+	observe("Ping", start, err)
+	return ping, err
+}
+
+func (i *instrumented) GetBucketKey(bucket string, key string, tx *sql.Tx) ([]byte, bool, error) {
+	start := time.Now()
+	val, ok, err := i.tv.GetBucketKey(bucket, key, tx)
+	observe("GetBucketKey", start, err)
+	return val, ok, err
+}
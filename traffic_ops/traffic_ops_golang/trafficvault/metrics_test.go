@@ -0,0 +1,59 @@
+package trafficvault
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/metrics"
+)
+
+// pingOnly is a TrafficVault which only implements Ping.
+type pingOnly struct {
+	TrafficVault
+	err error
+}
+
+func (p pingOnly) Ping(tx *sql.Tx, ctx context.Context) (tc.TrafficVaultPing, error) {
+	return tc.TrafficVaultPing{Status: "OK", Server: "vault.test"}, p.err
+}
+
+func TestWithMetrics(t *testing.T) {
+	before := metrics.TrafficVaultDuration.Count("Ping", "success")
+	ping, err := WithMetrics(pingOnly{}).Ping(nil, context.Background())
+	if err != nil || ping.Server != "vault.test" {
+		t.Errorf("expected the backend's ping response, actual: %+v, error: %v", ping, err)
+	}
+	if actual := metrics.TrafficVaultDuration.Count("Ping", "success"); actual != before+1 {
+		t.Errorf("expected a successful Ping to be observed, actual observations: %d", actual-before)
+	}
+
+	before = metrics.TrafficVaultDuration.Count("Ping", "error")
+	if _, err := WithMetrics(pingOnly{err: errors.New("down")}).Ping(nil, context.Background()); err == nil {
+		t.Error("expected the backend's error to be returned, actual: nil")
+	}
+	if actual := metrics.TrafficVaultDuration.Count("Ping", "error"); actual != before+1 {
+		t.Errorf("expected a failed Ping to be observed, actual observations: %d", actual-before)
+	}
+}
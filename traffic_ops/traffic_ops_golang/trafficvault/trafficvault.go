@@ -90,6 +90,40 @@ type TrafficVault interface {
 	GetBucketKey(bucket string, key string, tx *sql.Tx) ([]byte, bool, error)
 }
 
+// KeyRotator is implemented by Traffic Vault backends which encrypt secrets
+// with keys that can be rotated while Traffic Ops is running. Each secret is
+// stored with the ID of the key that encrypted it, new secrets are always
+// encrypted with the current key, and secrets encrypted with any other key
+// are re-encrypted with the current key in batches, after which the other
+// key may be retired.
+type KeyRotator interface {
+	// GetEncryptionKeys returns every encryption key the backend knows of,
+	// along with the number of stored secrets encrypted with each.
+	GetEncryptionKeys(ctx context.Context) (tc.TrafficVaultEncryptionKeys, error)
+	// CountStaleSecrets returns the number of stored secrets which are not
+	// encrypted with the current key.
+	CountStaleSecrets(ctx context.Context) (int, error)
+	// ReencryptSecrets re-encrypts up to limit stored secrets which are not
+	// encrypted with the current key, returning how many it re-encrypted.
+	// Each batch is committed on its own, so the backend remains usable
+	// throughout.
+	ReencryptSecrets(limit int, ctx context.Context) (int, error)
+	// RetireEncryptionKey retires the encryption key identified by the given
+	// ID, after which the backend never uses it. It returns an error if the
+	// key is current or may still be needed to decrypt any stored secret.
+	RetireEncryptionKey(id string, ctx context.Context) error
+}
+
+// GetKeyRotator returns the given TrafficVault as a KeyRotator, if its
+// backend supports key rotation.
+func GetKeyRotator(tv TrafficVault) (KeyRotator, bool) {
+	if i, ok := tv.(*instrumented); ok {
+		tv = i.tv
+	}
+	kr, ok := tv.(KeyRotator)
+	return kr, ok
+}
+
 var backends = make(map[string]LoadFunc)
 
 // A LoadFunc is a function that takes a json.RawMessage as input (the contents of
@@ -0,0 +1,180 @@
+package user
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+
+	"github.com/lib/pq"
+)
+
+const readTokensQuery = `
+SELECT id, name, routes, methods, expires, last_used, created
+FROM api_token
+WHERE tm_user = $1
+ORDER BY name
+`
+
+const insertTokenQuery = `
+INSERT INTO api_token (tm_user, name, token_hash, routes, methods, expires)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, expires, created
+`
+
+const deleteTokenQuery = `
+DELETE FROM api_token
+WHERE id = $1 AND tm_user = $2
+RETURNING id, name, routes, methods, expires, last_used, created
+`
+
+// tokenScanner is a *sql.Row or *sql.Rows.
+type tokenScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanToken scans an APIToken from the columns selected by readTokensQuery.
+func scanToken(row tokenScanner) (tc.APIToken, error) {
+	token := tc.APIToken{Methods: []string{}}
+	routes := []int64{}
+	if err := row.Scan(&token.ID, &token.Name, pq.Array(&routes), pq.Array(&token.Methods), &token.Expires, &token.LastUsed, &token.Created); err != nil {
+		return token, err
+	}
+	token.Routes = make([]int, 0, len(routes))
+	for _, route := range routes {
+		token.Routes = append(token.Routes, int(route))
+	}
+	return token, nil
+}
+
+// GetTokens is the handler for GET requests to /user/current/tokens. It
+// returns the personal access tokens of the current user, without their
+// secret values.
+func GetTokens(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	rows, err := tx.Query(readTokensQuery, inf.User.ID)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("querying tokens: "+err.Error()))
+		return
+	}
+	defer rows.Close()
+
+	tokens := []tc.APIToken{}
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("scanning tokens: "+err.Error()))
+			return
+		}
+		tokens = append(tokens, token)
+	}
+	api.WriteResp(w, r, tokens)
+}
+
+// CreateToken is the handler for POST requests to /user/current/tokens. The
+// secret value of the created token is only ever returned in its response.
+// Tokens can't be created by requests authenticated with a token, since the
+// new token could have a broader scope.
+func CreateToken(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	if inf.User.Token != nil {
+		api.HandleErr(w, r, tx, http.StatusForbidden, errors.New("tokens cannot be created by requests authenticated with a token"), nil)
+		return
+	}
+
+	req := tc.APITokenRequest{}
+	if err := api.Parse(r.Body, tx, &req); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	secret, hash, err := auth.GenerateAPIToken()
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	if req.Routes == nil {
+		req.Routes = []int{}
+	}
+	if req.Methods == nil {
+		req.Methods = []string{}
+	}
+	created := tc.APITokenCreated{
+		APIToken: tc.APIToken{Name: *req.Name, Routes: req.Routes, Methods: req.Methods},
+		Token:    secret,
+	}
+	if err := tx.QueryRow(insertTokenQuery, inf.User.ID, *req.Name, hash, pq.Array(req.Routes), pq.Array(req.Methods), *req.Expires).Scan(&created.ID, &created.Expires, &created.Created); err != nil {
+		userErr, sysErr, errCode := api.ParseDBError(err)
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "token was created. Its value will not be shown again.", created)
+	changeLogMsg := fmt.Sprintf("USER: %s, ID: %d, ACTION: %s personal access token %s (#%d)", inf.User.UserName, inf.User.ID, api.Created, created.Name, created.ID)
+	api.CreateChangeLogRawTx(api.ApiChange, changeLogMsg, inf.User, tx)
+}
+
+// DeleteToken is the handler for DELETE requests to
+// /user/current/tokens/{{ID}}, which revokes one of the current user's
+// personal access tokens.
+func DeleteToken(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+	id := inf.IntParams["id"]
+
+	token, err := scanToken(tx.QueryRow(deleteTokenQuery, id, inf.User.ID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no token exists by id %d", id), nil)
+			return
+		}
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("deleting token #%d: %w", id, err))
+		return
+	}
+
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "token was revoked.", token)
+	changeLogMsg := fmt.Sprintf("USER: %s, ID: %d, ACTION: Revoked personal access token %s (#%d)", inf.User.UserName, inf.User.ID, token.Name, token.ID)
+	api.CreateChangeLogRawTx(api.ApiChange, changeLogMsg, inf.User, tx)
+}
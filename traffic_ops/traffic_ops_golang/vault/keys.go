@@ -0,0 +1,214 @@
+package vault
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/asyncjob"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/trafficvault"
+)
+
+// reencryptJobType is the type of the asynchronous jobs which re-encrypt
+// Traffic Vault secrets with the current encryption key.
+const reencryptJobType = "trafficvault_reencrypt"
+
+// reencryptBatchSize is the number of secrets re-encrypted (and committed)
+// at a time by a trafficvault_reencrypt job.
+const reencryptBatchSize = 100
+
+// reencryptJobPayload is the payload of a trafficvault_reencrypt job.
+type reencryptJobPayload struct {
+	BatchSize int `json:"batchSize"`
+}
+
+func init() {
+	asyncjob.Register(reencryptJobType, runReencryptJob)
+}
+
+// getKeyRotator returns the Traffic Vault backend of the given request as a
+// KeyRotator, or an error and the HTTP status code to respond with.
+func getKeyRotator(inf *api.APIInfo) (trafficvault.KeyRotator, error, error, int) {
+	if !inf.Config.TrafficVaultEnabled {
+		return nil, nil, errors.New("Traffic Vault is not configured"), http.StatusInternalServerError
+	}
+	kr, ok := trafficvault.GetKeyRotator(inf.Vault)
+	if !ok {
+		return nil, errors.New("the configured Traffic Vault backend does not support encryption key rotation"), nil, http.StatusNotImplemented
+	}
+	return kr, nil, nil, http.StatusOK
+}
+
+// GetEncryptionKeys lists the Traffic Vault encryption keys, along with the
+// number of secrets encrypted with each.
+func GetEncryptionKeys(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	kr, userErr, sysErr, errCode := getKeyRotator(inf)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	keys, err := kr.GetEncryptionKeys(r.Context())
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("getting Traffic Vault encryption keys: "+err.Error()))
+		return
+	}
+	api.WriteResp(w, r, keys)
+}
+
+// ReencryptSecrets queues an asynchronous job which re-encrypts every
+// Traffic Vault secret not encrypted with the current encryption key.
+func ReencryptSecrets(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	if _, userErr, sysErr, errCode := getKeyRotator(inf); userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	payload := reencryptJobPayload{BatchSize: reencryptBatchSize}
+	status, err := asyncjob.Enqueue(inf.Tx.Tx, reencryptJobType, inf.User, payload, "Re-encryption of Traffic Vault secrets queued")
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("queueing Traffic Vault secret re-encryption: "+err.Error()))
+		return
+	}
+	asyncjob.WriteAccepted(w, r, status, "Re-encryption of Traffic Vault secrets")
+}
+
+// runReencryptJob is the asyncjob.Func which re-encrypts, in batches, every
+// Traffic Vault secret not encrypted with the current encryption key. Each
+// batch is committed by the backend as it goes, so Traffic Vault remains
+// usable while the job runs.
+func runReencryptJob(inf *api.APIInfo, job *asyncjob.Job) (string, error, error) {
+	p := reencryptJobPayload{}
+	if err := job.DecodePayload(&p); err != nil {
+		return "", nil, err
+	}
+	if p.BatchSize <= 0 {
+		p.BatchSize = reencryptBatchSize
+	}
+	kr, userErr, sysErr, _ := getKeyRotator(inf)
+	if userErr != nil || sysErr != nil {
+		return "", userErr, sysErr
+	}
+
+	total, err := kr.CountStaleSecrets(job.Context())
+	if err != nil {
+		return "", nil, errors.New("counting Traffic Vault secrets to re-encrypt: " + err.Error())
+	}
+	job.SetProgress(0, fmt.Sprintf("Re-encrypting %d secrets", total))
+
+	done := 0
+	for {
+		n, err := kr.ReencryptSecrets(p.BatchSize, job.Context())
+		if err != nil {
+			return "", nil, fmt.Errorf("re-encrypting Traffic Vault secrets after %d of %d: %w", done, total, err)
+		}
+		if n == 0 {
+			break
+		}
+		done += n
+		// secrets written with an old key during the job (by Traffic Ops instances
+		// not yet using the new key) can make done exceed the initial count
+		if done > total {
+			total = done
+		}
+		job.SetProgress(done*100/total, fmt.Sprintf("Re-encrypted %d of %d secrets", done, total))
+	}
+
+	api.CreateChangeLogRawTx(api.ApiChange, fmt.Sprintf("Re-encrypted %d Traffic Vault secrets with the current encryption key", done), inf.User, inf.Tx.Tx)
+	message := fmt.Sprintf("Successfully re-encrypted %d Traffic Vault secrets", done)
+	job.SetResult(map[string]int{"reencrypted": done})
+	return message, nil, nil
+}
+
+// RetireEncryptionKey retires a Traffic Vault encryption key, which is only
+// allowed once no stored secret may need it for decryption.
+func RetireEncryptionKey(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	kr, userErr, sysErr, errCode := getKeyRotator(inf)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+
+	id := inf.Params["id"]
+	keys, err := kr.GetEncryptionKeys(r.Context())
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("getting Traffic Vault encryption keys: "+err.Error()))
+		return
+	}
+	userErr, errCode = checkRetirable(keys, id)
+	if userErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, nil)
+		return
+	}
+
+	if err := kr.RetireEncryptionKey(id, r.Context()); err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("retiring Traffic Vault encryption key '"+id+"': "+err.Error()))
+		return
+	}
+	api.CreateChangeLogRawTx(api.ApiChange, "Traffic Vault encryption key: "+id+", ACTION: Retired", inf.User, inf.Tx.Tx)
+	api.WriteRespAlert(w, r, tc.SuccessLevel, "Traffic Vault encryption key '"+id+"' was retired")
+}
+
+// checkRetirable returns a user error and the HTTP status code to respond
+// with if the key identified by id can't be retired, or nil if it can.
+func checkRetirable(keys tc.TrafficVaultEncryptionKeys, id string) (error, int) {
+	for _, key := range keys.Keys {
+		if key.ID != id {
+			continue
+		}
+		if key.Current {
+			return errors.New("the current encryption key cannot be retired"), http.StatusBadRequest
+		}
+		if key.Retired {
+			return errors.New("encryption key '" + id + "' is already retired"), http.StatusConflict
+		}
+		if key.Secrets > 0 {
+			return fmt.Errorf("encryption key '%s' still encrypts %d secrets; re-encrypt them before retiring it", id, key.Secrets), http.StatusConflict
+		}
+		if keys.UnidentifiedSecrets > 0 {
+			return fmt.Errorf("%d secrets were encrypted before key IDs were recorded, and may need encryption key '%s'; re-encrypt them before retiring it", keys.UnidentifiedSecrets, id), http.StatusConflict
+		}
+		return nil, http.StatusOK
+	}
+	return errors.New("no such encryption key '" + id + "'"), http.StatusNotFound
+}
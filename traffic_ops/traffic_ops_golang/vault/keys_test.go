@@ -0,0 +1,59 @@
+package vault
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+)
+
+func TestCheckRetirable(t *testing.T) {
+	keys := tc.TrafficVaultEncryptionKeys{
+		Keys: []tc.TrafficVaultEncryptionKey{
+			{ID: "current", Current: true, Configured: true, Secrets: 5},
+			{ID: "unused", Configured: true},
+			{ID: "used", Configured: true, Secrets: 2},
+			{ID: "retired", Retired: true},
+		},
+	}
+	tests := map[string]int{
+		"current": http.StatusBadRequest,
+		"unused":  http.StatusOK,
+		"used":    http.StatusConflict,
+		"retired": http.StatusConflict,
+		"unknown": http.StatusNotFound,
+	}
+	for id, expected := range tests {
+		err, code := checkRetirable(keys, id)
+		if code != expected {
+			t.Errorf("retiring '%s': expected status %d, actual: %d", id, expected, code)
+		}
+		if (err == nil) != (expected == http.StatusOK) {
+			t.Errorf("retiring '%s': unexpected error: %v", id, err)
+		}
+	}
+
+	keys.UnidentifiedSecrets = 1
+	if err, code := checkRetirable(keys, "unused"); err == nil || code != http.StatusConflict {
+		t.Errorf("expected a conflict retiring a key while secrets without key IDs exist, actual: %d %v", code, err)
+	}
+}
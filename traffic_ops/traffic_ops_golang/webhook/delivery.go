@@ -0,0 +1,299 @@
+package webhook
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-rfc"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+)
+
+// MaxAttempts is the number of times delivery of an event is attempted
+// before it is marked failed.
+const MaxAttempts = 8
+
+// PollInterval is how often the delivery worker checks for pending
+// deliveries.
+const PollInterval = 5 * time.Second
+
+// RequestTimeout is the timeout of a single delivery attempt.
+const RequestTimeout = 10 * time.Second
+
+// MaxConcurrentDeliveries is the maximum number of deliveries a single
+// Traffic Ops instance will attempt at once.
+const MaxConcurrentDeliveries = 10
+
+// These bound the time between attempts to make a delivery, which grows
+// exponentially.
+const (
+	minRetryInterval = 5 * time.Second
+	maxRetryInterval = 5 * time.Minute
+)
+
+// staleDeliveryAge is how long a delivery may go without being updated while
+// it is being delivered before it is assumed that the Traffic Ops instance
+// delivering it stopped, and it is made pending again. It must be longer
+// than maxRetryInterval+RequestTimeout.
+const staleDeliveryAge = 15 * time.Minute
+
+// maxErrorLen is the maximum length of a response body or error recorded for
+// a failed attempt.
+const maxErrorLen = 1024
+
+const enqueueQuery = `
+INSERT INTO webhook_delivery (webhook, event, payload)
+SELECT id, $1, $2 FROM webhook
+WHERE enabled AND (cardinality(events) = 0 OR $1 = ANY(events))
+`
+
+// claimQuery marks up to $1 pending deliveries as being delivered, and
+// returns them. SKIP LOCKED keeps multiple Traffic Ops instances from
+// claiming the same delivery.
+const claimQuery = `
+UPDATE webhook_delivery AS d SET status='` + tc.WebhookDeliveryStatusDelivering + `'
+FROM webhook AS w
+WHERE w.id = d.webhook AND d.id IN (
+	SELECT id FROM webhook_delivery
+	WHERE status='` + tc.WebhookDeliveryStatusPending + `'
+	ORDER BY id
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING d.id, d.event, d.payload, d.attempts, w.url, w.secret
+`
+
+const reclaimQuery = `
+UPDATE webhook_delivery SET status='` + tc.WebhookDeliveryStatusPending + `'
+WHERE status='` + tc.WebhookDeliveryStatusDelivering + `'
+AND last_updated < now() - $1 * interval '1 second'
+`
+
+const updateAttemptQuery = `
+UPDATE webhook_delivery SET status=$1, attempts=$2, response_code=$3, error=$4
+WHERE id=$5
+`
+
+// Enqueue queues the delivery of an event to every enabled Webhook that
+// subscribes to it. The deliveries are inserted in tx, so they're only made
+// if tx is committed. Like change log entries, errors are logged rather than
+// returned, since failing to notify Webhooks shouldn't fail the request that
+// caused the event.
+func Enqueue(tx *sql.Tx, event tc.WebhookEvent, user *auth.CurrentUser, data interface{}) {
+	payload := tc.WebhookPayload{
+		Event: event,
+		Time:  time.Now(),
+		Data:  data,
+	}
+	if user != nil {
+		payload.User = user.UserName
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Errorf("webhook: marshalling %s event payload: %v", event, err)
+		return
+	}
+	if _, err := tx.Exec(enqueueQuery, string(event), body); err != nil {
+		log.Errorf("webhook: enqueueing %s event: %v", event, err)
+	}
+}
+
+// Sign returns the value of the signature header of a delivery with the
+// given body, for a Webhook with the given secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// delivery is a claimed delivery, with the details of its Webhook.
+type delivery struct {
+	ID       int
+	Event    string
+	Payload  []byte
+	Attempts int
+	URL      string
+	Secret   string
+}
+
+// attemptResult is the outcome of a single delivery attempt.
+type attemptResult struct {
+	ResponseCode *int
+	Err          error
+}
+
+// Worker delivers the events queued by Enqueue.
+type Worker struct {
+	db     *sql.DB
+	client *http.Client
+	sem    chan struct{}
+	// newBackoff returns the Backoff between attempts of a single delivery.
+	newBackoff func() (util.Backoff, error)
+}
+
+// NewWorker returns a Worker which delivers events queued in db.
+func NewWorker(db *sql.DB) *Worker {
+	return &Worker{
+		db:     db,
+		client: &http.Client{Timeout: RequestTimeout},
+		sem:    make(chan struct{}, MaxConcurrentDeliveries),
+		newBackoff: func() (util.Backoff, error) {
+			return util.NewBackoff(minRetryInterval, maxRetryInterval, util.DefaultFactor)
+		},
+	}
+}
+
+// StartWorker starts a Worker delivering the events queued in db, which runs
+// for the life of the process.
+func StartWorker(db *sql.DB) {
+	go NewWorker(db).Run()
+}
+
+// Run polls for and delivers pending deliveries forever.
+func (wk *Worker) Run() {
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := wk.db.Exec(reclaimQuery, int(staleDeliveryAge.Seconds())); err != nil {
+			log.Errorln("webhook: reclaiming stale deliveries: " + err.Error())
+		}
+		free := cap(wk.sem) - len(wk.sem)
+		if free == 0 {
+			continue
+		}
+		deliveries, err := wk.claim(free)
+		if err != nil {
+			log.Errorln("webhook: " + err.Error())
+			continue
+		}
+		for _, d := range deliveries {
+			wk.sem <- struct{}{}
+			go func(d delivery) {
+				defer func() { <-wk.sem }()
+				wk.deliver(d)
+			}(d)
+		}
+	}
+}
+
+// claim marks up to limit pending deliveries as being delivered by this
+// Worker, and returns them.
+func (wk *Worker) claim(limit int) ([]delivery, error) {
+	rows, err := wk.db.Query(claimQuery, limit)
+	if err != nil {
+		return nil, errors.New("claiming deliveries: " + err.Error())
+	}
+	defer log.Close(rows, "closing webhook delivery rows")
+	deliveries := []delivery{}
+	for rows.Next() {
+		d := delivery{}
+		if err := rows.Scan(&d.ID, &d.Event, &d.Payload, &d.Attempts, &d.URL, &d.Secret); err != nil {
+			return nil, errors.New("scanning deliveries: " + err.Error())
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// deliver attempts d until it succeeds or MaxAttempts have been made,
+// recording the result of each attempt.
+func (wk *Worker) deliver(d delivery) {
+	backoff, err := wk.newBackoff()
+	if err != nil {
+		log.Errorln("webhook: creating backoff: " + err.Error())
+		backoff = util.NewConstantBackoff(util.ConstantBackoffDuration)
+	}
+	for d.Attempts < MaxAttempts {
+		result := wk.attempt(d)
+		d.Attempts++
+		status := tc.WebhookDeliveryStatusSucceeded
+		if result.Err != nil {
+			log.Warnf("webhook: delivery #%d attempt %d to %s: %v", d.ID, d.Attempts, d.URL, result.Err)
+			status = tc.WebhookDeliveryStatusDelivering
+			if d.Attempts >= MaxAttempts {
+				status = tc.WebhookDeliveryStatusFailed
+			}
+		}
+		if err := wk.recordAttempt(d, status, result); err != nil {
+			log.Errorf("webhook: recording delivery #%d attempt %d: %v", d.ID, d.Attempts, err)
+		}
+		if status != tc.WebhookDeliveryStatusDelivering {
+			return
+		}
+		time.Sleep(backoff.BackoffDuration())
+	}
+	// only reached by reclaimed deliveries that had already made every attempt
+	if err := wk.recordAttempt(d, tc.WebhookDeliveryStatusFailed, attemptResult{Err: errors.New("maximum delivery attempts exceeded")}); err != nil {
+		log.Errorf("webhook: recording delivery #%d failure: %v", d.ID, err)
+	}
+}
+
+// attempt makes a single attempt to deliver d. Any 2xx response is success.
+func (wk *Worker) attempt(d delivery) attemptResult {
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return attemptResult{Err: errors.New("creating request: " + err.Error())}
+	}
+	req.Header.Set(rfc.ContentType, rfc.ApplicationJSON)
+	req.Header.Set(tc.WebhookEventHeader, d.Event)
+	req.Header.Set(tc.WebhookDeliveryHeader, strconv.Itoa(d.ID))
+	req.Header.Set(tc.WebhookSignatureHeader, Sign(d.Secret, d.Payload))
+
+	resp, err := wk.client.Do(req)
+	if err != nil {
+		return attemptResult{Err: err}
+	}
+	defer log.Close(resp.Body, "closing webhook response body")
+	code := resp.StatusCode
+	if code >= 200 && code < 300 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return attemptResult{ResponseCode: &code}
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorLen))
+	return attemptResult{ResponseCode: &code, Err: fmt.Errorf("response status %d: %s", code, body)}
+}
+
+func (wk *Worker) recordAttempt(d delivery, status string, result attemptResult) error {
+	var errStr *string
+	if result.Err != nil {
+		s := result.Err.Error()
+		if len(s) > maxErrorLen {
+			s = s[:maxErrorLen]
+		}
+		errStr = &s
+	}
+	_, err := wk.db.Exec(updateAttemptQuery, status, d.Attempts, result.ResponseCode, errStr, d.ID)
+	return err
+}
@@ -0,0 +1,152 @@
+package webhook
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestSign(t *testing.T) {
+	// from RFC4231 test case 2
+	expected := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if actual := Sign("Jefe", []byte("what do ya want for nothing?")); actual != expected {
+		t.Errorf("expected signature '%s', actual '%s'", expected, actual)
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO webhook_delivery").WithArgs(string(tc.WebhookEventSnapshot), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectCommit()
+
+	tx, err := mockDB.Begin()
+	if err != nil {
+		t.Fatalf("beginning transaction: %v", err)
+	}
+	Enqueue(tx, tc.WebhookEventSnapshot, &auth.CurrentUser{UserName: "admin"}, tc.WebhookSnapshotData{CDN: "cdn1", CDNID: 1})
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("committing transaction: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %v", err)
+	}
+}
+
+func newTestWorker(t *testing.T) (*Worker, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	wk := NewWorker(mockDB)
+	wk.newBackoff = func() (util.Backoff, error) { return util.NewConstantBackoff(time.Millisecond), nil }
+	return wk, mock
+}
+
+func TestDeliver(t *testing.T) {
+	payload := []byte(`{"event":"snapshot"}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != string(payload) {
+			t.Errorf("expected body '%s', actual '%s'", payload, body)
+		}
+		if sig := r.Header.Get(tc.WebhookSignatureHeader); sig != Sign("secret", payload) {
+			t.Errorf("expected signature '%s', actual '%s'", Sign("secret", payload), sig)
+		}
+		if event := r.Header.Get(tc.WebhookEventHeader); event != "snapshot" {
+			t.Errorf("expected event header 'snapshot', actual '%s'", event)
+		}
+		if id := r.Header.Get(tc.WebhookDeliveryHeader); id != "42" {
+			t.Errorf("expected delivery header '42', actual '%s'", id)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	wk, mock := newTestWorker(t)
+	mock.ExpectExec("UPDATE webhook_delivery").WithArgs(tc.WebhookDeliveryStatusSucceeded, 1, http.StatusNoContent, nil, 42).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	wk.deliver(delivery{ID: 42, Event: "snapshot", Payload: payload, URL: server.URL, Secret: "secret"})
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %v", err)
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	wk, mock := newTestWorker(t)
+	mock.ExpectExec("UPDATE webhook_delivery").WithArgs(tc.WebhookDeliveryStatusDelivering, 1, http.StatusServiceUnavailable, sqlmock.AnyArg(), 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE webhook_delivery").WithArgs(tc.WebhookDeliveryStatusDelivering, 2, http.StatusServiceUnavailable, sqlmock.AnyArg(), 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE webhook_delivery").WithArgs(tc.WebhookDeliveryStatusSucceeded, 3, http.StatusOK, nil, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	wk.deliver(delivery{ID: 7, Event: "snapshot", Payload: []byte(`{}`), URL: server.URL, Secret: "secret"})
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, actual %d", calls)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %v", err)
+	}
+}
+
+func TestDeliverFails(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	wk, mock := newTestWorker(t)
+	mock.ExpectExec("UPDATE webhook_delivery").WithArgs(tc.WebhookDeliveryStatusFailed, MaxAttempts, http.StatusInternalServerError, sqlmock.AnyArg(), 7).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// a reclaimed delivery with one attempt left
+	wk.deliver(delivery{ID: 7, Event: "snapshot", Payload: []byte(`{}`), Attempts: MaxAttempts - 1, URL: server.URL, Secret: "secret"})
+	if calls != 1 {
+		t.Errorf("expected 1 attempt, actual %d", calls)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %v", err)
+	}
+}
@@ -0,0 +1,276 @@
+// Package webhook provides the handlers for Traffic Ops's Webhooks, which
+// are called when lifecycle events such as Snapshots and CDN locks occur, and
+// the worker which delivers those events.
+package webhook
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/lib/go-util"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/dbhelpers"
+
+	"github.com/lib/pq"
+)
+
+const readQuery = `SELECT id, name, url, events, enabled, last_updated FROM webhook`
+
+const insertQuery = `
+INSERT INTO webhook (name, url, secret, events, enabled)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, last_updated
+`
+
+const updateQuery = `
+UPDATE webhook SET name=$1, url=$2, secret=$3, events=$4, enabled=$5
+WHERE id=$6
+RETURNING last_updated
+`
+
+const deleteQuery = `DELETE FROM webhook WHERE id=$1 RETURNING id, name, url, events, enabled, last_updated`
+
+const readDeliveriesQuery = `
+SELECT id, webhook, event, payload, status, attempts, response_code, error, created, last_updated
+FROM webhook_delivery
+`
+
+// eventStrings converts events to strings, for storage in a text array.
+func eventStrings(events []tc.WebhookEvent) []string {
+	strs := make([]string, 0, len(events))
+	for _, event := range events {
+		strs = append(strs, string(event))
+	}
+	return strs
+}
+
+// parseEvents converts a text array of events back to WebhookEvents.
+func parseEvents(strs []string) []tc.WebhookEvent {
+	events := make([]tc.WebhookEvent, 0, len(strs))
+	for _, str := range strs {
+		events = append(events, tc.WebhookEvent(str))
+	}
+	return events
+}
+
+// setDefaults sets the optional fields of a Webhook submitted by a client.
+func setDefaults(wh *tc.Webhook) {
+	if wh.Events == nil {
+		wh.Events = []tc.WebhookEvent{}
+	}
+	if wh.Enabled == nil {
+		wh.Enabled = util.BoolPtr(true)
+	}
+}
+
+// Read is the handler for GET requests to /webhooks.
+func Read(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	cols := map[string]dbhelpers.WhereColumnInfo{
+		"id":   {Column: "webhook.id", Checker: api.IsInt},
+		"name": {Column: "webhook.name", Checker: nil},
+	}
+	where, orderBy, pagination, queryValues, errs := dbhelpers.BuildWhereAndOrderByAndPagination(inf.Params, cols)
+	if len(errs) > 0 {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, util.JoinErrs(errs), nil)
+		return
+	}
+
+	rows, err := inf.Tx.NamedQuery(readQuery+where+orderBy+pagination, queryValues)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("querying webhooks: "+err.Error()))
+		return
+	}
+	defer rows.Close()
+
+	webhooks := []tc.Webhook{}
+	for rows.Next() {
+		wh := tc.Webhook{}
+		events := []string{}
+		if err := rows.Scan(&wh.ID, &wh.Name, &wh.URL, pq.Array(&events), &wh.Enabled, &wh.LastUpdated); err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("scanning webhooks: "+err.Error()))
+			return
+		}
+		wh.Events = parseEvents(events)
+		webhooks = append(webhooks, wh)
+	}
+	api.WriteResp(w, r, webhooks)
+}
+
+// Create is the handler for POST requests to /webhooks.
+func Create(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	wh := tc.Webhook{}
+	if err := api.Parse(r.Body, tx, &wh); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
+		return
+	}
+	setDefaults(&wh)
+
+	if err := tx.QueryRow(insertQuery, wh.Name, wh.URL, wh.Secret, pq.Array(eventStrings(wh.Events)), wh.Enabled).Scan(&wh.ID, &wh.LastUpdated); err != nil {
+		userErr, sysErr, errCode := api.ParseDBError(err)
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	wh.Secret = nil
+
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "webhook was created.", wh)
+	changeLogMsg := fmt.Sprintf("WEBHOOK: %s, ID: %d, ACTION: %s webhook", *wh.Name, *wh.ID, api.Created)
+	api.CreateChangeLogRawTx(api.ApiChange, changeLogMsg, inf.User, tx)
+}
+
+// Update is the handler for PUT requests to /webhooks/{{ID}}.
+func Update(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+	id := inf.IntParams["id"]
+
+	wh := tc.Webhook{}
+	if err := api.Parse(r.Body, tx, &wh); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
+		return
+	}
+	setDefaults(&wh)
+	wh.ID = &id
+
+	if err := tx.QueryRow(updateQuery, wh.Name, wh.URL, wh.Secret, pq.Array(eventStrings(wh.Events)), wh.Enabled, id).Scan(&wh.LastUpdated); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no webhook exists by id %d", id), nil)
+			return
+		}
+		userErr, sysErr, errCode := api.ParseDBError(err)
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	wh.Secret = nil
+
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "webhook was updated.", wh)
+	changeLogMsg := fmt.Sprintf("WEBHOOK: %s, ID: %d, ACTION: %s webhook", *wh.Name, id, api.Updated)
+	api.CreateChangeLogRawTx(api.ApiChange, changeLogMsg, inf.User, tx)
+}
+
+// Delete is the handler for DELETE requests to /webhooks/{{ID}}. Deleting a
+// Webhook also deletes the record of its deliveries.
+func Delete(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+	id := inf.IntParams["id"]
+
+	wh := tc.Webhook{}
+	events := []string{}
+	if err := tx.QueryRow(deleteQuery, id).Scan(&wh.ID, &wh.Name, &wh.URL, pq.Array(&events), &wh.Enabled, &wh.LastUpdated); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no webhook exists by id %d", id), nil)
+			return
+		}
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("deleting webhook #%d: %w", id, err))
+		return
+	}
+	wh.Events = parseEvents(events)
+
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "webhook was deleted.", wh)
+	changeLogMsg := fmt.Sprintf("WEBHOOK: %s, ID: %d, ACTION: %s webhook", *wh.Name, id, api.Deleted)
+	api.CreateChangeLogRawTx(api.ApiChange, changeLogMsg, inf.User, tx)
+}
+
+// GetDeliveries is the handler for GET requests to /webhooks/{{ID}}/deliveries.
+// Deliveries are returned newest first unless the client requests another
+// order.
+func GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	exists := false
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM webhook WHERE id=$1)`, inf.IntParams["id"]).Scan(&exists); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("checking webhook existence: "+err.Error()))
+		return
+	}
+	if !exists {
+		api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no webhook exists by id %d", inf.IntParams["id"]), nil)
+		return
+	}
+
+	cols := map[string]dbhelpers.WhereColumnInfo{
+		"id":      {Column: "webhook_delivery.webhook", Checker: api.IsInt},
+		"event":   {Column: "webhook_delivery.event", Checker: nil},
+		"status":  {Column: "webhook_delivery.status", Checker: nil},
+		"created": {Column: "webhook_delivery.created", Checker: nil},
+	}
+	where, orderBy, pagination, queryValues, errs := dbhelpers.BuildWhereAndOrderByAndPagination(inf.Params, cols)
+	if len(errs) > 0 {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, util.JoinErrs(errs), nil)
+		return
+	}
+	if orderBy == "" {
+		orderBy = dbhelpers.BaseOrderBy + " webhook_delivery.id DESC"
+	}
+
+	rows, err := inf.Tx.NamedQuery(readDeliveriesQuery+where+orderBy+pagination, queryValues)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("querying webhook deliveries: "+err.Error()))
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []tc.WebhookDelivery{}
+	for rows.Next() {
+		d := tc.WebhookDelivery{}
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.ResponseCode, &d.Error, &d.Created, &d.LastUpdated); err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("scanning webhook deliveries: "+err.Error()))
+			return
+		}
+		deliveries = append(deliveries, d)
+	}
+	api.WriteResp(w, r, deliveries)
+}
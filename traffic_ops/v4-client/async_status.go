@@ -0,0 +1,114 @@
+package client
+
+/*
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/toclientlib"
+)
+
+// apiAsyncStatus is the API version-relative path to the /async_status API
+// endpoint.
+const apiAsyncStatus = "/async_status"
+
+// AsyncJobPollInterval is how often WaitForAsyncJob checks whether a job has
+// finished.
+var AsyncJobPollInterval = time.Second
+
+// AsyncJobWaitTimeout is how long WaitForAsyncJob waits for a job to finish
+// before giving up.
+var AsyncJobWaitTimeout = 10 * time.Minute
+
+// GetAsyncStatus retrieves the status of the asynchronous job with the given
+// ID.
+func (to *Session) GetAsyncStatus(id int, opts RequestOptions) (tc.AsyncStatusResponse, toclientlib.ReqInf, error) {
+	var resp tc.AsyncStatusResponse
+	reqInf, err := to.get(apiAsyncStatus+"/"+strconv.Itoa(id), opts, &resp)
+	return resp, reqInf, err
+}
+
+// GetAsyncStatuses retrieves the statuses of asynchronous jobs, newest first
+// unless otherwise ordered.
+func (to *Session) GetAsyncStatuses(opts RequestOptions) (tc.AsyncStatusesResponse, toclientlib.ReqInf, error) {
+	var resp tc.AsyncStatusesResponse
+	reqInf, err := to.get(apiAsyncStatus, opts, &resp)
+	return resp, reqInf, err
+}
+
+// GetAsyncStatusLogs retrieves the messages logged by the asynchronous job
+// with the given ID.
+func (to *Session) GetAsyncStatusLogs(id int, opts RequestOptions) (tc.AsyncStatusLogsResponse, toclientlib.ReqInf, error) {
+	var resp tc.AsyncStatusLogsResponse
+	reqInf, err := to.get(fmt.Sprintf("%s/%d/logs", apiAsyncStatus, id), opts, &resp)
+	return resp, reqInf, err
+}
+
+// CancelAsyncJob cancels the asynchronous job with the given ID - or, if it's
+// already running, asks for it to be cancelled.
+func (to *Session) CancelAsyncJob(id int, opts RequestOptions) (tc.AsyncStatusResponse, toclientlib.ReqInf, error) {
+	var resp tc.AsyncStatusResponse
+	reqInf, err := to.post(fmt.Sprintf("%s/%d/cancel", apiAsyncStatus, id), opts, nil, &resp)
+	return resp, reqInf, err
+}
+
+// WaitForAsyncJob polls the status of the asynchronous job with the given ID
+// every AsyncJobPollInterval until it finishes, and returns its final
+// status. It gives up after AsyncJobWaitTimeout. Finishing unsuccessfully is
+// not an error; callers should check the returned status.
+func (to *Session) WaitForAsyncJob(id int, opts RequestOptions) (tc.AsyncStatusResponse, toclientlib.ReqInf, error) {
+	deadline := time.Now().Add(AsyncJobWaitTimeout)
+	for {
+		resp, reqInf, err := to.GetAsyncStatus(id, opts)
+		if err != nil || resp.Response.IsFinished() {
+			return resp, reqInf, err
+		}
+		if time.Now().After(deadline) {
+			return resp, reqInf, fmt.Errorf("async job #%d did not finish within %v", id, AsyncJobWaitTimeout)
+		}
+		time.Sleep(AsyncJobPollInterval)
+	}
+}
+
+// waitForAsyncJobResult waits for the job queued by a request to which
+// queued was the response, and decodes its result into result. An error is
+// returned if the job doesn't succeed.
+func (to *Session) waitForAsyncJobResult(queued tc.AsyncStatusResponse, opts RequestOptions, result interface{}) (toclientlib.ReqInf, error) {
+	opts.QueryParameters = url.Values{}
+	resp, reqInf, err := to.WaitForAsyncJob(queued.Response.Id, opts)
+	if err != nil {
+		return reqInf, err
+	}
+	if resp.Response.Status != tc.AsyncSucceeded {
+		msg := resp.Response.Status
+		if resp.Response.Message != nil {
+			msg += ": " + *resp.Response.Message
+		}
+		return reqInf, errors.New("async job #" + strconv.Itoa(queued.Response.Id) + " did not succeed - " + msg)
+	}
+	if result != nil && len(resp.Response.Result) > 0 {
+		if err := json.Unmarshal(resp.Response.Result, result); err != nil {
+			return reqInf, fmt.Errorf("decoding result of async job #%d: %v", queued.Response.Id, err)
+		}
+	}
+	return reqInf, nil
+}
@@ -18,6 +18,7 @@ package client
 import (
 	"fmt"
 	"net/url"
+	"strconv"
 
 	"github.com/apache/trafficcontrol/lib/go-tc"
 	"github.com/apache/trafficcontrol/traffic_ops/toclientlib"
@@ -63,3 +64,37 @@ func (to *Session) GetCDNSSLKeys(name string, opts RequestOptions) (tc.CDNSSLKey
 	reqInf, err := to.get(route, opts, &data)
 	return data, reqInf, err
 }
+
+// ExportCDN retrieves the entire configuration of the CDN with the given name
+// as a declarative document, suitable for use with ImportCDN.
+func (to *Session) ExportCDN(name string, opts RequestOptions) (tc.CDNExport, toclientlib.ReqInf, error) {
+	route := fmt.Sprintf("%s/%s/export", apiCDNs, url.PathEscape(name))
+	var data tc.CDNExport
+	reqInf, err := to.get(route, opts, &data)
+	return data, reqInf, err
+}
+
+// GetCDNCapacityForecast retrieves the capacity forecast of each Cache Group
+// in the CDN with the given name. The history window, horizon, and
+// utilization thresholds may be set with the "days", "horizonDays", and
+// "thresholds" query parameters in opts.
+func (to *Session) GetCDNCapacityForecast(name string, opts RequestOptions) (tc.CDNCapacityForecastResponse, toclientlib.ReqInf, error) {
+	route := fmt.Sprintf("%s/%s/capacity/forecast", apiCDNs, url.PathEscape(name))
+	var data tc.CDNCapacityForecastResponse
+	reqInf, err := to.get(route, opts, &data)
+	return data, reqInf, err
+}
+
+// ImportCDN creates or updates the CDN described by the given document, and
+// everything in it, to match the document. If dryRun is true, nothing is
+// changed, and the response describes what would have been.
+func (to *Session) ImportCDN(doc tc.CDNExport, dryRun bool, opts RequestOptions) (tc.CDNImportResponse, toclientlib.ReqInf, error) {
+	if opts.QueryParameters == nil {
+		opts.QueryParameters = url.Values{}
+	}
+	opts.QueryParameters.Set("dryRun", strconv.FormatBool(dryRun))
+	route := fmt.Sprintf("%s/import", apiCDNs)
+	var data tc.CDNImportResponse
+	reqInf, err := to.post(route, opts, doc, &data)
+	return data, reqInf, err
+}
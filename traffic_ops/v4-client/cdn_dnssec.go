@@ -29,9 +29,27 @@ const (
 	apiCDNsDNSSECKeysKSKGenerate = "/cdns/%s/dnsseckeys/ksk/generate"
 )
 
-// GenerateCDNDNSSECKeys generates DNSSEC keys for the given CDN.
+// GenerateCDNDNSSECKeys generates DNSSEC keys for the given CDN. It waits
+// for the asynchronous job that generates them to finish, and returns an
+// error if it doesn't succeed.
 func (to *Session) GenerateCDNDNSSECKeys(req tc.CDNDNSSECGenerateReq, opts RequestOptions) (tc.GenerateCDNDNSSECKeysResponse, toclientlib.ReqInf, error) {
 	var resp tc.GenerateCDNDNSSECKeysResponse
+	queued, reqInf, err := to.GenerateCDNDNSSECKeysAsync(req, opts)
+	resp.Alerts = queued.Alerts
+	if err != nil {
+		return resp, reqInf, err
+	}
+	if _, err := to.waitForAsyncJobResult(queued, opts, &resp.Response); err != nil {
+		return resp, reqInf, err
+	}
+	return resp, reqInf, nil
+}
+
+// GenerateCDNDNSSECKeysAsync queues an asynchronous job which generates
+// DNSSEC keys for the given CDN, and returns without waiting for it to
+// finish.
+func (to *Session) GenerateCDNDNSSECKeysAsync(req tc.CDNDNSSECGenerateReq, opts RequestOptions) (tc.AsyncStatusResponse, toclientlib.ReqInf, error) {
+	var resp tc.AsyncStatusResponse
 	reqInf, err := to.post(apiCDNsDNSSECKeysGenerate, opts, req, &resp)
 	return resp, reqInf, err
 }
@@ -18,6 +18,7 @@ package client
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/apache/trafficcontrol/lib/go-tc"
 	"github.com/apache/trafficcontrol/traffic_ops/toclientlib"
@@ -41,9 +42,26 @@ func (to *Session) GetCRConfig(cdn string, opts RequestOptions) (tc.SnapshotResp
 }
 
 // SnapshotCRConfig creates a new Snapshot for the CDN with the given Name -
-// NOT just a new CRConfig!
+// NOT just a new CRConfig! It waits for the asynchronous job that takes the
+// Snapshot to finish, and returns an error if it doesn't succeed.
 func (to *Session) SnapshotCRConfig(opts RequestOptions) (tc.PutSnapshotResponse, toclientlib.ReqInf, error) {
 	var resp tc.PutSnapshotResponse
+	queued, reqInf, err := to.SnapshotCRConfigAsync(opts)
+	resp.Alerts = queued.Alerts
+	if err != nil {
+		return resp, reqInf, err
+	}
+	if _, err := to.waitForAsyncJobResult(queued, opts, &resp.Response); err != nil {
+		return resp, reqInf, err
+	}
+	return resp, reqInf, nil
+}
+
+// SnapshotCRConfigAsync queues an asynchronous job which creates a new
+// Snapshot for the CDN with the given Name, and returns without waiting for
+// it to finish.
+func (to *Session) SnapshotCRConfigAsync(opts RequestOptions) (tc.AsyncStatusResponse, toclientlib.ReqInf, error) {
+	var resp tc.AsyncStatusResponse
 	if opts.QueryParameters == nil || (opts.QueryParameters.Get("cdn") == "" && opts.QueryParameters.Get("cdnID") == "") {
 		return resp, toclientlib.ReqInf{}, errors.New("cannot take Snapshot of unidentified CDN - set 'cdn' or 'cdnID' query parameter")
 	}
@@ -59,3 +77,30 @@ func (to *Session) GetCRConfigNew(cdn string, opts RequestOptions) (tc.SnapshotR
 	reqInf, err := to.get(uri, opts, &resp)
 	return resp, reqInf, err
 }
+
+// GetCRConfigDiff returns the difference between the current Snapshot of the
+// given CDN and the *new* Snapshot that would be taken now.
+func (to *Session) GetCRConfigDiff(cdn string, opts RequestOptions) (tc.CRConfigDiffResponse, toclientlib.ReqInf, error) {
+	uri := `/cdns/` + cdn + `/snapshot/diff`
+	var resp tc.CRConfigDiffResponse
+	reqInf, err := to.get(uri, opts, &resp)
+	return resp, reqInf, err
+}
+
+// GetSnapshotHistory returns the Snapshot history of the given CDN, newest
+// first.
+func (to *Session) GetSnapshotHistory(cdn string, opts RequestOptions) (tc.SnapshotHistoryResponse, toclientlib.ReqInf, error) {
+	uri := `/cdns/` + cdn + `/snapshots`
+	var resp tc.SnapshotHistoryResponse
+	reqInf, err := to.get(uri, opts, &resp)
+	return resp, reqInf, err
+}
+
+// RestoreSnapshot makes the historical Snapshot of the given CDN with the
+// given ID its current Snapshot.
+func (to *Session) RestoreSnapshot(cdn string, id int, req tc.SnapshotRestoreRequest, opts RequestOptions) (tc.SnapshotRestoreResponse, toclientlib.ReqInf, error) {
+	uri := fmt.Sprintf("/cdns/%s/snapshots/%d/restore", cdn, id)
+	var resp tc.SnapshotRestoreResponse
+	reqInf, err := to.post(uri, opts, req, &resp)
+	return resp, reqInf, err
+}
@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strconv"
 
 	"github.com/apache/trafficcontrol/lib/go-tc"
 	"github.com/apache/trafficcontrol/lib/go-util"
@@ -66,6 +67,10 @@ const (
 	// (namely the ID of the Delivery Service of interest).
 	apiDeliveryServicesSafeUpdate = apiDeliveryServiceID + "/safe"
 
+	// apiDeliveryServicesApply is the API path on which Traffic Ops reconciles the Delivery Services
+	// of a CDN or Tenant with a desired set of Delivery Services.
+	apiDeliveryServicesApply = apiDeliveryServices + "/apply"
+
 	// apiAPIDeliveryServiceXMLIDSSLKeys is the API path on which Traffic Ops serves information about
 	// and functionality relating to the SSL keys used by a Delivery Service identified by its XMLID. It is
 	// intended to be used with fmt.Sprintf to insert its required path parameter (namely the XMLID
@@ -78,6 +83,10 @@ const (
 	// apiDeliveryServiceAddSSLKeys is the API path on which Traffic Ops will add SSL keys
 	apiDeliveryServiceAddSSLKeys = apiDeliveryServices + "/sslkeys/add"
 
+	// apiSSLKeyExpirations is the API path on which Traffic Ops serves
+	// descriptions of the certificates of Delivery Services' SSL keys.
+	apiSSLKeyExpirations = "/sslkeys/expirations"
+
 	// apiDeliveryServiceURISigningKeys is the API path on which Traffic Ops serves information
 	// about and functionality relating to the URI-signing keys used by a Delivery Service identified
 	// by its XMLID. It is intended to be used with fmt.Sprintf to insert its required path parameter
@@ -195,6 +204,20 @@ func (to *Session) CreateDeliveryService(ds tc.DeliveryServiceV4, opts RequestOp
 	return resp, reqInf, nil
 }
 
+// ApplyDeliveryServices makes the Delivery Services of the CDN and/or Tenant
+// of the request match the Delivery Services in it, creating, updating, and
+// deleting Delivery Services as needed. If dryRun is true, nothing is
+// changed, and the response describes the changes which would have been made.
+func (to *Session) ApplyDeliveryServices(req tc.DeliveryServiceApplyRequest, dryRun bool, opts RequestOptions) (tc.DeliveryServiceApplyResponse, toclientlib.ReqInf, error) {
+	if opts.QueryParameters == nil {
+		opts.QueryParameters = url.Values{}
+	}
+	opts.QueryParameters.Set("dryRun", strconv.FormatBool(dryRun))
+	var resp tc.DeliveryServiceApplyResponse
+	reqInf, err := to.post(apiDeliveryServicesApply, opts, req, &resp)
+	return resp, reqInf, err
+}
+
 // UpdateDeliveryService replaces the Delivery Service identified by the
 // integral, unique identifier 'id' with the one it's passed.
 func (to *Session) UpdateDeliveryService(id int, ds tc.DeliveryServiceV4, opts RequestOptions) (tc.DeliveryServicesResponseV4, toclientlib.ReqInf, error) {
@@ -280,6 +303,16 @@ func (to *Session) GetDeliveryServiceSSLKeys(xmlid string, opts RequestOptions)
 	return data, reqInf, err
 }
 
+// GetSSLKeyExpirations retrieves descriptions of the certificates of every
+// Delivery Service's current SSL keys, in order of expiration. They may be
+// limited to those expiring within a number of days, or to a CDN, with the
+// "days" and "cdn" query parameters in opts.
+func (to *Session) GetSSLKeyExpirations(opts RequestOptions) (tc.SSLKeyExpirationsResponse, toclientlib.ReqInf, error) {
+	var data tc.SSLKeyExpirationsResponse
+	reqInf, err := to.get(apiSSLKeyExpirations, opts, &data)
+	return data, reqInf, err
+}
+
 // GetDeliveryServicesEligible returns the servers eligible for assignment to the Delivery
 // Service identified by the integral, unique identifier 'dsID'.
 func (to *Session) GetDeliveryServicesEligible(dsID int, opts RequestOptions) (tc.DSServerResponseV4, toclientlib.ReqInf, error) {
@@ -0,0 +1,79 @@
+package client
+
+/*
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/toclientlib"
+)
+
+// apiDSRApprovalPolicies is the API version-relative path to the
+// /deliveryservice_request_approval_policies API endpoint.
+const apiDSRApprovalPolicies = "/deliveryservice_request_approval_policies"
+
+// GetDeliveryServiceRequestApprovals retrieves the approval state of the
+// Delivery Service Request with the given ID.
+func (to *Session) GetDeliveryServiceRequestApprovals(id int, opts RequestOptions) (tc.DeliveryServiceRequestApprovalsResponse, toclientlib.ReqInf, error) {
+	route := fmt.Sprintf("%s/%d/approvals", apiDSRequests, id)
+	var data tc.DeliveryServiceRequestApprovalsResponse
+	reqInf, err := to.get(route, opts, &data)
+	return data, reqInf, err
+}
+
+// ApproveDeliveryServiceRequest approves the Delivery Service Request with
+// the given ID as the session user.
+func (to *Session) ApproveDeliveryServiceRequest(id int, approval tc.DeliveryServiceRequestApprovalRequest, opts RequestOptions) (tc.DeliveryServiceRequestApprovalsResponse, toclientlib.ReqInf, error) {
+	route := fmt.Sprintf("%s/%d/approvals", apiDSRequests, id)
+	var data tc.DeliveryServiceRequestApprovalsResponse
+	reqInf, err := to.post(route, opts, approval, &data)
+	return data, reqInf, err
+}
+
+// GetDSRApprovalPolicies retrieves the Delivery Service Request Approval
+// Policies of the Tenants available to the session user.
+func (to *Session) GetDSRApprovalPolicies(opts RequestOptions) (tc.DSRApprovalPoliciesResponse, toclientlib.ReqInf, error) {
+	var data tc.DSRApprovalPoliciesResponse
+	reqInf, err := to.get(apiDSRApprovalPolicies, opts, &data)
+	return data, reqInf, err
+}
+
+// CreateDSRApprovalPolicy creates the given Delivery Service Request
+// Approval Policy.
+func (to *Session) CreateDSRApprovalPolicy(policy tc.DSRApprovalPolicy, opts RequestOptions) (tc.DSRApprovalPolicyResponse, toclientlib.ReqInf, error) {
+	var data tc.DSRApprovalPolicyResponse
+	reqInf, err := to.post(apiDSRApprovalPolicies, opts, policy, &data)
+	return data, reqInf, err
+}
+
+// UpdateDSRApprovalPolicy replaces the Delivery Service Request Approval
+// Policy of the Tenant with the given ID.
+func (to *Session) UpdateDSRApprovalPolicy(tenantID int, policy tc.DSRApprovalPolicy, opts RequestOptions) (tc.DSRApprovalPolicyResponse, toclientlib.ReqInf, error) {
+	route := fmt.Sprintf("%s/%d", apiDSRApprovalPolicies, tenantID)
+	var data tc.DSRApprovalPolicyResponse
+	reqInf, err := to.put(route, opts, policy, &data)
+	return data, reqInf, err
+}
+
+// DeleteDSRApprovalPolicy deletes the Delivery Service Request Approval
+// Policy of the Tenant with the given ID.
+func (to *Session) DeleteDSRApprovalPolicy(tenantID int, opts RequestOptions) (tc.Alerts, toclientlib.ReqInf, error) {
+	route := fmt.Sprintf("%s/%d", apiDSRApprovalPolicies, tenantID)
+	var alerts tc.Alerts
+	reqInf, err := to.del(route, opts, &alerts)
+	return alerts, reqInf, err
+}
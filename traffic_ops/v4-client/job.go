@@ -27,6 +27,14 @@ import (
 // apiJobs is the API version-relative path to the /jobs API route.
 const apiJobs = "/jobs"
 
+// apiJobSchedules is the API version-relative path to the /jobs/schedules API
+// route.
+const apiJobSchedules = apiJobs + "/schedules"
+
+// apiJobAcknowledgements is the API version-relative path to the
+// /jobs/acknowledgements API route.
+const apiJobAcknowledgements = apiJobs + "/acknowledgements"
+
 // CreateInvalidationJob creates the passed Content Invalidation Job.
 func (to *Session) CreateInvalidationJob(job tc.InvalidationJobInput, opts RequestOptions) (tc.Alerts, toclientlib.ReqInf, error) {
 	var alerts tc.Alerts
@@ -68,3 +76,51 @@ func (to *Session) GetInvalidationJobs(opts RequestOptions) (tc.InvalidationJobs
 	reqInf, err := to.get(apiJobs, opts, &data)
 	return data, reqInf, err
 }
+
+// GetInvalidationJobProgress returns how many of the cache servers to which
+// the Content Invalidation Job identified by 'jobID' applies have applied it.
+func (to *Session) GetInvalidationJobProgress(jobID uint64, opts RequestOptions) (tc.InvalidationJobProgressResponse, toclientlib.ReqInf, error) {
+	var data tc.InvalidationJobProgressResponse
+	reqInf, err := to.get(apiJobs+"/"+strconv.FormatUint(jobID, 10)+"/progress", opts, &data)
+	return data, reqInf, err
+}
+
+// AcknowledgeInvalidationJobs reports that a cache server has applied the
+// given Content Invalidation Jobs.
+func (to *Session) AcknowledgeInvalidationJobs(ack tc.InvalidationJobAcknowledgement, opts RequestOptions) (tc.Alerts, toclientlib.ReqInf, error) {
+	var alerts tc.Alerts
+	reqInf, err := to.post(apiJobAcknowledgements, opts, ack, &alerts)
+	return alerts, reqInf, err
+}
+
+// GetInvalidationJobSchedules returns the recurring Content Invalidation Job
+// schedules visible to your Tenant.
+func (to *Session) GetInvalidationJobSchedules(opts RequestOptions) (tc.InvalidationJobSchedulesResponse, toclientlib.ReqInf, error) {
+	var data tc.InvalidationJobSchedulesResponse
+	reqInf, err := to.get(apiJobSchedules, opts, &data)
+	return data, reqInf, err
+}
+
+// CreateInvalidationJobSchedule creates the passed recurring Content
+// Invalidation Job schedule.
+func (to *Session) CreateInvalidationJobSchedule(schedule tc.InvalidationJobSchedule, opts RequestOptions) (tc.InvalidationJobScheduleResponse, toclientlib.ReqInf, error) {
+	var data tc.InvalidationJobScheduleResponse
+	reqInf, err := to.post(apiJobSchedules, opts, schedule, &data)
+	return data, reqInf, err
+}
+
+// UpdateInvalidationJobSchedule replaces the recurring Content Invalidation
+// Job schedule identified by 'id' with the one passed.
+func (to *Session) UpdateInvalidationJobSchedule(id int, schedule tc.InvalidationJobSchedule, opts RequestOptions) (tc.InvalidationJobScheduleResponse, toclientlib.ReqInf, error) {
+	var data tc.InvalidationJobScheduleResponse
+	reqInf, err := to.put(apiJobSchedules+"/"+strconv.Itoa(id), opts, schedule, &data)
+	return data, reqInf, err
+}
+
+// DeleteInvalidationJobSchedule deletes the recurring Content Invalidation Job
+// schedule identified by 'id'. Jobs it has already created are not deleted.
+func (to *Session) DeleteInvalidationJobSchedule(id int, opts RequestOptions) (tc.Alerts, toclientlib.ReqInf, error) {
+	var alerts tc.Alerts
+	reqInf, err := to.del(apiJobSchedules+"/"+strconv.Itoa(id), opts, &alerts)
+	return alerts, reqInf, err
+}
@@ -0,0 +1,76 @@
+package client
+
+/*
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"strconv"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/toclientlib"
+)
+
+// apiServerStatusTransitions is the API version-relative path to the
+// /server_status_transitions API route.
+const apiServerStatusTransitions = "/server_status_transitions"
+
+// apiServerMaintenanceWindows is the API version-relative path to the
+// /server_maintenance_windows API route.
+const apiServerMaintenanceWindows = "/server_maintenance_windows"
+
+// GetServerStatusTransitions returns the allowed server Status transitions.
+func (to *Session) GetServerStatusTransitions(opts RequestOptions) (tc.ServerStatusTransitionsResponse, toclientlib.ReqInf, error) {
+	var data tc.ServerStatusTransitionsResponse
+	reqInf, err := to.get(apiServerStatusTransitions, opts, &data)
+	return data, reqInf, err
+}
+
+// CreateServerStatusTransition allows the passed server Status transition.
+func (to *Session) CreateServerStatusTransition(transition tc.ServerStatusTransition, opts RequestOptions) (tc.ServerStatusTransitionResponse, toclientlib.ReqInf, error) {
+	var data tc.ServerStatusTransitionResponse
+	reqInf, err := to.post(apiServerStatusTransitions, opts, transition, &data)
+	return data, reqInf, err
+}
+
+// DeleteServerStatusTransition deletes the server Status transition
+// identified by 'id'.
+func (to *Session) DeleteServerStatusTransition(id int, opts RequestOptions) (tc.ServerStatusTransitionResponse, toclientlib.ReqInf, error) {
+	var data tc.ServerStatusTransitionResponse
+	reqInf, err := to.del(apiServerStatusTransitions+"/"+strconv.Itoa(id), opts, &data)
+	return data, reqInf, err
+}
+
+// GetServerMaintenanceWindows returns server maintenance windows.
+func (to *Session) GetServerMaintenanceWindows(opts RequestOptions) (tc.ServerMaintenanceWindowsResponse, toclientlib.ReqInf, error) {
+	var data tc.ServerMaintenanceWindowsResponse
+	reqInf, err := to.get(apiServerMaintenanceWindows, opts, &data)
+	return data, reqInf, err
+}
+
+// CreateServerMaintenanceWindow schedules the passed server maintenance
+// window.
+func (to *Session) CreateServerMaintenanceWindow(window tc.ServerMaintenanceWindow, opts RequestOptions) (tc.ServerMaintenanceWindowResponse, toclientlib.ReqInf, error) {
+	var data tc.ServerMaintenanceWindowResponse
+	reqInf, err := to.post(apiServerMaintenanceWindows, opts, window, &data)
+	return data, reqInf, err
+}
+
+// DeleteServerMaintenanceWindow deletes the server maintenance window
+// identified by 'id'. If the window is active, it is ended first.
+func (to *Session) DeleteServerMaintenanceWindow(id int, opts RequestOptions) (tc.Alerts, toclientlib.ReqInf, error) {
+	var alerts tc.Alerts
+	reqInf, err := to.del(apiServerMaintenanceWindows+"/"+strconv.Itoa(id), opts, &alerts)
+	return alerts, reqInf, err
+}
@@ -16,6 +16,7 @@ package client
 */
 
 import (
+	"fmt"
 	"net/url"
 
 	"github.com/apache/trafficcontrol/lib/go-tc"
@@ -60,3 +61,18 @@ func (to *Session) DeleteTopology(name string, opts RequestOptions) (tc.Alerts,
 	reqInf, err := to.del(apiTopologies, opts, &alerts)
 	return alerts, reqInf, err
 }
+
+// SimulateTopology returns the Cache Groups and servers through which requests
+// for the Delivery Service with the given XMLID pass from the given Cache
+// Group in the Topology with the given name.
+func (to *Session) SimulateTopology(name, dsXMLID, cacheGroup string, opts RequestOptions) (tc.TopologySimulationResponse, toclientlib.ReqInf, error) {
+	if opts.QueryParameters == nil {
+		opts.QueryParameters = url.Values{}
+	}
+	opts.QueryParameters.Set("ds", dsXMLID)
+	opts.QueryParameters.Set("cachegroup", cacheGroup)
+	path := fmt.Sprintf(apiTopologies+"/%s/simulate", url.PathEscape(name))
+	var resp tc.TopologySimulationResponse
+	reqInf, err := to.get(path, opts, &resp)
+	return resp, reqInf, err
+}
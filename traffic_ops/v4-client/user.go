@@ -112,3 +112,32 @@ func (to *Session) RegisterNewUser(tenantID uint, roleID uint, email rfc.EmailAd
 	reqInf, err := to.post("/users/register", opts, reqBody, &alerts)
 	return alerts, reqInf, err
 }
+
+// apiUserCurrentTokens is the API path on which Traffic Ops serves the
+// personal access tokens of the current user.
+const apiUserCurrentTokens = "/user/current/tokens"
+
+// GetCurrentUserTokens retrieves the personal access tokens of the currently
+// authenticated User.
+func (to *Session) GetCurrentUserTokens(opts RequestOptions) (tc.APITokensResponse, toclientlib.ReqInf, error) {
+	var data tc.APITokensResponse
+	reqInf, err := to.get(apiUserCurrentTokens, opts, &data)
+	return data, reqInf, err
+}
+
+// CreateCurrentUserToken creates a personal access token for the currently
+// authenticated User. The token's value is only returned in this response.
+func (to *Session) CreateCurrentUserToken(token tc.APITokenRequest, opts RequestOptions) (tc.APITokenCreatedResponse, toclientlib.ReqInf, error) {
+	var resp tc.APITokenCreatedResponse
+	reqInf, err := to.post(apiUserCurrentTokens, opts, token, &resp)
+	return resp, reqInf, err
+}
+
+// DeleteCurrentUserToken revokes the currently authenticated User's personal
+// access token with the given ID.
+func (to *Session) DeleteCurrentUserToken(id int, opts RequestOptions) (tc.APITokenResponse, toclientlib.ReqInf, error) {
+	var resp tc.APITokenResponse
+	route := fmt.Sprintf("%s/%d", apiUserCurrentTokens, id)
+	reqInf, err := to.del(route, opts, &resp)
+	return resp, reqInf, err
+}
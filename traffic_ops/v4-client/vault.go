@@ -15,6 +15,8 @@ package client
 */
 
 import (
+	"net/url"
+
 	"github.com/apache/trafficcontrol/lib/go-tc"
 	"github.com/apache/trafficcontrol/traffic_ops/toclientlib"
 )
@@ -22,6 +24,10 @@ import (
 const (
 	// apiVaultPing is the partial path (excluding the /api/<version> prefix) to the /vault/ping API endpoint.
 	apiVaultPing = "/vault/ping"
+	// apiVaultKeys is the partial path (excluding the /api/<version> prefix) to the /vault/keys API endpoint.
+	apiVaultKeys = "/vault/keys"
+	// apiVaultKeysReencrypt is the partial path (excluding the /api/<version> prefix) to the /vault/keys/reencrypt API endpoint.
+	apiVaultKeysReencrypt = apiVaultKeys + "/reencrypt"
 )
 
 // TrafficVaultPing returns a response indicating whether or not Traffic Vault is responsive.
@@ -30,3 +36,28 @@ func (to *Session) TrafficVaultPing(opts RequestOptions) (tc.TrafficVaultPingRes
 	reqInf, err := to.get(apiVaultPing, opts, &data)
 	return data, reqInf, err
 }
+
+// GetTrafficVaultEncryptionKeys returns the Traffic Vault encryption keys,
+// along with the number of secrets encrypted with each.
+func (to *Session) GetTrafficVaultEncryptionKeys(opts RequestOptions) (tc.TrafficVaultEncryptionKeysResponse, toclientlib.ReqInf, error) {
+	var data tc.TrafficVaultEncryptionKeysResponse
+	reqInf, err := to.get(apiVaultKeys, opts, &data)
+	return data, reqInf, err
+}
+
+// ReencryptTrafficVaultSecrets queues an asynchronous job which re-encrypts
+// every Traffic Vault secret not encrypted with the current encryption key,
+// and returns without waiting for it to finish.
+func (to *Session) ReencryptTrafficVaultSecrets(opts RequestOptions) (tc.AsyncStatusResponse, toclientlib.ReqInf, error) {
+	var resp tc.AsyncStatusResponse
+	reqInf, err := to.post(apiVaultKeysReencrypt, opts, nil, &resp)
+	return resp, reqInf, err
+}
+
+// RetireTrafficVaultEncryptionKey retires the Traffic Vault encryption key
+// with the given ID.
+func (to *Session) RetireTrafficVaultEncryptionKey(id string, opts RequestOptions) (tc.Alerts, toclientlib.ReqInf, error) {
+	var alerts tc.Alerts
+	reqInf, err := to.del(apiVaultKeys+"/"+url.PathEscape(id), opts, &alerts)
+	return alerts, reqInf, err
+}
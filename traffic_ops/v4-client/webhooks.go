@@ -0,0 +1,65 @@
+package client
+
+/*
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/toclientlib"
+)
+
+// apiWebhooks is the API version-relative path for the /webhooks API endpoint.
+const apiWebhooks = "/webhooks"
+
+// CreateWebhook creates the given Webhook.
+func (to *Session) CreateWebhook(webhook tc.Webhook, opts RequestOptions) (tc.WebhookResponse, toclientlib.ReqInf, error) {
+	var response tc.WebhookResponse
+	reqInf, err := to.post(apiWebhooks, opts, webhook, &response)
+	return response, reqInf, err
+}
+
+// GetWebhooks retrieves Webhooks from Traffic Ops.
+func (to *Session) GetWebhooks(opts RequestOptions) (tc.WebhooksResponse, toclientlib.ReqInf, error) {
+	var data tc.WebhooksResponse
+	reqInf, err := to.get(apiWebhooks, opts, &data)
+	return data, reqInf, err
+}
+
+// UpdateWebhook replaces the Webhook identified by id with the one provided.
+func (to *Session) UpdateWebhook(id int, webhook tc.Webhook, opts RequestOptions) (tc.WebhookResponse, toclientlib.ReqInf, error) {
+	var response tc.WebhookResponse
+	route := fmt.Sprintf("%s/%d", apiWebhooks, id)
+	reqInf, err := to.put(route, opts, webhook, &response)
+	return response, reqInf, err
+}
+
+// DeleteWebhook deletes the Webhook with the given ID.
+func (to *Session) DeleteWebhook(id int, opts RequestOptions) (tc.WebhookResponse, toclientlib.ReqInf, error) {
+	var response tc.WebhookResponse
+	route := fmt.Sprintf("%s/%d", apiWebhooks, id)
+	reqInf, err := to.del(route, opts, &response)
+	return response, reqInf, err
+}
+
+// GetWebhookDeliveries retrieves the record of deliveries made to the Webhook
+// with the given ID.
+func (to *Session) GetWebhookDeliveries(id int, opts RequestOptions) (tc.WebhookDeliveriesResponse, toclientlib.ReqInf, error) {
+	var data tc.WebhookDeliveriesResponse
+	route := fmt.Sprintf("%s/%d/deliveries", apiWebhooks, id)
+	reqInf, err := to.get(route, opts, &data)
+	return data, reqInf, err
+}
@@ -94,7 +94,7 @@ var CDNService = function($http, locationUtils, messageModel, ENV) {
     this.queueServerUpdates = function(id) {
         return $http.post(ENV.api['root'] + 'cdns/' + id + '/queue_update', {action: "queue"}).then(
             function(result) {
-                messageModel.setMessages([{level: 'success', text: 'Queued CDN server updates'}], false);
+                messageModel.setMessages(result.data.alerts, false);
                 return result;
             },
             function(err) {
@@ -107,7 +107,7 @@ var CDNService = function($http, locationUtils, messageModel, ENV) {
     this.clearServerUpdates = function(id) {
         return $http.post(ENV.api['root'] + 'cdns/' + id + '/queue_update', {action: "dequeue"}).then(
             function(result) {
-                messageModel.setMessages([{ level: 'success', text: 'Cleared CDN server updates'}], false);
+                messageModel.setMessages(result.data.alerts, false);
                 return result;
             },
             function(err) {
@@ -178,7 +178,7 @@ var CDNService = function($http, locationUtils, messageModel, ENV) {
     this.snapshot = function(cdn) {
         return $http.put(ENV.api['root'] + 'snapshot', undefined, {params: {cdnID: cdn.id}}).then(
             function(result) {
-                messageModel.setMessages([{level: 'success', text: 'Snapshot performed'}], true);
+                messageModel.setMessages(result.data.alerts, true);
                 locationUtils.navigateToPath('/cdns/' + cdn.id);
                 return result;
             },
@@ -17,7 +17,7 @@
  * under the License.
  */
 
-var ToolsService = function($http, messageModel, ENV) {
+var ToolsService = function($http, $timeout, messageModel, ENV) {
 
 	this.getOSVersions = function() {
 		return $http.get(ENV.api['root'] + "osversions").then(
@@ -30,18 +30,47 @@ var ToolsService = function($http, messageModel, ENV) {
 			);
 	};
 
-	this.generateISO = function(iso) {
-		respType = 'arraybuffer';
+	// waitForJob polls the status of the asynchronous job with the given ID
+	// until it finishes, and resolves with its final status.
+	var waitForJob = function(id) {
+		return $http.get(ENV.api['root'] + "async_status/" + id).then(
+			function(result) {
+				var status = result.data.response;
+				if (status.status === "PENDING" || status.status === "RUNNING") {
+					return $timeout(function() { return waitForJob(id); }, 2000);
+				}
+				return status;
+			}
+		);
+	};
 
-		return $http.post(ENV.api['root'] + "isos", iso, { responseType:respType }).then(
+	this.generateISO = function(iso) {
+		return $http.post(ENV.api['root'] + "isos", iso).then(
+			function(result) {
+				messageModel.setMessages(result.data.alerts, false);
+				return waitForJob(result.data.response.id);
+			}
+		).then(
+			function(status) {
+				if (status.status !== "SUCCEEDED") {
+					messageModel.setMessages([ { level: 'error', text: status.message } ], false);
+					throw status;
+				}
+				return $http.get(status.artifact, { responseType: 'arraybuffer' });
+			}
+		).then(
 			function(result) {
 				const isoName = iso.hostName + "." + iso.domainName + "-" + iso.osversionDir + ".iso";
 				download(result.data, isoName);
-				return result.data.response;
+				messageModel.setMessages([ { level: 'success', text: 'Generated ' + isoName } ], false);
+				return result.data;
 			},
 			function(err) {
-				// apparently there are no alerts sent from this endpoint
-				messageModel.setMessages([ { level: 'error', text: err.status.toString() + ': ' + err.statusText } ], false);
+				if (err.data && err.data.alerts) {
+					messageModel.setMessages(err.data.alerts, false);
+				} else if (err.status !== undefined && err.statusText !== undefined) {
+					messageModel.setMessages([ { level: 'error', text: err.status.toString() + ': ' + err.statusText } ], false);
+				}
 				throw err;
 			}
 		);
@@ -49,5 +78,5 @@ var ToolsService = function($http, messageModel, ENV) {
 
 };
 
-ToolsService.$inject = ['$http', 'messageModel', 'ENV'];
+ToolsService.$inject = ['$http', '$timeout', 'messageModel', 'ENV'];
 module.exports = ToolsService;